@@ -9,8 +9,12 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
 	"time"
 
 	"golang.org/x/net/http2"
@@ -30,11 +34,69 @@ type Sender interface {
 	Send(ctx *Context, code int, data any) error
 }
 
+// DefaultSender is a built-in Sender type. It sends []byte as "application/octet-stream"
+// (if no Content-Type set), string as "text/plain", error with ctx.Error, and other data
+// as JSON or XML with content negotiation by Accept header, JSON is the default.
+// YAML is negotiated too if YAMLMarshal is set, such as yaml.Marshal from "gopkg.in/yaml.v3".
+//
+//	app.Set(gear.SetSender, gear.DefaultSender{})
+//	app.Set(gear.SetSender, gear.DefaultSender{YAMLMarshal: yaml.Marshal})
+type DefaultSender struct {
+	YAMLMarshal func(v any) ([]byte, error)
+}
+
+// Send implemented Sender interface.
+// MessagePack is negotiated too if a "msgpack" marshaler is registered by RegisterBodyMarshaler.
+func (d DefaultSender) Send(ctx *Context, code int, data any) error {
+	switch v := data.(type) {
+	case []byte:
+		if ctx.Res.Get(HeaderContentType) == "" {
+			ctx.Type(MIMEOctetStream)
+		}
+		return ctx.End(code, v)
+	case string:
+		ctx.Type(MIMETextPlainCharsetUTF8)
+		return ctx.End(code, []byte(v))
+	case error:
+		return ctx.Error(v)
+	}
+
+	offers := []string{MIMEApplicationJSON, MIMEApplicationXML, MIMETextXML}
+	if d.YAMLMarshal != nil {
+		offers = append(offers, MIMEApplicationYAML, MIMETextYAML)
+	}
+	if bodyMarshaler("msgpack") != nil {
+		offers = append(offers, MIMEApplicationMsgPack, "application/x-msgpack")
+	}
+	switch ctx.AcceptType(offers...) {
+	case MIMEApplicationXML, MIMETextXML:
+		return ctx.XML(code, data)
+	case MIMEApplicationMsgPack, "application/x-msgpack":
+		return ctx.MsgPack(code, data)
+	case MIMEApplicationYAML, MIMETextYAML:
+		buf, err := d.YAMLMarshal(data)
+		if err != nil {
+			return err
+		}
+		ctx.Type(MIMEApplicationYAMLCharsetUTF8)
+		return ctx.End(code, buf)
+	default:
+		return ctx.JSON(code, data)
+	}
+}
+
 // Renderer interface is used by ctx.Render.
 type Renderer interface {
 	Render(ctx *Context, w io.Writer, name string, data any) error
 }
 
+// FuncsRenderer is a Renderer that accepts template funcs. When it is set by SetRenderer,
+// app.TemplateFuncs() will be injected to it by SetFuncs.
+type FuncsRenderer interface {
+	Renderer
+	SetFuncs(funcs map[string]any)
+}
+
 // URLParser interface is used by ctx.ParseUrl. Default to:
 //
 //	app.Set(gear.SetURLParser, gear.DefaultURLParser)
@@ -59,8 +121,8 @@ type BodyParser interface {
 	Parse(buf []byte, body any, mediaType, charset string) error
 }
 
-// DefaultBodyParser is default BodyParser type.
-// SetBodyParser used 1MB as default:
+// DefaultBodyParser is default BodyParser type. It parses JSON, XML and form (application/x-www-form-urlencoded)
+// body, the form body is parsed by ValuesToStruct with the "form" tag. SetBodyParser used 1MB as default:
 //
 //	app.Set(gear.SetBodyParser, gear.DefaultBodyParser(1<<20))
 type DefaultBodyParser int64
@@ -96,11 +158,130 @@ func (d DefaultBodyParser) Parse(buf []byte, body any, mediaType, charset string
 		}
 	case strings.HasPrefix(mediaType, MIMEApplicationXML), isLikeMediaType(mediaType, "xml"):
 		return xml.Unmarshal(buf, body)
+	case mediaType == MIMEApplicationForm:
+		values, err := url.ParseQuery(string(buf))
+		if err != nil {
+			return err
+		}
+		return ValuesToStruct(values, body, "form")
 	}
 
+	if unmarshal := bodyUnmarshaler(mediaType); unmarshal != nil {
+		return unmarshal(buf, body)
+	}
 	return ErrUnsupportedMediaType.WithMsgf("unsupported media type: %s", mediaType)
 }
 
+// ProtoBodyParser is a BodyParser that parses "application/protobuf" and "application/x-protobuf" body
+// with Unmarshal, and other body with the embedded DefaultBodyParser. It doesn't add dependencies to gear:
+//
+//	import "google.golang.org/protobuf/proto"
+//
+//	app.Set(gear.SetBodyParser, gear.ProtoBodyParser{
+//		DefaultBodyParser: 1 << 20,
+//		Unmarshal: func(buf []byte, body any) error {
+//			return proto.Unmarshal(buf, body.(proto.Message))
+//		},
+//	})
+//
+// The body template given to ctx.ParseBody should be a proto.Message that implements BodyTemplate too.
+type ProtoBodyParser struct {
+	DefaultBodyParser
+	Unmarshal func(buf []byte, body any) error
+}
+
+// Parse implemented BodyParser interface.
+func (p ProtoBodyParser) Parse(buf []byte, body any, mediaType, charset string) error {
+	if mediaType != MIMEApplicationProtobuf && mediaType != "application/x-protobuf" {
+		return p.DefaultBodyParser.Parse(buf, body, mediaType, charset)
+	}
+	if len(buf) == 0 {
+		return ErrBadRequest.WithMsg("request entity empty")
+	}
+	if p.Unmarshal == nil {
+		return ErrUnsupportedMediaType.WithMsgf("unsupported media type: %s", mediaType)
+	}
+	return p.Unmarshal(buf, body)
+}
+
+var bodyUnmarshalers = struct {
+	sync.RWMutex
+	m map[string]func(buf []byte, body any) error
+}{m: make(map[string]func(buf []byte, body any) error)}
+
+// RegisterBodyUnmarshaler registers an unmarshal function for the format (such as "yaml" or "toml") to
+// DefaultBodyParser, so ctx.ParseBody can decode the request body with media type "application/{format}",
+// "application/x-{format}", "text/{format}" or "application/*+{format}". It doesn't add dependencies to gear:
+//
+//	import (
+//		"github.com/BurntSushi/toml"
+//		"gopkg.in/yaml.v3"
+//	)
+//
+//	gear.RegisterBodyUnmarshaler("yaml", yaml.Unmarshal)
+//	gear.RegisterBodyUnmarshaler("toml", toml.Unmarshal)
+//
+// The body is validated by BodyTemplate.Validate as JSON and XML body, and unmarshal errors are responded with 400.
+// The "json" and "xml" formats are builtin and can't be registered.
+func RegisterBodyUnmarshaler(format string, fn func(buf []byte, body any) error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" || format == "json" || format == "xml" || fn == nil {
+		panic(Err.WithMsgf("invalid body unmarshaler for %q", format))
+	}
+	bodyUnmarshalers.Lock()
+	bodyUnmarshalers.m[format] = fn
+	bodyUnmarshalers.Unlock()
+}
+
+// bodyUnmarshaler returns the registered unmarshal function for the media type, or nil if not registered.
+func bodyUnmarshaler(mediaType string) func(buf []byte, body any) error {
+	format := mediaType
+	if i := strings.LastIndexByte(format, '+'); i >= 0 && strings.HasPrefix(format, "application/") {
+		format = format[i+1:]
+	} else if i := strings.IndexByte(format, '/'); i >= 0 {
+		format = strings.TrimPrefix(format[i+1:], "x-")
+		if t := mediaType[:i]; t != "application" && t != "text" {
+			return nil
+		}
+	}
+
+	bodyUnmarshalers.RLock()
+	defer bodyUnmarshalers.RUnlock()
+	return bodyUnmarshalers.m[format]
+}
+
+var bodyMarshalers = struct {
+	sync.RWMutex
+	m map[string]func(v any) ([]byte, error)
+}{m: make(map[string]func(v any) ([]byte, error))}
+
+// RegisterBodyMarshaler registers a marshal function for the format (such as "msgpack") to encode
+// the response body, it is used by ctx.MsgPack and DefaultSender. Register an unmarshal function with
+// RegisterBodyUnmarshaler to parse the request body of the format too. It doesn't add dependencies to gear:
+//
+//	import "github.com/vmihailenco/msgpack/v5"
+//
+//	gear.RegisterBodyMarshaler("msgpack", msgpack.Marshal)
+//	gear.RegisterBodyUnmarshaler("msgpack", msgpack.Unmarshal)
+//
+// The "json" and "xml" formats are builtin and can't be registered.
+func RegisterBodyMarshaler(format string, fn func(v any) ([]byte, error)) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" || format == "json" || format == "xml" || fn == nil {
+		panic(Err.WithMsgf("invalid body marshaler for %q", format))
+	}
+	bodyMarshalers.Lock()
+	bodyMarshalers.m[format] = fn
+	bodyMarshalers.Unlock()
+}
+
+// bodyMarshaler returns the registered marshal function for the format, or nil if not registered.
+func bodyMarshaler(format string) func(v any) ([]byte, error) {
+	bodyMarshalers.RLock()
+	defer bodyMarshalers.RUnlock()
+	return bodyMarshalers.m[format]
+}
+
 // HTTPError interface is used to create a server error that include status code and error message.
 type HTTPError interface {
 	// Error returns error's message.
@@ -126,8 +307,26 @@ type HTTPError interface {
 //	}
 type App struct {
 	Server *http.Server
-	mds    middlewares
+	appConfig
+
+	// the middleware stack and runtime states, they are not inherited by app.Clone.
+	mds         middlewares
+	names       []string
+	routers     []*Router
+	failedHooks atomic.Uint64
+	aborted     atomic.Uint64
+	slowHooks   atomic.Uint64
+	largeRes    atomic.Uint64
+	closing     atomic.Bool
+	draining    chan struct{} // closed when closing, see ctx.OnShutdown
+	degraded    atomic.Pointer[Degradation]
+	h3          atomic.Pointer[HTTP3Server]
+	redirect    atomic.Pointer[http.Server]
+}
 
+// appConfig is the configuration of an App, it is copied by value by app.Clone,
+// then the maps, slices and pointers that can be mutated are deep copied.
+type appConfig struct {
 	keys        []string
 	renderer    Renderer
 	sender      Sender
@@ -141,6 +340,23 @@ type App struct {
 	renderError func(HTTPError) (code int, contentType string, body []byte)
 	onerror     func(*Context, HTTPError)
 	withContext func(*http.Request) context.Context
+	transformer func(*Context, any) any
+	diagnostics *DiagnosticHeaders
+	sniffBody   bool
+	charsets    map[string]CharsetEncoder // never mutated after set
+	assetURL    func(name string) string
+	slowAfter   time.Duration
+	readiness   *readiness
+	conns       *connLimiter
+	etag        ETagMode
+	pbMarshal   func(m any) ([]byte, error)
+	newH3       func(addr string, handler http.Handler) HTTP3Server
+	crash       *crashReporter
+	maxResBytes int64
+	onLargeRes  func(ctx *Context, size int64) error
+	deprecated  *deprecations
+	validateTag bool
+	validators  []func(app *App) error
 	settings    map[any]any
 }
 
@@ -155,6 +371,7 @@ func New() *App {
 	app.Server.IdleTimeout = 90 * time.Second
 
 	app.mds = make(middlewares, 0)
+	app.draining = make(chan struct{})
 	app.settings = make(map[any]any)
 
 	env := os.Getenv("APP_ENV")
@@ -164,6 +381,7 @@ func New() *App {
 	app.Set(SetEnv, env)
 	app.Set(SetServerName, "Gear/"+Version)
 	app.Set(SetTrustedProxy, false)
+	app.Set(SetBodySniffing, false)
 	app.Set(SetBodyParser, DefaultBodyParser(2<<20)) // 2MB
 	app.Set(SetURLParser, DefaultURLParser{})
 	app.Set(SetLogger, log.New(os.Stderr, "", 0))
@@ -178,18 +396,85 @@ func New() *App {
 	return app
 }
 
-// Use uses the given middleware `handle`.
+// Clone returns a new App instance sharing the settings, parsers, renderer and logger of the app,
+// but with an independent middleware stack and a new http.Server with the same timeouts.
+// Settings, validators and readiness gates changed on the clone don't affect the original app, and vice versa.
+// It is useful to serve an internal admin listener and a public listener from one configured base:
+//
+//	base := gear.New()
+//	base.Set(gear.SetEnv, "production")
+//	base.Set(gear.SetTimeout, 3*time.Second)
+//
+//	public := base.Clone()
+//	public.UseHandler(publicRouter)
+//	admin := base.Clone()
+//	admin.UseHandler(adminRouter)
+//
+//	go admin.Listen("127.0.0.1:3001")
+//	public.Listen(":3000")
+//
+// Note that template funcs injected into a FuncsRenderer (see app.TemplateFuncs) are bound to the app
+// that set the renderer. Clone should be called before the app starts serving, app.Server is in use then.
+func (app *App) Clone() *App {
+	c := new(App)
+	c.Server = &http.Server{
+		ReadHeaderTimeout: app.Server.ReadHeaderTimeout,
+		ReadTimeout:       app.Server.ReadTimeout,
+		WriteTimeout:      app.Server.WriteTimeout,
+		IdleTimeout:       app.Server.IdleTimeout,
+		MaxHeaderBytes:    app.Server.MaxHeaderBytes,
+	}
+	if app.Server.TLSConfig != nil {
+		c.Server.TLSConfig = app.Server.TLSConfig.Clone()
+	}
+	c.mds = make(middlewares, 0)
+	c.draining = make(chan struct{})
+
+	c.appConfig = app.appConfig
+	c.validators = append([]func(app *App) error(nil), app.validators...)
+	if app.readiness != nil {
+		c.readiness = app.readiness.clone()
+	}
+	if app.conns != nil {
+		c.conns = app.conns.clone()
+	}
+	if app.crash != nil {
+		c.crash = newCrashReporter(app.crash.dir)
+	}
+	if app.deprecated != nil {
+		c.deprecated = newDeprecations(app.deprecated.hook)
+	}
+	c.settings = make(map[any]any, len(app.settings))
+	for k, v := range app.settings {
+		c.settings[k] = v
+	}
+	return c
+}
+
+// Use uses the given middleware `handle`. Use gear.Named to give it a stable name.
 func (app *App) Use(handle Middleware) *App {
 	app.mds = append(app.mds, handle)
+	app.names = append(app.names, MiddlewareName(handle))
 	return app
 }
 
 // UseHandler uses a instance that implemented Handler interface.
 func (app *App) UseHandler(h Handler) *App {
+	if router, ok := h.(*Router); ok {
+		app.routers = append(app.routers, router)
+	}
 	app.mds = append(app.mds, h.Serve)
+	app.names = append(app.names, handlerName(h))
 	return app
 }
 
+// Middlewares returns the names of the middlewares used by the app in order.
+// The name is the name given by gear.Named, or the function name of the middleware,
+// or the type name of the Handler used by app.UseHandler, such as "*gear.Router".
+func (app *App) Middlewares() []string {
+	return append([]string{}, app.names...)
+}
+
 type appSetting uint8
 
 // Build-in app settings
@@ -244,6 +529,10 @@ const (
 	//
 	//  app.Set(gear.SetRenderError, gear.RenderErrorResponse)
 	//
+	// or render the machine-readable "reason" and numeric "status" fields with NewRenderError:
+	//
+	//  app.Set(gear.SetRenderError, gear.NewRenderError(gear.RenderErrorOptions{Status: true, DefaultReason: true}))
+	//
 	SetRenderError
 
 	// Set a on-error hook to app that handle middleware error.
@@ -264,6 +553,8 @@ const (
 	// Set a timeout to for the middleware process, value should be `time.Duration`. No default.
 	// Example:
 	//  app.Set(gear.SetTimeout, 3*time.Second)
+	//
+	// It can be replaced for the routes by RouterOptions.Timeout, gear.Timeout and ctx.SetTimeout.
 	SetTimeout
 
 	// Set a graceful timeout to for gracefully shuts down, value should be `time.Duration`. Default to 10*time.Second.
@@ -285,9 +576,160 @@ const (
 	// Set true and proxy header fields will be trusted
 	// Default to false.
 	SetTrustedProxy
+
+	// Set a transform hook to app that will be applied to the data before marshal,
+	// it will be used by `ctx.JSON`, `ctx.JSONP`, `ctx.XML` and `ctx.Send`. The hook runs at most once
+	// per request, so a Sender calling `ctx.JSON` will not transform data twice. The error, []byte and string
+	// values sent by `ctx.Send` are not transformed.
+	// Value should be `func(ctx *Context, data any) any`, no default value. Example:
+	//  app.Set(gear.SetResponseTransformer, func(ctx *gear.Context, data any) any {
+	//  	return map[string]any{"data": data}
+	//  })
+	SetResponseTransformer
+
+	// Set diagnostics headers that will be emitted on every response, value should be `gear.DiagnosticHeaders`,
+	// no default value. Example:
+	//  app.Set(gear.SetDiagnosticHeaders, gear.DiagnosticHeaders{
+	//  	RequestID: true,
+	//  	Runtime:   true,
+	//  	ServedBy:  true,
+	//  	RouteHash: true,
+	//  	Envs:      []string{"development", "staging"},
+	//  })
+	SetDiagnosticHeaders
+
+	// Set true to sniff the request body type when Content-Type is missing or "application/octet-stream",
+	// a body starts with "{" or "[" will be parsed as JSON, and "<" as XML. It will be used by `ctx.ParseBody`.
+	// Default to false, the body will be rejected with 415 by `gear.DefaultBodyParser`.
+	SetBodySniffing
+
+	// Set charset encoders to transcode text/* response body from UTF-8 to the charset negotiated by
+	// Accept-Charset header, value should be `map[string]gear.CharsetEncoder` with charset name as key.
+	// No default value, responds UTF-8 only. Example:
+	//  app.Set(gear.SetCharsetEncoders, map[string]gear.CharsetEncoder{
+	//  	"iso-8859-1": gear.EncodeLatin1,
+	//  	"gbk":        simplifiedchinese.GBK.NewEncoder().Bytes, // golang.org/x/text/encoding/simplifiedchinese
+	//  })
+	SetCharsetEncoders
+
+	// Set a function that returns the URL of a asset name, it will be used by the "asset" template func
+	// of `app.TemplateFuncs()`. Value should be `func(name string) string`, default to return the name. Example:
+	//  manifest, _ := static.NewManifest("./assets", "/assets")
+	//  app.Set(gear.SetAssetURL, manifest.URL)
+	SetAssetURL
+
+	// Set a path that responds the readiness of app (see app.AddReadinessGate), value should be `string`.
+	// It responds the readiness gates as the HealthStatus JSON like NewHealth, 200 after all gates passed,
+	// or 503 with the pending gates or after app.Close called, such as
+	// `{"status":"fail","checks":{"db":{"status":"fail","error":"connection refused"}}}`.
+	// No default value. Example:
+	//  app.Set(gear.SetReadinessPath, "/readyz")
+	SetReadinessPath
+
+	// Set true to reject requests with 503 until all readiness gates passed (see app.AddReadinessGate),
+	// except the request to SetReadinessPath. Default to false.
+	SetBlockUntilReady
+
+	// Set the max number of concurrent connections accepted by the app's listeners, value should be `int`.
+	// The connections over the limit will be closed immediately after accepted, before request parsing.
+	// Default to 0, no limit. Example:
+	//  app.Set(gear.SetMaxConnections, 10000)
+	SetMaxConnections
+
+	// Set the max number of concurrent connections per client IP, value should be `int`.
+	// The connections over the limit will be closed immediately after accepted, before request parsing.
+	// Default to 0, no limit.
+	SetMaxConnsPerIP
+
+	// Set the max number of new connections per second per client IP, value should be `int`,
+	// bursts up to the same number are allowed. The connections over the rate will be closed immediately
+	// after accepted, before request parsing. Default to 0, no limit.
+	// The rejected connections can be counted by app.Stats().RejectedConns.
+	SetConnRatePerIP
+
+	// Set to generate ETag for GET and HEAD responses sent by ctx.End (and ctx.JSON, ctx.HTML, etc.) automatically,
+	// value should be `gear.ETagMode`. With it, the responses (with the generated ETag, or the ETag and Last-Modified
+	// headers set by ctx.ETag, ctx.LastModified or the "after hooks") are evaluated against If-None-Match and
+	// If-Modified-Since request headers after the "after hooks" ran, and 304 Not Modified is responded if fresh.
+	// Without it, use ctx.Fresh to evaluate the conditional requests. No default value.
+	SetETag
+
+	// Set a protobuf marshal function to app, it will be used by `ctx.Proto`,
+	// value should be `func(m any) ([]byte, error)`. No default value. Such as:
+	//
+	//  app.Set(gear.SetProtoMarshal, func(m any) ([]byte, error) {
+	//  	return proto.Marshal(m.(proto.Message))
+	//  })
+	SetProtoMarshal
+
+	// Set a duration to watch the "after hooks" (added by ctx.After), value should be `time.Duration`.
+	// The "after hooks" block the response writing, so they should not do I/O. A warning with the route
+	// is logged if they run longer than the duration, and counted by app.Stats().SlowAfterHooks.
+	// No default value. Example:
+	//  app.Set(gear.SetSlowAfterHooks, 50*time.Millisecond)
+	SetSlowAfterHooks
+
+	// Set a function to create the HTTP/3 server for app.ListenHTTP3, value should be
+	// `func(addr string, handler http.Handler) gear.HTTP3Server`. No default value. Such as:
+	//
+	//  app.Set(gear.SetHTTP3Server, func(addr string, handler http.Handler) gear.HTTP3Server {
+	//  	return &http3.Server{Addr: addr, Handler: handler} // github.com/quic-go/quic-go/http3
+	//  })
+	SetHTTP3Server
+
+	// Set the error to respond when no middleware responded (such as no router matched the request),
+	// value should be `gear.HTTPError`. It is handled by SetOnError and SetRenderError settings like the other errors.
+	// Default to nil, the app responds 421 Misdirected Request. Example:
+	//  app.Set(gear.SetUnhandledError, gear.ErrNotFound.WithMsg("no route matched"))
+	SetUnhandledError
+
+	// Set a directory to write the crash reports, value should be `string`. A crash report is a JSON file with
+	// the stacks of all goroutines, the recent requests and the build info, it is written by app.RecoverCrash
+	// on an unrecovered panic outside of the request scope, and by app.Fatal. No default value. Example:
+	//  app.Set(gear.SetCrashReportDir, "/var/log/myapp")
+	SetCrashReportDir
+
+	// Set the max bytes of the response body (before compression), value should be `int`. The responses
+	// exceeding it are reported by SetOnLargeResponse hook (default to log a warning with the route) and counted
+	// by app.Stats().LargeResponses, so the accidental huge responses can be caught in production.
+	// No default value. Example:
+	//  app.Set(gear.SetMaxResponseBytes, 1<<20) // 1MB
+	SetMaxResponseBytes
+
+	// Set a hook to report the responses exceeding SetMaxResponseBytes setting for logging or metrics,
+	// value should be `func(ctx *Context, size int64) error`. It is called once for every exceeding response,
+	// the size is the body size of the captured response (sent by ctx.End, ctx.JSON, etc.), or the written bytes
+	// when the streaming response (written by Response.Write, such as ctx.Stream) exceeded. The response is sent
+	// if the hook returns nil, or aborted with the error: the captured response is replaced with the error
+	// response, the streaming response is truncated at the max bytes, and the following writes fail with
+	// the error. No default value. Example:
+	//  app.Set(gear.SetOnLargeResponse, func(ctx *gear.Context, size int64) error {
+	//  	largeResponses.WithLabelValues(gear.GetRouterPatternFromCtx(ctx)).Inc()
+	//  	return gear.ErrInternalServerError.WithMsgf("response too large: %d bytes", size)
+	//  })
+	SetOnLargeResponse
+
+	// Set a hook to report the uses of the deprecated APIs that will be changed in v2 (ctx.Protocol, and
+	// the trustedProxy argument of ctx.IP and ctx.Scheme), value should be `func(d gear.Deprecation)`.
+	// It is opt-in, the uses are counted by call site when set: a warning with the call site is logged once
+	// for the first use, and the hook is called for every use, for metrics. No default value. Example:
+	//  app.Set(gear.SetOnDeprecated, func(d gear.Deprecation) {
+	//  	deprecatedCalls.WithLabelValues(d.API, d.Caller).Inc()
+	//  })
+	SetOnDeprecated
+
+	// Set to validate the parsed body by the "validate" struct tags (see ValidateStruct) in ctx.ParseBody,
+	// ctx.ParseURL, ctx.ParseMultipart and ctx.ParseMergePatch before body.Validate(), value should be `bool`.
+	// It is opt-in, because the tags may be written for other validators. The invalid tags, such as an unknown
+	// rule, are logged once and skipped, add gear.CheckValidateTags validator to fail fast. Default to false.
+	// Example:
+	//  app.Set(gear.SetValidateTags, true)
+	//  app.AddValidator(gear.CheckValidateTags(createUserBody{}, updateUserBody{}))
+	SetValidateTags
 )
 
 // Set add key/value settings to app. The settings can be retrieved by `ctx.Setting(key)`.
+// The user-defined settings can use the typed SettingKey, the value type is checked by Set.
 func (app *App) Set(key, val any) *App {
 	if k, ok := key.(appSetting); ok {
 		switch key {
@@ -350,6 +792,9 @@ func (app *App) Set(key, val any) *App {
 				panic(Err.WithMsg("SetRenderer setting must implemented `gear.Renderer` interface"))
 			} else {
 				app.renderer = renderer
+				if r, ok := renderer.(FuncsRenderer); ok {
+					r.SetFuncs(app.TemplateFuncs())
+				}
 			}
 		case SetTimeout:
 			if timeout, ok := val.(time.Duration); !ok {
@@ -381,14 +826,197 @@ func (app *App) Set(key, val any) *App {
 			if _, ok := val.(bool); !ok {
 				panic(Err.WithMsg("SetTrustedProxy setting must be `bool`"))
 			}
+		case SetResponseTransformer:
+			if transformer, ok := val.(func(*Context, any) any); !ok {
+				panic(Err.WithMsg("SetResponseTransformer setting must be `func(*Context, any) any`"))
+			} else {
+				app.transformer = transformer
+			}
+		case SetDiagnosticHeaders:
+			if diagnostics, ok := val.(DiagnosticHeaders); !ok {
+				panic(Err.WithMsg("SetDiagnosticHeaders setting must be `gear.DiagnosticHeaders`"))
+			} else {
+				diagnostics.init()
+				app.diagnostics = &diagnostics
+			}
+		case SetBodySniffing:
+			if sniffBody, ok := val.(bool); !ok {
+				panic(Err.WithMsg("SetBodySniffing setting must be `bool`"))
+			} else {
+				app.sniffBody = sniffBody
+			}
+		case SetReadinessPath:
+			if _, ok := val.(string); !ok {
+				panic(Err.WithMsg("SetReadinessPath setting must be `string`"))
+			}
+		case SetBlockUntilReady:
+			if _, ok := val.(bool); !ok {
+				panic(Err.WithMsg("SetBlockUntilReady setting must be `bool`"))
+			}
+		case SetMaxConnections:
+			if n, ok := val.(int); !ok {
+				panic(Err.WithMsg("SetMaxConnections setting must be `int`"))
+			} else {
+				app.setConnLimit(k, n)
+			}
+		case SetMaxConnsPerIP:
+			if n, ok := val.(int); !ok {
+				panic(Err.WithMsg("SetMaxConnsPerIP setting must be `int`"))
+			} else {
+				app.setConnLimit(k, n)
+			}
+		case SetConnRatePerIP:
+			if n, ok := val.(int); !ok {
+				panic(Err.WithMsg("SetConnRatePerIP setting must be `int`"))
+			} else {
+				app.setConnLimit(k, n)
+			}
+		case SetETag:
+			if mode, ok := val.(ETagMode); !ok || (mode != ETagWeak && mode != ETagStrong) {
+				panic(Err.WithMsg("SetETag setting must be `gear.ETagWeak` or `gear.ETagStrong`"))
+			} else {
+				app.etag = mode
+			}
+		case SetSlowAfterHooks:
+			if d, ok := val.(time.Duration); !ok || d <= 0 {
+				panic(Err.WithMsg("SetSlowAfterHooks setting must be positive `time.Duration`"))
+			} else {
+				app.slowAfter = d
+			}
+		case SetProtoMarshal:
+			if marshal, ok := val.(func(m any) ([]byte, error)); !ok || marshal == nil {
+				panic(Err.WithMsg("SetProtoMarshal setting must be `func(m any) ([]byte, error)`"))
+			} else {
+				app.pbMarshal = marshal
+			}
+		case SetHTTP3Server:
+			if newH3, ok := val.(func(string, http.Handler) HTTP3Server); !ok || newH3 == nil {
+				panic(Err.WithMsg("SetHTTP3Server setting must be `func(addr string, handler http.Handler) gear.HTTP3Server`"))
+			} else {
+				app.newH3 = newH3
+			}
+		case SetAssetURL:
+			if assetURL, ok := val.(func(string) string); !ok {
+				panic(Err.WithMsg("SetAssetURL setting must be `func(name string) string`"))
+			} else {
+				app.assetURL = assetURL
+			}
+		case SetCharsetEncoders:
+			if encoders, ok := val.(map[string]CharsetEncoder); !ok {
+				panic(Err.WithMsg("SetCharsetEncoders setting must be `map[string]gear.CharsetEncoder`"))
+			} else {
+				app.charsets = make(map[string]CharsetEncoder, len(encoders))
+				for cs, encoder := range encoders {
+					app.charsets[strings.ToLower(cs)] = encoder
+				}
+			}
+		case SetUnhandledError:
+			if err, ok := val.(HTTPError); !ok || IsNil(err) {
+				panic(Err.WithMsg("SetUnhandledError setting must be `gear.HTTPError`"))
+			}
+		case SetCrashReportDir:
+			if dir, ok := val.(string); !ok || dir == "" {
+				panic(Err.WithMsg("SetCrashReportDir setting must be a non-empty `string`"))
+			} else {
+				app.crash = newCrashReporter(dir)
+			}
+		case SetMaxResponseBytes:
+			if n, ok := val.(int); !ok || n <= 0 {
+				panic(Err.WithMsg("SetMaxResponseBytes setting must be positive `int`"))
+			} else {
+				app.maxResBytes = int64(n)
+			}
+		case SetOnLargeResponse:
+			if hook, ok := val.(func(*Context, int64) error); !ok || hook == nil {
+				panic(Err.WithMsg("SetOnLargeResponse setting must be `func(ctx *Context, size int64) error`"))
+			} else {
+				app.onLargeRes = hook
+			}
+		case SetOnDeprecated:
+			if hook, ok := val.(func(Deprecation)); !ok || hook == nil {
+				panic(Err.WithMsg("SetOnDeprecated setting must be `func(d gear.Deprecation)`"))
+			} else {
+				app.deprecated = newDeprecations(hook)
+			}
+		case SetValidateTags:
+			if validateTag, ok := val.(bool); !ok {
+				panic(Err.WithMsg("SetValidateTags setting must be `bool`"))
+			} else {
+				app.validateTag = validateTag
+			}
 		}
 		app.settings[k] = val
 		return app
 	}
+	if k, ok := key.(typedSetting); ok {
+		k.check(val)
+	}
 	app.settings[key] = val
 	return app
 }
 
+// URL returns the URL path of the named route with params, from the routers used by app.UseHandler.
+// See router.URL.
+func (app *App) URL(name string, params ...any) (string, error) {
+	for _, router := range app.routers {
+		if _, ok := router.names[name]; ok {
+			return router.URL(name, params...)
+		}
+	}
+	return "", Err.WithMsgf("route %q not found", name)
+}
+
+// URLWith returns the URL of the named route with params by name and query, from the routers used by app.UseHandler.
+// See router.URLWith.
+func (app *App) URLWith(name string, params map[string]string, query url.Values) (string, error) {
+	for _, router := range app.routers {
+		if _, ok := router.names[name]; ok {
+			return router.URLWith(name, params, query)
+		}
+	}
+	return "", Err.WithMsgf("route %q not found", name)
+}
+
+// PrintRoutes prints a table of the routes registered on the routers used by app.UseHandler, with method, pattern,
+// handler name and the number of middlewares (of the router, groups and route) in registration order.
+// It is useful to print at startup when composing many routers and groups:
+//
+//	app.PrintRoutes(os.Stdout)
+//	app.Error(app.Listen(":3000"))
+//
+// The output looks like:
+//
+//	METHOD  PATTERN         HANDLER       MIDDLEWARES
+//	GET     /api/users/:id  main.GetUser  2
+func (app *App) PrintRoutes(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATTERN\tHANDLER\tMIDDLEWARES")
+	for _, router := range app.routers {
+		for _, route := range router.routes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", route.method, router.rt+route.pattern, route.handler, route.mds())
+		}
+	}
+	return tw.Flush()
+}
+
+// TemplateFuncs returns the template helper funcs that can be used by template.Template.Funcs:
+//
+//	{{ route "user.show" .ID }} // reverses the named route URL by app.URL
+//	{{ asset "js/app.js" }}     // returns the asset URL by SetAssetURL setting
+//
+// It will be injected to the FuncsRenderer automatically by SetRenderer.
+func (app *App) TemplateFuncs() map[string]any {
+	return map[string]any{
+		"route": app.URL,
+		"asset": func(name string) string {
+			if app.assetURL == nil {
+				return name
+			}
+			return app.assetURL(name)
+		},
+	}
+}
+
 // Env returns app' env. You can set app env with `app.Set(gear.SetEnv, "some env")`
 // Default to os process "APP_ENV" or "development".
 func (app *App) Env() string {
@@ -397,18 +1025,50 @@ func (app *App) Env() string {
 
 // Listen starts the HTTP server.
 func (app *App) Listen(addr string) error {
+	if err := app.prepare(h2c.NewHandler(app, &http2.Server{})); err != nil {
+		return err
+	}
+	return app.listen(addr)
+}
+
+func (app *App) listen(addr string) error {
 	app.Server.Addr = addr
-	app.Server.ErrorLog = app.logger
-	app.Server.Handler = h2c.NewHandler(app, &http2.Server{})
-	return app.Server.ListenAndServe()
+	app.runReadinessGates()
+	if app.conns == nil {
+		return app.Server.ListenAndServe()
+	}
+	if addr == "" {
+		addr = ":http"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return app.Server.Serve(app.limitListener(l))
 }
 
 // ListenTLS starts the HTTPS server.
 func (app *App) ListenTLS(addr, certFile, keyFile string) error {
+	if err := app.prepare(app); err != nil {
+		return err
+	}
+	return app.listenTLS(addr, certFile, keyFile)
+}
+
+func (app *App) listenTLS(addr, certFile, keyFile string) error {
 	app.Server.Addr = addr
-	app.Server.ErrorLog = app.logger
-	app.Server.Handler = app
-	return app.Server.ListenAndServeTLS(certFile, keyFile)
+	app.runReadinessGates()
+	if app.conns == nil {
+		return app.Server.ListenAndServeTLS(certFile, keyFile)
+	}
+	if addr == "" {
+		addr = ":https"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return app.Server.ServeTLS(app.limitListener(l), certFile, keyFile)
 }
 
 // ListenWithContext starts the HTTP server (or HTTPS server with keyPair) with a context
@@ -424,6 +1084,14 @@ func (app *App) ListenTLS(addr, certFile, keyFile string) error {
 //		  // app.ListenWithContext(gear.ContextWithSignal(context.Background()), addr, certFile, keyFile)
 //	 }
 func (app *App) ListenWithContext(ctx context.Context, addr string, keyPair ...string) error {
+	useTLS := len(keyPair) >= 2 && keyPair[0] != "" && keyPair[1] != ""
+	var handler http.Handler = app
+	if !useTLS {
+		handler = h2c.NewHandler(app, &http2.Server{})
+	}
+	if err := app.prepare(handler); err != nil {
+		return err
+	}
 	timeout := app.settings[SetGraceTimeout].(time.Duration)
 	go func() {
 		<-ctx.Done()
@@ -434,10 +1102,10 @@ func (app *App) ListenWithContext(ctx context.Context, addr string, keyPair ...s
 		}
 	}()
 
-	if len(keyPair) >= 2 && keyPair[0] != "" && keyPair[1] != "" {
-		return app.ListenTLS(addr, keyPair[0], keyPair[1])
+	if useTLS {
+		return app.listenTLS(addr, keyPair[0], keyPair[1])
 	}
-	return app.Listen(addr)
+	return app.listen(addr)
 }
 
 // ServeWithContext accepts incoming connections on the Listener l, starts the HTTP server (or HTTPS server with keyPair) with a context
@@ -457,7 +1125,29 @@ func (app *App) ListenWithContext(ctx context.Context, addr string, keyPair ...s
 //		  // starts the HTTPS server.
 //		  // app.ServeWithContext(gear.ContextWithSignal(context.Background()), l, certFile, keyFile)
 //	 }
+//
+// If l is nil, the first listener passed by systemd socket activation (see SystemdListeners) is used,
+// the others are closed:
+//
+//	app.ServeWithContext(gear.ContextWithSignal(context.Background()), nil)
 func (app *App) ServeWithContext(ctx context.Context, l net.Listener, keyPair ...string) error {
+	if err := app.prepare(app); err != nil {
+		return err
+	}
+	if l == nil {
+		listeners, err := SystemdListeners()
+		if err != nil {
+			return err
+		}
+		if len(listeners) == 0 {
+			return Err.WithMsg("no listener passed by systemd socket activation")
+		}
+		for _, other := range listeners[1:] {
+			other.Close()
+		}
+		l = listeners[0]
+	}
+
 	timeout := app.settings[SetGraceTimeout].(time.Duration)
 	go func() {
 		<-ctx.Done()
@@ -468,8 +1158,8 @@ func (app *App) ServeWithContext(ctx context.Context, l net.Listener, keyPair ..
 		}
 	}()
 
-	app.Server.ErrorLog = app.logger
-	app.Server.Handler = app
+	app.runReadinessGates()
+	l = app.limitListener(l)
 	if len(keyPair) >= 2 && keyPair[0] != "" && keyPair[1] != "" {
 		return app.Server.ServeTLS(l, keyPair[0], keyPair[1])
 	}
@@ -484,21 +1174,64 @@ func (app *App) Start(addr ...string) *ServerListener {
 	if len(addr) > 0 && addr[0] != "" {
 		laddr = addr[0]
 	}
-	app.Server.ErrorLog = app.logger
-	app.Server.Handler = app
 
 	l, err := net.Listen("tcp", laddr)
 	if err != nil {
 		panic(Err.WithMsgf("failed to listen on %v: %v", laddr, err))
 	}
 
-	c := make(chan error)
+	c := make(chan error, 1)
+	if err := app.prepare(app); err != nil {
+		l.Close()
+		c <- err
+		return &ServerListener{l, c}
+	}
+	app.runReadinessGates()
 	go func() {
-		c <- app.Server.Serve(l)
+		c <- app.Server.Serve(app.limitListener(l))
 	}()
 	return &ServerListener{l, c}
 }
 
+// AppStats is the runtime statistics of the app, returned by app.Stats.
+type AppStats struct {
+	// The number of "end hooks" (registered by ctx.OnEnd) that panicked.
+	// The panics are recovered and written by app.Error with the request method, path, route and request id.
+	FailedHooks uint64
+	// The number of connections rejected by SetMaxConnections, SetMaxConnsPerIP and SetConnRatePerIP settings.
+	RejectedConns uint64
+	// The number of requests aborted by clients (closed before responding, responded with 499)
+	// or by handlers (panicked with http.ErrAbortHandler). They are not server errors and not logged by app.Error.
+	AbortedRequests uint64
+	// The number of requests whose "after hooks" run longer than SetSlowAfterHooks setting.
+	SlowAfterHooks uint64
+	// The number of responses exceeding SetMaxResponseBytes setting.
+	LargeResponses uint64
+}
+
+// Stats returns the runtime statistics of the app.
+func (app *App) Stats() AppStats {
+	stats := AppStats{
+		FailedHooks:     app.failedHooks.Load(),
+		AbortedRequests: app.aborted.Load(),
+		SlowAfterHooks:  app.slowHooks.Load(),
+		LargeResponses:  app.largeRes.Load(),
+	}
+	if app.conns != nil {
+		stats.RejectedConns = app.conns.rejected.Load()
+	}
+	return stats
+}
+
+// warn writes warning message to underlayer logging system.
+func (app *App) warn(msg string) {
+	if app.logger.Flags() == 0 {
+		app.logger.Printf("[%s] WARN %s\n", time.Now().UTC().Format("2006-01-02T15:04:05.999Z"), msg)
+	} else {
+		app.logger.Printf("WARN %s\n", msg)
+	}
+}
+
 // Error writes error to underlayer logging system.
 func (app *App) Error(err any) {
 	if err := ErrorWithStack(err, 2); err != nil {
@@ -519,6 +1252,9 @@ func (app *App) Error(err any) {
 
 func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := NewContext(app, w, r)
+	if app.crash != nil {
+		defer app.crash.record(ctx, time.Now())
+	}
 
 	if compressWriter := ctx.handleCompress(); compressWriter != nil {
 		defer compressWriter.Close()
@@ -528,8 +1264,11 @@ func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer catchRequest(ctx)
 	go handleCtxEnd(ctx)
 
-	// process app middleware
-	err := app.mds.run(ctx)
+	// process app middleware after readiness check
+	err := app.checkReadiness(ctx)
+	if IsNil(err) && !ctx.Res.wroteHeader.isTrue() {
+		err = app.mds.run(ctx)
+	}
 	if ctx.Res.wroteHeader.isTrue() {
 		if !IsNil(err) {
 			app.Error(err)
@@ -540,6 +1279,9 @@ func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// if context canceled abnormally...
 	if e := ctx.Err(); e != nil {
 		if e == context.Canceled {
+			if r.Context().Err() != nil {
+				app.aborted.Add(1) // canceled by client, not by ctx.Cancel
+			}
 			// https://stackoverflow.com/questions/46234679/what-is-the-correct-http-status-code-for-a-cancelled-request
 			// 499 Client Closed Request Used when the client has closed
 			// the request before the server could send a response.
@@ -549,6 +1291,13 @@ func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		err = ErrGatewayTimeout.WithMsg(e.Error())
 	}
 
+	// no middleware responded
+	if IsNil(err) && !IsStatusCode(ctx.Res.status) && ctx.Res.body == nil {
+		if e, ok := app.settings[SetUnhandledError].(HTTPError); ok {
+			err = e
+		}
+	}
+
 	// handle middleware errors
 	if !IsNil(err) {
 		ctx.Res.afterHooks = nil // clear afterHooks when any error
@@ -563,10 +1312,22 @@ func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Close closes the underlying server gracefully.
+// Close closes the underlying server (and the HTTP/3 server started by ListenHTTP3, the redirect server started
+// by RedirectHTTP) gracefully.
 // If context omit, Server.Close will be used to close immediately.
 // Otherwise Server.Shutdown will be used to close gracefully.
+// The readiness endpoints (see SetReadinessPath and NewHealth) will fail after Close called,
+// and the streaming requests are notified to end, see ctx.OnShutdown.
 func (app *App) Close(ctx ...context.Context) error {
+	if app.closing.CompareAndSwap(false, true) {
+		close(app.draining)
+	}
+	if h3 := app.h3.Load(); h3 != nil {
+		(*h3).Close()
+	}
+	if srv := app.redirect.Load(); srv != nil {
+		srv.Close()
+	}
 	if len(ctx) > 0 {
 		return app.Server.Shutdown(ctx[0])
 	}
@@ -595,24 +1356,39 @@ func (s *ServerListener) Wait() error {
 }
 
 func catchRequest(ctx *Context) {
-	if err := recover(); err != nil && err != http.ErrAbortHandler {
+	if err := recover(); err == http.ErrAbortHandler {
+		ctx.app.aborted.Add(1)
+	} else if err != nil {
 		ctx.Res.afterHooks = nil
 		ctx.Res.ResetHeader()
 		e := ErrorWithStack(err, 3)
+		if ctx.mdName != "" {
+			e.Stack = fmt.Sprintf("[middleware %q] %s", ctx.mdName, e.Stack)
+		}
 		ctx.app.onerror(ctx, e)
 		// try to ensure respond error if `app.onerror` does't do it.
 		ctx.respondError(e)
 	}
+	if ctx.cancelTime != nil {
+		ctx.cancelTime() // release the timers of the deadlines
+	}
 	// execute "end hooks" with LIFO order after Response.WriteHeader.
 	// they run in a goroutine, in order to not block current HTTP Request/Response.
 	if len(ctx.Res.endHooks) > 0 {
-		go tryRunHooks(ctx.app, ctx.Res.endHooks)
+		go tryRunHooks(ctx, ctx.Res.endHooks)
 	}
 }
 
 func handleCtxEnd(ctx *Context) {
-	<-ctx.done
-	ctx.Res.ended.setTrue()
+	done := ctx.done
+	for {
+		select {
+		case <-done:
+			ctx.Res.ended.setTrue()
+			return
+		case done = <-ctx.timeouts: // the deadline is replaced by ctx.SetTimeout
+		}
+	}
 }
 
 func runHooks(hooks []func()) {
@@ -622,13 +1398,48 @@ func runHooks(hooks []func()) {
 	}
 }
 
-func tryRunHooks(app *App, hooks []func()) {
-	defer catchErr(app)
+func tryRunHooks(ctx *Context, hooks []func()) {
+	defer catchHookErr(ctx)
 	runHooks(hooks)
 }
 
-func catchErr(app *App) {
+// catchHookErr recovers the panic in "end hooks", and reports it with the request it belongs to.
+func catchHookErr(ctx *Context) {
 	if err := recover(); err != nil && err != http.ErrAbortHandler {
-		app.Error(err)
+		e := ErrorWithStack(err, 3)
+		e.Msg = fmt.Sprintf("%s [end hook of %s %s, route: %q, request id: %q]",
+			e.Msg, ctx.Method, ctx.Path, GetRouterPatternFromCtx(ctx), ctx.requestID())
+		ctx.app.Error(e)
+		ctx.app.failedHooks.Add(1) // counted after reported, app.Stats observers can read the logs
+	}
+}
+
+// watchAfterHooks reports the "after hooks" that run longer than SetSlowAfterHooks setting.
+func (ctx *Context) watchAfterHooks(d time.Duration) {
+	if d > ctx.app.slowAfter {
+		ctx.app.warn(fmt.Sprintf("slow after hooks: %s > %s [%s %s, route: %q, request id: %q]",
+			d, ctx.app.slowAfter, ctx.Method, ctx.Path, GetRouterPatternFromCtx(ctx), ctx.requestID()))
+		ctx.app.slowHooks.Add(1)
+	}
+}
+
+// reportLargeResponse reports the response exceeding SetMaxResponseBytes setting by SetOnLargeResponse hook,
+// the captured response is replaced with the error response if the hook returns an error.
+func (ctx *Context) reportLargeResponse(size int64, captured bool) error {
+	app := ctx.app
+	app.largeRes.Add(1)
+	if app.onLargeRes == nil {
+		app.warn(fmt.Sprintf("large response: %d bytes > %d [%s %s, route: %q, request id: %q]",
+			size, app.maxResBytes, ctx.Method, ctx.Path, GetRouterPatternFromCtx(ctx), ctx.requestID()))
+		return nil
+	}
+	err := app.onLargeRes(ctx, size)
+	if err != nil && captured {
+		code, contentType, body := app.renderError(app.parseError(err))
+		ctx.Res.ResetHeader()
+		ctx.Res.Set(HeaderXContentTypeOptions, "nosniff")
+		ctx.Res.Set(HeaderContentType, contentType)
+		ctx.Res.status, ctx.Res.body = code, body
 	}
+	return err
 }
@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"io"
 	"log"
 	"math"
 	"net/http"
+	"os"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -106,6 +110,139 @@ func TestGearServer(t *testing.T) {
 	})
 }
 
+func TestGearAppClone(t *testing.T) {
+	assert := assert.New(t)
+
+	base := New()
+	base.Set(SetServerName, "Base")
+	base.Set(SetEnv, "test")
+	base.Server.ReadTimeout = time.Second
+	base.Use(func(ctx *Context) error {
+		return ctx.HTML(200, "base")
+	})
+
+	public := base.Clone()
+	public.Use(func(ctx *Context) error {
+		return ctx.HTML(200, "public")
+	})
+	admin := base.Clone()
+	admin.Set(SetServerName, "Admin")
+	admin.Use(func(ctx *Context) error {
+		return ctx.HTML(200, "admin")
+	})
+
+	assert.False(base.Server == public.Server)
+	assert.Equal(time.Second, public.Server.ReadTimeout)
+	assert.Equal(base.Server.IdleTimeout, admin.Server.IdleTimeout)
+	assert.Equal("test", public.Env())
+	assert.Equal("Base", public.settings[SetServerName])
+	assert.Equal("Admin", admin.settings[SetServerName])
+	assert.Equal("Base", base.settings[SetServerName])
+
+	for app, expected := range map[*App][]string{
+		base:   {"Base", "base"},
+		public: {"Base", "public"},
+		admin:  {"Admin", "admin"},
+	} {
+		srv := app.Start()
+		res, err := RequestBy("GET", "http://"+srv.Addr().String())
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(expected[0], res.Header.Get(HeaderServer))
+		assert.Equal(expected[1], PickRes(res.Text()).(string))
+		srv.Close()
+	}
+}
+
+func TestGearAppCloneFields(t *testing.T) {
+	assert := assert.New(t)
+
+	// the fields of App should be handled by Clone, update the lists when adding a field.
+	runtimeFields := []string{"Server", "appConfig", "mds", "names", "routers", "failedHooks", "aborted",
+		"slowHooks", "largeRes", "closing", "draining", "degraded", "h3", "redirect"}
+	sharedFields := []string{"keys", "logger", "diagnostics", "charsets"} // replaced by app.Set, never mutated
+	clonedFields := []string{"validators", "readiness", "conns", "crash", "deprecated", "settings"}
+	contains := func(names []string, name string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	appType := reflect.TypeOf(App{})
+	assert.Equal(len(runtimeFields), appType.NumField())
+	for _, name := range runtimeFields {
+		_, ok := appType.FieldByName(name)
+		assert.True(ok, name)
+	}
+
+	app := New()
+	app.Set(SetKeys, []string{"some key"})
+	app.Set(SetMaxConnections, 10)
+	app.Set(SetDiagnosticHeaders, DiagnosticHeaders{})
+	app.Set(SetCharsetEncoders, map[string]CharsetEncoder{"gbk": nil})
+	app.Set(SetCrashReportDir, t.TempDir())
+	app.Set(SetOnDeprecated, func(d Deprecation) {})
+	app.AddValidator(RequireKeys)
+	app.AddReadinessGate("db", func(ctx context.Context) error { return nil })
+	c := app.Clone()
+
+	configType := reflect.TypeOf(appConfig{})
+	v1 := reflect.ValueOf(app).Elem().FieldByName("appConfig")
+	v2 := reflect.ValueOf(c).Elem().FieldByName("appConfig")
+	for i := 0; i < configType.NumField(); i++ {
+		name := configType.Field(i).Name
+		switch configType.Field(i).Type.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Pointer:
+			assert.False(v1.Field(i).IsNil(), name)
+			if contains(sharedFields, name) {
+				assert.Equal(v1.Field(i).Pointer(), v2.Field(i).Pointer(), name)
+			} else {
+				assert.True(contains(clonedFields, name), "field %q should be deep copied by Clone", name)
+				assert.NotEqual(v1.Field(i).Pointer(), v2.Field(i).Pointer(), name)
+			}
+		}
+	}
+
+	c.AddValidator(func(app *App) error { return nil })
+	c.AddReadinessGate("cache", func(ctx context.Context) error { return nil })
+	assert.Equal(1, len(app.validators))
+	assert.Equal(2, len(c.validators))
+	assert.Equal([]string{"db"}, app.readiness.names)
+	assert.Equal([]string{"db", "cache"}, c.readiness.names)
+}
+
+func TestGearAppUnhandledError(t *testing.T) {
+	assert := assert.New(t)
+
+	app := New()
+	assert.Panics(func() { app.Set(SetUnhandledError, "not found") })
+	router := NewRouter(RouterOptions{Root: "/api"})
+	router.Get("/users", func(ctx *Context) error { return ctx.HTML(200, "OK") })
+	app.UseHandler(router)
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	res, err := RequestBy("GET", host+"/none")
+	assert.Nil(err)
+	assert.Equal(421, res.StatusCode)
+	res.Body.Close()
+
+	app.Set(SetUnhandledError, ErrNotFound.WithMsg("no route matched"))
+	res, err = RequestBy("GET", host+"/none")
+	assert.Nil(err)
+	assert.Equal(404, res.StatusCode)
+	assert.Equal(`{"error":"NotFound","message":"no route matched"}`, PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host+"/api/users")
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal("OK", PickRes(res.Text()).(string))
+}
+
 func TestGearAppHello(t *testing.T) {
 	t.Run("should work", func(t *testing.T) {
 		assert := assert.New(t)
@@ -186,6 +323,246 @@ func TestGearAppError(t *testing.T) {
 		assert.True(strings.Contains(buf.String(), `Msg:"some error"`))
 		assert.True(strings.Contains(buf.String(), `Stack:"\\t`))
 	})
+
+	t.Run("end hook panic", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf bytes.Buffer
+		app := New()
+		app.Set(SetLogger, log.New(&buf, "", 0))
+		router := NewRouter()
+		router.Get("/users/:id", func(ctx *Context) error {
+			ctx.OnEnd(func() {
+				panic("some hook error")
+			})
+			return ctx.HTML(200, "OK")
+		})
+		app.UseHandler(router)
+		srv := app.Start()
+		defer srv.Close()
+
+		assert.Equal(uint64(0), app.Stats().FailedHooks)
+		req, _ := NewRequst("GET", "http://"+srv.Addr().String()+"/users/123")
+		req.Header.Set(HeaderXRequestID, "abc")
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		res.Body.Close()
+
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(uint64(1), app.Stats().FailedHooks)
+		assert.True(strings.Contains(buf.String(), `Z] ERR {"`))
+		assert.True(strings.Contains(buf.String(),
+			`"message":"some hook error [end hook of GET /users/123, route: \"/users/:id\", request id: \"abc\"]"`))
+		assert.True(strings.Contains(buf.String(), `"stack":"\\t`))
+	})
+
+	t.Run("should count aborted requests", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf bytes.Buffer
+		app := New()
+		app.Set(SetLogger, log.New(&buf, "", 0))
+		handled := make(chan struct{}, 1)
+		app.Use(func(ctx *Context) error {
+			switch ctx.Path {
+			case "/abort":
+				panic(http.ErrAbortHandler)
+			case "/cancel":
+				ctx.Cancel()
+				return nil
+			}
+			<-ctx.Done()
+			handled <- struct{}{}
+			return nil
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("GET", host+"/abort")
+		if err == nil {
+			res.Body.Close()
+		}
+		res, err = RequestBy("GET", host+"/cancel")
+		assert.Nil(err)
+		res.Body.Close()
+		assert.Equal(uint64(1), app.Stats().AbortedRequests)
+
+		c, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(c, "GET", host+"/wait", nil)
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+		}()
+		_, err = DefaultClient.Do(req)
+		assert.NotNil(err)
+		<-handled
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(uint64(2), app.Stats().AbortedRequests)
+		assert.Equal("", buf.String())
+	})
+}
+
+func TestGearAppSlowAfterHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	app := New()
+	assert.Panics(func() { app.Set(SetSlowAfterHooks, 0) })
+	assert.Panics(func() { app.Set(SetSlowAfterHooks, time.Duration(0)) })
+	app.Set(SetLogger, log.New(&buf, "", 0))
+	app.Set(SetSlowAfterHooks, 20*time.Millisecond)
+
+	router := NewRouter()
+	router.Get("/users/:id", func(ctx *Context) error {
+		ctx.After(func() {
+			if ctx.Param("id") == "slow" {
+				time.Sleep(30 * time.Millisecond)
+			}
+		})
+		return ctx.HTML(200, "OK")
+	})
+	app.UseHandler(router)
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	res, err := RequestBy("GET", host+"/users/123")
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal(uint64(0), app.Stats().SlowAfterHooks)
+
+	req, _ := NewRequst("GET", host+"/users/slow")
+	req.Header.Set(HeaderXRequestID, "abc")
+	res, err = DefaultClientDo(req)
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal("OK", PickRes(res.Text()).(string))
+	assert.Equal(uint64(1), app.Stats().SlowAfterHooks)
+	assert.True(strings.Contains(buf.String(), `Z] WARN slow after hooks: `))
+	assert.True(strings.Contains(buf.String(), ` > 20ms [GET /users/slow, route: "/users/:id", request id: "abc"]`))
+}
+
+func TestGearAppMaxResponseBytes(t *testing.T) {
+	newApp := func() *App {
+		app := New()
+		router := NewRouter()
+		router.Get("/data/:size", func(ctx *Context) error {
+			size, _ := strconv.Atoi(ctx.Param("size"))
+			return ctx.HTML(200, strings.Repeat("a", size))
+		})
+		router.Get("/stream/:size", func(ctx *Context) error {
+			size, _ := strconv.Atoi(ctx.Param("size"))
+			return ctx.Stream(200, MIMETextPlainCharsetUTF8, strings.NewReader(strings.Repeat("a", size)))
+		})
+		router.Get("/file", func(ctx *Context) error {
+			return ctx.File("testdata/hello.html")
+		})
+		app.UseHandler(router)
+		return app
+	}
+
+	t.Run("should flag large responses", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf bytes.Buffer
+		app := newApp()
+		assert.Panics(func() { app.Set(SetMaxResponseBytes, 0) })
+		assert.Panics(func() { app.Set(SetMaxResponseBytes, int64(10)) })
+		assert.Panics(func() { app.Set(SetOnLargeResponse, func(ctx *Context, size int) error { return nil }) })
+		app.Set(SetLogger, log.New(&buf, "", 0))
+		app.Set(SetMaxResponseBytes, 10)
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("GET", host+"/data/10")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(uint64(0), app.Stats().LargeResponses)
+
+		res, err = RequestBy("GET", host+"/data/11")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(strings.Repeat("a", 11), PickRes(res.Text()).(string))
+		assert.Equal(uint64(1), app.Stats().LargeResponses)
+		assert.True(strings.Contains(buf.String(), `Z] WARN large response: 11 bytes > 10 [GET /data/11, route: "/data/:size"`))
+
+		res, err = RequestBy("GET", host+"/stream/4096")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(4096, len(PickRes(res.Text()).(string)))
+		assert.Equal(uint64(2), app.Stats().LargeResponses)
+	})
+
+	t.Run("should abort large responses by hook", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var sizes []int64
+		app := newApp()
+		app.Set(SetLogger, log.New(io.Discard, "", 0))
+		app.Set(SetMaxResponseBytes, 10)
+		app.Set(SetOnLargeResponse, func(ctx *Context, size int64) error {
+			sizes = append(sizes, size)
+			return ErrInternalServerError.WithMsgf("response too large: %d bytes", size)
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("GET", host+"/data/10")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+
+		res, err = RequestBy("GET", host+"/data/20")
+		assert.Nil(err)
+		assert.Equal(500, res.StatusCode)
+		assert.Equal(MIMEApplicationJSONCharsetUTF8, res.Header.Get(HeaderContentType))
+		assert.Equal(`{"error":"InternalServerError","message":"response too large: 20 bytes"}`, PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host+"/stream/20")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(strings.Repeat("a", 10), PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host+"/file")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		_, err = res.Text()
+		assert.NotNil(err, "truncated by Content-Length")
+
+		assert.Equal([]int64{20, 20, 11}, sizes)
+		assert.Equal(uint64(3), app.Stats().LargeResponses)
+	})
+}
+
+func TestGearAppPrintRoutes(t *testing.T) {
+	assert := assert.New(t)
+
+	getUser := Named("getUser", func(ctx *Context) error { return nil })
+	users := NewRouter(RouterOptions{Root: "/users"})
+	users.Use(noOp)
+	users.Get("/:id", getUser)
+
+	api := NewRouter(RouterOptions{Root: "/api"})
+	api.Use(noOp)
+	api.Post("/login", noOp, Named("login", noOp))
+	v1 := api.Group("/v1", noOp)
+	v1.Include(users)
+	v1.Use(noOp) // counted when printing
+
+	app := New()
+	app.UseHandler(api)
+	app.UseHandler(NewRouter().Handle("purge", "/", Named("purge", noOp)))
+
+	var buf bytes.Buffer
+	assert.Nil(app.PrintRoutes(&buf))
+	assert.Equal(`METHOD  PATTERN            HANDLER  MIDDLEWARES
+POST    /api/login         login    2
+GET     /api/v1/users/:id  getUser  4
+PURGE   /                  purge    0
+`, buf.String())
 }
 
 func TestGearAppOnError(t *testing.T) {
@@ -546,3 +923,159 @@ func TestGearSetServerName(t *testing.T) {
 		assert.Nil(app.Close())
 	})
 }
+
+func TestGearDefaultSender(t *testing.T) {
+	type senderData struct {
+		Name string `json:"name" xml:"name"`
+	}
+
+	app := New()
+	app.Set(SetSender, DefaultSender{YAMLMarshal: func(v any) ([]byte, error) {
+		return []byte("name: " + v.(senderData).Name + "\n"), nil
+	}})
+	app.Use(func(ctx *Context) error {
+		switch ctx.Path {
+		case "/bytes":
+			return ctx.Send(http.StatusOK, []byte("bytes"))
+		case "/string":
+			return ctx.Send(http.StatusOK, "text")
+		case "/error":
+			return ctx.Send(http.StatusOK, ErrNotFound.WithMsg("not found"))
+		default:
+			return ctx.Send(http.StatusOK, senderData{Name: "gear"})
+		}
+	})
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	send := func(path, accept string) *GearResponse {
+		req, _ := NewRequst("GET", host+path)
+		if accept != "" {
+			req.Header.Set(HeaderAccept, accept)
+		}
+		res, err := DefaultClientDo(req)
+		assert.Nil(t, err)
+		return res
+	}
+
+	t.Run("should send bytes, string and error", func(t *testing.T) {
+		assert := assert.New(t)
+
+		res := send("/bytes", "")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(MIMEOctetStream, res.Header.Get(HeaderContentType))
+		assert.Equal("bytes", PickRes(res.Text()).(string))
+
+		res = send("/string", "")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(MIMETextPlainCharsetUTF8, res.Header.Get(HeaderContentType))
+		assert.Equal("text", PickRes(res.Text()).(string))
+
+		res = send("/error", "")
+		assert.Equal(404, res.StatusCode)
+		assert.Equal(MIMEApplicationJSONCharsetUTF8, res.Header.Get(HeaderContentType))
+	})
+
+	t.Run("should negotiate struct data", func(t *testing.T) {
+		assert := assert.New(t)
+
+		res := send("/", "")
+		assert.Equal(MIMEApplicationJSONCharsetUTF8, res.Header.Get(HeaderContentType))
+		assert.Equal(`{"name":"gear"}`, PickRes(res.Text()).(string))
+
+		res = send("/", "text/html")
+		assert.Equal(MIMEApplicationJSONCharsetUTF8, res.Header.Get(HeaderContentType))
+
+		res = send("/", "application/xml, application/json;q=0.5")
+		assert.Equal(MIMEApplicationXMLCharsetUTF8, res.Header.Get(HeaderContentType))
+		assert.Equal(`<senderData><name>gear</name></senderData>`, PickRes(res.Text()).(string))
+
+		res = send("/", "text/yaml")
+		assert.Equal(MIMEApplicationYAMLCharsetUTF8, res.Header.Get(HeaderContentType))
+		assert.Equal("name: gear\n", PickRes(res.Text()).(string))
+	})
+
+	t.Run("should not negotiate YAML without marshal", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Set(SetSender, DefaultSender{})
+		ctx := CtxTest(app, "GET", "http://example.com", nil)
+		ctx.Req.Header.Set(HeaderAccept, MIMEApplicationYAML)
+		assert.Nil(ctx.Send(http.StatusOK, senderData{Name: "gear"}))
+		assert.Equal(MIMEApplicationJSONCharsetUTF8, ctx.Res.Get(HeaderContentType))
+	})
+}
+
+func TestGearSetDiagnosticHeaders(t *testing.T) {
+	t.Run("should emit headers", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		assert.Panics(func() {
+			app.Set(SetDiagnosticHeaders, true)
+		})
+		app.Set(SetDiagnosticHeaders, DiagnosticHeaders{RequestID: true, Runtime: true, ServedBy: true, RouteHash: true})
+		router := NewRouter()
+		router.Get("/users/:id", func(ctx *Context) error {
+			return ctx.HTML(200, ctx.Res.Get(HeaderXRequestID))
+		})
+		router.Get("/error", func(ctx *Context) error {
+			return ErrForbidden
+		})
+		app.UseHandler(router)
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("GET", host+"/users/123")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		rid := res.Header.Get(HeaderXRequestID)
+		assert.Equal(26, len(rid))
+		assert.Equal(rid, PickRes(res.Text()).(string))
+		_, err = time.ParseDuration(res.Header.Get(HeaderXRuntime) + "s")
+		assert.Nil(err)
+		hostname, _ := os.Hostname()
+		assert.Equal(hostname, res.Header.Get(HeaderXServedBy))
+		assert.Equal("ab48182e", res.Header.Get(HeaderXRouteHash))
+
+		req, _ := NewRequst("GET", host+"/error")
+		req.Header.Set(HeaderXRequestID, "abc")
+		res, err = DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(403, res.StatusCode)
+		assert.Equal("abc", res.Header.Get(HeaderXRequestID))
+		assert.NotEqual("", res.Header.Get(HeaderXRuntime))
+		assert.NotEqual("", res.Header.Get(HeaderXRouteHash))
+		res.Body.Close()
+
+		res, err = RequestBy("GET", host+"/none")
+		assert.Nil(err)
+		assert.Equal(421, res.StatusCode)
+		assert.NotEqual("", res.Header.Get(HeaderXRequestID))
+		assert.Equal("", res.Header.Get(HeaderXRouteHash))
+		res.Body.Close()
+	})
+
+	t.Run("should not emit headers in other env", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Set(SetEnv, "production")
+		app.Set(SetDiagnosticHeaders, DiagnosticHeaders{RequestID: true, Runtime: true, Envs: []string{"development"}})
+		app.Use(func(ctx *Context) error {
+			return ctx.End(204)
+		})
+		srv := app.Start()
+		defer srv.Close()
+
+		res, err := RequestBy("GET", "http://"+srv.Addr().String())
+		assert.Nil(err)
+		assert.Equal(204, res.StatusCode)
+		assert.Equal("", res.Header.Get(HeaderXRequestID))
+		assert.Equal("", res.Header.Get(HeaderXRuntime))
+		res.Body.Close()
+	})
+}
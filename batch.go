@@ -0,0 +1,300 @@
+package gear
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BatchOptions is options for Batch.
+type BatchOptions struct {
+	// MaxRequests is the max number of sub-requests in a batch request.
+	// Optional. Default to 20.
+	MaxRequests int
+	// MaxBodyBytes is the max bytes of the batch request body.
+	// Optional. Default to 10MB.
+	MaxBodyBytes int64
+	// Concurrency is the max number of sub-requests dispatched concurrently.
+	// Optional. Default to 1, the sub-requests are dispatched in order.
+	Concurrency int
+}
+
+// BatchRequest is a sub-request in the JSON batch request.
+type BatchRequest struct {
+	ID     string            `json:"id,omitempty"`
+	Method string            `json:"method"`
+	URL    string            `json:"url"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"` // JSON body, or a JSON string for other body
+}
+
+// BatchResponse is a sub-response in the JSON batch response.
+type BatchResponse struct {
+	ID     string            `json:"id,omitempty"`
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"` // JSON body, or a JSON string for other body
+}
+
+type batchItem struct {
+	id  string
+	req *http.Request
+	err error
+	res *responseRecorder
+}
+
+// Batch returns a middleware to serve a batch endpoint, that parses the sub-requests from the batch request,
+// dispatches them through the app in-process (with all middlewares, no HTTP round trip), and aggregates
+// the sub-responses, to reduce round trips for mobile clients. The sub-requests inherit the headers (such as
+// Authorization and Cookie) of the batch request, except the Content-* headers. The sub-requests are marked
+// by the request context, a sub-request to the batch endpoint is responded with 400 error.
+//
+// The JSON batch request (Content-Type: application/json) is responded with JSON:
+//
+//	POST /batch
+//	{"requests": [{"id": "1", "method": "GET", "url": "/users/1"}, {"id": "2", "method": "POST", "url": "/users", "body": {"name": "gear"}}]}
+//
+//	{"responses": [{"id": "1", "status": 200, "header": {...}, "body": {...}}, {"id": "2", "status": 201, ...}]}
+//
+// The multipart/mixed batch request, that every part is an "application/http" request, is responded with
+// multipart/mixed, the Content-ID of the request part is responded as "response-{Content-ID}".
+//
+//	router.Post("/batch", gear.Batch(gear.BatchOptions{Concurrency: 4}))
+func Batch(options ...BatchOptions) Middleware {
+	opts := BatchOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.MaxRequests <= 0 {
+		opts.MaxRequests = 20
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 10 << 20
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+
+	return func(ctx *Context) error {
+		if ctx.Value(isBatchRequest) != nil {
+			return ErrBadRequest.WithMsg("nested batch request")
+		}
+		mediaType, params, _ := mime.ParseMediaType(ctx.GetHeader(HeaderContentType))
+		if mediaType != MIMEApplicationJSON && mediaType != MIMEMultipartMixed {
+			return ErrUnsupportedMediaType.WithMsgf("unsupported media type: %s", mediaType)
+		}
+		if ctx.Req.Body == nil {
+			return ErrBadRequest.WithMsg("request entity empty")
+		}
+		body := http.MaxBytesReader(ctx.Res, ctx.Req.Body, opts.MaxBodyBytes)
+
+		var items []*batchItem
+		var err error
+		if mediaType == MIMEApplicationJSON {
+			items, err = parseJSONBatch(ctx, body, opts.MaxRequests)
+		} else {
+			items, err = parseMultipartBatch(ctx, body, params["boundary"], opts.MaxRequests)
+		}
+		if err != nil {
+			return err
+		}
+
+		dispatchBatch(ctx, items, opts.Concurrency)
+		if mediaType == MIMEApplicationJSON {
+			return respondJSONBatch(ctx, items)
+		}
+		return respondMultipartBatch(ctx, items)
+	}
+}
+
+func parseJSONBatch(ctx *Context, body io.Reader, max int) ([]*batchItem, error) {
+	batch := struct {
+		Requests []*BatchRequest `json:"requests"`
+	}{}
+	if err := json.NewDecoder(body).Decode(&batch); err != nil {
+		return nil, ErrBadRequest.WithMsgf("invalid batch request: %v", err)
+	}
+	if len(batch.Requests) == 0 {
+		return nil, ErrBadRequest.WithMsg("no batch requests")
+	}
+	if len(batch.Requests) > max {
+		return nil, ErrRequestEntityTooLarge.WithMsgf("too many batch requests, max %d", max)
+	}
+
+	items := make([]*batchItem, 0, len(batch.Requests))
+	for i, br := range batch.Requests {
+		item := &batchItem{id: br.ID}
+		if item.id == "" {
+			item.id = strconv.Itoa(i + 1)
+		}
+		items = append(items, item)
+
+		var rd io.Reader
+		contentType := ""
+		if len(br.Body) > 0 {
+			var s string
+			if json.Unmarshal(br.Body, &s) == nil {
+				rd = strings.NewReader(s)
+			} else {
+				rd = bytes.NewReader(br.Body)
+				contentType = MIMEApplicationJSON
+			}
+		}
+		req, err := http.NewRequestWithContext(ctx.Req.Context(), strings.ToUpper(br.Method), br.URL, rd)
+		if err != nil || br.Method == "" || !strings.HasPrefix(br.URL, "/") {
+			item.err = ErrBadRequest.WithMsgf("invalid batch request #%s", item.id)
+			continue
+		}
+		for key, val := range br.Header {
+			req.Header.Set(key, val)
+		}
+		if contentType != "" && req.Header.Get(HeaderContentType) == "" {
+			req.Header.Set(HeaderContentType, contentType)
+		}
+		item.req = req
+	}
+	return items, nil
+}
+
+func parseMultipartBatch(ctx *Context, body io.Reader, boundary string, max int) ([]*batchItem, error) {
+	if boundary == "" {
+		return nil, ErrBadRequest.WithMsg("invalid batch request: no multipart boundary")
+	}
+
+	var items []*batchItem
+	mr := multipart.NewReader(body, boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrBadRequest.WithMsgf("invalid batch request: %v", err)
+		}
+		if len(items) >= max {
+			return nil, ErrRequestEntityTooLarge.WithMsgf("too many batch requests, max %d", max)
+		}
+
+		item := &batchItem{id: strings.Trim(part.Header.Get("Content-ID"), "<>")}
+		if item.id == "" {
+			item.id = strconv.Itoa(len(items) + 1)
+		}
+		items = append(items, item)
+
+		req, err := http.ReadRequest(bufio.NewReader(part))
+		if err != nil {
+			item.err = ErrBadRequest.WithMsgf("invalid batch request #%s: %v", item.id, err)
+			continue
+		}
+		buf, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, ErrBadRequest.WithMsgf("invalid batch request: %v", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(buf))
+		item.req = req.WithContext(ctx.Req.Context())
+	}
+	if len(items) == 0 {
+		return nil, ErrBadRequest.WithMsg("no batch requests")
+	}
+	return items, nil
+}
+
+func dispatchBatch(ctx *Context, items []*batchItem, concurrency int) {
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for _, item := range items {
+		if item.err != nil {
+			continue
+		}
+		// inherit the headers of the batch request, except the Content-* headers
+		for key, vals := range ctx.Req.Header {
+			if _, ok := item.req.Header[key]; !ok && !strings.HasPrefix(key, "Content-") && key != HeaderAcceptEncoding {
+				item.req.Header[key] = vals
+			}
+		}
+		item.req = item.req.WithContext(context.WithValue(item.req.Context(), isBatchRequest, struct{}{}))
+		item.req.Host = ctx.Req.Host
+		item.req.RemoteAddr = ctx.Req.RemoteAddr
+		item.req.TLS = ctx.Req.TLS
+		if item.req.RequestURI == "" {
+			item.req.RequestURI = item.req.URL.RequestURI()
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(item *batchItem) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			item.res = ctx.app.invoke(item.req)
+		}(item)
+	}
+	wg.Wait()
+
+	for _, item := range items {
+		if item.err != nil {
+			code, contentType, body := ctx.app.renderError(ParseError(item.err))
+			item.res = newResponseRecorder()
+			item.res.header.Set(HeaderContentType, contentType)
+			item.res.status = code
+			item.res.body.Write(body)
+		}
+	}
+}
+
+func respondJSONBatch(ctx *Context, items []*batchItem) error {
+	responses := make([]*BatchResponse, 0, len(items))
+	for _, item := range items {
+		res := &BatchResponse{ID: item.id, Status: item.res.status, Header: make(map[string]string)}
+		for key := range item.res.header {
+			res.Header[key] = item.res.header.Get(key)
+		}
+		if buf := item.res.body.Bytes(); len(buf) > 0 {
+			mediaType, _, _ := mime.ParseMediaType(item.res.header.Get(HeaderContentType))
+			if (mediaType == MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json")) && json.Valid(buf) {
+				res.Body = buf
+			} else {
+				res.Body, _ = json.Marshal(string(buf))
+			}
+		}
+		responses = append(responses, res)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{"responses": responses})
+}
+
+func respondMultipartBatch(ctx *Context, items []*batchItem) error {
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	for _, item := range items {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			HeaderContentType: {MIMEApplicationHTTP},
+			"Content-ID":      {"<response-" + item.id + ">"},
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(pw, "HTTP/1.1 %d %s\r\n", item.res.status, http.StatusText(item.res.status))
+		item.res.header.Set(HeaderContentLength, strconv.Itoa(item.res.body.Len()))
+		if err := item.res.header.Write(pw); err != nil {
+			return err
+		}
+		io.WriteString(pw, "\r\n")
+		pw.Write(item.res.body.Bytes())
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	ctx.Type(MIMEMultipartMixed + "; boundary=" + mw.Boundary())
+	return ctx.End(http.StatusOK, buf.Bytes())
+}
@@ -0,0 +1,166 @@
+package gear
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newBatchApp(options ...BatchOptions) string {
+	app := New()
+	router := NewRouter()
+	router.Post("/batch", Batch(options...))
+	router.Get("/users/:id", func(ctx *Context) error {
+		return ctx.JSON(200, map[string]string{"id": ctx.Param("id"), "auth": ctx.GetHeader(HeaderAuthorization)})
+	})
+	router.Post("/users", func(ctx *Context) error {
+		body := map[string]any{}
+		if err := json.NewDecoder(ctx.Req.Body).Decode(&body); err != nil {
+			return ErrBadRequest.From(err)
+		}
+		body["id"] = "new"
+		body["type"] = ctx.GetHeader(HeaderContentType)
+		return ctx.JSON(201, body)
+	})
+	router.Put("/text", func(ctx *Context) error {
+		buf, _ := io.ReadAll(ctx.Req.Body)
+		return ctx.HTML(200, "text "+string(buf))
+	})
+	app.UseHandler(router)
+	return "http://" + app.Start().Addr().String()
+}
+
+func TestGearBatch(t *testing.T) {
+	t.Run("should dispatch JSON batch requests", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newBatchApp(BatchOptions{Concurrency: 2})
+		req, _ := http.NewRequest("POST", host+"/batch", strings.NewReader(`{"requests": [
+			{"id": "a", "method": "GET", "url": "/users/1"},
+			{"method": "post", "url": "/users", "body": {"name": "gear"}},
+			{"method": "PUT", "url": "/text", "body": "hello"},
+			{"method": "GET", "url": "/unknown"},
+			{"method": "GET", "url": "users"},
+			{"method": "POST", "url": "/batch"},
+			{"method": "POST", "url": "/BATCH?a=b", "body": {"requests": [{"method": "GET", "url": "/users/1"}]}}
+		]}`))
+		req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+		req.Header.Set(HeaderAuthorization, "Bearer abc")
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+
+		batch := struct {
+			Responses []*BatchResponse `json:"responses"`
+		}{}
+		assert.Nil(json.NewDecoder(res.Body).Decode(&batch))
+		res.Body.Close()
+		assert.Equal(7, len(batch.Responses))
+
+		r := batch.Responses[0]
+		assert.Equal("a", r.ID)
+		assert.Equal(200, r.Status)
+		assert.Equal(MIMEApplicationJSONCharsetUTF8, r.Header[HeaderContentType])
+		assert.Equal(`{"auth":"Bearer abc","id":"1"}`, string(r.Body))
+
+		r = batch.Responses[1]
+		assert.Equal("2", r.ID)
+		assert.Equal(201, r.Status)
+		assert.Equal(`{"id":"new","name":"gear","type":"application/json"}`, string(r.Body))
+
+		r = batch.Responses[2]
+		assert.Equal(200, r.Status)
+		assert.Equal(`"text hello"`, string(r.Body))
+
+		assert.Equal(421, batch.Responses[3].Status)
+		r = batch.Responses[4]
+		assert.Equal(400, r.Status)
+		assert.Equal(`{"error":"BadRequest","message":"invalid batch request #5"}`, string(r.Body))
+		r = batch.Responses[5]
+		assert.Equal(400, r.Status)
+		assert.Equal(`{"error":"BadRequest","message":"nested batch request"}`, string(r.Body))
+		r = batch.Responses[6]
+		assert.Equal(400, r.Status)
+		assert.Equal(`{"error":"BadRequest","message":"nested batch request"}`, string(r.Body))
+	})
+
+	t.Run("should dispatch multipart/mixed batch requests", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newBatchApp()
+		buf := &bytes.Buffer{}
+		mw := multipart.NewWriter(buf)
+		pw, _ := mw.CreatePart(map[string][]string{HeaderContentType: {MIMEApplicationHTTP}, "Content-ID": {"<item1>"}})
+		io.WriteString(pw, "GET /users/2 HTTP/1.1\r\nHost: example.com\r\n\r\n")
+		pw, _ = mw.CreatePart(map[string][]string{HeaderContentType: {MIMEApplicationHTTP}})
+		io.WriteString(pw, "POST /users HTTP/1.1\r\nContent-Type: application/json\r\nContent-Length: 15\r\n\r\n{\"name\":\"gear\"}")
+		pw, _ = mw.CreatePart(map[string][]string{HeaderContentType: {MIMEApplicationHTTP}})
+		io.WriteString(pw, "invalid")
+		mw.Close()
+
+		req, _ := http.NewRequest("POST", host+"/batch", buf)
+		req.Header.Set(HeaderContentType, strings.Replace(mw.FormDataContentType(), MIMEMultipartForm, MIMEMultipartMixed, 1))
+		req.Header.Set(HeaderAuthorization, "Bearer xyz")
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+
+		mediaType, params, err := mime.ParseMediaType(res.Header.Get(HeaderContentType))
+		assert.Nil(err)
+		assert.Equal(MIMEMultipartMixed, mediaType)
+		mr := multipart.NewReader(res.Body, params["boundary"])
+		expects := []struct {
+			id, body string
+			status   int
+		}{
+			{"<response-item1>", `{"auth":"Bearer xyz","id":"2"}`, 200},
+			{"<response-2>", `{"id":"new","name":"gear","type":"application/json"}`, 201},
+			{"<response-3>", `{"error":"BadRequest","message":"invalid batch request #3: malformed HTTP request \"invalid\""}`, 400},
+		}
+		for _, expect := range expects {
+			part, err := mr.NextPart()
+			assert.Nil(err)
+			assert.Equal(MIMEApplicationHTTP, part.Header.Get(HeaderContentType))
+			assert.Equal(expect.id, part.Header.Get("Content-ID"))
+			sub, err := http.ReadResponse(bufio.NewReader(part), nil)
+			assert.Nil(err)
+			assert.Equal(expect.status, sub.StatusCode)
+			body, _ := io.ReadAll(sub.Body)
+			assert.Equal(expect.body, string(body))
+		}
+		_, err = mr.NextPart()
+		assert.Equal(io.EOF, err)
+		res.Body.Close()
+	})
+
+	t.Run("should return error with invalid batch request", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newBatchApp(BatchOptions{MaxRequests: 1})
+		for _, c := range []struct {
+			contentType, body, res string
+			status                 int
+		}{
+			{MIMETextPlain, "", `{"error":"UnsupportedMediaType","message":"unsupported media type: text/plain"}`, 415},
+			{MIMEApplicationJSON, `[]`, `{"error":"BadRequest","message":"invalid batch request: json: cannot unmarshal array into Go value of type struct { Requests []*gear.BatchRequest \"json:\\\"requests\\\"\" }"}`, 400},
+			{MIMEApplicationJSON, `{"requests": []}`, `{"error":"BadRequest","message":"no batch requests"}`, 400},
+			{MIMEApplicationJSON, `{"requests": [{}, {}]}`, `{"error":"RequestEntityTooLarge","message":"too many batch requests, max 1"}`, 413},
+			{MIMEMultipartMixed, "", `{"error":"BadRequest","message":"invalid batch request: no multipart boundary"}`, 400},
+		} {
+			req, _ := http.NewRequest("POST", host+"/batch", strings.NewReader(c.body))
+			req.Header.Set(HeaderContentType, c.contentType)
+			res, err := DefaultClientDo(req)
+			assert.Nil(err)
+			assert.Equal(c.status, res.StatusCode)
+			assert.Equal(c.res, PickRes(res.Text()).(string))
+		}
+	})
+}
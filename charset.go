@@ -0,0 +1,68 @@
+package gear
+
+import (
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// CharsetEncoder encodes UTF-8 content to a charset.
+type CharsetEncoder func(buf []byte) ([]byte, error)
+
+// EncodeLatin1 is a CharsetEncoder that encodes UTF-8 content to ISO-8859-1.
+// It returns error if the content contains characters that can't be represented in ISO-8859-1.
+//
+//	app.Set(gear.SetCharsetEncoders, map[string]gear.CharsetEncoder{"iso-8859-1": gear.EncodeLatin1})
+func EncodeLatin1(buf []byte) ([]byte, error) {
+	res := make([]byte, 0, len(buf))
+	for i := 0; i < len(buf); {
+		r, size := utf8.DecodeRune(buf[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return nil, fmt.Errorf("invalid UTF-8 content at offset %d", i)
+		}
+		if r > 0xff {
+			return nil, fmt.Errorf("character %q can't be encoded to ISO-8859-1", r)
+		}
+		res = append(res, byte(r))
+		i += size
+	}
+	return res, nil
+}
+
+// encodeCharset transcodes the text/* body to the charset negotiated by Accept-Charset header
+// with SetCharsetEncoders. The body will be kept in UTF-8 if any error.
+func (ctx *Context) encodeCharset(body []byte) []byte {
+	if len(ctx.app.charsets) == 0 || len(body) == 0 {
+		return body
+	}
+	mediaType, params, err := mime.ParseMediaType(ctx.Res.Type())
+	if err != nil || !strings.HasPrefix(mediaType, "text/") {
+		return body
+	}
+	if cs := params["charset"]; cs != "" && !strings.EqualFold(cs, "utf-8") {
+		return body // not UTF-8 content
+	}
+
+	ctx.Res.Vary(HeaderAcceptCharset)
+	offers := make([]string, 0, len(ctx.app.charsets)+1)
+	offers = append(offers, "utf-8")
+	for cs := range ctx.app.charsets {
+		offers = append(offers, cs)
+	}
+	sort.Strings(offers[1:])
+
+	cs := strings.ToLower(ctx.AcceptCharset(offers...))
+	encoder := ctx.app.charsets[cs]
+	if encoder == nil {
+		return body
+	}
+	buf, err := encoder(body)
+	if err != nil {
+		return body
+	}
+	params["charset"] = cs
+	ctx.Type(mime.FormatMediaType(mediaType, params))
+	return buf
+}
@@ -0,0 +1,81 @@
+package gear
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeLatin1(t *testing.T) {
+	assert := assert.New(t)
+
+	buf, err := EncodeLatin1([]byte("Café ÿ"))
+	assert.Nil(err)
+	assert.Equal([]byte{'C', 'a', 'f', 0xe9, ' ', 0xff}, buf)
+
+	_, err = EncodeLatin1([]byte("你好"))
+	assert.NotNil(err)
+	_, err = EncodeLatin1([]byte{0xff})
+	assert.NotNil(err)
+}
+
+func TestGearSetCharsetEncoders(t *testing.T) {
+	app := New()
+	assert.Panics(t, func() {
+		app.Set(SetCharsetEncoders, map[string]func([]byte) ([]byte, error){})
+	})
+	app.Set(SetCharsetEncoders, map[string]CharsetEncoder{"ISO-8859-1": EncodeLatin1})
+	app.Use(func(ctx *Context) error {
+		switch ctx.Path {
+		case "/json":
+			return ctx.JSON(http.StatusOK, "Café")
+		case "/chinese":
+			return ctx.HTML(http.StatusOK, "你好")
+		default:
+			return ctx.HTML(http.StatusOK, "Café")
+		}
+	})
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	request := func(path, charset string) *GearResponse {
+		req, _ := NewRequst("GET", host+path)
+		if charset != "" {
+			req.Header.Set(HeaderAcceptCharset, charset)
+		}
+		res, err := DefaultClientDo(req)
+		assert.Nil(t, err)
+		return res
+	}
+
+	t.Run("should transcode text content", func(t *testing.T) {
+		assert := assert.New(t)
+
+		res := request("/", "iso-8859-1, utf-8;q=0.5")
+		assert.Equal("text/html; charset=iso-8859-1", res.Header.Get(HeaderContentType))
+		assert.Equal(HeaderAcceptCharset, res.Header.Get(HeaderVary))
+		assert.Equal("Caf\xe9", PickRes(res.Text()).(string))
+	})
+
+	t.Run("should respond UTF-8 content", func(t *testing.T) {
+		assert := assert.New(t)
+
+		res := request("/", "")
+		assert.Equal(MIMETextHTMLCharsetUTF8, res.Header.Get(HeaderContentType))
+		assert.Equal("Café", PickRes(res.Text()).(string))
+
+		res = request("/", "gbk")
+		assert.Equal(MIMETextHTMLCharsetUTF8, res.Header.Get(HeaderContentType))
+		assert.Equal("Café", PickRes(res.Text()).(string))
+
+		res = request("/chinese", "iso-8859-1")
+		assert.Equal(MIMETextHTMLCharsetUTF8, res.Header.Get(HeaderContentType))
+		assert.Equal("你好", PickRes(res.Text()).(string))
+
+		res = request("/json", "iso-8859-1")
+		assert.Equal(MIMEApplicationJSONCharsetUTF8, res.Header.Get(HeaderContentType))
+		assert.Equal(`"Café"`, PickRes(res.Text()).(string))
+	})
+}
@@ -0,0 +1,121 @@
+// Command gear scaffolds and inspects the projects using Gear.
+//
+// Install:
+//
+//	go install github.com/teambition/gear/cmd/gear@latest
+//
+// Usage:
+//
+//	gear new [-module example.com/myapp] myapp  # scaffold a new project in ./myapp
+//	gear gen handler user                       # generate handler/user.go with the CRUD handler stubs
+//	gear gen router user                        # generate router/user.go with the routes of the handlers
+//	gear routes                                 # print the routes of the project, by running it with -routes flag
+//	gear dev                                    # run the project, rebuild and restart it when the files changed
+//	gear version                                # print the version of Gear
+//
+// The "gen", "routes" and "dev" commands work in the project directory, or the directory of the -dir flag.
+// The project should serve with app.ListenGraceful to run by "gear dev", see the package github.com/teambition/gear/dev.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/teambition/gear"
+	"github.com/teambition/gear/dev"
+)
+
+const usage = `gear is the tool for the projects using Gear.
+
+Usage:
+
+	gear new [-module path] <dir>         scaffold a new project
+	gear gen handler [-dir dir] <name>    generate the CRUD handler stubs of a resource
+	gear gen router [-dir dir] <name>     generate the routes of a resource
+	gear routes [-dir dir]                print the routes of the project
+	gear dev [-dir dir] [-addr addr] [-reload addr] [-- args]
+	                                      run the project, rebuild and restart it when the files changed
+	gear version                          print the version of Gear
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "gear:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(out, usage)
+		return nil
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "new":
+		fs := flag.NewFlagSet("new", flag.ContinueOnError)
+		module := fs.String("module", "", "the module path of the project, default to the directory name")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: gear new [-module path] <dir>")
+		}
+		return newProject(fs.Arg(0), *module, out)
+
+	case "gen":
+		if len(args) == 0 || (args[0] != "handler" && args[0] != "router") {
+			return fmt.Errorf("usage: gear gen handler|router [-dir dir] <name>")
+		}
+		kind := args[0]
+		fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+		dir := fs.String("dir", ".", "the project directory")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: gear gen %s [-dir dir] <name>", kind)
+		}
+		if kind == "handler" {
+			return genHandler(*dir, fs.Arg(0), out)
+		}
+		return genRouter(*dir, fs.Arg(0), out)
+
+	case "routes":
+		fs := flag.NewFlagSet("routes", flag.ContinueOnError)
+		dir := fs.String("dir", ".", "the project directory")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		// the scaffolded main.go prints the routes and exits with -routes flag
+		c := exec.Command("go", "run", ".", "-routes")
+		c.Dir = *dir
+		c.Stdout = out
+		c.Stderr = os.Stderr
+		return c.Run()
+
+	case "dev":
+		fs := flag.NewFlagSet("dev", flag.ContinueOnError)
+		dir := fs.String("dir", ".", "the project directory")
+		addr := fs.String("addr", ":3000", "the address of the project to listen on")
+		reload := fs.String("reload", ":35729", "the address of the browser reload events server")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		return dev.Run(gear.ContextWithSignal(context.Background()), dev.Options{
+			Addr: *addr, ReloadAddr: *reload, Dir: *dir, Args: fs.Args(), Stdout: out})
+
+	case "version":
+		fmt.Fprintln(out, "Gear", gear.Version)
+		return nil
+
+	case "help", "-h", "--help":
+		fmt.Fprint(out, usage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q, run \"gear help\" for usage", cmd)
+}
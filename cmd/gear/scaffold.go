@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/teambition/gear"
+)
+
+// project is the data of the templates.
+type project struct {
+	Module  string
+	Name    string
+	Version string
+	// the resource of "gen" command, such as "user"
+	Resource string // User
+	Plural   string // Users
+	Path     string // /users
+}
+
+// newProject scaffolds a project in dir:
+//
+//	go.mod
+//	main.go                   the app with logging, and -addr and -routes flags
+//	router/router.go          the API router
+//	handler/hello.go          the handler stubs
+//	middleware/middleware.go  the middlewares of the project
+func newProject(dir, module string, out io.Writer) error {
+	name := filepath.Base(filepath.Clean(dir))
+	if module == "" {
+		module = name
+	}
+	p := &project{Module: module, Name: name, Version: gear.Version}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("directory %q is not empty", dir)
+	}
+	files := []struct{ name, tpl string }{
+		{"go.mod", goModTpl},
+		{"main.go", mainTpl},
+		{"router/router.go", routerTpl},
+		{"handler/hello.go", helloTpl},
+		{"middleware/middleware.go", middlewareTpl},
+	}
+	for _, f := range files {
+		if err := writeTemplate(filepath.Join(dir, f.name), f.tpl, p, out); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(out, "\nDone. Run the project:\n\n\tcd %s\n\tgo mod tidy\n\tgo run .\n", dir)
+	return nil
+}
+
+// genHandler generates handler/{name}.go with the CRUD handler stubs of the resource.
+func genHandler(dir, name string, out io.Writer) error {
+	p, err := resource(dir, name)
+	if err != nil {
+		return err
+	}
+	return writeTemplate(filepath.Join(dir, "handler", strings.ToLower(name)+".go"), handlerTpl, p, out)
+}
+
+// genRouter generates router/{name}.go with the routes of the resource handlers.
+func genRouter(dir, name string, out io.Writer) error {
+	p, err := resource(dir, name)
+	if err != nil {
+		return err
+	}
+	if err = writeTemplate(filepath.Join(dir, "router", strings.ToLower(name)+".go"), resourceRouterTpl, p, out); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nInclude the routes in router.New:\n\n\tapi.Include(%sRoutes())\n", unexport(p.Resource))
+	return nil
+}
+
+func resource(dir, name string) (*project, error) {
+	if name == "" || !isIdent(name) {
+		return nil, fmt.Errorf("invalid resource name %q", name)
+	}
+	module, err := readModule(dir)
+	if err != nil {
+		return nil, err
+	}
+	res := strings.ToUpper(name[:1]) + name[1:]
+	plural := res + "s"
+	if strings.HasSuffix(res, "s") {
+		plural = res + "es"
+	} else if strings.HasSuffix(res, "y") && len(res) > 1 && !strings.ContainsRune("aeiou", rune(res[len(res)-2])) {
+		plural = res[:len(res)-1] + "ies"
+	}
+	return &project{Module: module, Version: gear.Version, Resource: res, Plural: plural,
+		Path: "/" + strings.ToLower(plural)}, nil
+}
+
+// readModule reads the module path from the go.mod in dir.
+func readModule(dir string) (string, error) {
+	f, err := os.Open(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return "", fmt.Errorf("not a Go module: %w", err)
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if line := strings.TrimSpace(s.Text()); strings.HasPrefix(line, "module ") {
+			return strings.Trim(strings.TrimSpace(line[len("module "):]), `"`), nil
+		}
+	}
+	return "", fmt.Errorf("no module path in %s", filepath.Join(dir, "go.mod"))
+}
+
+// writeTemplate executes the template with p and writes it to file, the Go files are formatted.
+// The existing file will not be overwritten.
+func writeTemplate(file, tpl string, p *project, out io.Writer) error {
+	if _, err := os.Stat(file); err == nil {
+		return fmt.Errorf("file %q exists", file)
+	}
+	var buf bytes.Buffer
+	t := template.Must(template.New(filepath.Base(file)).Funcs(template.FuncMap{"unexport": unexport}).Parse(tpl))
+	if err := t.Execute(&buf, p); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if strings.HasSuffix(file, ".go") {
+		var err error
+		if data, err = format.Source(data); err != nil {
+			return fmt.Errorf("format %s: %w", file, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "create", file)
+	return nil
+}
+
+func unexport(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+func isIdent(s string) bool {
+	for i, r := range s {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return true
+}
+
+const goModTpl = `module {{.Module}}
+
+go 1.20
+
+require github.com/teambition/gear v{{.Version}}
+`
+
+const mainTpl = `package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/teambition/gear"
+	"github.com/teambition/gear/logging"
+
+	"{{.Module}}/middleware"
+	"{{.Module}}/router"
+)
+
+var (
+	addr   = flag.String("addr", ":3000", "the address to listen on")
+	routes = flag.Bool("routes", false, "print the routes and exit")
+)
+
+func main() {
+	flag.Parse()
+
+	app := gear.New()
+	app.Set(gear.SetServerName, "{{.Name}}")
+	app.Set(gear.SetValidateTags, true)
+	app.UseHandler(logging.Default(app.Env() == "development"))
+	app.Use(middleware.NoCache)
+	app.UseHandler(router.New())
+
+	if *routes {
+		app.PrintRoutes(os.Stdout)
+		return
+	}
+	logging.Info(map[string]any{"msg": "{{.Name}} start", "addr": *addr})
+	// ListenGraceful restarts with zero downtime on SIGUSR2, and inherits the listener from "gear dev"
+	app.Error(app.ListenGraceful(gear.ContextWithSignal(context.Background()), *addr))
+}
+`
+
+const routerTpl = `package router
+
+import (
+	"github.com/teambition/gear"
+
+	"{{.Module}}/handler"
+)
+
+// New returns the API router.
+func New() *gear.Router {
+	api := gear.NewRouter(gear.RouterOptions{Root: "/api"})
+	api.Get("/hello", handler.Hello).Name("hello")
+	return api
+}
+`
+
+const helloTpl = `package handler
+
+import (
+	"github.com/teambition/gear"
+)
+
+// Hello responds a greeting, try: http://127.0.0.1:3000/api/hello?name=Gear
+func Hello(ctx *gear.Context) error {
+	name := ctx.Query("name")
+	if name == "" {
+		name = "Gear"
+	}
+	return ctx.JSON(200, map[string]string{"message": "Hello, " + name + "!"})
+}
+`
+
+const middlewareTpl = `package middleware
+
+import (
+	"github.com/teambition/gear"
+)
+
+// NoCache disables the caching of the API responses by the browsers and proxies.
+func NoCache(ctx *gear.Context) error {
+	ctx.SetHeader(gear.HeaderCacheControl, "no-store")
+	return nil
+}
+`
+
+const handlerTpl = `package handler
+
+import (
+	"github.com/teambition/gear"
+)
+
+// {{.Resource}}Body is the request body to create or update a {{unexport .Resource}}.
+type {{.Resource}}Body struct {
+	Name string ` + "`" + `json:"name" validate:"required,max=64"` + "`" + `
+}
+
+// Validate implements gear.BodyTemplate interface.
+func (b *{{.Resource}}Body) Validate() error {
+	return nil
+}
+
+// List{{.Plural}} handles GET {{.Path}}
+func List{{.Plural}}(ctx *gear.Context) error {
+	return ctx.JSON(200, []any{})
+}
+
+// Get{{.Resource}} handles GET {{.Path}}/:id
+func Get{{.Resource}}(ctx *gear.Context) error {
+	return gear.ErrNotImplemented.WithMsgf("get {{unexport .Resource}} %s", ctx.Param("id"))
+}
+
+// Create{{.Resource}} handles POST {{.Path}}
+func Create{{.Resource}}(ctx *gear.Context) error {
+	body := {{.Resource}}Body{}
+	if err := ctx.ParseBody(&body); err != nil {
+		return err
+	}
+	return gear.ErrNotImplemented.WithMsg("create {{unexport .Resource}}")
+}
+
+// Update{{.Resource}} handles PUT {{.Path}}/:id
+func Update{{.Resource}}(ctx *gear.Context) error {
+	body := {{.Resource}}Body{}
+	if err := ctx.ParseBody(&body); err != nil {
+		return err
+	}
+	return gear.ErrNotImplemented.WithMsgf("update {{unexport .Resource}} %s", ctx.Param("id"))
+}
+
+// Delete{{.Resource}} handles DELETE {{.Path}}/:id
+func Delete{{.Resource}}(ctx *gear.Context) error {
+	return gear.ErrNotImplemented.WithMsgf("delete {{unexport .Resource}} %s", ctx.Param("id"))
+}
+`
+
+const resourceRouterTpl = `package router
+
+import (
+	"github.com/teambition/gear"
+
+	"{{.Module}}/handler"
+)
+
+// {{unexport .Resource}}Routes returns the routes of {{unexport .Plural}}, include them by api.Include.
+func {{unexport .Resource}}Routes() *gear.Router {
+	r := gear.NewRouter()
+	r.Get("{{.Path}}", handler.List{{.Plural}})
+	r.Post("{{.Path}}", handler.Create{{.Resource}})
+	r.Get("{{.Path}}/:id", handler.Get{{.Resource}}).Name("{{unexport .Resource}}")
+	r.Put("{{.Path}}/:id", handler.Update{{.Resource}})
+	r.Delete("{{.Path}}/:id", handler.Delete{{.Resource}})
+	return r
+}
+`
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func TestGearCLI(t *testing.T) {
+	t.Run("should print usage and version", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var out strings.Builder
+		assert.Nil(run(nil, &out))
+		assert.Contains(out.String(), "gear new [-module path] <dir>")
+		assert.Contains(out.String(), "gear dev [-dir dir] [-addr addr]")
+
+		out.Reset()
+		assert.Nil(run([]string{"version"}, &out))
+		assert.Equal("Gear "+gear.Version+"\n", out.String())
+
+		assert.NotNil(run([]string{"unknown"}, &out))
+		assert.NotNil(run([]string{"new"}, &out))
+		assert.NotNil(run([]string{"gen", "model", "user"}, &out))
+		assert.NotNil(run([]string{"dev", "-unknown"}, &out))
+	})
+
+	t.Run("should scaffold project and generate stubs", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := filepath.Join(t.TempDir(), "myapp")
+		var out strings.Builder
+		assert.Nil(run([]string{"new", "-module", "example.com/myapp", dir}, &out))
+		for _, name := range []string{"go.mod", "main.go", "router/router.go", "handler/hello.go", "middleware/middleware.go"} {
+			assert.Contains(out.String(), "create "+filepath.Join(dir, name))
+			_, err := os.Stat(filepath.Join(dir, name))
+			assert.Nil(err, name)
+		}
+		read := func(name string) string {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			assert.Nil(err)
+			return string(data)
+		}
+		assert.Equal("module example.com/myapp\n\ngo 1.20\n\nrequire github.com/teambition/gear v"+gear.Version+"\n", read("go.mod"))
+		assert.Contains(read("main.go"), `"example.com/myapp/router"`)
+		assert.Contains(read("main.go"), `app.Set(gear.SetServerName, "myapp")`)
+		assert.Contains(read("main.go"), `app.Set(gear.SetValidateTags, true)`)
+		assert.Contains(read("main.go"), `app.ListenGraceful(`)
+		assert.NotNil(run([]string{"new", dir}, &out), "directory not empty")
+
+		out.Reset()
+		assert.Nil(run([]string{"gen", "handler", "-dir", dir, "category"}, &out))
+		handler := read("handler/category.go")
+		assert.Contains(handler, "func ListCategories(ctx *gear.Context) error {")
+		assert.Contains(handler, "// UpdateCategory handles PUT /categories/:id")
+		assert.Contains(handler, "type CategoryBody struct {\n\tName string `json:\"name\" validate:\"required,max=64\"`\n}")
+		assert.NotNil(run([]string{"gen", "handler", "-dir", dir, "category"}, &out), "file exists")
+
+		out.Reset()
+		assert.Nil(run([]string{"gen", "router", "-dir", dir, "category"}, &out))
+		assert.Contains(out.String(), "api.Include(categoryRoutes())")
+		router := read("router/category.go")
+		assert.Contains(router, `"example.com/myapp/handler"`)
+		assert.Contains(router, `r.Get("/categories/:id", handler.GetCategory).Name("category")`)
+
+		assert.NotNil(run([]string{"gen", "router", "-dir", dir, "bad-name"}, &out))
+		assert.NotNil(run([]string{"gen", "router", "-dir", t.TempDir(), "user"}, &out), "no go.mod")
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"compress/zlib"
 	"io"
 	"net/http"
+	"strings"
+	"sync"
 )
 
 // Compressible interface is use to enable compress response content.
@@ -50,43 +52,117 @@ func (tc ThresholdCompress) Compressible(contentType string, contentLength int)
 	return contentType != ""
 }
 
+// Compressor creates a writer compressing the response body to w, see RegisterCompressor.
+// It can return a nil writer to respond without compression, such as when the encoding
+// needs a shared dictionary that the request doesn't announce.
+type Compressor func(ctx *Context, w io.Writer) (io.WriteCloser, error)
+
+var compressors = struct {
+	sync.RWMutex
+	encodings []string // in registration order
+	m         map[string]Compressor
+}{m: make(map[string]Compressor)}
+
+// RegisterCompressor registers a Compressor for the Content-Encoding of responses, it is used with SetCompress setting.
+// It can add modern codecs such as br and zstd (with shared dictionaries for JSON APIs) without adding dependencies
+// to gear, or replace the builtin gzip and deflate compressors. Register a Decompressor by RegisterDecompressor
+// for the request bodies of the encoding too.
+//
+//	import "github.com/klauspost/compress/zstd"
+//
+//	gear.RegisterCompressor("zstd", func(ctx *gear.Context, w io.Writer) (io.WriteCloser, error) {
+//		return zstd.NewWriter(w)
+//	})
+//
+// The registered encodings are negotiated only if the client lists them explicitly in Accept-Encoding header,
+// and they are preferred to gzip and deflate in registration order with the same quality.
+func RegisterCompressor(encoding string, fn Compressor) {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding == "" || encoding == "identity" || encoding == "*" || fn == nil {
+		panic(Err.WithMsgf("invalid compressor for %q", encoding))
+	}
+	compressors.Lock()
+	if _, ok := compressors.m[encoding]; !ok {
+		compressors.encodings = append(compressors.encodings, encoding)
+	}
+	compressors.m[encoding] = fn
+	compressors.Unlock()
+}
+
+// compressEncodings returns the registered encodings listed in the Accept-Encoding header
+// and the builtin encodings in preferred order.
+func compressEncodings(acceptEncoding string) []string {
+	compressors.RLock()
+	defer compressors.RUnlock()
+	encodings := make([]string, 0, len(compressors.encodings)+2)
+	for _, encoding := range compressors.encodings {
+		for _, s := range strings.Split(acceptEncoding, ",") {
+			if s, _, _ = strings.Cut(s, ";"); strings.EqualFold(strings.TrimSpace(s), encoding) {
+				encodings = append(encodings, encoding)
+				break
+			}
+		}
+	}
+	for _, encoding := range []string{"gzip", "deflate"} {
+		if _, ok := compressors.m[encoding]; !ok {
+			encodings = append(encodings, encoding)
+		}
+	}
+	return encodings
+}
+
+func compressor(encoding string) Compressor {
+	compressors.RLock()
+	defer compressors.RUnlock()
+	return compressors.m[encoding]
+}
+
 // http.ResponseWriter wrapper
 type compressWriter struct {
 	compress Compressible
 	encoding string
 	writer   io.WriteCloser
+	ctx      *Context
 	res      *Response
 	rw       http.ResponseWriter // underlying http.ResponseWriter
 }
 
 // https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Accept-Encoding
-func newCompress(res *Response, c Compressible, encoding string) *compressWriter {
-	switch encoding {
-	case "gzip", "deflate":
-		return &compressWriter{
-			compress: c,
-			res:      res,
-			rw:       res.rw,
-			encoding: encoding,
-		}
-	default:
+func newCompress(ctx *Context, c Compressible, encoding string) *compressWriter {
+	if encoding == "" || encoding == "identity" {
 		return nil
 	}
+	return &compressWriter{
+		compress: c,
+		ctx:      ctx,
+		res:      ctx.Res,
+		rw:       ctx.Res.rw,
+		encoding: encoding,
+	}
 }
 
 func (cw *compressWriter) WriteHeader(code int) {
 	defer cw.rw.WriteHeader(code)
 
-	if !isEmptyStatus(code) &&
+	// the content may be encoded already, such as the precompressed static files
+	if !isEmptyStatus(code) && cw.res.Get(HeaderContentEncoding) == "" &&
 		cw.compress.Compressible(cw.res.Get(HeaderContentType), len(cw.res.body)) {
 		var w io.WriteCloser
 
 		// http://www.gzip.org/zlib/zlib_faq.html#faq38
-		switch cw.encoding {
-		case "gzip": // recommend
-			w = gzip.NewWriter(cw.rw)
-		case "deflate": // should be zlib
-			w = zlib.NewWriter(cw.rw)
+		if fn := compressor(cw.encoding); fn != nil {
+			var err error
+			if w, err = fn(cw.ctx, cw.rw); err != nil {
+				w = nil // respond without compression
+				cw.ctx.app.Error(err)
+			}
+		} else {
+			switch cw.encoding {
+			case "gzip": // recommend
+				w = gzip.NewWriter(cw.rw)
+			case "deflate": // should be zlib
+				w = zlib.NewWriter(cw.rw)
+			}
 		}
 
 		if w != nil {
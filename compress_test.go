@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"compress/gzip"
 	"compress/zlib"
+	"encoding/hex"
+	"errors"
 	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -219,3 +222,80 @@ func TestGearResponseCompress(t *testing.T) {
 		})
 	})
 }
+
+type hexWriteCloser struct {
+	io.Writer
+}
+
+func (hexWriteCloser) Close() error { return nil }
+
+func TestGearRegisterCompressor(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Panics(func() { RegisterCompressor("identity", nil) })
+	assert.Panics(func() { RegisterCompressor("x-hex", nil) })
+
+	// a fake "x-hex" encoding that needs a "dictionary" for testing
+	RegisterCompressor("X-Hex", func(ctx *Context, w io.Writer) (io.WriteCloser, error) {
+		if ctx.GetHeader("Available-Dictionary") == "" {
+			return nil, nil
+		}
+		return hexWriteCloser{hex.NewEncoder(w)}, nil
+	})
+	RegisterCompressor("x-bad", func(ctx *Context, w io.Writer) (io.WriteCloser, error) {
+		return nil, errors.New("some compressor error")
+	})
+	defer func() {
+		compressors.Lock()
+		compressors.encodings = nil
+		compressors.m = make(map[string]Compressor)
+		compressors.Unlock()
+	}()
+	assert.Equal([]string{"gzip", "deflate"}, compressEncodings("gzip, *"))
+	assert.Equal([]string{"x-hex", "x-bad", "gzip", "deflate"}, compressEncodings("x-bad;q=0.5, X-Hex"))
+
+	var buf bytes.Buffer
+	app := New()
+	app.Set(SetLogger, log.New(&buf, "", 0))
+	app.Set(SetCompress, &DefaultCompress{})
+	body := strings.Repeat("Hello, Gear!", 100)
+	app.Use(func(ctx *Context) error {
+		ctx.Type(MIMETextPlainCharsetUTF8)
+		return ctx.End(http.StatusOK, []byte(body))
+	})
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	get := func(acceptEncoding, dictionary string) (*http.Response, string) {
+		req, _ := NewRequst("GET", host)
+		req.Header.Set(HeaderAcceptEncoding, acceptEncoding)
+		if dictionary != "" {
+			req.Header.Set("Available-Dictionary", dictionary)
+		}
+		res, err := DefaultClient.Do(req)
+		assert.Nil(err)
+		data, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		return res, string(data)
+	}
+
+	res, data := get("gzip, x-hex", ":abc:")
+	assert.Equal("x-hex", res.Header.Get(HeaderContentEncoding))
+	assert.Equal(hex.EncodeToString([]byte(body)), data)
+
+	res, data = get("gzip, x-hex", "")
+	assert.Equal("", res.Header.Get(HeaderContentEncoding))
+	assert.Equal(body, data)
+
+	res, _ = get("gzip;q=1, x-hex;q=0.5", ":abc:")
+	assert.Equal("gzip", res.Header.Get(HeaderContentEncoding))
+
+	res, _ = get("*", ":abc:")
+	assert.Equal("gzip", res.Header.Get(HeaderContentEncoding))
+
+	res, data = get("x-bad", "")
+	assert.Equal("", res.Header.Get(HeaderContentEncoding))
+	assert.Equal(body, data)
+	assert.True(strings.Contains(buf.String(), "some compressor error"))
+}
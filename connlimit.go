@@ -0,0 +1,171 @@
+package gear
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// connLimiter limits the connections accepted by the app's listeners,
+// configured by SetMaxConnections, SetMaxConnsPerIP and SetConnRatePerIP settings.
+type connLimiter struct {
+	mu        sync.Mutex
+	maxConns  int
+	maxPerIP  int
+	ratePerIP int
+	active    int
+	ips       map[string]*ipConns
+	swept     time.Time
+	rejected  atomic.Uint64
+}
+
+type ipConns struct {
+	active int
+	tokens float64 // token bucket for SetConnRatePerIP
+	last   time.Time
+}
+
+func newConnLimiter() *connLimiter {
+	return &connLimiter{ips: make(map[string]*ipConns)}
+}
+
+func (cl *connLimiter) clone() *connLimiter {
+	c := newConnLimiter()
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	c.maxConns, c.maxPerIP, c.ratePerIP = cl.maxConns, cl.maxPerIP, cl.ratePerIP
+	return c
+}
+
+func (cl *connLimiter) set(key appSetting, val int) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	switch key {
+	case SetMaxConnections:
+		cl.maxConns = val
+	case SetMaxConnsPerIP:
+		cl.maxPerIP = val
+	case SetConnRatePerIP:
+		cl.ratePerIP = val
+	}
+}
+
+// allow returns true and occupies a connection slot if a new connection from the ip is allowed.
+func (cl *connLimiter) allow(ip string, now time.Time) bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.maxConns > 0 && cl.active >= cl.maxConns {
+		return false
+	}
+	if cl.maxPerIP > 0 || cl.ratePerIP > 0 {
+		if now.Sub(cl.swept) > time.Minute {
+			cl.sweep(now)
+		}
+		s := cl.ips[ip]
+		if s == nil {
+			s = &ipConns{tokens: float64(cl.ratePerIP), last: now}
+			cl.ips[ip] = s
+		}
+		if cl.maxPerIP > 0 && s.active >= cl.maxPerIP {
+			return false
+		}
+		if cl.ratePerIP > 0 {
+			s.refill(cl.ratePerIP, now)
+			if s.tokens < 1 {
+				return false
+			}
+			s.tokens--
+		}
+		s.active++
+	}
+	cl.active++
+	return true
+}
+
+// release releases the connection slot occupied by allow.
+func (cl *connLimiter) release(ip string) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.active--
+	if s := cl.ips[ip]; s != nil {
+		if s.active--; s.active <= 0 && cl.ratePerIP <= 0 {
+			delete(cl.ips, ip)
+		}
+	}
+}
+
+// sweep removes the idle ips with full token bucket.
+func (cl *connLimiter) sweep(now time.Time) {
+	cl.swept = now
+	for ip, s := range cl.ips {
+		if s.active > 0 {
+			continue
+		}
+		if s.refill(cl.ratePerIP, now); s.tokens >= float64(cl.ratePerIP) {
+			delete(cl.ips, ip)
+		}
+	}
+}
+
+func (s *ipConns) refill(rate int, now time.Time) {
+	s.tokens += now.Sub(s.last).Seconds() * float64(rate)
+	if s.tokens > float64(rate) {
+		s.tokens = float64(rate)
+	}
+	s.last = now
+}
+
+// limitListener closes the connections rejected by the connLimiter immediately after accepted,
+// before any request parsing.
+type limitListener struct {
+	net.Listener
+	cl *connLimiter
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		ip, _, err := net.SplitHostPort(c.RemoteAddr().String())
+		if err != nil {
+			ip = c.RemoteAddr().String()
+		}
+		if !l.cl.allow(ip, time.Now()) {
+			l.cl.rejected.Add(1)
+			c.Close()
+			continue
+		}
+		return &limitConn{Conn: c, release: func() { l.cl.release(ip) }}, nil
+	}
+}
+
+type limitConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+// setConnLimit sets the connection limit settings.
+func (app *App) setConnLimit(key appSetting, val int) {
+	if app.conns == nil {
+		app.conns = newConnLimiter()
+	}
+	app.conns.set(key, val)
+}
+
+// limitListener wraps the listener with the connection limits of the app if any.
+func (app *App) limitListener(l net.Listener) net.Listener {
+	if app.conns == nil {
+		return l
+	}
+	return &limitListener{Listener: l, cl: app.conns}
+}
@@ -0,0 +1,121 @@
+package gear
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearConnLimiter(t *testing.T) {
+	t.Run("should limit max connections", func(t *testing.T) {
+		assert := assert.New(t)
+
+		cl := newConnLimiter()
+		cl.set(SetMaxConnections, 2)
+		now := time.Now()
+		assert.True(cl.allow("1.1.1.1", now))
+		assert.True(cl.allow("2.2.2.2", now))
+		assert.False(cl.allow("3.3.3.3", now))
+		cl.release("1.1.1.1")
+		assert.True(cl.allow("3.3.3.3", now))
+		assert.Equal(0, len(cl.ips))
+	})
+
+	t.Run("should limit connections per ip", func(t *testing.T) {
+		assert := assert.New(t)
+
+		cl := newConnLimiter()
+		cl.set(SetMaxConnsPerIP, 1)
+		now := time.Now()
+		assert.True(cl.allow("1.1.1.1", now))
+		assert.False(cl.allow("1.1.1.1", now))
+		assert.True(cl.allow("2.2.2.2", now))
+		cl.release("1.1.1.1")
+		assert.Equal(1, len(cl.ips))
+		assert.True(cl.allow("1.1.1.1", now))
+	})
+
+	t.Run("should limit connection rate per ip", func(t *testing.T) {
+		assert := assert.New(t)
+
+		cl := newConnLimiter()
+		cl.set(SetConnRatePerIP, 2)
+		now := time.Now()
+		assert.True(cl.allow("1.1.1.1", now))
+		assert.True(cl.allow("1.1.1.1", now))
+		assert.False(cl.allow("1.1.1.1", now))
+		assert.True(cl.allow("2.2.2.2", now))
+		assert.False(cl.allow("1.1.1.1", now.Add(400*time.Millisecond)))
+		assert.True(cl.allow("1.1.1.1", now.Add(500*time.Millisecond)))
+
+		for _, ip := range []string{"1.1.1.1", "1.1.1.1", "1.1.1.1", "2.2.2.2"} {
+			cl.release(ip)
+		}
+		assert.Equal(2, len(cl.ips))
+		cl.sweep(now.Add(2 * time.Second))
+		assert.Equal(0, len(cl.ips))
+	})
+}
+
+func TestGearAppConnLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	app := New()
+	assert.Panics(func() {
+		app.Set(SetMaxConnections, "1")
+	})
+	assert.Panics(func() {
+		app.Set(SetMaxConnsPerIP, int64(1))
+	})
+	assert.Panics(func() {
+		app.Set(SetConnRatePerIP, 1.5)
+	})
+	app.Set(SetMaxConnsPerIP, 1)
+	app.Use(func(ctx *Context) error {
+		return ctx.HTML(200, "OK")
+	})
+	srv := app.Start()
+	defer srv.Close()
+	addr := srv.Addr().String()
+
+	c1, err := net.Dial("tcp", addr)
+	assert.Nil(err)
+	_, err = io.WriteString(c1, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Nil(err)
+	buf := make([]byte, 12)
+	_, err = io.ReadFull(c1, buf)
+	assert.Nil(err)
+	assert.Equal("HTTP/1.1 200", string(buf))
+
+	// rejected while c1 is alive
+	c2, err := net.Dial("tcp", addr)
+	assert.Nil(err)
+	c2.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = io.WriteString(c2, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	if err == nil {
+		_, err = c2.Read(buf)
+	}
+	assert.NotNil(err)
+	assert.False(isTimeout(err))
+	c2.Close()
+	assert.Equal(uint64(1), app.Stats().RejectedConns)
+
+	c1.Close()
+	time.Sleep(10 * time.Millisecond)
+	res, err := RequestBy("GET", "http://"+addr)
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	res.Body.Close()
+
+	clone := app.Clone()
+	assert.Equal(1, clone.conns.maxPerIP)
+	assert.Equal(uint64(0), clone.Stats().RejectedConns)
+}
+
+func isTimeout(err error) bool {
+	e, ok := err.(net.Error)
+	return ok && e.Timeout()
+}
@@ -12,6 +12,7 @@ const (
 	MIMEApplicationXML                   = "application/xml"
 	MIMEApplicationXMLCharsetUTF8        = "application/xml; charset=utf-8"
 	MIMEApplicationYAML                  = "application/yaml"
+	MIMEApplicationYAMLCharsetUTF8       = "application/yaml; charset=utf-8"
 	MIMEApplicationTOML                  = "application/toml" // https://github.com/toml-lang/toml
 	MIMEApplicationForm                  = "application/x-www-form-urlencoded"
 	MIMEApplicationProtobuf              = "application/protobuf" // https://tools.ietf.org/html/draft-rfernando-protocol-buffers-00
@@ -19,15 +20,25 @@ const (
 	MIMETextHTMLCharsetUTF8              = "text/html; charset=utf-8"
 	MIMETextPlain                        = "text/plain"
 	MIMETextPlainCharsetUTF8             = "text/plain; charset=utf-8"
+	MIMETextXML                          = "text/xml"
+	MIMETextYAML                         = "text/yaml"
+	MIMETextEventStream                  = "text/event-stream"
 	MIMEMarkdown                         = "text/markdown"
 	MIMEMarkdownCharsetUTF8              = "text/markdown; charset=utf-8"
 	MIMEMultipartForm                    = "multipart/form-data"
+	MIMEMultipartMixed                   = "multipart/mixed"
+	MIMEApplicationHTTP                  = "application/http" // https://tools.ietf.org/html/rfc7230#section-8.3.2
 	MIMEOctetStream                      = "application/octet-stream"
 	MIMEApplicationSchemaJSON            = "application/schema+json"
 	MIMEApplicationSchemaInstanceJSON    = "application/schema-instance+json"
 	MIMEApplicationSchemaJSONLD          = "application/ld+json"
 	MIMEApplicationSchemaGraphQL         = "application/graphql"
 	MIMEApplicationCBOR                  = "application/cbor"
+	MIMEApplicationMsgPack               = "application/msgpack"          // https://msgpack.org
+	MIMEApplicationMergePatchJSON        = "application/merge-patch+json" // https://tools.ietf.org/html/rfc7386
+	MIMEApplicationJSONPatchJSON         = "application/json-patch+json"  // https://tools.ietf.org/html/rfc6902
+	MIMEApplicationJSONAPI               = "application/vnd.api+json"     // https://jsonapi.org/format/
+	MIMEApplicationHALJSON               = "application/hal+json"         // https://tools.ietf.org/html/draft-kelly-json-hal
 )
 
 // HTTP Header Fields
@@ -40,6 +51,7 @@ const (
 	HeaderCacheControl       = "Cache-Control"       // Requests, Responses
 	HeaderContentLength      = "Content-Length"      // Requests, Responses
 	HeaderContentMD5         = "Content-MD5"         // Requests, Responses
+	HeaderConnection         = "Connection"          // Requests, Responses
 	HeaderContentType        = "Content-Type"        // Requests, Responses
 	HeaderIfMatch            = "If-Match"            // Requests
 	HeaderIfModifiedSince    = "If-Modified-Since"   // Requests
@@ -80,6 +92,7 @@ const (
 	HeaderAcceptPatch                   = "Accept-Patch"                     // Responses
 	HeaderAcceptRanges                  = "Accept-Ranges"                    // Responses
 	HeaderAllow                         = "Allow"                            // Responses
+	HeaderAltSvc                        = "Alt-Svc"                          // Responses
 	HeaderContentEncoding               = "Content-Encoding"                 // Responses
 	HeaderContentLanguage               = "Content-Language"                 // Responses
 	HeaderContentLocation               = "Content-Location"                 // Responses
@@ -119,6 +132,30 @@ const (
 	HeaderXHTTPMethodOverride             = "X-HTTP-Method-Override"              // Responses
 	HeaderXDNSPrefetchControl             = "X-DNS-Prefetch-Control"              // Responses
 	HeaderXDownloadOptions                = "X-Download-Options"                  // Responses
+	HeaderXRuntime                        = "X-Runtime"                           // Responses
+	HeaderXServedBy                       = "X-Served-By"                         // Responses
+	HeaderXRouteHash                      = "X-Route-Hash"                        // Responses
+	HeaderXAccelBuffering                 = "X-Accel-Buffering"                   // Responses
+	HeaderXMiddlewareTrace                = "X-Middleware-Trace"                  // Responses
+)
+
+// Nonstandard HTTP methods, they can be routed by Router.Handle, such as `router.Handle(gear.MethodPurge, "/cache/*path", purge)`.
+// Standard methods are defined in net/http package, such as http.MethodGet.
+const (
+	// WebDAV methods, https://www.rfc-editor.org/rfc/rfc4918
+	MethodCopy      = "COPY"
+	MethodLock      = "LOCK"
+	MethodMkcol     = "MKCOL"
+	MethodMove      = "MOVE"
+	MethodPropfind  = "PROPFIND"
+	MethodProppatch = "PROPPATCH"
+	MethodUnlock    = "UNLOCK"
+	// https://www.rfc-editor.org/rfc/rfc3253
+	MethodReport = "REPORT"
+	// https://www.rfc-editor.org/rfc/rfc5323
+	MethodSearch = "SEARCH"
+	// Cache purge method supported by Varnish, Squid, Fastly, etc.
+	MethodPurge = "PURGE"
 )
 
 // Predefined errors
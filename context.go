@@ -7,9 +7,11 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"math/rand"
 	"mime"
 	"net"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"reflect"
 	"strings"
@@ -25,6 +27,7 @@ type contextKey int
 const (
 	isInheritedContext contextKey = iota
 	isGearContext
+	isBatchRequest // marks the sub-requests dispatched by Batch
 )
 
 // Any interface is used by ctx.Any.
@@ -72,6 +75,8 @@ type State struct {
 	KV            map[any]any
 	RouterPrefix  string
 	RouterMatched *trie.Matched
+	routerPattern string // the pattern of the matched route with typed constraints
+	routeMeta     map[string]any
 }
 
 // Valid implements gear.IsValid interface.
@@ -92,10 +97,21 @@ type Context struct {
 	Path    string
 	StartAt time.Time
 
-	query     url.Values
-	ctx       context.Context
-	cancelCtx context.CancelFunc
-	done      <-chan struct{}
+	query       url.Values
+	ctx         context.Context
+	cancelCtx   context.CancelFunc
+	timeoutBase context.Context        // the parent context of the deadline set by SetTimeout
+	timeoutCtx  context.Context        // the context with the deadline set by SetTimeout
+	cancelTime  context.CancelFunc     // releases the timers of the deadlines when the request ends
+	timeouts    chan (<-chan struct{}) // notifies handleCtxEnd the Done channel of the new deadline
+	done        <-chan struct{}
+	transformed bool
+	rand        *rand.Rand
+	randSeed    int64
+	seeded      bool
+	mdName      string   // the name of the running named middleware
+	mdTrace     []string // the names of named middlewares that ran, for diagnostics
+	forwards    int      // the number of ctx.Forward calls
 }
 
 // NewContext creates an instance of Context. Export for testing middleware.
@@ -116,20 +132,31 @@ func NewContext(app *App, w http.ResponseWriter, r *http.Request) *Context {
 		ctx.SetHeader(HeaderServer, app.serverName)
 	}
 
-	if app.timeout <= 0 {
-		ctx.ctx, ctx.cancelCtx = context.WithCancel(r.Context())
-	} else {
-		ctx.ctx, ctx.cancelCtx = context.WithTimeout(r.Context(), app.timeout)
-	}
-
+	ctx.ctx, ctx.cancelCtx = context.WithCancel(r.Context())
 	ctx.ctx = context.WithValue(ctx.ctx, isInheritedContext, struct{}{})
 	ctx.ctx = CtxWith(ctx.ctx, &State{KV: make(map[any]any)})
+	ctx.timeoutBase = ctx.ctx
+	if app.timeout > 0 {
+		ctx.ctx, ctx.cancelTime = context.WithTimeout(ctx.ctx, app.timeout)
+	}
+	ctx.timeoutCtx = ctx.ctx
 
 	ctx.Req = r.WithContext(ctx.ctx)
 	if app.withContext != nil {
 		ctx.WithContext(app.withContext(ctx.Req))
 	}
+	ctx.handleDiagnostics()
+	if app.slowAfter > 0 {
+		ctx.Res.afterWatch = ctx.watchAfterHooks
+	}
+	if app.etag != 0 {
+		ctx.Res.conditionalHook = ctx.conditional
+	}
+	if app.maxResBytes > 0 {
+		ctx.Res.limit = &responseLimit{max: app.maxResBytes, report: ctx.reportLargeResponse}
+	}
 
+	ctx.timeouts = make(chan (<-chan struct{}), 1)
 	ctx.done = ctx.ctx.Done()
 	return &ctx
 }
@@ -172,6 +199,40 @@ func (ctx *Context) Cancel() {
 	ctx.cancelCtx()
 }
 
+// SetTimeout replaces the deadline of the ctx created by the SetTimeout setting with a new timeout from now,
+// a zero or negative timeout removes the deadline. It does nothing after the ctx is done or the header wrote.
+// The router uses it for the route timeout, see RouterOptions.Timeout and gear.Timeout.
+// The contexts derived from the ctx before keep the previous deadline. If ctx.WithContext was called after
+// the previous deadline set, such as by the deadline middleware, the new deadline can't be later than it.
+func (ctx *Context) SetTimeout(timeout time.Duration) {
+	if ctx.ctx.Err() != nil || ctx.Res.wroteHeader.isTrue() {
+		return
+	}
+	if ctx.ctx != ctx.timeoutCtx {
+		ctx.timeoutBase = ctx.ctx
+	}
+
+	c := ctx.timeoutBase
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		c, cancel = context.WithTimeout(c, timeout)
+		if prev := ctx.cancelTime; prev != nil {
+			// the previous deadline is kept for the contexts derived from it
+			ctx.cancelTime = func() { cancel(); prev() }
+		} else {
+			ctx.cancelTime = cancel
+		}
+	}
+	ctx.timeoutCtx = c
+	ctx.ctx = c
+	ctx.Req = ctx.Req.WithContext(c)
+	select {
+	case <-ctx.timeouts: // drop the stale one
+	default:
+	}
+	ctx.timeouts <- c.Done()
+}
+
 // WithCancel returns a copy of the ctx with a new Done channel.
 // The returned context's Done channel is closed when the returned cancel function is called or when the parent context's Done channel is closed, whichever happens first.
 func (ctx *Context) WithCancel() (context.Context, context.CancelFunc) {
@@ -308,6 +369,7 @@ func (ctx *Context) Setting(key any) any {
 func (ctx *Context) IP(trustedProxy ...bool) net.IP {
 	trusted := ctx.Setting(SetTrustedProxy).(bool)
 	if len(trustedProxy) > 0 {
+		ctx.deprecated("ctx.IP(trustedProxy)", "the trustedProxy argument will be removed in v2, use SetTrustedProxy setting instead")
 		trusted = trustedProxy[0]
 	}
 
@@ -332,7 +394,12 @@ func (ctx *Context) IP(trustedProxy ...bool) net.IP {
 
 // Protocol -  Please use ctx.Scheme instead. This method will be changed in v2.
 func (ctx *Context) Protocol(trustedProxy ...bool) string {
-	return ctx.Scheme(trustedProxy...)
+	ctx.deprecated("ctx.Protocol", "it will be changed in v2, use ctx.Scheme instead")
+	trusted := ctx.Setting(SetTrustedProxy).(bool)
+	if len(trustedProxy) > 0 {
+		trusted = trustedProxy[0]
+	}
+	return ctx.scheme(trusted)
 }
 
 // Scheme returns the scheme ("http", "https", "ws", "wss") that a client used to connect to your proxy or load balancer.
@@ -340,9 +407,13 @@ func (ctx *Context) Protocol(trustedProxy ...bool) string {
 func (ctx *Context) Scheme(trustedProxy ...bool) string {
 	trusted := ctx.Setting(SetTrustedProxy).(bool)
 	if len(trustedProxy) > 0 {
+		ctx.deprecated("ctx.Scheme(trustedProxy)", "the trustedProxy argument will be removed in v2, use SetTrustedProxy setting instead")
 		trusted = trustedProxy[0]
 	}
+	return ctx.scheme(trusted)
+}
 
+func (ctx *Context) scheme(trusted bool) string {
 	var s string
 	if trusted {
 		if s = ctx.GetHeader(HeaderXRealScheme); s == "" {
@@ -369,6 +440,27 @@ func (ctx *Context) AcceptType(preferred ...string) string {
 	return negotiator.New(ctx.Req.Header).Type(preferred...)
 }
 
+// Accepts returns true if the content type (such as "application/json", without parameters) is
+// accepted by the HTTP Accept header. A request without Accept header accepts any type.
+//
+//	if ctx.Accepts("text/csv") {
+//		return ctx.Stream(http.StatusOK, "text/csv", report.CSV())
+//	}
+//	return ctx.JSON(http.StatusOK, report)
+func (ctx *Context) Accepts(mimeType string) bool {
+	return ctx.AcceptType(mimeType) != ""
+}
+
+// AcceptsJSON returns true if "application/json" is accepted by the HTTP Accept header.
+func (ctx *Context) AcceptsJSON() bool {
+	return ctx.Accepts(MIMEApplicationJSON)
+}
+
+// AcceptsHTML returns true if "text/html" is accepted by the HTTP Accept header.
+func (ctx *Context) AcceptsHTML() bool {
+	return ctx.Accepts(MIMETextHTML)
+}
+
 // AcceptLanguage returns the most preferred language from the HTTP Accept-Language header.
 // If nothing accepted, then empty string is returned.
 func (ctx *Context) AcceptLanguage(preferred ...string) string {
@@ -387,6 +479,39 @@ func (ctx *Context) AcceptCharset(preferred ...string) string {
 	return negotiator.New(ctx.Req.Header).Charset(preferred...)
 }
 
+// IsWebSocket returns true if the request is a WebSocket handshake, that is a GET request with
+// "Connection: upgrade" and "Upgrade: websocket" headers.
+func (ctx *Context) IsWebSocket() bool {
+	return ctx.Method == http.MethodGet &&
+		headerHasToken(ctx.Req.Header, HeaderConnection, "upgrade") &&
+		headerHasToken(ctx.Req.Header, HeaderUpgrade, "websocket")
+}
+
+// IsAJAX returns true if the request is sent by XMLHttpRequest with the "X-Requested-With: XMLHttpRequest" header,
+// such as by jQuery.
+func (ctx *Context) IsAJAX() bool {
+	return strings.EqualFold(ctx.GetHeader(HeaderXRequestedWith), "XMLHttpRequest")
+}
+
+// IsJSON returns true if the request body is JSON by the Content-Type header, "application/json" or
+// a "+json" suffix type (such as "application/merge-patch+json"). Use ctx.AcceptsJSON for the response.
+func (ctx *Context) IsJSON() bool {
+	mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader(HeaderContentType))
+	return mediaType == MIMEApplicationJSON || isLikeMediaType(mediaType, "json")
+}
+
+// headerHasToken reports whether the comma-separated header values contain the token, case-insensitively.
+func headerHasToken(header http.Header, key, token string) bool {
+	for _, val := range header.Values(key) {
+		for _, v := range strings.Split(val, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Param returns path parameter by name.
 func (ctx *Context) Param(key string) (val string) {
 	if s := CtxValue[State](ctx.ctx); s != nil && s.RouterMatched != nil {
@@ -395,6 +520,14 @@ func (ctx *Context) Param(key string) (val string) {
 	return
 }
 
+// RouteMeta returns the metadata value of the matched route by key, see router.Meta.
+func (ctx *Context) RouteMeta(key string) any {
+	if s := CtxValue[State](ctx.ctx); s != nil {
+		return s.routeMeta[key]
+	}
+	return nil
+}
+
 // Query returns the query param for the provided name.
 func (ctx *Context) Query(name string) string {
 	if ctx.query == nil {
@@ -412,8 +545,10 @@ func (ctx *Context) QueryAll(name string) []string {
 }
 
 // ParseBody parses request content with BodyParser, stores the result in the value
-// pointed to by BodyTemplate body, and validate it.
+// pointed to by BodyTemplate body, and validate it by the "validate" struct tags with SetValidateTags setting
+// (see ValidateStruct) and then body.Validate().
 // DefaultBodyParser support JSON, Form and XML.
+// The multipart/form-data request is parsed by ctx.ParseMultipart with default options.
 //
 // Define a BodyTemplate type in some API:
 //
@@ -436,19 +571,31 @@ func (ctx *Context) QueryAll(name string) []string {
 //		return err
 //	}
 func (ctx *Context) ParseBody(body BodyTemplate) error {
+	if mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader(HeaderContentType)); mediaType == MIMEMultipartForm {
+		return ctx.ParseMultipart(body)
+	}
+
+	buf, mediaType, params, err := ctx.readBody()
+	if err != nil {
+		return err
+	}
+
+	if err = ctx.app.bodyParser.Parse(buf, body, mediaType, params["charset"]); err != nil {
+		return ErrBadRequest.From(err)
+	}
+	return ctx.validateBody(body, ErrBadRequest)
+}
+
+// readBody reads the (decompressed) request body, limited by BodyParser.MaxBytes,
+// and parses the request Content-Type.
+func (ctx *Context) readBody() (buf []byte, mediaType string, params map[string]string, err error) {
 	if ctx.app.bodyParser == nil {
-		return Err.WithMsg("bodyParser not registered")
+		return nil, "", nil, Err.WithMsg("bodyParser not registered")
 	}
 	if ctx.Req.Body == nil {
-		return Err.WithMsg("missing request body")
+		return nil, "", nil, Err.WithMsg("missing request body")
 	}
 
-	var err error
-	var buf []byte
-	var mediaType string
-	var encoding string
-	var params map[string]string
-
 	if mediaType = ctx.GetHeader(HeaderContentType); mediaType == "" {
 		// RFC 2616, section 7.2.1 - empty type SHOULD be treated as application/octet-stream
 		mediaType = MIMEOctetStream
@@ -456,13 +603,13 @@ func (ctx *Context) ParseBody(body BodyTemplate) error {
 
 	ctx.SetAny("GEAR_REQUEST_CONTENT_TYPE", mediaType)
 	if mediaType, params, err = mime.ParseMediaType(mediaType); err != nil {
-		return ErrUnsupportedMediaType.From(err)
+		return nil, "", nil, ErrUnsupportedMediaType.From(err)
 	}
 
 	b := ctx.Req.Body
-	if encoding = ctx.GetHeader(HeaderContentEncoding); encoding != "" {
+	if encoding := ctx.GetHeader(HeaderContentEncoding); encoding != "" {
 		if b, err = Decompress(encoding, ctx.Req.Body); err != nil {
-			return ErrBadRequest.From(err)
+			return nil, "", nil, ErrBadRequest.From(err)
 		}
 	}
 
@@ -471,21 +618,34 @@ func (ctx *Context) ParseBody(body BodyTemplate) error {
 
 	if buf, err = io.ReadAll(reader); err != nil {
 		// err may not be 413 Request entity too large, just make it to 413
-		return ErrRequestEntityTooLarge.From(err)
+		return nil, "", nil, ErrRequestEntityTooLarge.From(err)
 	}
 
 	ctx.SetAny("GEAR_REQUEST_BODY", buf[:])
-	if err = ctx.app.bodyParser.Parse(buf, body, mediaType, params["charset"]); err != nil {
-		return ErrBadRequest.From(err)
+	if ctx.app.sniffBody && mediaType == MIMEOctetStream {
+		buf, mediaType = sniffBody(buf, mediaType)
 	}
-	if err = body.Validate(); err != nil {
-		return ErrBadRequest.From(err)
+	return buf, mediaType, params, nil
+}
+
+// sniffBody returns JSON or XML media type if the body clearly starts with "{", "[" or "<",
+// and the body without UTF-8 BOM. Otherwise returns the given buf and mediaType.
+func sniffBody(buf []byte, mediaType string) ([]byte, string) {
+	body := bytes.TrimPrefix(buf, []byte("\xef\xbb\xbf"))
+	if b := bytes.TrimLeft(body, " \t\r\n"); len(b) > 0 {
+		switch b[0] {
+		case '{', '[':
+			return body, MIMEApplicationJSON
+		case '<':
+			return body, MIMEApplicationXML
+		}
 	}
-	return nil
+	return buf, mediaType
 }
 
 // ParseURL parses router params (like ctx.Param) and queries (like ctx.Query) in request URL,
-// stores the result in the struct object pointed to by BodyTemplate body, and validate it.
+// stores the result in the struct object pointed to by BodyTemplate body, and validate it by the "validate"
+// struct tags with SetValidateTags setting (see ValidateStruct) and then body.Validate().
 //
 // Define a BodyTemplate type in some API:
 //
@@ -533,10 +693,7 @@ func (ctx *Context) ParseURL(body BodyTemplate) error {
 		}
 	}
 
-	if err := body.Validate(); err != nil {
-		return ErrBadRequest.From(err)
-	}
-	return nil
+	return ctx.validateBody(body, ErrBadRequest)
 }
 
 // Get - Please use ctx.GetHeader instead. This method will be changed in v2.
@@ -580,6 +737,20 @@ func (ctx *Context) SetHeader(key, value string) {
 	ctx.Res.Set(key, value)
 }
 
+// SetHeaders saves the key/value pairs to the response Header, it replaces any existing values of the keys.
+//
+//	ctx.SetHeaders(map[string]string{
+//		gear.HeaderCacheControl: "no-cache",
+//		gear.HeaderPragma:       "no-cache",
+//		gear.HeaderExpires:      "0",
+//	})
+func (ctx *Context) SetHeaders(headers map[string]string) {
+	h := ctx.Res.handlerHeader
+	for key, value := range headers {
+		h[textproto.CanonicalMIMEHeaderKey(key)] = []string{value}
+	}
+}
+
 // Status set a status code to the response, ctx.Res.Status() returns the status code.
 func (ctx *Context) Status(code int) {
 	ctx.Res.status = code
@@ -598,11 +769,23 @@ func (ctx *Context) HTML(code int, str string) error {
 	return ctx.End(code, []byte(str))
 }
 
+// HTMLString is like ctx.HTML, but the str is not copied to the response body, it is for the constant
+// responses on the hot paths, such as ping and health endpoints. The response body (ctx.Res.Body()) shares
+// the memory of str and must not be modified by the "after hooks" and middlewares.
+//
+//	router.Get("/ping", func(ctx *gear.Context) error {
+//		return ctx.HTMLString(http.StatusOK, "pong")
+//	})
+func (ctx *Context) HTMLString(code int, str string) error {
+	ctx.Type(MIMETextHTMLCharsetUTF8)
+	return ctx.End(code, stringBytes(str))
+}
+
 // JSON set a JSON body with status code to response.
 // It will end the ctx. The middlewares after current middleware will not run.
 // "after hooks" (if no error) and "end hooks" will run normally.
 func (ctx *Context) JSON(code int, val any) error {
-	buf, err := json.Marshal(val)
+	buf, err := json.Marshal(ctx.transform(val))
 	if err != nil {
 		return err
 	}
@@ -617,11 +800,104 @@ func (ctx *Context) JSONBlob(code int, buf []byte) error {
 	return ctx.End(code, buf)
 }
 
+// JSONStream sends a JSON response with status code. Unlike ctx.JSON, it encodes val directly to the response
+// with json.Encoder instead of building the whole payload in memory, so it is suitable for large payloads.
+// If val is a receive channel or a `func(yield func(any) error) error` iterator, the values from it are
+// streamed as a JSON array, until the channel is closed (or the request is canceled) or the iterator returns.
+// The SetResponseTransformer hook is applied to val, except channels and iterators.
+// Once the first value is written, the status code can't be changed, an error from the source or encoding
+// will truncate the response.
+// It will end the ctx. The middlewares after current middleware will not run.
+// "after hooks" and "end hooks" will run normally.
+//
+//	router.Get("/users", func(ctx *gear.Context) error {
+//		rows, err := db.QueryContext(ctx, "SELECT id, name FROM users")
+//		if err != nil {
+//			return err
+//		}
+//		defer rows.Close()
+//		return ctx.JSONStream(http.StatusOK, func(yield func(any) error) error {
+//			for rows.Next() {
+//				var user User
+//				if err := rows.Scan(&user.ID, &user.Name); err != nil {
+//					return err
+//				}
+//				if err := yield(user); err != nil {
+//					return err
+//				}
+//			}
+//			return rows.Err()
+//		})
+//	})
+func (ctx *Context) JSONStream(code int, val any) error {
+	if !ctx.Res.ended.swapTrue() {
+		return ErrInternalServerError.WithMsg("request ended before ctx.JSONStream")
+	}
+	ctx.Status(code)
+	ctx.Type(MIMEApplicationJSONCharsetUTF8)
+	ctx.Res.Del(HeaderContentLength)
+
+	if iter, ok := val.(func(yield func(any) error) error); ok {
+		return ctx.jsonArrayStream(iter)
+	}
+	if rv := reflect.ValueOf(val); rv.Kind() == reflect.Chan && rv.Type().ChanDir()&reflect.RecvDir != 0 {
+		return ctx.jsonArrayStream(func(yield func(any) error) error {
+			cases := []reflect.SelectCase{
+				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+				{Dir: reflect.SelectRecv, Chan: rv},
+			}
+			for {
+				chosen, v, ok := reflect.Select(cases)
+				switch {
+				case chosen == 0:
+					return ctx.Err()
+				case !ok:
+					return nil
+				}
+				if err := yield(v.Interface()); err != nil {
+					return err
+				}
+			}
+		})
+	}
+	// json.Encoder writes nothing if encoding failed, so the error can still be responded.
+	return json.NewEncoder(ctx.Res).Encode(ctx.transform(val))
+}
+
+// jsonArrayStream writes the values yielded by iter as a JSON array, reusing one buffer for all values.
+func (ctx *Context) jsonArrayStream(iter func(yield func(any) error) error) error {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	sep := byte('[')
+	err := iter(func(val any) error {
+		buf.Reset()
+		buf.WriteByte(sep)
+		if err := enc.Encode(val); err != nil {
+			return err
+		}
+		buf.Truncate(buf.Len() - 1) // remove the newline added by Encode
+		if _, err := ctx.Res.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		sep = ','
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if sep == '[' {
+		_, err = ctx.Res.Write([]byte("[]\n"))
+	} else {
+		_, err = ctx.Res.Write([]byte("]\n"))
+	}
+	return err
+}
+
 // JSONP sends a JSONP response with status code. It uses `callback` to construct the JSONP payload.
 // It will end the ctx. The middlewares after current middleware will not run.
 // "after hooks" (if no error) and "end hooks" will run normally.
 func (ctx *Context) JSONP(code int, callback string, val any) error {
-	buf, err := json.Marshal(val)
+	buf, err := json.Marshal(ctx.transform(val))
 	if err != nil {
 		return err
 	}
@@ -647,7 +923,7 @@ func (ctx *Context) JSONPBlob(code int, callback string, buf []byte) error {
 // It will end the ctx. The middlewares after current middleware will not run.
 // "after hooks" (if no error) and "end hooks" will run normally.
 func (ctx *Context) XML(code int, val any) error {
-	buf, err := xml.Marshal(val)
+	buf, err := xml.Marshal(ctx.transform(val))
 	if err != nil {
 		return err
 	}
@@ -662,6 +938,39 @@ func (ctx *Context) XMLBlob(code int, buf []byte) error {
 	return ctx.End(code, buf)
 }
 
+// MsgPack marshals the val with the "msgpack" marshaler registered by gear.RegisterBodyMarshaler,
+// and set it as "application/msgpack" body with status code to response.
+// It will end the ctx. The middlewares after current middleware will not run.
+// "after hooks" and "end hooks" will run normally.
+func (ctx *Context) MsgPack(code int, val any) error {
+	marshal := bodyMarshaler("msgpack")
+	if marshal == nil {
+		return Err.WithMsg("msgpack marshaler not registered")
+	}
+	buf, err := marshal(ctx.transform(val))
+	if err != nil {
+		return err
+	}
+	ctx.Type(MIMEApplicationMsgPack)
+	return ctx.End(code, buf)
+}
+
+// Proto marshals the protobuf message with the function registered by `app.Set(gear.SetProtoMarshal, fn)`,
+// and set it as "application/protobuf" body with status code to response.
+// It will end the ctx. The middlewares after current middleware will not run.
+// "after hooks" and "end hooks" will run normally.
+func (ctx *Context) Proto(code int, m any) error {
+	if ctx.app.pbMarshal == nil {
+		return Err.WithMsg("proto marshaler not registered")
+	}
+	buf, err := ctx.app.pbMarshal(m)
+	if err != nil {
+		return err
+	}
+	ctx.Type(MIMEApplicationProtobuf)
+	return ctx.End(code, buf)
+}
+
 // Send handle code and data with Sender interface.
 // Sender can be registered using `app.Set(gear.SetSender, someSender)`.
 // It will end the ctx. The middlewares after current middleware will not run.
@@ -701,7 +1010,7 @@ func (ctx *Context) Send(code int, data any) (err error) {
 	if ctx.app.sender == nil {
 		return Err.WithMsg("sender not registered")
 	}
-	return ctx.app.sender.Send(ctx, code, data)
+	return ctx.app.sender.Send(ctx, code, ctx.transform(data))
 }
 
 // Render renders a template with data and sends a text/html response with status
@@ -709,12 +1018,33 @@ func (ctx *Context) Send(code int, data any) (err error) {
 // It will end the ctx. The middlewares after current middleware will not run.
 // "after hooks" (if no error) and "end hooks" will run normally.
 func (ctx *Context) Render(code int, name string, data any) (err error) {
+	return ctx.RenderAs(code, MIMETextHTMLCharsetUTF8, name, data)
+}
+
+// RenderAs renders a template with data and sends a response with status code and content type, such as
+// plain text, XML, CSV or email bodies, so the templated machine formats don't need a second templating stack.
+// The Content-Type header is set before rendering, the renderer can choose the template engine by it,
+// such as text/template for the non-HTML outputs that should not be HTML-escaped:
+//
+//	func (t *MyRenderer) Render(ctx *gear.Context, w io.Writer, name string, data any) error {
+//		if strings.HasPrefix(ctx.Res.Get(gear.HeaderContentType), gear.MIMETextHTML) {
+//			return t.html.ExecuteTemplate(w, name, data)
+//		}
+//		return t.text.ExecuteTemplate(w, name, data)
+//	}
+//
+//	return ctx.RenderAs(http.StatusOK, gear.MIMETextPlainCharsetUTF8, "robots.txt", data)
+//	return ctx.RenderAs(http.StatusOK, gear.MIMEApplicationXMLCharsetUTF8, "sitemap.xml", data)
+//
+// It will end the ctx. The middlewares after current middleware will not run.
+// "after hooks" (if no error) and "end hooks" will run normally.
+func (ctx *Context) RenderAs(code int, contentType, name string, data any) (err error) {
 	if ctx.app.renderer == nil {
 		return Err.WithMsg("renderer not registered")
 	}
+	ctx.Type(contentType)
 	buf := new(bytes.Buffer)
 	if err = ctx.app.renderer.Render(ctx, buf, name, data); err == nil {
-		ctx.Type(MIMETextHTMLCharsetUTF8)
 		return ctx.End(code, buf.Bytes())
 	}
 	return
@@ -734,6 +1064,40 @@ func (ctx *Context) Stream(code int, contentType string, r io.Reader) (err error
 	return
 }
 
+// StreamSeeker sends a streaming response from `io.ReadSeeker` with status code and content type.
+// Unlike ctx.Stream, it honors the Range and If-Range request headers for 200 responses of GET and HEAD requests
+// (by http.ServeContent), responding 206 Partial Content with the requested ranges, so clients can resume
+// downloads or seek in videos. The If-Range header is evaluated against the response ETag header,
+// or the Last-Modified header set by the optional modtime.
+// It will end the ctx. The middlewares after current middleware will not run.
+// "after hooks" and "end hooks" will run normally.
+//
+//	router.Get("/videos/:id", func(ctx *gear.Context) error {
+//		file, err := os.Open(videoPath(ctx.Param("id")))
+//		if err != nil {
+//			return gear.ErrNotFound.From(err)
+//		}
+//		defer file.Close()
+//		info, _ := file.Stat()
+//		return ctx.StreamSeeker(http.StatusOK, "video/mp4", file, info.ModTime())
+//	})
+func (ctx *Context) StreamSeeker(code int, contentType string, r io.ReadSeeker, modtime ...time.Time) (err error) {
+	if code != http.StatusOK || (ctx.Method != http.MethodGet && ctx.Method != http.MethodHead) {
+		return ctx.Stream(code, contentType, r)
+	}
+	if ctx.Res.ended.swapTrue() {
+		var t time.Time
+		if len(modtime) > 0 {
+			t = modtime[0]
+		}
+		ctx.Type(contentType)
+		http.ServeContent(ctx.Res, ctx.Req, "", t, r)
+	} else {
+		err = ErrInternalServerError.WithMsg("request ended before ctx.StreamSeeker")
+	}
+	return
+}
+
 // Attachment sends a response from `io.ReaderSeeker` as attachment, prompting
 // client to save the file. If inline is true, the attachment will sends as inline,
 // opening the file in the browser.
@@ -753,6 +1117,22 @@ func (ctx *Context) Attachment(name string, modtime time.Time, content io.ReadSe
 	return
 }
 
+// URLFor returns the URL of the named route with params by name and query, it is a wrap of app.URLWith.
+// It is useful to generate redirect targets and links without hard-coding paths:
+//
+//	router.Get("/users/:id", GetUser).Name("user.show")
+//	router.Post("/users", func(ctx *gear.Context) error {
+//		// create user...
+//		url, err := ctx.URLFor("user.show", map[string]string{"id": user.ID}, nil)
+//		if err != nil {
+//			return err
+//		}
+//		return ctx.Redirect(url)
+//	})
+func (ctx *Context) URLFor(name string, params map[string]string, query url.Values) (string, error) {
+	return ctx.app.URLWith(name, params, query)
+}
+
 // Redirect redirects the request with status code 302.
 // You can use other status code with ctx.Status method, It is a wrap of http.Redirect.
 // It will end the ctx. The middlewares after current middleware will not run.
@@ -786,6 +1166,16 @@ func (ctx *Context) OkXML(val any) error {
 	return ctx.XML(http.StatusOK, val)
 }
 
+// OkMsgPack is a wrap of ctx.MsgPack with http.StatusOK
+func (ctx *Context) OkMsgPack(val any) error {
+	return ctx.MsgPack(http.StatusOK, val)
+}
+
+// OkProto is a wrap of ctx.Proto with http.StatusOK
+func (ctx *Context) OkProto(m any) error {
+	return ctx.Proto(http.StatusOK, m)
+}
+
 // OkSend is a wrap of ctx.Send with http.StatusOK
 func (ctx *Context) OkSend(val any) error {
 	return ctx.Send(http.StatusOK, val)
@@ -832,7 +1222,7 @@ func (ctx *Context) End(code int, buf ...[]byte) (err error) {
 	if ctx.Res.ended.swapTrue() {
 		var body []byte
 		if len(buf) > 0 {
-			body = buf[0]
+			body = ctx.encodeCharset(buf[0])
 		}
 		err = ctx.Res.respond(code, body)
 	} else {
@@ -861,6 +1251,79 @@ func (ctx *Context) OnEnd(hook func()) {
 	ctx.Res.endHooks = append(ctx.Res.endHooks, hook)
 }
 
+// OnShutdown registers a function to run in a goroutine when the app starts closing by app.Close, if the request
+// is still processing. The graceful shutdown waits for the active requests, so the long-lived streaming requests
+// (such as WebSocket) should use it to send a final message and end before the grace timeout force-closes
+// the connections. ctx.SSEStream ends the stream on shutdown, see SSEOptions.OnShutdown.
+//
+//	ctx.OnShutdown(func() {
+//		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutdown"))
+//		conn.Close()
+//	})
+func (ctx *Context) OnShutdown(fn func()) {
+	done := ctx.Done()
+	go func() {
+		select {
+		case <-ctx.app.draining:
+			fn()
+		case <-done:
+		}
+	}()
+}
+
+// SnapshotCtx captures the request metadata that is safe to use after the request ended, such as in "end hooks"
+// or async log sinks, where the http.Request may be reset for reusing (issue #24). The keys are the same as
+// the logging package: "start", "ip", "scheme", "proto", "method", "uri", "userAgent", "xRequestId", "router",
+// and "params" (a copy of the matched path parameters) and "status" (if the response status is set).
+//
+//	snapshot := gear.SnapshotCtx(ctx)
+//	ctx.OnEnd(func() {
+//		snapshot["status"] = ctx.Res.Status()
+//		auditLog <- snapshot
+//	})
+func SnapshotCtx(ctx *Context) map[string]any {
+	snapshot := map[string]any{
+		"start":     ctx.StartAt,
+		"ip":        ctx.IP().String(),
+		"scheme":    ctx.Scheme(),
+		"proto":     ctx.Req.Proto,
+		"method":    ctx.Method,
+		"uri":       ctx.Req.RequestURI,
+		"userAgent": ctx.GetHeader(HeaderUserAgent),
+	}
+	if rid := ctx.requestID(); rid != "" {
+		snapshot["xRequestId"] = rid
+	}
+	if router := GetRouterPatternFromCtx(ctx); router != "" {
+		snapshot["router"] = ctx.Method + " " + router
+	}
+	if params := ParamsFromCtx(ctx); len(params) > 0 {
+		copied := make(map[string]string, len(params))
+		for k, v := range params {
+			copied[k] = v
+		}
+		snapshot["params"] = copied
+	}
+	if status := ctx.Res.Status(); status > 0 {
+		snapshot["status"] = status
+	}
+	return snapshot
+}
+
+// transform applies the SetResponseTransformer hook to data, at most once per request.
+// The error, []byte and string values are not transformed, so the Sender can handle them as is.
+func (ctx *Context) transform(data any) any {
+	if ctx.app.transformer == nil || ctx.transformed {
+		return data
+	}
+	switch data.(type) {
+	case error, []byte, string:
+		return data
+	}
+	ctx.transformed = true
+	return ctx.app.transformer(ctx, data)
+}
+
 func (ctx *Context) respondError(err HTTPError) {
 	if !ctx.Res.wroteHeader.isTrue() {
 		code, contentType, body := ctx.app.renderError(err)
@@ -880,7 +1343,7 @@ func (ctx *Context) respondError(err HTTPError) {
 
 func (ctx *Context) handleCompress() (cw *compressWriter) {
 	if ctx.app.compress != nil && ctx.Method != http.MethodHead && ctx.Method != http.MethodOptions {
-		if cw = newCompress(ctx.Res, ctx.app.compress, ctx.AcceptEncoding("gzip", "deflate")); cw != nil {
+		if cw = newCompress(ctx, ctx.app.compress, ctx.AcceptEncoding(compressEncodings(ctx.GetHeader(HeaderAcceptEncoding))...)); cw != nil {
 			ctx.Res.rw = cw // override with http.ResponseWriter wrapper.
 		}
 	}
@@ -5,6 +5,7 @@ import (
 	"compress/gzip"
 	"compress/zlib"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
@@ -13,11 +14,13 @@ import (
 	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"reflect"
 	"strings"
 	"sync/atomic"
 	"testing"
+	texttemplate "text/template"
 	"time"
 
 	"github.com/go-http-utils/cookie"
@@ -480,6 +483,81 @@ func TestGearContextAccept(t *testing.T) {
 		assert.Equal("", ctx.AcceptType("image/png", "image/tiff"))
 	})
 
+	t.Run("ctx.Accepts", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		ctx := CtxTest(app, "GET", "http://example.com/foo", nil)
+		assert.True(ctx.Accepts("image/png"))
+		assert.True(ctx.AcceptsJSON())
+		assert.True(ctx.AcceptsHTML())
+
+		ctx.Req.Header.Set(HeaderAccept, "application/*;q=0.2, image/jpeg;q=0.8, text/plain")
+		assert.True(ctx.Accepts("image/jpeg"))
+		assert.False(ctx.Accepts("image/png"))
+		assert.True(ctx.AcceptsJSON())
+		assert.False(ctx.AcceptsHTML())
+
+		ctx.Req.Header.Set(HeaderAccept, "text/html, application/xhtml+xml, */*;q=0.1")
+		assert.True(ctx.AcceptsHTML())
+		assert.True(ctx.AcceptsJSON())
+
+		ctx.Req.Header.Set(HeaderAccept, "application/json;q=0")
+		assert.False(ctx.AcceptsJSON())
+	})
+
+	t.Run("ctx.IsWebSocket", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		ctx := CtxTest(app, "GET", "http://example.com/ws", nil)
+		assert.False(ctx.IsWebSocket())
+
+		ctx.Req.Header.Set(HeaderConnection, "keep-alive, Upgrade")
+		ctx.Req.Header.Set(HeaderUpgrade, "WebSocket")
+		assert.True(ctx.IsWebSocket())
+
+		ctx.Req.Header.Set(HeaderUpgrade, "h2c")
+		assert.False(ctx.IsWebSocket())
+
+		ctx = CtxTest(app, "POST", "http://example.com/ws", nil)
+		ctx.Req.Header.Set(HeaderConnection, "upgrade")
+		ctx.Req.Header.Set(HeaderUpgrade, "websocket")
+		assert.False(ctx.IsWebSocket())
+	})
+
+	t.Run("ctx.IsAJAX", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		ctx := CtxTest(app, "GET", "http://example.com/foo", nil)
+		assert.False(ctx.IsAJAX())
+
+		ctx.Req.Header.Set(HeaderXRequestedWith, "XMLHttpRequest")
+		assert.True(ctx.IsAJAX())
+		ctx.Req.Header.Set(HeaderXRequestedWith, "xmlhttprequest")
+		assert.True(ctx.IsAJAX())
+		ctx.Req.Header.Set(HeaderXRequestedWith, "com.example.app")
+		assert.False(ctx.IsAJAX())
+	})
+
+	t.Run("ctx.IsJSON", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		ctx := CtxTest(app, "POST", "http://example.com/foo", nil)
+		assert.False(ctx.IsJSON())
+
+		for _, typ := range []string{MIMEApplicationJSON, MIMEApplicationJSONCharsetUTF8, MIMEApplicationMergePatchJSON, "Application/JSON"} {
+			ctx.Req.Header.Set(HeaderContentType, typ)
+			assert.True(ctx.IsJSON(), typ)
+		}
+		for _, typ := range []string{MIMETextPlain, MIMEApplicationForm, "text/json+html", "application/jsonp", "invalid;;"} {
+			ctx.Req.Header.Set(HeaderContentType, typ)
+			assert.False(ctx.IsJSON(), typ)
+		}
+	})
+
 	t.Run("ctx.AcceptLanguage", func(t *testing.T) {
 		assert := assert.New(t)
 
@@ -745,6 +823,147 @@ func TestGearContextParseBody(t *testing.T) {
 		assert.Equal("password", body.Pass)
 	})
 
+	t.Run("should parse form content", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ctx := CtxTest(app, "POST", "http://example.com/foo",
+			bytes.NewBufferString(`id=admin&pass=pass%26word`))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationForm+"; charset=utf-8")
+
+		body := jsonBodyTemplate{}
+		assert.Nil(ctx.ParseBody(&body))
+		assert.Equal("admin", body.ID)
+		assert.Equal("pass&word", body.Pass)
+
+		ctx = CtxTest(app, "POST", "http://example.com/foo", bytes.NewBufferString(`id=ad&pass=password`))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationForm)
+		err := ctx.ParseBody(&jsonBodyTemplate{})
+		assert.Equal("BadRequest: invalid id or pass", err.Error())
+
+		ctx = CtxTest(app, "POST", "http://example.com/foo", bytes.NewBufferString(`id=%zz`))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationForm)
+		err = ctx.ParseBody(&jsonBodyTemplate{})
+		assert.Equal(400, err.(*Error).Code)
+	})
+
+	t.Run("should parse registered YAML and TOML content", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.Panics(func() { RegisterBodyUnmarshaler("json", json.Unmarshal) })
+		assert.Panics(func() { RegisterBodyUnmarshaler("yaml", nil) })
+
+		// a minimal "key: value" or `key = "value"` unmarshaler for testing
+		unmarshal := func(sep string) func(buf []byte, body any) error {
+			return func(buf []byte, body any) error {
+				m := make(map[string]string)
+				for _, line := range strings.Split(strings.TrimSpace(string(buf)), "\n") {
+					kv := strings.SplitN(line, sep, 2)
+					if len(kv) != 2 {
+						return errors.New("invalid line: " + line)
+					}
+					m[strings.TrimSpace(kv[0])] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+				}
+				data, _ := json.Marshal(m)
+				return json.Unmarshal(data, body)
+			}
+		}
+
+		yamlBody := "id: admin\npass: password\n"
+		ctx := CtxTest(app, "POST", "http://example.com/foo", bytes.NewBufferString(yamlBody))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationYAML)
+		err := ctx.ParseBody(&jsonBodyTemplate{})
+		assert.Equal(415, err.(*Error).Code)
+
+		RegisterBodyUnmarshaler("YAML", unmarshal(":"))
+		RegisterBodyUnmarshaler("toml", unmarshal("="))
+		defer func() {
+			bodyUnmarshalers.Lock()
+			delete(bodyUnmarshalers.m, "yaml")
+			delete(bodyUnmarshalers.m, "toml")
+			bodyUnmarshalers.Unlock()
+		}()
+
+		for _, c := range []struct{ body, contentType string }{
+			{yamlBody, MIMEApplicationYAMLCharsetUTF8},
+			{yamlBody, MIMETextYAML},
+			{yamlBody, "application/x-yaml"},
+			{yamlBody, "application/vnd.api+yaml"},
+			{"id = \"admin\"\npass = \"password\"\n", MIMEApplicationTOML},
+		} {
+			ctx = CtxTest(app, "POST", "http://example.com/foo", bytes.NewBufferString(c.body))
+			ctx.Req.Header.Set(HeaderContentType, c.contentType)
+			body := jsonBodyTemplate{}
+			assert.Nil(ctx.ParseBody(&body), c.contentType)
+			assert.Equal("admin", body.ID)
+			assert.Equal("password", body.Pass)
+		}
+
+		ctx = CtxTest(app, "POST", "http://example.com/foo", bytes.NewBufferString("id: ad\npass: password\n"))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationYAML)
+		err = ctx.ParseBody(&jsonBodyTemplate{})
+		assert.Equal(400, err.(*Error).Code)
+		assert.Equal("BadRequest: invalid id or pass", err.Error())
+
+		ctx = CtxTest(app, "POST", "http://example.com/foo", bytes.NewBufferString("id admin"))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationYAML)
+		err = ctx.ParseBody(&jsonBodyTemplate{})
+		assert.Equal(400, err.(*Error).Code)
+		assert.Equal("BadRequest: invalid line: id admin", err.Error())
+
+		ctx = CtxTest(app, "POST", "http://example.com/foo", bytes.NewBufferString(yamlBody))
+		ctx.Req.Header.Set(HeaderContentType, "image/yaml")
+		err = ctx.ParseBody(&jsonBodyTemplate{})
+		assert.Equal(415, err.(*Error).Code)
+	})
+
+	t.Run("should parse protobuf content with ProtoBodyParser", func(t *testing.T) {
+		assert := assert.New(t)
+
+		// a fake "id\x00pass" message codec for testing
+		unmarshal := func(buf []byte, body any) error {
+			fields := bytes.SplitN(buf, []byte{0}, 2)
+			if len(fields) != 2 {
+				return errors.New("invalid message")
+			}
+			b := body.(*jsonBodyTemplate)
+			b.ID, b.Pass = string(fields[0]), string(fields[1])
+			return nil
+		}
+
+		app := New()
+		app.Set(SetBodyParser, ProtoBodyParser{DefaultBodyParser: 100})
+		ctx := CtxTest(app, "POST", "http://example.com/foo", bytes.NewBufferString("admin\x00password"))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationProtobuf)
+		err := ctx.ParseBody(&jsonBodyTemplate{})
+		assert.Equal(415, err.(*Error).Code)
+
+		app.Set(SetBodyParser, ProtoBodyParser{DefaultBodyParser: 100, Unmarshal: unmarshal})
+		for _, contentType := range []string{MIMEApplicationProtobuf, "application/x-protobuf"} {
+			ctx = CtxTest(app, "POST", "http://example.com/foo", bytes.NewBufferString("admin\x00password"))
+			ctx.Req.Header.Set(HeaderContentType, contentType)
+			body := jsonBodyTemplate{}
+			assert.Nil(ctx.ParseBody(&body), contentType)
+			assert.Equal("admin", body.ID)
+			assert.Equal("password", body.Pass)
+		}
+
+		ctx = CtxTest(app, "POST", "http://example.com/foo", bytes.NewBufferString("ad\x00password"))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationProtobuf)
+		err = ctx.ParseBody(&jsonBodyTemplate{})
+		assert.Equal("BadRequest: invalid id or pass", err.Error())
+
+		ctx = CtxTest(app, "POST", "http://example.com/foo", bytes.NewBufferString("admin"))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationProtobuf)
+		err = ctx.ParseBody(&jsonBodyTemplate{})
+		assert.Equal("BadRequest: invalid message", err.Error())
+
+		ctx = CtxTest(app, "POST", "http://example.com/foo", bytes.NewBufferString(`{"id":"admin","pass":"password"}`))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+		body := jsonBodyTemplate{}
+		assert.Nil(ctx.ParseBody(&body))
+		assert.Equal("admin", body.ID)
+	})
+
 	t.Run("should support mapTemplate", func(t *testing.T) {
 		assert := assert.New(t)
 
@@ -792,6 +1011,40 @@ func TestGearContextParseBody(t *testing.T) {
 		assert.Equal(415, err.(*Error).Code)
 	})
 
+	t.Run("should sniff body type with SetBodySniffing", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		assert.Panics(func() {
+			app.Set(SetBodySniffing, "true")
+		})
+		app.Set(SetBodySniffing, true)
+
+		ctx := CtxTest(app, "POST", "http://example.com/foo",
+			bytes.NewBuffer([]byte("\xef\xbb\xbf \n{\"id\":\"admin\",\"pass\":\"password\"}")))
+		jsonBody := jsonBodyTemplate{}
+		assert.Nil(ctx.ParseBody(&jsonBody))
+		assert.Equal("admin", jsonBody.ID)
+
+		ctx = CtxTest(app, "POST", "http://example.com/foo",
+			bytes.NewBuffer([]byte(`<body id="admin" pass="password"></body>`)))
+		ctx.Req.Header.Set(HeaderContentType, MIMEOctetStream)
+		xmlBody := xmlBodyTemplate{}
+		assert.Nil(ctx.ParseBody(&xmlBody))
+		assert.Equal("admin", xmlBody.ID)
+
+		ctx = CtxTest(app, "POST", "http://example.com/foo",
+			bytes.NewBuffer([]byte(`id=admin&pass=password`)))
+		err := ctx.ParseBody(&jsonBody)
+		assert.Equal(415, err.(*Error).Code)
+
+		ctx = CtxTest(app, "POST", "http://example.com/foo",
+			bytes.NewBuffer([]byte(`{"id":"admin","pass":"password"}`)))
+		ctx.Req.Header.Set(HeaderContentType, MIMETextPlain)
+		err = ctx.ParseBody(&jsonBody)
+		assert.Equal(415, err.(*Error).Code)
+	})
+
 	t.Run("should 400 error with empty content", func(t *testing.T) {
 		assert := assert.New(t)
 
@@ -1254,6 +1507,86 @@ func TestGearContextJSON(t *testing.T) {
 	assert.Equal(MIMEApplicationJSONCharsetUTF8, res.Header.Get(HeaderContentType))
 }
 
+func TestGearContextJSONStream(t *testing.T) {
+	assert := assert.New(t)
+
+	app := New()
+	app.Use(func(ctx *Context) error {
+		switch ctx.Path {
+		case "/value":
+			return ctx.JSONStream(http.StatusCreated, map[string]any{"a": []int{1, 2}})
+		case "/chan":
+			ch := make(chan int)
+			go func() {
+				for i := 0; i < 3; i++ {
+					ch <- i
+				}
+				close(ch)
+			}()
+			return ctx.JSONStream(http.StatusOK, ch)
+		case "/empty":
+			ch := make(chan string)
+			close(ch)
+			return ctx.JSONStream(http.StatusOK, (<-chan string)(ch))
+		case "/iter":
+			return ctx.JSONStream(http.StatusOK, func(yield func(any) error) error {
+				for _, s := range []string{"a", "<b>"} {
+					if err := yield(map[string]string{"name": s}); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		case "/iter-error":
+			return ctx.JSONStream(http.StatusOK, func(yield func(any) error) error {
+				return ErrUnprocessableEntity.WithMsg("some error")
+			})
+		case "/error":
+			return ctx.JSONStream(http.StatusOK, math.NaN())
+		}
+		ctx.End(204)
+		return ctx.JSONStream(http.StatusOK, 1)
+	})
+
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	res, err := RequestBy("GET", host+"/value")
+	assert.Nil(err)
+	assert.Equal(201, res.StatusCode)
+	assert.Equal(MIMEApplicationJSONCharsetUTF8, res.Header.Get(HeaderContentType))
+	assert.Equal("{\"a\":[1,2]}\n", PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host+"/chan")
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal("[0,1,2]\n", PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host+"/empty")
+	assert.Nil(err)
+	assert.Equal("[]\n", PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host+"/iter")
+	assert.Nil(err)
+	assert.Equal(`[{"name":"a"},{"name":"\u003cb\u003e"}]`+"\n", PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host+"/iter-error")
+	assert.Nil(err)
+	assert.Equal(422, res.StatusCode)
+	assert.Contains(PickRes(res.Text()).(string), "some error")
+
+	res, err = RequestBy("GET", host+"/error")
+	assert.Nil(err)
+	assert.Equal(500, res.StatusCode)
+	assert.Contains(PickRes(res.Text()).(string), "json: unsupported value")
+
+	res, err = RequestBy("GET", host+"/ended")
+	assert.Nil(err)
+	assert.Equal(204, res.StatusCode)
+	res.Body.Close()
+}
+
 func TestGearContextOkJSON(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1408,6 +1741,122 @@ func (s *SenderTest) Send(ctx *Context, code int, data any) error {
 	}
 }
 
+func TestGearContextProto(t *testing.T) {
+	assert := assert.New(t)
+
+	app := New()
+	assert.Panics(func() { app.Set(SetProtoMarshal, func(m any) []byte { return nil }) })
+	app.Use(func(ctx *Context) error {
+		if ctx.Path == "/ok" {
+			return ctx.OkProto(&jsonBodyTemplate{"admin", "password"})
+		}
+		return ctx.Proto(http.StatusCreated, &jsonBodyTemplate{})
+	})
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	res, err := RequestBy("GET", host+"/ok")
+	assert.Nil(err)
+	assert.Equal(500, res.StatusCode)
+	assert.Equal(`{"error":"Error","message":"proto marshaler not registered"}`, PickRes(res.Text()).(string))
+
+	// a fake "id\x00pass" message codec for testing
+	app.Set(SetProtoMarshal, func(m any) ([]byte, error) {
+		b := m.(*jsonBodyTemplate)
+		if b.ID == "" {
+			return nil, errors.New("invalid message")
+		}
+		return []byte(b.ID + "\x00" + b.Pass), nil
+	})
+	res, err = RequestBy("GET", host+"/ok")
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal(MIMEApplicationProtobuf, res.Header.Get(HeaderContentType))
+	assert.Equal("admin\x00password", PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host)
+	assert.Nil(err)
+	assert.Equal(500, res.StatusCode)
+	assert.Equal(`{"error":"InternalServerError","message":"invalid message"}`, PickRes(res.Text()).(string))
+}
+
+func TestGearContextMsgPack(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Panics(func() { RegisterBodyMarshaler("json", json.Marshal) })
+	assert.Panics(func() { RegisterBodyMarshaler("msgpack", nil) })
+
+	app := New()
+	app.Set(SetSender, DefaultSender{})
+	app.Use(func(ctx *Context) error {
+		switch ctx.Path {
+		case "/send":
+			return ctx.Send(http.StatusOK, map[string]string{"id": "admin"})
+		case "/parse":
+			body := jsonBodyTemplate{}
+			if err := ctx.ParseBody(&body); err != nil {
+				return err
+			}
+			return ctx.OkMsgPack(body)
+		}
+		return ctx.MsgPack(http.StatusCreated, []string{"Hello"})
+	})
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	res, err := RequestBy("GET", host)
+	assert.Nil(err)
+	assert.Equal(500, res.StatusCode)
+	assert.Equal(`{"error":"Error","message":"msgpack marshaler not registered"}`, PickRes(res.Text()).(string))
+
+	// a fake "mp:{json}" codec for testing
+	RegisterBodyMarshaler("MsgPack", func(v any) ([]byte, error) {
+		buf, err := json.Marshal(v)
+		return append([]byte("mp:"), buf...), err
+	})
+	RegisterBodyUnmarshaler("msgpack", func(buf []byte, body any) error {
+		return json.Unmarshal(bytes.TrimPrefix(buf, []byte("mp:")), body)
+	})
+	defer func() {
+		bodyMarshalers.Lock()
+		delete(bodyMarshalers.m, "msgpack")
+		bodyMarshalers.Unlock()
+		bodyUnmarshalers.Lock()
+		delete(bodyUnmarshalers.m, "msgpack")
+		bodyUnmarshalers.Unlock()
+	}()
+
+	res, err = RequestBy("GET", host)
+	assert.Nil(err)
+	assert.Equal(201, res.StatusCode)
+	assert.Equal(MIMEApplicationMsgPack, res.Header.Get(HeaderContentType))
+	assert.Equal(`mp:["Hello"]`, PickRes(res.Text()).(string))
+
+	req, _ := http.NewRequest("POST", host+"/parse", strings.NewReader(`mp:{"id":"admin","pass":"password"}`))
+	req.Header.Set(HeaderContentType, "application/x-msgpack")
+	res, err = DefaultClientDo(req)
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal(`mp:{"id":"admin","pass":"password"}`, PickRes(res.Text()).(string))
+
+	for accept, expected := range map[string]string{
+		"":                      `{"id":"admin"}`,
+		MIMEApplicationJSON:     `{"id":"admin"}`,
+		MIMEApplicationMsgPack:  `mp:{"id":"admin"}`,
+		"application/x-msgpack": `mp:{"id":"admin"}`,
+		"text/html, */*;q=0.1":  `{"id":"admin"}`,
+	} {
+		req, _ := NewRequst("GET", host+"/send")
+		req.Header.Set(HeaderAccept, accept)
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(expected, PickRes(res.Text()).(string), accept)
+	}
+}
+
 func TestGearContextSend(t *testing.T) {
 	t.Run("should panic when sender not registered", func(t *testing.T) {
 		assert := assert.New(t)
@@ -1476,17 +1925,138 @@ func TestGearContextSend(t *testing.T) {
 	})
 }
 
+func TestGearContextResponseTransformer(t *testing.T) {
+	assert := assert.New(t)
+
+	app := New()
+	assert.Panics(func() {
+		app.Set(SetResponseTransformer, func(data any) any { return data })
+	})
+	app.Set(SetSender, &SenderTest{})
+	app.Set(SetResponseTransformer, func(ctx *Context, data any) any {
+		return map[string]any{"data": data}
+	})
+	app.Use(func(ctx *Context) error {
+		switch ctx.Path {
+		case "/json":
+			return ctx.JSON(http.StatusOK, []int{1, 2})
+		case "/jsonp":
+			return ctx.JSONP(http.StatusOK, "cb", 1)
+		case "/send":
+			return ctx.Send(http.StatusOK, []string{"a"})
+		case "/send-text":
+			return ctx.Send(http.StatusOK, "hello")
+		case "/send-error":
+			return ctx.Send(http.StatusOK, ErrForbidden.WithMsg("no access"))
+		default:
+			return ctx.JSONBlob(http.StatusOK, []byte(`[]`))
+		}
+	})
+
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	res, err := RequestBy("GET", host+"/json")
+	assert.Nil(err)
+	assert.Equal(`{"data":[1,2]}`, PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host+"/jsonp")
+	assert.Nil(err)
+	assert.Equal(`/**/ typeof cb === "function" && cb({"data":1});`, PickRes(res.Text()).(string))
+
+	// transform once for Sender that calls ctx.JSON
+	res, err = RequestBy("GET", host+"/send")
+	assert.Nil(err)
+	assert.Equal(`{"data":["a"]}`, PickRes(res.Text()).(string))
+
+	// error, []byte and string are not transformed
+	res, err = RequestBy("GET", host+"/send-text")
+	assert.Nil(err)
+	assert.Equal(`hello`, PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host+"/send-error")
+	assert.Nil(err)
+	assert.Equal(403, res.StatusCode)
+	assert.Equal(`{"error":"Forbidden","message":"no access"}`, PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host)
+	assert.Nil(err)
+	assert.Equal(`[]`, PickRes(res.Text()).(string))
+}
+
 type RenderTest struct {
-	tpl *template.Template
+	tpl  *template.Template
+	text *texttemplate.Template // for the non-HTML outputs
 }
 
 func (t *RenderTest) Render(ctx *Context, w io.Writer, name string, data any) (err error) {
+	if t.text != nil && !strings.HasPrefix(ctx.Res.Get(HeaderContentType), MIMETextHTML) {
+		return t.text.ExecuteTemplate(w, name, data)
+	}
 	if err = t.tpl.ExecuteTemplate(w, name, data); err != nil {
 		err = ErrNotFound.From(err)
 	}
 	return
 }
 
+type FuncsRenderTest struct {
+	funcs map[string]any
+}
+
+func (t *FuncsRenderTest) SetFuncs(funcs map[string]any) {
+	t.funcs = funcs
+}
+
+func (t *FuncsRenderTest) Render(ctx *Context, w io.Writer, name string, data any) error {
+	tpl, err := template.New(name).Funcs(t.funcs).Parse(`<a href="{{ route "user.show" .ID }}"><img src="{{ asset "avatar.png" }}"></a>`)
+	if err != nil {
+		return err
+	}
+	return tpl.Execute(w, data)
+}
+
+func TestGearTemplateFuncs(t *testing.T) {
+	assert := assert.New(t)
+
+	app := New()
+	assert.Panics(func() {
+		app.Set(SetAssetURL, "/assets")
+	})
+	renderer := &FuncsRenderTest{}
+	app.Set(SetRenderer, renderer)
+	assert.NotNil(renderer.funcs["route"])
+	assert.NotNil(renderer.funcs["asset"])
+
+	router := NewRouter()
+	router.Get("/users/:id", func(ctx *Context) error {
+		return ctx.Render(http.StatusOK, "user", map[string]string{"ID": ctx.Param("id")})
+	}).Name("user.show", "/users/:id")
+	app.UseHandler(router)
+
+	_, err := app.URL("none")
+	assert.NotNil(err)
+	url, err := app.URL("user.show", 1)
+	assert.Nil(err)
+	assert.Equal("/users/1", url)
+
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	res, err := RequestBy("GET", host+"/users/123")
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal(`<a href="/users/123"><img src="avatar.png"></a>`, PickRes(res.Text()).(string))
+
+	app.Set(SetAssetURL, func(name string) string {
+		return "/assets/" + name
+	})
+	res, err = RequestBy("GET", host+"/users/123")
+	assert.Nil(err)
+	assert.Equal(`<a href="/users/123"><img src="/assets/avatar.png"></a>`, PickRes(res.Text()).(string))
+}
+
 func TestGearContextRender(t *testing.T) {
 	t.Run("should panic when renderer not registered", func(t *testing.T) {
 		assert := assert.New(t)
@@ -1547,6 +2117,38 @@ func TestGearContextRender(t *testing.T) {
 		assert.Equal(404, res.StatusCode)
 		assert.Equal(`{"error":"NotFound","message":"html/template: \"helloA\" is undefined"}`, PickRes(res.Text()).(string))
 	})
+
+	t.Run("RenderAs", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Set(SetRenderer, &RenderTest{
+			tpl:  template.Must(template.New("hello").Parse("Hello, {{.}}!")),
+			text: texttemplate.Must(texttemplate.New("hello").Parse("Hello, {{.}}!")),
+		})
+		app.Use(func(ctx *Context) error {
+			if ctx.Path == "/html" {
+				return ctx.Render(http.StatusOK, "hello", "<Gear>")
+			}
+			return ctx.RenderAs(http.StatusCreated, MIMETextPlainCharsetUTF8, "hello", "<Gear>")
+		})
+
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("GET", host+"/text")
+		assert.Nil(err)
+		assert.Equal(201, res.StatusCode)
+		assert.Equal(MIMETextPlainCharsetUTF8, res.Header.Get(HeaderContentType))
+		assert.Equal("Hello, <Gear>!", PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host+"/html")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(MIMETextHTMLCharsetUTF8, res.Header.Get(HeaderContentType))
+		assert.Equal("Hello, &lt;Gear&gt;!", PickRes(res.Text()).(string))
+	})
 }
 
 func TestGearContextStream(t *testing.T) {
@@ -1605,6 +2207,64 @@ func TestGearContextStream(t *testing.T) {
 	})
 }
 
+func TestGearContextStreamSeeker(t *testing.T) {
+	assert := assert.New(t)
+
+	modtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	app := New()
+	app.Use(func(ctx *Context) error {
+		code := http.StatusOK
+		if ctx.Path == "/created" {
+			code = http.StatusCreated
+		}
+		return ctx.StreamSeeker(code, MIMETextPlainCharsetUTF8, strings.NewReader("Hello, World!"), modtime)
+	})
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	res, err := RequestBy("GET", host)
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal("bytes", res.Header.Get(HeaderAcceptRanges))
+	assert.Equal(MIMETextPlainCharsetUTF8, res.Header.Get(HeaderContentType))
+	assert.Equal("Hello, World!", PickRes(res.Text()).(string))
+
+	req, _ := http.NewRequest("GET", host, nil)
+	req.Header.Set(HeaderRange, "bytes=7-11")
+	res, err = DefaultClientDo(req)
+	assert.Nil(err)
+	assert.Equal(206, res.StatusCode)
+	assert.Equal("bytes 7-11/13", res.Header.Get(HeaderContentRange))
+	assert.Equal("World", PickRes(res.Text()).(string))
+
+	req.Header.Set(HeaderIfRange, modtime.Add(-time.Hour).Format(http.TimeFormat))
+	res, err = DefaultClientDo(req)
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal("Hello, World!", PickRes(res.Text()).(string))
+
+	req.Header.Set(HeaderIfRange, modtime.Format(http.TimeFormat))
+	res, err = DefaultClientDo(req)
+	assert.Nil(err)
+	assert.Equal(206, res.StatusCode)
+	res.Body.Close()
+
+	req.Header.Del(HeaderIfRange)
+	req.Header.Set(HeaderRange, "bytes=20-")
+	res, err = DefaultClientDo(req)
+	assert.Nil(err)
+	assert.Equal(416, res.StatusCode)
+	res.Body.Close()
+
+	req, _ = http.NewRequest("GET", host+"/created", nil)
+	req.Header.Set(HeaderRange, "bytes=7-11")
+	res, err = DefaultClientDo(req)
+	assert.Nil(err)
+	assert.Equal(201, res.StatusCode)
+	assert.Equal("Hello, World!", PickRes(res.Text()).(string))
+}
+
 func TestGearContextAttachment(t *testing.T) {
 	data, err := os.ReadFile("testdata/README.md")
 	if err != nil {
@@ -1659,6 +2319,43 @@ func TestGearContextAttachment(t *testing.T) {
 	})
 }
 
+func TestGearContextURLFor(t *testing.T) {
+	assert := assert.New(t)
+
+	app := New()
+	router := NewRouter(RouterOptions{Root: "/api"})
+	router.Get("/users/:id", func(ctx *Context) error {
+		return ctx.HTML(200, ctx.Param("id")+" "+ctx.Query("tab"))
+	}).Name("user.show")
+	router.Post("/users", func(ctx *Context) error {
+		target, err := ctx.URLFor("user.show", map[string]string{"id": "123"}, url.Values{"tab": {"profile"}})
+		if err != nil {
+			return err
+		}
+		ctx.Status(303)
+		return ctx.Redirect(target)
+	})
+	router.Get("/none", func(ctx *Context) error {
+		_, err := ctx.URLFor("none", nil, nil)
+		return err
+	})
+	app.UseHandler(router)
+
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	res, err := RequestBy("POST", host+"/api/users")
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal("123 profile", PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host+"/api/none")
+	assert.Nil(err)
+	assert.Equal(500, res.StatusCode)
+	res.Body.Close()
+}
+
 func TestGearContextRedirect(t *testing.T) {
 	t.Run("should work", func(t *testing.T) {
 		assert := assert.New(t)
@@ -2135,3 +2832,126 @@ func TestGearContextOnEnd(t *testing.T) {
 		assert.Equal(204, res.StatusCode)
 	})
 }
+
+func TestGearSnapshotCtx(t *testing.T) {
+	assert := assert.New(t)
+
+	snapshots := make(chan map[string]any, 1)
+	app := New()
+	router := NewRouter()
+	router.Get("/users/:id", func(ctx *Context) error {
+		snapshot := SnapshotCtx(ctx)
+		assert.Nil(snapshot["status"])
+		ctx.OnEnd(func() {
+			snapshot["status"] = ctx.Res.Status()
+			snapshots <- snapshot
+		})
+		return ctx.HTML(200, "OK")
+	})
+	app.UseHandler(router)
+	srv := app.Start()
+	defer srv.Close()
+
+	req, _ := NewRequst("GET", "http://"+srv.Addr().String()+"/users/123?a=b")
+	req.Header.Set(HeaderXRequestID, "abc")
+	req.Header.Set(HeaderUserAgent, "gear-test")
+	res, err := DefaultClientDo(req)
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+
+	snapshot := <-snapshots
+	assert.Equal("127.0.0.1", snapshot["ip"])
+	assert.Equal("http", snapshot["scheme"])
+	assert.Equal("HTTP/1.1", snapshot["proto"])
+	assert.Equal("GET", snapshot["method"])
+	assert.Equal("/users/123?a=b", snapshot["uri"])
+	assert.Equal("gear-test", snapshot["userAgent"])
+	assert.Equal("abc", snapshot["xRequestId"])
+	assert.Equal("GET /users/:id", snapshot["router"])
+	assert.Equal(map[string]string{"id": "123"}, snapshot["params"])
+	assert.Equal(200, snapshot["status"])
+	assert.False(snapshot["start"].(time.Time).IsZero())
+
+	ctx := CtxTest(app, "GET", "http://example.com/foo", nil)
+	snapshot = SnapshotCtx(ctx)
+	assert.Nil(snapshot["router"])
+	assert.Nil(snapshot["params"])
+	assert.Nil(snapshot["xRequestId"])
+}
+
+func TestGearContextSetTimeout(t *testing.T) {
+	t.Run("should replace the deadline", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Set(SetTimeout, 20*time.Millisecond)
+		ctx := CtxTest(app, "GET", "http://example.com/", nil)
+		before, cancel := ctx.WithCancel()
+		defer cancel()
+
+		d1, ok := ctx.Deadline()
+		assert.True(ok)
+		assert.Equal(0, len(ctx.timeouts), "should not notify without replacing")
+		ctx.SetTimeout(time.Second)
+		d2, ok := ctx.Deadline()
+		assert.True(ok)
+		assert.True(d2.Sub(d1) > 900*time.Millisecond)
+		assert.Equal(0, len(ctx.Res.endHooks), "should not release the timers by end hooks")
+		d3, _ := ctx.Req.Context().Deadline()
+		assert.Equal(d2, d3)
+		child, cancel := ctx.WithCancel()
+		defer cancel()
+
+		<-before.Done() // should keep the previous deadline
+		assert.Equal(context.DeadlineExceeded, before.Err())
+		assert.Nil(ctx.Err())
+		assert.Nil(child.Err())
+
+		ctx.SetTimeout(10 * time.Millisecond)
+		<-child.Done()
+		assert.Equal(context.DeadlineExceeded, ctx.Err())
+		assert.Equal(context.DeadlineExceeded, child.Err())
+
+		ctx.SetTimeout(time.Second)
+		assert.Equal(context.DeadlineExceeded, ctx.Err(), "should do nothing after done")
+	})
+
+	t.Run("should remove the deadline", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Set(SetTimeout, 10*time.Millisecond)
+		ctx := CtxTest(app, "GET", "http://example.com/", nil)
+		ctx.SetTimeout(-1)
+		_, ok := ctx.Deadline()
+		assert.False(ok)
+		time.Sleep(30 * time.Millisecond)
+		assert.Nil(ctx.Err())
+
+		ctx.Cancel()
+		assert.Equal(context.Canceled, ctx.Err())
+	})
+
+	t.Run("should release the timers when the request ends", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var ctxs []context.Context
+		app := New()
+		app.Set(SetTimeout, time.Second)
+		app.Use(func(ctx *Context) error {
+			ctxs = append(ctxs, ctx.Context())
+			ctx.SetTimeout(2 * time.Second)
+			ctxs = append(ctxs, ctx.Context())
+			return ctx.End(204)
+		})
+		srv := app.Start()
+		defer srv.Close()
+
+		res, err := RequestBy("GET", "http://"+srv.Addr().String())
+		assert.Nil(err)
+		assert.Equal(204, res.StatusCode)
+		for _, c := range ctxs {
+			assert.Equal(context.Canceled, c.Err())
+		}
+	})
+}
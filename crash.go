@@ -0,0 +1,171 @@
+package gear
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// recentRequestsSize is the number of the recent requests kept for the crash reports.
+const recentRequestsSize = 64
+
+// osExit is os.Exit, it is replaced in tests.
+var osExit = os.Exit
+
+// CrashReport is the crash report written by app.RecoverCrash and app.Fatal, see SetCrashReportDir setting.
+type CrashReport struct {
+	Time       time.Time         `json:"time"`
+	Reason     string            `json:"reason"`
+	Env        string            `json:"env"`
+	Hostname   string            `json:"hostname,omitempty"`
+	PID        int               `json:"pid"`
+	Goroutines int               `json:"goroutines"`
+	Stack      string            `json:"stack"` // the stacks of all goroutines
+	Requests   []RecentRequest   `json:"requests"`
+	Build      *debug.BuildInfo  `json:"build,omitempty"`
+	Stats      AppStats          `json:"stats"`
+	Degraded   *Degradation      `json:"degraded,omitempty"`
+	Extra      map[string]string `json:"extra,omitempty"`
+}
+
+// RecentRequest is a finished request kept for the crash reports, the recent requests are in the finishing order.
+type RecentRequest struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"` // the URL path without query, it may contain secrets
+	Status    int       `json:"status"`
+	Duration  string    `json:"duration"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// crashReporter writes the crash reports to the dir, and keeps the recent requests in a ring buffer.
+type crashReporter struct {
+	dir  string
+	mu   sync.Mutex
+	next int
+	ring []RecentRequest
+}
+
+func newCrashReporter(dir string) *crashReporter {
+	return &crashReporter{dir: dir, ring: make([]RecentRequest, 0, recentRequestsSize)}
+}
+
+func (c *crashReporter) record(ctx *Context, start time.Time) {
+	r := RecentRequest{
+		Time:      start.UTC(),
+		Method:    ctx.Method,
+		Path:      ctx.Req.URL.Path,
+		Status:    ctx.Res.Status(),
+		Duration:  time.Since(start).String(),
+		RequestID: ctx.requestID(),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.ring) < recentRequestsSize {
+		c.ring = append(c.ring, r)
+		return
+	}
+	c.ring[c.next] = r
+	c.next = (c.next + 1) % recentRequestsSize
+}
+
+func (c *crashReporter) recent() []RecentRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := make([]RecentRequest, 0, len(c.ring))
+	res = append(res, c.ring[c.next:]...)
+	return append(res, c.ring[:c.next]...)
+}
+
+// RecoverCrash recovers the panic, writes a crash report to the SetCrashReportDir setting and panics again,
+// so the process crashes as before with a report for postmortem. The panics in the request scope are recovered
+// and responded with 500 by the app, use it for the main goroutine and the background goroutines:
+//
+//	app := gear.New()
+//	app.Set(gear.SetCrashReportDir, "/var/log/myapp") // a volume of the container
+//	go func() {
+//		defer app.RecoverCrash()
+//		consumeQueue()
+//	}()
+//
+// It does nothing but panics again if the SetCrashReportDir setting is not set.
+func (app *App) RecoverCrash() {
+	if err := recover(); err != nil {
+		if app.crash != nil {
+			if _, e := app.WriteCrashReport(fmt.Sprintf("panic: %v", err)); e != nil {
+				app.Error(e)
+			}
+		}
+		panic(err)
+	}
+}
+
+// Fatal writes the error by app.Error and a crash report to the SetCrashReportDir setting if set,
+// then calls os.Exit(1).
+func (app *App) Fatal(err any) {
+	app.Error(err)
+	if app.crash != nil {
+		if _, e := app.WriteCrashReport(fmt.Sprintf("fatal: %v", err)); e != nil {
+			app.Error(e)
+		}
+	}
+	osExit(1)
+}
+
+// WriteCrashReport writes a CrashReport with the reason to the SetCrashReportDir setting, returns the file path.
+// The file is named like "crash-20240102T150405.000Z-1234.json" with the time and the process id.
+// The extra key/values can be added to the report, such as the version of the config.
+func (app *App) WriteCrashReport(reason string, extra ...map[string]string) (string, error) {
+	if app.crash == nil {
+		return "", Err.WithMsg("SetCrashReportDir setting is required by crash report")
+	}
+	report := &CrashReport{
+		Time:       time.Now().UTC(),
+		Reason:     reason,
+		Env:        app.Env(),
+		PID:        os.Getpid(),
+		Goroutines: runtime.NumGoroutine(),
+		Stack:      allStacks(),
+		Requests:   app.crash.recent(),
+		Stats:      app.Stats(),
+	}
+	report.Hostname, _ = os.Hostname()
+	report.Build, _ = debug.ReadBuildInfo()
+	if d := app.Degradation(); d.Degraded {
+		report.Degraded = &d
+	}
+	if len(extra) > 0 {
+		report.Extra = extra[0]
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("crash-%s-%d.json", report.Time.Format("20060102T150405.000Z"), report.PID)
+	file := filepath.Join(app.crash.dir, name)
+	if err = os.MkdirAll(app.crash.dir, 0755); err == nil {
+		err = os.WriteFile(file, data, 0644)
+	}
+	if err != nil {
+		return "", Err.WithMsgf("failed to write crash report: %v", err)
+	}
+	return file, nil
+}
+
+// allStacks returns the stacks of all goroutines, it is truncated at 64MB.
+func allStacks() string {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= 64<<20 {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,101 @@
+package gear
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearCrashReport(t *testing.T) {
+	readReport := func(t *testing.T, dir string) *CrashReport {
+		files, err := filepath.Glob(filepath.Join(dir, "crash-*.json"))
+		assert.Nil(t, err)
+		if !assert.Equal(t, 1, len(files)) {
+			t.FailNow()
+		}
+		data, err := os.ReadFile(files[0])
+		assert.Nil(t, err)
+		report := &CrashReport{}
+		assert.Nil(t, json.Unmarshal(data, report))
+		return report
+	}
+
+	t.Run("should validate the setting", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		assert.Panics(func() { app.Set(SetCrashReportDir, 1) })
+		assert.Panics(func() { app.Set(SetCrashReportDir, "") })
+		_, err := app.WriteCrashReport("test")
+		assert.NotNil(err)
+	})
+
+	t.Run("should write report on panic with the recent requests", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := filepath.Join(t.TempDir(), "crash")
+		app := New()
+		app.Set(SetCrashReportDir, dir)
+		app.Use(func(ctx *Context) error {
+			if ctx.Path == "/panic" {
+				panic("request panic") // recovered by the app, no crash report
+			}
+			return ctx.HTML(200, "OK")
+		})
+		for i := 0; i < recentRequestsSize+2; i++ {
+			app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/"+strconv.Itoa(i), nil))
+		}
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+		_, err := os.Stat(dir)
+		assert.True(os.IsNotExist(err))
+
+		assert.PanicsWithValue("background crash", func() {
+			defer app.RecoverCrash()
+			panic("background crash")
+		})
+
+		report := readReport(t, dir)
+		assert.Equal("panic: background crash", report.Reason)
+		assert.Equal(os.Getpid(), report.PID)
+		assert.Equal("development", report.Env)
+		assert.True(report.Goroutines > 0)
+		assert.Contains(report.Stack, "goroutine ")
+		assert.Contains(report.Stack, "TestGearCrashReport")
+		assert.Equal(recentRequestsSize, len(report.Requests))
+		assert.Equal("/3", report.Requests[0].Path)
+		assert.Equal(200, report.Requests[0].Status)
+		last := report.Requests[len(report.Requests)-1]
+		assert.Equal("/panic", last.Path)
+		assert.Equal(500, last.Status)
+		assert.NotNil(report.Build)
+	})
+
+	t.Run("should write report on Fatal", func(t *testing.T) {
+		assert := assert.New(t)
+
+		code := 0
+		osExit = func(c int) { code = c }
+		defer func() { osExit = os.Exit }()
+
+		dir := t.TempDir()
+		var buf strings.Builder
+		app := New()
+		app.Set(SetLogger, log.New(&buf, "", 0))
+		app.Set(SetCrashReportDir, dir)
+		app.Fatal(errors.New("db lost"))
+		assert.Equal(1, code)
+		assert.Contains(buf.String(), "db lost")
+
+		report := readReport(t, dir)
+		assert.Equal("fatal: db lost", report.Reason)
+		assert.Equal(0, len(report.Requests))
+	})
+}
@@ -0,0 +1,146 @@
+package gear
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const csrfSecretLen = 32
+
+// VerifyOrigin verifies the Origin (or the Referer if no Origin) request header of the unsafe requests,
+// it should be the request host or one of the allowed origins (such as "https://admin.example.com").
+// It returns ErrForbidden for an untrusted origin, or nil if both headers are absent, such as the requests
+// from non-browser clients. It is used by the csrf middleware, and can be used for custom auth flows:
+//
+//	router.Post("/login", func(ctx *gear.Context) error {
+//		if err := gear.VerifyOrigin(ctx, "https://accounts.example.com"); err != nil {
+//			return err
+//		}
+//		// ...
+//	})
+func VerifyOrigin(ctx *Context, allowed ...string) error {
+	origin := ctx.GetHeader(HeaderOrigin)
+	if origin == "" {
+		referer := ctx.GetHeader(HeaderReferer)
+		if referer == "" {
+			return nil
+		}
+		if u, err := url.Parse(referer); err == nil {
+			origin = u.Scheme + "://" + u.Host
+		}
+	}
+
+	if u, err := url.Parse(origin); err == nil && u.Host != "" {
+		if strings.EqualFold(u.Host, ctx.Host) {
+			return nil
+		}
+		for _, o := range allowed {
+			if strings.EqualFold(strings.TrimSuffix(o, "/"), u.Scheme+"://"+u.Host) {
+				return nil
+			}
+		}
+	}
+	return ErrForbidden.WithMsgf("untrusted origin: %s", origin)
+}
+
+// NewCSRFSecret returns a random secret to generate the CSRF tokens, it should be kept in the session
+// or an HttpOnly cookie of the client.
+func NewCSRFSecret() (string, error) {
+	key := make([]byte, csrfSecretLen)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key), nil
+}
+
+// NewCSRFToken returns a CSRF token of the secret. The token is masked randomly on every call to mitigate
+// the BREACH attack, all of them are valid. It returns "" if the secret is invalid.
+func NewCSRFToken(secret string) string {
+	key, err := base64.RawURLEncoding.DecodeString(secret)
+	if err != nil || len(key) != csrfSecretLen {
+		return ""
+	}
+	token := make([]byte, 2*len(key))
+	if _, err := rand.Read(token[:len(key)]); err != nil {
+		panic(err)
+	}
+	for i := range key {
+		token[len(key)+i] = token[i] ^ key[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// VerifyCSRFToken reports whether the token is generated from the secret by NewCSRFToken.
+func VerifyCSRFToken(token, secret string) bool {
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(buf) != 2*csrfSecretLen {
+		return false
+	}
+	key, err := base64.RawURLEncoding.DecodeString(secret)
+	if err != nil || len(key) != csrfSecretLen {
+		return false
+	}
+	for i := range key {
+		buf[i] ^= buf[csrfSecretLen+i]
+	}
+	return subtle.ConstantTimeCompare(buf[:csrfSecretLen], key) == 1
+}
+
+// SetCSRFCookie implements the double submit cookie pattern with the cookie template (Name is required).
+// It keeps the secret in the HttpOnly cookie (a new secret is set if the cookie is absent or invalid),
+// and returns a token to be sent back by a request header or form field, that is verified by VerifyCSRFCookie:
+//
+//	router.Get("/form", func(ctx *gear.Context) error {
+//		token, err := gear.SetCSRFCookie(ctx, http.Cookie{Name: "_csrf", Path: "/", Secure: true})
+//		if err != nil {
+//			return err
+//		}
+//		return ctx.HTML(200, `<form method="POST"><input type="hidden" name="_csrf" value="`+token+`"></form>`)
+//	})
+//
+//	router.Post("/form", func(ctx *gear.Context) error {
+//		if err := gear.VerifyCSRFCookie(ctx, "_csrf", ctx.Req.PostFormValue("_csrf")); err != nil {
+//			return err
+//		}
+//		// ...
+//	})
+func SetCSRFCookie(ctx *Context, cookie http.Cookie) (string, error) {
+	secret := csrfSecretOf(ctx, cookie.Name)
+	if secret == "" {
+		var err error
+		if secret, err = NewCSRFSecret(); err != nil {
+			return "", err
+		}
+		cookie.Value = secret
+		cookie.HttpOnly = true
+		if cookie.SameSite == 0 {
+			cookie.SameSite = http.SameSiteLaxMode
+		}
+		http.SetCookie(ctx.Res, &cookie)
+	}
+	return NewCSRFToken(secret), nil
+}
+
+// VerifyCSRFCookie verifies the token against the secret in the cookie set by SetCSRFCookie.
+// It returns ErrForbidden if the cookie is absent or the token is invalid.
+func VerifyCSRFCookie(ctx *Context, cookieName, token string) error {
+	if secret := csrfSecretOf(ctx, cookieName); secret == "" || !VerifyCSRFToken(token, secret) {
+		return ErrForbidden.WithMsg("invalid CSRF token")
+	}
+	return nil
+}
+
+func csrfSecretOf(ctx *Context, cookieName string) string {
+	c, err := ctx.Req.Cookie(cookieName)
+	if err != nil {
+		return ""
+	}
+	if key, err := base64.RawURLEncoding.DecodeString(c.Value); err != nil || len(key) != csrfSecretLen {
+		return "" // ignore invalid secret
+	}
+	return c.Value
+}
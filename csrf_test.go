@@ -0,0 +1,102 @@
+package gear
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearVerifyOrigin(t *testing.T) {
+	assert := assert.New(t)
+
+	verify := func(origin, referer string, allowed ...string) error {
+		ctx := CtxTest(New(), "POST", "http://example.com/login", nil)
+		if origin != "" {
+			ctx.Req.Header.Set(HeaderOrigin, origin)
+		}
+		if referer != "" {
+			ctx.Req.Header.Set(HeaderReferer, referer)
+		}
+		return VerifyOrigin(ctx, allowed...)
+	}
+
+	assert.Nil(verify("", ""))
+	assert.Nil(verify("http://example.com", ""))
+	assert.Nil(verify("https://EXAMPLE.com", ""))
+	assert.Nil(verify("", "https://example.com/form?a=b"))
+	assert.Nil(verify("https://admin.example.com", "", "https://admin.example.com/"))
+	assert.Nil(verify("", "https://admin.example.com/form", "https://Admin.example.com"))
+
+	assert.Equal("Forbidden: untrusted origin: https://evil.com", verify("https://evil.com", "").Error())
+	assert.Equal("Forbidden: untrusted origin: https://evil.com", verify("", "https://evil.com/form").Error())
+	assert.Equal("Forbidden: untrusted origin: null", verify("null", "https://example.com/form").Error())
+	assert.NotNil(verify("http://admin.example.com", "", "https://admin.example.com"))
+	assert.NotNil(verify("https://evil.com", "https://example.com/form", "https://admin.example.com"))
+}
+
+func TestGearCSRFToken(t *testing.T) {
+	assert := assert.New(t)
+
+	secret, err := NewCSRFSecret()
+	assert.Nil(err)
+	other, _ := NewCSRFSecret()
+	assert.NotEqual(secret, other)
+
+	token := NewCSRFToken(secret)
+	assert.NotEqual(token, NewCSRFToken(secret), "should be masked randomly")
+	assert.True(VerifyCSRFToken(token, secret))
+	assert.True(VerifyCSRFToken(NewCSRFToken(secret), secret))
+	assert.False(VerifyCSRFToken(token, other))
+	assert.False(VerifyCSRFToken(token[1:], secret))
+	assert.False(VerifyCSRFToken("", secret))
+	assert.False(VerifyCSRFToken(token, "invalid"))
+	assert.Equal("", NewCSRFToken("invalid"))
+}
+
+func TestGearCSRFCookie(t *testing.T) {
+	ctx := CtxTest(New(), "GET", "http://example.com/form", nil)
+	token, err := SetCSRFCookie(ctx, http.Cookie{Name: "_csrf", Path: "/", Secure: true})
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", token)
+	ctx.Res.WriteHeader(200)
+	cookies := CtxResult(ctx).Cookies()
+	assert.Len(t, cookies, 1)
+	cookie := cookies[0]
+	assert.Equal(t, "_csrf", cookie.Name)
+	assert.Equal(t, "/", cookie.Path)
+	assert.True(t, cookie.HttpOnly)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+	t.Run("should reuse the secret in cookie", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ctx := CtxTest(New(), "GET", "http://example.com/form", nil)
+		ctx.Req.AddCookie(cookie)
+		token2, err := SetCSRFCookie(ctx, http.Cookie{Name: "_csrf"})
+		assert.Nil(err)
+		assert.NotEqual(token, token2)
+		assert.True(VerifyCSRFToken(token2, cookie.Value))
+		ctx.Res.WriteHeader(200)
+		assert.Len(CtxResult(ctx).Cookies(), 0)
+	})
+
+	t.Run("should verify token with cookie", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ctx := CtxTest(New(), "POST", "http://example.com/form", nil)
+		ctx.Req.AddCookie(cookie)
+		assert.Nil(VerifyCSRFCookie(ctx, "_csrf", token))
+		assert.Equal("Forbidden: invalid CSRF token", VerifyCSRFCookie(ctx, "_csrf", "").Error())
+		assert.Equal("Forbidden: invalid CSRF token", VerifyCSRFCookie(ctx, "_other", token).Error())
+
+		ctx = CtxTest(New(), "POST", "http://example.com/form", nil)
+		ctx.Req.AddCookie(&http.Cookie{Name: "_csrf", Value: "invalid"})
+		assert.Equal("Forbidden: invalid CSRF token", VerifyCSRFCookie(ctx, "_csrf", token).Error())
+		token3, _ := SetCSRFCookie(ctx, http.Cookie{Name: "_csrf"})
+		assert.NotEqual("", token3)
+		ctx.Res.WriteHeader(200)
+		assert.Len(CtxResult(ctx).Cookies(), 1, "should replace the invalid secret")
+	})
+}
@@ -0,0 +1,103 @@
+package gear
+
+import (
+	"net/http"
+	"time"
+)
+
+// Degradation is the degradation mode state of an app, see app.SetDegraded.
+type Degradation struct {
+	Degraded bool       `json:"degraded"`
+	Reason   string     `json:"reason,omitempty"`
+	Since    *time.Time `json:"since,omitempty"`
+}
+
+// Validate implemented BodyTemplate interface.
+func (d *Degradation) Validate() error {
+	if !d.Degraded && d.Reason != "" {
+		return ErrBadRequest.WithMsg("reason should be empty when not degraded")
+	}
+	return nil
+}
+
+// SetDegraded switches the degradation mode of the app. In degradation mode, handlers and middlewares
+// can check ctx.Degraded and serve cached or partial responses to shed load. It is usually switched by
+// a load-shedding or SLO burn-rate trigger, or by the admin endpoint app.ServeDegradation.
+//
+//	app.UseHandler(slo.New(slo.Options{
+//		OnExhausted: func(r *slo.RouteReport) {
+//			app.SetDegraded(true, "error budget of "+r.Pattern+" exhausted")
+//		},
+//	}))
+func (app *App) SetDegraded(degraded bool, reason ...string) *App {
+	if !degraded {
+		app.degraded.Store(nil)
+		return app
+	}
+
+	d := &Degradation{Degraded: true}
+	if len(reason) > 0 {
+		d.Reason = reason[0]
+	}
+	if prev := app.degraded.Load(); prev != nil {
+		d.Since = prev.Since // keep the start time when updating reason.
+	} else {
+		now := time.Now().UTC()
+		d.Since = &now
+	}
+	app.degraded.Store(d)
+	return app
+}
+
+// Degradation returns the current degradation mode state of the app.
+func (app *App) Degradation() Degradation {
+	if d := app.degraded.Load(); d != nil {
+		return *d
+	}
+	return Degradation{}
+}
+
+// ServeDegradation is a middleware serving as the admin endpoint of the app's degradation mode,
+// it responds the current Degradation as JSON.
+// PUT or POST request with a Degradation JSON body switches the degradation mode, and DELETE request
+// switches it off. It should be mounted to a protected router, such as an admin app:
+//
+//	admin := app.Clone()
+//	adminRouter := gear.NewRouter()
+//	adminRouter.Get("/degradation", app.ServeDegradation) // switches the public app
+//	adminRouter.Put("/degradation", app.ServeDegradation)
+//	adminRouter.Delete("/degradation", app.ServeDegradation)
+//	admin.UseHandler(adminRouter)
+//	go admin.Listen("127.0.0.1:3001")
+//	app.Listen(":3000")
+func (app *App) ServeDegradation(ctx *Context) error {
+	switch ctx.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut, http.MethodPost:
+		body := new(Degradation)
+		if err := ctx.ParseBody(body); err != nil {
+			return err
+		}
+		app.SetDegraded(body.Degraded, body.Reason)
+	case http.MethodDelete:
+		app.SetDegraded(false)
+	default:
+		ctx.SetHeader(HeaderAllow, "GET, HEAD, PUT, POST, DELETE")
+		return ErrMethodNotAllowed.WithMsgf(`"%s" is not allowed in "%s"`, ctx.Method, ctx.Path)
+	}
+
+	ctx.SetHeader(HeaderCacheControl, "no-store")
+	return ctx.JSON(http.StatusOK, app.Degradation())
+}
+
+// Degraded returns true if the app is in degradation mode, see app.SetDegraded.
+//
+//	func GetFeed(ctx *gear.Context) error {
+//		if ctx.Degraded() {
+//			return ctx.JSON(http.StatusOK, cachedFeed()) // skip the expensive personalization
+//		}
+//		return ctx.JSON(http.StatusOK, buildFeed(ctx))
+//	}
+func (ctx *Context) Degraded() bool {
+	return ctx.app.degraded.Load() != nil
+}
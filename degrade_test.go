@@ -0,0 +1,104 @@
+package gear
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearDegradation(t *testing.T) {
+	t.Run("should switch degradation mode", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		assert.Equal(Degradation{}, app.Degradation())
+
+		app.SetDegraded(true)
+		d := app.Degradation()
+		assert.True(d.Degraded)
+		assert.Equal("", d.Reason)
+		assert.NotNil(d.Since)
+
+		app.SetDegraded(true, "overload")
+		d2 := app.Degradation()
+		assert.Equal("overload", d2.Reason)
+		assert.Equal(d.Since, d2.Since)
+
+		assert.Equal(Degradation{}, app.Clone().Degradation())
+		app.SetDegraded(false, "ignored")
+		assert.Equal(Degradation{}, app.Degradation())
+	})
+
+	t.Run("should serve degradation endpoint", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Use(func(ctx *Context) error {
+			if ctx.Degraded() {
+				return ctx.HTML(200, "cached")
+			}
+			return ctx.HTML(200, "fresh")
+		})
+		admin := app.Clone() // clone before the app starts serving
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		router := NewRouter()
+		router.Get("/degradation", app.ServeDegradation)
+		router.Put("/degradation", app.ServeDegradation)
+		router.Delete("/degradation", app.ServeDegradation)
+		router.Patch("/degradation", app.ServeDegradation)
+		admin.UseHandler(router)
+		adminSrv := admin.Start()
+		defer adminSrv.Close()
+		adminHost := "http://" + adminSrv.Addr().String() + "/degradation"
+
+		res, err := RequestBy("GET", adminHost)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("no-store", res.Header.Get(HeaderCacheControl))
+		assert.Equal(`{"degraded":false}`, PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host)
+		assert.Nil(err)
+		assert.Equal("fresh", PickRes(res.Text()).(string))
+
+		req, _ := http.NewRequest("PUT", adminHost, strings.NewReader(`{"degraded":true,"reason":"load shedding"}`))
+		req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+		res, err = DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.True(strings.HasPrefix(PickRes(res.Text()).(string), `{"degraded":true,"reason":"load shedding","since":"`))
+		assert.Equal("load shedding", app.Degradation().Reason)
+		assert.False(admin.Degradation().Degraded)
+
+		res, err = RequestBy("GET", host)
+		assert.Nil(err)
+		assert.Equal("cached", PickRes(res.Text()).(string))
+
+		req, _ = http.NewRequest("PUT", adminHost, strings.NewReader(`{"degraded":false,"reason":"x"}`))
+		req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+		res, err = DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(400, res.StatusCode)
+		res.Body.Close()
+		assert.True(app.Degradation().Degraded)
+
+		res, err = RequestBy("PATCH", adminHost)
+		assert.Nil(err)
+		assert.Equal(405, res.StatusCode)
+		assert.Equal("GET, HEAD, PUT, POST, DELETE", res.Header.Get(HeaderAllow))
+		res.Body.Close()
+
+		res, err = RequestBy("DELETE", adminHost)
+		assert.Nil(err)
+		assert.Equal(`{"degraded":false}`, PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host)
+		assert.Nil(err)
+		assert.Equal("fresh", PickRes(res.Text()).(string))
+	})
+}
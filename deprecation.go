@@ -0,0 +1,49 @@
+package gear
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// Deprecation is a use of the deprecated API, it is reported by SetOnDeprecated hook.
+type Deprecation struct {
+	API     string // the deprecated API, such as "ctx.Protocol"
+	Message string // the change in v2 and the replacement
+	Caller  string // the call site, "file:line"
+	Count   uint64 // the number of uses at the call site, including this one
+}
+
+// deprecations counts the uses of the deprecated APIs by call site, see SetOnDeprecated setting.
+type deprecations struct {
+	hook   func(Deprecation)
+	mu     sync.Mutex
+	counts map[string]uint64 // keyed by the API and call site
+}
+
+func newDeprecations(hook func(Deprecation)) *deprecations {
+	return &deprecations{hook: hook, counts: make(map[string]uint64)}
+}
+
+// deprecated reports the use of the deprecated API by the caller of the ctx method.
+// It logs a warning for the first use at a call site, and calls the hook for every use.
+func (ctx *Context) deprecated(api, msg string) {
+	d := ctx.app.deprecated
+	if d == nil {
+		return
+	}
+	caller := "unknown"
+	if _, file, line, ok := runtime.Caller(2); ok {
+		caller = file + ":" + strconv.Itoa(line)
+	}
+
+	d.mu.Lock()
+	d.counts[api+" "+caller]++
+	n := d.counts[api+" "+caller]
+	d.mu.Unlock()
+	if n == 1 {
+		ctx.app.warn(fmt.Sprintf("deprecated %s used at %s: %s", api, caller, msg))
+	}
+	d.hook(Deprecation{API: api, Message: msg, Caller: caller, Count: n})
+}
@@ -0,0 +1,71 @@
+package gear
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearDeprecation(t *testing.T) {
+	t.Run("should not report without SetOnDeprecated", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf bytes.Buffer
+		app := New()
+		app.Set(SetLogger, log.New(&buf, "", 0))
+		ctx := CtxTest(app, "GET", "http://example.com/foo", nil)
+		assert.Equal("http", ctx.Protocol())
+		assert.Equal("", buf.String())
+	})
+
+	t.Run("should count uses by call site and warn once", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf bytes.Buffer
+		var uses []Deprecation
+		app := New()
+		assert.Panics(func() { app.Set(SetOnDeprecated, func(d *Deprecation) {}) })
+		assert.Panics(func() { app.Set(SetOnDeprecated, (func(d Deprecation))(nil)) })
+		app.Set(SetLogger, log.New(&buf, "", 0))
+		app.Set(SetOnDeprecated, func(d Deprecation) {
+			uses = append(uses, d)
+		})
+
+		ctx := CtxTest(app, "GET", "http://example.com/foo", nil)
+		ctx.Req.Header.Set(HeaderXForwardedProto, "https")
+		for i := 0; i < 2; i++ {
+			assert.Equal("http", ctx.Protocol())
+		}
+		assert.Equal("https", ctx.Protocol(true))
+		assert.Equal("https", ctx.Scheme(true))
+		assert.Equal("http", ctx.Scheme())
+		ctx.IP(false)
+		ctx.IP()
+
+		assert.Equal(5, len(uses))
+		assert.Equal("ctx.Protocol", uses[0].API)
+		assert.Equal("it will be changed in v2, use ctx.Scheme instead", uses[0].Message)
+		assert.True(strings.Contains(uses[0].Caller, "deprecation_test.go:"))
+		assert.Equal(uint64(1), uses[0].Count)
+		assert.Equal(uses[0].Caller, uses[1].Caller)
+		assert.Equal(uint64(2), uses[1].Count)
+		assert.NotEqual(uses[0].Caller, uses[2].Caller)
+		assert.Equal(uint64(1), uses[2].Count)
+		assert.Equal("ctx.Scheme(trustedProxy)", uses[3].API)
+		assert.Equal("ctx.IP(trustedProxy)", uses[4].API)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Equal(4, len(lines))
+		assert.True(strings.Contains(lines[0], "Z] WARN deprecated ctx.Protocol used at "))
+		assert.True(strings.HasSuffix(lines[3], ": the trustedProxy argument will be removed in v2, use SetTrustedProxy setting instead"))
+
+		c := app.Clone()
+		ctx = CtxTest(c, "GET", "http://example.com/foo", nil)
+		ctx.Protocol()
+		assert.Equal(6, len(uses))
+		assert.Equal(uint64(1), uses[5].Count, "counted independently by the clone")
+	})
+}
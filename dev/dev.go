@@ -0,0 +1,381 @@
+// Package dev is the live-reload development runner of Gear apps. It watches the source, template and static files,
+// rebuilds and restarts the app with the listener handed off (see app.ListenGraceful), so the requests are not
+// refused during restarts, and reloads the browser pages by the script injected by the Reload middleware.
+//
+// The app should serve with app.ListenGraceful to inherit the listener from the runner, and use the Reload
+// middleware, which does nothing if the app is not started by the runner:
+//
+//	func main() {
+//		app := gear.New()
+//		app.Use(dev.Reload())
+//		app.UseHandler(router)
+//		app.Error(app.ListenGraceful(gear.ContextWithSignal(context.Background()), ":3000"))
+//	}
+//
+// Then run it with the gear CLI in the project directory:
+//
+//	gear dev -addr :3000
+package dev
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teambition/gear"
+)
+
+// EnvReloadPort is the environment variable of the reload events server port, it is set by the runner
+// for the app, and enables the Reload middleware.
+const EnvReloadPort = "GEAR_DEV_RELOAD_PORT"
+
+// envListenFD is the environment variable of the inherited listener, see gear.ListenGraceful.
+const envListenFD = "GEAR_LISTEN_FD"
+
+// Options is the options of the dev runner.
+type Options struct {
+	// Addr is the address of the app to listen on, the listener is handed off to the app processes.
+	// Optional. Default to ":3000".
+	Addr string
+	// Dir is the directory of the project (the main package) to build and watch.
+	// Optional. Default to ".".
+	Dir string
+	// BuildArgs is the extra arguments of "go build", such as []string{"-tags", "dev"}.
+	// Optional. Default to nil.
+	BuildArgs []string
+	// Args is the arguments of the app.
+	// Optional. Default to nil.
+	Args []string
+	// RestartExts is the extensions of the files that the app should be rebuilt and restarted when changed.
+	// Optional. Default to []string{".go", ".mod", ".sum"}.
+	RestartExts []string
+	// ReloadExts is the extensions of the files that the browser pages should be reloaded only when changed,
+	// such as the templates and static files loaded from disk when serving.
+	// Optional. Default to []string{".html", ".tmpl", ".css", ".js"}.
+	ReloadExts []string
+	// Interval is the interval to poll the file changes.
+	// Optional. Default to 500 milliseconds.
+	Interval time.Duration
+	// ReloadAddr is the address of the reload events server, the injected script connects to it.
+	// Optional. Default to ":35729".
+	ReloadAddr string
+	// Stdout and Stderr are the outputs of the runner, "go build" and the app.
+	// Optional. Default to os.Stdout and os.Stderr.
+	Stdout, Stderr io.Writer
+}
+
+// Run runs the app in the project directory for development until the ctx is done: it builds and starts the app,
+// restarts it when the RestartExts files changed, and reloads the browser pages when the app restarted or
+// the ReloadExts files changed. The build errors are printed, and the previous app keeps serving.
+//
+//	dev.Run(gear.ContextWithSignal(context.Background()), dev.Options{Addr: ":3000"})
+func Run(ctx context.Context, opts Options) error {
+	r, err := newRunner(opts)
+	if err != nil {
+		return err
+	}
+	defer r.close()
+
+	events := gear.New()
+	events.Use(r.serveEvents)
+	el, err := net.Listen("tcp", r.opts.ReloadAddr)
+	if err != nil {
+		return err
+	}
+	go events.ServeWithContext(ctx, el)
+	defer events.Close()
+	r.reloadPort = strconv.Itoa(el.Addr().(*net.TCPAddr).Port)
+
+	r.logf("serving on %s, reload events on %s", r.listener.Addr(), el.Addr())
+	if err = r.build(); err == nil {
+		r.restart()
+	}
+	snapshot := r.scan()
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		next := r.scan()
+		restart, reload := r.changed(snapshot, next)
+		snapshot = next
+		switch {
+		case restart:
+			r.logf("files changed, rebuilding...")
+			if r.build() == nil {
+				r.restart()
+				r.broadcast()
+			}
+		case reload:
+			r.logf("files changed, reloading...")
+			r.broadcast()
+		}
+	}
+}
+
+type runner struct {
+	opts       Options
+	listener   net.Listener
+	file       *os.File // the listener file handed off to the app
+	bin        string
+	cmd        *exec.Cmd
+	exited     chan struct{}
+	reloadPort string
+
+	mu      sync.Mutex
+	clients map[chan struct{}]struct{}
+}
+
+func newRunner(opts Options) (*runner, error) {
+	if opts.Addr == "" {
+		opts.Addr = ":3000"
+	}
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	if opts.RestartExts == nil {
+		opts.RestartExts = []string{".go", ".mod", ".sum"}
+	}
+	if opts.ReloadExts == nil {
+		opts.ReloadExts = []string{".html", ".tmpl", ".css", ".js"}
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 500 * time.Millisecond
+	}
+	if opts.ReloadAddr == "" {
+		opts.ReloadAddr = ":35729"
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
+
+	l, err := net.Listen("tcp", opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp("", "gear-dev-*")
+	if err != nil {
+		f.Close()
+		l.Close()
+		return nil, err
+	}
+	bin := filepath.Join(tmp, "app")
+	if runtime.GOOS == "windows" {
+		bin += ".exe"
+	}
+	return &runner{opts: opts, listener: l, file: f, bin: bin, clients: make(map[chan struct{}]struct{})}, nil
+}
+
+func (r *runner) logf(format string, args ...any) {
+	fmt.Fprintf(r.opts.Stderr, "[gear dev] "+format+"\n", args...)
+}
+
+func (r *runner) build() error {
+	args := append([]string{"build", "-o", r.bin}, r.opts.BuildArgs...)
+	cmd := exec.Command("go", append(args, ".")...)
+	cmd.Dir = r.opts.Dir
+	cmd.Stdout, cmd.Stderr = r.opts.Stdout, r.opts.Stderr
+	if err := cmd.Run(); err != nil {
+		r.logf("build failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// restart starts the new app process before stopping the previous one, they share the listener,
+// so the new connections are always accepted.
+func (r *runner) restart() {
+	cmd := exec.Command(r.bin, r.opts.Args...)
+	cmd.Dir = r.opts.Dir
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, r.opts.Stdout, r.opts.Stderr
+	cmd.ExtraFiles = []*os.File{r.file}
+	cmd.Env = append(os.Environ(), envListenFD+"=3", EnvReloadPort+"="+r.reloadPort)
+	if err := cmd.Start(); err != nil {
+		r.logf("start failed: %v", err)
+		return
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	r.stop()
+	r.cmd, r.exited = cmd, exited
+}
+
+// stop stops the app process gracefully, or kills it after 5 seconds.
+func (r *runner) stop() {
+	if r.cmd == nil {
+		return
+	}
+	if err := r.cmd.Process.Signal(os.Interrupt); err != nil {
+		r.cmd.Process.Kill() // os.Interrupt is not supported on windows
+	}
+	select {
+	case <-r.exited:
+	case <-time.After(5 * time.Second):
+		r.cmd.Process.Kill()
+		<-r.exited
+	}
+	r.cmd = nil
+}
+
+func (r *runner) close() {
+	r.stop()
+	r.file.Close()
+	r.listener.Close()
+	os.RemoveAll(filepath.Dir(r.bin))
+}
+
+// scan returns the modification times of the watched files.
+func (r *runner) scan() map[string]time.Time {
+	res := make(map[string]time.Time)
+	filepath.WalkDir(r.opts.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != r.opts.Dir && (strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(name); hasExt(r.opts.RestartExts, ext) || hasExt(r.opts.ReloadExts, ext) {
+			if info, err := d.Info(); err == nil {
+				res[path] = info.ModTime()
+			}
+		}
+		return nil
+	})
+	return res
+}
+
+// changed compares the snapshots, returns whether the app should be restarted, or the pages should be reloaded.
+func (r *runner) changed(prev, next map[string]time.Time) (restart, reload bool) {
+	check := func(path string) {
+		if ext := filepath.Ext(path); hasExt(r.opts.RestartExts, ext) {
+			restart = true
+		} else {
+			reload = true
+		}
+	}
+	for path, t := range next {
+		if pt, ok := prev[path]; !ok || !pt.Equal(t) {
+			check(path)
+		}
+	}
+	for path := range prev {
+		if _, ok := next[path]; !ok {
+			check(path)
+		}
+	}
+	return
+}
+
+func hasExt(exts []string, ext string) bool {
+	for _, e := range exts {
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveEvents serves the reload events to the injected script.
+func (r *runner) serveEvents(ctx *gear.Context) error {
+	if ctx.Path != "/events" {
+		return gear.ErrNotFound
+	}
+	ch := make(chan struct{}, 1)
+	r.mu.Lock()
+	r.clients[ch] = struct{}{}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.clients, ch)
+		r.mu.Unlock()
+	}()
+
+	ctx.SetHeader(gear.HeaderAccessControlAllowOrigin, "*")
+	return ctx.SSEStream(func(send func(event, data string) error) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ch:
+				if err := send("reload", ""); err != nil {
+					return err
+				}
+			}
+		}
+	})
+}
+
+// broadcast sends the reload event to the connected pages.
+func (r *runner) broadcast() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for ch := range r.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// reloadScript connects to the reload events server, and reloads the page on the reload event.
+const reloadScript = `<script>(function(){var es=new EventSource(location.protocol+"//"+location.hostname+":%s/events");` +
+	`es.addEventListener("reload",function(){es.close();location.reload()})})()</script>`
+
+// Reload returns a middleware to inject the auto-reload script into the HTML responses (before "</body>",
+// or appended). It works only when the app is started by the dev runner, otherwise it does nothing,
+// so it can be used in production safely. The HTML content should be captured by ctx.Res (such as ctx.HTML
+// and ctx.Render), the files served by http.ServeContent are not injected.
+func Reload() gear.Middleware {
+	port := os.Getenv(EnvReloadPort)
+	if port == "" {
+		return func(ctx *gear.Context) error { return nil }
+	}
+	script := []byte(fmt.Sprintf(reloadScript, port))
+	return func(ctx *gear.Context) error {
+		ctx.After(func() {
+			body := ctx.Res.Body()
+			if body == nil || !strings.HasPrefix(ctx.Res.Type(), gear.MIMETextHTML) {
+				return
+			}
+			res := make([]byte, 0, len(body)+len(script))
+			if i := bytes.LastIndex(body, []byte("</body>")); i >= 0 {
+				res = append(append(append(res, body[:i]...), script...), body[i:]...)
+			} else {
+				res = append(append(res, body...), script...)
+			}
+			ctx.Res.Del(gear.HeaderContentLength)
+			ctx.Res.SetBody(res)
+		})
+		return nil
+	}
+}
@@ -0,0 +1,186 @@
+package dev
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func TestGearDevReload(t *testing.T) {
+	t.Run("should do nothing if not started by the runner", func(t *testing.T) {
+		assert := assert.New(t)
+
+		t.Setenv(EnvReloadPort, "")
+		app := gear.New()
+		app.Use(Reload())
+		app.Use(func(ctx *gear.Context) error {
+			return ctx.HTML(200, "<html><body>Hello</body></html>")
+		})
+		srv := app.Start()
+		defer srv.Close()
+
+		res, err := http.Get("http://" + srv.Addr().String())
+		assert.Nil(err)
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		assert.Equal("<html><body>Hello</body></html>", string(body))
+	})
+
+	t.Run("should inject the reload script into HTML", func(t *testing.T) {
+		assert := assert.New(t)
+
+		t.Setenv(EnvReloadPort, "35729")
+		app := gear.New()
+		app.Use(Reload())
+		app.Use(func(ctx *gear.Context) error {
+			switch ctx.Path {
+			case "/page":
+				return ctx.HTML(200, "<html><body>Hello</body></html>")
+			case "/fragment":
+				return ctx.HTML(200, "<p>Hello</p>")
+			}
+			return ctx.JSON(200, map[string]string{"html": "</body>"})
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+		script := strings.Replace(reloadScript, "%s", "35729", 1)
+
+		get := func(path string) string {
+			res, err := http.Get(host + path)
+			assert.Nil(err)
+			defer res.Body.Close()
+			body, _ := io.ReadAll(res.Body)
+			assert.Equal(int64(len(body)), res.ContentLength)
+			return string(body)
+		}
+		assert.Equal("<html><body>Hello"+script+"</body></html>", get("/page"))
+		assert.Equal("<p>Hello</p>"+script, get("/fragment"))
+		assert.NotContains(get("/json"), "<script>")
+	})
+}
+
+func TestGearDevWatch(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		file := filepath.Join(dir, name)
+		assert.Nil(os.MkdirAll(filepath.Dir(file), 0755))
+		assert.Nil(os.WriteFile(file, []byte(content), 0644))
+	}
+	write("main.go", "package main")
+	write("views/index.html", "<html></html>")
+	write("README.md", "# app")
+	write(".git/HEAD", "ref")
+	write("node_modules/x/index.js", "")
+
+	r := &runner{opts: Options{Dir: dir, RestartExts: []string{".go"}, ReloadExts: []string{".html", ".js"}}}
+	prev := r.scan()
+	assert.Equal(2, len(prev))
+	assert.Contains(prev, filepath.Join(dir, "main.go"))
+	assert.Contains(prev, filepath.Join(dir, "views/index.html"))
+
+	restart, reload := r.changed(prev, r.scan())
+	assert.False(restart)
+	assert.False(reload)
+
+	next := r.scan()
+	next[filepath.Join(dir, "views/index.html")] = time.Now().Add(time.Second)
+	restart, reload = r.changed(prev, next)
+	assert.False(restart)
+	assert.True(reload)
+
+	delete(next, filepath.Join(dir, "views/index.html"))
+	restart, reload = r.changed(prev, next)
+	assert.False(restart)
+	assert.True(reload)
+
+	next[filepath.Join(dir, "util.go")] = time.Now()
+	restart, _ = r.changed(prev, next)
+	assert.True(restart)
+}
+
+// devApp is a tiny app without dependencies, it serves on the inherited listener as app.ListenGraceful does.
+const devApp = `package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+)
+
+const version = "%s"
+
+func main() {
+	l, err := net.FileListener(os.NewFile(3, "listener"))
+	if err != nil {
+		panic(err)
+	}
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(version + " " + os.Getenv("GEAR_DEV_RELOAD_PORT")))
+	}))
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	<-c
+}
+`
+
+func TestGearDevRun(t *testing.T) {
+	if testing.Short() || runtime.GOOS == "windows" {
+		t.Skip("skip building the app")
+	}
+	assert := assert.New(t)
+
+	t.Setenv("GOFLAGS", "")
+	dir := t.TempDir()
+	assert.Nil(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module devapp\n\ngo 1.20\n"), 0644))
+	writeApp := func(version string) {
+		assert.Nil(os.WriteFile(filepath.Join(dir, "main.go"), []byte(strings.Replace(devApp, "%s", version, 1)), 0644))
+	}
+	writeApp("v1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, Options{Addr: "127.0.0.1:38511", ReloadAddr: "127.0.0.1:38512", Dir: dir,
+			Interval: 50 * time.Millisecond, Stdout: io.Discard, Stderr: io.Discard})
+	}()
+
+	get := func(url string) string {
+		res, err := http.Get(url)
+		if err != nil {
+			return ""
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return string(body)
+	}
+	assert.Eventually(func() bool { return get("http://127.0.0.1:38511") == "v1 38512" }, 60*time.Second, 50*time.Millisecond)
+	events, err := http.Get("http://127.0.0.1:38512/events")
+	assert.Nil(err)
+	assert.Equal("*", events.Header.Get(gear.HeaderAccessControlAllowOrigin))
+	defer events.Body.Close()
+
+	writeApp("v2")
+	os.Chtimes(filepath.Join(dir, "main.go"), time.Now().Add(time.Second), time.Now().Add(time.Second))
+	assert.Eventually(func() bool { return get("http://127.0.0.1:38511") == "v2 38512" }, 60*time.Second, 50*time.Millisecond)
+
+	buf := make([]byte, 256)
+	n, _ := io.ReadAtLeast(events.Body, buf, len("event: reload"))
+	assert.Contains(string(buf[:n]), "event: reload")
+
+	cancel()
+	assert.Nil(<-done)
+	assert.Equal("", get("http://127.0.0.1:38511"))
+}
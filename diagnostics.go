@@ -0,0 +1,87 @@
+package gear
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DiagnosticHeaders is the value of SetDiagnosticHeaders setting, it defines the diagnostics headers
+// that will be emitted on every response, including error responses.
+type DiagnosticHeaders struct {
+	// Emits "X-Request-Id" header with the request's "X-Request-Id" or a generated one.
+	// It is set when the request starts, so the following middlewares can read it by `ctx.Res.Get(gear.HeaderXRequestID)`.
+	RequestID bool
+	// Emits "X-Runtime" header with the processing duration in seconds, such as "0.012345".
+	Runtime bool
+	// Emits "X-Served-By" header with the hostname.
+	ServedBy bool
+	// Emits "X-Route-Hash" header with the FNV-1a hash of the matched route pattern.
+	RouteHash bool
+	// Emits "X-Middleware-Trace" header with the names of the named middlewares (see gear.Named) that ran,
+	// such as "requestid, auth".
+	MiddlewareTrace bool
+	// Envs defines the app envs that headers are emitted in. Optional. Default to all envs.
+	Envs []string
+
+	hostname string
+}
+
+func (d *DiagnosticHeaders) init() {
+	if d.ServedBy {
+		d.hostname, _ = os.Hostname()
+	}
+}
+
+func (d *DiagnosticHeaders) enabled(env string) bool {
+	if len(d.Envs) == 0 {
+		return true
+	}
+	for _, e := range d.Envs {
+		if e == env {
+			return true
+		}
+	}
+	return false
+}
+
+// handleDiagnostics sets the request id header, and adds a hook to set the others before header wrote.
+func (ctx *Context) handleDiagnostics() {
+	d := ctx.app.diagnostics
+	if d == nil || !d.enabled(ctx.app.Env()) {
+		return
+	}
+
+	if d.RequestID && ctx.Res.Get(HeaderXRequestID) == "" {
+		rid := ctx.GetHeader(HeaderXRequestID)
+		if rid == "" {
+			rid = NewID()
+		}
+		ctx.SetHeader(HeaderXRequestID, rid)
+	}
+	if d.MiddlewareTrace {
+		ctx.mdTrace = make([]string, 0, 4)
+	}
+
+	ctx.Res.diagnosticsHook = func() {
+		if d.Runtime {
+			ctx.Res.Set(HeaderXRuntime, strconv.FormatFloat(time.Since(ctx.StartAt).Seconds(), 'f', 6, 64))
+		}
+		if d.ServedBy && d.hostname != "" {
+			ctx.Res.Set(HeaderXServedBy, d.hostname)
+		}
+		if d.RouteHash {
+			if pattern := GetRouterPatternFromCtx(ctx); pattern != "" {
+				h := fnv.New32a()
+				h.Write([]byte(pattern))
+				ctx.Res.Set(HeaderXRouteHash, fmt.Sprintf("%08x", h.Sum32()))
+			}
+		}
+		if d.MiddlewareTrace && len(ctx.mdTrace) > 0 {
+			ctx.Res.Set(HeaderXMiddlewareTrace, strings.Join(ctx.mdTrace, ", "))
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package gear
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// the max number of ctx.Forward calls in a request, to prevent forwarding loops.
+const maxForwards = 10
+
+// Forward re-dispatches the request to the route of the method and path (may have query) through the routers
+// used by app.UseHandler, in-process without an HTTP round trip. The Context is shared, so the values
+// (ctx.SetAny), cookies and response headers set before are kept, and the router middlewares of the new route
+// run. The ctx.Method, ctx.Path and ctx.Req are rewritten, it is useful for aliases and legacy URL rewrites:
+//
+//	router.Get("/v1/users/:id", func(ctx *gear.Context) error {
+//		return ctx.Forward(http.MethodGet, "/v2/users/"+ctx.Param("id"))
+//	})
+//
+// It returns 404 error if no route matched, and 508 error if forwarded more than 10 times.
+func (ctx *Context) Forward(method, path string) error {
+	if ctx.Res.wroteHeader.isTrue() {
+		return Err.WithMsg("can't forward after header wrote")
+	}
+	if ctx.forwards++; ctx.forwards > maxForwards {
+		return ErrLoopDetected.WithMsgf("too many forwards, max %d", maxForwards)
+	}
+	u, err := url.ParseRequestURI(path)
+	if err != nil {
+		return Err.WithMsgf("invalid forward path %q", path)
+	}
+
+	req := ctx.Req.Clone(ctx.ctx)
+	req.Method = method
+	req.URL.Path, req.URL.RawPath, req.URL.RawQuery = u.Path, u.RawPath, u.RawQuery
+	req.RequestURI = u.RequestURI()
+	ctx.Req = req
+	ctx.Method = method
+	ctx.Path = u.Path
+	ctx.query = nil
+	if state := CtxValue[State](ctx); state != nil {
+		state.RouterPrefix, state.RouterMatched, state.routerPattern = "", nil, ""
+	}
+
+	for _, router := range ctx.app.routers {
+		if err := router.Serve(ctx); !IsNil(err) || ctx.Res.ended.isTrue() {
+			return err
+		}
+	}
+	return ErrNotFound.WithMsgf("no route to forward %s %s", method, u.Path)
+}
+
+// Invoke dispatches the request through the app in-process (with all middlewares), without an HTTP round trip,
+// and returns the response with the buffered body. It is useful for batch handlers (see Batch) and testing.
+// The RequestURI and RemoteAddr ("127.0.0.1:0") of the request are set if empty.
+//
+//	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/users/123", nil)
+//	res := app.Invoke(req)
+//	defer res.Body.Close()
+func (app *App) Invoke(req *http.Request) *http.Response {
+	rec := app.invoke(req)
+	return &http.Response{
+		Status:        strconv.Itoa(rec.status) + " " + http.StatusText(rec.status),
+		StatusCode:    rec.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.header,
+		Body:          io.NopCloser(bytes.NewReader(rec.body.Bytes())),
+		ContentLength: int64(rec.body.Len()),
+		Request:       req,
+	}
+}
+
+func (app *App) invoke(req *http.Request) *responseRecorder {
+	if req.RequestURI == "" {
+		req.RequestURI = req.URL.RequestURI()
+	}
+	if req.RemoteAddr == "" {
+		req.RemoteAddr = "127.0.0.1:0"
+	}
+	rec := newResponseRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec
+}
+
+// responseRecorder records the response of in-process dispatching.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: make(http.Header)}
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
+
+// Flush implemented http.Flusher interface, it is a no-op.
+func (r *responseRecorder) Flush() {}
@@ -0,0 +1,132 @@
+package gear
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearContextForward(t *testing.T) {
+	app := New()
+	app.Use(func(ctx *Context) error {
+		ctx.SetAny("user", "gear")
+		return nil
+	})
+	router := NewRouter(RouterOptions{Root: "/api"})
+	router.Get("/v2/users/:id", func(ctx *Context) error {
+		user, _ := ctx.Any("user")
+		return ctx.JSON(200, map[string]any{
+			"id":      ctx.Param("id"),
+			"user":    user,
+			"fields":  ctx.Query("fields"),
+			"route":   GetRouterPatternFromCtx(ctx),
+			"path":    ctx.Path,
+			"forward": ctx.GetHeader("X-Forward"),
+		})
+	})
+	router.Get("/v1/users/:id", func(ctx *Context) error {
+		ctx.SetHeader("X-Legacy", "true")
+		return ctx.Forward(http.MethodGet, "/api/v2/users/"+ctx.Param("id")+"?fields=name")
+	})
+	router.Get("/loop", func(ctx *Context) error {
+		return ctx.Forward(http.MethodGet, "/api/loop")
+	})
+	router.Get("/unknown", func(ctx *Context) error {
+		return ctx.Forward(http.MethodGet, "/api/none")
+	})
+	router.Get("/invalid", func(ctx *Context) error {
+		return ctx.Forward(http.MethodGet, "api")
+	})
+	router.Get("/wrote", func(ctx *Context) error {
+		ctx.Res.WriteHeader(200)
+		return ctx.Forward(http.MethodGet, "/api/v2/users/1")
+	})
+	app.UseHandler(router)
+
+	admin := NewRouter(RouterOptions{Root: "/admin"})
+	admin.Use(func(ctx *Context) error {
+		ctx.SetHeader("X-Admin", "true")
+		return nil
+	})
+	admin.Post("/users/:id", func(ctx *Context) error {
+		return ctx.HTML(200, "admin "+ctx.Method+" "+ctx.Param("id"))
+	})
+	router.Put("/users/:id", func(ctx *Context) error {
+		return ctx.Forward(http.MethodPost, "/admin/users/"+ctx.Param("id"))
+	})
+	app.UseHandler(admin)
+
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	t.Run("should forward to another route", func(t *testing.T) {
+		assert := assert.New(t)
+
+		req, _ := http.NewRequest("GET", host+"/api/v1/users/123", nil)
+		req.Header.Set("X-Forward", "1")
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("true", res.Header.Get("X-Legacy"))
+		assert.Equal(`{"fields":"name","forward":"1","id":"123","path":"/api/v2/users/123","route":"/api/v2/users/:id","user":"gear"}`,
+			PickRes(res.Text()).(string))
+
+		res, err = RequestBy("PUT", host+"/api/users/123")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("true", res.Header.Get("X-Admin"))
+		assert.Equal("admin POST 123", PickRes(res.Text()).(string))
+	})
+
+	t.Run("should return error", func(t *testing.T) {
+		assert := assert.New(t)
+
+		res, err := RequestBy("GET", host+"/api/loop")
+		assert.Nil(err)
+		assert.Equal(508, res.StatusCode)
+		assert.Equal(`{"error":"LoopDetected","message":"too many forwards, max 10"}`, PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host+"/api/unknown")
+		assert.Nil(err)
+		assert.Equal(404, res.StatusCode)
+		assert.Equal(`{"error":"NotFound","message":"no route to forward GET /api/none"}`, PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host+"/api/invalid")
+		assert.Nil(err)
+		assert.Equal(500, res.StatusCode)
+		assert.Equal(`{"error":"Error","message":"invalid forward path \"api\""}`, PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host+"/api/wrote")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		res.Body.Close()
+	})
+}
+
+func TestGearAppInvoke(t *testing.T) {
+	assert := assert.New(t)
+
+	app := New()
+	router := NewRouter()
+	router.Get("/users/:id", func(ctx *Context) error {
+		return ctx.JSON(200, map[string]string{"id": ctx.Param("id"), "ip": ctx.IP().String()})
+	})
+	app.UseHandler(router)
+
+	req, _ := http.NewRequest(http.MethodGet, "/users/123", nil)
+	res := app.Invoke(req)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal("200 OK", res.Status)
+	assert.Equal(MIMEApplicationJSONCharsetUTF8, res.Header.Get(HeaderContentType))
+	body, _ := io.ReadAll(res.Body)
+	assert.Equal(`{"id":"123","ip":"127.0.0.1"}`, string(body))
+	assert.Equal(int64(len(body)), res.ContentLength)
+
+	req, _ = http.NewRequest(http.MethodPost, "/users/123", nil)
+	res = app.Invoke(req)
+	assert.Equal(405, res.StatusCode)
+	assert.Equal("GET", res.Header.Get(HeaderAllow))
+}
@@ -0,0 +1,174 @@
+package gear
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ETagMode is the value of SetETag setting.
+type ETagMode int
+
+const (
+	// ETagWeak generates weak ETags, such as `W/"d-CgqfKmdylCVXq1NV12r0Qvj2XgE"` for "Hello, World!".
+	// It is suitable for the responses that may be compressed or transformed by proxies.
+	ETagWeak ETagMode = iota + 1
+	// ETagStrong generates strong ETags, such as `"d-CgqfKmdylCVXq1NV12r0Qvj2XgE"`.
+	ETagStrong
+)
+
+// ETag generates an ETag from data (usually the response body) and sets it to the response ETag header.
+// The ETag is weak if weak is true. It returns the ETag.
+//
+//	func GetUser(ctx *gear.Context) error {
+//		user := loadUser(ctx.Param("id"))
+//		ctx.ETag([]byte(user.Version), true)
+//		ctx.LastModified(user.UpdatedAt)
+//		if ctx.Fresh() {
+//			return ctx.End(http.StatusNotModified) // skip the rendering
+//		}
+//		return ctx.JSON(http.StatusOK, user)
+//	}
+func (ctx *Context) ETag(data []byte, weak ...bool) string {
+	etag := ETagOf(data, len(weak) > 0 && weak[0])
+	ctx.SetHeader(HeaderETag, etag)
+	return etag
+}
+
+// LastModified sets the response Last-Modified header with t.
+func (ctx *Context) LastModified(t time.Time) {
+	if !t.IsZero() {
+		ctx.SetHeader(HeaderLastModified, t.UTC().Format(http.TimeFormat))
+	}
+}
+
+// Fresh returns true if the request is a GET or HEAD request, and its If-None-Match or If-Modified-Since
+// header matches the response ETag or Last-Modified header, so the client's cached response is still fresh.
+func (ctx *Context) Fresh() bool {
+	if ctx.Method != http.MethodGet && ctx.Method != http.MethodHead {
+		return false
+	}
+	if strings.Contains(ctx.GetHeader(HeaderCacheControl), "no-cache") {
+		return false
+	}
+
+	if inm := ctx.GetHeader(HeaderIfNoneMatch); inm != "" {
+		etag := ctx.Res.Get(HeaderETag)
+		if etag == "" {
+			return false
+		}
+		for _, tag := range strings.Split(inm, ",") {
+			if tag = strings.TrimSpace(tag); tag == "*" || weakETag(tag) == weakETag(etag) {
+				return true
+			}
+		}
+		return false // If-Modified-Since is ignored with If-None-Match, RFC 7232 Section 3.3
+	}
+
+	if ims := ctx.GetHeader(HeaderIfModifiedSince); ims != "" {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		modified, err := http.ParseTime(ctx.Res.Get(HeaderLastModified))
+		return err == nil && !modified.After(since)
+	}
+	return false
+}
+
+// ResourceETag generates a strong ETag from the JSON encoding of the resource, sets it to the response ETag header
+// and returns it. The ETag changes when the resource changes, so it can be used with RequireIfMatch for
+// optimistic concurrency control:
+//
+//	router.Put("/users/:id", func(ctx *gear.Context) error {
+//		user := loadUser(ctx.Param("id"))
+//		etag, err := ctx.ResourceETag(user)
+//		if err != nil {
+//			return err
+//		}
+//		if err := ctx.RequireIfMatch(etag); err != nil {
+//			return err // 428 if If-Match absent, 412 if user was modified by others
+//		}
+//		updateUser(ctx, user)
+//		ctx.ResourceETag(user) // the new ETag
+//		return ctx.JSON(http.StatusOK, user)
+//	})
+func (ctx *Context) ResourceETag(resource any) (string, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return "", err
+	}
+	return ctx.ETag(data), nil
+}
+
+// RequireIfMatch evaluates the If-Match request header against the current ETag of the resource, that is required
+// by the unsafe requests (such as PUT, PATCH and DELETE) to prevent the "lost update" problem. It returns
+// ErrPreconditionRequired (428) if the header is absent, or ErrPreconditionFailed (412) if none of the ETags
+// matches. The ETags are compared with strong comparison (weak ETags never match), RFC 7232 Section 3.1,
+// "*" matches any current ETag (the resource exists).
+func (ctx *Context) RequireIfMatch(etag string) error {
+	im := ctx.GetHeader(HeaderIfMatch)
+	if im == "" {
+		return ErrPreconditionRequired.WithMsg("If-Match header is required")
+	}
+	if etag != "" && !strings.HasPrefix(etag, "W/") {
+		for _, tag := range strings.Split(im, ",") {
+			if tag = strings.TrimSpace(tag); tag == "*" || tag == etag {
+				return nil
+			}
+		}
+	}
+	return ErrPreconditionFailed.WithMsgf("ETag %s does not match If-Match", etag)
+}
+
+// RequireIfUnmodifiedSince evaluates the If-Unmodified-Since request header against the last modification time
+// of the resource, for the resources without ETag. It returns ErrPreconditionRequired (428) if the header is absent
+// or invalid, or ErrPreconditionFailed (412) if the resource was modified since then.
+// The time is compared in seconds, the precision of HTTP date.
+func (ctx *Context) RequireIfUnmodifiedSince(lastModified time.Time) error {
+	since, err := http.ParseTime(ctx.GetHeader(HeaderIfUnmodifiedSince))
+	if err != nil {
+		return ErrPreconditionRequired.WithMsg("If-Unmodified-Since header is required")
+	}
+	if lastModified.Truncate(time.Second).After(since) {
+		return ErrPreconditionFailed.WithMsgf("resource was modified at %s", lastModified.UTC().Format(http.TimeFormat))
+	}
+	return nil
+}
+
+// ETagOf generates an ETag from data, the ETag is weak if weak is true.
+func ETagOf(data []byte, weak bool) string {
+	sum := sha1.Sum(data)
+	etag := `"` + strconv.FormatInt(int64(len(data)), 16) + "-" + base64.RawURLEncoding.EncodeToString(sum[:]) + `"`
+	if weak {
+		etag = "W/" + etag
+	}
+	return etag
+}
+
+// weakETag returns the opaque tag for weak comparison, RFC 7232 Section 2.3.2.
+func weakETag(etag string) string {
+	return strings.TrimPrefix(etag, "W/")
+}
+
+// conditional generates ETag for the response body sent by ctx.End and evaluates the conditional request,
+// it responds 304 without body if fresh. It runs with SetETag setting only, after the "after hooks".
+func (ctx *Context) conditional() {
+	r := ctx.Res
+	if r.status != http.StatusOK || r.body == nil || (ctx.Method != http.MethodGet && ctx.Method != http.MethodHead) {
+		return
+	}
+	if r.Get(HeaderETag) == "" {
+		ctx.ETag(r.body, ctx.app.etag == ETagWeak)
+	}
+	if ctx.Fresh() {
+		r.Del(HeaderContentType)
+		r.Del(HeaderContentLength)
+		r.status = http.StatusNotModified
+		r.body = nil
+	}
+}
@@ -0,0 +1,268 @@
+package gear
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearETag(t *testing.T) {
+	t.Run("ETagOf", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.Equal(`"d-CgqfKmdylCVXq1NV12r0Qvj2XgE"`, ETagOf([]byte("Hello, World!"), false))
+		assert.Equal(`W/"d-CgqfKmdylCVXq1NV12r0Qvj2XgE"`, ETagOf([]byte("Hello, World!"), true))
+		assert.Equal(`"0-2jmj7l5rSw0yVb_vlWAYkK_YBwk"`, ETagOf(nil, false))
+	})
+
+	t.Run("should panic with invalid SetETag", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		assert.Panics(func() { app.Set(SetETag, true) })
+		assert.Panics(func() { app.Set(SetETag, ETagMode(3)) })
+		app.Set(SetETag, ETagStrong)
+		assert.Equal(ETagStrong, app.Clone().etag)
+	})
+
+	t.Run("ctx.Fresh", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		ctx := CtxTest(app, "GET", "http://example.com/foo", nil)
+		assert.False(ctx.Fresh())
+		ctx.Req.Header.Set(HeaderIfNoneMatch, `"abc"`)
+		assert.False(ctx.Fresh())
+
+		ctx.SetHeader(HeaderETag, `W/"abc"`)
+		assert.True(ctx.Fresh())
+		ctx.Req.Header.Set(HeaderIfNoneMatch, `"x", W/"abc"`)
+		assert.True(ctx.Fresh())
+		ctx.Req.Header.Set(HeaderIfNoneMatch, "*")
+		assert.True(ctx.Fresh())
+		ctx.Req.Header.Set(HeaderCacheControl, "no-cache")
+		assert.False(ctx.Fresh())
+
+		now := time.Now()
+		ctx = CtxTest(app, "GET", "http://example.com/foo", nil)
+		ctx.LastModified(time.Time{})
+		assert.Equal("", ctx.Res.Get(HeaderLastModified))
+		ctx.LastModified(now)
+		assert.Equal(now.UTC().Format(http.TimeFormat), ctx.Res.Get(HeaderLastModified))
+		ctx.Req.Header.Set(HeaderIfModifiedSince, now.Add(-time.Hour).UTC().Format(http.TimeFormat))
+		assert.False(ctx.Fresh())
+		ctx.Req.Header.Set(HeaderIfModifiedSince, now.UTC().Format(http.TimeFormat))
+		assert.True(ctx.Fresh())
+		ctx.Req.Header.Set(HeaderIfModifiedSince, "invalid")
+		assert.False(ctx.Fresh())
+
+		ctx.Req.Header.Set(HeaderIfModifiedSince, now.UTC().Format(http.TimeFormat))
+		ctx.Req.Header.Set(HeaderIfNoneMatch, `"abc"`)
+		ctx.SetHeader(HeaderETag, `"xyz"`)
+		assert.False(ctx.Fresh())
+
+		ctx = CtxTest(app, "POST", "http://example.com/foo", nil)
+		ctx.SetHeader(HeaderETag, `"abc"`)
+		ctx.Req.Header.Set(HeaderIfNoneMatch, `"abc"`)
+		assert.False(ctx.Fresh())
+	})
+
+	t.Run("should respond 304 with SetETag", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Set(SetETag, ETagWeak)
+		app.Use(func(ctx *Context) error {
+			switch ctx.Path {
+			case "/created":
+				return ctx.JSON(http.StatusCreated, []string{"Hello"})
+			case "/modified":
+				ctx.LastModified(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+			}
+			return ctx.JSON(http.StatusOK, []string{"Hello"})
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("GET", host)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		etag := res.Header.Get(HeaderETag)
+		assert.Equal(ETagOf([]byte(`["Hello"]`), true), etag)
+		assert.Equal(`["Hello"]`, PickRes(res.Text()).(string))
+
+		req, _ := http.NewRequest("GET", host, nil)
+		req.Header.Set(HeaderIfNoneMatch, etag)
+		res, err = DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(304, res.StatusCode)
+		assert.Equal(etag, res.Header.Get(HeaderETag))
+		assert.Equal("", res.Header.Get(HeaderContentType))
+		assert.Equal("", PickRes(res.Text()).(string))
+
+		req, _ = http.NewRequest("HEAD", host, nil)
+		req.Header.Set(HeaderIfNoneMatch, etag)
+		res, err = DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(304, res.StatusCode)
+		res.Body.Close()
+
+		req, _ = http.NewRequest("GET", host+"/created", nil)
+		req.Header.Set(HeaderIfNoneMatch, etag)
+		res, err = DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(201, res.StatusCode)
+		assert.Equal("", res.Header.Get(HeaderETag))
+		res.Body.Close()
+
+		req, _ = http.NewRequest("GET", host+"/modified", nil)
+		req.Header.Set(HeaderIfModifiedSince, "Wed, 01 Jan 2020 00:00:00 GMT")
+		req.Header.Set(HeaderIfNoneMatch, `"other"`)
+		res, err = DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		res.Body.Close()
+
+		req.Header.Del(HeaderIfNoneMatch)
+		res, err = DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(304, res.StatusCode)
+		res.Body.Close()
+	})
+
+	t.Run("should respect the ETag set by after hooks with SetETag", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Set(SetETag, ETagStrong)
+		app.Use(func(ctx *Context) error {
+			ctx.After(func() {
+				ctx.SetHeader(HeaderETag, `"v2"`)
+			})
+			return ctx.HTML(http.StatusOK, "Hello")
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		req, _ := http.NewRequest("GET", host, nil)
+		req.Header.Set(HeaderIfNoneMatch, `"v2"`)
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(304, res.StatusCode)
+		res.Body.Close()
+	})
+
+	t.Run("should not respond 304 automatically without SetETag", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Use(func(ctx *Context) error {
+			ctx.SetHeader(HeaderETag, `"v1"`)
+			return ctx.HTML(http.StatusOK, "Hello")
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		req, _ := http.NewRequest("GET", host, nil)
+		req.Header.Set(HeaderIfNoneMatch, `"v1"`)
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("Hello", PickRes(res.Text()).(string))
+	})
+
+	t.Run("should respond 304 with ctx.ETag without SetETag", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Use(func(ctx *Context) error {
+			if ctx.Path == "/auto" {
+				return ctx.HTML(http.StatusOK, "OK")
+			}
+			ctx.ETag([]byte("v1"))
+			if ctx.Fresh() {
+				return ctx.End(http.StatusNotModified)
+			}
+			return ctx.HTML(http.StatusOK, "Hello")
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("GET", host+"/auto")
+		assert.Nil(err)
+		assert.Equal("", res.Header.Get(HeaderETag))
+		res.Body.Close()
+
+		req, _ := http.NewRequest("GET", host, nil)
+		req.Header.Set(HeaderIfNoneMatch, ETagOf([]byte("v1"), false))
+		res, err = DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(304, res.StatusCode)
+		res.Body.Close()
+
+		req.Header.Set(HeaderIfNoneMatch, ETagOf([]byte("v0"), false))
+		res, err = DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("Hello", PickRes(res.Text()).(string))
+	})
+
+	t.Run("ctx.RequireIfMatch", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		ctx := CtxTest(app, "PUT", "http://example.com/users/1", nil)
+		etag, err := ctx.ResourceETag(map[string]any{"id": 1, "name": "gear"})
+		assert.Nil(err)
+		assert.Equal(ETagOf([]byte(`{"id":1,"name":"gear"}`), false), etag)
+		assert.Equal(etag, ctx.Res.Get(HeaderETag))
+		_, err = ctx.ResourceETag(func() {})
+		assert.NotNil(err)
+
+		err = ctx.RequireIfMatch(etag)
+		assert.Equal(428, ParseError(err).Status())
+		assert.Equal("PreconditionRequired: If-Match header is required", err.Error())
+
+		ctx.Req.Header.Set(HeaderIfMatch, etag)
+		assert.Nil(ctx.RequireIfMatch(etag))
+		ctx.Req.Header.Set(HeaderIfMatch, `"xyz", `+etag)
+		assert.Nil(ctx.RequireIfMatch(etag))
+		ctx.Req.Header.Set(HeaderIfMatch, "*")
+		assert.Nil(ctx.RequireIfMatch(etag))
+
+		err = ctx.RequireIfMatch("")
+		assert.Equal(412, ParseError(err).Status(), "should not match the missing resource")
+		ctx.Req.Header.Set(HeaderIfMatch, `"xyz"`)
+		err = ctx.RequireIfMatch(etag)
+		assert.Equal(412, ParseError(err).Status())
+		assert.Equal("PreconditionFailed: ETag "+etag+" does not match If-Match", err.Error())
+		ctx.Req.Header.Set(HeaderIfMatch, `W/"xyz"`)
+		assert.NotNil(ctx.RequireIfMatch(`W/"xyz"`), "should not match weak ETags")
+	})
+
+	t.Run("ctx.RequireIfUnmodifiedSince", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		ctx := CtxTest(app, "PUT", "http://example.com/users/1", nil)
+		now := time.Now()
+
+		err := ctx.RequireIfUnmodifiedSince(now)
+		assert.Equal(428, ParseError(err).Status())
+		ctx.Req.Header.Set(HeaderIfUnmodifiedSince, "invalid")
+		assert.Equal(428, ParseError(ctx.RequireIfUnmodifiedSince(now)).Status())
+
+		ctx.Req.Header.Set(HeaderIfUnmodifiedSince, now.UTC().Format(http.TimeFormat))
+		assert.Nil(ctx.RequireIfUnmodifiedSince(now))
+		assert.Nil(ctx.RequireIfUnmodifiedSince(now.Add(-time.Hour)))
+		err = ctx.RequireIfUnmodifiedSince(now.Add(time.Second))
+		assert.Equal(412, ParseError(err).Status())
+		assert.Equal("PreconditionFailed: resource was modified at "+now.Add(time.Second).UTC().Format(http.TimeFormat), err.Error())
+	})
+}
@@ -37,29 +37,19 @@ func main() {
 
 	// try: http://127.0.0.1:3000/events
 	router.Get("/events", func(ctx *gear.Context) error {
-		// https://github.com/kljensen/golang-html5-sse-example/blob/1e2f75f9ea91b900a42ac373c59dc7c8388dfb2a/server.go
-		// Set the headers related to event streaming.
-		ctx.SetHeader("Content-Type", "text/event-stream")
-		ctx.SetHeader("Cache-Control", "no-cache")
-		ctx.SetHeader("Connection", "keep-alive")
-
-		for {
-			// Read from our messageChan.
-			msg, open := <-messageChan
-			if !open {
-				// If our messageChan was closed, this means that the client has
-				// disconnected.
-				fmt.Fprint(ctx.Res, "message chan closed\n\n")
-				ctx.Res.Flush()
-				break
+		// ctx.SSEStream sets the event stream headers, flushes every event and sends heartbeats.
+		return ctx.SSEStream(func(send func(event, data string) error) error {
+			for {
+				select {
+				case <-ctx.Done(): // client disconnected
+					return nil
+				case msg := <-messageChan:
+					if err := send("message", "Message: "+msg); err != nil {
+						return err
+					}
+				}
 			}
-
-			fmt.Fprintf(ctx.Res, "data: Message: %s\n\n", msg)
-			// Flush the response.  This is only possible if the repsonse supports streaming.
-			ctx.Res.Flush()
-		}
-
-		return nil
+		})
 	})
 
 	app.UseHandler(router)
@@ -0,0 +1,104 @@
+package gear
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// FilterFields returns a copy of data that only contains the given fields, like JSON:API sparse fieldsets.
+// The data is marshaled with encoding/json, and filtered as a JSON object or an array of JSON objects.
+// A field can be a dot-separated path for nested objects. If data is not a JSON object or array,
+// or no fields given, data will be returned without change.
+//
+//	val, err := gear.FilterFields(users, "id", "name", "profile.avatar")
+func FilterFields(data any, fields ...string) (any, error) {
+	tree := fieldsTree{}
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			tree.add(strings.Split(field, "."))
+		}
+	}
+	if len(tree) == 0 {
+		return data, nil
+	}
+
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) == 0 || (buf[0] != '{' && buf[0] != '[') {
+		return data, nil
+	}
+
+	var doc any
+	if err = unmarshalJSONDoc(buf, &doc); err != nil {
+		return nil, err
+	}
+	return tree.filter(doc), nil
+}
+
+// SparseFieldsTransformer returns a SetResponseTransformer hook that filters response data
+// by the comma-separated fields in the query parameter, default to "fields".
+//
+//	app.Set(gear.SetResponseTransformer, gear.SparseFieldsTransformer())
+//	// GET /users?fields=id,name
+func SparseFieldsTransformer(param ...string) func(*Context, any) any {
+	name := "fields"
+	if len(param) > 0 && param[0] != "" {
+		name = param[0]
+	}
+
+	return func(ctx *Context, data any) any {
+		if _, ok := data.(error); ok {
+			return data
+		}
+		if fields := ctx.Query(name); fields != "" {
+			if val, err := FilterFields(data, strings.Split(fields, ",")...); err == nil {
+				return val
+			}
+		}
+		return data
+	}
+}
+
+// fieldsTree is a tree of field paths, a nil subtree means the whole field should be kept.
+type fieldsTree map[string]fieldsTree
+
+func (t fieldsTree) add(path []string) {
+	sub, ok := t[path[0]]
+	if len(path) == 1 {
+		t[path[0]] = nil
+		return
+	}
+	if ok && sub == nil {
+		return // the whole field is kept already
+	}
+	if sub == nil {
+		sub = fieldsTree{}
+		t[path[0]] = sub
+	}
+	sub.add(path[1:])
+}
+
+func (t fieldsTree) filter(doc any) any {
+	switch v := doc.(type) {
+	case map[string]any:
+		res := make(map[string]any, len(t))
+		for key, sub := range t {
+			if val, ok := v[key]; ok {
+				if sub != nil {
+					val = sub.filter(val)
+				}
+				res[key] = val
+			}
+		}
+		return res
+	case []any:
+		for i, item := range v {
+			v[i] = t.filter(item)
+		}
+		return v
+	default:
+		return doc
+	}
+}
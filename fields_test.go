@@ -0,0 +1,99 @@
+package gear
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fieldsUser struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Profile struct {
+		Avatar string `json:"avatar"`
+		Bio    string `json:"bio"`
+	} `json:"profile"`
+}
+
+func TestGearFilterFields(t *testing.T) {
+	user := fieldsUser{ID: 9007199254740993, Name: "gear", Email: "gear@example.com"}
+	user.Profile.Avatar = "a.png"
+	user.Profile.Bio = "bio"
+
+	t.Run("should filter object", func(t *testing.T) {
+		assert := assert.New(t)
+
+		val, err := FilterFields(user, "id", " name", "profile.avatar", "unknown")
+		assert.Nil(err)
+		buf, _ := json.Marshal(val)
+		assert.Equal(`{"id":9007199254740993,"name":"gear","profile":{"avatar":"a.png"}}`, string(buf))
+
+		val, err = FilterFields(&user, "profile.bio", "profile")
+		assert.Nil(err)
+		buf, _ = json.Marshal(val)
+		assert.Equal(`{"profile":{"avatar":"a.png","bio":"bio"}}`, string(buf))
+	})
+
+	t.Run("should filter array", func(t *testing.T) {
+		assert := assert.New(t)
+
+		val, err := FilterFields([]fieldsUser{user, user}, "name")
+		assert.Nil(err)
+		buf, _ := json.Marshal(val)
+		assert.Equal(`[{"name":"gear"},{"name":"gear"}]`, string(buf))
+	})
+
+	t.Run("should return data without change", func(t *testing.T) {
+		assert := assert.New(t)
+
+		val, err := FilterFields(user)
+		assert.Nil(err)
+		assert.Equal(user, val)
+
+		val, err = FilterFields(user, "", " ")
+		assert.Nil(err)
+		assert.Equal(user, val)
+
+		val, err = FilterFields("text", "name")
+		assert.Nil(err)
+		assert.Equal("text", val)
+
+		val, err = FilterFields([]byte("text"), "name")
+		assert.Nil(err)
+		assert.Equal([]byte("text"), val)
+
+		_, err = FilterFields(func() {}, "name")
+		assert.NotNil(err)
+	})
+}
+
+func TestGearSparseFieldsTransformer(t *testing.T) {
+	assert := assert.New(t)
+
+	app := New()
+	app.Set(SetResponseTransformer, SparseFieldsTransformer())
+	app.Use(func(ctx *Context) error {
+		return ctx.JSON(http.StatusOK, []fieldsUser{{ID: 1, Name: "gear"}})
+	})
+
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	res, err := RequestBy("GET", host+"/users?fields=id,name")
+	assert.Nil(err)
+	assert.Equal(`[{"id":1,"name":"gear"}]`, PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host+"/users")
+	assert.Nil(err)
+	assert.Equal(`[{"id":1,"name":"gear","email":"","profile":{"avatar":"","bio":""}}]`, PickRes(res.Text()).(string))
+
+	transformer := SparseFieldsTransformer("select")
+	ctx := CtxTest(app, "GET", "http://example.com/users?select=name", nil)
+	err = ErrBadRequest.WithMsg("some error")
+	assert.Equal(err, transformer(ctx, err))
+	assert.Equal(map[string]any{"name": "gear"}, transformer(ctx, fieldsUser{Name: "gear"}))
+}
@@ -0,0 +1,95 @@
+package gear
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// File sends the file of path as response. The content type is detected by the file extension, or sniffed
+// from the content. It honors the conditional requests (If-Modified-Since, If-None-Match...) and the Range
+// requests, and sends the content with the zero-copy sendfile system call if the underlying connection supports.
+// It responds 404 for the nonexistent file or directory, 403 for the permission denied file.
+// It will end the ctx. The middlewares after current middleware will not run.
+// "after hooks" and "end hooks" will run normally.
+//
+//	router.Get("/reports/:id", func(ctx *gear.Context) error {
+//		return ctx.File(filepath.Join(reportsDir, filepath.Base(ctx.Param("id"))+".pdf"))
+//	})
+//
+// The path is not sanitized, use ctx.FileFromFS with os.DirFS(dir) to serve the files in a directory by
+// the user input.
+func (ctx *Context) File(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileError(err)
+	}
+	defer f.Close()
+	return ctx.serveFile(f, path, "ctx.File")
+}
+
+// FileFromFS sends the file of name in fsys as response, such as an embed.FS or os.DirFS.
+// The name should be a valid path (see fs.ValidPath), the leading slash is trimmed, so it can be
+// the request path, other invalid paths (such as "../a") respond 404.
+// Same as ctx.File, it honors the conditional requests and the Range requests, and the os.DirFS files
+// are sent with the zero-copy sendfile system call if possible.
+// It will end the ctx. The middlewares after current middleware will not run.
+// "after hooks" and "end hooks" will run normally.
+//
+//	//go:embed assets
+//	var assets embed.FS
+//
+//	router.Get("/assets/*", func(ctx *gear.Context) error {
+//		return ctx.FileFromFS(assets, "assets/"+ctx.Param("*"))
+//	})
+func (ctx *Context) FileFromFS(fsys fs.FS, name string) error {
+	name = strings.TrimPrefix(name, "/")
+	if !fs.ValidPath(name) {
+		return ErrNotFound.WithMsgf("invalid file path %q", name)
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return fileError(err)
+	}
+	defer f.Close()
+	return ctx.serveFile(f, name, "ctx.FileFromFS")
+}
+
+func (ctx *Context) serveFile(f fs.File, name, caller string) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fileError(err)
+	}
+	if info.IsDir() {
+		return ErrNotFound.WithMsgf("%q is a directory", path.Base(name))
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		buf, err := io.ReadAll(f)
+		if err != nil {
+			return ErrInternalServerError.From(err)
+		}
+		content = bytes.NewReader(buf)
+	}
+	if !ctx.Res.ended.swapTrue() {
+		return ErrInternalServerError.WithMsg("request ended before " + caller)
+	}
+	// http.ServeContent detects the content type by the name extension, or sniffs the content.
+	http.ServeContent(ctx.Res, ctx.Req, info.Name(), info.ModTime(), content)
+	return nil
+}
+
+func fileError(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrNotFound.From(err)
+	case errors.Is(err, fs.ErrPermission):
+		return ErrForbidden.From(err)
+	}
+	return ErrInternalServerError.From(err)
+}
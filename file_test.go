@@ -0,0 +1,120 @@
+package gear
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearContextFile(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("Hello, Gear!"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "data"), []byte("<html><body>Hi</body></html>"), 0644))
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"assets/app.js": &fstest.MapFile{Data: []byte("console.log(1)"), ModTime: modTime},
+	}
+
+	app := New()
+	app.Use(func(ctx *Context) error {
+		if ctx.Path == "/twice" {
+			ctx.File(filepath.Join(dir, "hello.txt"))
+			return ctx.File(filepath.Join(dir, "hello.txt"))
+		}
+		if name := ctx.Query("fs"); name != "" {
+			return ctx.FileFromFS(fsys, name)
+		}
+		return ctx.File(filepath.Join(dir, ctx.Query("file")))
+	})
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	do := func(path string, header ...string) (*http.Response, string) {
+		req, _ := http.NewRequest("GET", host+path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		res, err := DefaultClientDo(req)
+		assert.Nil(t, err)
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.Response, string(body)
+	}
+
+	t.Run("should send file with content type", func(t *testing.T) {
+		assert := assert.New(t)
+
+		res, body := do("/?file=hello.txt")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("text/plain; charset=utf-8", res.Header.Get(HeaderContentType))
+		assert.Equal("12", res.Header.Get(HeaderContentLength))
+		assert.Equal("bytes", res.Header.Get(HeaderAcceptRanges))
+		assert.Equal("Hello, Gear!", body)
+
+		res, body = do("/?file=data")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(MIMETextHTMLCharsetUTF8, res.Header.Get(HeaderContentType))
+		assert.Equal("<html><body>Hi</body></html>", body)
+	})
+
+	t.Run("should support conditional and range requests", func(t *testing.T) {
+		assert := assert.New(t)
+
+		res, _ := do("/?file=hello.txt")
+		lastModified := res.Header.Get(HeaderLastModified)
+		assert.NotEqual("", lastModified)
+
+		res, body := do("/?file=hello.txt", HeaderIfModifiedSince, lastModified)
+		assert.Equal(304, res.StatusCode)
+		assert.Equal("", body)
+
+		res, body = do("/?file=hello.txt", "Range", "bytes=7-10")
+		assert.Equal(206, res.StatusCode)
+		assert.Equal("bytes 7-10/12", res.Header.Get("Content-Range"))
+		assert.Equal("Gear", body)
+
+		res, _ = do("/?file=hello.txt", "Range", "bytes=20-")
+		assert.Equal(416, res.StatusCode)
+	})
+
+	t.Run("should respond errors", func(t *testing.T) {
+		assert := assert.New(t)
+
+		res, _ := do("/?file=missing.txt")
+		assert.Equal(404, res.StatusCode)
+		res, _ = do("/?file=sub")
+		assert.Equal(404, res.StatusCode)
+
+		res, body := do("/twice")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("Hello, Gear!", body)
+	})
+
+	t.Run("should send file from fs.FS", func(t *testing.T) {
+		assert := assert.New(t)
+
+		res, body := do("/?fs=/assets/app.js")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("text/javascript; charset=utf-8", res.Header.Get(HeaderContentType))
+		assert.Equal(modTime.Format(http.TimeFormat), res.Header.Get(HeaderLastModified))
+		assert.Equal("console.log(1)", body)
+
+		res, _ = do("/?fs=assets/app.js", HeaderIfModifiedSince, modTime.Format(http.TimeFormat))
+		assert.Equal(304, res.StatusCode)
+
+		res, _ = do("/?fs=../hello.txt")
+		assert.Equal(404, res.StatusCode)
+		res, _ = do("/?fs=assets")
+		assert.Equal(404, res.StatusCode)
+		res, _ = do("/?fs=assets/missing.js")
+		assert.Equal(404, res.StatusCode)
+	})
+}
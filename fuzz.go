@@ -0,0 +1,243 @@
+package gear
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// The bounds of the fuzz inputs, the larger inputs are truncated.
+const (
+	FuzzMaxHeaderBytes = 8 << 10 // 8KB
+	FuzzMaxBodyBytes   = 1 << 20 // 1MB
+)
+
+// fuzzMethods are used when the fuzzed method is not a valid HTTP token.
+var fuzzMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// FuzzInput is a request of the fuzz corpus, see app.FuzzServe.
+type FuzzInput struct {
+	Method string
+	Path   string // the request target, such as "/users?limit=10"
+	Header http.Header
+	Body   []byte
+}
+
+// FuzzSeed adds the inputs to the seed corpus of the fuzz target f (the *testing.F), in the order of
+// app.FuzzServe parameters. The header is encoded in the wire format, "Key: Value\r\n" lines.
+func FuzzSeed(f interface{ Add(args ...any) }, inputs ...FuzzInput) {
+	for _, in := range inputs {
+		var b strings.Builder
+		in.Header.Write(&b)
+		f.Add(in.Method, in.Path, b.String(), in.Body)
+	}
+}
+
+// FuzzCrash is the error returned by app.FuzzServe when the app panicked or responded an invalid status code.
+type FuzzCrash struct {
+	Input  FuzzInput
+	Status int    // the response status code, or the invalid status code set by the app
+	Panic  any    // the recovered panic value, nil if not panicked
+	Stack  string // the stack of the panic
+}
+
+// Error implements the error interface.
+func (c *FuzzCrash) Error() string {
+	if c.Panic != nil {
+		return fmt.Sprintf("panic on %s %s: %v\n%s", c.Input.Method, c.Input.Path, c.Panic, c.Stack)
+	}
+	return fmt.Sprintf("invalid status code %d on %s %s", c.Status, c.Input.Method, c.Input.Path)
+}
+
+// fuzzMu serializes app.FuzzServe, the app middlewares are guarded during the call.
+var fuzzMu sync.Mutex
+
+// FuzzServe drives app.ServeHTTP with the fuzzed method, path (request target), header and body, it is
+// the body of Go fuzz targets (https://go.dev/doc/security/fuzz/) to fuzz the routers, body parsers and
+// middlewares of the app. The inputs are normalized to a valid request as the HTTP server receives:
+// the invalid method is replaced with a common one, the path is escaped if it is invalid, the invalid header
+// lines are dropped, the header and body are truncated to FuzzMaxHeaderBytes and FuzzMaxBodyBytes.
+// It returns the response, and a *FuzzCrash error if the app middlewares panicked (recovered and responded 500
+// by app.ServeHTTP, so it is hidden in production) or set an invalid status code (replaced with 200 or 421 by
+// Response.WriteHeader).
+// The panics in the "end hooks" are not detected, they run after the response in a goroutine.
+// The app middlewares are replaced with a guard during the call, so it should not be called when the app is serving.
+//
+//	func FuzzAPI(f *testing.F) {
+//		app := newApp()
+//		gear.FuzzSeed(f,
+//			gear.FuzzInput{Method: "GET", Path: "/api/users?limit=10"},
+//			gear.FuzzInput{Method: "POST", Path: "/api/users",
+//				Header: http.Header{"Content-Type": {"application/json"}}, Body: []byte(`{"name":"gear"}`)},
+//		)
+//		f.Fuzz(func(t *testing.T, method, path, header string, body []byte) {
+//			if _, err := app.FuzzServe(method, path, header, body); err != nil {
+//				t.Fatal(err)
+//			}
+//		})
+//	}
+//
+// Then run "go test -fuzz=FuzzAPI".
+func (app *App) FuzzServe(method, path, header string, body []byte) (*http.Response, error) {
+	in := fuzzInput(method, path, header, body)
+	req, err := http.NewRequest(in.Method, "http://example.com"+in.Path, bytes.NewReader(in.Body))
+	if err != nil {
+		return nil, err // should not happen, the input is normalized
+	}
+	req.RequestURI = in.Path
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header = in.Header
+	if host := in.Header.Get("Host"); host != "" {
+		req.Host = host
+		in.Header.Del("Host")
+	}
+
+	rec := &fuzzRecorder{header: make(http.Header)}
+	rec.serve(app, req)
+
+	res := &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.status(), http.StatusText(rec.status())),
+		StatusCode:    rec.status(),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.header,
+		Body:          io.NopCloser(bytes.NewReader(rec.body.Bytes())),
+		ContentLength: int64(rec.body.Len()),
+		Request:       req,
+	}
+	switch {
+	case rec.panic != nil:
+		return res, &FuzzCrash{Input: in, Status: res.StatusCode, Panic: rec.panic, Stack: rec.stack}
+	case rec.invalid != 0:
+		return res, &FuzzCrash{Input: in, Status: rec.invalid}
+	case !IsStatusCode(res.StatusCode): // written to the underlying http.ResponseWriter directly
+		return res, &FuzzCrash{Input: in, Status: res.StatusCode}
+	}
+	return res, nil
+}
+
+// fuzzInput normalizes the fuzzed inputs to a valid request.
+func fuzzInput(method, path, header string, body []byte) FuzzInput {
+	if !isToken(method) {
+		method = fuzzMethods[len(method)%len(fuzzMethods)]
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if _, err := url.ParseRequestURI(path); err != nil || strings.IndexFunc(path, isSpaceOrCtl) >= 0 {
+		path = (&url.URL{Path: path}).EscapedPath()
+	}
+	if len(header) > FuzzMaxHeaderBytes {
+		header = header[:FuzzMaxHeaderBytes]
+	}
+	if len(body) > FuzzMaxBodyBytes {
+		body = body[:FuzzMaxBodyBytes]
+	}
+
+	h := make(http.Header)
+	for _, line := range strings.Split(header, "\n") {
+		key, val, ok := strings.Cut(strings.TrimSuffix(line, "\r"), ":")
+		val = strings.TrimSpace(val)
+		if ok && isToken(key) && isFieldValue(val) {
+			h.Add(key, val)
+		}
+	}
+	return FuzzInput{Method: method, Path: path, Header: h, Body: body}
+}
+
+func isSpaceOrCtl(r rune) bool {
+	return r <= ' ' || r == 0x7f
+}
+
+// isFieldValue reports whether s is a valid HTTP field value without the control characters.
+func isFieldValue(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < ' ' && c != '\t') || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// fuzzRecorder is a http.ResponseWriter that records the response in memory,
+// and the panic and the invalid status code of the app middlewares.
+type fuzzRecorder struct {
+	header  http.Header
+	code    int
+	invalid int // the invalid status code set by the app, it is replaced by Response.WriteHeader
+	body    bytes.Buffer
+	panic   any
+	stack   string
+}
+
+// serve serves the request by the app with the guarded middlewares.
+func (r *fuzzRecorder) serve(app *App, req *http.Request) {
+	fuzzMu.Lock()
+	defer fuzzMu.Unlock()
+	mds := app.mds
+	app.mds = middlewares{r.guard(mds)}
+	defer func() { app.mds = mds }()
+	app.ServeHTTP(r, req)
+}
+
+// guard runs the middlewares, and records the panic and the invalid status code set by them.
+func (r *fuzzRecorder) guard(mds middlewares) Middleware {
+	return func(ctx *Context) error {
+		defer func() {
+			if err := recover(); err != nil {
+				if err != http.ErrAbortHandler {
+					r.panic, r.stack = err, string(debug.Stack())
+				}
+				panic(err) // recovered and responded by app.ServeHTTP
+			}
+		}()
+		// the first "after hook" runs last, it checks the final status.
+		ctx.After(func() {
+			if !IsStatusCode(ctx.Res.status) {
+				r.invalid = ctx.Res.status
+			}
+		})
+		return mds.run(ctx)
+	}
+}
+
+func (r *fuzzRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *fuzzRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.body.Write(b)
+}
+
+func (r *fuzzRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (r *fuzzRecorder) Flush() {}
+
+func (r *fuzzRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, http.ErrNotSupported
+}
+
+func (r *fuzzRecorder) status() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+	return r.code
+}
@@ -0,0 +1,122 @@
+package gear
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fuzzUserBody struct {
+	Name string `json:"name" validate:"required,max=16"`
+	Age  int    `json:"age"`
+}
+
+func (b *fuzzUserBody) Validate() error {
+	return nil
+}
+
+type fuzzSeeds struct {
+	args [][]any
+}
+
+func (s *fuzzSeeds) Add(args ...any) {
+	s.args = append(s.args, args)
+}
+
+func newFuzzApp() *App {
+	app := New()
+	app.Set(SetLogger, log.New(io.Discard, "", 0))
+	router := NewRouter()
+	router.Get("/users/:id", func(ctx *Context) error {
+		if ctx.Param("id") == "panic" {
+			panic("boom")
+		}
+		return ctx.JSON(200, map[string]string{"id": ctx.Param("id"), "q": ctx.Query("q")})
+	})
+	router.Post("/users", func(ctx *Context) error {
+		body := fuzzUserBody{}
+		if err := ctx.ParseBody(&body); err != nil {
+			return err
+		}
+		return ctx.JSON(201, body)
+	})
+	router.Get("/status", func(ctx *Context) error {
+		return ctx.End(1000)
+	})
+	app.UseHandler(router)
+	return app
+}
+
+func TestGearFuzzServe(t *testing.T) {
+	t.Run("should normalize inputs", func(t *testing.T) {
+		assert := assert.New(t)
+
+		in := fuzzInput("GE T", "users/1 2?q=a", "Content-Type: application/json\r\nX-Bad\r\nX-Ctl: a\x00b\r\nX-Ok:  1 \r\n",
+			[]byte(strings.Repeat("a", FuzzMaxBodyBytes+1)))
+		assert.Equal(fuzzMethods[4], in.Method)
+		assert.Equal("/users/1%202%3Fq=a", in.Path)
+		assert.Equal(http.Header{"Content-Type": {"application/json"}, "X-Ok": {"1"}}, in.Header)
+		assert.Equal(FuzzMaxBodyBytes, len(in.Body))
+
+		in = fuzzInput("PATCH", "/users/1?q=a", "", nil)
+		assert.Equal("PATCH", in.Method)
+		assert.Equal("/users/1?q=a", in.Path)
+
+		in = fuzzInput("GET", "//evil.com/a%zz\x00", "", nil)
+		assert.Equal("//evil.com/a%25zz%00", in.Path)
+
+		seeds := &fuzzSeeds{}
+		FuzzSeed(seeds, FuzzInput{Method: "POST", Path: "/users", Header: http.Header{"Content-Type": {"application/json"}}})
+		assert.Equal([][]any{{"POST", "/users", "Content-Type: application/json\r\n", []byte(nil)}}, seeds.args)
+	})
+
+	t.Run("should serve requests and detect crashes", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := newFuzzApp()
+		res, err := app.FuzzServe("GET", "/users/123?q=gear", "Host: api.example.com\r\n", nil)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		body, _ := io.ReadAll(res.Body)
+		assert.Equal(`{"id":"123","q":"gear"}`, string(body))
+		assert.Equal("api.example.com", res.Request.Host)
+
+		res, err = app.FuzzServe("POST", "/users", "Content-Type: application/json\r\n", []byte(`{"name":1}`))
+		assert.Nil(err)
+		assert.Equal(400, res.StatusCode)
+
+		res, err = app.FuzzServe("GET", "/users/panic", "", nil)
+		assert.Equal(500, res.StatusCode)
+		crash, ok := err.(*FuzzCrash)
+		assert.True(ok)
+		assert.Equal("boom", crash.Panic)
+		assert.Contains(crash.Error(), "panic on GET /users/panic: boom")
+		assert.Contains(crash.Stack, "fuzz_test.go")
+
+		_, err = app.FuzzServe("GET", "/status", "", nil)
+		assert.Equal("invalid status code 1000 on GET /status", err.Error())
+	})
+}
+
+func FuzzGearApp(f *testing.F) {
+	app := newFuzzApp()
+	FuzzSeed(f,
+		FuzzInput{Method: "GET", Path: "/users/123?q=gear"},
+		FuzzInput{Method: "POST", Path: "/users", Header: http.Header{"Content-Type": {"application/json"}},
+			Body: []byte(`{"name":"gear","age":1}`)},
+		FuzzInput{Method: "POST", Path: "/users", Header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			Body: []byte(`name=gear&age=1`)},
+	)
+	f.Fuzz(func(t *testing.T, method, path, header string, body []byte) {
+		if strings.Contains(path, "panic") || strings.Contains(path, "status") {
+			t.Skip()
+		}
+		if _, err := app.FuzzServe(method, path, header, body); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
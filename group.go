@@ -0,0 +1,123 @@
+package gear
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Group manages several apps listening on different addresses (such as public, admin and metrics ports)
+// in one process. It starts them together, propagates the first fatal error,
+// and shuts all of them down gracefully when the context is done or any app fails.
+//
+//	public := gear.New()
+//	public.UseHandler(publicRouter)
+//	admin := public.Clone()
+//	admin.UseHandler(adminRouter)
+//
+//	group := gear.NewGroup().
+//		Add(public, ":3000").
+//		Add(admin, "127.0.0.1:3001")
+//	if err := group.Run(gear.ContextWithSignal(context.Background())); err != nil {
+//		log.Fatal(err)
+//	}
+type Group struct {
+	members []*groupMember
+}
+
+type groupMember struct {
+	app     *App
+	addr    string
+	l       net.Listener
+	keyPair []string
+}
+
+// NewGroup returns a Group instance.
+func NewGroup() *Group {
+	return &Group{}
+}
+
+// Add adds an app to the group, it will listen on the addr when the group runs.
+// It starts the HTTPS server if keyPair (certFile, keyFile) provided.
+func (g *Group) Add(app *App, addr string, keyPair ...string) *Group {
+	g.members = append(g.members, &groupMember{app: app, addr: addr, keyPair: keyPair})
+	return g
+}
+
+// AddListener adds an app to the group, it will accept incoming connections on the Listener l when the group runs.
+// It starts the HTTPS server if keyPair (certFile, keyFile) provided.
+func (g *Group) AddListener(app *App, l net.Listener, keyPair ...string) *Group {
+	g.members = append(g.members, &groupMember{app: app, l: l, keyPair: keyPair})
+	return g
+}
+
+// Run starts all apps of the group and blocks until the ctx is done or any app stops with an error.
+// All apps are then shut down gracefully, each with its SetGraceTimeout setting.
+// Run returns the first fatal error, or nil if the group is stopped by ctx.
+// If any address can't be listened, no app will be started.
+func (g *Group) Run(ctx context.Context) error {
+	for _, m := range g.members {
+		if err := m.app.prepare(m.app); err != nil {
+			return err
+		}
+	}
+	listeners := make([]net.Listener, len(g.members))
+	for i, m := range g.members {
+		if m.l != nil {
+			listeners[i] = m.l
+			continue
+		}
+		l, err := net.Listen("tcp", m.addr)
+		if err != nil {
+			for _, l := range listeners[:i] {
+				l.Close()
+			}
+			return Err.WithMsgf("failed to listen on %v: %v", m.addr, err)
+		}
+		listeners[i] = l
+	}
+
+	errCh := make(chan error, len(g.members))
+	var wg sync.WaitGroup
+	for i, m := range g.members {
+		wg.Add(1)
+		go func(m *groupMember, l net.Listener) {
+			defer wg.Done()
+			m.app.runReadinessGates()
+			var err error
+			if len(m.keyPair) >= 2 && m.keyPair[0] != "" && m.keyPair[1] != "" {
+				err = m.app.Server.ServeTLS(m.app.limitListener(l), m.keyPair[0], m.keyPair[1])
+			} else {
+				err = m.app.Server.Serve(m.app.limitListener(l))
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				errCh <- err
+			}
+		}(m, listeners[i])
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errCh:
+	}
+
+	var closing sync.WaitGroup
+	for _, m := range g.members {
+		closing.Add(1)
+		go func(app *App) {
+			defer closing.Done()
+			c, cancel := context.WithTimeout(context.Background(), app.settings[SetGraceTimeout].(time.Duration))
+			defer cancel()
+			if e := app.Close(c); e != nil {
+				app.Error(e)
+			}
+		}(m.app)
+	}
+	closing.Wait()
+	wg.Wait()
+	return err
+}
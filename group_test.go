@@ -0,0 +1,86 @@
+package gear
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearGroup(t *testing.T) {
+	newApp := func(name string) *App {
+		app := New()
+		app.Use(func(ctx *Context) error {
+			return ctx.HTML(200, name)
+		})
+		return app
+	}
+
+	t.Run("should run and shutdown apps together", func(t *testing.T) {
+		assert := assert.New(t)
+
+		l1, _ := net.Listen("tcp", "127.0.0.1:0")
+		l2, _ := net.Listen("tcp", "127.0.0.1:0")
+		public, admin := newApp("public"), newApp("admin")
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- NewGroup().AddListener(public, l1).AddListener(admin, l2).Run(ctx)
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		for l, name := range map[net.Listener]string{l1: "public", l2: "admin"} {
+			res, err := RequestBy("GET", "http://"+l.Addr().String())
+			assert.Nil(err)
+			assert.Equal(200, res.StatusCode)
+			assert.Equal(name, PickRes(res.Text()).(string))
+		}
+
+		cancel()
+		select {
+		case err := <-done:
+			assert.Nil(err)
+		case <-time.After(time.Second):
+			t.Error("group should stop")
+		}
+		_, err := RequestBy("GET", "http://"+l1.Addr().String())
+		assert.NotNil(err)
+	})
+
+	t.Run("should not start if failed to listen", func(t *testing.T) {
+		assert := assert.New(t)
+
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		defer l.Close()
+		err := NewGroup().
+			Add(newApp("public"), "127.0.0.1:0").
+			Add(newApp("admin"), l.Addr().String()).
+			Run(context.Background())
+		assert.NotNil(err)
+		assert.Contains(err.Error(), "failed to listen on "+l.Addr().String())
+	})
+
+	t.Run("should propagate the first fatal error", func(t *testing.T) {
+		assert := assert.New(t)
+
+		l1, _ := net.Listen("tcp", "127.0.0.1:0")
+		l2, _ := net.Listen("tcp", "127.0.0.1:0")
+		l2.Close()
+		public := newApp("public")
+		done := make(chan error)
+		go func() {
+			done <- NewGroup().AddListener(public, l1).AddListener(newApp("admin"), l2).Run(context.Background())
+		}()
+
+		select {
+		case err := <-done:
+			assert.NotNil(err)
+		case <-time.After(time.Second):
+			t.Error("group should stop")
+		}
+		_, err := RequestBy("GET", "http://"+l1.Addr().String())
+		assert.NotNil(err)
+	})
+}
@@ -0,0 +1,388 @@
+package gear
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HealthOptions is options for NewHealth.
+type HealthOptions struct {
+	// LivenessPath defines the liveness endpoint.
+	// Optional. Default to "/healthz".
+	LivenessPath string
+	// ReadinessPath defines the readiness endpoint.
+	// Optional. Default to "/readyz".
+	ReadinessPath string
+	// Timeout is the timeout for running all checkers of an endpoint request.
+	// Optional. Default to 5 seconds.
+	Timeout time.Duration
+}
+
+// HealthCheck is the result of a checker in the health endpoints response.
+type HealthCheck struct {
+	Status string `json:"status"` // "ok" or "fail"
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthStatus is the response of the health endpoints.
+type HealthStatus struct {
+	Status string                  `json:"status"` // "ok" or "fail"
+	Checks map[string]*HealthCheck `json:"checks"`
+}
+
+// Health serves the liveness and readiness endpoints with registered checkers, see NewHealth.
+type Health struct {
+	opts      HealthOptions
+	mu        sync.RWMutex
+	liveness  map[string]func(context.Context) error
+	readiness map[string]func(context.Context) error
+}
+
+// NewHealth returns a Health handler that serves the liveness endpoint ("/healthz") and the readiness endpoint
+// ("/readyz"). They run the registered checkers concurrently, and respond the aggregated HealthStatus as JSON
+// with status 200 if all checkers passed, or 503 otherwise.
+//
+// The liveness endpoint runs the liveness checkers only. The readiness endpoint runs the liveness and readiness
+// checkers, and reports the readiness gates of the app (see app.AddReadinessGate) as the checks too, it fails if any
+// gate is pending, or the app is closing by app.Close, so the load balancers can stop routing traffic to it during
+// graceful shutdown. The SetReadinessPath setting serves the same report of the readiness gates without a Health.
+//
+//	health := gear.NewHealth()
+//	health.AddReadinessCheck("db", func(ctx context.Context) error {
+//		return db.PingContext(ctx)
+//	})
+//
+//	app := gear.New()
+//	app.UseHandler(health) // should be used before other middlewares, such as auth.
+//	app.UseHandler(router)
+func NewHealth(options ...HealthOptions) *Health {
+	opts := HealthOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.LivenessPath == "" {
+		opts.LivenessPath = "/healthz"
+	}
+	if opts.ReadinessPath == "" {
+		opts.ReadinessPath = "/readyz"
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	return &Health{
+		opts:      opts,
+		liveness:  make(map[string]func(context.Context) error),
+		readiness: make(map[string]func(context.Context) error),
+	}
+}
+
+// AddLivenessCheck adds a named checker to the liveness and readiness endpoints.
+// It panics if name is empty, fn is nil or the name added already.
+func (h *Health) AddLivenessCheck(name string, fn func(ctx context.Context) error) *Health {
+	h.add(h.liveness, name, fn)
+	return h
+}
+
+// AddReadinessCheck adds a named checker, such as a dependency ping, to the readiness endpoint.
+// It panics if name is empty, fn is nil or the name added already.
+func (h *Health) AddReadinessCheck(name string, fn func(ctx context.Context) error) *Health {
+	h.add(h.readiness, name, fn)
+	return h
+}
+
+func (h *Health) add(checkers map[string]func(context.Context) error, name string, fn func(context.Context) error) {
+	if name == "" || fn == nil {
+		panic(Err.WithMsg("invalid health checker"))
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok1 := h.liveness[name]
+	_, ok2 := h.readiness[name]
+	if ok1 || ok2 {
+		panic(Err.WithMsgf("health checker %q added already", name))
+	}
+	checkers[name] = fn
+}
+
+// Serve implemented gear.Handler interface.
+func (h *Health) Serve(ctx *Context) error {
+	if ctx.Method != http.MethodGet && ctx.Method != http.MethodHead {
+		return nil
+	}
+	switch ctx.Path {
+	case h.opts.LivenessPath:
+		return respondHealth(ctx, h.Check(ctx, false))
+	case h.opts.ReadinessPath:
+		status := h.Check(ctx, true)
+		gates := ctx.app.readinessStatus()
+		if gates.Status != "ok" {
+			status.Status = "fail"
+		}
+		for name, check := range gates.Checks {
+			status.Checks[name] = check
+		}
+		return respondHealth(ctx, status)
+	}
+	return nil
+}
+
+// Check runs the liveness checkers, and the readiness checkers if readiness is true, returns the aggregated status.
+func (h *Health) Check(ctx context.Context, readiness bool) *HealthStatus {
+	h.mu.RLock()
+	checkers := make(map[string]func(context.Context) error, len(h.liveness)+len(h.readiness))
+	for name, fn := range h.liveness {
+		checkers[name] = fn
+	}
+	if readiness {
+		for name, fn := range h.readiness {
+			checkers[name] = fn
+		}
+	}
+	h.mu.RUnlock()
+
+	c, cancel := context.WithTimeout(ctx, h.opts.Timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	status := &HealthStatus{Status: "ok", Checks: make(map[string]*HealthCheck, len(checkers))}
+	for name, fn := range checkers {
+		wg.Add(1)
+		go func(name string, fn func(context.Context) error) {
+			defer wg.Done()
+			check := &HealthCheck{Status: "ok"}
+			if err := runHealthCheck(c, fn); err != nil {
+				check.Status = "fail"
+				check.Error = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if check.Status != "ok" {
+				status.Status = "fail"
+			}
+			status.Checks[name] = check
+		}(name, fn)
+	}
+	wg.Wait()
+	return status
+}
+
+// runHealthCheck runs the checker, returns the ctx's error if the checker doesn't return in time.
+func runHealthCheck(ctx context.Context, fn func(context.Context) error) error {
+	ch := make(chan error, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				ch <- ErrorWithStack(err)
+			}
+		}()
+		ch <- fn(ctx)
+	}()
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func respondHealth(ctx *Context, status *HealthStatus) error {
+	ctx.SetHeader(HeaderCacheControl, "no-store")
+	if status.Status != "ok" {
+		return ctx.JSON(http.StatusServiceUnavailable, status)
+	}
+	return ctx.JSON(http.StatusOK, status)
+}
+
+// readiness holds the readiness gates of an app, they are reported by the readiness endpoints
+// of SetReadinessPath setting and NewHealth.
+type readiness struct {
+	mu      sync.Mutex
+	once    sync.Once
+	started bool
+	names   []string
+	gates   map[string]func(context.Context) error
+	pending map[string]error // the pending gates with their last errors
+}
+
+// AddReadinessGate adds a named readiness gate to the app, such as warming up caches or DB pools.
+// The gates are evaluated concurrently when the app starts serving (by app.Listen, app.ListenTLS,
+// app.ListenWithContext, app.ServeWithContext, app.Start, Group.Run, or the first request otherwise).
+// A failed gate is logged by app.Error and retried with backoff (from 100ms up to 5s) until it passes
+// or the server shuts down. The app is ready after all gates passed, see app.Ready.
+//
+// The readiness can be served on the path of SetReadinessPath setting, and requests to other paths
+// can be rejected with 503 until ready by SetBlockUntilReady setting:
+//
+//	app := gear.New()
+//	app.Set(gear.SetReadinessPath, "/readyz")
+//	app.Set(gear.SetBlockUntilReady, true)
+//	app.AddReadinessGate("db", func(ctx context.Context) error {
+//		return db.PingContext(ctx)
+//	})
+//
+// It panics if name is empty, fn is nil or the name added already, or the app has started serving.
+// The gates are inherited by app.Clone, and evaluated independently by each app.
+func (app *App) AddReadinessGate(name string, fn func(ctx context.Context) error) *App {
+	if name == "" || fn == nil {
+		panic(Err.WithMsg("invalid readiness gate"))
+	}
+	if app.readiness == nil {
+		app.readiness = &readiness{
+			gates:   make(map[string]func(context.Context) error),
+			pending: make(map[string]error),
+		}
+	}
+	r := app.readiness
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.gates[name]; ok {
+		panic(Err.WithMsgf("readiness gate %q added already", name))
+	}
+	if r.started {
+		panic(Err.WithMsgf("can't add readiness gate %q after app started", name))
+	}
+	r.names = append(r.names, name)
+	r.gates[name] = fn
+	r.pending[name] = nil
+	return app
+}
+
+// clone returns a new readiness with the same gates, all of them are pending.
+func (r *readiness) clone() *readiness {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := &readiness{
+		names:   append([]string(nil), r.names...),
+		gates:   make(map[string]func(context.Context) error, len(r.gates)),
+		pending: make(map[string]error, len(r.gates)),
+	}
+	for name, fn := range r.gates {
+		c.gates[name] = fn
+		c.pending[name] = nil
+	}
+	return c
+}
+
+// Ready returns true if all readiness gates of the app passed, and the names of the pending gates otherwise.
+// It returns true if there is no readiness gate.
+func (app *App) Ready() (bool, []string) {
+	if app.readiness == nil {
+		return true, nil
+	}
+	r := app.readiness
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) == 0 {
+		return true, nil
+	}
+	pending := make([]string, 0, len(r.pending))
+	for name := range r.pending {
+		pending = append(pending, name)
+	}
+	sort.Strings(pending)
+	return false, pending
+}
+
+// runReadinessGates starts evaluating the readiness gates once.
+func (app *App) runReadinessGates() {
+	if app.readiness == nil {
+		return
+	}
+	r := app.readiness
+	r.once.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		app.Server.RegisterOnShutdown(cancel)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.started = true
+		for _, name := range r.names {
+			go app.runReadinessGate(ctx, name, r.gates[name])
+		}
+	})
+}
+
+func (app *App) runReadinessGate(ctx context.Context, name string, fn func(context.Context) error) {
+	backoff := 100 * time.Millisecond
+	for {
+		err := fn(ctx)
+		if err == nil {
+			r := app.readiness
+			r.mu.Lock()
+			delete(r.pending, name)
+			r.mu.Unlock()
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		r := app.readiness
+		r.mu.Lock()
+		r.pending[name] = err
+		r.mu.Unlock()
+		app.Error(Err.WithMsgf("readiness gate %q failed: %v", name, err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > 5*time.Second {
+			backoff = 5 * time.Second
+		}
+	}
+}
+
+// readinessStatus returns the status of the readiness gates, it fails if any gate is pending or the app is closing.
+func (app *App) readinessStatus() *HealthStatus {
+	status := &HealthStatus{Status: "ok", Checks: make(map[string]*HealthCheck)}
+	if r := app.readiness; r != nil {
+		r.mu.Lock()
+		for _, name := range r.names {
+			check := &HealthCheck{Status: "ok"}
+			if err, ok := r.pending[name]; ok {
+				check.Status = "fail"
+				check.Error = "readiness gate is pending"
+				if err != nil {
+					check.Error = err.Error()
+				}
+				status.Status = "fail"
+			}
+			status.Checks[name] = check
+		}
+		r.mu.Unlock()
+	}
+	if app.closing.Load() {
+		status.Status = "fail"
+		status.Checks["app"] = &HealthCheck{Status: "fail", Error: "app is closing"}
+	}
+	return status
+}
+
+// checkReadiness responds the readiness on SetReadinessPath,
+// or rejects the request with 503 if SetBlockUntilReady and the app is not ready.
+func (app *App) checkReadiness(ctx *Context) error {
+	app.runReadinessGates()
+	path, _ := app.settings[SetReadinessPath].(string)
+	block, _ := app.settings[SetBlockUntilReady].(bool)
+	if path == "" && !block {
+		return nil
+	}
+
+	if path != "" && ctx.Path == path {
+		status := app.readinessStatus()
+		if status.Status != "ok" {
+			ctx.SetHeader(HeaderRetryAfter, "1")
+		}
+		return respondHealth(ctx, status)
+	}
+	if ready, _ := app.Ready(); block && !ready {
+		ctx.SetHeader(HeaderRetryAfter, "1")
+		return ErrServiceUnavailable.WithMsg("service is not ready")
+	}
+	return nil
+}
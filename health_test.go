@@ -0,0 +1,269 @@
+package gear
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearHealth(t *testing.T) {
+	t.Run("should panic with invalid checker", func(t *testing.T) {
+		assert := assert.New(t)
+
+		h := NewHealth()
+		fn := func(ctx context.Context) error { return nil }
+		assert.Panics(func() { h.AddLivenessCheck("", fn) })
+		assert.Panics(func() { h.AddReadinessCheck("db", nil) })
+		h.AddLivenessCheck("db", fn)
+		assert.Panics(func() { h.AddReadinessCheck("db", fn) })
+	})
+
+	t.Run("should serve liveness and readiness endpoints", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var dbErr error
+		h := NewHealth(HealthOptions{Timeout: 50 * time.Millisecond})
+		h.AddLivenessCheck("self", func(ctx context.Context) error { return nil })
+		h.AddReadinessCheck("db", func(ctx context.Context) error { return dbErr })
+		app := New()
+		app.UseHandler(h)
+		app.Use(func(ctx *Context) error {
+			return ctx.HTML(200, "OK")
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("GET", host+"/healthz")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("no-store", res.Header.Get(HeaderCacheControl))
+		assert.Equal(`{"status":"ok","checks":{"self":{"status":"ok"}}}`, PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host+"/readyz")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(`{"status":"ok","checks":{"db":{"status":"ok"},"self":{"status":"ok"}}}`, PickRes(res.Text()).(string))
+
+		dbErr = errors.New("connection refused")
+		res, err = RequestBy("GET", host+"/readyz")
+		assert.Nil(err)
+		assert.Equal(503, res.StatusCode)
+		assert.Equal(`{"status":"fail","checks":{"db":{"status":"fail","error":"connection refused"},"self":{"status":"ok"}}}`,
+			PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host+"/healthz")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		res.Body.Close()
+
+		res, err = RequestBy("GET", host+"/other")
+		assert.Nil(err)
+		assert.Equal("OK", PickRes(res.Text()).(string))
+	})
+
+	t.Run("should fail with timeout and panic", func(t *testing.T) {
+		assert := assert.New(t)
+
+		h := NewHealth(HealthOptions{Timeout: 20 * time.Millisecond})
+		h.AddLivenessCheck("slow", func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		})
+		h.AddLivenessCheck("panic", func(ctx context.Context) error {
+			panic("some error")
+		})
+		status := h.Check(context.Background(), false)
+		assert.Equal("fail", status.Status)
+		assert.Equal("context deadline exceeded", status.Checks["slow"].Error)
+		assert.Contains(status.Checks["panic"].Error, "some error")
+	})
+
+	t.Run("should fail readiness with pending gates and closing app", func(t *testing.T) {
+		assert := assert.New(t)
+
+		gate := make(chan struct{})
+		app := New()
+		app.AddReadinessGate("cache", func(ctx context.Context) error {
+			select {
+			case <-gate:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		app.UseHandler(NewHealth(HealthOptions{ReadinessPath: "/ready"}))
+		app.runReadinessGates()
+
+		req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+		res := httptest.NewRecorder()
+		app.ServeHTTP(res, req)
+		assert.Equal(503, res.Code)
+		assert.Equal(`{"status":"fail","checks":{"cache":{"status":"fail","error":"readiness gate is pending"}}}`, res.Body.String())
+
+		close(gate)
+		time.Sleep(10 * time.Millisecond)
+		res = httptest.NewRecorder()
+		app.ServeHTTP(res, req)
+		assert.Equal(200, res.Code)
+		assert.Equal(`{"status":"ok","checks":{"cache":{"status":"ok"}}}`, res.Body.String())
+
+		assert.Nil(app.Close(context.Background()))
+		res = httptest.NewRecorder()
+		app.ServeHTTP(res, req)
+		assert.Equal(503, res.Code)
+		assert.Equal(`{"status":"fail","checks":{"app":{"status":"fail","error":"app is closing"},"cache":{"status":"ok"}}}`,
+			res.Body.String())
+
+		res = httptest.NewRecorder()
+		app.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		assert.Equal(200, res.Code)
+	})
+}
+
+func TestGearAppReadinessGate(t *testing.T) {
+	t.Run("should panic with invalid gate", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		fn := func(ctx context.Context) error { return nil }
+		assert.Panics(func() { app.AddReadinessGate("", fn) })
+		assert.Panics(func() { app.AddReadinessGate("db", nil) })
+		app.AddReadinessGate("db", fn)
+		assert.Panics(func() { app.AddReadinessGate("db", fn) })
+		assert.Panics(func() { app.Set(SetReadinessPath, true) })
+		assert.Panics(func() { app.Set(SetBlockUntilReady, "true") })
+
+		srv := app.Start()
+		defer srv.Close()
+		assert.Panics(func() { app.AddReadinessGate("cache", fn) })
+	})
+
+	t.Run("should be ready without gates", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Set(SetReadinessPath, "/readyz")
+		app.Set(SetBlockUntilReady, true)
+		app.Use(func(ctx *Context) error {
+			return ctx.HTML(200, "OK")
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		ready, pending := app.Ready()
+		assert.True(ready)
+		assert.Nil(pending)
+
+		res, err := RequestBy("GET", host+"/readyz")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(`{"status":"ok","checks":{}}`, PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("OK", PickRes(res.Text()).(string))
+	})
+
+	t.Run("should wait for gates", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var calls int32
+		warm := make(chan struct{})
+		app := New()
+		app.Set(SetLogger, log.New(io.Discard, "", 0))
+		app.Set(SetReadinessPath, "/readyz")
+		app.Set(SetBlockUntilReady, true)
+		app.AddReadinessGate("db", func(ctx context.Context) error {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				return errors.New("connection refused")
+			}
+			return nil
+		})
+		app.AddReadinessGate("cache", func(ctx context.Context) error {
+			<-warm
+			return nil
+		})
+		app.Use(func(ctx *Context) error {
+			return ctx.HTML(200, "OK")
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		time.Sleep(20 * time.Millisecond) // the db gate failed once, and retries after 100ms
+		res, err := RequestBy("GET", host+"/readyz")
+		assert.Nil(err)
+		assert.Equal(503, res.StatusCode)
+		assert.Equal("1", res.Header.Get(HeaderRetryAfter))
+		assert.Equal(`{"status":"fail","checks":{"cache":{"status":"fail","error":"readiness gate is pending"},`+
+			`"db":{"status":"fail","error":"connection refused"}}}`, PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host)
+		assert.Nil(err)
+		assert.Equal(503, res.StatusCode)
+		assert.Equal("1", res.Header.Get(HeaderRetryAfter))
+		assert.Equal(`{"error":"ServiceUnavailable","message":"service is not ready"}`, PickRes(res.Text()).(string))
+
+		time.Sleep(150 * time.Millisecond)
+		ready, pending := app.Ready()
+		assert.False(ready)
+		assert.Equal([]string{"cache"}, pending)
+		assert.Equal(int32(2), atomic.LoadInt32(&calls))
+
+		close(warm)
+		time.Sleep(10 * time.Millisecond)
+		ready, _ = app.Ready()
+		assert.True(ready)
+
+		res, err = RequestBy("GET", host+"/readyz")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(`{"status":"ok","checks":{"cache":{"status":"ok"},"db":{"status":"ok"}}}`, PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("OK", PickRes(res.Text()).(string))
+	})
+
+	t.Run("should serve other paths if not block", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Set(SetReadinessPath, "/readyz")
+		app.AddReadinessGate("db", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		app.Use(func(ctx *Context) error {
+			return ctx.HTML(200, "OK")
+		})
+		srv := app.Start()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("GET", host+"/readyz")
+		assert.Nil(err)
+		assert.Equal(503, res.StatusCode)
+		res.Body.Close()
+
+		res, err = RequestBy("GET", host)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("OK", PickRes(res.Text()).(string))
+
+		// cancel the gates
+		assert.Nil(app.Close(context.Background()))
+		srv.Close()
+	})
+}
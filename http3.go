@@ -0,0 +1,67 @@
+package gear
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// HTTP3Server is the HTTP/3 (QUIC) server to serve the app, such as the *http3.Server of
+// github.com/quic-go/quic-go/http3. It is created by the SetHTTP3Server setting for app.ListenHTTP3.
+type HTTP3Server interface {
+	ListenAndServeTLS(certFile, keyFile string) error
+	Close() error
+}
+
+// ListenHTTP3 starts the HTTPS server and the HTTP/3 server on the same address (TCP and UDP) with the
+// same app handler, the HTTPS responses advertise the HTTP/3 endpoint by the Alt-Svc header, so the clients
+// can switch to QUIC. The HTTP/3 server is created by the SetHTTP3Server setting, gear doesn't depend on
+// a QUIC implementation. It returns when either of the servers stopped, and the other one is closed.
+//
+//	app := gear.New()
+//	app.Set(gear.SetHTTP3Server, func(addr string, handler http.Handler) gear.HTTP3Server {
+//		return &http3.Server{Addr: addr, Handler: handler}
+//	})
+//	app.Error(app.ListenHTTP3(":443", "cert.pem", "key.pem"))
+func (app *App) ListenHTTP3(addr, certFile, keyFile string) error {
+	if app.newH3 == nil {
+		return Err.WithMsg("SetHTTP3Server setting is required by ListenHTTP3")
+	}
+	if addr == "" {
+		addr = ":https"
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	p, err := net.LookupPort("udp", port)
+	if err != nil {
+		return err
+	}
+
+	h3 := app.newH3(addr, app)
+	app.h3.Store(&h3)
+	altSvc := fmt.Sprintf(`h3=":%d"; ma=86400`, p)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderAltSvc, altSvc)
+		app.ServeHTTP(w, r)
+	})
+	if err := app.prepare(handler); err != nil {
+		return err
+	}
+
+	errc := make(chan error, 2)
+	go func() {
+		errc <- h3.ListenAndServeTLS(certFile, keyFile)
+	}()
+	go func() {
+		errc <- app.listenTLS(addr, certFile, keyFile)
+	}()
+
+	err = <-errc
+	if !app.closing.Load() {
+		app.Close() // one of the servers stopped, close the other one
+		<-errc
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package gear
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeHTTP3Server struct {
+	addr    string
+	handler http.Handler
+	err     error
+	once    sync.Once
+	closed  chan struct{}
+}
+
+func (s *fakeHTTP3Server) ListenAndServeTLS(certFile, keyFile string) error {
+	if s.err != nil {
+		return s.err
+	}
+	<-s.closed
+	return http.ErrServerClosed
+}
+
+func (s *fakeHTTP3Server) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+func TestGearAppListenHTTP3(t *testing.T) {
+	assert.Panics(t, func() {
+		New().Set(SetHTTP3Server, func(addr string) HTTP3Server { return nil })
+	})
+
+	t.Run("should require SetHTTP3Server", func(t *testing.T) {
+		assert := assert.New(t)
+
+		err := New().ListenHTTP3("127.0.0.1:13334", "./testdata/out/test.crt", "./testdata/out/test.key")
+		assert.Equal("Error: SetHTTP3Server setting is required by ListenHTTP3", err.Error())
+	})
+
+	t.Run("should serve HTTPS with Alt-Svc header", func(t *testing.T) {
+		assert := assert.New(t)
+
+		h3 := &fakeHTTP3Server{closed: make(chan struct{})}
+		app := New()
+		app.Set(SetHTTP3Server, func(addr string, handler http.Handler) HTTP3Server {
+			h3.addr, h3.handler = addr, handler
+			return h3
+		})
+		app.Use(func(ctx *Context) error {
+			if ctx.Path == "/error" {
+				return ErrBadRequest
+			}
+			return ctx.HTML(200, ctx.Req.Proto)
+		})
+
+		errc := make(chan error, 1)
+		go func() {
+			errc <- app.ListenHTTP3("127.0.0.1:13334", "./testdata/out/test.crt", "./testdata/out/test.key")
+		}()
+		time.Sleep(100 * time.Millisecond)
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+		res, err := client.Get("https://127.0.0.1:13334/")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(`h3=":13334"; ma=86400`, res.Header.Get(HeaderAltSvc))
+		res.Body.Close()
+
+		res, err = client.Get("https://127.0.0.1:13334/error")
+		assert.Nil(err)
+		assert.Equal(400, res.StatusCode)
+		assert.Equal(`h3=":13334"; ma=86400`, res.Header.Get(HeaderAltSvc))
+		res.Body.Close()
+
+		assert.Nil(app.Close())
+		assert.Equal(http.ErrServerClosed, <-errc)
+		<-h3.closed
+		assert.Equal("127.0.0.1:13334", h3.addr)
+		assert.True(h3.handler == app)
+	})
+
+	t.Run("should close HTTPS server if HTTP/3 server failed", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Set(SetHTTP3Server, func(addr string, handler http.Handler) HTTP3Server {
+			return &fakeHTTP3Server{err: errors.New("udp failed"), closed: make(chan struct{})}
+		})
+		err := app.ListenHTTP3("127.0.0.1:13334", "./testdata/out/test.crt", "./testdata/out/test.key")
+		assert.Equal("udp failed", err.Error())
+		assert.True(app.closing.Load())
+	})
+
+	t.Run("should close HTTP/3 server if HTTPS server failed", func(t *testing.T) {
+		assert := assert.New(t)
+
+		h3 := &fakeHTTP3Server{closed: make(chan struct{})}
+		app := New()
+		app.Set(SetHTTP3Server, func(addr string, handler http.Handler) HTTP3Server {
+			return h3
+		})
+		err := app.ListenHTTP3("127.0.0.1:13334", "", "")
+		assert.NotNil(err)
+		<-h3.closed
+
+		assert.NotNil(app.ListenHTTP3("127.0.0.1", "", ""))
+	})
+}
@@ -0,0 +1,232 @@
+package gear
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// JSONAPIDocument represents a JSON:API top-level document.
+// https://jsonapi.org/format/#document-top-level
+type JSONAPIDocument struct {
+	Data     any                `json:"data,omitempty"` // *JSONAPIResource or []*JSONAPIResource
+	Errors   []JSONAPIError     `json:"errors,omitempty"`
+	Included []*JSONAPIResource `json:"included,omitempty"`
+	Links    map[string]string  `json:"links,omitempty"`
+	Meta     any                `json:"meta,omitempty"`
+}
+
+// JSONAPIResource represents a JSON:API resource object.
+// https://jsonapi.org/format/#document-resource-objects
+type JSONAPIResource struct {
+	Type          string            `json:"type"`
+	ID            string            `json:"id,omitempty"`
+	Attributes    any               `json:"attributes,omitempty"`
+	Relationships map[string]any    `json:"relationships,omitempty"`
+	Links         map[string]string `json:"links,omitempty"`
+	Meta          any               `json:"meta,omitempty"`
+}
+
+// JSONAPIError represents a JSON:API error object.
+// https://jsonapi.org/format/#error-objects
+type JSONAPIError struct {
+	Status string `json:"status,omitempty"`
+	Code   string `json:"code,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	Meta   any    `json:"meta,omitempty"`
+}
+
+// ToJSONAPIError convert a error to JSONAPIError instance.
+func ToJSONAPIError(e error) JSONAPIError {
+	err := Err.From(e)
+	return JSONAPIError{
+		Status: strconv.Itoa(err.Code),
+		Code:   err.Err,
+		Title:  http.StatusText(err.Code),
+		Detail: err.Msg,
+		Meta:   err.Data,
+	}
+}
+
+// JSONAPI sends a JSON:API document with status code to response.
+// The val can be a JSONAPIDocument, an error, or the primary data (such as *JSONAPIResource
+// or []*JSONAPIResource) that will be wrapped as {"data": val}.
+// An error will be sent as {"errors": [...]} with its status code if code is not a error code.
+// It will end the ctx. The middlewares after current middleware will not run.
+// "after hooks" (if no error) and "end hooks" will run normally.
+//
+//	ctx.JSONAPI(http.StatusOK, &gear.JSONAPIResource{
+//		Type:       "articles",
+//		ID:         "1",
+//		Attributes: article,
+//		Links:      map[string]string{"self": "/articles/1"},
+//	})
+func (ctx *Context) JSONAPI(code int, val any) error {
+	var doc any
+	switch v := val.(type) {
+	case JSONAPIDocument, *JSONAPIDocument:
+		doc = v
+	case error:
+		if code < 400 {
+			code = ParseError(v).Status()
+		}
+		doc = JSONAPIDocument{Errors: []JSONAPIError{ToJSONAPIError(v)}}
+	default:
+		doc = JSONAPIDocument{Data: v}
+	}
+
+	buf, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	ctx.Type(MIMEApplicationJSONAPI)
+	return ctx.End(code, buf)
+}
+
+// HALLink represents a HAL link object.
+// https://tools.ietf.org/html/draft-kelly-json-hal-08#section-5
+type HALLink struct {
+	Href      string `json:"href"`
+	Templated bool   `json:"templated,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Title     string `json:"title,omitempty"`
+}
+
+// HALResource is a builder for HAL resource object. The Data will be marshaled as
+// a JSON object, and "_links", "_embedded" will be added to it.
+//
+//	res := gear.NewHAL(order).
+//		Link("self", "/orders/123").
+//		Link("customer", "/customers/7809").
+//		Embed("items", gear.NewHAL(item1).Link("self", "/items/1"), gear.NewHAL(item2).Link("self", "/items/2"))
+//	ctx.HAL(http.StatusOK, res)
+type HALResource struct {
+	Data     any
+	links    map[string][]HALLink
+	embedded map[string]any
+}
+
+// NewHAL creates a HALResource with data. The data should be marshaled as a JSON object, or be nil.
+func NewHAL(data any) *HALResource {
+	return &HALResource{Data: data}
+}
+
+// Link adds a link with href to the resource, a relation with more than one links
+// will be marshaled as an array.
+func (r *HALResource) Link(rel, href string) *HALResource {
+	return r.AddLink(rel, HALLink{Href: href})
+}
+
+// AddLink adds a HALLink to the resource.
+func (r *HALResource) AddLink(rel string, link HALLink) *HALResource {
+	if r.links == nil {
+		r.links = make(map[string][]HALLink)
+	}
+	r.links[rel] = append(r.links[rel], link)
+	return r
+}
+
+// Embed embeds resources to the resource. One resource will be marshaled as an object,
+// otherwise as an array. Use EmbedList to always marshal as an array.
+func (r *HALResource) Embed(rel string, resources ...*HALResource) *HALResource {
+	if len(resources) == 1 {
+		return r.embed(rel, resources[0])
+	}
+	return r.embed(rel, resources)
+}
+
+// EmbedList embeds resources to the resource as an array.
+func (r *HALResource) EmbedList(rel string, resources []*HALResource) *HALResource {
+	if resources == nil {
+		resources = []*HALResource{}
+	}
+	return r.embed(rel, resources)
+}
+
+func (r *HALResource) embed(rel string, val any) *HALResource {
+	if r.embedded == nil {
+		r.embedded = make(map[string]any)
+	}
+	r.embedded[rel] = val
+	return r
+}
+
+// MarshalJSON implemented json.Marshaler interface.
+func (r *HALResource) MarshalJSON() ([]byte, error) {
+	obj := make(map[string]any)
+	if r.Data != nil {
+		buf, err := json.Marshal(r.Data)
+		if err != nil {
+			return nil, err
+		}
+		var data any
+		if err = unmarshalJSONDoc(buf, &data); err != nil {
+			return nil, err
+		}
+		if data != nil {
+			m, ok := data.(map[string]any)
+			if !ok {
+				return nil, Err.WithMsgf("HAL resource data should be a JSON object, got %T", r.Data)
+			}
+			obj = m
+		}
+	}
+
+	if len(r.links) > 0 {
+		links := make(map[string]any, len(r.links))
+		for rel, l := range r.links {
+			if len(l) == 1 {
+				links[rel] = l[0]
+			} else {
+				links[rel] = l
+			}
+		}
+		obj["_links"] = links
+	}
+	if len(r.embedded) > 0 {
+		obj["_embedded"] = r.embedded
+	}
+	return json.Marshal(obj)
+}
+
+// HAL sends a HAL resource with status code to response.
+// It will end the ctx. The middlewares after current middleware will not run.
+// "after hooks" (if no error) and "end hooks" will run normally.
+func (ctx *Context) HAL(code int, res *HALResource) error {
+	buf, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	ctx.Type(MIMEApplicationHALJSON)
+	return ctx.End(code, buf)
+}
+
+// JSONAPISender is a Sender that sends data with ctx.JSONAPI.
+//
+//	app.Set(gear.SetSender, gear.JSONAPISender{})
+type JSONAPISender struct{}
+
+// Send implemented Sender interface.
+func (s JSONAPISender) Send(ctx *Context, code int, data any) error {
+	return ctx.JSONAPI(code, data)
+}
+
+// HALSender is a Sender that sends data with ctx.HAL, data that is not a *HALResource
+// will be wrapped by gear.NewHAL, and error will be sent by ctx.Error.
+//
+//	app.Set(gear.SetSender, gear.HALSender{})
+type HALSender struct{}
+
+// Send implemented Sender interface.
+func (s HALSender) Send(ctx *Context, code int, data any) error {
+	switch v := data.(type) {
+	case *HALResource:
+		return ctx.HAL(code, v)
+	case error:
+		return ctx.Error(v)
+	default:
+		return ctx.HAL(code, NewHAL(data))
+	}
+}
@@ -0,0 +1,137 @@
+package gear
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type hypermediaArticle struct {
+	Title string `json:"title"`
+}
+
+func TestGearContextJSONAPI(t *testing.T) {
+	assert := assert.New(t)
+
+	app := New()
+	app.Use(func(ctx *Context) error {
+		switch ctx.Path {
+		case "/resource":
+			return ctx.JSONAPI(http.StatusOK, &JSONAPIResource{
+				Type:       "articles",
+				ID:         "1",
+				Attributes: hypermediaArticle{Title: "Gear"},
+				Links:      map[string]string{"self": "/articles/1"},
+			})
+		case "/document":
+			return ctx.JSONAPI(http.StatusOK, JSONAPIDocument{
+				Data: []*JSONAPIResource{},
+				Meta: map[string]int{"total": 0},
+			})
+		case "/error":
+			return ctx.JSONAPI(0, ErrNotFound.WithMsg("article not found"))
+		default:
+			return ctx.JSONAPI(http.StatusOK, func() {})
+		}
+	})
+
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	res, err := RequestBy("GET", host+"/resource")
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal(MIMEApplicationJSONAPI, res.Header.Get(HeaderContentType))
+	assert.Equal(`{"data":{"type":"articles","id":"1","attributes":{"title":"Gear"},"links":{"self":"/articles/1"}}}`,
+		PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host+"/document")
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal(`{"data":[],"meta":{"total":0}}`, PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host+"/error")
+	assert.Nil(err)
+	assert.Equal(404, res.StatusCode)
+	assert.Equal(MIMEApplicationJSONAPI, res.Header.Get(HeaderContentType))
+	assert.Equal(`{"errors":[{"status":"404","code":"NotFound","title":"Not Found","detail":"article not found"}]}`,
+		PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host)
+	assert.Nil(err)
+	assert.Equal(500, res.StatusCode)
+}
+
+func TestGearHALResource(t *testing.T) {
+	t.Run("should marshal", func(t *testing.T) {
+		assert := assert.New(t)
+
+		res := NewHAL(hypermediaArticle{Title: "Gear"}).
+			Link("self", "/articles/1").
+			Link("tags", "/tags/1").
+			Link("tags", "/tags/2").
+			AddLink("find", HALLink{Href: "/articles{?id}", Templated: true}).
+			Embed("author", NewHAL(map[string]string{"name": "gear"}).Link("self", "/users/1")).
+			Embed("comments", NewHAL(nil), NewHAL(nil)).
+			EmbedList("likes", nil)
+
+		buf, err := res.MarshalJSON()
+		assert.Nil(err)
+		assert.Equal(`{"_embedded":{"author":{"_links":{"self":{"href":"/users/1"}},"name":"gear"},"comments":[{},{}],"likes":[]},`+
+			`"_links":{"find":{"href":"/articles{?id}","templated":true},"self":{"href":"/articles/1"},"tags":[{"href":"/tags/1"},{"href":"/tags/2"}]},`+
+			`"title":"Gear"}`, string(buf))
+	})
+
+	t.Run("should error if data is not object", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := NewHAL([]int{1}).MarshalJSON()
+		assert.NotNil(err)
+		_, err = NewHAL(func() {}).MarshalJSON()
+		assert.NotNil(err)
+	})
+}
+
+func TestGearHypermediaSender(t *testing.T) {
+	assert := assert.New(t)
+
+	app := New()
+	app.Set(SetSender, HALSender{})
+	app.Use(func(ctx *Context) error {
+		switch ctx.Path {
+		case "/hal":
+			return ctx.Send(http.StatusOK, NewHAL(nil).Link("self", "/hal"))
+		case "/data":
+			return ctx.Send(http.StatusOK, hypermediaArticle{Title: "Gear"})
+		case "/jsonapi":
+			return JSONAPISender{}.Send(ctx, http.StatusOK, &JSONAPIResource{Type: "articles", ID: "1"})
+		default:
+			return ctx.Send(http.StatusOK, ErrForbidden.WithMsg("no"))
+		}
+	})
+
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	res, err := RequestBy("GET", host+"/hal")
+	assert.Nil(err)
+	assert.Equal(MIMEApplicationHALJSON, res.Header.Get(HeaderContentType))
+	assert.Equal(`{"_links":{"self":{"href":"/hal"}}}`, PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host+"/data")
+	assert.Nil(err)
+	assert.Equal(`{"title":"Gear"}`, PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host+"/jsonapi")
+	assert.Nil(err)
+	assert.Equal(MIMEApplicationJSONAPI, res.Header.Get(HeaderContentType))
+	assert.Equal(`{"data":{"type":"articles","id":"1"}}`, PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host)
+	assert.Nil(err)
+	assert.Equal(403, res.StatusCode)
+	assert.Equal(MIMEApplicationJSONCharsetUTF8, res.Header.Get(HeaderContentType))
+}
@@ -0,0 +1,101 @@
+package gear
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"hash/fnv"
+	mrand "math/rand"
+	"time"
+)
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewID returns a new ULID (https://github.com/ulid/spec) string, such as "01HF3Z5J8X9K2M4N6P7Q8R9S0T".
+// It is 26 characters of Crockford's base32, encoding 48 bits millisecond timestamp and 80 random bits,
+// so the IDs are lexicographically sortable by creation time.
+// It is used to generate the "X-Request-Id" header by SetDiagnosticHeaders setting.
+func NewID() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	if _, err := rand.Read(b[6:]); err != nil {
+		panic(err)
+	}
+
+	// 128 bits -> 26 base32 characters, the first character holds the highest 3 bits.
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var id [26]byte
+	for i := 25; i >= 0; i-- {
+		id[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(id[:])
+}
+
+// Rand returns a request-scoped pseudo-random generator seeded by the request ID
+// ("X-Request-Id" header of the response or the request), so the random decisions, such as logging sampling
+// and canary routing, are consistent within one request and across the services sharing the request ID.
+// A random seed is used if no request ID. The generator is not safe for concurrent use.
+func (ctx *Context) Rand() *mrand.Rand {
+	if ctx.rand == nil {
+		ctx.rand = mrand.New(mrand.NewSource(ctx.seed()))
+	}
+	return ctx.rand
+}
+
+// Sample reports whether the request is sampled at the rate in [0, 1], such as 0.1 for 10%.
+// The result is decided by the request ID, so it is always the same for the same rate within one request,
+// and a request sampled at a lower rate is sampled at any higher rate.
+//
+//	if ctx.Sample(0.01) {
+//		logging.FromCtx(ctx)["body"] = body
+//	}
+func (ctx *Context) Sample(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	if rate >= 1 {
+		return true
+	}
+	return float64(uint64(ctx.seed())>>11)/(1<<53) < rate
+}
+
+func (ctx *Context) seed() int64 {
+	if ctx.seeded {
+		return ctx.randSeed
+	}
+
+	if rid := ctx.requestID(); rid != "" {
+		h := fnv.New64a()
+		h.Write([]byte(rid))
+		ctx.randSeed = int64(mix64(h.Sum64()))
+	} else {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			panic(err)
+		}
+		ctx.randSeed = int64(binary.BigEndian.Uint64(b[:]))
+	}
+	ctx.seeded = true
+	return ctx.randSeed
+}
+
+// requestID returns the "X-Request-Id" header of the response or the request.
+func (ctx *Context) requestID() string {
+	if rid := ctx.Res.Get(HeaderXRequestID); rid != "" {
+		return rid
+	}
+	return ctx.GetHeader(HeaderXRequestID)
+}
+
+// mix64 is the finalizer of SplitMix64. It spreads the FNV hash to all bits,
+// as the high bits of FNV hash barely change with the last bytes, such as sequential request ids.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
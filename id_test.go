@@ -0,0 +1,91 @@
+package gear
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearNewID(t *testing.T) {
+	assert := assert.New(t)
+
+	id := NewID()
+	assert.Equal(26, len(id))
+	assert.True(id[0] <= '7')
+	for _, c := range id {
+		assert.True(strings.ContainsRune(crockford, c))
+	}
+
+	ids := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		ids[NewID()] = true
+	}
+	assert.Equal(1000, len(ids))
+
+	time.Sleep(2 * time.Millisecond)
+	assert.True(NewID() > id, "should be sortable by time")
+}
+
+func TestGearContextRand(t *testing.T) {
+	app := New()
+
+	t.Run("should be seeded by request ID", func(t *testing.T) {
+		assert := assert.New(t)
+
+		newCtx := func(rid string) *Context {
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			if rid != "" {
+				req.Header.Set(HeaderXRequestID, rid)
+			}
+			return NewContext(app, httptest.NewRecorder(), req)
+		}
+
+		ctx1 := newCtx("abc")
+		ctx2 := newCtx("abc")
+		assert.True(ctx1.Rand() == ctx1.Rand())
+		assert.Equal(ctx1.Rand().Int63(), ctx2.Rand().Int63())
+		assert.Equal(ctx1.Rand().Float64(), ctx2.Rand().Float64())
+		assert.NotEqual(newCtx("abd").Rand().Int63(), newCtx("abc").Rand().Int63())
+
+		ctx3 := newCtx("")
+		ctx3.Res.Set(HeaderXRequestID, "abc")
+		assert.Equal(newCtx("abc").Rand().Int63(), ctx3.Rand().Int63())
+
+		ctx4 := newCtx("")
+		assert.Equal(ctx4.seed(), ctx4.seed())
+	})
+
+	t.Run("Sample", func(t *testing.T) {
+		assert := assert.New(t)
+
+		sampled := 0
+		for i := 0; i < 1000; i++ {
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			req.Header.Set(HeaderXRequestID, NewID())
+			ctx := NewContext(app, httptest.NewRecorder(), req)
+			assert.False(ctx.Sample(0))
+			assert.True(ctx.Sample(1))
+			s := ctx.Sample(0.2)
+			assert.Equal(s, ctx.Sample(0.2))
+			if s {
+				sampled++
+				assert.True(ctx.Sample(0.5))
+			}
+		}
+		assert.True(sampled > 100 && sampled < 300)
+
+		sampled = 0
+		for i := 0; i < 1000; i++ {
+			req := httptest.NewRequest("GET", "http://example.com/", nil)
+			req.Header.Set(HeaderXRequestID, fmt.Sprintf("req-%d", i))
+			if NewContext(app, httptest.NewRecorder(), req).Sample(0.2) {
+				sampled++
+			}
+		}
+		assert.True(sampled > 100 && sampled < 300)
+	})
+}
@@ -0,0 +1,81 @@
+package gear
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd socket activation, SD_LISTEN_FDS_START.
+var listenFdsStart = 3
+
+// ListenUnix starts the HTTP server on the Unix domain socket path, such as behind a local proxy (nginx, envoy).
+// The stale socket file of the previous process is removed, and the socket file is chmod to perm if it is not 0,
+// such as 0660 to allow the proxy in the same group to connect. The socket file is removed after the server closed.
+//
+//	app := gear.New()
+//	app.Error(app.ListenUnix("/run/myapp/http.sock", 0660))
+func (app *App) ListenUnix(path string, perm os.FileMode) error {
+	if err := app.prepare(h2c.NewHandler(app, &http2.Server{})); err != nil {
+		return err
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if perm != 0 {
+		if err := os.Chmod(path, perm); err != nil {
+			l.Close()
+			return err
+		}
+	}
+
+	app.runReadinessGates()
+	return app.Server.Serve(app.limitListener(l))
+}
+
+// SystemdListeners returns the listeners passed by systemd socket activation (the LISTEN_PID, LISTEN_FDS and
+// LISTEN_FDNAMES environment variables), in the order of the sockets in the systemd socket unit. It returns nil
+// if the process is not socket-activated. The environment variables are unset, so they are not inherited by
+// the child processes. See sd_listen_fds(3). It is used by ServeWithContext with a nil listener.
+func SystemdListeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		fd := listenFdsStart + i
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(f) // the fd is duplicated
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, Err.WithMsgf("failed to inherit the systemd listener %s: %v", name, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
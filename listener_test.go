@@ -0,0 +1,134 @@
+//go:build !windows
+
+package gear
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearAppListenUnix(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "gear.sock")
+	assert.Nil(os.WriteFile(path, nil, 0644))
+	assert.NotNil(New().ListenUnix(path, 0660), "should not remove the regular file")
+	assert.Nil(os.Remove(path))
+
+	// a stale socket file
+	l, err := net.Listen("unix", path)
+	assert.Nil(err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	app := New()
+	app.Use(func(ctx *Context) error {
+		return ctx.HTML(200, ctx.Req.Proto)
+	})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- app.ListenUnix(path, 0660)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	info, err := os.Stat(path)
+	assert.Nil(err)
+	assert.Equal(os.FileMode(0660), info.Mode().Perm())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	res, err := client.Get("http://unix/")
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal("HTTP/1.1", string(body))
+
+	assert.Nil(app.Close())
+	assert.Equal(http.ErrServerClosed, <-errc)
+	_, err = os.Stat(path)
+	assert.True(os.IsNotExist(err), "should remove the socket file after closed")
+}
+
+func TestGearSystemdListeners(t *testing.T) {
+	t.Run("should return nil if not socket-activated", func(t *testing.T) {
+		assert := assert.New(t)
+
+		t.Setenv("LISTEN_PID", "")
+		t.Setenv("LISTEN_FDS", "1")
+		listeners, err := SystemdListeners()
+		assert.Nil(err)
+		assert.Nil(listeners)
+
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+		listeners, err = SystemdListeners()
+		assert.Nil(err)
+		assert.Nil(listeners)
+
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		t.Setenv("LISTEN_FDS", "0")
+		listeners, err = SystemdListeners()
+		assert.Nil(err)
+		assert.Nil(listeners)
+
+		err = New().ServeWithContext(context.Background(), nil)
+		assert.Equal("Error: no listener passed by systemd socket activation", err.Error())
+	})
+
+	t.Run("should serve with the inherited listener", func(t *testing.T) {
+		assert := assert.New(t)
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(err)
+		addr := l.Addr().String()
+		f, err := l.(*net.TCPListener).File()
+		assert.Nil(err)
+		fd, err := syscall.Dup(int(f.Fd())) // the fd is owned by SystemdListeners
+		assert.Nil(err)
+		f.Close()
+		l.Close()
+
+		start := listenFdsStart
+		listenFdsStart = fd
+		defer func() {
+			listenFdsStart = start
+		}()
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		t.Setenv("LISTEN_FDS", "1")
+		t.Setenv("LISTEN_FDNAMES", "http")
+
+		app := New()
+		app.Use(func(ctx *Context) error {
+			return ctx.HTML(200, "systemd")
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		errc := make(chan error, 1)
+		go func() {
+			errc <- app.ServeWithContext(ctx, nil)
+		}()
+		time.Sleep(100 * time.Millisecond)
+
+		res, err := RequestBy("GET", "http://"+addr)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("systemd", PickRes(res.Text()).(string))
+		cancel()
+		assert.Equal(http.ErrServerClosed, <-errc)
+
+		_, ok := os.LookupEnv("LISTEN_FDS")
+		assert.False(ok, "should unset the environment variables")
+	})
+}
@@ -0,0 +1,163 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teambition/gear"
+)
+
+// DefaultW3CFields is the default fields of W3CConsume.
+var DefaultW3CFields = []string{
+	"date", "time", "c-ip", "cs-method", "cs-uri-stem", "cs-uri-query",
+	"sc-status", "sc-bytes", "time-taken", "cs(User-Agent)", "cs(Referer)",
+}
+
+// CombinedConsume returns a log consume function for Logger.SetLogConsume, that writes the access log to w
+// in Apache Combined Log Format, so it can be ingested by the legacy analyzers, such as GoAccess and AWStats:
+//
+//	127.0.0.1 - - [01/Jun/2017:12:23:13 +0000] "GET /index.html?q=x HTTP/1.1" 200 2326 "https://example.com/" "Mozilla/5.0 ..."
+//
+// The user field is the "user" string of the log if set. The log is written regardless of the logger level.
+//
+//	logger := logging.New(os.Stdout)
+//	logger.SetLogConsume(logging.CombinedConsume(os.Stdout))
+//	app.UseHandler(logger)
+func CombinedConsume(w io.Writer) func(Log, *gear.Context) {
+	var mu sync.Mutex
+	return func(log Log, ctx *gear.Context) {
+		user := "-"
+		if s, ok := log["user"].(string); ok && s != "" {
+			user = s
+		}
+		line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s %s %s\n",
+			ctx.IP(), quoteCLF(user, false), ctx.StartAt.Format("02/Jan/2006:15:04:05 -0700"),
+			ctx.Method, ctx.Req.RequestURI, ctx.Req.Proto, ctx.Res.Status(), clfBytes(ctx),
+			quoteCLF(ctx.GetHeader(gear.HeaderReferer), true), quoteCLF(ctx.GetHeader(gear.HeaderUserAgent), true))
+
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(w, line)
+	}
+}
+
+// W3CConsume returns a log consume function for Logger.SetLogConsume, that writes the access log to w
+// in W3C Extended Log File Format with the fields, the "#Version", "#Date" and "#Fields" directives are written
+// before the first log. The fields are DefaultW3CFields if omitted, the supported fields are:
+//
+//	date, time, c-ip, cs-method, cs-uri, cs-uri-stem, cs-uri-query, cs-version, cs-host,
+//	sc-status, sc-bytes, time-taken (seconds), cs(Header) and sc(Header), such as cs(User-Agent) and sc(Content-Type)
+//
+// It panics if a field is not supported. The empty value is written as "-", and the spaces in the value as "+".
+//
+//	logger := logging.New(os.Stdout)
+//	logger.SetLogConsume(logging.W3CConsume(os.Stdout, "date", "time", "c-ip", "cs-method", "cs-uri-stem", "sc-status"))
+//	app.UseHandler(logger)
+func W3CConsume(w io.Writer, fields ...string) func(Log, *gear.Context) {
+	if len(fields) == 0 {
+		fields = DefaultW3CFields
+	}
+	getters := make([]func(*gear.Context, time.Time) string, len(fields))
+	for i, field := range fields {
+		if getters[i] = w3cField(field); getters[i] == nil {
+			panic(gear.Err.WithMsgf("unsupported W3C field %q", field))
+		}
+	}
+
+	var mu sync.Mutex
+	started := false
+	return func(_ Log, ctx *gear.Context) {
+		end := time.Now().UTC()
+		values := make([]string, len(getters))
+		for i, get := range getters {
+			v := get(ctx, end)
+			if v == "" {
+				v = "-"
+			}
+			values[i] = strings.ReplaceAll(crlfEscaper.Replace(v), " ", "+")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !started {
+			started = true
+			fmt.Fprintf(w, "#Version: 1.0\n#Date: %s\n#Fields: %s\n", end.Format("2006-01-02 15:04:05"), strings.Join(fields, " "))
+		}
+		io.WriteString(w, strings.Join(values, " ")+"\n")
+	}
+}
+
+func w3cField(field string) func(ctx *gear.Context, end time.Time) string {
+	switch field {
+	case "date":
+		return func(ctx *gear.Context, _ time.Time) string { return ctx.StartAt.UTC().Format("2006-01-02") }
+	case "time":
+		return func(ctx *gear.Context, _ time.Time) string { return ctx.StartAt.UTC().Format("15:04:05") }
+	case "c-ip":
+		return func(ctx *gear.Context, _ time.Time) string { return ctx.IP().String() }
+	case "cs-method":
+		return func(ctx *gear.Context, _ time.Time) string { return ctx.Method }
+	case "cs-uri":
+		return func(ctx *gear.Context, _ time.Time) string { return ctx.Req.RequestURI }
+	case "cs-uri-stem":
+		return func(ctx *gear.Context, _ time.Time) string { return ctx.Req.URL.EscapedPath() }
+	case "cs-uri-query":
+		return func(ctx *gear.Context, _ time.Time) string { return ctx.Req.URL.RawQuery }
+	case "cs-version":
+		return func(ctx *gear.Context, _ time.Time) string { return ctx.Req.Proto }
+	case "cs-host":
+		return func(ctx *gear.Context, _ time.Time) string { return ctx.Host }
+	case "sc-status":
+		return func(ctx *gear.Context, _ time.Time) string { return strconv.Itoa(ctx.Res.Status()) }
+	case "sc-bytes":
+		return func(ctx *gear.Context, _ time.Time) string { return strconv.Itoa(resBytes(ctx)) }
+	case "time-taken":
+		return func(ctx *gear.Context, end time.Time) string {
+			return strconv.FormatFloat(end.Sub(ctx.StartAt).Seconds(), 'f', 3, 64)
+		}
+	}
+	if len(field) > 4 && field[len(field)-1] == ')' {
+		name := http.CanonicalHeaderKey(field[3 : len(field)-1])
+		switch field[:3] {
+		case "cs(":
+			return func(ctx *gear.Context, _ time.Time) string { return ctx.GetHeader(name) }
+		case "sc(":
+			return func(ctx *gear.Context, _ time.Time) string { return ctx.Res.Get(name) }
+		}
+	}
+	return nil
+}
+
+// resBytes returns the response body size, the body of streaming response is not kept.
+func resBytes(ctx *gear.Context) int {
+	if n := len(ctx.Res.Body()); n > 0 {
+		return n
+	}
+	n, _ := strconv.Atoi(ctx.Res.Get(gear.HeaderContentLength))
+	return n
+}
+
+func clfBytes(ctx *gear.Context) string {
+	if n := resBytes(ctx); n > 0 {
+		return strconv.Itoa(n)
+	}
+	return "-"
+}
+
+// quoteCLF escapes the value for Common Log Format, the empty value is "-".
+func quoteCLF(s string, quoted bool) string {
+	if s == "" {
+		s = "-"
+	} else {
+		s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", `\r`, "\n", `\n`).Replace(s)
+	}
+	if quoted {
+		return `"` + s + `"`
+	}
+	return s
+}
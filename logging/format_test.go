@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+// syncBuffer is a bytes.Buffer safe for the end hooks writing and the test reading.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestGearLoggerFormatConsume(t *testing.T) {
+	newApp := func(consume func(Log, *gear.Context)) (*gear.App, string, func()) {
+		app := gear.New()
+		logger := New(&bytes.Buffer{})
+		logger.SetLogConsume(consume)
+		app.UseHandler(logger)
+		app.Use(func(ctx *gear.Context) error {
+			logger.SetTo(ctx, "user", "alice")
+			if ctx.Path == "/empty" {
+				return ctx.End(204)
+			}
+			return ctx.HTML(200, "Hello")
+		})
+		srv := app.Start()
+		return app, "http://" + srv.Addr().String(), func() { srv.Close() }
+	}
+	request := func(url string) {
+		req, _ := NewRequst("GET", url)
+		req.Header.Set(gear.HeaderUserAgent, `Go "test"`)
+		req.Header.Set(gear.HeaderReferer, "https://example.com/")
+		res, err := DefaultClientDo(req)
+		if err == nil {
+			res.Body.Close()
+		}
+		time.Sleep(20 * time.Millisecond) // wait for the end hooks
+	}
+
+	t.Run("CombinedConsume", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf syncBuffer
+		_, host, closeFn := newApp(CombinedConsume(&buf))
+		defer closeFn()
+
+		request(host + "/index.html?q=x")
+		request(host + "/empty")
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Equal(2, len(lines))
+		assert.True(regexp.MustCompile(`^127\.0\.0\.1 - alice \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /index.html\?q=x HTTP/1.1" 200 5 "https://example.com/" "Go \\"test\\""$`).MatchString(lines[0]), lines[0])
+		assert.True(strings.HasSuffix(lines[1], `"GET /empty HTTP/1.1" 204 - "https://example.com/" "Go \"test\""`), lines[1])
+
+		assert.Equal("-", quoteCLF("", false))
+		assert.Equal(`"a\nb"`, quoteCLF("a\nb", true))
+	})
+
+	t.Run("W3CConsume", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.Panics(func() {
+			W3CConsume(io.Discard, "date", "s-ip")
+		})
+
+		var buf syncBuffer
+		_, host, closeFn := newApp(W3CConsume(&buf))
+		defer closeFn()
+
+		request(host + "/index.html?q=x")
+		request(host + "/empty")
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Equal(5, len(lines))
+		assert.Equal("#Version: 1.0", lines[0])
+		assert.True(strings.HasPrefix(lines[1], "#Date: "))
+		assert.Equal("#Fields: date time c-ip cs-method cs-uri-stem cs-uri-query sc-status sc-bytes time-taken cs(User-Agent) cs(Referer)", lines[2])
+		assert.True(regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2} 127\.0\.0\.1 GET /index.html q=x 200 5 \d+\.\d{3} Go\+"test" https://example.com/$`).MatchString(lines[3]), lines[3])
+		assert.True(strings.Contains(lines[4], " GET /empty - 204 0 "), lines[4])
+	})
+
+	t.Run("W3CConsume with fields", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf syncBuffer
+		_, host, closeFn := newApp(W3CConsume(&buf, "cs-uri", "cs-version", "sc(content-type)", "cs(X-None)"))
+		defer closeFn()
+
+		request(host + "/index.html?q=x")
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Equal(4, len(lines))
+		assert.Equal("#Fields: cs-uri cs-version sc(content-type) cs(X-None)", lines[2])
+		assert.Equal("/index.html?q=x HTTP/1.1 text/html;+charset=utf-8 -", lines[3])
+	})
+}
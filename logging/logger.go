@@ -326,6 +326,10 @@ type Logger struct {
 	mu      sync.Mutex               // ensures atomic writes; protects the following fields
 	init    func(Log, *gear.Context) // hook to initialize log with gear.Context
 	consume func(Log, *gear.Context) // hook to consume log
+
+	startRate float64       // sampling rate of "start" log
+	running   time.Duration // interval of "running" log
+	metrics   *metrics      // request metrics of "metrics" log
 }
 
 // Check log output level statisfy output level or not, used internal, for performance
@@ -554,6 +558,30 @@ func (l *Logger) SetLogConsume(fn func(Log, *gear.Context)) *Logger {
 	return l
 }
 
+// SetStartLog set the logger writing a "start" log when the request started, in addition to the completion log.
+// The start logs are sampled at the rate in [0, 1] by ctx.Sample, such as 0.1 for 10%. 0 to disable (default).
+// The log is written with InfoLevel:
+//
+//	{"event":"start","ip":"127.0.0.1","method":"GET","uri":"/","xRequestId":"...",...}
+func (l *Logger) SetStartLog(sampleRate float64) *Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.startRate = sampleRate
+	return l
+}
+
+// SetRunningLog set the logger writing a "running" notice log every interval while the request is still running,
+// so operators can see long-running or hung requests that never finish. 0 to disable (default).
+// The log is written with WarningLevel:
+//
+//	{"event":"running","elapsed":30000,"ip":"127.0.0.1","method":"GET","uri":"/","xRequestId":"...",...}
+func (l *Logger) SetRunningLog(interval time.Duration) *Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.running = interval
+	return l
+}
+
 // New implements gear.Any interface,then we can use ctx.Any to retrieve a Log instance from ctx.
 // Here also some initialization work after created.
 func (l *Logger) New(ctx *gear.Context) (any, error) {
@@ -592,6 +620,7 @@ func (l *Logger) SetTo(ctx *gear.Context, key string, val any) {
 func (l *Logger) Serve(ctx *gear.Context) error {
 	// should be inited when start
 	log := l.FromCtx(ctx)
+	l.lifecycle(log, ctx)
 
 	// Add a "end hook" to flush logs
 	ctx.OnEnd(func() {
@@ -619,10 +648,58 @@ func (l *Logger) Serve(ctx *gear.Context) error {
 		}
 
 		l.consume(log, ctx)
+
+		l.mu.Lock()
+		m := l.metrics
+		l.mu.Unlock()
+		if m != nil {
+			m.record(ctx, ctx.Res.Status())
+		}
 	})
 	return nil
 }
 
+// lifecycle writes the "start" log and starts the "running" log ticker for the request.
+func (l *Logger) lifecycle(log Log, ctx *gear.Context) {
+	l.mu.Lock()
+	startRate, running := l.startRate, l.running
+	l.mu.Unlock()
+	if startRate <= 0 && running <= 0 {
+		return
+	}
+
+	base := log.Into(Log{})
+	if s := ctx.GetHeader(gear.HeaderXRequestID); s != "" {
+		base["xRequestId"] = s
+	} else if s := ctx.Res.Get(gear.HeaderXRequestID); s != "" {
+		base["xRequestId"] = s
+	}
+
+	if startRate > 0 && l.checkLogLevel(InfoLevel) && ctx.Sample(startRate) {
+		l.output(time.Now().UTC(), InfoLevel, base.With(Log{"event": "start"}))
+	}
+
+	if running > 0 && l.checkLogLevel(WarningLevel) {
+		done := make(chan struct{})
+		ctx.OnEnd(func() { close(done) })
+		go func() {
+			ticker := time.NewTicker(running)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ctx.Done():
+					return
+				case t := <-ticker.C:
+					elapsed := t.Sub(ctx.StartAt) / 1e6 // ms
+					l.output(t.UTC(), WarningLevel, base.With(Log{"event": "running", "elapsed": elapsed}))
+				}
+			}
+		}()
+	}
+}
+
 // Emerg produce a "Emergency" log with the default logger
 func Emerg(v any) {
 	std.Emerg(v)
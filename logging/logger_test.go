@@ -612,6 +612,59 @@ func TestGearLoggerMiddleware(t *testing.T) {
 		assert.Contains(log, `"responseContentType":"application/json; charset=utf-8"`)
 		res.Body.Close()
 	})
+
+	t.Run("start and running log", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf bytes.Buffer
+		app := gear.New()
+		logger := New(&buf).SetJSONLog().SetStartLog(1).SetRunningLog(20 * time.Millisecond)
+		app.UseHandler(logger)
+		app.Use(func(ctx *gear.Context) error {
+			if ctx.Path == "/slow" {
+				time.Sleep(70 * time.Millisecond)
+			}
+			return ctx.HTML(200, "OK")
+		})
+		srv := app.Start()
+		defer srv.Close()
+
+		req, _ := NewRequst("GET", "http://"+srv.Addr().String()+"/slow")
+		req.Header.Set(gear.HeaderXRequestID, "abc")
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		res.Body.Close()
+		time.Sleep(50 * time.Millisecond)
+
+		logger.mu.Lock()
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		logger.mu.Unlock()
+		assert.True(len(lines) >= 4)
+		assert.Contains(lines[0], `"event":"start"`)
+		assert.Contains(lines[0], `"level":"info"`)
+		assert.Contains(lines[0], `"uri":"/slow"`)
+		assert.Contains(lines[0], `"xRequestId":"abc"`)
+		assert.Contains(lines[1], `"event":"running"`)
+		assert.Contains(lines[1], `"level":"warning"`)
+		assert.Contains(lines[1], `"elapsed":`)
+		assert.Contains(lines[1], `"xRequestId":"abc"`)
+		assert.Contains(lines[len(lines)-1], `"status":200`)
+		assert.Equal(len(lines)-2, strings.Count(buf.String(), `"event":"running"`))
+
+		buf.Reset()
+		logger.SetStartLog(0)
+		res, err = RequestBy("GET", "http://"+srv.Addr().String())
+		assert.Nil(err)
+		res.Body.Close()
+		time.Sleep(50 * time.Millisecond)
+
+		logger.mu.Lock()
+		log := buf.String()
+		logger.mu.Unlock()
+		assert.Equal(1, strings.Count(log, "\n"))
+		assert.NotContains(log, `"event"`)
+	})
 }
 
 func TestParseLevel(t *testing.T) {
@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/teambition/gear"
+)
+
+// maxMetricsSamples is the reservoir size of the request durations per route in an interval, for percentiles.
+const maxMetricsSamples = 1024
+
+// metricsAlpha is the smoothing factor of the exponentially weighted moving average of the request durations.
+const metricsAlpha = 0.1
+
+type routeMetrics struct {
+	count   int
+	errors  int
+	ewma    float64   // ms, rolling across intervals
+	samples []float64 // ms, reset every interval
+}
+
+type metrics struct {
+	mu     sync.Mutex
+	routes map[string]*routeMetrics
+	stop   chan struct{}
+}
+
+// SetMetricsLog set the logger computing the request metrics per route from the consumed logs, and writing
+// a "metrics" summary log every interval, for the teams without a metrics stack who rely on logs.
+// The summary has the count, the 5xx errors count and the p50, p95 and p99 durations (ms) in the interval,
+// and the exponentially weighted moving average of the durations (ms). The routes without requests in the interval
+// are omitted, and the unmatched requests are summarized as "METHOD <unmatched>". 0 to disable (default).
+// The log is written with InfoLevel:
+//
+//	{"event":"metrics","interval":60000,"routes":{"GET /users/:id":{"count":120,"errors":1,"ewma":12.5,"p50":10.2,"p95":31.7,"p99":58.1}}}
+func (l *Logger) SetMetricsLog(interval time.Duration) *Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.metrics != nil {
+		close(l.metrics.stop)
+		l.metrics = nil
+	}
+	if interval > 0 {
+		m := &metrics{routes: make(map[string]*routeMetrics), stop: make(chan struct{})}
+		l.metrics = m
+		go l.emitMetrics(m, interval)
+	}
+	return l
+}
+
+func (l *Logger) emitMetrics(m *metrics, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case t := <-ticker.C:
+			if routes := m.summary(); len(routes) > 0 && l.checkLogLevel(InfoLevel) {
+				l.output(t.UTC(), InfoLevel, Log{"event": "metrics", "interval": interval / 1e6, "routes": routes})
+			}
+		}
+	}
+}
+
+func (m *metrics) record(ctx *gear.Context, status int) {
+	key := gear.GetRouterPatternFromCtx(ctx)
+	if key == "" {
+		key = "<unmatched>"
+	}
+	key = ctx.Method + " " + key
+	d := float64(time.Since(ctx.StartAt)) / 1e6 // ms
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.routes[key]
+	if r == nil {
+		r = &routeMetrics{ewma: d}
+		m.routes[key] = r
+	}
+	r.count++
+	if status >= 500 {
+		r.errors++
+	}
+	r.ewma += metricsAlpha * (d - r.ewma)
+	if len(r.samples) < maxMetricsSamples {
+		r.samples = append(r.samples, d)
+	} else if i := rand.Intn(r.count); i < maxMetricsSamples {
+		r.samples[i] = d // reservoir sampling
+	}
+}
+
+// summary returns the metrics of the routes in the interval, and resets them.
+func (m *metrics) summary() map[string]Log {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	routes := make(map[string]Log)
+	for key, r := range m.routes {
+		if r.count == 0 {
+			continue
+		}
+		sort.Float64s(r.samples)
+		routes[key] = Log{
+			"count":  r.count,
+			"errors": r.errors,
+			"ewma":   round(r.ewma),
+			"p50":    percentile(r.samples, 0.5),
+			"p95":    percentile(r.samples, 0.95),
+			"p99":    percentile(r.samples, 0.99),
+		}
+		r.count, r.errors, r.samples = 0, 0, r.samples[:0]
+	}
+	return routes
+}
+
+// percentile returns the q percentile of the sorted samples with nearest-rank method.
+func percentile(sorted []float64, q float64) float64 {
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return round(sorted[i])
+}
+
+func round(ms float64) float64 {
+	return math.Round(ms*1000) / 1000
+}
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func TestGearLoggerMetricsLog(t *testing.T) {
+	t.Run("percentile", func(t *testing.T) {
+		assert := assert.New(t)
+
+		samples := []float64{}
+		for i := 1; i <= 100; i++ {
+			samples = append(samples, float64(i))
+		}
+		assert.Equal(float64(50), percentile(samples, 0.5))
+		assert.Equal(float64(95), percentile(samples, 0.95))
+		assert.Equal(float64(99), percentile(samples, 0.99))
+		assert.Equal(float64(7), percentile([]float64{7}, 0.5))
+		assert.Equal(1.235, round(1.23456))
+	})
+
+	t.Run("should write metrics summary log", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf bytes.Buffer
+		app := gear.New()
+		logger := New(&buf).SetJSONLog().SetMetricsLog(100 * time.Millisecond)
+		defer logger.SetMetricsLog(0)
+		logger.SetLogConsume(func(Log, *gear.Context) {})
+		router := gear.NewRouter()
+		router.Get("/users/:id", func(ctx *gear.Context) error {
+			if ctx.Param("id") == "0" {
+				return gear.ErrInternalServerError
+			}
+			return ctx.HTML(200, "OK")
+		})
+		app.UseHandler(logger)
+		app.UseHandler(router)
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		for _, path := range []string{"/users/1", "/users/2", "/users/0", "/none"} {
+			res, err := RequestBy("GET", host+path)
+			assert.Nil(err)
+			res.Body.Close()
+		}
+		time.Sleep(150 * time.Millisecond)
+
+		logger.mu.Lock()
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		logger.mu.Unlock()
+		assert.Equal(1, len(lines))
+		log := map[string]any{}
+		assert.Nil(json.Unmarshal([]byte(lines[0]), &log))
+		assert.Equal("metrics", log["event"])
+		assert.Equal("info", log["level"])
+		assert.Equal(float64(100), log["interval"])
+
+		routes := log["routes"].(map[string]any)
+		assert.Equal(2, len(routes))
+		users := routes["GET /users/:id"].(map[string]any)
+		assert.Equal(float64(3), users["count"])
+		assert.Equal(float64(1), users["errors"])
+		for _, key := range []string{"ewma", "p50", "p95", "p99"} {
+			assert.True(users[key].(float64) >= 0)
+		}
+		unmatched := routes["GET <unmatched>"].(map[string]any)
+		assert.Equal(float64(1), unmatched["count"])
+		assert.Equal(float64(0), unmatched["errors"])
+
+		// no log without requests in the interval
+		time.Sleep(120 * time.Millisecond)
+		logger.mu.Lock()
+		assert.Equal(1, strings.Count(buf.String(), "\n"))
+		logger.mu.Unlock()
+	})
+}
@@ -0,0 +1,315 @@
+package cache
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teambition/gear"
+)
+
+// HeaderXCache is the response header indicating whether the response is served from cache, "HIT" or "MISS".
+const HeaderXCache = "X-Cache"
+
+// Store is the interface of the cache store. The Redis or memcached backends can be plugged in by implementing it.
+type Store interface {
+	// Get returns the value of the key, or nil if the key not exists or expired.
+	Get(key string) ([]byte, error)
+	// Set sets the value of the key with the time to live.
+	Set(key string, val []byte, ttl time.Duration) error
+	// Delete deletes the key.
+	Delete(key string) error
+}
+
+// Options is cache middleware options.
+type Options struct {
+	// Store is the store of the cached responses.
+	// Optional. Default to NewMemoryStore(1024).
+	Store Store
+	// TTL is the time to live of the cached responses without "max-age" or "s-maxage" in Cache-Control header.
+	// Optional. Default to 1 minute.
+	TTL time.Duration
+	// Vary defines the request headers that the cached responses vary on, they are parts of the cache key.
+	// The response with Vary header not included will not be cached.
+	// Optional. Default to nil, the cache key is host, path and query.
+	Vary []string
+	// Key returns the cache key of the request.
+	// Optional. Default to the host, path, query and the Vary request headers.
+	Key func(ctx *gear.Context) string
+	// Skipper returns true to skip the cache for the request.
+	// Optional. Default to nil.
+	Skipper func(ctx *gear.Context) bool
+}
+
+// cacheableStatus are the status codes that can be cached by default, see RFC 7231 section 6.1.
+var cacheableStatus = map[int]bool{200: true, 203: true, 300: true, 301: true, 404: true, 410: true}
+
+// excludedHeaders will not be cached.
+var excludedHeaders = []string{gear.HeaderContentLength, "Date", "Age", HeaderXCache, gear.HeaderXRequestID}
+
+// entry is the cached response.
+type entry struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	Time   int64       `json:"time"` // unix seconds
+}
+
+// New creates a middleware to cache GET and HEAD responses. It respects the Cache-Control header
+// on both request and response:
+//
+//   - Request with "no-store" will not be served from cache, and its response will not be cached.
+//   - Request with "no-cache" or "max-age=0" will not be served from cache, but its response will be cached.
+//   - Response with "no-store", "no-cache" or "private", or with Set-Cookie header will not be cached.
+//   - Response with "s-maxage" or "max-age" will be cached for that time, instead of the TTL option.
+//
+// Only the response with body captured by ctx.Res (see Response.Body) can be cached, so the
+// responses written by Response.Write directly (such as files and streams) will not be cached.
+//
+//	package main
+//
+//	import (
+//		"time"
+//
+//		"github.com/teambition/gear"
+//		"github.com/teambition/gear/middleware/cache"
+//	)
+//
+//	func main() {
+//		app := gear.New()
+//		router := gear.NewRouter()
+//		router.Get("/articles", cache.New(cache.Options{
+//			TTL:  10 * time.Second,
+//			Vary: []string{gear.HeaderAcceptLanguage},
+//		}), ListArticles)
+//		app.UseHandler(router)
+//		app.Error(app.Listen(":3000"))
+//	}
+func New(options ...Options) gear.Middleware {
+	opts := Options{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryStore(1024)
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = time.Minute
+	}
+	varyNames := make([]string, 0, len(opts.Vary))
+	vary := make(map[string]bool, len(opts.Vary))
+	for _, name := range opts.Vary {
+		name = http.CanonicalHeaderKey(name)
+		varyNames = append(varyNames, name)
+		vary[name] = true
+	}
+	if opts.Key == nil {
+		opts.Key = func(ctx *gear.Context) string {
+			b := strings.Builder{}
+			b.WriteString(ctx.Host)
+			b.WriteString(ctx.Req.URL.RequestURI())
+			for _, name := range varyNames {
+				b.WriteString("\n")
+				b.WriteString(name)
+				b.WriteString(": ")
+				b.WriteString(strings.Join(ctx.Req.Header.Values(name), ", "))
+			}
+			return b.String()
+		}
+	}
+
+	return func(ctx *gear.Context) error {
+		if ctx.Method != http.MethodGet && ctx.Method != http.MethodHead {
+			return nil
+		}
+		if opts.Skipper != nil && opts.Skipper(ctx) {
+			return nil
+		}
+		reqDirectives := parseCacheControl(ctx.Req.Header.Values(gear.HeaderCacheControl))
+		if _, ok := reqDirectives["no-store"]; ok {
+			return nil
+		}
+
+		key := opts.Key(ctx)
+		_, noCache := reqDirectives["no-cache"]
+		if !noCache && reqDirectives["max-age"] != "0" {
+			val, err := opts.Store.Get(key)
+			if err != nil {
+				ctx.LogErr(err) // log the store error and serve it uncached
+			}
+			e := &entry{}
+			if val != nil && json.Unmarshal(val, e) == nil {
+				header := ctx.Res.Header()
+				for k, v := range e.Header {
+					if _, ok := header[k]; !ok {
+						header[k] = v
+					}
+				}
+				age := time.Now().Unix() - e.Time
+				if age < 0 {
+					age = 0
+				}
+				header.Set("Age", strconv.FormatInt(age, 10))
+				header.Set(HeaderXCache, "HIT")
+				return ctx.End(e.Status, e.Body)
+			}
+		}
+
+		ctx.SetHeader(HeaderXCache, "MISS")
+		if ctx.Method != http.MethodGet {
+			return nil
+		}
+		ctx.OnEnd(func() {
+			status := ctx.Res.Status()
+			body := ctx.Res.Body()
+			header := ctx.Res.Header()
+			if !cacheableStatus[status] || body == nil || header.Get(gear.HeaderSetCookie) != "" {
+				return
+			}
+			for _, name := range header.Values(gear.HeaderVary) {
+				for _, field := range strings.Split(name, ",") {
+					if field = http.CanonicalHeaderKey(strings.TrimSpace(field)); field != "" && !vary[field] {
+						return
+					}
+				}
+			}
+
+			ttl := opts.TTL
+			resDirectives := parseCacheControl(header.Values(gear.HeaderCacheControl))
+			for _, d := range []string{"no-store", "no-cache", "private"} {
+				if _, ok := resDirectives[d]; ok {
+					return
+				}
+			}
+			for _, d := range []string{"s-maxage", "max-age"} {
+				if v, ok := resDirectives[d]; ok {
+					if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+						ttl = time.Duration(seconds) * time.Second
+					}
+					break
+				}
+			}
+			if ttl <= 0 {
+				return
+			}
+
+			e := &entry{Status: status, Header: header.Clone(), Body: body, Time: time.Now().Unix()}
+			for _, name := range excludedHeaders {
+				e.Header.Del(name)
+			}
+			val, err := json.Marshal(e)
+			if err == nil {
+				err = opts.Store.Set(key, val, ttl)
+			}
+			if err != nil {
+				ctx.LogErr(err)
+			}
+		})
+		return nil
+	}
+}
+
+// parseCacheControl parses the Cache-Control header values to directives map.
+func parseCacheControl(values []string) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range values {
+		for _, d := range strings.Split(value, ",") {
+			d = strings.ToLower(strings.TrimSpace(d))
+			if d == "" {
+				continue
+			}
+			name, val, _ := strings.Cut(d, "=")
+			directives[name] = strings.Trim(val, `"`)
+		}
+	}
+	return directives
+}
+
+// MemoryStore is an in-memory Store with LRU eviction.
+type MemoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type memoryItem struct {
+	key     string
+	val     []byte
+	expires time.Time
+}
+
+// NewMemoryStore returns a MemoryStore that holds up to capacity items,
+// the least recently used item will be evicted when full.
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		panic(gear.Err.WithMsg("invalid capacity"))
+	}
+	return &MemoryStore{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get implements the Store interface.
+func (s *MemoryStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[key]
+	if !ok {
+		return nil, nil
+	}
+	item := el.Value.(*memoryItem)
+	if time.Now().After(item.expires) {
+		s.remove(el)
+		return nil, nil
+	}
+	s.ll.MoveToFront(el)
+	return item.val, nil
+}
+
+// Set implements the Store interface.
+func (s *MemoryStore) Set(key string, val []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires := time.Now().Add(ttl)
+	if el, ok := s.items[key]; ok {
+		item := el.Value.(*memoryItem)
+		item.val = val
+		item.expires = expires
+		s.ll.MoveToFront(el)
+		return nil
+	}
+	s.items[key] = s.ll.PushFront(&memoryItem{key: key, val: val, expires: expires})
+	if s.ll.Len() > s.capacity {
+		s.remove(s.ll.Back())
+	}
+	return nil
+}
+
+// Delete implements the Store interface.
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+// Len returns the number of items in the store, including the expired ones not evicted yet.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	s.ll.Remove(el)
+	delete(s.items, el.Value.(*memoryItem).key)
+}
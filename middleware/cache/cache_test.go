@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+type response struct {
+	status int
+	body   string
+	cache  string
+}
+
+func request(t *testing.T, method, url string, header ...string) response {
+	req, err := http.NewRequest(method, url, nil)
+	assert.Nil(t, err)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	return response{res.StatusCode, string(body), res.Header.Get(HeaderXCache)}
+}
+
+func TestGearMiddlewareCache(t *testing.T) {
+	t.Run("should cache GET and HEAD responses", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var count int32
+		store := NewMemoryStore(10)
+		app := gear.New()
+		app.Use(New(Options{Store: store, Vary: []string{"accept-language"}}))
+		router := gear.NewRouter()
+		router.Get("/count", func(ctx *gear.Context) error {
+			n := atomic.AddInt32(&count, 1)
+			ctx.Res.Vary(gear.HeaderAcceptLanguage)
+			return ctx.HTML(200, strconv.Itoa(int(n)))
+		})
+		router.Get("/missing", func(ctx *gear.Context) error {
+			atomic.AddInt32(&count, 1)
+			return gear.ErrNotFound
+		})
+		router.Post("/count", func(ctx *gear.Context) error {
+			n := atomic.AddInt32(&count, 1)
+			return ctx.HTML(200, strconv.Itoa(int(n)))
+		})
+		app.UseHandler(router)
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		assert.Equal(response{200, "1", "MISS"}, request(t, "GET", host+"/count"))
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(response{200, "1", "HIT"}, request(t, "GET", host+"/count"))
+		assert.Equal(response{200, "", "HIT"}, request(t, "HEAD", host+"/count"))
+		assert.Equal(response{200, "2", "MISS"}, request(t, "GET", host+"/count?a=1"))
+		assert.Equal(response{200, "3", "MISS"}, request(t, "GET", host+"/count", gear.HeaderAcceptLanguage, "zh"))
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(response{200, "3", "HIT"}, request(t, "GET", host+"/count", gear.HeaderAcceptLanguage, "zh"))
+		assert.Equal(response{200, "4", ""}, request(t, "POST", host+"/count"))
+		assert.Equal(3, store.Len())
+
+		res := request(t, "GET", host+"/missing")
+		assert.Equal(404, res.status)
+		assert.Equal("MISS", res.cache)
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(4, store.Len())
+		res = request(t, "GET", host+"/missing")
+		assert.Equal(404, res.status)
+		assert.Equal("HIT", res.cache)
+		assert.Equal(int32(5), atomic.LoadInt32(&count))
+	})
+
+	t.Run("should respect Cache-Control", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var count int32
+		app := gear.New()
+		router := gear.NewRouter()
+		router.Use(New(Options{TTL: time.Hour}))
+		router.Get("/:control", func(ctx *gear.Context) error {
+			n := atomic.AddInt32(&count, 1)
+			switch ctx.Param("control") {
+			case "private":
+				ctx.SetHeader(gear.HeaderCacheControl, "private, max-age=60")
+			case "short":
+				ctx.SetHeader(gear.HeaderCacheControl, "public, max-age=1")
+			case "cookie":
+				ctx.Res.Set(gear.HeaderSetCookie, "a=b")
+			case "vary":
+				ctx.Res.Vary(gear.HeaderAcceptLanguage)
+			}
+			return ctx.HTML(200, strconv.Itoa(int(n)))
+		})
+		app.UseHandler(router)
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		for _, path := range []string{"/private", "/cookie", "/vary"} {
+			request(t, "GET", host+path)
+			time.Sleep(10 * time.Millisecond)
+			assert.Equal("MISS", request(t, "GET", host+path).cache, path)
+		}
+		assert.Equal(int32(6), atomic.LoadInt32(&count))
+
+		assert.Equal(response{200, "7", "MISS"}, request(t, "GET", host+"/short"))
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(response{200, "7", "HIT"}, request(t, "GET", host+"/short"))
+		assert.Equal(response{200, "8", ""}, request(t, "GET", host+"/short", gear.HeaderCacheControl, "no-store"))
+		assert.Equal(response{200, "7", "HIT"}, request(t, "GET", host+"/short"))
+		assert.Equal(response{200, "9", "MISS"}, request(t, "GET", host+"/short", gear.HeaderCacheControl, "no-cache"))
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(response{200, "9", "HIT"}, request(t, "GET", host+"/short"))
+		assert.Equal(response{200, "10", "MISS"}, request(t, "GET", host+"/short", gear.HeaderCacheControl, "max-age=0"))
+
+		time.Sleep(1100 * time.Millisecond)
+		assert.Equal(response{200, "11", "MISS"}, request(t, "GET", host+"/short"))
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Panics(func() {
+		NewMemoryStore(0)
+	})
+
+	store := NewMemoryStore(2)
+	assert.Nil(store.Set("a", []byte("1"), time.Hour))
+	assert.Nil(store.Set("b", []byte("2"), time.Hour))
+	val, err := store.Get("a")
+	assert.Nil(err)
+	assert.Equal([]byte("1"), val)
+
+	assert.Nil(store.Set("c", []byte("3"), time.Hour)) // evict "b"
+	val, _ = store.Get("b")
+	assert.Nil(val)
+	val, _ = store.Get("a")
+	assert.Equal([]byte("1"), val)
+	assert.Equal(2, store.Len())
+
+	assert.Nil(store.Set("a", []byte("4"), time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	val, _ = store.Get("a")
+	assert.Nil(val)
+	assert.Equal(1, store.Len())
+
+	assert.Nil(store.Delete("c"))
+	assert.Nil(store.Delete("c"))
+	assert.Equal(0, store.Len())
+}
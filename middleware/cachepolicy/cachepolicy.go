@@ -0,0 +1,134 @@
+package cachepolicy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teambition/gear"
+)
+
+// Policy is the browser cache policy, that is rendered to the Cache-Control, Expires and Pragma headers.
+type Policy struct {
+	// NoStore forbids caching the response, the other directives are ignored.
+	NoStore bool
+	// NoCache requires revalidating the cached response with the server before using it.
+	NoCache bool
+	// Private restricts caching to the browsers, the shared caches (proxies and CDNs) must not store it.
+	Private bool
+	// Public allows the shared caches to store the response, even if it is usually not cacheable (such as with Authorization).
+	Public bool
+	// MaxAge is the time that the response remains fresh, "max-age" directive.
+	MaxAge time.Duration
+	// SMaxAge is the time that the response remains fresh in the shared caches, "s-maxage" directive.
+	SMaxAge time.Duration
+	// StaleWhileRevalidate is the time that the stale response can be used while revalidating in the background.
+	StaleWhileRevalidate time.Duration
+	// MustRevalidate forbids using the stale response without revalidating.
+	MustRevalidate bool
+	// Immutable indicates that the response will not change while fresh, the browsers will not revalidate it on reload.
+	Immutable bool
+}
+
+// The presets of policies.
+var (
+	// NoStore is for the API responses that should not be cached, such as the user's data.
+	NoStore = Policy{NoStore: true}
+	// ImmutableAssets is for the content-hashed static assets, such as "app.3f2a1b.js".
+	ImmutableAssets = Policy{Public: true, MaxAge: 365 * 24 * time.Hour, Immutable: true}
+	// ShortLived is for the HTML pages that may be cached briefly and should be revalidated after expired.
+	ShortLived = Policy{Public: true, MaxAge: time.Minute, MustRevalidate: true}
+)
+
+// String returns the Cache-Control header value of the policy.
+func (p Policy) String() string {
+	if p.NoStore {
+		return "no-store"
+	}
+
+	var directives []string
+	switch {
+	case p.Private:
+		directives = append(directives, "private")
+	case p.Public:
+		directives = append(directives, "public")
+	}
+	if p.NoCache {
+		directives = append(directives, "no-cache")
+	}
+	directives = append(directives, "max-age="+seconds(p.MaxAge))
+	if p.SMaxAge > 0 {
+		directives = append(directives, "s-maxage="+seconds(p.SMaxAge))
+	}
+	if p.StaleWhileRevalidate > 0 {
+		directives = append(directives, "stale-while-revalidate="+seconds(p.StaleWhileRevalidate))
+	}
+	if p.MustRevalidate {
+		directives = append(directives, "must-revalidate")
+	}
+	if p.Immutable {
+		directives = append(directives, "immutable")
+	}
+	return strings.Join(directives, ", ")
+}
+
+// Apply sets the Cache-Control, Expires and Pragma (for the HTTP/1.0 caches) headers of the policy to the response.
+func (p Policy) Apply(ctx *gear.Context) {
+	ctx.SetHeader(gear.HeaderCacheControl, p.String())
+	if p.NoStore || p.NoCache || p.MaxAge <= 0 {
+		ctx.SetHeader(gear.HeaderPragma, "no-cache")
+		ctx.SetHeader(gear.HeaderExpires, "0")
+		return
+	}
+	ctx.Res.Del(gear.HeaderPragma)
+	ctx.SetHeader(gear.HeaderExpires, time.Now().Add(p.MaxAge).UTC().Format(http.TimeFormat))
+}
+
+// New creates a middleware to apply the cache policy to the responses, so the route groups can share consistent
+// cache headers. The policy is applied before writing the response header, it will not override the Cache-Control
+// header set by the handlers. The cacheable policies (without NoStore and NoCache) are not applied to the error
+// responses (status >= 400), such as 404 for a missing asset.
+//
+//	package main
+//
+//	import (
+//		"github.com/teambition/gear"
+//		"github.com/teambition/gear/middleware/cachepolicy"
+//	)
+//
+//	func main() {
+//		app := gear.New()
+//		api := gear.NewRouter(gear.RouterOptions{Root: "/api"})
+//		api.Use(cachepolicy.New(cachepolicy.NoStore))
+//		api.Get("/user", func(ctx *gear.Context) error {
+//			return ctx.JSON(200, map[string]string{"name": "gear"})
+//		})
+//		pages := gear.NewRouter()
+//		pages.Use(cachepolicy.New(cachepolicy.ShortLived))
+//		pages.Get("/", func(ctx *gear.Context) error {
+//			return ctx.HTML(200, "<h1>Hello, Gear!</h1>")
+//		})
+//		app.UseHandler(api)
+//		app.UseHandler(pages)
+//		app.Error(app.Listen(":3000"))
+//	}
+func New(p Policy) gear.Middleware {
+	cacheable := !p.NoStore && !p.NoCache
+	return func(ctx *gear.Context) error {
+		ctx.After(func() {
+			if ctx.Res.Get(gear.HeaderCacheControl) != "" {
+				return
+			}
+			if cacheable && ctx.Res.Status() >= 400 {
+				return
+			}
+			p.Apply(ctx)
+		})
+		return nil
+	}
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
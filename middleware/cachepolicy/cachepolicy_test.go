@@ -0,0 +1,111 @@
+package cachepolicy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func TestPolicyString(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("no-store", NoStore.String())
+	assert.Equal("public, max-age=31536000, immutable", ImmutableAssets.String())
+	assert.Equal("public, max-age=60, must-revalidate", ShortLived.String())
+	assert.Equal("max-age=0", Policy{}.String())
+	assert.Equal("no-store", Policy{NoStore: true, MaxAge: time.Hour}.String())
+	assert.Equal("private, no-cache, max-age=0", Policy{Private: true, Public: true, NoCache: true}.String())
+	assert.Equal("public, max-age=600, s-maxage=3600, stale-while-revalidate=30",
+		Policy{Public: true, MaxAge: 10 * time.Minute, SMaxAge: time.Hour, StaleWhileRevalidate: 30 * time.Second}.String())
+}
+
+func TestCachePolicy(t *testing.T) {
+	newApp := func(p Policy) *gear.App {
+		app := gear.New()
+		app.Use(New(p))
+		app.Use(func(ctx *gear.Context) error {
+			switch ctx.Path {
+			case "/custom":
+				ctx.SetHeader(gear.HeaderCacheControl, "private, max-age=10")
+			case "/missing":
+				return ctx.End(404, []byte("missing"))
+			case "/error":
+				return gear.ErrInternalServerError
+			}
+			return ctx.HTML(200, "ok")
+		})
+		return app
+	}
+	invoke := func(app *gear.App, path string) *http.Response {
+		return app.Invoke(httptest.NewRequest("GET", path, nil))
+	}
+
+	t.Run("NoStore", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := newApp(NoStore)
+		res := invoke(app, "/")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("no-store", res.Header.Get(gear.HeaderCacheControl))
+		assert.Equal("no-cache", res.Header.Get(gear.HeaderPragma))
+		assert.Equal("0", res.Header.Get(gear.HeaderExpires))
+
+		res = invoke(app, "/missing")
+		assert.Equal(404, res.StatusCode)
+		assert.Equal("no-store", res.Header.Get(gear.HeaderCacheControl))
+
+		res = invoke(app, "/custom")
+		assert.Equal("private, max-age=10", res.Header.Get(gear.HeaderCacheControl))
+		assert.Equal("", res.Header.Get(gear.HeaderPragma))
+	})
+
+	t.Run("ImmutableAssets", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := newApp(ImmutableAssets)
+		res := invoke(app, "/")
+		assert.Equal("public, max-age=31536000, immutable", res.Header.Get(gear.HeaderCacheControl))
+		assert.Equal("", res.Header.Get(gear.HeaderPragma))
+		expires, err := http.ParseTime(res.Header.Get(gear.HeaderExpires))
+		assert.Nil(err)
+		assert.InDelta(float64(time.Now().Add(365*24*time.Hour).Unix()), float64(expires.Unix()), 2)
+
+		res = invoke(app, "/missing")
+		assert.Equal(404, res.StatusCode)
+		assert.Equal("", res.Header.Get(gear.HeaderCacheControl))
+		assert.Equal("", res.Header.Get(gear.HeaderExpires))
+
+		res = invoke(app, "/error")
+		assert.Equal(500, res.StatusCode)
+		assert.Equal("", res.Header.Get(gear.HeaderCacheControl))
+	})
+
+	t.Run("ShortLived", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := newApp(ShortLived)
+		res := invoke(app, "/")
+		assert.Equal("public, max-age=60, must-revalidate", res.Header.Get(gear.HeaderCacheControl))
+		expires, err := http.ParseTime(res.Header.Get(gear.HeaderExpires))
+		assert.Nil(err)
+		assert.InDelta(float64(time.Now().Add(time.Minute).Unix()), float64(expires.Unix()), 2)
+	})
+
+	t.Run("Apply", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := gear.New()
+		app.Use(func(ctx *gear.Context) error {
+			NoStore.Apply(ctx)
+			ShortLived.Apply(ctx)
+			return ctx.HTML(200, "ok")
+		})
+		res := invoke(app, "/")
+		assert.Equal("public, max-age=60, must-revalidate", res.Header.Get(gear.HeaderCacheControl))
+		assert.Equal("", res.Header.Get(gear.HeaderPragma))
+	})
+}
@@ -0,0 +1,269 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/teambition/gear"
+)
+
+// Options is contract recorder options.
+type Options struct {
+	// Envs defines the app envs (`app.Set(gear.SetEnv, env)`) that the recorder is enabled in.
+	// Optional. Default to []string{"test"}.
+	Envs []string
+	// Dir is the directory that the golden files are written in, one file per route.
+	// Optional. Default to "testdata/contracts".
+	Dir string
+	// Headers defines the request and response headers to record, the recorded response headers
+	// are verified by Verify.
+	// Optional. Default to []string{"Content-Type"}.
+	Headers []string
+	// MaxBodyBytes defines the max bytes of request body and response body to record,
+	// the larger bodies are truncated and their interactions will not pass Verify.
+	// Optional. Default to 1MB.
+	MaxBodyBytes int
+}
+
+// Contract is the golden file content of a route.
+type Contract struct {
+	Route        string         `json:"route"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request/response pair.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the recorded request of an interaction.
+type Request struct {
+	Method string            `json:"method"`
+	URL    string            `json:"url"`
+	Header map[string]string `json:"header,omitempty"`
+	// JSON is the body if it is valid JSON, otherwise Body.
+	JSON json.RawMessage `json:"json,omitempty"`
+	Body string          `json:"body,omitempty"`
+}
+
+// Response is the recorded response of an interaction.
+type Response struct {
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	// JSON is the body if it is valid JSON, otherwise Body.
+	JSON json.RawMessage `json:"json,omitempty"`
+	Body string          `json:"body,omitempty"`
+}
+
+func (r *Request) key() string {
+	return r.Method + " " + r.URL + " " + string(r.JSON) + r.Body
+}
+
+// Recorder is a test middleware that records request/response pairs per route into golden files.
+type Recorder struct {
+	opts      Options
+	envs      map[string]bool
+	mu        sync.Mutex
+	contracts map[string]*Contract
+}
+
+// New creates a recorder to capture the request/response pairs of the matched routes into golden files,
+// one file per route (such as "testdata/contracts/GET_api_users_{id}.json" for "GET /api/users/:id").
+// An interaction with the same method, URL and request body replaces the recorded one, so the golden files
+// are stable on re-running. The golden files can be shared with the consumers, and be verified against the
+// service by Verify. It does nothing if app env is not in Options.Envs.
+//
+//	package main
+//
+//	import (
+//		"github.com/teambition/gear"
+//		"github.com/teambition/gear/middleware/contract"
+//	)
+//
+//	func main() {
+//		app := gear.New()
+//		app.Set(gear.SetEnv, "test")
+//		app.UseHandler(contract.New(contract.Options{Dir: "./contracts"}))
+//		router := gear.NewRouter()
+//		router.Get("/users/:id", func(ctx *gear.Context) error {
+//			return ctx.JSON(200, map[string]any{"id": ctx.Param("id"), "name": "gear"})
+//		})
+//		app.UseHandler(router)
+//		app.Error(app.Listen(":3000"))
+//	}
+func New(options ...Options) *Recorder {
+	opts := Options{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if len(opts.Envs) == 0 {
+		opts.Envs = []string{"test"}
+	}
+	if opts.Dir == "" {
+		opts.Dir = filepath.Join("testdata", "contracts")
+	}
+	if len(opts.Headers) == 0 {
+		opts.Headers = []string{gear.HeaderContentType}
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 1 << 20
+	}
+
+	r := &Recorder{opts: opts, envs: make(map[string]bool), contracts: make(map[string]*Contract)}
+	for _, env := range opts.Envs {
+		r.envs[env] = true
+	}
+	return r
+}
+
+// Serve implemented gear.Handler interface.
+func (r *Recorder) Serve(ctx *gear.Context) error {
+	if env, _ := ctx.Setting(gear.SetEnv).(string); !r.envs[env] {
+		return nil
+	}
+
+	in := &Interaction{Request: Request{
+		Method: ctx.Method,
+		URL:    ctx.Req.URL.RequestURI(),
+		Header: pickHeader(ctx.Req.Header, r.opts.Headers),
+	}}
+	if ctx.Req.Body != nil && ctx.Req.Body != http.NoBody {
+		buf, err := io.ReadAll(io.LimitReader(ctx.Req.Body, int64(r.opts.MaxBodyBytes)))
+		if err != nil {
+			return gear.ErrBadRequest.From(err)
+		}
+		in.Request.JSON, in.Request.Body = encodeBody(buf)
+		// the rest of body is still readable by the following middlewares.
+		ctx.Req.Body = readCloser{io.MultiReader(bytes.NewReader(buf), ctx.Req.Body), ctx.Req.Body}
+	}
+
+	ctx.OnEnd(func() {
+		pattern := gear.GetRouterPatternFromCtx(ctx)
+		if pattern == "" {
+			return
+		}
+		body := ctx.Res.Body()
+		if len(body) > r.opts.MaxBodyBytes {
+			body = body[:r.opts.MaxBodyBytes]
+		}
+		in.Response.Status = ctx.Res.Status()
+		in.Response.Header = pickHeader(ctx.Res.Header(), r.opts.Headers)
+		in.Response.JSON, in.Response.Body = encodeBody(body)
+		if err := r.record(ctx.Method+" "+pattern, in); err != nil {
+			ctx.LogErr(err)
+		}
+	})
+	return nil
+}
+
+func (r *Recorder) record(route string, in *Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file := filepath.Join(r.opts.Dir, FileName(route))
+	c := r.contracts[route]
+	if c == nil {
+		// merge with the golden file recorded before.
+		c = &Contract{Route: route}
+		if data, err := os.ReadFile(file); err == nil {
+			if err := json.Unmarshal(data, c); err != nil {
+				return errors.New("contract: invalid golden file " + file + ": " + err.Error())
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		r.contracts[route] = c
+	}
+
+	replaced := false
+	for i, item := range c.Interactions {
+		if item.Request.key() == in.Request.key() {
+			c.Interactions[i] = in
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		c.Interactions = append(c.Interactions, in)
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.opts.Dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0o644)
+}
+
+// FileName returns the golden file name of the route, such as "GET_api_users_{id}.json"
+// for "GET /api/users/:id".
+func FileName(route string) string {
+	method, pattern, _ := strings.Cut(route, " ")
+	b := strings.Builder{}
+	b.WriteString(strings.ToUpper(method))
+	for _, seg := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		if seg == "" {
+			continue
+		}
+		b.WriteByte('_')
+		switch seg[0] {
+		case ':':
+			seg = "{" + seg[1:] + "}"
+		case '*':
+			seg = "{*" + seg[1:] + "}"
+		}
+		for _, c := range seg {
+			switch {
+			case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+				c == '-', c == '.', c == '{', c == '}', c == '*':
+				b.WriteRune(c)
+			default:
+				b.WriteByte('-')
+			}
+		}
+	}
+	b.WriteString(".json")
+	return b.String()
+}
+
+func pickHeader(header http.Header, keys []string) map[string]string {
+	var res map[string]string
+	for _, key := range keys {
+		if val := header.Get(key); val != "" {
+			if res == nil {
+				res = make(map[string]string)
+			}
+			res[http.CanonicalHeaderKey(key)] = val
+		}
+	}
+	return res
+}
+
+func encodeBody(buf []byte) (json.RawMessage, string) {
+	if len(buf) == 0 {
+		return nil, ""
+	}
+	if json.Valid(buf) {
+		dst := &bytes.Buffer{}
+		if err := json.Compact(dst, buf); err == nil {
+			return dst.Bytes(), ""
+		}
+	}
+	return nil, string(buf)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,146 @@
+package contract
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func newApp(env string, r *Recorder) *gear.App {
+	app := gear.New()
+	app.Set(gear.SetEnv, env)
+	app.UseHandler(r)
+	router := gear.NewRouter(gear.RouterOptions{Root: "/api"})
+	router.Get("/users/:id", func(ctx *gear.Context) error {
+		return ctx.JSON(200, map[string]any{"id": ctx.Param("id"), "name": "gear"})
+	})
+	router.Post("/users", func(ctx *gear.Context) error {
+		body := map[string]any{}
+		if err := json.NewDecoder(ctx.Req.Body).Decode(&body); err != nil {
+			return gear.ErrBadRequest.From(err)
+		}
+		body["id"] = "new"
+		return ctx.JSON(201, body)
+	})
+	router.Get("/text", func(ctx *gear.Context) error {
+		ctx.Type(gear.MIMETextPlainCharsetUTF8)
+		return ctx.End(200, []byte("hello"))
+	})
+	app.UseHandler(router)
+	return app
+}
+
+func request(t *testing.T, host, method, url, body string) {
+	req, err := http.NewRequest(method, host+url, strings.NewReader(body))
+	assert.Nil(t, err)
+	if body != "" {
+		req.Header.Set(gear.HeaderContentType, gear.MIMEApplicationJSON)
+	}
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	res.Body.Close()
+}
+
+func readContract(t *testing.T, file string) *Contract {
+	var data []byte
+	var err error
+	for i := 0; i < 20; i++ { // the interactions are recorded in end hooks
+		if data, err = os.ReadFile(file); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, err)
+	c := &Contract{}
+	assert.Nil(t, json.Unmarshal(data, c))
+	return c
+}
+
+func compact(raw json.RawMessage) string {
+	buf, _ := encodeBody(raw)
+	return string(buf)
+}
+
+func TestGearMiddlewareContract(t *testing.T) {
+	t.Run("should record interactions per route", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := t.TempDir()
+		r := New(Options{Dir: dir})
+		host := "http://" + newApp("test", r).Start().Addr().String()
+
+		request(t, host, "GET", "/api/users/1?fields=name", "")
+		c := readContract(t, filepath.Join(dir, "GET_api_users_{id}.json"))
+		assert.Equal("GET /api/users/:id", c.Route)
+		assert.Equal(1, len(c.Interactions))
+		in := c.Interactions[0]
+		assert.Equal("GET", in.Request.Method)
+		assert.Equal("/api/users/1?fields=name", in.Request.URL)
+		assert.Equal(200, in.Response.Status)
+		assert.Equal(map[string]string{"Content-Type": "application/json; charset=utf-8"}, in.Response.Header)
+		assert.Equal(`{"id":"1","name":"gear"}`, compact(in.Response.JSON))
+
+		request(t, host, "POST", "/api/users", `{"name": "gear"}`)
+		c = readContract(t, filepath.Join(dir, "POST_api_users.json"))
+		in = c.Interactions[0]
+		assert.Equal(map[string]string{"Content-Type": "application/json"}, in.Request.Header)
+		assert.Equal(`{"name":"gear"}`, compact(in.Request.JSON))
+		assert.Equal(201, in.Response.Status)
+		assert.Equal(`{"id":"new","name":"gear"}`, compact(in.Response.JSON))
+
+		request(t, host, "GET", "/api/text", "")
+		c = readContract(t, filepath.Join(dir, "GET_api_text.json"))
+		assert.Nil(c.Interactions[0].Response.JSON)
+		assert.Equal("hello", c.Interactions[0].Response.Body)
+
+		request(t, host, "GET", "/api/unknown", "")
+		files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		assert.Equal(3, len(files))
+	})
+
+	t.Run("should merge with the recorded golden files", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := t.TempDir()
+		file := filepath.Join(dir, "GET_api_users_{id}.json")
+		host := "http://" + newApp("test", New(Options{Dir: dir})).Start().Addr().String()
+		request(t, host, "GET", "/api/users/1", "")
+		assert.Equal(1, len(readContract(t, file).Interactions))
+
+		host = "http://" + newApp("test", New(Options{Dir: dir})).Start().Addr().String()
+		request(t, host, "GET", "/api/users/1", "")
+		request(t, host, "GET", "/api/users/2", "")
+		time.Sleep(50 * time.Millisecond)
+		c := readContract(t, file)
+		assert.Equal(2, len(c.Interactions))
+		assert.Equal("/api/users/1", c.Interactions[0].Request.URL)
+		assert.Equal("/api/users/2", c.Interactions[1].Request.URL)
+	})
+
+	t.Run("should do nothing if env is not enabled", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := t.TempDir()
+		host := "http://" + newApp("development", New(Options{Dir: dir})).Start().Addr().String()
+		request(t, host, "GET", "/api/users/1", "")
+		time.Sleep(50 * time.Millisecond)
+		files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		assert.Equal(0, len(files))
+	})
+}
+
+func TestFileName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("GET.json", FileName("GET /"))
+	assert.Equal("GET_api_users_{id}.json", FileName("get /api/users/:id"))
+	assert.Equal("GET_files_{*path}.json", FileName("GET /files/*path"))
+	assert.Equal("GET_{id---d---}_v1.0.json", FileName(`GET /:id(^\d+$)/v1.0`))
+}
@@ -0,0 +1,111 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// Verify replays the interactions in the golden files of dir against the handler (such as a gear.App),
+// and returns the mismatches of status, recorded response headers and body as a joined error, or nil if
+// all of them are satisfied. The JSON bodies are compared semantically. The golden files may be recorded
+// by the service itself or by the consumers for consumer-driven contract testing:
+//
+//	func TestContracts(t *testing.T) {
+//		app := newApp() // the gear app of the service
+//		if err := contract.Verify(app, "testdata/contracts"); err != nil {
+//			t.Error(err)
+//		}
+//	}
+func Verify(handler http.Handler, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("contract: no golden files in %q", dir)
+	}
+
+	var errs []error
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c := &Contract{}
+		if err := json.Unmarshal(data, c); err != nil {
+			errs = append(errs, fmt.Errorf("contract: invalid golden file %s: %w", file, err))
+			continue
+		}
+		for _, in := range c.Interactions {
+			for _, msg := range replay(handler, in) {
+				errs = append(errs, fmt.Errorf("contract: %s %s %s: %s",
+					filepath.Base(file), in.Request.Method, in.Request.URL, msg))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func replay(handler http.Handler, in *Interaction) []string {
+	var body io.Reader
+	switch {
+	case len(in.Request.JSON) > 0:
+		body = bytes.NewReader(in.Request.JSON)
+	case in.Request.Body != "":
+		body = bytes.NewReader([]byte(in.Request.Body))
+	}
+	req := httptest.NewRequest(in.Request.Method, in.Request.URL, body)
+	for key, val := range in.Request.Header {
+		req.Header.Set(key, val)
+	}
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	var msgs []string
+	if res.Code != in.Response.Status {
+		msgs = append(msgs, fmt.Sprintf("status %d, expected %d", res.Code, in.Response.Status))
+	}
+	for key, val := range in.Response.Header {
+		if got := res.Header().Get(key); got != val {
+			msgs = append(msgs, fmt.Sprintf("header %s %q, expected %q", key, got, val))
+		}
+	}
+
+	gotJSON, gotBody := encodeBody(res.Body.Bytes())
+	switch {
+	case len(in.Response.JSON) > 0:
+		if !equalJSON(gotJSON, in.Response.JSON) {
+			if gotJSON == nil {
+				gotJSON = json.RawMessage(fmt.Sprintf("%q", gotBody))
+			}
+			want, _ := encodeBody(in.Response.JSON)
+			msgs = append(msgs, fmt.Sprintf("body %s, expected %s", gotJSON, want))
+		}
+	case gotJSON != nil || gotBody != in.Response.Body:
+		if gotJSON != nil {
+			gotBody = string(gotJSON)
+		}
+		msgs = append(msgs, fmt.Sprintf("body %q, expected %q", gotBody, in.Response.Body))
+	}
+	return msgs
+}
+
+func equalJSON(a, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	var x, y any
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
@@ -0,0 +1,76 @@
+package contract
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func TestVerify(t *testing.T) {
+	t.Run("should verify recorded interactions", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := t.TempDir()
+		host := "http://" + newApp("test", New(Options{Dir: dir})).Start().Addr().String()
+		request(t, host, "GET", "/api/users/1", "")
+		request(t, host, "POST", "/api/users", `{"name":"gear"}`)
+		request(t, host, "GET", "/api/text", "")
+		readContract(t, filepath.Join(dir, "GET_api_text.json"))
+
+		assert.Nil(Verify(newApp("production", New()), dir))
+
+		// the service drifts from the contracts
+		app := gear.New()
+		router := gear.NewRouter(gear.RouterOptions{Root: "/api"})
+		router.Get("/users/:id", func(ctx *gear.Context) error {
+			return ctx.JSON(200, map[string]any{"id": ctx.Param("id"), "username": "gear"})
+		})
+		router.Post("/users", func(ctx *gear.Context) error {
+			return ctx.JSON(200, map[string]any{"name": "gear", "id": "new"})
+		})
+		router.Get("/text", func(ctx *gear.Context) error {
+			return ctx.HTML(200, "hello")
+		})
+		app.UseHandler(router)
+
+		err := Verify(app, dir)
+		assert.NotNil(err)
+		assert.Equal(strings.Join([]string{
+			`contract: GET_api_text.json GET /api/text: header Content-Type "text/html; charset=utf-8", expected "text/plain; charset=utf-8"`,
+			`contract: GET_api_users_{id}.json GET /api/users/1: body {"id":"1","username":"gear"}, expected {"id":"1","name":"gear"}`,
+			`contract: POST_api_users.json POST /api/users: status 200, expected 201`,
+		}, "\n"), err.Error())
+	})
+
+	t.Run("should verify golden files from consumers", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := t.TempDir()
+		assert.Nil(os.WriteFile(filepath.Join(dir, "GET_api_users_{id}.json"), []byte(`{
+			"route": "GET /api/users/:id",
+			"interactions": [{
+				"request": {"method": "GET", "url": "/api/users/2"},
+				"response": {"status": 200, "json": {"name": "gear", "id": "2"}}
+			}, {
+				"request": {"method": "GET", "url": "/api/users/3"},
+				"response": {"status": 200, "body": "3"}
+			}]
+		}`), 0o644))
+		err := Verify(newApp("production", New()), dir)
+		assert.Equal(`contract: GET_api_users_{id}.json GET /api/users/3: body "{\"id\":\"3\",\"name\":\"gear\"}", expected "3"`, err.Error())
+	})
+
+	t.Run("should return error for invalid dir or file", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dir := t.TempDir()
+		assert.Equal(`contract: no golden files in "`+dir+`"`, Verify(newApp("test", New()), dir).Error())
+
+		assert.Nil(os.WriteFile(filepath.Join(dir, "GET.json"), []byte(`{`), 0o644))
+		assert.Contains(Verify(newApp("test", New()), dir).Error(), "contract: invalid golden file")
+	})
+}
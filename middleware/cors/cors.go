@@ -16,7 +16,8 @@ type Options struct {
 	AllowOrigins []string
 	// AllowMethods defines the methods which will be allowed to access
 	// the resource. It is used in handling the preflighted requests.
-	// Default value is []string{"GET", "HEAD", "PUT", "POST", "DELETE", "PATCH"} .
+	// Default value is []string{"GET", "HEAD", "PUT", "POST", "DELETE", "PATCH"} ,
+	// or the route's allowed methods when used as gear.RouterOptions.Preflight .
 	AllowMethods []string
 	// AllowOriginsValidator validates the request Origin by validator
 	// function.The validator function accpects an `*gear.Context` and returns the
@@ -49,6 +50,13 @@ var (
 )
 
 // New creates a middleware to provide CORS support for gear.
+// It can also be used as gear.RouterOptions.Preflight to answer preflighted requests in router:
+//
+//	router := gear.NewRouter(gear.RouterOptions{
+//		Root:       "/api",
+//		IgnoreCase: true,
+//		Preflight:  cors.New(cors.Options{MaxAge: time.Hour}),
+//	})
 func New(options ...Options) gear.Middleware {
 	opts := Options{}
 	if len(options) > 0 {
@@ -57,6 +65,7 @@ func New(options ...Options) gear.Middleware {
 	if opts.AllowOrigins == nil {
 		opts.AllowOrigins = defaultAllowOrigins
 	}
+	routeMethods := opts.AllowMethods == nil
 	if opts.AllowMethods == nil {
 		opts.AllowMethods = defaultAllowMethods
 	}
@@ -114,7 +123,10 @@ func New(options ...Options) gear.Middleware {
 				ctx.Res.Del(gear.HeaderAccessControlAllowCredentials)
 				return ctx.End(http.StatusOK)
 			}
-			if len(opts.AllowMethods) > 0 {
+			// The "Allow" header is set by router's auto-OPTIONS with the route's allowed methods.
+			if allow := ctx.Res.Get(gear.HeaderAllow); routeMethods && allow != "" {
+				ctx.SetHeader(gear.HeaderAccessControlAllowMethods, allow)
+			} else if len(opts.AllowMethods) > 0 {
 				ctx.SetHeader(gear.HeaderAccessControlAllowMethods, strings.Join(opts.AllowMethods, ", "))
 			}
 
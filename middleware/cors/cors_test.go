@@ -197,3 +197,52 @@ func TestGearMiddlewareCORS(t *testing.T) {
 		})
 	})
 }
+
+func TestGearMiddlewareCORSPreflight(t *testing.T) {
+	assert := assert.New(t)
+
+	called := 0
+	router := gear.NewRouter(gear.RouterOptions{
+		Root:      "/api",
+		Preflight: New(Options{MaxAge: time.Hour}),
+	})
+	router.Use(func(ctx *gear.Context) error {
+		called++
+		return nil
+	})
+	router.Get("/user", func(ctx *gear.Context) error {
+		return ctx.HTML(200, "OK")
+	})
+	router.Put("/user", func(ctx *gear.Context) error {
+		return ctx.HTML(200, "OK")
+	})
+
+	app := gear.New()
+	app.UseHandler(router)
+	srv := app.Start()
+	defer srv.Close()
+	url := "http://" + srv.Addr().String() + "/api/user"
+
+	req, err := http.NewRequest(http.MethodOptions, url, nil)
+	assert.Nil(err)
+	req.Header.Set(gear.HeaderOrigin, "test.org")
+	req.Header.Set(gear.HeaderAccessControlRequestMethod, http.MethodPut)
+	res, err := DefaultClient.Do(req)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("test.org", res.Header.Get(gear.HeaderAccessControlAllowOrigin))
+	assert.Equal("GET, PUT", res.Header.Get(gear.HeaderAccessControlAllowMethods))
+	assert.Equal("3600", res.Header.Get(gear.HeaderAccessControlMaxAge))
+	res.Body.Close()
+
+	req, err = http.NewRequest(http.MethodOptions, url, nil)
+	assert.Nil(err)
+	res, err = DefaultClient.Do(req)
+	assert.Nil(err)
+	assert.Equal(http.StatusNoContent, res.StatusCode)
+	assert.Equal("GET, PUT", res.Header.Get(gear.HeaderAllow))
+	assert.Equal("", res.Header.Get(gear.HeaderAccessControlAllowOrigin))
+	res.Body.Close()
+
+	assert.Equal(0, called)
+}
@@ -0,0 +1,211 @@
+package csrf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/teambition/gear"
+)
+
+const secretLen = 32
+
+// Store keeps the secret of the client that the tokens are generated from and verified with.
+// The synchronizer token pattern can be used by implementing it with the session of the client.
+type Store interface {
+	// Get returns the secret of the client, or "" if not exists.
+	Get(ctx *gear.Context) (string, error)
+	// Set saves the secret of the client.
+	Set(ctx *gear.Context, secret string) error
+}
+
+// Options is csrf middleware options.
+type Options struct {
+	// Store keeps the secret of the client.
+	// Optional. Default to the double submit cookie pattern, the secret is kept in a cookie with the Cookie* options.
+	Store Store
+	// CookieName is the name of the secret cookie, use "__Host-csrf" with CookieSecure for HTTPS sites
+	// to prevent the cookie from being injected by subdomains.
+	// Optional. Default to "_csrf".
+	CookieName string
+	// CookiePath is the path of the secret cookie.
+	// Optional. Default to "/".
+	CookiePath string
+	// CookieDomain is the domain of the secret cookie.
+	// Optional. Default to "".
+	CookieDomain string
+	// CookieSecure sets the Secure attribute of the secret cookie.
+	// Optional. Default to false.
+	CookieSecure bool
+	// CookieSameSite sets the SameSite attribute of the secret cookie.
+	// Optional. Default to http.SameSiteLaxMode.
+	CookieSameSite http.SameSite
+	// CookieMaxAge is the max age of the secret cookie.
+	// Optional. Default to 0, the cookie is a session cookie.
+	CookieMaxAge time.Duration
+	// Header is the request header to read the token.
+	// Optional. Default to gear.HeaderXCSRFToken.
+	Header string
+	// FormField is the field of "application/x-www-form-urlencoded" request body to read the token,
+	// if the token is not in the Header. The body can still be parsed by ctx.ParseBody.
+	// Optional. Default to "_csrf".
+	FormField string
+	// TrustedOrigins are the origins (such as "https://example.com") allowed to send unsafe requests
+	// besides the request host. The Origin (or Referer if no Origin) request header is checked if present.
+	// Optional. Default to nil.
+	TrustedOrigins []string
+	// Skipper returns true to skip the protection for the request, such as the API requests with bearer tokens.
+	// Optional. Default to nil.
+	Skipper func(ctx *gear.Context) bool
+}
+
+type secretKey struct{}
+
+// New creates a middleware to protect against Cross-Site Request Forgery. The unsafe requests (not GET, HEAD,
+// OPTIONS and TRACE) without a valid token are rejected with 403 Forbidden. The token can be retrieved by
+// TokenFromCtx and sent by the X-CSRF-Token header or the "_csrf" form field:
+//
+//	package main
+//
+//	import (
+//		"github.com/teambition/gear"
+//		"github.com/teambition/gear/middleware/csrf"
+//	)
+//
+//	func main() {
+//		app := gear.New()
+//		app.Use(csrf.New(csrf.Options{
+//			TrustedOrigins: []string{"https://admin.example.com"},
+//		}))
+//		app.Use(func(ctx *gear.Context) error {
+//			return ctx.HTML(200, `<form method="POST"><input type="hidden" name="_csrf" value="`+
+//				csrf.TokenFromCtx(ctx)+`"><button>Submit</button></form>`)
+//		})
+//		app.Error(app.Listen(":3000"))
+//	}
+func New(options ...Options) gear.Middleware {
+	opts := Options{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.CookieName == "" {
+		opts.CookieName = "_csrf"
+	}
+	if opts.CookiePath == "" {
+		opts.CookiePath = "/"
+	}
+	if opts.CookieSameSite == 0 {
+		opts.CookieSameSite = http.SameSiteLaxMode
+	}
+	if opts.Header == "" {
+		opts.Header = gear.HeaderXCSRFToken
+	}
+	if opts.FormField == "" {
+		opts.FormField = "_csrf"
+	}
+	if opts.Store == nil {
+		opts.Store = &cookieStore{&opts}
+	}
+
+	return func(ctx *gear.Context) error {
+		if opts.Skipper != nil && opts.Skipper(ctx) {
+			return nil
+		}
+
+		secret, err := opts.Store.Get(ctx)
+		if err != nil {
+			return err
+		}
+		switch ctx.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		default:
+			if err := gear.VerifyOrigin(ctx, opts.TrustedOrigins...); err != nil {
+				return err
+			}
+			if secret == "" || !gear.VerifyCSRFToken(readToken(ctx, &opts), secret) {
+				return gear.ErrForbidden.WithMsg("invalid CSRF token")
+			}
+		}
+
+		if secret == "" {
+			if secret, err = gear.NewCSRFSecret(); err == nil {
+				err = opts.Store.Set(ctx, secret)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		ctx.SetAny(secretKey{}, secret)
+		return nil
+	}
+}
+
+// TokenFromCtx returns a token for the request, it should be sent back by the X-CSRF-Token header
+// (or the Header option) or the "_csrf" form field (or the FormField option) with the unsafe requests.
+// The token is masked randomly on every call to mitigate the BREACH attack, all of them are valid.
+// It returns "" if the csrf middleware is not used.
+func TokenFromCtx(ctx *gear.Context) string {
+	val, _ := ctx.Any(secretKey{})
+	secret, _ := val.(string)
+	if secret == "" {
+		return ""
+	}
+	return gear.NewCSRFToken(secret)
+}
+
+func readToken(ctx *gear.Context, opts *Options) string {
+	if token := ctx.GetHeader(opts.Header); token != "" {
+		return token
+	}
+	mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader(gear.HeaderContentType))
+	if mediaType != gear.MIMEApplicationForm || ctx.Req.Body == nil {
+		return ""
+	}
+
+	// read the form body (up to 1MB) and restore it, so it can be parsed by ctx.ParseBody later.
+	body := ctx.Req.Body
+	buf, err := io.ReadAll(io.LimitReader(body, 1<<20))
+	ctx.Req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), body), body}
+	if err != nil {
+		return ""
+	}
+	values, _ := url.ParseQuery(string(buf))
+	return values.Get(opts.FormField)
+}
+
+// cookieStore keeps the secret in cookie for the double submit cookie pattern.
+type cookieStore struct {
+	opts *Options
+}
+
+func (s *cookieStore) Get(ctx *gear.Context) (string, error) {
+	c, err := ctx.Req.Cookie(s.opts.CookieName)
+	if err != nil {
+		return "", nil
+	}
+	if key, err := base64.RawURLEncoding.DecodeString(c.Value); err != nil || len(key) != secretLen {
+		return "", nil // ignore invalid secret, a new one will be set
+	}
+	return c.Value, nil
+}
+
+func (s *cookieStore) Set(ctx *gear.Context, secret string) error {
+	http.SetCookie(ctx.Res, &http.Cookie{
+		Name:     s.opts.CookieName,
+		Value:    secret,
+		Path:     s.opts.CookiePath,
+		Domain:   s.opts.CookieDomain,
+		MaxAge:   int(s.opts.CookieMaxAge / time.Second),
+		Secure:   s.opts.CookieSecure,
+		HttpOnly: true,
+		SameSite: s.opts.CookieSameSite,
+	})
+	return nil
+}
@@ -0,0 +1,194 @@
+package csrf
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+type form struct {
+	Name string `form:"name"`
+}
+
+func (f *form) Validate() error {
+	return nil
+}
+
+type response struct {
+	status int
+	body   string
+	cookie *http.Cookie
+}
+
+func request(t *testing.T, method, url, body string, cookie *http.Cookie, header ...string) response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.Nil(t, err)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer res.Body.Close()
+	buf, _ := io.ReadAll(res.Body)
+	r := response{status: res.StatusCode, body: string(buf)}
+	if cookies := res.Cookies(); len(cookies) > 0 {
+		r.cookie = cookies[0]
+	}
+	return r
+}
+
+func newApp(opts Options) string {
+	app := gear.New()
+	app.Use(New(opts))
+	app.Use(func(ctx *gear.Context) error {
+		if ctx.Method == http.MethodGet {
+			return ctx.HTML(200, TokenFromCtx(ctx))
+		}
+		f := form{}
+		if ctx.GetHeader(gear.HeaderContentType) == gear.MIMEApplicationForm {
+			if err := ctx.ParseBody(&f); err != nil {
+				return err
+			}
+		}
+		return ctx.HTML(200, "OK"+f.Name)
+	})
+	return "http://" + app.Start().Addr().String()
+}
+
+func TestGearMiddlewareCSRF(t *testing.T) {
+	t.Run("should work with double submit cookie", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newApp(Options{})
+		res := request(t, "POST", host, "", nil)
+		assert.Equal(403, res.status)
+		assert.Equal(`{"error":"Forbidden","message":"invalid CSRF token"}`, res.body)
+		assert.Nil(res.cookie)
+
+		res = request(t, "GET", host, "", nil)
+		assert.Equal(200, res.status)
+		cookie := res.cookie
+		token := res.body
+		assert.Equal("_csrf", cookie.Name)
+		assert.True(cookie.HttpOnly)
+		assert.Equal(http.SameSiteLaxMode, cookie.SameSite)
+		assert.Equal(43, len(cookie.Value)) // 32 bytes secret
+		assert.Equal(86, len(token))
+		assert.NotEqual(cookie.Value, token)
+
+		res = request(t, "GET", host, "", cookie)
+		assert.Nil(res.cookie)
+		assert.NotEqual(token, res.body) // masked randomly
+		token2 := res.body
+
+		for _, tk := range []string{token, token2} {
+			res = request(t, "POST", host, "", cookie, gear.HeaderXCSRFToken, tk)
+			assert.Equal(200, res.status)
+			assert.Equal("OK", res.body)
+		}
+
+		res = request(t, "PUT", host, "", nil, gear.HeaderXCSRFToken, token)
+		assert.Equal(403, res.status)
+		res = request(t, "DELETE", host, "", cookie, gear.HeaderXCSRFToken, token[1:])
+		assert.Equal(403, res.status)
+		res = request(t, "DELETE", host, "", cookie, gear.HeaderXCSRFToken, cookie.Value)
+		assert.Equal(403, res.status)
+
+		res = request(t, "GET", host, "", nil)
+		res = request(t, "POST", host, "", res.cookie, gear.HeaderXCSRFToken, token)
+		assert.Equal(403, res.status)
+	})
+
+	t.Run("should read token from form body", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newApp(Options{FormField: "token"})
+		res := request(t, "GET", host, "", nil)
+		cookie, token := res.cookie, res.body
+
+		res = request(t, "POST", host, "name=gear&token="+token, cookie, gear.HeaderContentType, gear.MIMEApplicationForm)
+		assert.Equal(200, res.status)
+		assert.Equal("OKgear", res.body)
+
+		res = request(t, "POST", host, "name=gear&_csrf="+token, cookie, gear.HeaderContentType, gear.MIMEApplicationForm)
+		assert.Equal(403, res.status)
+	})
+
+	t.Run("should check origin", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newApp(Options{TrustedOrigins: []string{"https://Admin.example.com/"}})
+		res := request(t, "GET", host, "", nil)
+		cookie, token := res.cookie, res.body
+
+		for origin, status := range map[string]int{
+			host:                        200,
+			"https://admin.example.com": 200,
+			"https://evil.example.com":  403,
+			"null":                      403,
+		} {
+			res = request(t, "POST", host, "", cookie, gear.HeaderXCSRFToken, token, gear.HeaderOrigin, origin)
+			assert.Equal(status, res.status, origin)
+		}
+
+		res = request(t, "POST", host, "", cookie, gear.HeaderXCSRFToken, token, gear.HeaderReferer, host+"/form")
+		assert.Equal(200, res.status)
+		res = request(t, "POST", host, "", cookie, gear.HeaderXCSRFToken, token, gear.HeaderReferer, "https://evil.example.com/form")
+		assert.Equal(403, res.status)
+		assert.Equal(`{"error":"Forbidden","message":"untrusted origin: https://evil.example.com"}`, res.body)
+	})
+
+	t.Run("should work with custom store and skipper", func(t *testing.T) {
+		assert := assert.New(t)
+
+		store := &memoryStore{}
+		host := newApp(Options{
+			Store:   store,
+			Header:  "X-Token",
+			Skipper: func(ctx *gear.Context) bool { return ctx.GetHeader(gear.HeaderAuthorization) != "" },
+		})
+		res := request(t, "GET", host, "", nil)
+		assert.Nil(res.cookie)
+		assert.NotEqual("", store.secret)
+		token := res.body
+
+		res = request(t, "POST", host, "", nil, "X-Token", token)
+		assert.Equal(200, res.status)
+		res = request(t, "POST", host, "", nil, gear.HeaderXCSRFToken, token)
+		assert.Equal(403, res.status)
+		res = request(t, "POST", host, "", nil, gear.HeaderAuthorization, "Bearer abc")
+		assert.Equal(200, res.status)
+	})
+
+	t.Run("TokenFromCtx should return empty without middleware", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := gear.New()
+		app.Use(func(ctx *gear.Context) error {
+			return ctx.HTML(200, "token:"+TokenFromCtx(ctx))
+		})
+		res := request(t, "GET", "http://"+app.Start().Addr().String(), "", nil)
+		assert.Equal("token:", res.body)
+	})
+}
+
+// memoryStore keeps one secret for all clients, like a session store for testing.
+type memoryStore struct {
+	secret string
+}
+
+func (s *memoryStore) Get(ctx *gear.Context) (string, error) {
+	return s.secret, nil
+}
+
+func (s *memoryStore) Set(ctx *gear.Context, secret string) error {
+	s.secret = secret
+	return nil
+}
@@ -0,0 +1,174 @@
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teambition/gear"
+)
+
+// Deadline budget headers.
+const (
+	// HeaderXRequestTimeout is the remaining budget of the request, in milliseconds, such as "1500".
+	// A Go duration string (such as "1.5s") is accepted too.
+	HeaderXRequestTimeout = "X-Request-Timeout"
+	// HeaderGRPCTimeout is the gRPC style timeout header, such as "1500m".
+	// https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md
+	HeaderGRPCTimeout = "Grpc-Timeout"
+)
+
+// Options is deadline middleware options.
+type Options struct {
+	// Headers defines the headers to read the inbound budget from, the first valid one is used.
+	// Optional. Default to []string{"X-Request-Timeout", "Grpc-Timeout"}.
+	Headers []string
+	// MaxTimeout limits the inbound budget.
+	// Optional. No limit by default (the app timeout by `gear.SetTimeout` still works).
+	MaxTimeout time.Duration
+}
+
+// New creates a middleware to read the inbound deadline budget from header, and set ctx deadline
+// to min(app timeout, remaining budget). It responds 504 if the budget is exhausted already.
+// Use Propagate or Transport to write the decremented budget to outbound requests.
+//
+//	package main
+//
+//	import (
+//		"github.com/teambition/gear"
+//		"github.com/teambition/gear/middleware/deadline"
+//	)
+//
+//	func main() {
+//		app := gear.New()
+//		app.Set(gear.SetTimeout, 10*time.Second)
+//		app.Use(deadline.New())
+//		app.Use(func(ctx *gear.Context) error {
+//			req, _ := http.NewRequestWithContext(ctx, "GET", "http://user-service/users", nil)
+//			if err := deadline.Propagate(ctx, req); err != nil {
+//				return err
+//			}
+//			// do request...
+//		})
+//		app.Error(app.Listen(":3000"))
+//	}
+func New(options ...Options) gear.Middleware {
+	opts := Options{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if len(opts.Headers) == 0 {
+		opts.Headers = []string{HeaderXRequestTimeout, HeaderGRPCTimeout}
+	}
+
+	return func(ctx *gear.Context) error {
+		budget, ok := time.Duration(0), false
+		for _, name := range opts.Headers {
+			if budget, ok = ParseTimeout(ctx.GetHeader(name)); ok {
+				break
+			}
+		}
+		if !ok {
+			return nil
+		}
+		if budget <= 0 {
+			return gear.ErrGatewayTimeout.WithMsg("request deadline budget exhausted")
+		}
+		if opts.MaxTimeout > 0 && budget > opts.MaxTimeout {
+			budget = opts.MaxTimeout
+		}
+
+		// context.WithTimeout keeps the earlier deadline of the app timeout and the budget.
+		c, cancel := context.WithTimeout(ctx.Context(), budget)
+		ctx.OnEnd(cancel)
+		ctx.WithContext(c)
+		return nil
+	}
+}
+
+// ParseTimeout parses a timeout header value, supports milliseconds ("1500"), Go duration ("1.5s")
+// and gRPC timeout ("1500m", units: H, M, S, m, u, n).
+func ParseTimeout(val string) (time.Duration, bool) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return 0, false
+	}
+	if ms, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return time.Duration(ms) * time.Millisecond, true
+	}
+
+	if n, err := strconv.ParseInt(val[:len(val)-1], 10, 64); err == nil && n >= 0 {
+		var unit time.Duration
+		switch val[len(val)-1] {
+		case 'H':
+			unit = time.Hour
+		case 'M':
+			unit = time.Minute
+		case 'S':
+			unit = time.Second
+		case 'm':
+			unit = time.Millisecond
+		case 'u':
+			unit = time.Microsecond
+		case 'n':
+			unit = time.Nanosecond
+		}
+		if unit > 0 {
+			return time.Duration(n) * unit, true
+		}
+	}
+
+	if d, err := time.ParseDuration(val); err == nil {
+		return d, true
+	}
+	return 0, false
+}
+
+// Propagate writes the remaining budget of ctx deadline to the outbound request's
+// "X-Request-Timeout" header (in milliseconds). Nothing will be written if ctx has no deadline.
+// It returns context.DeadlineExceeded if the budget is exhausted already.
+func Propagate(ctx context.Context, req *http.Request) error {
+	d, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	budget := time.Until(d)
+	if budget <= 0 {
+		return context.DeadlineExceeded
+	}
+	ms := budget.Milliseconds()
+	if ms == 0 {
+		ms = 1
+	}
+	req.Header.Set(HeaderXRequestTimeout, strconv.FormatInt(ms, 10))
+	return nil
+}
+
+// Transport wraps a http.RoundTripper (http.DefaultTransport if nil) to propagate
+// the deadline budget of the request's context on every outbound request.
+//
+//	client := &http.Client{Transport: deadline.Transport(nil)}
+//	req, _ := http.NewRequestWithContext(ctx, "GET", "http://user-service/users", nil)
+//	res, err := client.Do(req)
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		if _, ok := req.Context().Deadline(); ok {
+			req = req.Clone(req.Context()) // RoundTripper should not modify request
+			if err := Propagate(req.Context(), req); err != nil {
+				return nil, err
+			}
+		}
+		return rt.RoundTrip(req)
+	})
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (fn roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
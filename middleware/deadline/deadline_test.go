@@ -0,0 +1,152 @@
+package deadline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func TestGearMiddlewareDeadline(t *testing.T) {
+	upstream := gear.New()
+	upstream.Use(func(ctx *gear.Context) error {
+		return ctx.HTML(http.StatusOK, ctx.GetHeader(HeaderXRequestTimeout))
+	})
+	upSrv := upstream.Start()
+	defer upSrv.Close()
+
+	app := gear.New()
+	app.Set(gear.SetTimeout, time.Second)
+	app.Use(New(Options{MaxTimeout: 500 * time.Millisecond}))
+	app.Use(func(ctx *gear.Context) error {
+		switch ctx.Path {
+		case "/wait":
+			<-ctx.Done()
+			return nil
+		case "/proxy":
+			client := &http.Client{Transport: Transport(nil)}
+			req, _ := http.NewRequestWithContext(ctx.Context(), "GET", "http://"+upSrv.Addr().String(), nil)
+			res, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer res.Body.Close()
+			buf, _ := io.ReadAll(res.Body)
+			return ctx.HTML(http.StatusOK, string(buf))
+		default:
+			d, _ := ctx.Deadline()
+			return ctx.HTML(http.StatusOK, strconv.FormatInt(time.Until(d).Milliseconds(), 10))
+		}
+	})
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	request := func(path, name, val string) (int, string) {
+		req, _ := http.NewRequest(http.MethodGet, host+path, nil)
+		if name != "" {
+			req.Header.Set(name, val)
+		}
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer res.Body.Close()
+		buf, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(buf)
+	}
+
+	t.Run("should set ctx deadline with budget", func(t *testing.T) {
+		assert := assert.New(t)
+
+		code, body := request("/", HeaderXRequestTimeout, "100")
+		assert.Equal(200, code)
+		ms, _ := strconv.Atoi(body)
+		assert.True(ms > 50 && ms <= 100)
+
+		code, body = request("/", HeaderGRPCTimeout, "1S")
+		assert.Equal(200, code)
+		ms, _ = strconv.Atoi(body)
+		assert.True(ms > 400 && ms <= 500, "should be limited by MaxTimeout")
+
+		code, body = request("/", "", "")
+		assert.Equal(200, code)
+		ms, _ = strconv.Atoi(body)
+		assert.True(ms > 900 && ms <= 1000, "should be limited by app timeout")
+
+		code, body = request("/", HeaderXRequestTimeout, "invalid")
+		assert.Equal(200, code)
+		ms, _ = strconv.Atoi(body)
+		assert.True(ms > 900)
+	})
+
+	t.Run("should respond 504", func(t *testing.T) {
+		assert := assert.New(t)
+
+		code, _ := request("/", HeaderXRequestTimeout, "0")
+		assert.Equal(504, code)
+
+		start := time.Now()
+		code, _ = request("/wait", HeaderXRequestTimeout, "50ms")
+		assert.Equal(504, code)
+		assert.True(time.Since(start) < 500*time.Millisecond)
+	})
+
+	t.Run("should propagate budget", func(t *testing.T) {
+		assert := assert.New(t)
+
+		code, body := request("/proxy", HeaderXRequestTimeout, "200")
+		assert.Equal(200, code)
+		ms, _ := strconv.Atoi(body)
+		assert.True(ms > 100 && ms <= 200)
+	})
+}
+
+func TestParseTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	for val, expected := range map[string]time.Duration{
+		"1500":   1500 * time.Millisecond,
+		"1.5s":   1500 * time.Millisecond,
+		"1H":     time.Hour,
+		"2M":     2 * time.Minute,
+		"3S":     3 * time.Second,
+		"1500m":  1500 * time.Millisecond,
+		"100u":   100 * time.Microsecond,
+		"100n":   100 * time.Nanosecond,
+		" 100 ":  100 * time.Millisecond,
+		"-1":     -time.Millisecond,
+		"1m30s":  90 * time.Second,
+		"100000": 100 * time.Second,
+	} {
+		d, ok := ParseTimeout(val)
+		assert.True(ok, val)
+		assert.Equal(expected, d, val)
+	}
+
+	for _, val := range []string{"", "m", "abc", "1x"} {
+		_, ok := ParseTimeout(val)
+		assert.False(ok, val)
+	}
+}
+
+func TestPropagate(t *testing.T) {
+	assert := assert.New(t)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	assert.Nil(Propagate(context.Background(), req))
+	assert.Equal("", req.Header.Get(HeaderXRequestTimeout))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(Propagate(ctx, req))
+	ms, _ := strconv.Atoi(req.Header.Get(HeaderXRequestTimeout))
+	assert.True(ms > 900 && ms <= 1000)
+
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, Propagate(ctx, req))
+}
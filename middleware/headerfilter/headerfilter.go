@@ -0,0 +1,132 @@
+package headerfilter
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/teambition/gear"
+)
+
+// HopByHopHeaders are the hop-by-hop headers defined in RFC 7230 section 6.1,
+// they are meaningful only for a single transport-level connection and must not be forwarded.
+var HopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection", // non-standard but still sent by some clients
+	gear.HeaderProxyAuthenticate,
+	gear.HeaderProxyAuthorization,
+	gear.HeaderTE,
+	"Trailer",
+	gear.HeaderTransferEncoding,
+	gear.HeaderUpgrade,
+}
+
+// SensitiveHeaders are the request headers carrying credentials.
+var SensitiveHeaders = []string{
+	gear.HeaderAuthorization,
+	gear.HeaderCookie,
+}
+
+// Options is headerfilter middleware options.
+type Options struct {
+	// Strip defines the request headers to be removed. If "Connection" is included,
+	// the headers listed in the "Connection" header will be removed too.
+	// Optional. Default to HopByHopHeaders and SensitiveHeaders.
+	Strip []string
+	// Allow defines the only request headers to be kept, others will be removed. Strip applies after it.
+	// Optional. Default to nil, keep all headers not stripped.
+	Allow []string
+}
+
+// New creates a middleware to strip hop-by-hop and sensitive request headers in place,
+// before the request is logged, mirrored or proxied.
+//
+//	package main
+//
+//	import (
+//		"net/http/httputil"
+//		"net/url"
+//
+//		"github.com/teambition/gear"
+//		"github.com/teambition/gear/middleware/headerfilter"
+//	)
+//
+//	func main() {
+//		target, _ := url.Parse("http://127.0.0.1:8080")
+//		proxy := httputil.NewSingleHostReverseProxy(target)
+//
+//		app := gear.New()
+//		router := gear.NewRouter()
+//		router.Get("/public/*", headerfilter.New(), gear.WrapHandler(proxy))
+//		app.UseHandler(router)
+//		app.Error(app.Listen(":3000"))
+//	}
+func New(options ...Options) gear.Middleware {
+	f := newFilter(options...)
+	return func(ctx *gear.Context) error {
+		f.filter(ctx.Req.Header)
+		return nil
+	}
+}
+
+// Filter returns a copy of the header with hop-by-hop and sensitive headers removed by the options,
+// the header argument will not be changed. It is useful to log or mirror the request headers.
+//
+//	logging.SetTo(ctx, "headers", headerfilter.Filter(ctx.Req.Header))
+func Filter(header http.Header, options ...Options) http.Header {
+	h := header.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	newFilter(options...).filter(h)
+	return h
+}
+
+type filter struct {
+	strip      map[string]bool
+	allow      map[string]bool
+	connection bool
+}
+
+func newFilter(options ...Options) *filter {
+	opts := Options{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.Strip == nil {
+		opts.Strip = append(append([]string{}, HopByHopHeaders...), SensitiveHeaders...)
+	}
+
+	f := &filter{strip: canonicalSet(opts.Strip)}
+	f.connection = f.strip["Connection"]
+	if opts.Allow != nil {
+		f.allow = canonicalSet(opts.Allow)
+	}
+	return f
+}
+
+func (f *filter) filter(header http.Header) {
+	if f.connection {
+		// RFC 7230 section 6.1, remove the headers nominated by Connection header.
+		for _, v := range header.Values("Connection") {
+			for _, name := range strings.Split(v, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					header.Del(name)
+				}
+			}
+		}
+	}
+	for key := range header {
+		if f.strip[key] || (f.allow != nil && !f.allow[key]) {
+			delete(header, key)
+		}
+	}
+}
+
+func canonicalSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[http.CanonicalHeaderKey(name)] = true
+	}
+	return set
+}
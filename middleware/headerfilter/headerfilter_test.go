@@ -0,0 +1,92 @@
+package headerfilter
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func TestGearMiddlewareHeaderFilter(t *testing.T) {
+	newServer := func(mw gear.Middleware) *gear.ServerListener {
+		app := gear.New()
+		app.Use(mw)
+		app.Use(func(ctx *gear.Context) error {
+			return ctx.JSON(200, ctx.Req.Header)
+		})
+		return app.Start()
+	}
+	request := func(host string) (http.Header, error) {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+host, nil)
+		req.Header.Set(gear.HeaderAuthorization, "Bearer token")
+		req.Header.Set(gear.HeaderCookie, "sid=123")
+		req.Header.Set("Keep-Alive", "timeout=5")
+		req.Header.Set("Connection", "keep-alive, X-Hop")
+		req.Header.Set("X-Hop", "1")
+		req.Header.Set("X-Custom", "abc")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		header := http.Header{}
+		err = json.NewDecoder(res.Body).Decode(&header)
+		return header, err
+	}
+
+	t.Run("should strip hop-by-hop and sensitive headers by default", func(t *testing.T) {
+		assert := assert.New(t)
+
+		srv := newServer(New())
+		defer srv.Close()
+
+		header, err := request(srv.Addr().String())
+		assert.Nil(err)
+		assert.Equal("", header.Get(gear.HeaderAuthorization))
+		assert.Equal("", header.Get(gear.HeaderCookie))
+		assert.Equal("", header.Get("Keep-Alive"))
+		assert.Equal("", header.Get("Connection"))
+		assert.Equal("", header.Get("X-Hop"))
+		assert.Equal("abc", header.Get("X-Custom"))
+		assert.NotEqual("", header.Get(gear.HeaderUserAgent))
+	})
+
+	t.Run("should work with Strip and Allow options", func(t *testing.T) {
+		assert := assert.New(t)
+
+		srv := newServer(New(Options{
+			Strip: []string{"authorization"},
+			Allow: []string{"Authorization", "cookie", "X-Hop", "X-Custom"},
+		}))
+		defer srv.Close()
+
+		header, err := request(srv.Addr().String())
+		assert.Nil(err)
+		assert.Equal("", header.Get(gear.HeaderAuthorization))
+		assert.Equal("sid=123", header.Get(gear.HeaderCookie))
+		assert.Equal("1", header.Get("X-Hop"))
+		assert.Equal("abc", header.Get("X-Custom"))
+		assert.Equal("", header.Get(gear.HeaderUserAgent))
+		assert.Equal(3, len(header))
+	})
+}
+
+func TestGearHeaderFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	header := http.Header{}
+	header.Set(gear.HeaderAuthorization, "Bearer token")
+	header.Set(gear.HeaderTE, "trailers")
+	header.Set("X-Custom", "abc")
+
+	h := Filter(header)
+	assert.Equal(http.Header{"X-Custom": []string{"abc"}}, h)
+	assert.Equal(3, len(header), "should not change the argument")
+
+	h = Filter(header, Options{Strip: []string{}})
+	assert.Equal(header, h)
+
+	assert.Equal(http.Header{}, Filter(nil))
+}
@@ -0,0 +1,258 @@
+package inspector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teambition/gear"
+)
+
+// Options is inspector middleware options.
+type Options struct {
+	// Envs defines the app envs (`app.Set(gear.SetEnv, env)`) that inspector is enabled in.
+	// Optional. Default to []string{"development"}.
+	Envs []string
+	// Size defines the number of the last exchanges kept in ring buffer.
+	// Optional. Default to 50.
+	Size int
+	// Path defines the inspector endpoint that responds the kept exchanges as JSON, newest first.
+	// Optional. No endpoint if empty.
+	Path string
+	// Logger dumps every exchange to it if set.
+	// Optional.
+	Logger *log.Logger
+	// MaxBodyBytes defines the max bytes of request body and response body to capture.
+	// Optional. Default to 64KB.
+	MaxBodyBytes int
+	// SampleRate defines the rate in [0, 1] of the requests to capture, it is decided by ctx.Sample,
+	// so the request with the same X-Request-Id is always captured or not.
+	// Optional. Default to 1, capture all requests.
+	SampleRate float64
+	// Wire makes the Logger dump exchanges in HTTP wire format (like httputil.DumpRequest
+	// and httputil.DumpResponse), that is useful to debug client integration issues.
+	// Optional. Default to false, dump in pretty-printed format.
+	Wire bool
+}
+
+// Exchange is a captured request/response exchange.
+type Exchange struct {
+	ID             int64         `json:"id"`
+	Time           time.Time     `json:"time"`
+	Duration       time.Duration `json:"duration"`
+	Method         string        `json:"method"`
+	URL            string        `json:"url"`
+	Host           string        `json:"host"`
+	Proto          string        `json:"proto"`
+	RequestHeader  http.Header   `json:"requestHeader"`
+	RequestBody    string        `json:"requestBody"`
+	Status         int           `json:"status"`
+	ResponseHeader http.Header   `json:"responseHeader"`
+	ResponseBody   string        `json:"responseBody"`
+}
+
+// String returns the pretty-printed dump of the exchange.
+func (e *Exchange) String() string {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "--> #%d %s %s %s\n", e.ID, e.Method, e.URL, e.Proto)
+	writeMessage(buf, e.RequestHeader, e.RequestBody)
+	fmt.Fprintf(buf, "<-- #%d %d %s (%s)\n", e.ID, e.Status, http.StatusText(e.Status), e.Duration)
+	writeMessage(buf, e.ResponseHeader, e.ResponseBody)
+	return buf.String()
+}
+
+// Wire returns the dump of the exchange in HTTP wire format.
+// The bodies are truncated by Options.MaxBodyBytes.
+func (e *Exchange) Wire() string {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "%s %s %s\r\n", e.Method, e.URL, e.Proto)
+	fmt.Fprintf(buf, "Host: %s\r\n", e.Host)
+	e.RequestHeader.Write(buf)
+	buf.WriteString("\r\n")
+	if e.RequestBody != "" {
+		buf.WriteString(e.RequestBody)
+		buf.WriteString("\r\n\r\n")
+	}
+	fmt.Fprintf(buf, "%s %d %s\r\n", e.Proto, e.Status, http.StatusText(e.Status))
+	e.ResponseHeader.Write(buf)
+	buf.WriteString("\r\n")
+	buf.WriteString(e.ResponseBody)
+	return buf.String()
+}
+
+// Inspector is a development middleware that captures full request/response exchanges.
+type Inspector struct {
+	opts  Options
+	envs  map[string]bool
+	mu    sync.Mutex
+	count int64
+	ring  []*Exchange
+}
+
+// New creates a inspector middleware to dump full request/response exchanges to logger,
+// or to serve them by a inspector endpoint. It does nothing if app env is not in Options.Envs,
+// so don't worry about enabling it in production. Set Options.SampleRate and Options.Wire to log
+// the full wire data of sampled requests only.
+//
+//	package main
+//
+//	import (
+//		"log"
+//		"os"
+//
+//		"github.com/teambition/gear"
+//		"github.com/teambition/gear/middleware/inspector"
+//	)
+//
+//	func main() {
+//		app := gear.New()
+//		app.UseHandler(inspector.New(inspector.Options{
+//			Path:   "/_inspector",
+//			Logger: log.New(os.Stdout, "", 0),
+//		}))
+//		app.Use(func(ctx *gear.Context) error {
+//			return ctx.HTML(200, "<h1>Hello, Gear!</h1>")
+//		})
+//		app.Error(app.Listen(":3000"))
+//	}
+func New(options ...Options) *Inspector {
+	opts := Options{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if len(opts.Envs) == 0 {
+		opts.Envs = []string{"development"}
+	}
+	if opts.Size <= 0 {
+		opts.Size = 50
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 64 << 10
+	}
+	if opts.SampleRate <= 0 || opts.SampleRate > 1 {
+		opts.SampleRate = 1
+	}
+
+	i := &Inspector{opts: opts, envs: make(map[string]bool), ring: make([]*Exchange, 0, opts.Size)}
+	for _, env := range opts.Envs {
+		i.envs[env] = true
+	}
+	return i
+}
+
+// Serve implemented gear.Handler interface.
+func (i *Inspector) Serve(ctx *gear.Context) error {
+	if env, _ := ctx.Setting(gear.SetEnv).(string); !i.envs[env] {
+		return nil
+	}
+	if i.opts.Path != "" && ctx.Path == i.opts.Path && ctx.Method == http.MethodGet {
+		return ctx.JSON(http.StatusOK, i.Exchanges())
+	}
+	if i.opts.SampleRate < 1 && !ctx.Sample(i.opts.SampleRate) {
+		return nil
+	}
+
+	ex := &Exchange{
+		Time:          time.Now(),
+		Method:        ctx.Method,
+		URL:           ctx.Req.URL.String(),
+		Host:          ctx.Req.Host,
+		Proto:         ctx.Req.Proto,
+		RequestHeader: ctx.Req.Header.Clone(),
+	}
+	if ctx.Req.Body != nil && ctx.Req.Body != http.NoBody {
+		buf, err := io.ReadAll(io.LimitReader(ctx.Req.Body, int64(i.opts.MaxBodyBytes)))
+		if err != nil {
+			return gear.ErrBadRequest.From(err)
+		}
+		ex.RequestBody = string(buf)
+		// the rest of body is still readable by the following middlewares.
+		ctx.Req.Body = readCloser{io.MultiReader(bytes.NewReader(buf), ctx.Req.Body), ctx.Req.Body}
+	}
+
+	ctx.OnEnd(func() {
+		ex.Duration = time.Since(ex.Time)
+		ex.Status = ctx.Res.Status()
+		ex.ResponseHeader = ctx.Res.Header().Clone()
+		body := ctx.Res.Body()
+		if len(body) > i.opts.MaxBodyBytes {
+			body = body[:i.opts.MaxBodyBytes]
+		}
+		ex.ResponseBody = string(body)
+		i.add(ex)
+		switch {
+		case i.opts.Logger == nil:
+		case i.opts.Wire:
+			i.opts.Logger.Print(ex.Wire())
+		default:
+			i.opts.Logger.Print(ex.String())
+		}
+	})
+	return nil
+}
+
+// Exchanges returns the kept exchanges, newest first.
+func (i *Inspector) Exchanges() []*Exchange {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	res := make([]*Exchange, len(i.ring))
+	for j, ex := range i.ring {
+		res[len(i.ring)-1-j] = ex
+	}
+	return res
+}
+
+// Reset removes all kept exchanges.
+func (i *Inspector) Reset() {
+	i.mu.Lock()
+	i.ring = i.ring[:0]
+	i.mu.Unlock()
+}
+
+func (i *Inspector) add(ex *Exchange) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.count++
+	ex.ID = i.count
+	if len(i.ring) == i.opts.Size {
+		copy(i.ring, i.ring[1:])
+		i.ring = i.ring[:len(i.ring)-1]
+	}
+	i.ring = append(i.ring, ex)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func writeMessage(buf *bytes.Buffer, header http.Header, body string) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(buf, "%s: %s\n", key, strings.Join(header[key], ", "))
+	}
+	if body != "" {
+		buf.WriteByte('\n')
+		pretty := new(bytes.Buffer)
+		if strings.Contains(header.Get(gear.HeaderContentType), "json") &&
+			json.Indent(pretty, []byte(body), "", "  ") == nil {
+			body = pretty.String()
+		}
+		buf.WriteString(body)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+}
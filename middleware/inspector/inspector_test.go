@@ -0,0 +1,161 @@
+package inspector
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func TestGearMiddlewareInspector(t *testing.T) {
+	t.Run("should capture exchanges", func(t *testing.T) {
+		assert := assert.New(t)
+
+		logs := &safeBuffer{}
+		ins := New(Options{Size: 2, Path: "/_inspector", Logger: log.New(logs, "", 0)})
+		app := gear.New()
+		app.Set(gear.SetEnv, "development")
+		app.UseHandler(ins)
+		app.Use(func(ctx *gear.Context) error {
+			body, err := io.ReadAll(ctx.Req.Body)
+			if err != nil {
+				return err
+			}
+			return ctx.JSON(http.StatusOK, map[string]string{"echo": string(body)})
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		for _, body := range []string{"a", "b", "c"} {
+			res, err := http.Post(host+"/echo?q=1", gear.MIMETextPlain, strings.NewReader(body))
+			assert.Nil(err)
+			buf, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.Equal(`{"echo":"`+body+`"}`, string(buf))
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		exchanges := ins.Exchanges()
+		assert.Equal(2, len(exchanges))
+		ex := exchanges[0]
+		assert.Equal(int64(3), ex.ID)
+		assert.Equal(http.MethodPost, ex.Method)
+		assert.Equal("/echo?q=1", ex.URL)
+		assert.Equal(gear.MIMETextPlain, ex.RequestHeader.Get(gear.HeaderContentType))
+		assert.Equal("c", ex.RequestBody)
+		assert.Equal(200, ex.Status)
+		assert.Equal(gear.MIMEApplicationJSONCharsetUTF8, ex.ResponseHeader.Get(gear.HeaderContentType))
+		assert.Equal(`{"echo":"c"}`, ex.ResponseBody)
+		assert.Equal(int64(2), exchanges[1].ID)
+
+		output := logs.String()
+		assert.Contains(output, "--> #1 POST /echo?q=1 HTTP/1.1\n")
+		assert.Contains(output, "<-- #3 200 OK (")
+		assert.Contains(output, "{\n  \"echo\": \"c\"\n}\n")
+
+		res, err := http.Get(host + "/_inspector")
+		assert.Nil(err)
+		var list []*Exchange
+		assert.Nil(json.NewDecoder(res.Body).Decode(&list))
+		res.Body.Close()
+		assert.Equal(2, len(list))
+		assert.Equal(int64(3), list[0].ID)
+
+		ins.Reset()
+		assert.Equal(0, len(ins.Exchanges()))
+	})
+
+	t.Run("should dump sampled exchanges in wire format", func(t *testing.T) {
+		assert := assert.New(t)
+
+		logs := &safeBuffer{}
+		ins := New(Options{SampleRate: 0.5, Wire: true, MaxBodyBytes: 4, Logger: log.New(logs, "", 0)})
+		sampled := int32(0)
+		app := gear.New()
+		app.Set(gear.SetEnv, "development")
+		app.UseHandler(ins)
+		app.Use(func(ctx *gear.Context) error {
+			if ctx.Sample(0.5) {
+				atomic.AddInt32(&sampled, 1)
+			}
+			ctx.SetHeader("X-Test", "ok")
+			return ctx.HTML(http.StatusOK, "Hello")
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+			req, _ := http.NewRequest(http.MethodPost, host+"/wire?id="+id, strings.NewReader("some body"))
+			req.Header.Set(gear.HeaderXRequestID, id)
+			res, err := http.DefaultClient.Do(req)
+			assert.Nil(err)
+			res.Body.Close()
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		n := int(atomic.LoadInt32(&sampled))
+		assert.True(n > 0 && n < 10)
+		exchanges := ins.Exchanges()
+		assert.Equal(n, len(exchanges))
+
+		ex := exchanges[0]
+		wire := ex.Wire()
+		assert.True(strings.HasPrefix(wire, "POST "+ex.URL+" HTTP/1.1\r\nHost: "+srv.Addr().String()+"\r\n"))
+		assert.Contains(wire, "X-Request-Id: "+ex.RequestHeader.Get(gear.HeaderXRequestID)+"\r\n")
+		assert.Contains(wire, "\r\n\r\nsome\r\n\r\nHTTP/1.1 200 OK\r\n")
+		assert.Contains(wire, "X-Test: ok\r\n")
+		assert.True(strings.HasSuffix(wire, "\r\n\r\nHell"))
+		assert.Equal(n, strings.Count(logs.String(), "HTTP/1.1 200 OK\r\n"))
+	})
+
+	t.Run("should do nothing in other env", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ins := New(Options{Path: "/_inspector"})
+		app := gear.New()
+		app.Set(gear.SetEnv, "production")
+		app.UseHandler(ins)
+		app.Use(func(ctx *gear.Context) error {
+			return ctx.HTML(http.StatusOK, "OK")
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := http.Get(host + "/_inspector")
+		assert.Nil(err)
+		buf, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		assert.Equal("OK", string(buf))
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(0, len(ins.Exchanges()))
+	})
+}
+
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
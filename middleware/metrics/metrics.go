@@ -0,0 +1,269 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/teambition/gear"
+)
+
+// MIMEPrometheusText is the content type of Prometheus text exposition format.
+const MIMEPrometheusText = "text/plain; version=0.0.4; charset=utf-8"
+
+// Options is metrics middleware options.
+type Options struct {
+	// Path defines the endpoint that exposes the metrics in Prometheus text format.
+	// Optional. Default to "/metrics".
+	Path string
+	// Namespace defines the prefix of metric names, such as "http" for "http_requests_total".
+	// Optional. Default to "http".
+	Namespace string
+	// DurationBuckets defines the upper bounds (in seconds) of request duration histogram buckets.
+	// Optional. Default to 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10.
+	DurationBuckets []float64
+	// SizeBuckets defines the upper bounds (in bytes) of response size histogram buckets.
+	// Optional. Default to 100, 1000, 10000, 100000, 1000000, 10000000.
+	SizeBuckets []float64
+}
+
+// Metrics is a middleware that records the request count, duration, response size
+// and in-flight requests, and exposes them in Prometheus text format.
+type Metrics struct {
+	opts     Options
+	inFlight atomic.Int64
+	mu       sync.Mutex
+	series   map[labels]*series
+}
+
+type labels struct {
+	method, route, status string
+}
+
+type series struct {
+	count        int64
+	durationSum  float64
+	durations    []int64 // not cumulative, the last one is +Inf
+	sizeSum      float64
+	sizeBuckets  []int64
+	sizeObserved bool
+}
+
+// New creates a metrics middleware. It should be used before routers, the requests are labeled by
+// method, route pattern (by gear.GetRouterPatternFromCtx) and status. The requests not matched by
+// routers are labeled with empty route, so that the metrics will not explode by arbitrary paths.
+//
+//	package main
+//
+//	import (
+//		"github.com/teambition/gear"
+//		"github.com/teambition/gear/middleware/metrics"
+//	)
+//
+//	func main() {
+//		app := gear.New()
+//		app.UseHandler(metrics.New())
+//		router := gear.NewRouter()
+//		router.Get("/users/:id", GetUser)
+//		app.UseHandler(router)
+//		app.Error(app.Listen(":3000"))
+//		// GET /metrics
+//	}
+func New(options ...Options) *Metrics {
+	opts := Options{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.Path == "" {
+		opts.Path = "/metrics"
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = "http"
+	}
+	if len(opts.DurationBuckets) == 0 {
+		opts.DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	} else {
+		opts.DurationBuckets = append([]float64{}, opts.DurationBuckets...)
+	}
+	if len(opts.SizeBuckets) == 0 {
+		opts.SizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+	} else {
+		opts.SizeBuckets = append([]float64{}, opts.SizeBuckets...)
+	}
+	sort.Float64s(opts.DurationBuckets)
+	sort.Float64s(opts.SizeBuckets)
+	return &Metrics{opts: opts, series: make(map[labels]*series)}
+}
+
+// Serve implemented gear.Handler interface.
+func (m *Metrics) Serve(ctx *gear.Context) error {
+	if ctx.Path == m.opts.Path && ctx.Method == http.MethodGet {
+		buf := new(bytes.Buffer)
+		m.WriteTo(buf)
+		ctx.SetHeader(gear.HeaderContentType, MIMEPrometheusText)
+		return ctx.End(http.StatusOK, buf.Bytes())
+	}
+
+	m.inFlight.Add(1)
+	ctx.OnEnd(func() {
+		m.inFlight.Add(-1)
+		size := -1
+		if body := ctx.Res.Body(); body != nil {
+			size = len(body)
+		} else if n, err := strconv.Atoi(ctx.Res.Get(gear.HeaderContentLength)); err == nil {
+			size = n
+		}
+		m.Record(ctx.Method, gear.GetRouterPatternFromCtx(ctx), ctx.Res.Status(), time.Since(ctx.StartAt), size)
+	})
+	return nil
+}
+
+// Record records a request's status, duration and response size to the route.
+// The size is ignored if it is negative, such as the response written by Response.Write directly.
+func (m *Metrics) Record(method, route string, status int, d time.Duration, size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := labels{method, route, strconv.Itoa(status)}
+	s := m.series[key]
+	if s == nil {
+		s = &series{
+			durations:   make([]int64, len(m.opts.DurationBuckets)+1),
+			sizeBuckets: make([]int64, len(m.opts.SizeBuckets)+1),
+		}
+		m.series[key] = s
+	}
+
+	s.count++
+	seconds := d.Seconds()
+	s.durationSum += seconds
+	s.durations[sort.SearchFloat64s(m.opts.DurationBuckets, seconds)]++
+	if size >= 0 {
+		s.sizeObserved = true
+		s.sizeSum += float64(size)
+		s.sizeBuckets[sort.SearchFloat64s(m.opts.SizeBuckets, float64(size))]++
+	}
+}
+
+// Reset removes all recorded metrics, except the in-flight requests.
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	m.series = make(map[labels]*series)
+	m.mu.Unlock()
+}
+
+// WriteTo writes the metrics in Prometheus text format to w.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]labels, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	cw := &countWriter{w: bufio.NewWriter(w)}
+	ns := m.opts.Namespace
+
+	name := ns + "_requests_total"
+	cw.header(name, "counter", "The total number of HTTP requests.")
+	for _, k := range keys {
+		cw.sample(name, k.String(), "", float64(m.series[k].count))
+	}
+
+	name = ns + "_request_duration_seconds"
+	cw.header(name, "histogram", "The HTTP request latencies in seconds.")
+	for _, k := range keys {
+		s := m.series[k]
+		cw.histogram(name, k.String(), m.opts.DurationBuckets, s.durations, s.durationSum, s.count)
+	}
+
+	name = ns + "_response_size_bytes"
+	cw.header(name, "histogram", "The HTTP response sizes in bytes.")
+	for _, k := range keys {
+		if s := m.series[k]; s.sizeObserved {
+			var n int64
+			for _, c := range s.sizeBuckets {
+				n += c
+			}
+			cw.histogram(name, k.String(), m.opts.SizeBuckets, s.sizeBuckets, s.sizeSum, n)
+		}
+	}
+
+	name = ns + "_requests_in_flight"
+	cw.header(name, "gauge", "The number of HTTP requests being served.")
+	cw.sample(name, "", "", float64(m.inFlight.Load()))
+
+	if cw.err == nil {
+		cw.err = cw.w.(*bufio.Writer).Flush()
+	}
+	return cw.n, cw.err
+}
+
+func (l labels) String() string {
+	return `method="` + escape(l.method) + `",route="` + escape(l.route) + `",status="` + l.status + `"`
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) write(s string) {
+	if cw.err == nil {
+		n, err := io.WriteString(cw.w, s)
+		cw.n += int64(n)
+		cw.err = err
+	}
+}
+
+func (cw *countWriter) header(name, typ, help string) {
+	cw.write("# HELP " + name + " " + help + "\n# TYPE " + name + " " + typ + "\n")
+}
+
+func (cw *countWriter) sample(name, labels, le string, val float64) {
+	if le != "" {
+		if labels != "" {
+			labels += ","
+		}
+		labels += `le="` + le + `"`
+	}
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+	cw.write(name + " " + strconv.FormatFloat(val, 'g', -1, 64) + "\n")
+}
+
+func (cw *countWriter) histogram(name, labels string, bounds []float64, buckets []int64, sum float64, count int64) {
+	var cumulative int64
+	for i, b := range bounds {
+		cumulative += buckets[i]
+		cw.sample(name+"_bucket", labels, strconv.FormatFloat(b, 'g', -1, 64), float64(cumulative))
+	}
+	cw.sample(name+"_bucket", labels, "+Inf", float64(count))
+	cw.sample(name+"_sum", labels, "", sum)
+	cw.sample(name+"_count", labels, "", float64(count))
+}
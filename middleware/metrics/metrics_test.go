@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func TestGearMiddlewareMetrics(t *testing.T) {
+	t.Run("should record and expose metrics", func(t *testing.T) {
+		assert := assert.New(t)
+
+		m := New()
+		app := gear.New()
+		app.UseHandler(m)
+		router := gear.NewRouter()
+		router.Get("/users/:id", func(ctx *gear.Context) error {
+			return ctx.HTML(http.StatusOK, "user "+ctx.Param("id"))
+		})
+		router.Get("/slow", func(ctx *gear.Context) error {
+			time.Sleep(30 * time.Millisecond)
+			return gear.ErrBadGateway
+		})
+		app.UseHandler(router)
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		for _, path := range []string{"/users/1", "/users/2", "/slow", "/none"} {
+			res, err := http.Get(host + path)
+			assert.Nil(err)
+			res.Body.Close()
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		res, err := http.Get(host + "/metrics")
+		assert.Nil(err)
+		assert.Equal(MIMEPrometheusText, res.Header.Get(gear.HeaderContentType))
+		buf, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		output := string(buf)
+
+		assert.Contains(output, "# HELP http_requests_total The total number of HTTP requests.\n# TYPE http_requests_total counter\n")
+		assert.Contains(output, `http_requests_total{method="GET",route="/users/:id",status="200"} 2`+"\n")
+		assert.Contains(output, `http_requests_total{method="GET",route="/slow",status="502"} 1`+"\n")
+		assert.Contains(output, `http_requests_total{method="GET",route="",status="421"} 1`+"\n")
+		assert.Contains(output, "# TYPE http_request_duration_seconds histogram\n")
+		assert.Contains(output, `http_request_duration_seconds_bucket{method="GET",route="/slow",status="502",le="0.025"} 0`+"\n")
+		assert.Contains(output, `http_request_duration_seconds_bucket{method="GET",route="/slow",status="502",le="0.05"} 1`+"\n")
+		assert.Contains(output, `http_request_duration_seconds_bucket{method="GET",route="/slow",status="502",le="+Inf"} 1`+"\n")
+		assert.Contains(output, `http_request_duration_seconds_count{method="GET",route="/users/:id",status="200"} 2`+"\n")
+		assert.Contains(output, `http_response_size_bytes_bucket{method="GET",route="/users/:id",status="200",le="100"} 2`+"\n")
+		assert.Contains(output, `http_response_size_bytes_sum{method="GET",route="/users/:id",status="200"} 12`+"\n")
+		assert.Contains(output, "# TYPE http_requests_in_flight gauge\nhttp_requests_in_flight 0\n")
+		assert.False(strings.Contains(output, "/metrics"))
+
+		m.Reset()
+		out := new(bytes.Buffer)
+		n, err := m.WriteTo(out)
+		assert.Nil(err)
+		assert.Equal(int64(out.Len()), n)
+		assert.False(strings.Contains(out.String(), "http_requests_total{"))
+	})
+
+	t.Run("should work with options", func(t *testing.T) {
+		assert := assert.New(t)
+
+		m := New(Options{
+			Namespace:       "api",
+			DurationBuckets: []float64{1, 0.1},
+			SizeBuckets:     []float64{10},
+		})
+		m.Record("GET", `/a"b\`, 200, 50*time.Millisecond, 20)
+		m.Record("GET", `/a"b\`, 200, 500*time.Millisecond, -1)
+
+		out := new(bytes.Buffer)
+		m.WriteTo(out)
+		output := out.String()
+		labels := `method="GET",route="/a\"b\\",status="200"`
+		assert.Contains(output, "api_requests_total{"+labels+"} 2\n")
+		assert.Contains(output, "api_request_duration_seconds_bucket{"+labels+`,le="0.1"} 1`+"\n")
+		assert.Contains(output, "api_request_duration_seconds_bucket{"+labels+`,le="1"} 2`+"\n")
+		assert.Contains(output, "api_request_duration_seconds_sum{"+labels+"} 0.55\n")
+		assert.Contains(output, "api_response_size_bytes_bucket{"+labels+`,le="10"} 0`+"\n")
+		assert.Contains(output, "api_response_size_bytes_count{"+labels+"} 1\n")
+	})
+}
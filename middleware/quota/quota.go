@@ -0,0 +1,270 @@
+package quota
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teambition/gear"
+)
+
+// The quota response headers.
+const (
+	HeaderXQuotaLimit     = "X-Quota-Limit"     // the request quota of the period
+	HeaderXQuotaRemaining = "X-Quota-Remaining" // the remaining requests of the period
+	HeaderXQuotaReset     = "X-Quota-Reset"     // the unix seconds when the quota resets
+)
+
+// HeaderXAPIKey is the request header of the API key, the default quota key.
+const HeaderXAPIKey = "X-API-Key"
+
+// Period is the period of the quota, the usage counters are reset at the start of each period.
+type Period int
+
+// The quota periods.
+const (
+	Daily Period = iota
+	Monthly
+)
+
+// Store is the interface of the usage counters store. The Redis backend can be plugged in by implementing it,
+// such as INCRBY and EXPIREAT commands.
+type Store interface {
+	// Incr increments the counter of the key by n, and returns the counter after incrementing.
+	// The counter is created with 0 if the key not exists or expired, and it expires at expireAt.
+	Incr(key string, n int64, expireAt time.Time) (int64, error)
+	// Get returns the counter of the key, or 0 if the key not exists or expired.
+	Get(key string) (int64, error)
+}
+
+// Options is quota middleware options.
+type Options struct {
+	// Store is the store of the usage counters, it should be shared by the app instances.
+	// Optional. Default to NewMemoryStore(), it works only for a single instance.
+	Store Store
+	// Period is the quota period. Optional. Default to Daily.
+	Period Period
+	// Location is the time zone to start the periods. Optional. Default to time.UTC.
+	Location *time.Location
+	// Limit is the default request quota of a key in the period, such as the quota of the free plan.
+	// Required, it should be positive.
+	Limit int64
+	// LimitFor returns the request quota of the key, such as the quota of the key's plan.
+	// A zero returned means the default Limit, a negative means unlimited, no quota headers responded.
+	// Optional. Default to nil.
+	LimitFor func(ctx *gear.Context, key string) int64
+	// Key returns the quota key of the request, such as the API key or the account.
+	// The request with an empty key is rejected with 401 Unauthorized.
+	// Optional. Default to the X-API-Key request header, or the client IP if not present.
+	Key func(ctx *gear.Context) string
+	// Scope is the namespace of the usage counters, the middlewares with different scopes count separately.
+	// Optional. Default to "".
+	Scope string
+	// PerRoute counts the requests of each route separately, with the method and the route pattern.
+	// It works only when the middleware runs after the router matched, such as in router.Use or a route handler.
+	// Optional. Default to false.
+	PerRoute bool
+	// Skipper returns true to skip the quota for the request, such as the internal requests.
+	// Optional. Default to nil.
+	Skipper func(ctx *gear.Context) bool
+}
+
+// New creates a middleware to enforce the request quotas per key, such as the daily or monthly quotas per API key
+// of an API product. It responds the quota headers, and 429 Too Many Requests with the Retry-After header
+// if the quota exceeded. The rejected requests are not counted. The store errors are logged and the requests
+// are allowed, so the store outage will not break the API.
+//
+//	package main
+//
+//	import (
+//		"github.com/teambition/gear"
+//		"github.com/teambition/gear/middleware/quota"
+//	)
+//
+//	func main() {
+//		app := gear.New()
+//		app.Use(quota.New(quota.Options{
+//			Store:  redisStore, // implements quota.Store
+//			Period: quota.Monthly,
+//			Limit:  10000,
+//			LimitFor: func(ctx *gear.Context, key string) int64 {
+//				return plans.Quota(key) // 0 for the default Limit
+//			},
+//		}))
+//		app.UseHandler(router)
+//		app.Error(app.Listen(":3000"))
+//	}
+//
+// Response headers:
+//
+//	X-Quota-Limit: 10000
+//	X-Quota-Remaining: 9998
+//	X-Quota-Reset: 1706745600
+func New(opts Options) gear.Middleware {
+	if opts.Limit <= 0 {
+		panic(gear.Err.WithMsg("quota limit should be positive"))
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryStore()
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.Key == nil {
+		opts.Key = func(ctx *gear.Context) string {
+			if key := ctx.GetHeader(HeaderXAPIKey); key != "" {
+				return key
+			}
+			if ip := ctx.IP(); ip != nil {
+				return ip.String()
+			}
+			return ""
+		}
+	}
+
+	return func(ctx *gear.Context) error {
+		if opts.Skipper != nil && opts.Skipper(ctx) {
+			return nil
+		}
+		key := opts.Key(ctx)
+		if key == "" {
+			return gear.ErrUnauthorized.WithMsg("quota key required")
+		}
+		limit := opts.Limit
+		if opts.LimitFor != nil {
+			if l := opts.LimitFor(ctx, key); l < 0 {
+				return nil
+			} else if l > 0 {
+				limit = l
+			}
+		}
+
+		route := ""
+		if opts.PerRoute {
+			route = ctx.Method + " " + gear.GetRouterPatternFromCtx(ctx)
+		}
+		start, reset := opts.Period.bounds(time.Now().In(opts.Location))
+		counter := counterKey(opts.Scope, start, route, key)
+
+		used, err := opts.Store.Incr(counter, 1, reset)
+		if err != nil {
+			ctx.LogErr(err)
+			return nil
+		}
+		exceeded := used > limit
+		if exceeded {
+			if _, err = opts.Store.Incr(counter, -1, reset); err != nil {
+				ctx.LogErr(err)
+			}
+			used = limit
+		}
+
+		// the "X-" headers are kept by the error responses, see gear.Response.ResetHeader
+		header := ctx.Res.Header()
+		header.Set(HeaderXQuotaLimit, strconv.FormatInt(limit, 10))
+		header.Set(HeaderXQuotaRemaining, strconv.FormatInt(limit-used, 10))
+		header.Set(HeaderXQuotaReset, strconv.FormatInt(reset.Unix(), 10))
+		if exceeded {
+			ctx.SetHeader(gear.HeaderRetryAfter, strconv.FormatInt(int64(time.Until(reset)/time.Second)+1, 10))
+			return gear.ErrTooManyRequests.WithMsgf("request quota %d exceeded", limit)
+		}
+		return nil
+	}
+}
+
+// Usage returns the used requests of the key in the current period from the store of the options,
+// such as for the usage dashboard of the API keys. The route is the method and the route pattern
+// (such as "GET /api/users/:id") if the PerRoute option is true, otherwise it should be "".
+func Usage(opts Options, key, route string) (int64, error) {
+	if opts.Store == nil {
+		return 0, gear.Err.WithMsg("quota store required")
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	start, _ := opts.Period.bounds(time.Now().In(opts.Location))
+	return opts.Store.Get(counterKey(opts.Scope, start, route, key))
+}
+
+func counterKey(scope string, start time.Time, route, key string) string {
+	var b strings.Builder
+	b.WriteString("quota:")
+	b.WriteString(scope)
+	b.WriteString(":")
+	b.WriteString(start.Format("2006-01-02"))
+	if route != "" {
+		b.WriteString(":")
+		b.WriteString(route)
+	}
+	b.WriteString(":")
+	b.WriteString(key)
+	return b.String()
+}
+
+// bounds returns the start of the period including t, and the start of the next period.
+func (p Period) bounds(t time.Time) (time.Time, time.Time) {
+	y, m, d := t.Date()
+	if p == Monthly {
+		start := time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 1, 0)
+	}
+	start := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+// MemoryStore is an in-memory Store, the expired counters are removed when incrementing.
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]*counter
+	sweepAt  time.Time
+}
+
+type counter struct {
+	n        int64
+	expireAt time.Time
+}
+
+// NewMemoryStore returns a MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]*counter)}
+}
+
+// Incr implements the Store interface.
+func (s *MemoryStore) Incr(key string, n int64, expireAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.After(s.sweepAt) {
+		for k, c := range s.counters {
+			if !now.Before(c.expireAt) {
+				delete(s.counters, k)
+			}
+		}
+		s.sweepAt = now.Add(time.Minute)
+	}
+	c := s.counters[key]
+	if c == nil || !now.Before(c.expireAt) {
+		c = &counter{expireAt: expireAt}
+		s.counters[key] = c
+	}
+	c.n += n
+	return c.n, nil
+}
+
+// Get implements the Store interface.
+func (s *MemoryStore) Get(key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.counters[key]; c != nil && time.Now().Before(c.expireAt) {
+		return c.n, nil
+	}
+	return 0, nil
+}
+
+// Len returns the number of the counters, including the expired ones not removed.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.counters)
+}
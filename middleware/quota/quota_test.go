@@ -0,0 +1,165 @@
+package quota
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+type errStore struct{}
+
+func (errStore) Incr(key string, n int64, expireAt time.Time) (int64, error) {
+	return 0, errors.New("store down")
+}
+
+func (errStore) Get(key string) (int64, error) {
+	return 0, errors.New("store down")
+}
+
+func request(t *testing.T, method, url, apiKey string) *http.Response {
+	req, err := http.NewRequest(method, url, nil)
+	assert.Nil(t, err)
+	if apiKey != "" {
+		req.Header.Set(HeaderXAPIKey, apiKey)
+	}
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	res.Body.Close()
+	return res
+}
+
+func TestGearMiddlewareQuota(t *testing.T) {
+	t.Run("should panic with invalid limit", func(t *testing.T) {
+		assert.Panics(t, func() { New(Options{}) })
+	})
+
+	t.Run("should enforce the quota per key", func(t *testing.T) {
+		assert := assert.New(t)
+
+		store := NewMemoryStore()
+		opts := Options{
+			Store:  store,
+			Limit:  2,
+			Period: Monthly,
+			LimitFor: func(ctx *gear.Context, key string) int64 {
+				switch key {
+				case "pro":
+					return 3
+				case "internal":
+					return -1
+				}
+				return 0
+			},
+		}
+		app := gear.New()
+		app.Use(New(opts))
+		app.Use(func(ctx *gear.Context) error {
+			return ctx.HTML(200, "OK")
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		now := time.Now().UTC()
+		reset := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
+		for i := 1; i <= 3; i++ {
+			res := request(t, "GET", host, "free")
+			if i <= 2 {
+				assert.Equal(200, res.StatusCode)
+				assert.Equal(strconv.Itoa(2-i), res.Header.Get(HeaderXQuotaRemaining))
+				assert.Equal("", res.Header.Get(gear.HeaderRetryAfter))
+			} else {
+				assert.Equal(429, res.StatusCode)
+				assert.Equal("0", res.Header.Get(HeaderXQuotaRemaining))
+				assert.NotEqual("", res.Header.Get(gear.HeaderRetryAfter))
+			}
+			assert.Equal("2", res.Header.Get(HeaderXQuotaLimit))
+			assert.Equal(strconv.FormatInt(reset.Unix(), 10), res.Header.Get(HeaderXQuotaReset))
+		}
+		used, err := Usage(opts, "free", "")
+		assert.Nil(err)
+		assert.Equal(int64(2), used)
+
+		res := request(t, "GET", host, "pro")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("3", res.Header.Get(HeaderXQuotaLimit))
+		assert.Equal("2", res.Header.Get(HeaderXQuotaRemaining))
+
+		res = request(t, "GET", host, "internal")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("", res.Header.Get(HeaderXQuotaLimit))
+
+		// the client IP is the default key
+		res = request(t, "GET", host, "")
+		assert.Equal(200, res.StatusCode)
+		used, err = Usage(opts, "127.0.0.1", "")
+		assert.Nil(err)
+		assert.Equal(int64(1), used)
+		assert.Equal(3, store.Len())
+	})
+
+	t.Run("should count per route", func(t *testing.T) {
+		assert := assert.New(t)
+
+		opts := Options{Store: NewMemoryStore(), Limit: 1, PerRoute: true, Scope: "api"}
+		router := gear.NewRouter()
+		router.Use(New(opts))
+		router.Get("/users/:id", func(ctx *gear.Context) error { return ctx.HTML(200, "user") })
+		router.Get("/orgs/:id", func(ctx *gear.Context) error { return ctx.HTML(200, "org") })
+		app := gear.New()
+		app.UseHandler(router)
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		assert.Equal(200, request(t, "GET", host+"/users/1", "k").StatusCode)
+		assert.Equal(429, request(t, "GET", host+"/users/2", "k").StatusCode)
+		assert.Equal(200, request(t, "GET", host+"/orgs/1", "k").StatusCode)
+		used, err := Usage(opts, "k", "GET /users/:id")
+		assert.Nil(err)
+		assert.Equal(int64(1), used)
+	})
+
+	t.Run("should allow requests when store failed", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := gear.New()
+		app.Use(New(Options{Store: errStore{}, Limit: 1}))
+		app.Use(func(ctx *gear.Context) error {
+			return ctx.HTML(200, "OK")
+		})
+		srv := app.Start()
+		defer srv.Close()
+
+		res := request(t, "GET", "http://"+srv.Addr().String(), "k")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("", res.Header.Get(HeaderXQuotaLimit))
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewMemoryStore()
+	n, err := s.Incr("a", 2, time.Now().Add(time.Hour))
+	assert.Nil(err)
+	assert.Equal(int64(2), n)
+	n, _ = s.Incr("a", 1, time.Now().Add(time.Hour))
+	assert.Equal(int64(3), n)
+	n, _ = s.Get("a")
+	assert.Equal(int64(3), n)
+
+	n, _ = s.Incr("b", 1, time.Now().Add(-time.Second))
+	assert.Equal(int64(1), n)
+	n, _ = s.Get("b")
+	assert.Equal(int64(0), n)
+	n, _ = s.Incr("b", 1, time.Now().Add(time.Hour))
+	assert.Equal(int64(1), n)
+	n, _ = s.Get("none")
+	assert.Equal(int64(0), n)
+}
@@ -0,0 +1,234 @@
+package routestats
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/teambition/gear"
+)
+
+// Options is routestats middleware options.
+type Options struct {
+	// Path defines the debug endpoint that responds the route stats as JSON, the slowest (by p99) first.
+	// Optional. Default to "/debug/gear/routes".
+	Path string
+	// Size defines the number of the latest latencies kept in ring buffer per route to compute percentiles.
+	// Optional. Default to 1024.
+	Size int
+	// Buckets defines the upper bounds of histogram buckets.
+	// Optional. Default to 5ms, 10ms, 25ms, 50ms, 100ms, 250ms, 500ms, 1s, 2.5s, 5s, 10s.
+	Buckets []time.Duration
+}
+
+// RouteStats is the latency and status aggregates of a route. Durations are in milliseconds.
+type RouteStats struct {
+	Method    string           `json:"method"`
+	Pattern   string           `json:"pattern"`
+	Count     int64            `json:"count"`
+	Status    map[string]int64 `json:"status"` // count by status class, such as "2xx"
+	Min       float64          `json:"min"`
+	Max       float64          `json:"max"`
+	Avg       float64          `json:"avg"`
+	P50       float64          `json:"p50"`
+	P90       float64          `json:"p90"`
+	P99       float64          `json:"p99"`
+	Histogram []Bucket         `json:"histogram"`
+}
+
+// Bucket is a histogram bucket, Count is the number of requests with latency <= Le (in milliseconds).
+// The last bucket's Le is "+Inf".
+type Bucket struct {
+	Le    string `json:"le"`
+	Count int64  `json:"count"`
+}
+
+// Stats is a middleware that keeps in-memory per-route latency and status aggregates.
+type Stats struct {
+	opts   Options
+	mu     sync.Mutex
+	routes map[string]*route
+}
+
+type route struct {
+	method, pattern string
+	total           time.Duration
+	n               int64
+	min, max        time.Duration
+	status          map[string]int64
+	buckets         []int64 // the last one is +Inf
+	ring            []time.Duration
+	next            int
+}
+
+// New creates a routestats middleware. It should be used before routers, and only
+// the requests matched by routers are recorded.
+//
+//	package main
+//
+//	import (
+//		"github.com/teambition/gear"
+//		"github.com/teambition/gear/middleware/routestats"
+//	)
+//
+//	func main() {
+//		app := gear.New()
+//		app.UseHandler(routestats.New())
+//		router := gear.NewRouter()
+//		router.Get("/users/:id", GetUser)
+//		app.UseHandler(router)
+//		app.Error(app.Listen(":3000"))
+//		// GET /debug/gear/routes
+//	}
+func New(options ...Options) *Stats {
+	opts := Options{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.Path == "" {
+		opts.Path = "/debug/gear/routes"
+	}
+	if opts.Size <= 0 {
+		opts.Size = 1024
+	}
+	if len(opts.Buckets) == 0 {
+		opts.Buckets = []time.Duration{
+			5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+			100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+			time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+		}
+	} else {
+		opts.Buckets = append([]time.Duration{}, opts.Buckets...)
+	}
+	sort.Slice(opts.Buckets, func(i, j int) bool { return opts.Buckets[i] < opts.Buckets[j] })
+	return &Stats{opts: opts, routes: make(map[string]*route)}
+}
+
+// Serve implemented gear.Handler interface.
+func (s *Stats) Serve(ctx *gear.Context) error {
+	if ctx.Path == s.opts.Path && ctx.Method == http.MethodGet {
+		return ctx.JSON(http.StatusOK, s.Routes())
+	}
+
+	ctx.OnEnd(func() {
+		if pattern := gear.GetRouterPatternFromCtx(ctx); pattern != "" {
+			s.Record(ctx.Method, pattern, ctx.Res.Status(), time.Since(ctx.StartAt))
+		}
+	})
+	return nil
+}
+
+// Record records a request's status and latency to the route.
+func (s *Stats) Record(method, pattern string, status int, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := method + " " + pattern
+	r := s.routes[key]
+	if r == nil {
+		r = &route{
+			method:  method,
+			pattern: pattern,
+			status:  make(map[string]int64),
+			buckets: make([]int64, len(s.opts.Buckets)+1),
+			ring:    make([]time.Duration, 0, s.opts.Size),
+		}
+		s.routes[key] = r
+	}
+
+	r.n++
+	r.total += d
+	if r.n == 1 || d < r.min {
+		r.min = d
+	}
+	if d > r.max {
+		r.max = d
+	}
+	r.status[strconv.Itoa(status/100)+"xx"]++
+	i := sort.Search(len(s.opts.Buckets), func(i int) bool { return d <= s.opts.Buckets[i] })
+	r.buckets[i]++
+	if len(r.ring) < cap(r.ring) {
+		r.ring = append(r.ring, d)
+	} else {
+		r.ring[r.next] = d
+		r.next = (r.next + 1) % len(r.ring)
+	}
+}
+
+// Routes returns the stats of all recorded routes, the slowest (by p99) first.
+func (s *Stats) Routes() []*RouteStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]*RouteStats, 0, len(s.routes))
+	for _, r := range s.routes {
+		res = append(res, s.stats(r))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].P99 != res[j].P99 {
+			return res[i].P99 > res[j].P99
+		}
+		return res[i].Method+" "+res[i].Pattern < res[j].Method+" "+res[j].Pattern
+	})
+	return res
+}
+
+// Reset removes all recorded stats.
+func (s *Stats) Reset() {
+	s.mu.Lock()
+	s.routes = make(map[string]*route)
+	s.mu.Unlock()
+}
+
+func (s *Stats) stats(r *route) *RouteStats {
+	rs := &RouteStats{
+		Method:    r.method,
+		Pattern:   r.pattern,
+		Count:     r.n,
+		Status:    make(map[string]int64, len(r.status)),
+		Min:       ms(r.min),
+		Max:       ms(r.max),
+		Avg:       ms(r.total / time.Duration(r.n)),
+		Histogram: make([]Bucket, len(r.buckets)),
+	}
+	for k, v := range r.status {
+		rs.Status[k] = v
+	}
+
+	var cumulative int64
+	for i, count := range r.buckets {
+		cumulative += count
+		le := "+Inf"
+		if i < len(s.opts.Buckets) {
+			le = strconv.FormatFloat(ms(s.opts.Buckets[i]), 'f', -1, 64)
+		}
+		rs.Histogram[i] = Bucket{Le: le, Count: cumulative}
+	}
+
+	sorted := make([]time.Duration, len(r.ring))
+	copy(sorted, r.ring)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rs.P50 = ms(percentile(sorted, 0.5))
+	rs.P90 = ms(percentile(sorted, 0.9))
+	rs.P99 = ms(percentile(sorted, 0.99))
+	return rs
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
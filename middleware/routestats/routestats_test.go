@@ -0,0 +1,87 @@
+package routestats
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func TestGearMiddlewareRouteStats(t *testing.T) {
+	assert := assert.New(t)
+
+	stats := New()
+	app := gear.New()
+	app.UseHandler(stats)
+	router := gear.NewRouter()
+	router.Get("/fast/:id", func(ctx *gear.Context) error {
+		return ctx.End(http.StatusNoContent)
+	})
+	router.Get("/slow", func(ctx *gear.Context) error {
+		time.Sleep(30 * time.Millisecond)
+		return gear.ErrBadGateway
+	})
+	app.UseHandler(router)
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	for _, path := range []string{"/fast/1", "/fast/2", "/slow", "/none"} {
+		res, err := http.Get(host + path)
+		assert.Nil(err)
+		res.Body.Close()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	res, err := http.Get(host + "/debug/gear/routes")
+	assert.Nil(err)
+	var routes []*RouteStats
+	assert.Nil(json.NewDecoder(res.Body).Decode(&routes))
+	res.Body.Close()
+
+	assert.Equal(2, len(routes))
+	slow := routes[0]
+	assert.Equal("GET", slow.Method)
+	assert.Equal("/slow", slow.Pattern)
+	assert.Equal(int64(1), slow.Count)
+	assert.Equal(map[string]int64{"5xx": 1}, slow.Status)
+	assert.True(slow.Min >= 30 && slow.P99 == slow.Min)
+	assert.Equal(Bucket{Le: "25", Count: 0}, slow.Histogram[2])
+	assert.Equal(Bucket{Le: "+Inf", Count: 1}, slow.Histogram[len(slow.Histogram)-1])
+
+	fast := routes[1]
+	assert.Equal("/fast/:id", fast.Pattern)
+	assert.Equal(int64(2), fast.Count)
+	assert.Equal(map[string]int64{"2xx": 2}, fast.Status)
+
+	stats.Reset()
+	assert.Equal(0, len(stats.Routes()))
+}
+
+func TestStatsRecord(t *testing.T) {
+	assert := assert.New(t)
+
+	stats := New(Options{Size: 10, Buckets: []time.Duration{100 * time.Millisecond, 10 * time.Millisecond}})
+	for i := 1; i <= 20; i++ {
+		stats.Record("GET", "/", 200, time.Duration(i)*time.Millisecond)
+	}
+	stats.Record("POST", "/", 500, time.Second)
+
+	routes := stats.Routes()
+	assert.Equal(2, len(routes))
+	assert.Equal("POST", routes[0].Method)
+
+	rs := routes[1]
+	assert.Equal(int64(20), rs.Count)
+	assert.Equal(1.0, rs.Min)
+	assert.Equal(20.0, rs.Max)
+	assert.Equal(10.5, rs.Avg)
+	// only the latest 10 latencies are kept in ring buffer
+	assert.Equal(15.0, rs.P50)
+	assert.Equal(19.0, rs.P90)
+	assert.Equal(20.0, rs.P99)
+	assert.Equal([]Bucket{{"10", 10}, {"100", 20}, {"+Inf", 20}}, rs.Histogram)
+}
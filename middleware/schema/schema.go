@@ -0,0 +1,150 @@
+package schema
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/teambition/gear"
+)
+
+// Options is schema middleware options.
+type Options struct {
+	// Envs defines the app envs (`app.Set(gear.SetEnv, env)`) that the validation is enabled in.
+	// Optional. Default to []string{"development"}.
+	Envs []string
+	// Schemas maps the routes to the JSON Schema files that their JSON responses are validated against.
+	// The route is the request method and the router pattern, such as "GET /api/users/:id". The file may
+	// have a JSON pointer fragment to the schema in it, so the schemas can be taken from an OpenAPI document
+	// directly, such as "openapi.json#/components/schemas/User".
+	Schemas map[string]string
+	// Dir is the directory that the relative schema files are in.
+	// Optional. Default to the working directory.
+	Dir string
+	// Logger logs the violations and the schema loading errors.
+	// Optional. Default to log.Default().
+	Logger *log.Logger
+	// OnViolation is called with the route and the violations of an invalid response, such as
+	// `$.user.age: expected integer, got string`. It is called after the response is sent.
+	// Optional. Default to log the violations by Logger.
+	OnViolation func(ctx *gear.Context, route string, violations []string)
+}
+
+// New creates a middleware to validate the JSON responses (2xx) of the routes in Options.Schemas against
+// JSON Schema, to catch contract drift before clients do. It does nothing if app env is not in Options.Envs,
+// so don't worry about enabling it in production. The schema files are loaded on first use, a file that
+// fails to load is logged and its routes are not validated.
+//
+//	package main
+//
+//	import (
+//		"github.com/teambition/gear"
+//		"github.com/teambition/gear/middleware/schema"
+//	)
+//
+//	func main() {
+//		app := gear.New()
+//		app.Use(schema.New(schema.Options{
+//			Dir: "./api",
+//			Schemas: map[string]string{
+//				"GET /users/:id": "openapi.json#/components/schemas/User",
+//				"GET /orgs/:id":  "org.schema.json",
+//			},
+//		}))
+//		router := gear.NewRouter()
+//		router.Get("/users/:id", func(ctx *gear.Context) error {
+//			return ctx.JSON(200, map[string]any{"id": ctx.Param("id"), "name": "gear"})
+//		})
+//		app.UseHandler(router)
+//		app.Error(app.Listen(":3000"))
+//	}
+func New(options ...Options) gear.Middleware {
+	opts := Options{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if len(opts.Envs) == 0 {
+		opts.Envs = []string{"development"}
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	if opts.OnViolation == nil {
+		logger := opts.Logger
+		opts.OnViolation = func(ctx *gear.Context, route string, violations []string) {
+			logger.Printf("schema violations of %s:\n\t%s", route, strings.Join(violations, "\n\t"))
+		}
+	}
+
+	envs := make(map[string]bool, len(opts.Envs))
+	for _, env := range opts.Envs {
+		envs[env] = true
+	}
+	var once sync.Once
+	var schemas map[string]*Schema
+
+	return func(ctx *gear.Context) error {
+		if env, _ := ctx.Setting(gear.SetEnv).(string); !envs[env] {
+			return nil
+		}
+		once.Do(func() { schemas = load(&opts) })
+
+		ctx.OnEnd(func() {
+			route := ctx.Method + " " + gear.GetRouterPatternFromCtx(ctx)
+			s := schemas[route]
+			if s == nil || ctx.Res.Status() < 200 || ctx.Res.Status() >= 300 || !isJSON(ctx) {
+				return
+			}
+			body := ctx.Res.Body()
+			if len(body) == 0 {
+				return
+			}
+			if violations := s.Validate(body); len(violations) > 0 {
+				opts.OnViolation(ctx, route, violations)
+			}
+		})
+		return nil
+	}
+}
+
+func load(opts *Options) map[string]*Schema {
+	files := make(map[string][]byte)
+	schemas := make(map[string]*Schema, len(opts.Schemas))
+	for route, file := range opts.Schemas {
+		name, fragment, _ := strings.Cut(file, "#")
+		if opts.Dir != "" && !filepath.IsAbs(name) {
+			name = filepath.Join(opts.Dir, name)
+		}
+		data, ok := files[name]
+		if !ok {
+			var err error
+			if data, err = os.ReadFile(name); err != nil {
+				opts.Logger.Printf("schema: failed to load %q for %s: %v", file, route, err)
+				continue
+			}
+			files[name] = data
+		}
+		s, err := Parse(data, fragment)
+		if err != nil {
+			opts.Logger.Printf("schema: failed to load %q for %s: %v", file, route, err)
+			continue
+		}
+		schemas[normalize(route)] = s
+	}
+	return schemas
+}
+
+// normalize returns the route as "METHOD pattern", such as "GET /api/users/:id".
+func normalize(route string) string {
+	method, pattern, _ := strings.Cut(strings.TrimSpace(route), " ")
+	return fmt.Sprintf("%s %s", strings.ToUpper(method), strings.TrimSpace(pattern))
+}
+
+func isJSON(ctx *gear.Context) bool {
+	mediaType, _, _ := mime.ParseMediaType(ctx.Res.Get(gear.HeaderContentType))
+	return mediaType == gear.MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,174 @@
+package schema
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+type violation struct {
+	route      string
+	violations []string
+}
+
+// buffer is a bytes.Buffer safe for concurrent use, the violations are logged in end hooks.
+type buffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *buffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newApp(t *testing.T, env string, opts Options) (string, chan violation) {
+	ch := make(chan violation, 10)
+	opts.OnViolation = func(ctx *gear.Context, route string, violations []string) {
+		ch <- violation{route, violations}
+	}
+
+	app := gear.New()
+	app.Set(gear.SetEnv, env)
+	app.Use(New(opts))
+	router := gear.NewRouter(gear.RouterOptions{Root: "/api"})
+	router.Get("/users/:id", func(ctx *gear.Context) error {
+		if ctx.Param("id") == "0" {
+			return gear.ErrNotFound.WithMsg("user not found")
+		}
+		if ctx.Query("bad") != "" {
+			return ctx.JSON(200, map[string]any{"id": ctx.Param("id")})
+		}
+		return ctx.JSON(200, map[string]any{"id": ctx.Param("id"), "name": "gear"})
+	})
+	router.Get("/orgs/:id", func(ctx *gear.Context) error {
+		return ctx.JSON(200, map[string]any{"id": 1})
+	})
+	app.UseHandler(router)
+	return "http://" + app.Start().Addr().String(), ch
+}
+
+func get(t *testing.T, url string) int {
+	res, err := http.Get(url)
+	assert.Nil(t, err)
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func expectViolation(t *testing.T, ch chan violation) violation {
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		t.Error("no violation reported")
+		return violation{}
+	}
+}
+
+func expectNoViolation(t *testing.T, ch chan violation) {
+	select {
+	case v := <-ch:
+		t.Errorf("unexpected violation: %v", v)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestGearMiddlewareSchema(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "openapi.json"), []byte(`{
+		"components": {"schemas": {"User": {
+			"type": "object",
+			"required": ["id", "name"],
+			"properties": {"id": {"type": "string"}, "name": {"type": "string"}}
+		}}}
+	}`), 0o644))
+
+	t.Run("should validate JSON responses of selected routes", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host, ch := newApp(t, "development", Options{
+			Dir: dir,
+			Schemas: map[string]string{
+				"get /api/users/:id": "openapi.json#/components/schemas/User",
+			},
+		})
+		assert.Equal(200, get(t, host+"/api/users/1"))
+		expectNoViolation(t, ch)
+
+		assert.Equal(200, get(t, host+"/api/users/1?bad=1"))
+		assert.Equal(violation{"GET /api/users/:id", []string{`$: missing required property "name"`}},
+			expectViolation(t, ch))
+
+		assert.Equal(404, get(t, host+"/api/users/0"))
+		assert.Equal(200, get(t, host+"/api/orgs/1"))
+		expectNoViolation(t, ch)
+	})
+
+	t.Run("should do nothing if env is not enabled", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host, ch := newApp(t, "production", Options{
+			Dir:     dir,
+			Schemas: map[string]string{"GET /api/users/:id": "openapi.json#/components/schemas/User"},
+		})
+		assert.Equal(200, get(t, host+"/api/users/1?bad=1"))
+		expectNoViolation(t, ch)
+	})
+
+	t.Run("should log schema loading errors", func(t *testing.T) {
+		assert := assert.New(t)
+
+		buf := &buffer{}
+		host, ch := newApp(t, "test", Options{
+			Envs:   []string{"test"},
+			Dir:    dir,
+			Logger: log.New(buf, "", 0),
+			Schemas: map[string]string{
+				"GET /api/users/:id": "openapi.json#/components/schemas/Unknown",
+				"GET /api/orgs/:id":  "org.json",
+			},
+		})
+		assert.Equal(200, get(t, host+"/api/users/1?bad=1"))
+		assert.Equal(200, get(t, host+"/api/orgs/1"))
+		expectNoViolation(t, ch)
+		assert.Contains(buf.String(), `schema: failed to load "openapi.json#/components/schemas/Unknown" for GET /api/users/:id`)
+		assert.Contains(buf.String(), `schema: failed to load "org.json" for GET /api/orgs/:id`)
+	})
+
+	t.Run("should log violations by default", func(t *testing.T) {
+		assert := assert.New(t)
+
+		buf := &buffer{}
+		app := gear.New()
+		app.Use(New(Options{
+			Envs:    []string{"development"},
+			Dir:     dir,
+			Logger:  log.New(buf, "", 0),
+			Schemas: map[string]string{"GET /users/:id": "openapi.json#/components/schemas/User"},
+		}))
+		router := gear.NewRouter()
+		router.Get("/users/:id", func(ctx *gear.Context) error {
+			return ctx.JSON(200, map[string]any{"id": 1, "name": "gear"})
+		})
+		app.UseHandler(router)
+		host := "http://" + app.Start().Addr().String()
+		assert.Equal(200, get(t, host+"/users/1"))
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal("schema violations of GET /users/:id:\n\t$.id: expected string, got number\n", buf.String())
+	})
+}
@@ -0,0 +1,372 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Schema is a compiled JSON Schema. It supports the keywords commonly used to describe API responses:
+// type, enum, const, properties, required, additionalProperties, items, minItems, maxItems, uniqueItems,
+// minLength, maxLength, pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf,
+// minProperties, maxProperties, allOf, anyOf, oneOf, not, nullable (OpenAPI 3.0) and the local $ref
+// (such as "#/components/schemas/User"). Other keywords are ignored.
+type Schema struct {
+	doc  any // the whole document that $ref is resolved in
+	node any // the schema node in doc
+	mu   sync.Mutex
+	res  map[string]*regexp.Regexp
+}
+
+// Parse parses a JSON Schema document. The fragment is a JSON pointer (RFC 6901) to the schema in the
+// document, such as "/components/schemas/User" for an OpenAPI document, or "" for the document itself.
+func Parse(data []byte, fragment string) (*Schema, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	node, err := pointer(doc, fragment)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{doc: doc, node: node, res: make(map[string]*regexp.Regexp)}, nil
+}
+
+// Validate validates the JSON data, and returns the violations, such as
+// `$.user.age: expected integer, got string`. It returns nil if the data is valid.
+func (s *Schema) Validate(data []byte) []string {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []string{"$: invalid JSON: " + err.Error()}
+	}
+	var errs []string
+	s.validate(s.node, v, "$", &errs, 0)
+	return errs
+}
+
+func (s *Schema) validate(node, v any, path string, errs *[]string, depth int) {
+	if depth > 64 {
+		*errs = append(*errs, path+": schema $ref is too deep")
+		return
+	}
+	switch n := node.(type) {
+	case bool:
+		if !n {
+			*errs = append(*errs, path+": not allowed")
+		}
+		return
+	case map[string]any:
+		if ref, ok := n["$ref"].(string); ok {
+			target, err := s.resolve(ref)
+			if err != nil {
+				*errs = append(*errs, path+": "+err.Error())
+				return
+			}
+			s.validate(target, v, path, errs, depth+1)
+			return
+		}
+		s.validateObject(n, v, path, errs, depth)
+	}
+}
+
+func (s *Schema) validateObject(n map[string]any, v any, path string, errs *[]string, depth int) {
+	if v == nil && n["nullable"] == true {
+		return
+	}
+	if t, ok := n["type"]; ok && !matchType(t, v) {
+		*errs = append(*errs, fmt.Sprintf("%s: expected %s, got %s", path, typeString(t), typeOf(v)))
+		return
+	}
+	if enum, ok := n["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*errs = append(*errs, fmt.Sprintf("%s: %s is not one of %s", path, encode(v), encode(enum)))
+		}
+	}
+	if c, ok := n["const"]; ok && !reflect.DeepEqual(c, v) {
+		*errs = append(*errs, fmt.Sprintf("%s: %s is not %s", path, encode(v), encode(c)))
+	}
+
+	switch val := v.(type) {
+	case string:
+		length := float64(utf8.RuneCountInString(val))
+		if min, ok := number(n["minLength"]); ok && length < min {
+			*errs = append(*errs, fmt.Sprintf("%s: length %v is less than %v", path, length, min))
+		}
+		if max, ok := number(n["maxLength"]); ok && length > max {
+			*errs = append(*errs, fmt.Sprintf("%s: length %v is greater than %v", path, length, max))
+		}
+		if pattern, ok := n["pattern"].(string); ok {
+			if re, err := s.regexp(pattern); err != nil {
+				*errs = append(*errs, fmt.Sprintf("%s: invalid pattern %q", path, pattern))
+			} else if !re.MatchString(val) {
+				*errs = append(*errs, fmt.Sprintf("%s: %q does not match %q", path, val, pattern))
+			}
+		}
+
+	case float64:
+		if min, ok := number(n["minimum"]); ok {
+			if val < min || (val == min && n["exclusiveMinimum"] == true) {
+				*errs = append(*errs, fmt.Sprintf("%s: %v is less than minimum %v", path, val, min))
+			}
+		}
+		if max, ok := number(n["maximum"]); ok {
+			if val > max || (val == max && n["exclusiveMaximum"] == true) {
+				*errs = append(*errs, fmt.Sprintf("%s: %v is greater than maximum %v", path, val, max))
+			}
+		}
+		if min, ok := number(n["exclusiveMinimum"]); ok && val <= min {
+			*errs = append(*errs, fmt.Sprintf("%s: %v is not greater than %v", path, val, min))
+		}
+		if max, ok := number(n["exclusiveMaximum"]); ok && val >= max {
+			*errs = append(*errs, fmt.Sprintf("%s: %v is not less than %v", path, val, max))
+		}
+		if m, ok := number(n["multipleOf"]); ok && m > 0 {
+			if q := val / m; math.Abs(q-math.Round(q)) > 1e-9 {
+				*errs = append(*errs, fmt.Sprintf("%s: %v is not a multiple of %v", path, val, m))
+			}
+		}
+
+	case []any:
+		count := float64(len(val))
+		if min, ok := number(n["minItems"]); ok && count < min {
+			*errs = append(*errs, fmt.Sprintf("%s: %v items is less than %v", path, count, min))
+		}
+		if max, ok := number(n["maxItems"]); ok && count > max {
+			*errs = append(*errs, fmt.Sprintf("%s: %v items is greater than %v", path, count, max))
+		}
+		if n["uniqueItems"] == true {
+		check:
+			for i := range val {
+				for j := 0; j < i; j++ {
+					if reflect.DeepEqual(val[i], val[j]) {
+						*errs = append(*errs, fmt.Sprintf("%s: items %d and %d are equal", path, j, i))
+						break check
+					}
+				}
+			}
+		}
+		if items, ok := n["items"]; ok {
+			for i, item := range val {
+				s.validate(items, item, path+"["+strconv.Itoa(i)+"]", errs, depth+1)
+			}
+		}
+
+	case map[string]any:
+		count := float64(len(val))
+		if min, ok := number(n["minProperties"]); ok && count < min {
+			*errs = append(*errs, fmt.Sprintf("%s: %v properties is less than %v", path, count, min))
+		}
+		if max, ok := number(n["maxProperties"]); ok && count > max {
+			*errs = append(*errs, fmt.Sprintf("%s: %v properties is greater than %v", path, count, max))
+		}
+		if required, ok := n["required"].([]any); ok {
+			for _, r := range required {
+				if name, ok := r.(string); ok {
+					if _, ok := val[name]; !ok {
+						*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", path, name))
+					}
+				}
+			}
+		}
+		props, _ := n["properties"].(map[string]any)
+		additional, hasAdditional := n["additionalProperties"]
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if prop, ok := props[key]; ok {
+				s.validate(prop, val[key], propPath(path, key), errs, depth+1)
+			} else if hasAdditional {
+				if additional == false {
+					*errs = append(*errs, fmt.Sprintf("%s: additional property %q is not allowed", path, key))
+				} else {
+					s.validate(additional, val[key], propPath(path, key), errs, depth+1)
+				}
+			}
+		}
+	}
+
+	if all, ok := n["allOf"].([]any); ok {
+		for _, sub := range all {
+			s.validate(sub, v, path, errs, depth+1)
+		}
+	}
+	if some, ok := n["anyOf"].([]any); ok {
+		if s.count(some, v, path, depth) == 0 {
+			*errs = append(*errs, path+": does not match any schema of anyOf")
+		}
+	}
+	if one, ok := n["oneOf"].([]any); ok {
+		if c := s.count(one, v, path, depth); c != 1 {
+			*errs = append(*errs, fmt.Sprintf("%s: matches %d schemas of oneOf, expected 1", path, c))
+		}
+	}
+	if not, ok := n["not"]; ok {
+		var sub []string
+		if s.validate(not, v, path, &sub, depth+1); len(sub) == 0 {
+			*errs = append(*errs, path+": should not match the schema of not")
+		}
+	}
+}
+
+// count returns the number of the schemas that v is valid against.
+func (s *Schema) count(schemas []any, v any, path string, depth int) int {
+	c := 0
+	for _, sub := range schemas {
+		var errs []string
+		if s.validate(sub, v, path, &errs, depth+1); len(errs) == 0 {
+			c++
+		}
+	}
+	return c
+}
+
+func (s *Schema) resolve(ref string) (any, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported $ref %q, only local $ref is supported", ref)
+	}
+	return pointer(s.doc, ref[1:])
+}
+
+func (s *Schema) regexp(pattern string) (*regexp.Regexp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if re, ok := s.res[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	s.res[pattern] = re
+	return re, nil
+}
+
+// pointer resolves the JSON pointer (RFC 6901) in doc, the pointer may be URL encoded.
+func pointer(doc any, ptr string) (any, error) {
+	if p, err := url.PathUnescape(ptr); err == nil {
+		ptr = p
+	}
+	if ptr == "" || ptr == "/" {
+		return doc, nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", ptr)
+	}
+	node := doc
+	for _, token := range strings.Split(ptr[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch n := node.(type) {
+		case map[string]any:
+			v, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("JSON pointer %q not found", ptr)
+			}
+			node = v
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, fmt.Errorf("JSON pointer %q not found", ptr)
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("JSON pointer %q not found", ptr)
+		}
+	}
+	return node, nil
+}
+
+func matchType(t, v any) bool {
+	switch t := t.(type) {
+	case string:
+		return isType(t, v)
+	case []any:
+		for _, s := range t {
+			if name, ok := s.(string); ok && isType(name, v) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func isType(name string, v any) bool {
+	switch name {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	default:
+		return name == typeOf(v)
+	}
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func typeString(t any) string {
+	if list, ok := t.([]any); ok {
+		names := make([]string, 0, len(list))
+		for _, s := range list {
+			names = append(names, fmt.Sprint(s))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func number(v any) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+func encode(v any) string {
+	buf, _ := json.Marshal(v)
+	return string(buf)
+}
+
+func propPath(path, key string) string {
+	if key == "" {
+		return path + `[""]`
+	}
+	for i, r := range key {
+		if !(r == '_' || r == '$' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || i > 0 && '0' <= r && r <= '9') {
+			return path + "[" + strconv.Quote(key) + "]"
+		}
+	}
+	return path + "." + key
+}
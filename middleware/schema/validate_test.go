@@ -0,0 +1,102 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	t.Run("should validate by keywords", func(t *testing.T) {
+		assert := assert.New(t)
+
+		s, err := Parse([]byte(`{
+			"type": "object",
+			"required": ["id", "name"],
+			"additionalProperties": false,
+			"properties": {
+				"id": {"type": "integer", "minimum": 1},
+				"name": {"type": "string", "minLength": 2, "maxLength": 8, "pattern": "^[a-z]+$"},
+				"role": {"enum": ["admin", "member"]},
+				"score": {"type": "number", "exclusiveMaximum": 100, "multipleOf": 0.5},
+				"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2, "uniqueItems": true},
+				"email": {"type": "string", "nullable": true},
+				"x-meta": {"type": ["object", "null"], "minProperties": 1}
+			}
+		}`), "")
+		assert.Nil(err)
+
+		assert.Nil(s.Validate([]byte(`{"id":1,"name":"gear","role":"admin","score":99.5,"tags":["a"],"email":null}`)))
+		assert.Nil(s.Validate([]byte(`{"id":1,"name":"gear","x-meta":null}`)))
+
+		assert.Equal([]string{
+			`$: missing required property "name"`,
+			`$.id: expected integer, got number`,
+		}, s.Validate([]byte(`{"id":1.5}`)))
+		assert.Equal([]string{
+			`$.id: 0 is less than minimum 1`,
+			`$.name: length 9 is greater than 8`,
+			`$.name: "Gearframe" does not match "^[a-z]+$"`,
+			`$.role: "guest" is not one of ["admin","member"]`,
+			`$.score: 100 is not less than 100`,
+			`$.tags: 3 items is greater than 2`,
+			`$.tags: items 0 and 2 are equal`,
+			`$.tags[1]: expected string, got boolean`,
+			`$["x-meta"]: 0 properties is less than 1`,
+		}, s.Validate([]byte(`{"id":0,"name":"Gearframe","role":"guest","score":100,"tags":["a",true,"a"],"x-meta":{}}`)))
+		assert.Equal([]string{
+			`$: additional property "age" is not allowed`,
+			`$.score: 1.2 is not a multiple of 0.5`,
+		}, s.Validate([]byte(`{"id":1,"name":"gear","score":1.2,"age":1}`)))
+		assert.Equal([]string{`$: expected object, got array`}, s.Validate([]byte(`[]`)))
+		assert.Equal(1, len(s.Validate([]byte(`{`))))
+	})
+
+	t.Run("should resolve $ref and fragment in OpenAPI document", func(t *testing.T) {
+		assert := assert.New(t)
+
+		doc := []byte(`{
+			"openapi": "3.0.0",
+			"components": {"schemas": {
+				"User": {"type": "object", "required": ["id"], "properties": {
+					"id": {"type": "string"},
+					"org": {"$ref": "#/components/schemas/Org"},
+					"friends": {"type": "array", "items": {"$ref": "#/components/schemas/User"}}
+				}},
+				"Org": {"oneOf": [
+					{"type": "object", "required": ["id"], "properties": {"id": {"type": "string"}}},
+					{"type": "string"}
+				]},
+				"Pet": {"allOf": [
+					{"required": ["kind"]},
+					{"not": {"properties": {"kind": {"const": "dragon"}}}}
+				], "anyOf": [{"type": "object"}, {"type": "string"}]}
+			}}
+		}`)
+		_, err := Parse(doc, "/components/schemas/Unknown")
+		assert.Error(err)
+		_, err = Parse([]byte(`{`), "")
+		assert.Error(err)
+
+		user, err := Parse(doc, "/components/schemas/User")
+		assert.Nil(err)
+		assert.Nil(user.Validate([]byte(`{"id":"u1","org":"o1","friends":[{"id":"u2","org":{"id":"o2"}}]}`)))
+		assert.Equal([]string{
+			`$.friends[0]: missing required property "id"`,
+			`$.friends[0].org: matches 0 schemas of oneOf, expected 1`,
+		}, user.Validate([]byte(`{"id":"u1","friends":[{"org":1}]}`)))
+
+		pet, err := Parse(doc, "/components/schemas/Pet")
+		assert.Nil(err)
+		assert.Nil(pet.Validate([]byte(`{"kind":"cat"}`)))
+		assert.Equal([]string{`$: should not match the schema of not`}, pet.Validate([]byte(`{"kind":"dragon"}`)))
+		assert.Equal([]string{
+			`$: should not match the schema of not`,
+			`$: does not match any schema of anyOf`,
+		}, pet.Validate([]byte(`1`)))
+
+		s, err := Parse([]byte(`{"$ref": "other.json#/User"}`), "")
+		assert.Nil(err)
+		assert.Equal([]string{`$: unsupported $ref "other.json#/User", only local $ref is supported`}, s.Validate([]byte(`{}`)))
+	})
+}
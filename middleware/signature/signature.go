@@ -0,0 +1,208 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/teambition/gear"
+)
+
+// Signature algorithms.
+const (
+	AlgorithmHMACSHA256 = "hmac-sha256"
+	AlgorithmEd25519    = "ed25519"
+)
+
+// HeaderDigest is the Digest header of RFC 3230.
+const HeaderDigest = "Digest"
+
+// Options is signature middleware options.
+type Options struct {
+	// Key is the signing key, a []byte secret for HMAC-SHA256, or an ed25519.PrivateKey for Ed25519.
+	// Verify accepts the ed25519.PublicKey too.
+	// Required.
+	Key any
+	// KeyID identifies the key for the receivers, such as for key rotation.
+	// Optional. Default to "", the keyId parameter is omitted.
+	KeyID string
+	// Header is the response header to emit the signature.
+	// Optional. Default to "Signature".
+	Header string
+	// Skipper returns true to skip signing the response.
+	// Optional. Default to nil.
+	Skipper func(ctx *gear.Context) bool
+}
+
+// New creates a middleware to sign the response body with HMAC-SHA256 or Ed25519, that is required by some partner
+// or webhook APIs. The signature is emitted in the Signature header, and the SHA-256 digest of the body in the Digest
+// header (RFC 3230):
+//
+//	Digest: SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=
+//	Signature: keyId="k1",algorithm="hmac-sha256",signature="bJX4...Q4E="
+//
+// Only the response body written by ctx.End (and ctx.JSON, ctx.HTML, etc.) is signed, the streamed responses
+// (written by ctx.Res.Write directly) and the responses without body are not. The body is signed before the
+// compression of gear.SetCompress, don't compress the signed responses if the receivers verify the compressed body.
+// The responses can be verified by Verify, such as the responses of app.Invoke in tests:
+//
+//	package main
+//
+//	import (
+//		"github.com/teambition/gear"
+//		"github.com/teambition/gear/middleware/signature"
+//	)
+//
+//	func main() {
+//		app := gear.New()
+//		app.Use(signature.New(signature.Options{
+//			Key:   []byte("some secret"),
+//			KeyID: "k1",
+//		}))
+//		app.Use(func(ctx *gear.Context) error {
+//			return ctx.JSON(200, map[string]any{"event": "created"})
+//		})
+//		app.Error(app.Listen(":3000"))
+//	}
+func New(opts Options) gear.Middleware {
+	if _, err := algorithmOf(opts.Key, false); err != nil {
+		panic(gear.Err.WithMsg(err.Error()))
+	}
+	if opts.Header == "" {
+		opts.Header = "Signature"
+	}
+
+	return func(ctx *gear.Context) error {
+		if opts.Skipper != nil && opts.Skipper(ctx) {
+			return nil
+		}
+		ctx.After(func() {
+			body := ctx.Res.Body()
+			if body == nil {
+				return
+			}
+			ctx.SetHeader(HeaderDigest, Digest(body))
+			ctx.SetHeader(opts.Header, sign(opts, body))
+		})
+		return nil
+	}
+}
+
+// Digest returns the Digest header value of RFC 3230 with the SHA-256 algorithm for the body.
+func Digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Verify verifies the Digest and the signature headers (see New) of the response body with the key in opts.
+// It returns nil if both of them are valid.
+//
+//	res := app.Invoke(req)
+//	body, _ := io.ReadAll(res.Body)
+//	err := signature.Verify(signature.Options{Key: publicKey}, res.Header, body)
+func Verify(opts Options, header http.Header, body []byte) error {
+	algorithm, err := algorithmOf(opts.Key, true)
+	if err != nil {
+		return err
+	}
+	if opts.Header == "" {
+		opts.Header = "Signature"
+	}
+
+	digest := header.Get(HeaderDigest)
+	if digest == "" {
+		return errors.New("signature: missing Digest header")
+	}
+	if digest != Digest(body) {
+		return errors.New("signature: digest mismatch")
+	}
+
+	params := parseParams(header.Get(opts.Header))
+	if params["signature"] == "" {
+		return fmt.Errorf("signature: missing %s header", opts.Header)
+	}
+	if params["algorithm"] != algorithm {
+		return fmt.Errorf("signature: unexpected algorithm %q", params["algorithm"])
+	}
+	if opts.KeyID != "" && params["keyId"] != opts.KeyID {
+		return fmt.Errorf("signature: unexpected keyId %q", params["keyId"])
+	}
+	sig, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil {
+		return fmt.Errorf("signature: invalid signature: %v", err)
+	}
+
+	ok := false
+	switch key := opts.Key.(type) {
+	case []byte:
+		ok = subtle.ConstantTimeCompare(sig, hmacSum(key, body)) == 1
+	case ed25519.PrivateKey:
+		ok = ed25519.Verify(key.Public().(ed25519.PublicKey), body, sig)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, body, sig)
+	}
+	if !ok {
+		return errors.New("signature: signature mismatch")
+	}
+	return nil
+}
+
+func sign(opts Options, body []byte) string {
+	var algorithm string
+	var sig []byte
+	switch key := opts.Key.(type) {
+	case []byte:
+		algorithm, sig = AlgorithmHMACSHA256, hmacSum(key, body)
+	case ed25519.PrivateKey:
+		algorithm, sig = AlgorithmEd25519, ed25519.Sign(key, body)
+	}
+
+	val := fmt.Sprintf(`algorithm=%q,signature=%q`, algorithm, base64.StdEncoding.EncodeToString(sig))
+	if opts.KeyID != "" {
+		val = fmt.Sprintf(`keyId=%q,`, opts.KeyID) + val
+	}
+	return val
+}
+
+func hmacSum(key, body []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// algorithmOf returns the algorithm of the key, the public key is valid only for verifying.
+func algorithmOf(key any, verifying bool) (string, error) {
+	switch k := key.(type) {
+	case []byte:
+		if len(k) > 0 {
+			return AlgorithmHMACSHA256, nil
+		}
+	case ed25519.PrivateKey:
+		if len(k) == ed25519.PrivateKeySize {
+			return AlgorithmEd25519, nil
+		}
+	case ed25519.PublicKey:
+		if verifying && len(k) == ed25519.PublicKeySize {
+			return AlgorithmEd25519, nil
+		}
+	}
+	return "", fmt.Errorf("signature: invalid key %T", key)
+}
+
+// parseParams parses the `key="value"` parameters separated by ",".
+func parseParams(s string) map[string]string {
+	params := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok {
+			params[key] = strings.Trim(val, `"`)
+		}
+	}
+	return params
+}
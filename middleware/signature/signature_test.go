@@ -0,0 +1,113 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func newApp(opts Options) *gear.App {
+	app := gear.New()
+	app.Use(New(opts))
+	app.Use(func(ctx *gear.Context) error {
+		switch ctx.Path {
+		case "/stream":
+			ctx.Res.WriteHeader(200)
+			_, err := ctx.Res.Write([]byte("streaming"))
+			return err
+		case "/empty":
+			return ctx.End(204)
+		case "/skip":
+			return ctx.HTML(200, "skip")
+		}
+		return ctx.JSON(200, map[string]any{"event": "created"})
+	})
+	return app
+}
+
+func invoke(app *gear.App, path string) (*http.Response, []byte) {
+	res := app.Invoke(httptest.NewRequest("GET", path, nil))
+	body, _ := io.ReadAll(res.Body)
+	return res, body
+}
+
+func TestSignature(t *testing.T) {
+	assert.Panics(t, func() {
+		New(Options{})
+	})
+	assert.Panics(t, func() {
+		New(Options{Key: "secret"})
+	})
+	assert.Panics(t, func() {
+		New(Options{Key: make(ed25519.PublicKey, ed25519.PublicKeySize)})
+	})
+
+	t.Run("Digest", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.Equal("SHA-256=47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", Digest([]byte{}))
+		assert.Equal("SHA-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=", Digest([]byte(`{"hello": "world"}`)))
+	})
+
+	t.Run("should sign with HMAC-SHA256", func(t *testing.T) {
+		assert := assert.New(t)
+
+		opts := Options{Key: []byte("some secret"), KeyID: "k1"}
+		app := newApp(opts)
+		res, body := invoke(app, "/")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(Digest(body), res.Header.Get(HeaderDigest))
+		assert.Contains(res.Header.Get("Signature"), `keyId="k1",algorithm="hmac-sha256",signature="`)
+		assert.Nil(Verify(opts, res.Header, body))
+
+		assert.ErrorContains(Verify(opts, res.Header, []byte(`{"event":"deleted"}`)), "digest mismatch")
+		assert.ErrorContains(Verify(Options{Key: []byte("other secret")}, res.Header, body), "signature mismatch")
+		assert.ErrorContains(Verify(Options{Key: []byte("some secret"), KeyID: "k2"}, res.Header, body), `unexpected keyId "k1"`)
+		_, priv, _ := ed25519.GenerateKey(rand.Reader)
+		assert.ErrorContains(Verify(Options{Key: priv}, res.Header, body), `unexpected algorithm "hmac-sha256"`)
+		assert.ErrorContains(Verify(Options{}, res.Header, body), "invalid key <nil>")
+	})
+
+	t.Run("should sign with Ed25519", func(t *testing.T) {
+		assert := assert.New(t)
+
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		assert.Nil(err)
+		app := newApp(Options{Key: priv, Header: "X-Signature"})
+		res, body := invoke(app, "/")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("", res.Header.Get("Signature"))
+		assert.Contains(res.Header.Get("X-Signature"), `algorithm="ed25519",signature="`)
+		assert.Nil(Verify(Options{Key: pub, Header: "X-Signature"}, res.Header, body))
+		assert.Nil(Verify(Options{Key: priv, Header: "X-Signature"}, res.Header, body))
+		assert.ErrorContains(Verify(Options{Key: pub}, res.Header, body), "missing Signature header")
+
+		other, _, _ := ed25519.GenerateKey(rand.Reader)
+		assert.ErrorContains(Verify(Options{Key: other, Header: "X-Signature"}, res.Header, body), "signature mismatch")
+	})
+
+	t.Run("should not sign responses without body", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := newApp(Options{
+			Key: []byte("some secret"),
+			Skipper: func(ctx *gear.Context) bool {
+				return ctx.Path == "/skip"
+			},
+		})
+		for _, path := range []string{"/stream", "/empty", "/skip"} {
+			res, _ := invoke(app, path)
+			assert.Equal("", res.Header.Get(HeaderDigest), path)
+			assert.Equal("", res.Header.Get("Signature"), path)
+		}
+		res, body := invoke(app, "/stream")
+		assert.Equal("streaming", string(body))
+		assert.ErrorContains(Verify(Options{Key: []byte("some secret")}, res.Header, body), "missing Digest header")
+	})
+}
@@ -0,0 +1,262 @@
+package slo
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/teambition/gear"
+)
+
+// Options is slo middleware options.
+type Options struct {
+	// Path defines the debug endpoint that responds the SLO reports of routes as JSON.
+	// Optional. Default to "/debug/gear/slo".
+	Path string
+	// Objective defines the target ratio of successful (non 5xx) requests.
+	// Optional. Default to 0.999.
+	Objective float64
+	// LatencyThreshold defines the latency objective, the requests slower than it are counted as slow.
+	// Optional. Default to 0, no latency objective.
+	LatencyThreshold time.Duration
+	// LatencyObjective defines the target ratio of the requests not slower than LatencyThreshold.
+	// Optional. Default to 0.99.
+	LatencyObjective float64
+	// Windows defines the sliding windows to compute the burn rates.
+	// Optional. Default to 5 minutes and 1 hour.
+	Windows []time.Duration
+	// Resolution defines the granularity of the sliding windows.
+	// Optional. Default to 10 seconds.
+	Resolution time.Duration
+	// BurnRateThreshold defines the burn rate that the error budget is considered exhausted,
+	// if the availability or latency burn rates of all windows reach it.
+	// Optional. Default to 14.4, which exhausts 2% of a 30 days budget in 1 hour.
+	BurnRateThreshold float64
+	// OnExhausted is called when the error budget of a route becomes exhausted, such as to enable degradation mode.
+	// Optional.
+	OnExhausted func(r *RouteReport)
+	// OnRecovered is called when the error budget of a route becomes not exhausted after OnExhausted.
+	// Optional.
+	OnRecovered func(r *RouteReport)
+}
+
+// RouteReport is the SLO report of a route.
+type RouteReport struct {
+	Method    string          `json:"method"`
+	Pattern   string          `json:"pattern"`
+	Exhausted bool            `json:"exhausted"`
+	Windows   []*WindowReport `json:"windows"`
+}
+
+// WindowReport is the SLO attainment and burn rates of a route in a sliding window.
+// The burn rate is the ratio of the actual error rate to the error rate allowed by the objective,
+// 1 means the error budget will be exhausted exactly at the end of the SLO period.
+type WindowReport struct {
+	Window          string  `json:"window"`
+	Total           int64   `json:"total"`
+	SuccessRatio    float64 `json:"successRatio"`
+	BurnRate        float64 `json:"burnRate"`
+	LatencyRatio    float64 `json:"latencyRatio,omitempty"`
+	LatencyBurnRate float64 `json:"latencyBurnRate,omitempty"`
+}
+
+// SLO is a middleware that tracks the success ratio and latency objective attainment of routes.
+type SLO struct {
+	opts   Options
+	size   int64 // the number of buckets in ring
+	mu     sync.Mutex
+	routes map[string]*route
+	now    func() time.Time
+}
+
+type route struct {
+	method, pattern string
+	buckets         []bucket
+	exhausted       bool
+}
+
+type bucket struct {
+	idx                 int64 // the index of time resolution
+	total, errors, slow int64
+}
+
+// New creates a slo middleware. It should be used before routers, and only
+// the requests matched by routers are tracked.
+//
+//	package main
+//
+//	import (
+//		"time"
+//
+//		"github.com/teambition/gear"
+//		"github.com/teambition/gear/middleware/slo"
+//	)
+//
+//	func main() {
+//		app := gear.New()
+//		app.UseHandler(slo.New(slo.Options{
+//			Objective:        0.999,
+//			LatencyThreshold: 300 * time.Millisecond,
+//			OnExhausted: func(r *slo.RouteReport) {
+//				app.Error(gear.Err.WithMsgf("error budget of %s %s exhausted", r.Method, r.Pattern))
+//			},
+//		}))
+//		router := gear.NewRouter()
+//		router.Get("/users/:id", GetUser)
+//		app.UseHandler(router)
+//		app.Error(app.Listen(":3000"))
+//		// GET /debug/gear/slo
+//	}
+func New(options ...Options) *SLO {
+	opts := Options{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.Path == "" {
+		opts.Path = "/debug/gear/slo"
+	}
+	if opts.Objective <= 0 || opts.Objective >= 1 {
+		opts.Objective = 0.999
+	}
+	if opts.LatencyObjective <= 0 || opts.LatencyObjective >= 1 {
+		opts.LatencyObjective = 0.99
+	}
+	if len(opts.Windows) == 0 {
+		opts.Windows = []time.Duration{5 * time.Minute, time.Hour}
+	} else {
+		opts.Windows = append([]time.Duration{}, opts.Windows...)
+	}
+	sort.Slice(opts.Windows, func(i, j int) bool { return opts.Windows[i] < opts.Windows[j] })
+	if opts.Resolution <= 0 {
+		opts.Resolution = 10 * time.Second
+	}
+	if opts.BurnRateThreshold <= 0 {
+		opts.BurnRateThreshold = 14.4
+	}
+
+	size := int64(opts.Windows[len(opts.Windows)-1] / opts.Resolution)
+	if size < 1 {
+		size = 1
+	}
+	return &SLO{opts: opts, size: size, routes: make(map[string]*route), now: time.Now}
+}
+
+// Serve implemented gear.Handler interface.
+func (s *SLO) Serve(ctx *gear.Context) error {
+	if ctx.Path == s.opts.Path && ctx.Method == http.MethodGet {
+		return ctx.JSON(http.StatusOK, s.Reports())
+	}
+
+	ctx.OnEnd(func() {
+		if pattern := gear.GetRouterPatternFromCtx(ctx); pattern != "" {
+			s.Record(ctx.Method, pattern, ctx.Res.Status(), time.Since(ctx.StartAt))
+		}
+	})
+	return nil
+}
+
+// Record records a request's status and latency to the route,
+// and calls OnExhausted or OnRecovered if the error budget state of the route changed.
+func (s *SLO) Record(method, pattern string, status int, d time.Duration) {
+	s.mu.Lock()
+	key := method + " " + pattern
+	r := s.routes[key]
+	if r == nil {
+		r = &route{method: method, pattern: pattern, buckets: make([]bucket, s.size)}
+		s.routes[key] = r
+	}
+
+	idx := s.now().UnixNano() / int64(s.opts.Resolution)
+	b := &r.buckets[idx%s.size]
+	if b.idx != idx {
+		*b = bucket{idx: idx}
+	}
+	b.total++
+	if status >= 500 {
+		b.errors++
+	}
+	if s.opts.LatencyThreshold > 0 && d > s.opts.LatencyThreshold {
+		b.slow++
+	}
+
+	report := s.report(r, idx)
+	changed := report.Exhausted != r.exhausted
+	r.exhausted = report.Exhausted
+	s.mu.Unlock()
+
+	switch {
+	case !changed:
+	case report.Exhausted && s.opts.OnExhausted != nil:
+		s.opts.OnExhausted(report)
+	case !report.Exhausted && s.opts.OnRecovered != nil:
+		s.opts.OnRecovered(report)
+	}
+}
+
+// Reports returns the SLO reports of all recorded routes, the exhausted and the highest burn rate first.
+func (s *SLO) Reports() []*RouteReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.now().UnixNano() / int64(s.opts.Resolution)
+	res := make([]*RouteReport, 0, len(s.routes))
+	for _, r := range s.routes {
+		res = append(res, s.report(r, idx))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Exhausted != res[j].Exhausted {
+			return res[i].Exhausted
+		}
+		bi, bj := res[i].Windows[0].BurnRate, res[j].Windows[0].BurnRate
+		if bi != bj {
+			return bi > bj
+		}
+		return res[i].Method+" "+res[i].Pattern < res[j].Method+" "+res[j].Pattern
+	})
+	return res
+}
+
+// Reset removes all recorded data.
+func (s *SLO) Reset() {
+	s.mu.Lock()
+	s.routes = make(map[string]*route)
+	s.mu.Unlock()
+}
+
+func (s *SLO) report(r *route, idx int64) *RouteReport {
+	rr := &RouteReport{Method: r.method, Pattern: r.pattern, Windows: make([]*WindowReport, len(s.opts.Windows))}
+	availability, latency := true, s.opts.LatencyThreshold > 0
+	for i, w := range s.opts.Windows {
+		var total, errors, slow int64
+		n := int64(w / s.opts.Resolution)
+		if n < 1 {
+			n = 1
+		}
+		for j := idx - n + 1; j <= idx; j++ {
+			if b := r.buckets[j%s.size]; b.idx == j {
+				total += b.total
+				errors += b.errors
+				slow += b.slow
+			}
+		}
+
+		wr := &WindowReport{Window: w.String(), Total: total, SuccessRatio: 1}
+		if total > 0 {
+			wr.SuccessRatio = 1 - float64(errors)/float64(total)
+			wr.BurnRate = (1 - wr.SuccessRatio) / (1 - s.opts.Objective)
+		}
+		if s.opts.LatencyThreshold > 0 {
+			wr.LatencyRatio = 1
+			if total > 0 {
+				wr.LatencyRatio = 1 - float64(slow)/float64(total)
+				wr.LatencyBurnRate = (1 - wr.LatencyRatio) / (1 - s.opts.LatencyObjective)
+			}
+		}
+		availability = availability && wr.BurnRate >= s.opts.BurnRateThreshold
+		latency = latency && wr.LatencyBurnRate >= s.opts.BurnRateThreshold
+		rr.Windows[i] = wr
+	}
+	rr.Exhausted = availability || latency
+	return rr
+}
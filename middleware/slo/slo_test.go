@@ -0,0 +1,119 @@
+package slo
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func TestGearMiddlewareSLO(t *testing.T) {
+	t.Run("should track routes and serve reports", func(t *testing.T) {
+		assert := assert.New(t)
+
+		s := New(Options{LatencyThreshold: 20 * time.Millisecond})
+		app := gear.New()
+		app.UseHandler(s)
+		router := gear.NewRouter()
+		router.Get("/users/:id", func(ctx *gear.Context) error {
+			return ctx.HTML(http.StatusOK, "user "+ctx.Param("id"))
+		})
+		router.Get("/slow", func(ctx *gear.Context) error {
+			time.Sleep(30 * time.Millisecond)
+			return gear.ErrBadGateway
+		})
+		app.UseHandler(router)
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		for _, path := range []string{"/users/1", "/users/2", "/slow", "/none"} {
+			res, err := http.Get(host + path)
+			assert.Nil(err)
+			res.Body.Close()
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		res, err := http.Get(host + "/debug/gear/slo")
+		assert.Nil(err)
+		var reports []*RouteReport
+		assert.Nil(json.NewDecoder(res.Body).Decode(&reports))
+		res.Body.Close()
+
+		assert.Equal(2, len(reports))
+		assert.Equal("/slow", reports[0].Pattern)
+		assert.True(reports[0].Exhausted)
+		assert.Equal(2, len(reports[0].Windows))
+		assert.Equal("5m0s", reports[0].Windows[0].Window)
+		assert.Equal("1h0m0s", reports[0].Windows[1].Window)
+		assert.Equal(int64(1), reports[0].Windows[0].Total)
+		assert.Equal(float64(0), reports[0].Windows[0].SuccessRatio)
+		assert.Equal(float64(0), reports[0].Windows[0].LatencyRatio)
+		assert.InDelta(1000, reports[0].Windows[0].BurnRate, 0.001)
+		assert.InDelta(100, reports[0].Windows[0].LatencyBurnRate, 0.001)
+
+		assert.Equal("GET", reports[1].Method)
+		assert.Equal("/users/:id", reports[1].Pattern)
+		assert.False(reports[1].Exhausted)
+		assert.Equal(int64(2), reports[1].Windows[1].Total)
+		assert.Equal(float64(1), reports[1].Windows[1].SuccessRatio)
+		assert.Equal(float64(0), reports[1].Windows[1].BurnRate)
+
+		s.Reset()
+		assert.Equal(0, len(s.Reports()))
+	})
+
+	t.Run("should slide windows and trigger callbacks", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var exhausted, recovered []string
+		now := time.Unix(1600000000, 0)
+		s := New(Options{
+			Objective:         0.9,
+			Windows:           []time.Duration{time.Hour, time.Minute},
+			Resolution:        time.Second,
+			BurnRateThreshold: 2,
+			OnExhausted: func(r *RouteReport) {
+				exhausted = append(exhausted, r.Method+" "+r.Pattern)
+			},
+			OnRecovered: func(r *RouteReport) {
+				recovered = append(recovered, r.Method+" "+r.Pattern)
+			},
+		})
+		s.now = func() time.Time { return now }
+
+		for i := 0; i < 8; i++ {
+			s.Record("GET", "/a", 200, time.Millisecond)
+		}
+		s.Record("GET", "/a", 500, time.Millisecond)
+		assert.Equal(0, len(exhausted))
+		s.Record("GET", "/a", 503, time.Millisecond)
+		assert.Equal([]string{"GET /a"}, exhausted)
+		s.Record("GET", "/a", 500, time.Millisecond)
+		assert.Equal(1, len(exhausted))
+
+		r := s.Reports()[0]
+		assert.Equal("1m0s", r.Windows[0].Window)
+		assert.InDelta(8.0/11, r.Windows[0].SuccessRatio, 0.001)
+		assert.InDelta(30.0/11, r.Windows[0].BurnRate, 0.001)
+		assert.Equal(float64(0), r.Windows[0].LatencyBurnRate)
+
+		// the short window is recovered, the long window is still burning.
+		now = now.Add(2 * time.Minute)
+		s.Record("GET", "/a", 200, time.Millisecond)
+		assert.Equal([]string{"GET /a"}, recovered)
+		r = s.Reports()[0]
+		assert.False(r.Exhausted)
+		assert.Equal(int64(1), r.Windows[0].Total)
+		assert.Equal(int64(12), r.Windows[1].Total)
+
+		// the ring is reused after the longest window.
+		now = now.Add(time.Hour)
+		r = s.Reports()[0]
+		assert.Equal(int64(0), r.Windows[1].Total)
+		assert.Equal(float64(1), r.Windows[1].SuccessRatio)
+	})
+}
@@ -0,0 +1,215 @@
+package static
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teambition/gear"
+)
+
+// precompressed sibling files, in the order of preference.
+var precompressed = []struct {
+	encoding, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// NewFromFS creates a static middleware to serves static content from the fs.FS, such as the embed.FS
+// by go:embed or os.DirFS. Options.Root is ignored. The files are served with strong ETag by content
+// (the embed.FS has no modtime), and the precompressed sibling files ("app.js.br" or "app.js.gz" for
+// "app.js") are served with Content-Encoding if the client accepts.
+//
+//	package main
+//
+//	import (
+//		"embed"
+//		"io/fs"
+//
+//		"github.com/teambition/gear"
+//		"github.com/teambition/gear/middleware/static"
+//	)
+//
+//	//go:embed dist
+//	var dist embed.FS
+//
+//	func main() {
+//		assets, _ := fs.Sub(dist, "dist")
+//		app := gear.New()
+//		app.Use(static.NewFromFS(assets, static.Options{
+//			Fallback: "index.html",
+//			Exclude:  []string{"/api/*"},
+//		}))
+//		app.Error(app.Listen(":3000"))
+//	}
+func NewFromFS(fsys fs.FS, opts Options) gear.Middleware {
+	if fsys == nil {
+		panic(gear.Err.WithMsg("invalid fs: nil"))
+	}
+	s := &fsServer{fsys: fsys, opts: opts, browser: http.FileServer(http.FS(fsys))}
+	if opts.Fallback != "" {
+		s.fallback = strings.TrimPrefix(path.Clean("/"+opts.Fallback), "/")
+		if info, err := fs.Stat(fsys, s.fallback); err != nil || info.IsDir() {
+			panic(gear.Err.WithMsgf("invalid fallback file: %s", opts.Fallback))
+		}
+	}
+	return newMiddleware(opts, s.serve)
+}
+
+type fsServer struct {
+	fsys     fs.FS
+	opts     Options
+	fallback string
+	browser  http.Handler
+	etags    sync.Map // name -> *fileETag
+}
+
+type fileETag struct {
+	modTime time.Time
+	size    int64
+	etag    string
+}
+
+func (s *fsServer) serve(ctx *gear.Context, urlPath string) error {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == "" {
+		name = "."
+	}
+
+	info, err := fs.Stat(s.fsys, name)
+	switch {
+	case err == nil && info.IsDir():
+		index := path.Join(name, "index.html")
+		if fi, err := fs.Stat(s.fsys, index); err == nil && !fi.IsDir() {
+			if redirectDir(ctx) {
+				return nil
+			}
+			return s.serveFile(ctx, index)
+		}
+		if s.opts.Browse {
+			if redirectDir(ctx) {
+				return nil
+			}
+			req := ctx.Req.Clone(ctx)
+			req.URL.Path = strings.TrimSuffix("/"+strings.TrimPrefix(name, "."), "/") + "/"
+			s.browser.ServeHTTP(ctx.Res, req)
+			return nil
+		}
+		if s.fallback != "" && acceptsHTML(ctx) {
+			return s.serveFallback(ctx)
+		}
+	case errors.Is(err, fs.ErrNotExist) && s.fallback != "" && acceptsHTML(ctx):
+		return s.serveFallback(ctx)
+	case err == nil:
+		return s.serveFile(ctx, name)
+	}
+	return notFound(ctx, s.opts)
+}
+
+func (s *fsServer) serveFallback(ctx *gear.Context) error {
+	ctx.SetHeader(gear.HeaderCacheControl, "no-cache")
+	return s.serveFile(ctx, s.fallback)
+}
+
+func (s *fsServer) serveFile(ctx *gear.Context, name string) error {
+	file, encoding := name, ""
+	var encodings []string
+	for _, p := range precompressed {
+		if fi, err := fs.Stat(s.fsys, name+p.ext); err == nil && !fi.IsDir() {
+			encodings = append(encodings, p.encoding)
+		}
+	}
+	if len(encodings) > 0 {
+		ctx.Res.Vary(gear.HeaderAcceptEncoding)
+		if encoding = ctx.AcceptEncoding(encodings...); encoding != "" {
+			for _, p := range precompressed {
+				if p.encoding == encoding {
+					file = name + p.ext
+				}
+			}
+		}
+	}
+
+	f, err := s.fsys.Open(file)
+	if err != nil {
+		return gear.ErrNotFound.From(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return gear.ErrNotFound.From(err)
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		buf, err := io.ReadAll(f)
+		if err != nil {
+			return gear.ErrInternalServerError.From(err)
+		}
+		content = bytes.NewReader(buf)
+	}
+	etag, err := s.etag(file, info, content)
+	if err != nil {
+		return gear.ErrInternalServerError.From(err)
+	}
+
+	ctype := mime.TypeByExtension(path.Ext(name))
+	if ctype == "" && encoding != "" {
+		ctype = gear.MIMEOctetStream // don't sniff the encoded content
+	}
+	if ctype != "" {
+		ctx.SetHeader(gear.HeaderContentType, ctype)
+	}
+	if encoding != "" {
+		ctx.SetHeader(gear.HeaderContentEncoding, encoding)
+	}
+	ctx.SetHeader(gear.HeaderETag, etag)
+	http.ServeContent(ctx.Res, ctx.Req, name, info.ModTime(), content)
+	return nil
+}
+
+// etag returns the strong ETag of the file content in the same format as gear.ETagOf,
+// it is cached until the modtime or size of the file changed.
+func (s *fsServer) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if v, ok := s.etags.Load(name); ok {
+		if e := v.(*fileETag); e.modTime.Equal(info.ModTime()) && e.size == info.Size() {
+			return e.etag, nil
+		}
+	}
+
+	h := sha1.New()
+	size, err := io.Copy(h, content)
+	if err != nil {
+		return "", err
+	}
+	if _, err = content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + strconv.FormatInt(size, 16) + "-" + base64.RawURLEncoding.EncodeToString(h.Sum(nil)) + `"`
+	s.etags.Store(name, &fileETag{modTime: info.ModTime(), size: info.Size(), etag: etag})
+	return etag, nil
+}
+
+// redirectDir redirects the directory request without trailing slash, as http.ServeFile does,
+// so the relative links in the index.html or listing work.
+func redirectDir(ctx *gear.Context) bool {
+	if strings.HasSuffix(ctx.Req.URL.Path, "/") {
+		return false
+	}
+	url := path.Base(ctx.Req.URL.Path) + "/"
+	if q := ctx.Req.URL.RawQuery; q != "" {
+		url += "?" + q
+	}
+	http.Redirect(ctx.Res, ctx.Req, url, http.StatusMovedPermanently)
+	return true
+}
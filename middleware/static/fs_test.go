@@ -0,0 +1,156 @@
+package static
+
+import (
+	"io/ioutil"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func TestGearMiddlewareStaticFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":      {Data: []byte("<h1>index</h1>")},
+		"app.js":          {Data: []byte("console.log('app')")},
+		"app.js.gz":       {Data: []byte("gzip app.js")},
+		"app.js.br":       {Data: []byte("br app.js")},
+		"lib.js":          {Data: []byte("console.log('lib')")},
+		"lib.js.gz":       {Data: []byte("gzip lib.js")},
+		"docs/index.html": {Data: []byte("<h1>docs</h1>")},
+		"assets/a.css":    {Data: []byte("body {}")},
+	}
+
+	assert.Panics(t, func() {
+		NewFromFS(nil, Options{})
+	})
+	assert.Panics(t, func() {
+		NewFromFS(fsys, Options{Fallback: "none.html"})
+	})
+	assert.Panics(t, func() {
+		NewFromFS(fsys, Options{Fallback: "docs"})
+	})
+
+	newHost := func(opts Options) string {
+		app := gear.New()
+		app.Set(gear.SetCompress, &gear.DefaultCompress{})
+		app.Use(NewFromFS(fsys, opts))
+		app.Use(func(ctx *gear.Context) error {
+			return ctx.HTML(200, "next "+ctx.Path)
+		})
+		return "http://" + app.Start().Addr().String()
+	}
+	get := func(url string, header ...string) (*GearResponse, string) {
+		req, _ := NewRequst("GET", url)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		res, err := DefaultClientDo(req)
+		assert.Nil(t, err)
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		return res, string(body)
+	}
+
+	t.Run("should serve files with ETag", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newHost(Options{})
+		res, body := get(host+"/assets/a.css", gear.HeaderAcceptEncoding, "identity")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("text/css; charset=utf-8", res.Header.Get(gear.HeaderContentType))
+		assert.Equal("", res.Header.Get(gear.HeaderContentEncoding))
+		assert.Equal(gear.ETagOf([]byte("body {}"), false), res.Header.Get(gear.HeaderETag))
+		assert.Equal("body {}", body)
+
+		res, body = get(host+"/assets/a.css", gear.HeaderIfNoneMatch, res.Header.Get(gear.HeaderETag))
+		assert.Equal(304, res.StatusCode)
+		assert.Equal("", body)
+
+		res, body = get(host + "/none.js")
+		assert.Equal(404, res.StatusCode)
+	})
+
+	t.Run("should serve precompressed files", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newHost(Options{})
+		res, body := get(host+"/app.js", gear.HeaderAcceptEncoding, "gzip, deflate, br")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("br", res.Header.Get(gear.HeaderContentEncoding))
+		assert.Equal(gear.HeaderAcceptEncoding, res.Header.Get(gear.HeaderVary))
+		assert.Contains(res.Header.Get(gear.HeaderContentType), "javascript")
+		assert.Equal(gear.ETagOf([]byte("br app.js"), false), res.Header.Get(gear.HeaderETag))
+		assert.Equal("br app.js", body)
+
+		res, body = get(host+"/app.js", gear.HeaderAcceptEncoding, "gzip")
+		assert.Equal("gzip", res.Header.Get(gear.HeaderContentEncoding))
+		assert.Equal("gzip app.js", body)
+
+		res, body = get(host+"/lib.js", gear.HeaderAcceptEncoding, "br;q=1.0, gzip;q=0.5")
+		assert.Equal("gzip", res.Header.Get(gear.HeaderContentEncoding))
+		assert.Equal("gzip lib.js", body)
+
+		res, body = get(host+"/app.js", gear.HeaderAcceptEncoding, "identity")
+		assert.Equal("", res.Header.Get(gear.HeaderContentEncoding))
+		assert.Equal(gear.HeaderAcceptEncoding, res.Header.Get(gear.HeaderVary))
+		assert.Equal("console.log('app')", body)
+	})
+
+	t.Run("should serve index.html of directories", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newHost(Options{})
+		res, body := get(host+"/", gear.HeaderAcceptEncoding, "identity")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("text/html; charset=utf-8", res.Header.Get(gear.HeaderContentType))
+		assert.Equal("<h1>index</h1>", body)
+
+		res, body = get(host+"/docs/", gear.HeaderAcceptEncoding, "identity")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("<h1>docs</h1>", body)
+
+		res, _ = get(host+"/assets/", gear.HeaderAcceptEncoding, "identity")
+		assert.Equal(404, res.StatusCode)
+
+		host = newHost(Options{Fallthrough: true})
+		res, body = get(host+"/assets/", gear.HeaderAcceptEncoding, "identity")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("next /assets/", body)
+	})
+
+	t.Run("should redirect directories without trailing slash", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newHost(Options{})
+		res, body := get(host+"/docs", gear.HeaderAcceptEncoding, "identity")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("/docs/", res.Request.URL.Path)
+		assert.Equal("<h1>docs</h1>", body)
+	})
+
+	t.Run("should list directories with Browse", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newHost(Options{Browse: true})
+		res, body := get(host+"/assets/", gear.HeaderAcceptEncoding, "identity")
+		assert.Equal(200, res.StatusCode)
+		assert.Contains(body, `<a href="a.css">a.css</a>`)
+	})
+
+	t.Run("should serve fallback and fall through excluded paths", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newHost(Options{Fallback: "/index.html", Exclude: []string{"/api/*"}})
+		res, body := get(host+"/users/123", gear.HeaderAccept, "text/html", gear.HeaderAcceptEncoding, "identity")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("no-cache", res.Header.Get(gear.HeaderCacheControl))
+		assert.Equal("<h1>index</h1>", body)
+
+		res, _ = get(host+"/users/123", gear.HeaderAccept, "*/*")
+		assert.Equal(404, res.StatusCode)
+
+		res, body = get(host+"/api/users", gear.HeaderAccept, "text/html", gear.HeaderAcceptEncoding, "identity")
+		assert.Equal("next /api/users", body)
+	})
+}
@@ -0,0 +1,125 @@
+package static
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/teambition/gear"
+)
+
+// Manifest is a asset manifest that maps asset names to content-hashed URL paths,
+// such as "js/app.js" to "/assets/js/app.9f8e1a2b.js".
+// Use it with Options.Manifest to serve the hashed paths with immutable cache headers,
+// and use Manifest.URL in templates to rewrite asset URLs.
+type Manifest struct {
+	root   string
+	assets map[string]string // name -> hashed URL path
+	files  map[string]string // hashed URL path -> name
+}
+
+// NewManifest walks the root directory, hashes every file (hidden files excluded) by content,
+// and returns the asset manifest with the URL prefix.
+//
+//	manifest, err := static.NewManifest("./assets", "/assets")
+//	if err != nil {
+//		panic(err)
+//	}
+//	app.Use(static.New(static.Options{Root: "./assets", Prefix: "/assets", StripPrefix: true, Manifest: manifest}))
+//	app.Set(gear.SetRenderer, NewRenderer(template.FuncMap{"asset": manifest.URL}))
+func NewManifest(root, prefix string) (*Manifest, error) {
+	root, err := filepath.Abs(filepath.FromSlash(root))
+	if err != nil {
+		return nil, err
+	}
+	if prefix == "" {
+		prefix = "/"
+	}
+
+	m := &Manifest{root: root, assets: make(map[string]string), files: make(map[string]string)}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		name, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		hash, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+		ext := path.Ext(name)
+		hashed := path.Join(prefix, strings.TrimSuffix(name, ext)+"."+hash+ext)
+		m.assets[name] = hashed
+		m.files[hashed] = name
+		return nil
+	})
+	if err != nil {
+		return nil, gear.Err.WithMsgf("create manifest failed: %v", err)
+	}
+	return m, nil
+}
+
+// URL returns the content-hashed URL path of the asset name, such as "js/app.js".
+// The name will be returned if not found in manifest.
+func (m *Manifest) URL(name string) string {
+	if hashed, ok := m.assets[strings.TrimPrefix(name, "/")]; ok {
+		return hashed
+	}
+	return name
+}
+
+// Assets returns a copy of the asset name to hashed URL path map.
+func (m *Manifest) Assets() map[string]string {
+	assets := make(map[string]string, len(m.assets))
+	for name, hashed := range m.assets {
+		assets[name] = hashed
+	}
+	return assets
+}
+
+// MarshalJSON implemented json.Marshaler interface, so the manifest can be written to a file for other tools.
+func (m *Manifest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.assets)
+}
+
+// file returns the file path of the hashed URL path.
+func (m *Manifest) file(urlPath string) (string, bool) {
+	name, ok := m.files[urlPath]
+	if !ok {
+		return "", false
+	}
+	return filepath.Join(m.root, filepath.FromSlash(name)), true
+}
+
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:8], nil
+}
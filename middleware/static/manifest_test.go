@@ -0,0 +1,80 @@
+package static
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teambition/gear"
+)
+
+func TestGearMiddlewareStaticManifest(t *testing.T) {
+	root := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "js"), 0755))
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, ".git"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "js", "app.js"), []byte("console.log('gear')"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "LICENSE"), []byte("MIT"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, ".env"), []byte("KEY=1"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, ".git", "HEAD"), []byte("main"), 0644))
+
+	hash := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])[:8]
+	}
+	appJS := "/assets/js/app." + hash("console.log('gear')") + ".js"
+	license := "/assets/LICENSE." + hash("MIT")
+
+	t.Run("NewManifest", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := NewManifest(filepath.Join(root, "none"), "/assets")
+		assert.NotNil(err)
+
+		manifest, err := NewManifest(root, "/assets")
+		assert.Nil(err)
+		assert.Equal(map[string]string{"js/app.js": appJS, "LICENSE": license}, manifest.Assets())
+		assert.Equal(appJS, manifest.URL("js/app.js"))
+		assert.Equal(appJS, manifest.URL("/js/app.js"))
+		assert.Equal("js/none.js", manifest.URL("js/none.js"))
+
+		buf, err := json.Marshal(manifest)
+		assert.Nil(err)
+		assert.Equal(`{"LICENSE":"`+license+`","js/app.js":"`+appJS+`"}`, string(buf))
+	})
+
+	t.Run("should serve hashed paths", func(t *testing.T) {
+		assert := assert.New(t)
+
+		manifest, err := NewManifest(root, "/assets")
+		assert.Nil(err)
+		app := gear.New()
+		app.Use(New(Options{Root: root, Prefix: "/assets", StripPrefix: true, Manifest: manifest}))
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("GET", host+appJS)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("public, max-age=31536000, immutable", res.Header.Get(gear.HeaderCacheControl))
+		buf, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		assert.Equal("console.log('gear')", string(buf))
+
+		res, err = RequestBy("GET", host+"/assets/js/app.js")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("", res.Header.Get(gear.HeaderCacheControl))
+		res.Body.Close()
+
+		res, err = RequestBy("POST", host+license)
+		assert.Nil(err)
+		assert.Equal(405, res.StatusCode)
+		res.Body.Close()
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -19,9 +20,16 @@ type Options struct {
 	Includes    []string          // Optional, a slice of file path to serve, it will ignore Prefix and StripPrefix options.
 	Files       map[string][]byte // Optional, a map of File objects to serve.
 	OnlyFiles   bool              // Optional, if Options.Files provided and Options.OnlyFiles is true, it will not seek files in other way.
+	Manifest    *Manifest         // Optional, serves the content-hashed paths in manifest with immutable cache headers.
+	Browse      bool              // Optional, renders the listings of the directories without "index.html", default to `false`, respond 404.
+	Fallback    string            // Optional, a file in Root (such as "index.html") to serve for the unknown paths that accept HTML, to support client-side routing of SPAs.
+	Exclude     []string          // Optional, the URL path patterns (by path.Match, such as "/api/*") not to serve, a pattern without "/" matches the base name (such as "*.map").
+	Fallthrough bool              // Optional, passes the requests that can't be served (not found or method not allowed) to the following middlewares instead of responding 404 or 405, default to `false`.
 }
 
 // New creates a static middleware to serves static content from the provided root directory.
+// Set Options.Fallback to "index.html" to serve a SPA with client-side routing, and Options.Exclude
+// to leave the API paths to the following middlewares.
 //
 //	package main
 //
@@ -46,7 +54,6 @@ type Options struct {
 //		app.Error(app.Listen(":3000"))
 //	}
 func New(opts Options) gear.Middleware {
-	modTime := time.Now()
 	if opts.Root == "" {
 		opts.Root = "."
 	}
@@ -63,15 +70,58 @@ func New(opts Options) gear.Middleware {
 		panic(gear.Err.WithMsgf("invalid root path: %s", root))
 	}
 
+	fallback := ""
+	if opts.Fallback != "" {
+		fallback = filepath.Join(root, filepath.FromSlash(opts.Fallback))
+		if info, _ := os.Stat(fallback); info == nil || info.IsDir() {
+			panic(gear.Err.WithMsgf("invalid fallback file: %s", fallback))
+		}
+	}
+
+	return newMiddleware(opts, func(ctx *gear.Context, path string) error {
+		file := filepath.Join(root, filepath.FromSlash(path))
+		info, err := os.Stat(file)
+		switch {
+		case err == nil && info.IsDir():
+			if _, err := os.Stat(filepath.Join(file, "index.html")); err == nil || opts.Browse {
+				break // served by http.ServeFile with "index.html" or listing
+			}
+			if fallback != "" && acceptsHTML(ctx) {
+				return serveFallback(ctx, fallback)
+			}
+			return notFound(ctx, opts)
+		case os.IsNotExist(err):
+			if fallback != "" && acceptsHTML(ctx) {
+				return serveFallback(ctx, fallback)
+			}
+			if opts.Fallthrough {
+				return nil
+			}
+		}
+		http.ServeFile(ctx.Res, ctx.Req, file)
+		return nil
+	})
+}
+
+// newMiddleware creates the static middleware that serves the matched path by serve.
+func newMiddleware(opts Options, serve func(ctx *gear.Context, path string) error) gear.Middleware {
+	modTime := time.Now()
 	if opts.Prefix == "" {
 		opts.Prefix = "/"
 	}
 
 	return func(ctx *gear.Context) (err error) {
 		path := ctx.Path
+		if excludes(opts.Exclude, path) {
+			return nil
+		}
+		hashedFile, hashed := "", false
+		if opts.Manifest != nil {
+			hashedFile, hashed = opts.Manifest.file(path)
+		}
 
 		switch {
-		case includes(opts.Includes, path): // do nothing
+		case hashed, includes(opts.Includes, path): // do nothing
 		case strings.HasPrefix(path, opts.Prefix):
 			if opts.StripPrefix {
 				path = strings.TrimPrefix(path, opts.Prefix)
@@ -81,6 +131,9 @@ func New(opts Options) gear.Middleware {
 		}
 
 		if ctx.Method != http.MethodGet && ctx.Method != http.MethodHead {
+			if opts.Fallthrough {
+				return nil
+			}
 			status := 200
 			if ctx.Method != http.MethodOptions {
 				status = 405
@@ -90,19 +143,67 @@ func New(opts Options) gear.Middleware {
 			return ctx.End(status)
 		}
 
+		if hashed {
+			ctx.SetHeader(gear.HeaderCacheControl, "public, max-age=31536000, immutable")
+			http.ServeFile(ctx.Res, ctx.Req, hashedFile)
+			return nil
+		}
+
 		if opts.Files != nil {
 			if file, ok := opts.Files[path]; ok {
 				http.ServeContent(ctx.Res, ctx.Req, path, modTime, bytes.NewReader(file))
 				return nil
 			}
 			if opts.OnlyFiles {
+				if opts.Fallthrough {
+					return nil
+				}
 				return gear.ErrNotFound.WithMsgf("%s could not be found", path)
 			}
 		}
-		path = filepath.Join(root, filepath.FromSlash(path))
-		http.ServeFile(ctx.Res, ctx.Req, path)
-		return nil
+		return serve(ctx, path)
+	}
+}
+
+// serveFallback serves the fallback file without caching, the client-side router will handle the URL path.
+func serveFallback(ctx *gear.Context, fallback string) error {
+	f, err := os.Open(fallback)
+	if err != nil {
+		return gear.ErrNotFound.From(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return gear.ErrNotFound.From(err)
+	}
+	ctx.SetHeader(gear.HeaderCacheControl, "no-cache")
+	http.ServeContent(ctx.Res, ctx.Req, info.Name(), info.ModTime(), f)
+	return nil
+}
+
+// notFound responds 404, or passes the request to the following middlewares with Options.Fallthrough.
+func notFound(ctx *gear.Context, opts Options) error {
+	if !opts.Fallthrough {
+		http.NotFound(ctx.Res, ctx.Req)
+	}
+	return nil
+}
+
+func acceptsHTML(ctx *gear.Context) bool {
+	return strings.Contains(ctx.GetHeader(gear.HeaderAccept), gear.MIMETextHTML)
+}
+
+func excludes(patterns []string, urlPath string) bool {
+	for _, pattern := range patterns {
+		name := urlPath
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(urlPath)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
 	}
+	return false
 }
 
 func includes(arr []string, str string) bool {
@@ -257,3 +257,105 @@ func TestGearMiddlewareStaticWithIncludes(t *testing.T) {
 		res.Body.Close()
 	})
 }
+
+func TestGearMiddlewareStaticWithBrowseAndFallback(t *testing.T) {
+	assert.Panics(t, func() {
+		New(Options{Root: "../../testdata", Fallback: "none.html"})
+	})
+
+	newHost := func(opts Options) string {
+		app := gear.New()
+		app.Use(New(opts))
+		app.Use(func(ctx *gear.Context) error {
+			return ctx.HTML(200, "next "+ctx.Path)
+		})
+		return "http://" + app.Start().Addr().String()
+	}
+	get := func(url, accept string) (*GearResponse, string) {
+		req, _ := NewRequst("GET", url)
+		if accept != "" {
+			req.Header.Set(gear.HeaderAccept, accept)
+		}
+		res, err := DefaultClientDo(req)
+		assert.Nil(t, err)
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		return res, string(body)
+	}
+
+	t.Run("should not list directories by default", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newHost(Options{Root: "../../testdata"})
+		res, _ := get(host+"/out/", "")
+		assert.Equal(404, res.StatusCode)
+	})
+
+	t.Run("should list directories with Browse", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newHost(Options{Root: "../../testdata", Browse: true})
+		res, body := get(host+"/out/", "")
+		assert.Equal(200, res.StatusCode)
+		assert.Contains(body, `<a href="test.crt">test.crt</a>`)
+	})
+
+	t.Run("should serve fallback for unknown paths that accept HTML", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newHost(Options{Root: "../../testdata", Fallback: "hello.html"})
+		hello, _ := ioutil.ReadFile("../../testdata/hello.html")
+
+		for _, path := range []string{"/users/123", "/out/"} {
+			res, body := get(host+path, "text/html,application/xhtml+xml,*/*;q=0.8")
+			assert.Equal(200, res.StatusCode)
+			assert.Equal("text/html; charset=utf-8", res.Header.Get(gear.HeaderContentType))
+			assert.Equal("no-cache", res.Header.Get(gear.HeaderCacheControl))
+			assert.Equal(string(hello), body)
+		}
+
+		res, _ := get(host+"/js/none.js", "*/*")
+		assert.Equal(404, res.StatusCode)
+		res, body := get(host+"/hello.css", "text/html")
+		assert.Equal(200, res.StatusCode)
+		assert.NotEqual(string(hello), body)
+	})
+
+	t.Run("should fall through excluded paths", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newHost(Options{Root: "../../testdata", Fallback: "hello.html", Exclude: []string{"/api/*", "*.css"}})
+		res, body := get(host+"/api/users", "text/html")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("next /api/users", body)
+		res, body = get(host+"/hello.css", "")
+		assert.Equal("next /hello.css", body)
+		res, _ = get(host+"/api/users/123", "text/html") // "*" doesn't match "/"
+		assert.Equal("no-cache", res.Header.Get(gear.HeaderCacheControl))
+	})
+
+	t.Run("should fall through unserved requests with Fallthrough", func(t *testing.T) {
+		assert := assert.New(t)
+
+		host := newHost(Options{Root: "../../testdata", Fallthrough: true})
+		res, body := get(host+"/js/none.js", "")
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("next /js/none.js", body)
+		res, body = get(host+"/out/", "")
+		assert.Equal("next /out/", body)
+		res, body = get(host+"/hello.html", "")
+		assert.NotEqual("next /hello.html", body)
+
+		req, _ := NewRequst("POST", host+"/hello.html")
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		res.Body.Close()
+
+		host = newHost(Options{Root: "../../testdata", Fallthrough: true, Fallback: "hello.html"})
+		res, body = get(host+"/users/123", "*/*")
+		assert.Equal("next /users/123", body)
+		res, body = get(host+"/users/123", "text/html")
+		assert.Equal("no-cache", res.Header.Get(gear.HeaderCacheControl))
+	})
+}
@@ -0,0 +1,265 @@
+package gear
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// MultipartOptions is options for ctx.ParseMultipart.
+type MultipartOptions struct {
+	// MaxMemory is the max bytes of the file parts stored in memory,
+	// the remainder will be stored on disk in temporary files, which are removed after the request.
+	// Optional. Default to 10MB.
+	MaxMemory int64
+	// MaxFileSize is the max bytes of each uploaded file.
+	// Optional. Default to 0, limited by MaxTotalSize only.
+	MaxFileSize int64
+	// MaxTotalSize is the max bytes of the whole request body.
+	// Optional. Default to BodyParser.MaxBytes().
+	MaxTotalSize int64
+}
+
+var (
+	multipartFileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	multipartFileHeaderSliceType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// ParseMultipart parses the multipart/form-data request body, stores the form values (by "form" tag)
+// and the uploaded files (by "file" tag) in the struct object pointed to by BodyTemplate body, and validate it.
+// The file fields should be *multipart.FileHeader or []*multipart.FileHeader type.
+// ctx.ParseBody will call it for multipart/form-data request with default options.
+//
+// Define a BodyTemplate type in some API:
+//
+//	type profileTemplate struct {
+//		Name   string                  `form:"name"`
+//		Avatar *multipart.FileHeader   `file:"avatar"`
+//		Photos []*multipart.FileHeader `file:"photos"`
+//	}
+//
+//	func (b *profileTemplate) Validate() error {
+//		if b.Avatar == nil {
+//			return gear.ErrBadRequest.WithMsg("avatar required")
+//		}
+//		return nil
+//	}
+//
+// Use it in APIhandler:
+//
+//	body := profileTemplate{}
+//	if err := ctx.ParseMultipart(&body, gear.MultipartOptions{MaxFileSize: 2 << 20}); err != nil {
+//		return err
+//	}
+//	file, err := body.Avatar.Open()
+func (ctx *Context) ParseMultipart(body BodyTemplate, options ...MultipartOptions) error {
+	opts := MultipartOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if ctx.app.urlParser == nil {
+		return Err.WithMsg("urlParser not registered")
+	}
+	if err := ctx.parseMultipartForm(&opts); err != nil {
+		return err
+	}
+
+	form := ctx.Req.MultipartForm
+	if err := checkFileSize(form.File, opts.MaxFileSize); err != nil {
+		return err
+	}
+	if err := ctx.app.urlParser.Parse(form.Value, body, "form"); err != nil {
+		return ErrBadRequest.From(err)
+	}
+	if err := filesToStruct(form.File, reflect.ValueOf(body)); err != nil {
+		return err // invalid BodyTemplate
+	}
+	return ctx.validateBody(body, ErrBadRequest)
+}
+
+// FormFile returns the first uploaded file of the multipart/form-data request by the form field name.
+// The request body is parsed like ctx.ParseMultipart with the options if not parsed,
+// it returns 400 error if the file is missing, 413 error if the file is too large.
+//
+//	fh, err := ctx.FormFile("avatar", gear.MultipartOptions{MaxFileSize: 2 << 20})
+//	if err != nil {
+//		return err
+//	}
+//	if err = ctx.SaveUploadedFile(fh, filepath.Join(dir, uuid), 2<<20, "image/png", "image/jpeg"); err != nil {
+//		return err
+//	}
+func (ctx *Context) FormFile(name string, options ...MultipartOptions) (*multipart.FileHeader, error) {
+	opts := MultipartOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if err := ctx.parseMultipartForm(&opts); err != nil {
+		return nil, err
+	}
+	fhs := ctx.Req.MultipartForm.File[name]
+	if len(fhs) == 0 {
+		return nil, ErrBadRequest.WithMsgf("file %q required", name)
+	}
+	if err := checkFileSize(map[string][]*multipart.FileHeader{name: fhs[:1]}, opts.MaxFileSize); err != nil {
+		return nil, err
+	}
+	return fhs[0], nil
+}
+
+// SaveUploadedFile saves the uploaded file to dst, the directories of dst are created if not exist.
+// It returns 413 error if the file is larger than maxBytes (no limit if maxBytes <= 0).
+// If allowedTypes given, the content type sniffed from the file content (see http.DetectContentType, the header
+// of the file part is not trusted) should be one of them, such as "image/png" or "image/*",
+// otherwise it returns 415 error. The file is written to a temporary file in the directory of dst and then
+// renamed to dst, so the partial file will not be left on errors.
+func (ctx *Context) SaveUploadedFile(fh *multipart.FileHeader, dst string, maxBytes int64, allowedTypes ...string) error {
+	if maxBytes > 0 && fh.Size > maxBytes {
+		return ErrRequestEntityTooLarge.WithMsgf("file %q too large", fh.Filename)
+	}
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	head = head[:n]
+	if len(allowedTypes) > 0 {
+		mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+		if !matchMediaType(mediaType, allowedTypes) {
+			return ErrUnsupportedMediaType.WithMsgf("file %q type %s not allowed", fh.Filename, mediaType)
+		}
+	}
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op after renamed
+
+	var r io.Reader = io.MultiReader(bytes.NewReader(head), src)
+	if maxBytes > 0 {
+		r = io.LimitReader(r, maxBytes+1)
+	}
+	written, err := io.Copy(f, r)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	if maxBytes > 0 && written > maxBytes {
+		return ErrRequestEntityTooLarge.WithMsgf("file %q too large", fh.Filename)
+	}
+	return os.Rename(f.Name(), dst)
+}
+
+// matchMediaType reports whether the media type matches one of the patterns, such as "image/png" or "image/*".
+func matchMediaType(mediaType string, patterns []string) bool {
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == mediaType || p == "*/*" || (strings.HasSuffix(p, "/*") && strings.HasPrefix(mediaType, p[:len(p)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkFileSize(files map[string][]*multipart.FileHeader, maxFileSize int64) error {
+	if maxFileSize > 0 {
+		for _, fhs := range files {
+			for _, fh := range fhs {
+				if fh.Size > maxFileSize {
+					return ErrRequestEntityTooLarge.WithMsgf("file %q too large", fh.Filename)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parseMultipartForm parses the multipart/form-data request body once, the default options are set to opts.
+func (ctx *Context) parseMultipartForm(opts *MultipartOptions) error {
+	if opts.MaxMemory <= 0 {
+		opts.MaxMemory = 10 << 20
+	}
+	if opts.MaxTotalSize <= 0 {
+		if ctx.app.bodyParser == nil {
+			return Err.WithMsg("bodyParser not registered")
+		}
+		opts.MaxTotalSize = ctx.app.bodyParser.MaxBytes()
+	}
+	if ctx.Req.Body == nil {
+		return Err.WithMsg("missing request body")
+	}
+
+	contentType := ctx.GetHeader(HeaderContentType)
+	ctx.SetAny("GEAR_REQUEST_CONTENT_TYPE", contentType)
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != MIMEMultipartForm {
+		return ErrUnsupportedMediaType.WithMsgf("unsupported media type: %s", contentType)
+	}
+
+	if ctx.Req.MultipartForm == nil {
+		ctx.Req.Body = http.MaxBytesReader(ctx.Res, ctx.Req.Body, opts.MaxTotalSize)
+		if err := ctx.Req.ParseMultipartForm(opts.MaxMemory); err != nil {
+			var e *http.MaxBytesError
+			if errors.As(err, &e) {
+				return ErrRequestEntityTooLarge.From(err)
+			}
+			return ErrBadRequest.From(err)
+		}
+	}
+	return nil
+}
+
+func filesToStruct(files map[string][]*multipart.FileHeader, rv reflect.Value) error {
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return Err.WithMsgf("invalid struct: %v", rv)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rv.NumField(); i++ {
+		structField := rt.Field(i)
+		value := rv.Field(i)
+		if structField.Anonymous {
+			// embedded field
+			if value.Kind() == reflect.Struct && value.CanAddr() {
+				if err := filesToStruct(files, value.Addr()); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		fk := structField.Tag.Get("file")
+		if fk == "" || !value.CanSet() {
+			continue
+		}
+		fhs := files[fk]
+		switch structField.Type {
+		case multipartFileHeaderType:
+			if len(fhs) > 0 {
+				value.Set(reflect.ValueOf(fhs[0]))
+			}
+		case multipartFileHeaderSliceType:
+			value.Set(reflect.ValueOf(fhs))
+		default:
+			return Err.WithMsgf("invalid file field %q, must be *multipart.FileHeader or []*multipart.FileHeader", structField.Name)
+		}
+	}
+	return nil
+}
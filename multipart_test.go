@@ -0,0 +1,222 @@
+package gear
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type multipartBase struct {
+	Photos []*multipart.FileHeader `file:"photos"`
+}
+
+type multipartTemplate struct {
+	multipartBase
+	Name   string                `form:"name"`
+	Age    int                   `form:"age"`
+	Avatar *multipart.FileHeader `file:"avatar"`
+	Resume *multipart.FileHeader `file:"resume"`
+}
+
+func (b *multipartTemplate) Validate() error {
+	if b.Avatar == nil {
+		return ErrBadRequest.WithMsg("avatar required")
+	}
+	return nil
+}
+
+type invalidMultipartTemplate struct {
+	Avatar string `file:"avatar"`
+}
+
+func (b *invalidMultipartTemplate) Validate() error {
+	return nil
+}
+
+func newMultipartBody(fields map[string]string, files map[string][]string) (*bytes.Buffer, string) {
+	buf := new(bytes.Buffer)
+	w := multipart.NewWriter(buf)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	for k, contents := range files {
+		for i, content := range contents {
+			fw, _ := w.CreateFormFile(k, k+string(rune('0'+i))+".txt")
+			io.WriteString(fw, content)
+		}
+	}
+	w.Close()
+	return buf, w.FormDataContentType()
+}
+
+func TestGearContextParseMultipart(t *testing.T) {
+	t.Run("should parse form values and files", func(t *testing.T) {
+		assert := assert.New(t)
+
+		for _, useParseBody := range []bool{false, true} {
+			app := New()
+			app.Use(func(ctx *Context) error {
+				body := multipartTemplate{}
+				var err error
+				if useParseBody {
+					err = ctx.ParseBody(&body)
+				} else {
+					err = ctx.ParseMultipart(&body, MultipartOptions{MaxMemory: 1})
+				}
+				if err != nil {
+					return err
+				}
+				f, err := body.Avatar.Open()
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				avatar, _ := io.ReadAll(f)
+				return ctx.JSON(200, map[string]any{
+					"name":   body.Name,
+					"age":    body.Age,
+					"avatar": string(avatar),
+					"photos": len(body.Photos),
+					"resume": body.Resume == nil,
+				})
+			})
+			srv := app.Start()
+
+			buf, contentType := newMultipartBody(map[string]string{"name": "gear", "age": "7"},
+				map[string][]string{"avatar": {"avatar data"}, "photos": {"p1", "p2"}})
+			req, _ := http.NewRequest("POST", "http://"+srv.Addr().String(), buf)
+			req.Header.Set(HeaderContentType, contentType)
+			res, err := DefaultClientDo(req)
+			assert.Nil(err)
+			assert.Equal(200, res.StatusCode)
+			assert.Equal(`{"age":7,"avatar":"avatar data","name":"gear","photos":2,"resume":true}`, PickRes(res.Text()).(string))
+			srv.Close()
+		}
+	})
+
+	t.Run("should respond errors", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Use(func(ctx *Context) error {
+			if ctx.Path == "/invalid" {
+				return ctx.ParseMultipart(&invalidMultipartTemplate{})
+			}
+			return ctx.ParseMultipart(&multipartTemplate{}, MultipartOptions{MaxFileSize: 8, MaxTotalSize: 1024})
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		post := func(url string, body io.Reader, contentType string) (*GearResponse, error) {
+			req, _ := http.NewRequest("POST", url, body)
+			req.Header.Set(HeaderContentType, contentType)
+			return DefaultClientDo(req)
+		}
+
+		res, err := post(host, strings.NewReader(`{"name":"gear"}`), MIMEApplicationJSON)
+		assert.Nil(err)
+		assert.Equal(415, res.StatusCode)
+		res.Body.Close()
+
+		buf, contentType := newMultipartBody(map[string]string{"name": "gear"}, nil)
+		res, err = post(host, buf, contentType)
+		assert.Nil(err)
+		assert.Equal(400, res.StatusCode)
+		assert.Equal(`{"error":"BadRequest","message":"avatar required"}`, PickRes(res.Text()).(string))
+
+		buf, contentType = newMultipartBody(nil, map[string][]string{"avatar": {"123456789"}})
+		res, err = post(host, buf, contentType)
+		assert.Nil(err)
+		assert.Equal(413, res.StatusCode)
+		assert.Equal(`{"error":"RequestEntityTooLarge","message":"file \"avatar0.txt\" too large"}`, PickRes(res.Text()).(string))
+
+		buf, contentType = newMultipartBody(map[string]string{"name": strings.Repeat("x", 2048)}, nil)
+		res, err = post(host, buf, contentType)
+		assert.Nil(err)
+		assert.Equal(413, res.StatusCode)
+		res.Body.Close()
+
+		res, err = post(host, strings.NewReader("invalid"), "multipart/form-data; boundary=xxx")
+		assert.Nil(err)
+		assert.Equal(400, res.StatusCode)
+		res.Body.Close()
+
+		buf, contentType = newMultipartBody(nil, map[string][]string{"avatar": {"123"}})
+		res, err = post(host+"/invalid", buf, contentType)
+		assert.Nil(err)
+		assert.Equal(500, res.StatusCode)
+		assert.True(strings.Contains(PickRes(res.Text()).(string), `invalid file field \"Avatar\"`))
+	})
+}
+
+func TestGearContextFormFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("x", 16)
+	app := New()
+	app.Use(func(ctx *Context) error {
+		fh, err := ctx.FormFile("avatar", MultipartOptions{MaxFileSize: 64})
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, ctx.Query("name"))
+		if err = ctx.SaveUploadedFile(fh, dst, 32, "image/*"); err != nil {
+			return err
+		}
+		return ctx.HTML(200, fh.Filename)
+	})
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	post := func(name string, files map[string][]string) (int, string) {
+		buf, contentType := newMultipartBody(nil, files)
+		req, _ := http.NewRequest("POST", host+"?name="+name, buf)
+		req.Header.Set(HeaderContentType, contentType)
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		return res.StatusCode, PickRes(res.Text()).(string)
+	}
+
+	code, text := post("a.png", map[string][]string{"avatar": {png, "second"}})
+	assert.Equal(200, code)
+	assert.Equal("avatar0.txt", text)
+	data, err := os.ReadFile(filepath.Join(dir, "a.png"))
+	assert.Nil(err)
+	assert.Equal(png, string(data))
+
+	code, _ = post("sub/b.png", map[string][]string{"avatar": {png}})
+	assert.Equal(200, code)
+	_, err = os.Stat(filepath.Join(dir, "sub", "b.png"))
+	assert.Nil(err)
+
+	code, text = post("c.png", map[string][]string{"photo": {png}})
+	assert.Equal(400, code)
+	assert.Equal(`{"error":"BadRequest","message":"file \"avatar\" required"}`, text)
+
+	code, _ = post("c.png", map[string][]string{"avatar": {strings.Repeat("x", 65)}})
+	assert.Equal(413, code)
+
+	code, text = post("c.png", map[string][]string{"avatar": {png + strings.Repeat("x", 16)}})
+	assert.Equal(413, code)
+	assert.Equal(`{"error":"RequestEntityTooLarge","message":"file \"avatar0.txt\" too large"}`, text)
+
+	code, text = post("c.txt", map[string][]string{"avatar": {"plain text"}})
+	assert.Equal(415, code)
+	assert.Equal(`{"error":"UnsupportedMediaType","message":"file \"avatar0.txt\" type text/plain not allowed"}`, text)
+
+	entries, err := os.ReadDir(dir)
+	assert.Nil(err)
+	assert.Equal(2, len(entries), "no partial files left")
+	assert.True(matchMediaType("image/png", []string{"text/plain", "IMAGE/PNG"}))
+	assert.False(matchMediaType("image/png", []string{"image/jpeg", "text/*"}))
+}
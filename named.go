@@ -0,0 +1,84 @@
+package gear
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// middlewareNames is the registry of the names of the middlewares returned by Named and OnlyEnv,
+// see MiddlewareName. It is keyed by the closure, the code pointer is shared by all closures of Named.
+// The middlewares are kept by the registry, so the closures are not collected and the keys are not reused.
+var middlewareNames = struct {
+	sync.RWMutex
+	m map[unsafe.Pointer]namedMiddleware
+}{m: make(map[unsafe.Pointer]namedMiddleware)}
+
+type namedMiddleware struct {
+	md   Middleware
+	name string
+}
+
+// closureOf returns the closure of the function value.
+func closureOf(md Middleware) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&md))
+}
+
+// nameMiddleware records the name of the middleware, and returns it.
+func nameMiddleware(name string, md Middleware) Middleware {
+	middlewareNames.Lock()
+	defer middlewareNames.Unlock()
+	middlewareNames.m[closureOf(md)] = namedMiddleware{md, name}
+	return md
+}
+
+// Named returns a middleware wrapping md with a stable name, so that app.Middlewares,
+// the "X-Middleware-Trace" diagnostics header (see DiagnosticHeaders.MiddlewareTrace) and
+// the panic logs can refer to it by name instead of the anonymous function.
+//
+//	app.Use(gear.Named("auth", auth.New()))
+//	app.Middlewares() // []string{"auth"}
+//
+// It panics if name is empty or md is nil. The name is kept for the process, so Named should be called
+// when building the app, not for every request.
+func Named(name string, md Middleware) Middleware {
+	if name == "" || md == nil {
+		panic(Err.WithMsg("invalid named middleware"))
+	}
+	return nameMiddleware(name, func(ctx *Context) error {
+		if ctx.mdTrace != nil {
+			ctx.mdTrace = append(ctx.mdTrace, name)
+		}
+		prev := ctx.mdName
+		ctx.mdName = name
+		// keep the name if md panics, it will be logged by the panic log.
+		err := md(ctx)
+		ctx.mdName = prev
+		return err
+	})
+}
+
+// MiddlewareName returns the name of the middleware, it is the name given by Named,
+// or the function name, such as "github.com/teambition/gear/middleware/cors.New.func1".
+func MiddlewareName(md Middleware) string {
+	if md == nil {
+		return ""
+	}
+	middlewareNames.RLock()
+	named, ok := middlewareNames.m[closureOf(md)]
+	middlewareNames.RUnlock()
+	if ok {
+		return named.name
+	}
+	if fn := runtime.FuncForPC(reflect.ValueOf(md).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return ""
+}
+
+// handlerName returns the name of the Handler for app.Middlewares.
+func handlerName(h Handler) string {
+	return fmt.Sprintf("%T", h)
+}
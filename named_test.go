@@ -0,0 +1,104 @@
+package gear
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearNamed(t *testing.T) {
+	t.Run("should name middlewares", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.Panics(func() { Named("", noOp) })
+		assert.Panics(func() { Named("noop", nil) })
+
+		newMd := func() Middleware {
+			return func(ctx *Context) error { return nil }
+		}
+		md1 := Named("md1", newMd())
+		md2 := Named("md2", newMd())
+		assert.Equal("md1", MiddlewareName(md1))
+		assert.Equal("md2", MiddlewareName(md2))
+		assert.Equal("", MiddlewareName(nil))
+		assert.Equal("github.com/teambition/gear.(*Router).Serve-fm", MiddlewareName(NewRouter().Serve))
+
+		app := New()
+		app.Use(md1)
+		app.Use(newMd())
+		app.UseHandler(NewRouter())
+		app.Use(md2)
+		names := app.Middlewares()
+		assert.Equal(4, len(names))
+		assert.Equal("md1", names[0])
+		assert.True(strings.HasPrefix(names[1], "github.com/teambition/gear.TestGearNamed."))
+		assert.Equal("*gear.Router", names[2])
+		assert.Equal("md2", names[3])
+
+		names[0] = "x"
+		assert.Equal("md1", app.Middlewares()[0])
+		assert.Equal(0, len(app.Clone().Middlewares()))
+	})
+
+	t.Run("should emit middleware trace header", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Set(SetDiagnosticHeaders, DiagnosticHeaders{MiddlewareTrace: true})
+		app.Use(Named("auth", noOp))
+		app.Use(noOp)
+		router := NewRouter()
+		router.Get("/", Named("handler", func(ctx *Context) error {
+			return ctx.HTML(200, "OK")
+		}))
+		router.Get("/err", Named("err", func(ctx *Context) error {
+			return ErrForbidden
+		}))
+		app.UseHandler(router)
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("GET", host)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("auth, handler", res.Header.Get(HeaderXMiddlewareTrace))
+		res.Body.Close()
+
+		res, err = RequestBy("GET", host+"/err")
+		assert.Nil(err)
+		assert.Equal(403, res.StatusCode)
+		assert.Equal("auth, err", res.Header.Get(HeaderXMiddlewareTrace))
+		res.Body.Close()
+
+		res, err = RequestBy("GET", host+"/none")
+		assert.Nil(err)
+		assert.Equal("auth", res.Header.Get(HeaderXMiddlewareTrace))
+		res.Body.Close()
+	})
+
+	t.Run("should log the middleware name of panic", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf bytes.Buffer
+		app := New()
+		app.Set(SetLogger, log.New(&buf, "", 0))
+		app.Use(Named("ok", noOp))
+		app.Use(Named("boom", func(ctx *Context) error {
+			panic("boom!")
+		}))
+		srv := app.Start()
+		defer srv.Close()
+
+		res, err := RequestBy("GET", "http://"+srv.Addr().String())
+		assert.Nil(err)
+		assert.Equal(500, res.StatusCode)
+		res.Body.Close()
+		time.Sleep(10 * time.Millisecond)
+		assert.Contains(buf.String(), `[middleware \"boom\"]`)
+	})
+}
@@ -0,0 +1,461 @@
+package gear
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ParseMergePatch parses a PATCH request body and applies it onto the value pointed to by
+// BodyTemplate target, then validate the patched value. The patch format is chosen by
+// the request Content-Type:
+//
+//	application/merge-patch+json  RFC 7386 JSON Merge Patch
+//	application/json-patch+json   RFC 6902 JSON Patch
+//	application/json              treated as JSON Merge Patch
+//
+// The target is marshaled with encoding/json, patched, and unmarshaled into a shallow copy of the target
+// with the JSON fields reset, the target will be updated only if the patched value is valid. Fields that
+// encoding/json ignores (unexported or `json:"-"`) keep their values.
+//
+//	router.Patch("/users/:id", func(ctx *gear.Context) error {
+//		user := getUser(ctx.Param("id")) // *User, User implemented gear.BodyTemplate
+//		if err := ctx.ParseMergePatch(user); err != nil {
+//			return err
+//		}
+//		return ctx.OkJSON(saveUser(user))
+//	})
+func (ctx *Context) ParseMergePatch(target BodyTemplate) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return Err.WithMsgf("invalid patch target: %v", rv)
+	}
+
+	buf, mediaType, _, err := ctx.readBody()
+	if err != nil {
+		return err
+	}
+	if len(buf) == 0 {
+		return ErrBadRequest.WithMsg("request entity empty")
+	}
+
+	doc, err := json.Marshal(target)
+	if err != nil {
+		return ErrInternalServerError.From(err)
+	}
+
+	switch mediaType {
+	case MIMEApplicationMergePatchJSON, MIMEApplicationJSON:
+		doc, err = ApplyMergePatch(doc, buf)
+	case MIMEApplicationJSONPatchJSON:
+		doc, err = ApplyJSONPatch(doc, buf)
+	default:
+		return ErrUnsupportedMediaType.WithMsgf("unsupported patch media type: %s", mediaType)
+	}
+	if err != nil {
+		return err
+	}
+
+	patched := reflect.New(rv.Elem().Type())
+	patched.Elem().Set(rv.Elem())
+	resetJSONFields(patched.Elem())
+	if err = json.Unmarshal(doc, patched.Interface()); err != nil {
+		return ErrUnprocessableEntity.From(err)
+	}
+	if err = ctx.validateBody(patched.Interface().(BodyTemplate), ErrUnprocessableEntity); err != nil {
+		return err
+	}
+	rv.Elem().Set(patched.Elem())
+	return nil
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// resetJSONFields zeroes the fields of v that encoding/json unmarshals, the nested and embedded structs
+// are reset recursively, so the fields that encoding/json skips keep their values.
+func resetJSONFields(v reflect.Value) {
+	t := v.Type()
+	if t.Kind() != reflect.Struct || reflect.PointerTo(t).Implements(jsonUnmarshalerType) ||
+		reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		v.Set(reflect.Zero(t))
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		fv := v.Field(i)
+		if name == "-" || !fv.CanSet() {
+			continue
+		}
+		// the embedded struct pointer is copied, so the target's struct will not be changed.
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Ptr && sf.Type.Elem().Kind() == reflect.Struct {
+			if !fv.IsNil() {
+				cp := reflect.New(sf.Type.Elem())
+				cp.Elem().Set(fv.Elem())
+				resetJSONFields(cp.Elem())
+				fv.Set(cp)
+			}
+			continue
+		}
+		resetJSONFields(fv)
+	}
+}
+
+// ApplyMergePatch applies a RFC 7386 JSON Merge Patch to the JSON document, returns the patched document.
+//
+//	doc, err := gear.ApplyMergePatch([]byte(`{"a":"b","c":{"d":"e"}}`), []byte(`{"a":"z","c":{"d":null}}`))
+//	// doc: {"a":"z","c":{}}
+func ApplyMergePatch(doc, patch []byte) ([]byte, error) {
+	var d, p any
+	if len(bytes.TrimSpace(doc)) > 0 {
+		if err := unmarshalJSONDoc(doc, &d); err != nil {
+			return nil, ErrBadRequest.WithMsgf("invalid document: %v", err)
+		}
+	}
+	if err := unmarshalJSONDoc(patch, &p); err != nil {
+		return nil, ErrBadRequest.WithMsgf("invalid merge patch: %v", err)
+	}
+	return json.Marshal(mergePatch(d, p))
+}
+
+// ApplyJSONPatch applies a RFC 6902 JSON Patch to the JSON document, returns the patched document.
+// It returns a 422 error if an operation can't be applied, and a 409 error if a "test" operation failed.
+//
+//	doc, err := gear.ApplyJSONPatch([]byte(`{"a":[1,2]}`), []byte(`[{"op":"add","path":"/a/-","value":3}]`))
+//	// doc: {"a":[1,2,3]}
+func ApplyJSONPatch(doc, patch []byte) ([]byte, error) {
+	var d any
+	var ops []patchOperation
+	if err := unmarshalJSONDoc(doc, &d); err != nil {
+		return nil, ErrBadRequest.WithMsgf("invalid document: %v", err)
+	}
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, ErrBadRequest.WithMsgf("invalid json patch: %v", err)
+	}
+
+	var err error
+	for i, op := range ops {
+		if d, err = op.apply(d); err != nil {
+			if err == errPatchTestFailed {
+				return nil, ErrConflict.WithMsgf("operation %d (%s %s): %v", i, op.Op, op.Path, err)
+			}
+			return nil, ErrUnprocessableEntity.WithMsgf("operation %d (%s %s): %v", i, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(d)
+}
+
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any, len(p))
+	}
+	for key, val := range p {
+		if val == nil {
+			delete(t, key)
+		} else {
+			t[key] = mergePatch(t[key], val)
+		}
+	}
+	return t
+}
+
+// unmarshalJSONDoc uses json.Number to keep the precision of numbers.
+func unmarshalJSONDoc(buf []byte, v any) error {
+	d := json.NewDecoder(bytes.NewReader(buf))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+var errPatchTestFailed = errors.New("test operation failed")
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (op patchOperation) value() (v any, err error) {
+	if op.Value == nil {
+		return nil, errors.New(`missing "value"`)
+	}
+	err = unmarshalJSONDoc(op.Value, &v)
+	return
+}
+
+func (op patchOperation) apply(doc any) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		val, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, path, val)
+	case "remove":
+		return patchRemove(doc, path)
+	case "replace":
+		val, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = patchGet(doc, path); err != nil {
+			return nil, err
+		}
+		if doc, err = patchRemove(doc, path); err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, path, val)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		val, err := patchGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			val = copyJSONValue(val)
+		} else {
+			if len(from) < len(path) && isPointerPrefix(from, path) {
+				return nil, errors.New(`"from" must not be a prefix of "path"`)
+			}
+			if doc, err = patchRemove(doc, from); err != nil {
+				return nil, err
+			}
+		}
+		return patchAdd(doc, path, val)
+	case "test":
+		val, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		cur, err := patchGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equalJSONValue(cur, val) {
+			return nil, errPatchTestFailed
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("invalid operation %q", op.Op)
+	}
+}
+
+// parsePointer parses a RFC 6901 JSON Pointer.
+func parsePointer(s string) ([]string, error) {
+	if s == "" {
+		return []string{}, nil
+	}
+	if s[0] != '/' {
+		return nil, fmt.Errorf("invalid json pointer %q", s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func isPointerPrefix(prefix, path []string) bool {
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > length || (!allowEnd && i == length) {
+		return 0, fmt.Errorf("array index %d out of bounds", i)
+	}
+	return i, nil
+}
+
+func patchGet(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch v := doc.(type) {
+		case map[string]any:
+			val, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", token)
+			}
+			doc = val
+		case []any:
+			i, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = v[i]
+		default:
+			return nil, fmt.Errorf("can't traverse %q", token)
+		}
+	}
+	return doc, nil
+}
+
+// patchUpdate walks doc along the path, calls fn with the container and the last token,
+// and rebuilds the document with the returned container.
+func patchUpdate(doc any, path []string, fn func(container any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	switch v := doc.(type) {
+	case map[string]any:
+		child, ok := v[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("member %q not found", path[0])
+		}
+		child, err := patchUpdate(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		v[path[0]] = child
+		return v, nil
+	case []any:
+		i, err := arrayIndex(path[0], len(v), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := patchUpdate(v[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = child
+		return v, nil
+	default:
+		return nil, fmt.Errorf("can't traverse %q", path[0])
+	}
+}
+
+func patchAdd(doc any, path []string, val any) (any, error) {
+	if len(path) == 0 {
+		return val, nil
+	}
+	return patchUpdate(doc, path, func(container any, token string) (any, error) {
+		switch v := container.(type) {
+		case map[string]any:
+			v[token] = val
+			return v, nil
+		case []any:
+			i, err := arrayIndex(token, len(v), true)
+			if err != nil {
+				return nil, err
+			}
+			v = append(v, nil)
+			copy(v[i+1:], v[i:])
+			v[i] = val
+			return v, nil
+		default:
+			return nil, fmt.Errorf("can't add %q", token)
+		}
+	})
+}
+
+func patchRemove(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	return patchUpdate(doc, path, func(container any, token string) (any, error) {
+		switch v := container.(type) {
+		case map[string]any:
+			if _, ok := v[token]; !ok {
+				return nil, fmt.Errorf("member %q not found", token)
+			}
+			delete(v, token)
+			return v, nil
+		case []any:
+			i, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(v[:i], v[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("can't remove %q", token)
+		}
+	})
+}
+
+func copyJSONValue(val any) any {
+	switch v := val.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for key, item := range v {
+			m[key] = copyJSONValue(item)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, item := range v {
+			s[i] = copyJSONValue(item)
+		}
+		return s
+	default:
+		return val
+	}
+}
+
+func equalJSONValue(a, b any) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		fx, e1 := x.Float64()
+		fy, e2 := y.Float64()
+		return e1 == nil && e2 == nil && fx == fy
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, item := range x {
+			if other, ok := y[key]; !ok || !equalJSONValue(item, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equalJSONValue(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,197 @@
+package gear
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type patchUserTemplate struct {
+	Name  string            `json:"name"`
+	Age   int               `json:"age"`
+	Tags  []string          `json:"tags"`
+	Meta  map[string]string `json:"meta,omitempty"`
+	Local string            `json:"-"`
+	patchProfile
+}
+
+type patchProfile struct {
+	Bio  string `json:"bio"`
+	hash string
+}
+
+func (b *patchUserTemplate) Validate() error {
+	if b.Name == "" {
+		return errors.New("name required")
+	}
+	return nil
+}
+
+func TestGearApplyMergePatch(t *testing.T) {
+	t.Run("RFC 7386 examples", func(t *testing.T) {
+		assert := assert.New(t)
+
+		cases := [][3]string{
+			{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+			{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+			{`{"a":"b"}`, `{"a":null}`, `{}`},
+			{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+			{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+			{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+			{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+			{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+			{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+			{`{"a":"b"}`, `["c"]`, `["c"]`},
+			{`{"a":"foo"}`, `null`, `null`},
+			{`{"a":"foo"}`, `"bar"`, `"bar"`},
+			{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+			{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+			{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+			{`{"id":12345678901234567890}`, `{"a":1}`, `{"a":1,"id":12345678901234567890}`},
+		}
+		for _, c := range cases {
+			doc, err := ApplyMergePatch([]byte(c[0]), []byte(c[1]))
+			assert.Nil(err)
+			assert.Equal(c[2], string(doc))
+		}
+	})
+
+	t.Run("invalid patch", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := ApplyMergePatch([]byte(`{}`), []byte(`{a}`))
+		assert.Equal(400, err.(*Error).Code)
+	})
+}
+
+func TestGearApplyJSONPatch(t *testing.T) {
+	t.Run("should work", func(t *testing.T) {
+		assert := assert.New(t)
+
+		cases := [][3]string{
+			{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+			{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+			{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc"]}]`, `{"foo":["bar",["abc"]]}`},
+			{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+			{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+			{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+			{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+				`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+				`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+			{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+			{`{"foo":{"a":1}}`, `[{"op":"copy","from":"/foo","path":"/bar"},{"op":"add","path":"/bar/b","value":2}]`, `{"bar":{"a":1,"b":2},"foo":{"a":1}}`},
+			{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+			{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"remove","path":"/~1"}]`, `{"~1":10}`},
+			{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"child":{"grandchild":{}},"foo":"bar"}`},
+			{`{"foo":"bar"}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+		}
+		for _, c := range cases {
+			doc, err := ApplyJSONPatch([]byte(c[0]), []byte(c[1]))
+			assert.Nil(err)
+			assert.Equal(c[2], string(doc))
+		}
+	})
+
+	t.Run("should return error", func(t *testing.T) {
+		assert := assert.New(t)
+
+		cases := []struct {
+			doc, patch string
+			code       int
+		}{
+			{`{"foo":"bar"}`, `{"op":"add"}`, 400},
+			{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, 422},
+			{`{"foo":"bar"}`, `[{"op":"add","path":"/baz"}]`, 422},
+			{`{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, 422},
+			{`{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":1}]`, 422},
+			{`{"foo":[1]}`, `[{"op":"add","path":"/foo/2","value":1}]`, 422},
+			{`{"foo":[1]}`, `[{"op":"add","path":"/foo/01","value":1}]`, 422},
+			{`{"foo":{"a":1}}`, `[{"op":"move","from":"/foo","path":"/foo/a/b"}]`, 422},
+			{`{"foo":"bar"}`, `[{"op":"unknown","path":"/foo"}]`, 422},
+			{`{"foo":"bar"}`, `[{"op":"add","path":"foo","value":1}]`, 422},
+			{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, 409},
+		}
+		for _, c := range cases {
+			_, err := ApplyJSONPatch([]byte(c.doc), []byte(c.patch))
+			assert.Equal(c.code, err.(*Error).Code, c.patch)
+		}
+	})
+}
+
+func TestGearContextParseMergePatch(t *testing.T) {
+	app := New()
+
+	t.Run("should apply merge patch", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ctx := CtxTest(app, "PATCH", "http://example.com/users/1",
+			bytes.NewBuffer([]byte(`{"age":21,"tags":null,"meta":{"a":"b"}}`)))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationMergePatchJSON)
+
+		user := &patchUserTemplate{Name: "gear", Age: 20, Tags: []string{"go"}, Local: "x",
+			patchProfile: patchProfile{Bio: "hi", hash: "secret"}}
+		assert.Nil(ctx.ParseMergePatch(user))
+		assert.Equal("gear", user.Name)
+		assert.Equal(21, user.Age)
+		assert.Nil(user.Tags)
+		assert.Equal(map[string]string{"a": "b"}, user.Meta)
+		assert.Equal("x", user.Local)
+		assert.Equal("hi", user.Bio)
+		assert.Equal("secret", user.hash)
+	})
+
+	t.Run("should apply json patch", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ctx := CtxTest(app, "PATCH", "http://example.com/users/1",
+			bytes.NewBuffer([]byte(`[{"op":"test","path":"/age","value":20},{"op":"add","path":"/tags/-","value":"web"}]`)))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationJSONPatchJSON)
+
+		user := &patchUserTemplate{Name: "gear", Age: 20, Tags: []string{"go"}}
+		assert.Nil(ctx.ParseMergePatch(user))
+		assert.Equal([]string{"go", "web"}, user.Tags)
+	})
+
+	t.Run("should not change target when validate error", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ctx := CtxTest(app, "PATCH", "http://example.com/users/1",
+			bytes.NewBuffer([]byte(`{"name":null,"age":30}`)))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+
+		user := &patchUserTemplate{Name: "gear", Age: 20}
+		err := ctx.ParseMergePatch(user)
+		assert.Equal(422, err.(*Error).Code)
+		assert.Equal("gear", user.Name)
+		assert.Equal(20, user.Age)
+	})
+
+	t.Run("should return error", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ctx := CtxTest(app, "PATCH", "http://example.com/users/1",
+			bytes.NewBuffer([]byte(`[{"op":"test","path":"/age","value":21}]`)))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationJSONPatchJSON)
+		err := ctx.ParseMergePatch(&patchUserTemplate{Name: "gear", Age: 20})
+		assert.Equal(409, err.(*Error).Code)
+
+		ctx = CtxTest(app, "PATCH", "http://example.com/users/1",
+			bytes.NewBuffer([]byte(`<name>gear</name>`)))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationXML)
+		err = ctx.ParseMergePatch(&patchUserTemplate{Name: "gear"})
+		assert.Equal(415, err.(*Error).Code)
+
+		ctx = CtxTest(app, "PATCH", "http://example.com/users/1", bytes.NewBuffer([]byte{}))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+		err = ctx.ParseMergePatch(&patchUserTemplate{Name: "gear"})
+		assert.Equal(400, err.(*Error).Code)
+
+		ctx = CtxTest(app, "PATCH", "http://example.com/users/1", bytes.NewBuffer([]byte(`{}`)))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+		var user *patchUserTemplate
+		err = ctx.ParseMergePatch(user)
+		assert.Equal(500, err.(*Error).Code)
+	})
+}
@@ -0,0 +1,118 @@
+package gear
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// PluginSetup creates a middleware for app with the plugin options.
+type PluginSetup func(app *App, options PluginOptions) (Middleware, error)
+
+// PluginOptions is the options of a plugin from config.
+type PluginOptions map[string]any
+
+// Decode decodes options to v (a pointer to struct usually) with encoding/json.
+//
+//	opts := struct {
+//		MaxAge int `json:"maxAge"`
+//	}{}
+//	if err := options.Decode(&opts); err != nil {
+//		return nil, err
+//	}
+func (o PluginOptions) Decode(v any) error {
+	buf, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, v)
+}
+
+// PluginConfig defines a plugin to initialize by name with options.
+type PluginConfig struct {
+	Name    string        `json:"name" yaml:"name"`
+	Options PluginOptions `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+var plugins = struct {
+	sync.RWMutex
+	m map[string]PluginSetup
+}{m: make(map[string]PluginSetup)}
+
+// RegisterPlugin makes a plugin available by the name, so that app can initialize it from config
+// with app.UsePlugins. It is usually called in the init function of the plugin package.
+// It panics if name is empty, setup is nil or the name registered already.
+//
+//	func init() {
+//		gear.RegisterPlugin("requestid", func(app *gear.App, options gear.PluginOptions) (gear.Middleware, error) {
+//			return requestid.New(), nil
+//		})
+//	}
+func RegisterPlugin(name string, setup PluginSetup) {
+	if name == "" {
+		panic(Err.WithMsg("plugin name is required"))
+	}
+	if setup == nil {
+		panic(Err.WithMsgf("plugin %q setup is nil", name))
+	}
+
+	plugins.Lock()
+	defer plugins.Unlock()
+	if _, ok := plugins.m[name]; ok {
+		panic(Err.WithMsgf("plugin %q registered already", name))
+	}
+	plugins.m[name] = setup
+}
+
+// Plugins returns the sorted names of the registered plugins.
+func Plugins() []string {
+	plugins.RLock()
+	defer plugins.RUnlock()
+
+	names := make([]string, 0, len(plugins.m))
+	for name := range plugins.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UsePlugins initializes the registered plugins by name with options in order, and uses
+// them as app middlewares. It returns error if any plugin not registered or failed to setup,
+// and no middleware will be used in that case.
+//
+//	var configs []gear.PluginConfig
+//	// load configs from a config file...
+//	// [{"name": "requestid"}, {"name": "cors", "options": {"allowOrigins": ["*"]}}]
+//	if err := app.UsePlugins(configs...); err != nil {
+//		panic(err)
+//	}
+func (app *App) UsePlugins(configs ...PluginConfig) error {
+	mds := make([]Middleware, 0, len(configs))
+	for _, cfg := range configs {
+		plugins.RLock()
+		setup, ok := plugins.m[cfg.Name]
+		plugins.RUnlock()
+		if !ok {
+			return Err.WithMsgf("plugin %q not registered", cfg.Name)
+		}
+
+		options := cfg.Options
+		if options == nil {
+			options = PluginOptions{}
+		}
+		md, err := setup(app, options)
+		if err != nil {
+			return Err.WithMsgf("plugin %q setup failed: %v", cfg.Name, err)
+		}
+		if md == nil {
+			return Err.WithMsgf("plugin %q setup returns nil middleware", cfg.Name)
+		}
+		mds = append(mds, md)
+	}
+
+	for _, md := range mds {
+		app.Use(md)
+	}
+	return nil
+}
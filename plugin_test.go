@@ -0,0 +1,89 @@
+package gear
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearPlugin(t *testing.T) {
+	RegisterPlugin("test-header", func(app *App, options PluginOptions) (Middleware, error) {
+		opts := struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}{Name: "X-Plugin"}
+		if err := options.Decode(&opts); err != nil {
+			return nil, err
+		}
+		if opts.Value == "" {
+			return nil, errors.New("value is required")
+		}
+		return func(ctx *Context) error {
+			ctx.SetHeader(opts.Name, opts.Value)
+			return nil
+		}, nil
+	})
+	RegisterPlugin("test-nil", func(app *App, options PluginOptions) (Middleware, error) {
+		return nil, nil
+	})
+
+	t.Run("RegisterPlugin", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.Panics(func() {
+			RegisterPlugin("", func(app *App, options PluginOptions) (Middleware, error) { return noOp, nil })
+		})
+		assert.Panics(func() {
+			RegisterPlugin("test", nil)
+		})
+		assert.Panics(func() {
+			RegisterPlugin("test-header", func(app *App, options PluginOptions) (Middleware, error) { return noOp, nil })
+		})
+
+		names := strings.Join(Plugins(), ",")
+		assert.True(strings.Contains(names, "test-header,test-nil"))
+	})
+
+	t.Run("app.UsePlugins", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		assert.Nil(app.UsePlugins(
+			PluginConfig{Name: "test-header", Options: PluginOptions{"value": "a"}},
+			PluginConfig{Name: "test-header", Options: PluginOptions{"name": "X-Plugin-B", "value": "b"}},
+		))
+		app.Use(func(ctx *Context) error {
+			return ctx.End(http.StatusNoContent)
+		})
+		srv := app.Start()
+		defer srv.Close()
+
+		res, err := RequestBy("GET", "http://"+srv.Addr().String())
+		assert.Nil(err)
+		assert.Equal(204, res.StatusCode)
+		assert.Equal("a", res.Header.Get("X-Plugin"))
+		assert.Equal("b", res.Header.Get("X-Plugin-B"))
+		res.Body.Close()
+	})
+
+	t.Run("app.UsePlugins with error", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		err := app.UsePlugins(PluginConfig{Name: "test-header", Options: PluginOptions{"value": "a"}}, PluginConfig{Name: "unknown"})
+		assert.Equal(`Error: plugin "unknown" not registered`, err.Error())
+		assert.Equal(0, len(app.mds))
+
+		err = app.UsePlugins(PluginConfig{Name: "test-header"})
+		assert.Equal(`Error: plugin "test-header" setup failed: value is required`, err.Error())
+
+		err = app.UsePlugins(PluginConfig{Name: "test-header", Options: PluginOptions{"value": 1}})
+		assert.NotNil(err)
+
+		err = app.UsePlugins(PluginConfig{Name: "test-nil"})
+		assert.Equal(`Error: plugin "test-nil" setup returns nil middleware`, err.Error())
+	})
+}
@@ -0,0 +1,76 @@
+package gear
+
+import "sync"
+
+// PooledTemplate is a BodyTemplate that can be reused by TemplatePool.
+// Reset should clear all fields, since the body parser (such as json.Unmarshal) only sets the fields in request body.
+type PooledTemplate interface {
+	BodyTemplate
+	Reset()
+}
+
+// TemplatePool is a pool of body templates backed by sync.Pool, it reuses the templates across requests
+// to reduce allocations for hot endpoints. Use it with gear.HandleBody.
+type TemplatePool[T PooledTemplate] struct {
+	pool sync.Pool
+}
+
+// NewTemplatePool creates a TemplatePool with the function to create new templates.
+//
+//	type CreateUserBody struct {
+//		Name  string   `json:"name"`
+//		Tags  []string `json:"tags"`
+//	}
+//
+//	func (b *CreateUserBody) Validate() error {
+//		if b.Name == "" {
+//			return gear.ErrBadRequest.WithMsg("invalid name")
+//		}
+//		return nil
+//	}
+//
+//	func (b *CreateUserBody) Reset() {
+//		*b = CreateUserBody{Tags: b.Tags[:0]}
+//	}
+//
+//	var createUserBodies = gear.NewTemplatePool(func() *CreateUserBody { return new(CreateUserBody) })
+//
+//	router.Post("/users", gear.HandleBody(createUserBodies, func(ctx *gear.Context, body *CreateUserBody) error {
+//		user, err := createUser(ctx, body.Name, body.Tags)
+//		if err != nil {
+//			return err
+//		}
+//		return ctx.JSON(http.StatusCreated, user)
+//	}))
+func NewTemplatePool[T PooledTemplate](fn func() T) *TemplatePool[T] {
+	if fn == nil {
+		panic(Err.WithMsg("invalid template constructor"))
+	}
+	return &TemplatePool[T]{pool: sync.Pool{New: func() any { return fn() }}}
+}
+
+// Get returns a template from the pool, or a new one if the pool is empty.
+func (p *TemplatePool[T]) Get() T {
+	return p.pool.Get().(T)
+}
+
+// Put resets the template and puts it back to the pool.
+func (p *TemplatePool[T]) Put(body T) {
+	body.Reset()
+	p.pool.Put(body)
+}
+
+// HandleBody returns a middleware that parses the request body into a template from the pool by ctx.ParseBody,
+// and calls fn with it. The template is put back to the pool after fn returned, so it should not be retained
+// by fn, such as used in goroutines or "end hooks".
+func HandleBody[T PooledTemplate](pool *TemplatePool[T], fn func(ctx *Context, body T) error) Middleware {
+	return func(ctx *Context) error {
+		body := pool.Get()
+		defer pool.Put(body)
+
+		if err := ctx.ParseBody(body); err != nil {
+			return err
+		}
+		return fn(ctx, body)
+	}
+}
@@ -0,0 +1,82 @@
+package gear
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pooledBody struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+func (b *pooledBody) Validate() error {
+	if b.Name == "" {
+		return ErrBadRequest.WithMsg("invalid name")
+	}
+	return nil
+}
+
+func (b *pooledBody) Reset() {
+	*b = pooledBody{Tags: b.Tags[:0]}
+}
+
+func TestGearTemplatePool(t *testing.T) {
+	t.Run("should reset and reuse templates", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.Panics(func() { NewTemplatePool[*pooledBody](nil) })
+
+		created := 0
+		pool := NewTemplatePool(func() *pooledBody {
+			created++
+			return new(pooledBody)
+		})
+		body := pool.Get()
+		assert.Equal(1, created)
+		body.Name = "abc"
+		body.Tags = append(body.Tags, "x")
+		pool.Put(body)
+		assert.Equal("", body.Name)
+		assert.Equal(0, len(body.Tags))
+	})
+
+	t.Run("should work with HandleBody", func(t *testing.T) {
+		assert := assert.New(t)
+
+		pool := NewTemplatePool(func() *pooledBody { return new(pooledBody) })
+		app := New()
+		app.Use(HandleBody(pool, func(ctx *Context, body *pooledBody) error {
+			return ctx.JSON(http.StatusOK, body)
+		}))
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		post := func(body string) (*GearResponse, error) {
+			req, _ := http.NewRequest("POST", host, strings.NewReader(body))
+			req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+			return DefaultClientDo(req)
+		}
+
+		res, err := post(`{"name":"a","tags":["x","y"]}`)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(`{"name":"a","tags":["x","y"]}`, PickRes(res.Text()).(string))
+
+		res, err = post(`{"name":"b"}`)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		text := PickRes(res.Text()).(string)
+		assert.True(strings.HasPrefix(text, `{"name":"b","tags":`))
+		assert.NotContains(text, `"x"`)
+
+		res, err = post(`{"tags":["z"]}`)
+		assert.Nil(err)
+		assert.Equal(400, res.StatusCode)
+		assert.Equal(`{"error":"BadRequest","message":"invalid name"}`, PickRes(res.Text()).(string))
+	})
+}
@@ -0,0 +1,59 @@
+package gear
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RedirectHTTP starts a minimal HTTP server on addr (default to ":http") that redirects all the requests to
+// the HTTPS host on the default port, with 301 for GET and HEAD requests and 308 for the others, so the method
+// and body are kept. It is used with ListenTLS (or ListenHTTP3), and closed by app.Close with the main server,
+// then it returns http.ErrServerClosed.
+// Behind a trusted proxy (the SetTrustedProxy setting) that terminates TLS, the requests with
+// "X-Forwarded-Proto: https" are served by the app instead of redirecting, to avoid the redirect loop.
+// The Strict-Transport-Security header can be set by the secure middleware on the HTTPS server.
+//
+//	app := gear.New()
+//	app.Use(secure.StrictTransportSecurity(secure.StrictTransportSecurityOptions{MaxAge: 365 * 24 * time.Hour}))
+//	go func() {
+//		app.Error(app.RedirectHTTP(":80"))
+//	}()
+//	app.Error(app.ListenTLS(":443", "cert.pem", "key.pem"))
+func (app *App) RedirectHTTP(addr string) error {
+	if addr == "" {
+		addr = ":http"
+	}
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           http.HandlerFunc(app.redirectHTTPS),
+		ErrorLog:          app.logger,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	app.redirect.Store(srv)
+	if app.closing.Load() {
+		return http.ErrServerClosed
+	}
+	return srv.ListenAndServe()
+}
+
+func (app *App) redirectHTTPS(w http.ResponseWriter, r *http.Request) {
+	if app.settings[SetTrustedProxy].(bool) && strings.EqualFold(r.Header.Get(HeaderXForwardedProto), "https") {
+		app.ServeHTTP(w, r)
+		return
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6
+		}
+	}
+	code := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		code = http.StatusPermanentRedirect
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+}
@@ -0,0 +1,72 @@
+package gear
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearAppRedirectHTTP(t *testing.T) {
+	t.Run("should redirect to https", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Use(func(ctx *Context) error {
+			return ctx.HTML(200, ctx.Scheme())
+		})
+		redirect := func(req *http.Request) *http.Response {
+			w := httptest.NewRecorder()
+			app.redirectHTTPS(w, req)
+			return w.Result()
+		}
+
+		res := redirect(httptest.NewRequest("GET", "http://example.com/a/b?c=1", nil))
+		assert.Equal(301, res.StatusCode)
+		assert.Equal("https://example.com/a/b?c=1", res.Header.Get(HeaderLocation))
+
+		res = redirect(httptest.NewRequest("HEAD", "http://example.com:8080/", nil))
+		assert.Equal(301, res.StatusCode)
+		assert.Equal("https://example.com/", res.Header.Get(HeaderLocation))
+
+		res = redirect(httptest.NewRequest("POST", "http://[::1]:80/users", nil))
+		assert.Equal(308, res.StatusCode)
+		assert.Equal("https://[::1]/users", res.Header.Get(HeaderLocation))
+
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set(HeaderXForwardedProto, "https")
+		res = redirect(req)
+		assert.Equal(301, res.StatusCode, "should not trust X-Forwarded-Proto by default")
+
+		app.Set(SetTrustedProxy, true)
+		res = redirect(req)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("", res.Header.Get(HeaderLocation))
+	})
+
+	t.Run("should close with app", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		errc := make(chan error, 1)
+		go func() {
+			errc <- app.RedirectHTTP("127.0.0.1:3327")
+		}()
+		time.Sleep(100 * time.Millisecond)
+
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+		res, err := client.Get("http://127.0.0.1:3327/hello")
+		assert.Nil(err)
+		res.Body.Close()
+		assert.Equal(301, res.StatusCode)
+		assert.Equal("https://127.0.0.1/hello", res.Header.Get(HeaderLocation))
+
+		assert.Nil(app.Close())
+		assert.Equal(http.ErrServerClosed, <-errc)
+		assert.Equal(http.ErrServerClosed, app.RedirectHTTP("127.0.0.1:3327"))
+	})
+}
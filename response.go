@@ -2,14 +2,17 @@ package gear
 
 import (
 	"bufio"
+	"io"
 	"net"
 	"net/http"
+	"net/textproto"
 	"regexp"
+	"time"
 )
 
 var misdirectedResponseBody []byte = []byte(`{"error":"MisdirectedRequest","message":"The request was directed at a server that is not able to produce a response."}`)
 
-var defaultHeaderFilterReg = regexp.MustCompile(`(?i)^(accept|allow|retry-after|warning|vary|server|access-control-allow-|x-)`)
+var defaultHeaderFilterReg = regexp.MustCompile(`(?i)^(accept|allow|alt-svc|retry-after|warning|vary|server|access-control-allow-|x-)`)
 
 // Response wraps an http.ResponseWriter and implements its interface to be used
 // by an HTTP handler to construct an HTTP response.
@@ -20,11 +23,49 @@ type Response struct {
 	endHooks    []func()
 	ended       atomicBool // indicate that app middlewares run out.
 	wroteHeader atomicBool
+	sent        atomicBool // the header is sent to the underlying writer, the body can't be replaced.
 	// some http.ResponseWriter implementations will reset http.Header to nil.
 	// we capture it for ctx.OnEnd hooks. https://github.com/teambition/gear/issues/49
 	handlerHeader http.Header
 	w             http.ResponseWriter // the origin http.ResponseWriter, should not be override.
 	rw            http.ResponseWriter // maybe a http.ResponseWriter wrapper
+
+	// conditionalHook evaluates the conditional request after afterHooks, see SetETag.
+	conditionalHook func()
+	// diagnosticsHook runs before header wrote, it will not be cleared by errors like afterHooks.
+	diagnosticsHook func()
+	// afterWatch receives the duration of afterHooks, it is set by SetSlowAfterHooks setting.
+	afterWatch func(time.Duration)
+	// limit bounds the response body size, it is set by SetMaxResponseBytes setting.
+	limit *responseLimit
+}
+
+// responseLimit counts the written bytes of a response, and reports it once when exceeding the max bytes.
+type responseLimit struct {
+	max      int64
+	written  int64
+	reported bool
+	err      error // the error to abort the streaming response, returned by report
+	// report reports the size of the exceeding response. The captured response is replaced with the error
+	// response if aborted, otherwise the error is returned to abort the streaming response.
+	report func(size int64, captured bool) error
+}
+
+func (l *responseLimit) write(w io.Writer, buf []byte) (n int, err error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if !l.reported && l.written+int64(len(buf)) > l.max {
+		l.reported = true
+		if l.err = l.report(l.written+int64(len(buf)), false); l.err != nil {
+			n, _ = w.Write(buf[:l.max-l.written])
+			l.written += int64(n)
+			return n, l.err
+		}
+	}
+	n, err = w.Write(buf)
+	l.written += int64(n)
+	return
 }
 
 // Get gets the first value associated with the given key. If there are no values associated with the key, Get returns "". To access multiple values of a key, access the map directly with CanonicalHeaderKey.
@@ -37,6 +78,18 @@ func (r *Response) Set(key, value string) {
 	r.handlerHeader.Set(key, value)
 }
 
+// SetIfEmpty sets the header entry associated with key to value if the header has no value of the key,
+// so a middleware can provide a default value without overriding the handlers' value.
+// It returns true if the value is set.
+func (r *Response) SetIfEmpty(key, value string) bool {
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	if len(r.handlerHeader[key]) > 0 {
+		return false
+	}
+	r.handlerHeader[key] = []string{value}
+	return true
+}
+
 // Add adds the key, value pair to the header. It appends to any existing values associated with key.
 func (r *Response) Add(key, value string) {
 	r.handlerHeader.Add(key, value)
@@ -70,12 +123,28 @@ func (r *Response) Type() string {
 }
 
 // Body returns the response content. If you use Response.Write directly, the content will not be captured.
+// The content should not be modified, it may share the memory of a string, such as the str of ctx.HTMLString.
 func (r *Response) Body() []byte {
 	return r.body
 }
 
+// SetBody replaces the response content captured by ctx.Res (see Response.Body) in the "after hooks",
+// such as injecting a snippet into the HTML responses. It does nothing after the header wrote,
+// or if the content is not captured (written by Response.Write directly).
+//
+//	ctx.After(func() {
+//		if body := ctx.Res.Body(); body != nil && strings.HasPrefix(ctx.Res.Type(), gear.MIMETextHTML) {
+//			ctx.Res.SetBody(bytes.Replace(body, []byte("</body>"), []byte(snippet+"</body>"), 1))
+//		}
+//	})
+func (r *Response) SetBody(body []byte) {
+	if r.body != nil && !r.sent.isTrue() {
+		r.body = body
+	}
+}
+
 // ResetHeader reset headers. The default filterReg is
-// `(?i)^(accept|allow|retry-after|warning|vary|server|access-control-allow-|x-)`.
+// `(?i)^(accept|allow|alt-svc|retry-after|warning|vary|server|access-control-allow-|x-)`.
 func (r *Response) ResetHeader(filterReg ...*regexp.Regexp) {
 	reg := defaultHeaderFilterReg
 	if len(filterReg) > 0 {
@@ -103,9 +172,57 @@ func (r *Response) Write(buf []byte) (int, error) {
 		}
 		r.WriteHeader(0)
 	}
+	if r.limit != nil {
+		return r.limit.write(r.rw, buf)
+	}
 	return r.rw.Write(buf)
 }
 
+// ReadFrom implements the io.ReaderFrom interface, so io.Copy to the response (such as http.ServeContent)
+// can send an *os.File with the zero-copy sendfile system call of the underlying http.ResponseWriter.
+// If the underlying writer is wrapped (such as by compression), it copies the content to it normally.
+func (r *Response) ReadFrom(src io.Reader) (int64, error) {
+	if !r.wroteHeader.isTrue() {
+		if r.status == 0 {
+			r.status = 200
+		}
+		r.WriteHeader(0)
+	}
+	if l := r.limit; l != nil && !l.reported {
+		// send the content in the limit, then check the rest by Write
+		n, err := r.readFrom(io.LimitReader(src, l.max-l.written))
+		l.written += n
+		if err != nil {
+			return n, err
+		}
+		var b [1]byte
+		if m, err := io.ReadFull(src, b[:]); m == 0 {
+			if err == io.EOF {
+				err = nil
+			}
+			return n, err
+		}
+		m, err := r.Write(b[:])
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+		m2, err := r.ReadFrom(src)
+		return n + m2, err
+	}
+	if r.limit != nil && r.limit.err != nil {
+		return 0, r.limit.err
+	}
+	return r.readFrom(src)
+}
+
+func (r *Response) readFrom(src io.Reader) (int64, error) {
+	if rf, ok := r.rw.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	return io.Copy(r.rw, src)
+}
+
 // WriteHeader sends an HTTP response header with status code.
 // If WriteHeader is not called explicitly, the first call to Write
 // will trigger an implicit WriteHeader(http.StatusOK).
@@ -123,7 +240,13 @@ func (r *Response) WriteHeader(code int) {
 	}
 
 	// execute "after hooks" with LIFO order before Response.WriteHeader
-	runHooks(r.afterHooks)
+	if r.afterWatch != nil && len(r.afterHooks) > 0 {
+		start := time.Now()
+		runHooks(r.afterHooks)
+		r.afterWatch(time.Since(start))
+	} else {
+		runHooks(r.afterHooks)
+	}
 
 	// check status, r.status maybe changed in afterHooks
 	if !IsStatusCode(r.status) {
@@ -140,7 +263,18 @@ func (r *Response) WriteHeader(code int) {
 	} else if isEmptyStatus(r.status) {
 		r.body = nil
 	}
+	if r.conditionalHook != nil {
+		r.conditionalHook()
+	}
+	if r.limit != nil && int64(len(r.body)) > r.limit.max {
+		r.limit.reported = true
+		r.limit.report(int64(len(r.body)), true)
+	}
+	if r.diagnosticsHook != nil {
+		r.diagnosticsHook()
+	}
 	// we don't need to set Content-Length, http.Server will handle it
+	r.sent.setTrue()
 	r.rw.WriteHeader(r.status)
 }
 
@@ -32,6 +32,15 @@ func TestGearResponse(t *testing.T) {
 		res.Del("Set-Cookie")
 		assert.Equal("", res.Get("Set-Cookie"))
 
+		assert.True(res.SetIfEmpty("x-frame-options", "DENY"))
+		assert.False(res.SetIfEmpty("X-Frame-Options", "SAMEORIGIN"))
+		assert.Equal("DENY", res.Get(HeaderXFrameOptions))
+
+		ctx.SetHeaders(map[string]string{"cache-control": "no-cache", HeaderPragma: "no-cache", HeaderXFrameOptions: "SAMEORIGIN"})
+		assert.Equal([]string{"no-cache"}, header.Values(HeaderCacheControl))
+		assert.Equal("no-cache", res.Get(HeaderPragma))
+		assert.Equal([]string{"SAMEORIGIN"}, header.Values(HeaderXFrameOptions))
+
 		assert.Equal("", res.Get("Vary"))
 		res.Vary("Accept-Encoding")
 		assert.Equal("Accept-Encoding", res.Get("Vary"))
@@ -224,6 +233,28 @@ func TestGearResponse(t *testing.T) {
 		assert.Equal("Hello", CtxBody(ctx))
 	})
 
+	t.Run("SetBody", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ctx := CtxTest(app, "GET", "http://example.com/foo", nil)
+		ctx.After(func() {
+			ctx.Res.SetBody(append(ctx.Res.Body(), " Gear"...))
+		})
+		ctx.Res.respond(200, []byte("Hello"))
+		assert.Equal("Hello Gear", CtxBody(ctx))
+		ctx.Res.SetBody([]byte("Hi"))
+		assert.Equal([]byte("Hello Gear"), ctx.Res.Body())
+
+		ctx = CtxTest(app, "GET", "http://example.com/foo", nil)
+		ctx.After(func() {
+			ctx.Res.SetBody([]byte("Hi"))
+		})
+		ctx.Res.WriteHeader(200)
+		ctx.Res.Write([]byte("Hello"))
+		assert.Nil(ctx.Res.Body())
+		assert.Equal("Hello", CtxBody(ctx))
+	})
+
 	t.Run("Body should be nil", func(t *testing.T) {
 		assert := assert.New(t)
 
@@ -0,0 +1,145 @@
+package gear
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"time"
+)
+
+// envListenFD is the environment variable of the listener file descriptor inherited from the parent process.
+const envListenFD = "GEAR_LISTEN_FD"
+
+// restartCommand returns the command to start the new process, the same executable with the same arguments.
+var restartCommand = func() (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd, nil
+}
+
+// ListenGraceful starts the HTTP server (or HTTPS server with keyPair) with a context, that restarts with
+// zero downtime on SIGUSR2 (not supported on windows): it starts a new process of the same executable and
+// arguments, which inherits the listener file descriptor and serves the new connections immediately, and the
+// old process stops accepting and drains the in-flight requests in SetGraceTimeout, then ListenGraceful returns
+// http.ErrServerClosed. So the new binary can be deployed without an external supervisor or dropped connections.
+// The server will be shut down gracefully when the ctx is done too.
+//
+//	func main() {
+//		app := gear.New()
+//		// do some thing...
+//		app.Error(app.ListenGraceful(gear.ContextWithSignal(context.Background()), ":3000"))
+//	}
+//
+//	// deploy the new binary, then:
+//	// kill -USR2 <pid>
+func (app *App) ListenGraceful(ctx context.Context, addr string, keyPair ...string) error {
+	if err := app.prepare(app); err != nil {
+		return err
+	}
+	l, err := inheritedListener()
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		if addr == "" {
+			addr = ":http"
+			if len(keyPair) >= 2 {
+				addr = ":https"
+			}
+		}
+		if l, err = net.Listen("tcp", addr); err != nil {
+			return err
+		}
+	}
+
+	restart := make(chan os.Signal, 1)
+	if restartSignal != nil {
+		signal.Notify(restart, restartSignal)
+		defer signal.Stop(restart)
+	}
+	go func() {
+		app.waitRestart(ctx, l, restart)
+		c, cancel := context.WithTimeout(context.Background(), app.settings[SetGraceTimeout].(time.Duration))
+		defer cancel()
+		if err := app.Close(c); err != nil {
+			app.Error(err)
+		}
+	}()
+
+	app.runReadinessGates()
+	if len(keyPair) >= 2 && keyPair[0] != "" && keyPair[1] != "" {
+		return app.Server.ServeTLS(app.limitListener(l), keyPair[0], keyPair[1])
+	}
+	return app.Server.Serve(app.limitListener(l))
+}
+
+// waitRestart waits until the ctx is done or the new process started by the restart signal.
+func (app *App) waitRestart(ctx context.Context, l net.Listener, restart <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-restart:
+			if err := startProcess(l); err != nil {
+				app.Error(Err.WithMsgf("failed to restart: %v", err))
+				continue // keep serving
+			}
+			return
+		}
+	}
+}
+
+// startProcess starts the new process with the listener file descriptor as fd 3.
+func startProcess(l net.Listener) error {
+	fl, ok := l.(interface{ File() (*os.File, error) })
+	if !ok {
+		return Err.WithMsgf("listener %T can't be inherited", l)
+	}
+	f, err := fl.File() // the duplicated fd
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cmd, err := restartCommand()
+	if err != nil {
+		return err
+	}
+	cmd.ExtraFiles = []*os.File{f}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, envListenFD+"=3")
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// inheritedListener returns the listener inherited from the parent process by ListenGraceful,
+// or nil if not restarted.
+func inheritedListener() (net.Listener, error) {
+	val, ok := os.LookupEnv(envListenFD)
+	if !ok {
+		return nil, nil
+	}
+	os.Unsetenv(envListenFD)
+	fd, err := strconv.Atoi(val)
+	if err != nil {
+		return nil, Err.WithMsgf("invalid %s: %q", envListenFD, val)
+	}
+	f := os.NewFile(uintptr(fd), "listener")
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, Err.WithMsgf("failed to inherit the listener: %v", err)
+	}
+	return l, nil
+}
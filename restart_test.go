@@ -0,0 +1,97 @@
+//go:build !windows
+// +build !windows
+
+package gear
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearAppListenGraceful(t *testing.T) {
+	if os.Getenv("GEAR_TEST_RESTARTED") == "1" {
+		// the new process started by restart
+		app := New()
+		app.Use(func(ctx *Context) error {
+			return ctx.HTML(200, "restarted")
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		app.ListenGraceful(ctx, "")
+		return
+	}
+
+	t.Run("should serve and close with ctx", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Use(func(ctx *Context) error {
+			return ctx.HTML(200, "ok")
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		errc := make(chan error, 1)
+		go func() {
+			errc <- app.ListenGraceful(ctx, "127.0.0.1:3326")
+		}()
+		time.Sleep(100 * time.Millisecond)
+
+		res, err := RequestBy("GET", "http://127.0.0.1:3326")
+		assert.Nil(err)
+		assert.Equal("ok", PickRes(res.Text()).(string))
+		cancel()
+		assert.Equal(http.ErrServerClosed, <-errc)
+
+		assert.NotNil(New().ListenGraceful(context.Background(), "127.0.0.1:-1"))
+		t.Setenv(envListenFD, "x")
+		assert.Equal(`Error: invalid GEAR_LISTEN_FD: "x"`, New().ListenGraceful(context.Background(), "").Error())
+	})
+
+	t.Run("should restart with the inherited listener", func(t *testing.T) {
+		assert := assert.New(t)
+
+		command := restartCommand
+		defer func() {
+			restartCommand = command
+		}()
+		restartCommand = func() (*exec.Cmd, error) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestGearAppListenGraceful$")
+			cmd.Env = append(os.Environ(), "GEAR_TEST_RESTARTED=1")
+			return cmd, nil
+		}
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(err)
+		addr := l.Addr().String()
+		l.Close()
+
+		app := New()
+		app.Use(func(ctx *Context) error {
+			return ctx.HTML(200, "old")
+		})
+		errc := make(chan error, 1)
+		go func() {
+			errc <- app.ListenGraceful(context.Background(), addr)
+		}()
+		time.Sleep(100 * time.Millisecond)
+
+		res, err := RequestBy("GET", "http://"+addr)
+		assert.Nil(err)
+		assert.Equal("old", PickRes(res.Text()).(string))
+
+		assert.Nil(syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+		assert.Equal(http.ErrServerClosed, <-errc)
+
+		DefaultClient.CloseIdleConnections()
+		res, err = RequestBy("GET", "http://"+addr)
+		assert.Nil(err)
+		assert.Equal("restarted", PickRes(res.Text()).(string))
+	})
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package gear
+
+import (
+	"os"
+	"syscall"
+)
+
+// restartSignal triggers the zero downtime restart of ListenGraceful.
+var restartSignal os.Signal = syscall.SIGUSR2
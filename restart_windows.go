@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package gear
+
+import "os"
+
+// restartSignal is not supported on windows, ListenGraceful doesn't restart.
+var restartSignal os.Signal
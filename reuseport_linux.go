@@ -0,0 +1,34 @@
+package gear
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// soReusePort is SO_REUSEPORT socket option, syscall.SO_REUSEPORT is missing on some linux platforms.
+var soReusePort = 0xf
+
+func init() {
+	if strings.HasPrefix(runtime.GOARCH, "mips") {
+		soReusePort = 0x200
+	}
+}
+
+func listenReusePort(ctx context.Context, addr string) (net.Listener, error) {
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var err error
+			e := c.Control(func(fd uintptr) {
+				err = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+			})
+			if e != nil {
+				return e
+			}
+			return err
+		},
+	}
+	return lc.Listen(ctx, "tcp", addr)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package gear
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+func listenReusePort(ctx context.Context, addr string) (net.Listener, error) {
+	return nil, errors.New("SO_REUSEPORT is not supported on this platform")
+}
@@ -0,0 +1,67 @@
+package gear
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearAppListenReusePort(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Run("should return error", func(t *testing.T) {
+			assert.NotNil(t, New().ListenReusePort(context.Background(), "127.0.0.1:0", 2))
+		})
+		return
+	}
+
+	t.Run("should serve with multiple listeners", func(t *testing.T) {
+		assert := assert.New(t)
+
+		// pick a free port
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		addr := l.Addr().String()
+		l.Close()
+
+		app := New()
+		app.Use(func(ctx *Context) error {
+			return ctx.HTML(200, "OK")
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- app.ListenReusePort(ctx, addr, 4)
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		for i := 0; i < 10; i++ {
+			res, err := RequestBy("GET", "http://"+addr)
+			assert.Nil(err)
+			assert.Equal(200, res.StatusCode)
+			assert.Equal("OK", PickRes(res.Text()).(string))
+		}
+
+		cancel()
+		select {
+		case err := <-done:
+			assert.Equal(http.ErrServerClosed, err)
+		case <-time.After(time.Second):
+			t.Error("app should stop")
+		}
+	})
+
+	t.Run("should return error if failed to listen", func(t *testing.T) {
+		assert := assert.New(t)
+
+		// the listener without SO_REUSEPORT blocks the port
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		defer l.Close()
+		err := New().ListenReusePort(context.Background(), l.Addr().String(), 2)
+		assert.NotNil(err)
+		assert.Contains(err.Error(), "SO_REUSEPORT")
+	})
+}
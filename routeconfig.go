@@ -0,0 +1,196 @@
+package gear
+
+import (
+	"encoding/json"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+)
+
+// RouteConfig defines a route in declarative routes config.
+// One of Handler, Upstream and Upstreams should be set.
+type RouteConfig struct {
+	Method    string           `json:"method" yaml:"method"`
+	Path      string           `json:"path" yaml:"path"`
+	Handler   string           `json:"handler,omitempty" yaml:"handler,omitempty"`     // name of handler in ConfigRouterOptions.Handlers
+	Upstream  string           `json:"upstream,omitempty" yaml:"upstream,omitempty"`   // reverse proxy to the upstream URL
+	Upstreams []UpstreamTarget `json:"upstreams,omitempty" yaml:"upstreams,omitempty"` // load-balanced reverse proxy, see NewUpstream
+	Balance   BalanceStrategy  `json:"balance,omitempty" yaml:"balance,omitempty"`     // balance strategy of Upstreams
+}
+
+// RoutesConfig is the declarative routes config, such as:
+//
+//	{
+//		"routes": [
+//			{"method": "GET", "path": "/health", "handler": "health"},
+//			{"method": "GET", "path": "/users/:id", "upstream": "http://user-service:8080"},
+//			{"method": "GET", "path": "/orgs/:id", "balance": "least-conn", "upstreams": [
+//				{"url": "http://org-service-1:8080"},
+//				{"url": "http://org-service-2:8080"}
+//			]}
+//		]
+//	}
+type RoutesConfig struct {
+	Routes []RouteConfig `json:"routes" yaml:"routes"`
+}
+
+// ConfigRouterOptions is options for ConfigRouter.
+type ConfigRouterOptions struct {
+	RouterOptions
+
+	// File is the routes config file path, it will be read on every loading.
+	File string
+
+	// Unmarshal decodes the config file. Default to json.Unmarshal,
+	// set it to yaml.Unmarshal (such as "gopkg.in/yaml.v3") for a YAML config.
+	Unmarshal func(data []byte, v any) error
+
+	// Handlers is the named handlers that can be referenced by RouteConfig.Handler.
+	Handlers map[string]Middleware
+}
+
+// ConfigRouter is a Router built from declarative routes config. The config is loaded when created
+// and can be reloaded at runtime, a failed reloading keeps the current routes.
+//
+//	router, err := gear.NewConfigRouter(gear.ConfigRouterOptions{
+//		RouterOptions: gear.RouterOptions{Root: "/", IgnoreCase: true},
+//		File:          "./routes.json",
+//		Handlers:      map[string]gear.Middleware{"health": Health},
+//	})
+//	if err != nil {
+//		panic(err)
+//	}
+//	router.ReloadOnSignal(func(err error) { app.Error(err) }) // reload on SIGHUP
+//	app.UseHandler(router)
+type ConfigRouter struct {
+	opts   ConfigRouterOptions
+	router atomic.Pointer[Router]
+}
+
+// NewConfigRouter returns a ConfigRouter instance with the routes config loaded.
+func NewConfigRouter(opts ConfigRouterOptions) (*ConfigRouter, error) {
+	if ro := opts.RouterOptions; ro.Root == "" && !ro.IgnoreCase && !ro.FixedPathRedirect &&
+		!ro.TrailingSlashRedirect && ro.Preflight == nil {
+		opts.RouterOptions = defaultRouterOptions
+	}
+	if opts.Unmarshal == nil {
+		opts.Unmarshal = json.Unmarshal
+	}
+
+	r := &ConfigRouter{opts: opts}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the routes config file and replaces the current routes atomically.
+// The current routes will be kept if any error.
+func (r *ConfigRouter) Reload() error {
+	buf, err := os.ReadFile(filepath.FromSlash(r.opts.File))
+	if err != nil {
+		return Err.WithMsgf("read routes config failed: %v", err)
+	}
+
+	cfg := RoutesConfig{}
+	if err = r.opts.Unmarshal(buf, &cfg); err != nil {
+		return Err.WithMsgf("parse routes config failed: %v", err)
+	}
+
+	router, err := r.build(cfg)
+	if err != nil {
+		return err
+	}
+	r.router.Store(router)
+	return nil
+}
+
+// ReloadOnSignal reloads the routes config when receiving the signals, default to SIGHUP.
+// The onError will be called with the reloading error if not nil. It returns a function to stop it.
+func (r *ConfigRouter) ReloadOnSignal(onError func(error), sig ...os.Signal) (stop func()) {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGHUP}
+	}
+
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, sig...)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-signals:
+				if err := r.Reload(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// Serve implemented gear.Handler interface.
+func (r *ConfigRouter) Serve(ctx *Context) error {
+	return r.router.Load().Serve(ctx)
+}
+
+func (r *ConfigRouter) build(cfg RoutesConfig) (router *Router, err error) {
+	defer func() {
+		// trie.Define panics with invalid or conflict pattern
+		if e := recover(); e != nil {
+			router, err = nil, Err.WithMsgf("invalid routes config: %v", e)
+		}
+	}()
+
+	router = NewRouter(r.opts.RouterOptions)
+	for i, route := range cfg.Routes {
+		if route.Method == "" || route.Path == "" {
+			return nil, Err.WithMsgf("invalid route #%d: method and path are required", i)
+		}
+
+		var handler Middleware
+		switch {
+		case countTrue(route.Handler != "", route.Upstream != "", len(route.Upstreams) > 0) > 1:
+			return nil, Err.WithMsgf("invalid route #%d: only one of handler, upstream and upstreams should be set", i)
+		case route.Handler != "":
+			if handler = r.opts.Handlers[route.Handler]; handler == nil {
+				return nil, Err.WithMsgf("invalid route #%d: handler %q not found", i, route.Handler)
+			}
+		case route.Upstream != "":
+			u, err := url.Parse(route.Upstream)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return nil, Err.WithMsgf("invalid route #%d: invalid upstream %q", i, route.Upstream)
+			}
+			handler = WrapHandler(httputil.NewSingleHostReverseProxy(u))
+		case len(route.Upstreams) > 0:
+			up, err := NewUpstream(UpstreamOptions{Targets: route.Upstreams, Balance: route.Balance})
+			if err != nil {
+				return nil, Err.WithMsgf("invalid route #%d: %s", i, err.(*Error).Msg)
+			}
+			handler = up.Serve
+		default:
+			return nil, Err.WithMsgf("invalid route #%d: handler or upstream is required", i)
+		}
+		router.Handle(route.Method, route.Path, handler)
+	}
+	return router, nil
+}
+
+func countTrue(set ...bool) int {
+	n := 0
+	for _, ok := range set {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
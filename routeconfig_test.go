@@ -0,0 +1,149 @@
+package gear
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearConfigRouter(t *testing.T) {
+	upstream := New()
+	upstream.Use(func(ctx *Context) error {
+		return ctx.HTML(http.StatusOK, "upstream "+ctx.Path)
+	})
+	upSrv := upstream.Start()
+	defer upSrv.Close()
+
+	file := filepath.Join(t.TempDir(), "routes.json")
+	writeRoutes := func(routes string) {
+		assert.Nil(t, os.WriteFile(file, []byte(`{"routes": [`+routes+`]}`), 0644))
+	}
+	handlers := map[string]Middleware{
+		"hello": func(ctx *Context) error {
+			return ctx.HTML(http.StatusOK, "hello "+ctx.Param("name"))
+		},
+	}
+
+	t.Run("should load and reload routes", func(t *testing.T) {
+		assert := assert.New(t)
+
+		writeRoutes(`{"method": "GET", "path": "/hello/:name", "handler": "hello"}`)
+		router, err := NewConfigRouter(ConfigRouterOptions{File: file, Handlers: handlers})
+		assert.Nil(err)
+
+		app := New()
+		app.UseHandler(router)
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("GET", host+"/hello/gear")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("hello gear", PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host+"/api/users")
+		assert.Nil(err)
+		assert.Equal(421, res.StatusCode)
+		res.Body.Close()
+
+		writeRoutes(`{"method": "GET", "path": "/api/:path*", "upstream": "http://` + upSrv.Addr().String() + `"}`)
+		assert.Nil(router.Reload())
+
+		res, err = RequestBy("GET", host+"/api/users")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("upstream /api/users", PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host+"/hello/gear")
+		assert.Nil(err)
+		assert.Equal(421, res.StatusCode)
+		res.Body.Close()
+
+		writeRoutes(`{"method": "GET", "path": "/hello/:name", "handler": "unknown"}`)
+		err = router.Reload()
+		assert.NotNil(err)
+		assert.True(strings.Contains(err.Error(), `handler "unknown" not found`))
+
+		res, err = RequestBy("GET", host+"/api/users")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		res.Body.Close()
+	})
+
+	t.Run("should reload on signal", func(t *testing.T) {
+		assert := assert.New(t)
+
+		writeRoutes(`{"method": "GET", "path": "/hello/:name", "handler": "hello"}`)
+		router, err := NewConfigRouter(ConfigRouterOptions{File: file, Handlers: handlers})
+		assert.Nil(err)
+
+		errs := make(chan error, 1)
+		stop := router.ReloadOnSignal(func(err error) { errs <- err })
+		defer stop()
+
+		writeRoutes(`{"method": "GET", "path": "/hello/:name"}`)
+		p, err := os.FindProcess(os.Getpid())
+		assert.Nil(err)
+		assert.Nil(p.Signal(syscall.SIGHUP))
+		select {
+		case err := <-errs:
+			assert.True(strings.Contains(err.Error(), "handler or upstream is required"))
+		case <-time.After(time.Second):
+			t.Error("reloading not triggered")
+		}
+	})
+
+	t.Run("should load routes with load-balanced upstreams", func(t *testing.T) {
+		assert := assert.New(t)
+
+		writeRoutes(`{"method": "GET", "path": "/api/:path*", "balance": "least-conn", "upstreams": [{"url": "http://` +
+			upSrv.Addr().String() + `"}]}`)
+		router, err := NewConfigRouter(ConfigRouterOptions{File: file})
+		assert.Nil(err)
+
+		app := New()
+		app.UseHandler(router)
+		srv := app.Start()
+		defer srv.Close()
+
+		res, err := RequestBy("GET", "http://"+srv.Addr().String()+"/api/users")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("upstream /api/users", PickRes(res.Text()).(string))
+	})
+
+	t.Run("should return error with invalid config", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := NewConfigRouter(ConfigRouterOptions{File: file + ".none"})
+		assert.NotNil(err)
+
+		for _, routes := range []string{
+			`{"path": "/"}`,
+			`{"method": "GET", "path": "/", "handler": "hello", "upstream": "http://localhost"}`,
+			`{"method": "GET", "path": "/", "upstream": "localhost"}`,
+			`{"method": "GET", "path": "/", "upstream": "http://localhost", "upstreams": [{"url": "http://localhost"}]}`,
+			`{"method": "GET", "path": "/", "upstreams": [{"url": "http://localhost"}], "balance": "random"}`,
+			`{"method": "GET", "path": "/", "handler": "hello"}, {"method": "GET", "path": "/", "handler": "hello"}`,
+		} {
+			writeRoutes(routes)
+			_, err = NewConfigRouter(ConfigRouterOptions{File: file, Handlers: handlers})
+			assert.NotNil(err, routes)
+		}
+
+		assert.Nil(os.WriteFile(file, []byte("routes:\n"), 0644))
+		_, err = NewConfigRouter(ConfigRouterOptions{File: file})
+		assert.NotNil(err)
+		_, err = NewConfigRouter(ConfigRouterOptions{File: file, Unmarshal: func(data []byte, v any) error {
+			return nil
+		}})
+		assert.Nil(err)
+	})
+}
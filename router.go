@@ -1,9 +1,17 @@
 package gear
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/teambition/trie-mux"
 )
@@ -47,12 +55,13 @@ import (
 // request to that function.
 //
 // The registered path, against which the router matches incoming requests, can
-// contain six types of parameters:
+// contain seven types of parameters:
 //
 //	| Syntax | Description |
 //	|--------|------|
 //	| `:name` | named parameter |
 //	| `:name(regexp)` | named with regexp parameter |
+//	| `:name(constraint)` | named with typed constraint parameter |
 //	| `:name+suffix` | named parameter with suffix matching |
 //	| `:name(regexp)+suffix` | named with regexp parameter and suffix matching |
 //	| `:name*` | named with catch-all parameter |
@@ -99,6 +108,19 @@ import (
 //	/files/LICENSE                   matched: filepath="LICENSE"
 //	/files/templates/article.html    matched: filepath="templates/article.html"
 //
+// Named with typed constraint parameters are named parameters validated by the constraint function, the builtin
+// constraints are "int", "uint", "uuid" and "date" (such as "2006-01-02"), and more can be registered by
+// RegisterParamConstraint. They are simpler and faster than regexp for the common cases:
+//
+// Defined: `/api/users/:id<int>/posts/:date<date>`
+//
+//	/api/users/123/posts/2024-01-31    matched: id="123", date="2024-01-31"
+//	/api/users/abc/posts/2024-01-31    no match
+//	/api/users/123/posts/2024-13-01    no match
+//
+// The routes with typed constraints are tried in the registration order before the other routes, so they can
+// coexist with the routes of the same shape, such as `/items/:id<int>` and `/items/:slug`.
+//
 // The value of parameters is saved on the `Matched.Params`. Retrieve the value of a parameter by name:
 //
 //	type := matched.Params("type")
@@ -110,8 +132,74 @@ type Router struct {
 	rt         string
 	trie       *trie.Trie
 	otherwise  Middleware
+	preflight  Middleware
+	notAllowed Middleware
+	fallthru   bool
+	timeout    time.Duration
+	overrides  map[string]bool // the methods that POST requests can be overridden to
 	middleware Middleware
 	mds        []Middleware
+	names      map[string]string
+	last       string  // the last registered route pattern, for Name
+	parent     *Router // parent router of a group
+	prefix     string  // full path prefix of a group
+	params     []paramHandler
+	routes     []routeRecord // the routes registered on the router and its groups, for app.PrintRoutes
+	otherwises []*Router     // the groups with Otherwise handler, for the top router
+	// the routes with typed param constraints of the top router, they share the trie nodes with the other routes
+	constraints map[routeKey]*constrainedRoute
+	// the method-agnostic handlers of the mounted handlers of the top router, by node
+	mounts   map[*trie.Node]Middleware
+	trieOpts trie.Options
+	// the last registered route for Meta, and the route metadata of the top router
+	lastRoute routeKey
+	meta      map[routeKey]map[string]any
+}
+
+// constrainedRoute is a route with typed param constraints, the constraints are checked after the trie matched.
+type constrainedRoute struct {
+	pattern     string // the pattern with constraints, relative to the router root
+	constraints []paramConstraint
+}
+
+type paramConstraint struct {
+	param string
+	fn    func(val string) bool
+}
+
+type paramHandler struct {
+	name string
+	fn   func(ctx *Context, val string) error
+}
+
+// routeKey is a route of the top router, its metadata is looked up by the matched node and method.
+type routeKey struct {
+	node   *trie.Node
+	method string
+}
+
+type routeRecord struct {
+	method  string
+	pattern string // relative to the router root
+	handler string
+	mds     func() int // counts the middlewares when printing, they may be added after the route
+}
+
+// top returns the top router of a group.
+func (r *Router) top() *Router {
+	for r.parent != nil {
+		r = r.parent
+	}
+	return r
+}
+
+// countMds returns the number of middlewares of the group and its ancestor groups.
+func (r *Router) countMds() int {
+	n := 0
+	for ; r != nil; r = r.parent {
+		n += len(r.mds)
+	}
+	return n
 }
 
 // RouterOptions is options for Router
@@ -136,6 +224,71 @@ type RouterOptions struct {
 	// client is redirected to "/foo"" with http status code 301 for GET requests
 	// and 307 for all other request methods.
 	TrailingSlashRedirect bool
+
+	// Preflight will be called when the router auto-answers OPTIONS request for a matched route
+	// without OPTIONS handler, after the "Allow" header is set. It is useful to emit CORS preflight headers,
+	// such as Access-Control-Allow-* and Access-Control-Max-Age, so preflight requests don't need to
+	// flow through the whole middleware chain:
+	//
+	//	router := gear.NewRouter(gear.RouterOptions{
+	//		Root:       "/api",
+	//		IgnoreCase: true,
+	//		Preflight:  cors.New(cors.Options{MaxAge: time.Hour}),
+	//	})
+	//
+	// The router responds 204 if Preflight doesn't end the response.
+	Preflight Middleware
+
+	// MethodNotAllowed will be called when the path of a route is matched but not the method, after the "Allow"
+	// header is set, instead of the Otherwise handler. It is useful to customize the 405 response:
+	//
+	//	router := gear.NewRouter(gear.RouterOptions{
+	//		MethodNotAllowed: func(ctx *gear.Context) error {
+	//			return ctx.JSON(405, map[string]any{"allow": ctx.Res.Header().Values(gear.HeaderAllow)})
+	//		},
+	//	})
+	//
+	// The router responds 405 error if MethodNotAllowed doesn't end the response. Use the Otherwise handler to
+	// customize the response of the unmatched path, and the SetUnhandledError setting of app to replace
+	// the 421 response when no router matched.
+	MethodNotAllowed Middleware
+
+	// Fallthrough passes the requests that the router can't handle to the following middlewares,
+	// the router responds 405 Method Not Allowed for the matched path without handler of the method
+	// by default. The unmatched requests always fall through, register an Otherwise handler to claim them:
+	//
+	//	apiRouter := gear.NewRouter(gear.RouterOptions{Root: "/api"})
+	//	apiRouter.Otherwise(func(ctx *gear.Context) error {
+	//		return gear.ErrNotFound.WithMsgf("%s %s not found", ctx.Method, ctx.Path)
+	//	})
+	//	viewRouter := gear.NewRouter(gear.RouterOptions{Fallthrough: true})
+	//
+	//	app.UseHandler(apiRouter)
+	//	app.UseHandler(viewRouter)
+	//	app.Use(static.New(static.Options{Root: "./public"}))
+	Fallthrough bool
+
+	// Timeout replaces the app's timeout (the SetTimeout setting) for the requests handled by the router,
+	// a negative Timeout removes the deadline. Use gear.Timeout for the routes and groups:
+	//
+	//	router := gear.NewRouter(gear.RouterOptions{Root: "/api", Timeout: 10 * time.Second})
+	//	router.Post("/upload", gear.Timeout(10*time.Minute), upload)
+	//	events := router.Group("/events", gear.Timeout(-1)) // SSE and long-polling, no deadline
+	//
+	// Optional. Default to 0, the app's timeout is used.
+	Timeout time.Duration
+
+	// MethodOverride defines the methods that the POST requests can be overridden to, by the
+	// "X-HTTP-Method-Override" header or the "_method" field of the form body (application/x-www-form-urlencoded,
+	// up to 64KB), for the HTML forms and the clients that can't send PUT, PATCH or DELETE requests.
+	// The ctx.Method is rewritten only if a route of the overridden method matches, otherwise the request is
+	// routed as POST. The form body is kept for the handlers:
+	//
+	//	router := gear.NewRouter(gear.RouterOptions{MethodOverride: []string{"PUT", "PATCH", "DELETE"}})
+	//	router.Delete("/posts/:id", DeletePost) // <form method="POST"><input type="hidden" name="_method" value="DELETE">
+	//
+	// Optional. Default to nil, no method override.
+	MethodOverride []string
 }
 
 var defaultRouterOptions = RouterOptions{
@@ -179,15 +332,30 @@ func NewRouter(routerOptions ...RouterOptions) *Router {
 		opts.Root += "/"
 	}
 
+	trieOpts := trie.Options{
+		IgnoreCase:            opts.IgnoreCase,
+		FixedPathRedirect:     opts.FixedPathRedirect,
+		TrailingSlashRedirect: opts.TrailingSlashRedirect,
+	}
+	var overrides map[string]bool
+	for _, method := range opts.MethodOverride {
+		if overrides == nil {
+			overrides = make(map[string]bool)
+		}
+		overrides[strings.ToUpper(method)] = true
+	}
+
 	return &Router{
-		root: opts.Root,
-		rt:   opts.Root[0 : len(opts.Root)-1],
-		mds:  make([]Middleware, 0),
-		trie: trie.New(trie.Options{
-			IgnoreCase:            opts.IgnoreCase,
-			FixedPathRedirect:     opts.FixedPathRedirect,
-			TrailingSlashRedirect: opts.TrailingSlashRedirect,
-		}),
+		root:       opts.Root,
+		rt:         opts.Root[0 : len(opts.Root)-1],
+		mds:        make([]Middleware, 0),
+		preflight:  opts.Preflight,
+		notAllowed: opts.MethodNotAllowed,
+		fallthru:   opts.Fallthrough,
+		timeout:    opts.Timeout,
+		overrides:  overrides,
+		trie:       trie.New(trieOpts),
+		trieOpts:   trieOpts,
 	}
 }
 
@@ -204,18 +372,271 @@ func (r *Router) Use(handle Middleware) *Router {
 //
 // This function is intended for bulk loading and to allow the usage of less
 // frequently used, non-standardized or custom methods (e.g. for internal
-// communication with a proxy).
+// communication with a proxy), such as gear.MethodPropfind, gear.MethodReport and gear.MethodPurge.
+// The method should be a valid HTTP token, it is case-insensitive and will be converted to upper case.
 func (r *Router) Handle(method, pattern string, handlers ...Middleware) *Router {
-	if method == "" {
-		panic(Err.WithMsg("invalid method"))
+	if !isToken(method) {
+		panic(Err.WithMsgf("invalid method %q", method))
 	}
 	if len(handlers) == 0 {
 		panic(Err.WithMsg("invalid middleware"))
 	}
-	r.trie.Define(pattern).Handle(strings.ToUpper(method), Compose(handlers...))
+	method = strings.ToUpper(method)
+	top := r.top()
+	node := top.handle(method, r.prefix+pattern, r.wrap(Compose(handlers...)))
+	r.last = pattern
+	r.lastRoute = routeKey{node, method}
+
+	top.routes = append(top.routes, routeRecord{
+		method:  method,
+		pattern: r.prefix + pattern,
+		handler: MiddlewareName(handlers[len(handlers)-1]),
+		mds:     func() int { return r.countMds() + len(handlers) - 1 },
+	})
+	return r
+}
+
+// Group returns a router group with the path prefix and middlewares. The group shares routes
+// and options with the router, and its routes inherit the prefix and middlewares of all ancestors.
+// Middlewares registered by group.Use will run only for the routes of the group (and sub-groups),
+// after the middlewares of the parent. The Otherwise and Param handlers of the group are scoped to the group too.
+// Don't use the group with app.UseHandler, use the top router.
+//
+//	router := gear.NewRouter(gear.RouterOptions{Root: "/api"})
+//	v1 := router.Group("/v1", auth)
+//	v1.Get("/users", listUsers) // GET /api/v1/users
+//	admin := v1.Group("/admin", requireAdmin)
+//	admin.Delete("/users/:id", deleteUser) // DELETE /api/v1/admin/users/:id, runs auth and requireAdmin
+//	app.UseHandler(router)
+func (r *Router) Group(prefix string, mds ...Middleware) *Router {
+	if !strings.HasPrefix(prefix, "/") {
+		panic(Err.WithMsgf("invalid group prefix %q", prefix))
+	}
+	g := &Router{
+		root:   r.root,
+		rt:     r.rt,
+		trie:   r.trie,
+		mds:    make([]Middleware, 0),
+		parent: r,
+		prefix: r.prefix + strings.TrimSuffix(prefix, "/"),
+	}
+	for _, md := range mds {
+		g.Use(md)
+	}
+	return g
+}
+
+// Timeout returns a middleware that replaces the app's timeout (the SetTimeout setting) and the router's
+// timeout with the given timeout from now, a zero or negative timeout removes the deadline. Use it as the first
+// handler of a route or the middleware of a group, for the endpoints need longer (or shorter) time than others:
+//
+//	router.Post("/upload", gear.Timeout(10*time.Minute), upload)
+//	router.Get("/events", gear.Timeout(-1), sse) // no deadline
+//	slow := router.Group("/reports", gear.Timeout(time.Minute))
+func Timeout(timeout time.Duration) Middleware {
+	return func(ctx *Context) error {
+		ctx.SetTimeout(timeout)
+		return nil
+	}
+}
+
+// Include merges the routes of the other router (including its groups) into the router, so routes can be defined
+// next to their feature packages and composed at startup. The other router's root is used as the path prefix,
+// and its middlewares and param handlers run for its routes only, after the middlewares of the router:
+//
+//	// package user
+//	func Routes() *gear.Router {
+//		router := gear.NewRouter(gear.RouterOptions{Root: "/users"})
+//		router.Use(loadSession)
+//		router.Get("/:id", GetUser).Name("user.show")
+//		return router
+//	}
+//
+//	// package main
+//	api := gear.NewRouter(gear.RouterOptions{Root: "/api"})
+//	api.Use(auth)
+//	api.Include(user.Routes()) // GET /api/users/:id, runs auth and loadSession
+//	api.URL("user.show", 123)  // "/api/users/123"
+//
+// The route names are merged with the path prefix, and duplicate routes or names will panic.
+// The other router's options, Otherwise and Preflight handlers are not included,
+// and the routes registered on the other router after Include are not included too.
+func (r *Router) Include(other *Router) *Router {
+	if other == nil || other == r || other.parent != nil || other.trie == r.trie {
+		panic(Err.WithMsg("invalid router to include"))
+	}
+	top := r.top()
+	for _, node := range other.trie.GetEndpoints() {
+		if h := other.mounts[node]; h != nil {
+			pattern := node.GetPattern()
+			if cr := other.constraints[routeKey{node, mountMethod}]; cr != nil {
+				pattern = cr.pattern
+			}
+			top.mount(r.prefix+other.rt+pattern, r.wrap(other.included(h)))
+		}
+		if node.GetAllow() == "" {
+			continue // only the mounted handler
+		}
+		for _, method := range strings.Split(node.GetAllow(), ", ") {
+			pattern := node.GetPattern()
+			if cr := other.constraints[routeKey{node, method}]; cr != nil {
+				pattern = cr.pattern
+			}
+			n := top.handle(method, r.prefix+other.rt+pattern, r.wrap(other.included(node.GetHandler(method).(Middleware))))
+			for key, val := range other.meta[routeKey{node, method}] {
+				top.setMeta(routeKey{n, method}, key, val)
+			}
+		}
+	}
+	for name, pattern := range other.names {
+		r.Name(name, other.rt+pattern)
+	}
+
+	for _, route := range other.routes {
+		route := route
+		top.routes = append(top.routes, routeRecord{
+			method:  route.method,
+			pattern: r.prefix + other.rt + route.pattern,
+			handler: route.handler,
+			mds:     func() int { return r.countMds() + route.mds() },
+		})
+	}
+	return r
+}
+
+// included wraps the route handler to run the router's middlewares and param handlers when it is included,
+// or the group's when the route is registered on a router group.
+// The middlewares are read when serving, so router.Use works after Include.
+func (r *Router) included(handler Middleware) Middleware {
+	return func(ctx *Context) error {
+		h := handler
+		if len(r.params) > 0 {
+			if params := CtxValue[State](ctx).RouterMatched.Params; len(params) > 0 {
+				h = r.withParams(params, h)
+			}
+		}
+		if r.middleware == nil {
+			return h(ctx)
+		}
+		return middlewares{r.middleware, h}.run(ctx)
+	}
+}
+
+// mountParam is the catch-all parameter of the mounted handlers, it is removed from the params when serving.
+// mountMethod is the method of the mounted handlers in the routes and the constraints, they handle all methods.
+const (
+	mountParam  = "_mount"
+	mountMethod = "*"
+)
+
+// Mount mounts h under the path prefix, h can be a *Router, a gear.Handler or a http.Handler, so the third-party
+// handlers (such as pprof, expvar or a gRPC-gateway mux) can live inside gear routing:
+//
+//	router := gear.NewRouter(gear.RouterOptions{Root: "/api"})
+//	router.Mount("/admin", adminRouter)           // same as router.Group("/admin").Include(adminRouter)
+//	router.Mount("/ops", gear.NewHealth())         // GET /api/ops/healthz and /api/ops/readyz
+//	router.Mount("/debug/vars", expvar.Handler())  // GET /api/debug/vars
+//	router.Mount("/tenants/:tenant/v1", gwmux)     // the requests of "/api/tenants/:tenant/v1" and its sub paths
+//
+// A *Router is included under the prefix, see Include. The other handlers serve the requests of the prefix and its
+// sub paths in all methods (including WebDAV methods such as PROPFIND, and the custom methods) that are not
+// handled by the routes of the same pattern, with the router root and the prefix stripped from the path: ctx.Path for a gear.Handler, and the URL path of a shallow copy of the request
+// for a http.Handler. The parameters of the prefix can be read by ctx.Param or gear.ParamsFromCtx(req.Context()).
+func (r *Router) Mount(prefix string, h any) *Router {
+	if !strings.HasPrefix(prefix, "/") {
+		panic(Err.WithMsgf("invalid mount prefix %q", prefix))
+	}
+	var handler Middleware
+	switch v := h.(type) {
+	case *Router:
+		r.Group(prefix).Include(v)
+		return r
+	case Handler:
+		handler = func(ctx *Context) error {
+			path := ctx.Path
+			ctx.Path = mountPath(ctx)
+			defer func() { ctx.Path = path }()
+			return v.Serve(ctx)
+		}
+	case http.Handler:
+		handler = func(ctx *Context) error {
+			req := new(http.Request)
+			*req = *ctx.Req
+			req.URL = new(url.URL)
+			*req.URL = *ctx.Req.URL
+			req.URL.Path = mountPath(ctx)
+			req.URL.RawPath = ""
+			v.ServeHTTP(ctx.Res, req)
+			return nil
+		}
+	default:
+		panic(Err.WithMsgf("invalid handler %T to mount", h))
+	}
+
+	prefix = strings.TrimSuffix(prefix, "/")
+	top := r.top()
+	for _, pattern := range []string{prefix, prefix + "/:" + mountParam + "*"} {
+		if pattern == "" {
+			pattern = "/"
+		}
+		top.mount(r.prefix+pattern, r.wrap(handler))
+	}
+	top.routes = append(top.routes, routeRecord{
+		method:  mountMethod,
+		pattern: r.prefix + prefix + "/*",
+		handler: fmt.Sprintf("%T", h),
+		mds:     r.countMds,
+	})
 	return r
 }
 
+// mount defines the method-agnostic handler of the mounted handler on the top router.
+func (r *Router) mount(pattern string, handler Middleware) {
+	path, constraints := parseParamConstraints(pattern)
+	node := r.trie.Define(path)
+	if r.mounts[node] != nil {
+		panic(Err.WithMsgf("%q already mounted", pattern))
+	}
+	if r.mounts == nil {
+		r.mounts = make(map[*trie.Node]Middleware)
+	}
+	r.mounts[node] = handler
+	if len(constraints) > 0 {
+		if r.constraints == nil {
+			r.constraints = make(map[routeKey]*constrainedRoute)
+		}
+		r.constraints[routeKey{node, mountMethod}] = &constrainedRoute{pattern: pattern, constraints: constraints}
+	}
+}
+
+// mountPath returns the path under the mount prefix, the matched params are replaced with a copy without
+// the catch-all parameter.
+func mountPath(ctx *Context) string {
+	state := CtxValue[State](ctx)
+	if state == nil || state.RouterMatched == nil {
+		return "/"
+	}
+	rest := state.RouterMatched.Params[mountParam]
+	matched := *state.RouterMatched
+	matched.Params = make(map[string]string, len(state.RouterMatched.Params))
+	for key, val := range state.RouterMatched.Params {
+		if key != mountParam {
+			matched.Params[key] = val
+		}
+	}
+	state.RouterMatched = &matched
+	return "/" + rest
+}
+
+// wrap wraps the route handler with the middlewares of the group and its ancestor groups.
+// The middlewares are read when serving, so group.Use works after routes registered.
+func (r *Router) wrap(handler Middleware) Middleware {
+	if r.parent == nil {
+		return handler // the top router's middlewares run in router.Serve
+	}
+	return r.parent.wrap(r.included(handler))
+}
+
 // Get registers a new GET route for a path with matching handler in the router.
 func (r *Router) Get(pattern string, handlers ...Middleware) *Router {
 	return r.Handle(http.MethodGet, pattern, handlers...)
@@ -253,14 +674,249 @@ func (r *Router) Options(pattern string, handlers ...Middleware) *Router {
 
 // Otherwise registers a new Middleware handler in the router
 // that will run if there is no other handler matching.
+// The Otherwise handler of a router group runs for the requests under the group prefix only, after the middlewares
+// of the group, and the handler of the deepest group wins:
+//
+//	router := gear.NewRouter(gear.RouterOptions{Root: "/api"})
+//	router.Otherwise(notFound)
+//	v1 := router.Group("/v1", auth)
+//	v1.Otherwise(notFoundV1) // for "/api/v1" and "/api/v1/*", runs auth
 func (r *Router) Otherwise(handlers ...Middleware) *Router {
 	if len(handlers) == 0 {
 		panic(Err.WithMsg("invalid middleware"))
 	}
-	r.otherwise = Compose(handlers...)
+	if r.parent == nil {
+		r.otherwise = Compose(handlers...)
+		return r
+	}
+	if r.otherwise == nil {
+		top := r.top()
+		top.otherwises = append(top.otherwises, r)
+	}
+	r.otherwise = r.wrap(Compose(handlers...))
 	return r
 }
 
+// otherwiseFor returns the Otherwise handler of the deepest group with the path (relative to the router root)
+// prefix, or the router's Otherwise handler.
+func (r *Router) otherwiseFor(path string) Middleware {
+	handler := r.otherwise
+	depth := -1
+	for _, g := range r.otherwises {
+		prefix := g.prefix
+		p := path
+		if r.trieOpts.IgnoreCase {
+			prefix, p = strings.ToLower(prefix), strings.ToLower(p)
+		}
+		if len(prefix) > depth && (p == prefix || strings.HasPrefix(p, prefix+"/")) {
+			handler, depth = g.otherwise, len(prefix)
+		}
+	}
+	return handler
+}
+
+// Param registers a handler for the route parameter name. It runs once per request when the matched route pattern
+// contains the parameter (such as ":id" for "id"), after the router middlewares and before the route handlers
+// (including the middlewares of router groups), so the loading and validation of the parameter can be shared:
+//
+//	router.Param("id", func(ctx *gear.Context, id string) error {
+//		user, err := loadUser(ctx, id)
+//		if err != nil {
+//			return gear.ErrNotFound.From(err)
+//		}
+//		gear.CtxValue[gear.State](ctx).KV[userKey] = user
+//		return nil
+//	})
+//	router.Get("/users/:id", GetUser)
+//	router.Put("/users/:id", UpdateUser)
+//
+// If several parameters are matched, their handlers run in the registration order.
+// The rest handlers will not run if a handler returns error or ends the ctx.
+// The Param handlers of a router group run for the routes of the group only, after the middlewares of the group.
+func (r *Router) Param(name string, fn func(ctx *Context, val string) error) *Router {
+	if name == "" || fn == nil {
+		panic(Err.WithMsg("invalid param handler"))
+	}
+	for _, p := range r.params {
+		if p.name == name {
+			panic(Err.WithMsgf("param handler %q exists", name))
+		}
+	}
+	r.params = append(r.params, paramHandler{name, fn})
+	return r
+}
+
+// withParams wraps the handler to run the param handlers for the matched params.
+func (r *Router) withParams(params map[string]string, handler Middleware) Middleware {
+	return func(ctx *Context) error {
+		for _, p := range r.params {
+			if val, ok := params[p.name]; ok {
+				if err := p.fn(ctx, val); err != nil || ctx.Res.ended.isTrue() {
+					return err
+				}
+			}
+		}
+		return handler(ctx)
+	}
+}
+
+// Name names a route pattern, so that the URL of the route can be reversed by router.URL.
+// The pattern can be omitted to name the last route registered on the router.
+// The pattern of a router group is relative to the group prefix, and the name is shared with the top router.
+//
+//	router.Get("/users/:id", GetUser).Name("user.show")
+//	router.Name("file", "/files/:dir/:filepath*")
+//	router.URL("user.show", 123) // "/users/123"
+func (r *Router) Name(name string, pattern ...string) *Router {
+	p := r.last
+	if len(pattern) > 0 {
+		p = pattern[0]
+	} else if p == "" {
+		panic(Err.WithMsgf("no route to name %q", name))
+	}
+	if r.parent != nil {
+		r.parent.Name(name, r.prefix[len(r.parent.prefix):]+p)
+		return r
+	}
+	if name == "" {
+		panic(Err.WithMsg("invalid route name"))
+	}
+	if r.names == nil {
+		r.names = make(map[string]string)
+	}
+	if _, ok := r.names[name]; ok {
+		panic(Err.WithMsgf("route name %q defined already", name))
+	}
+	r.names[name] = p
+	return r
+}
+
+// Meta attaches the metadata key/value to the last route registered on the router, so the middlewares
+// (such as authz, docs or metrics) can make decisions by the declarative route attributes.
+// The metadata of the matched route can be read by ctx.RouteMeta or gear.RouteMetaFromCtx,
+// including in the middlewares of the router (router.Use).
+//
+//	router.Use(func(ctx *gear.Context) error {
+//		if role, ok := ctx.RouteMeta("auth").(string); ok {
+//			return checkRole(ctx, role)
+//		}
+//		return nil
+//	})
+//	router.Delete("/users/:id", DeleteUser).Meta("auth", "admin").Meta("doc", "Delete a user")
+func (r *Router) Meta(key string, val any) *Router {
+	if r.lastRoute.node == nil {
+		panic(Err.WithMsgf("no route to attach metadata %q", key))
+	}
+	r.top().setMeta(r.lastRoute, key, val)
+	return r
+}
+
+func (r *Router) setMeta(route routeKey, key string, val any) {
+	if r.meta == nil {
+		r.meta = make(map[routeKey]map[string]any)
+	}
+	if r.meta[route] == nil {
+		r.meta[route] = make(map[string]any)
+	}
+	r.meta[route][key] = val
+}
+
+// URL returns the URL path of the named route with params in order, the router's root is included.
+// A catch-all parameter will not be escaped.
+//
+//	router := gear.NewRouter(gear.RouterOptions{Root: "/api"})
+//	router.Get("/files/:dir/:filepath*", GetFile).Name("file")
+//	router.URL("file", "docs", "a/b.md") // "/api/files/docs/a/b.md"
+func (r *Router) URL(name string, params ...any) (string, error) {
+	i := 0
+	path, err := r.reverse(name, func(string) (string, error) {
+		if i >= len(params) {
+			return "", Err.WithMsgf("route %q: missing params, expected more than %d", name, len(params))
+		}
+		i++
+		return fmt.Sprint(params[i-1]), nil
+	})
+	if err == nil && i != len(params) {
+		return "", Err.WithMsgf("route %q: too many params, expected %d", name, i)
+	}
+	return path, err
+}
+
+// URLWith returns the URL of the named route with the params by name and the query,
+// the router's root is included. A catch-all parameter will not be escaped.
+//
+//	router := gear.NewRouter(gear.RouterOptions{Root: "/api"})
+//	router.Get("/users/:id/posts", ListPosts).Name("user.posts")
+//	router.URLWith("user.posts", map[string]string{"id": "123"}, url.Values{"page": {"2"}})
+//	// "/api/users/123/posts?page=2"
+func (r *Router) URLWith(name string, params map[string]string, query url.Values) (string, error) {
+	used := 0
+	path, err := r.reverse(name, func(key string) (string, error) {
+		val, ok := params[key]
+		if !ok {
+			return "", Err.WithMsgf("route %q: missing param %q", name, key)
+		}
+		used++
+		return val, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if used != len(params) {
+		return "", Err.WithMsgf("route %q: unknown params, expected %d", name, used)
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path, nil
+}
+
+// reverse builds the URL path of the named route, with the param value returned by param func.
+func (r *Router) reverse(name string, param func(key string) (string, error)) (string, error) {
+	if r.parent != nil {
+		return r.parent.reverse(name, param)
+	}
+	pattern, ok := r.names[name]
+	if !ok {
+		return "", Err.WithMsgf("route %q not found", name)
+	}
+
+	segments := strings.Split(pattern, "/")
+	for j, seg := range segments {
+		if seg == "" || seg[0] != ':' {
+			continue
+		}
+		if strings.HasPrefix(seg, "::") {
+			segments[j] = seg[1:]
+			continue
+		}
+
+		key := seg[1:]
+		rest := ""
+		if k := strings.IndexAny(key, "(<+*"); k >= 0 {
+			key, rest = key[:k], key[k:]
+		}
+		if strings.HasPrefix(rest, "(") {
+			rest = rest[strings.LastIndex(rest, ")")+1:]
+		} else if strings.HasPrefix(rest, "<") {
+			rest = rest[strings.IndexByte(rest, '>')+1:]
+		}
+		val, err := param(key)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case rest == "*":
+			segments[j] = val
+		case strings.HasPrefix(rest, "+"):
+			segments[j] = url.PathEscape(val) + rest[1:]
+		default:
+			segments[j] = url.PathEscape(val)
+		}
+	}
+	return r.rt + strings.Join(segments, "/"), nil
+}
+
 // Serve implemented gear.Handler interface
 func (r *Router) Serve(ctx *Context) error {
 	path := ctx.Path
@@ -277,7 +933,16 @@ func (r *Router) Serve(ctx *Context) error {
 		path = path[l:]
 	}
 
-	matched := r.trie.Match(path)
+	matched, pattern := r.match(path, method)
+	if r.overrides != nil && method == http.MethodPost {
+		if override := r.overrideMethod(ctx); override != "" {
+			if m, p := r.match(path, override); m.Node != nil && m.Node.GetHandler(override) != nil {
+				matched, pattern, method = m, p, override
+				ctx.Method = method
+				ctx.Req.Method = method
+			}
+		}
+	}
 
 	if matched.Node == nil {
 		// FixedPathRedirect or TrailingSlashRedirect
@@ -298,25 +963,41 @@ func (r *Router) Serve(ctx *Context) error {
 			return ctx.Redirect(ctx.Req.URL.String())
 		}
 
-		if r.otherwise == nil {
+		if handler = r.otherwiseFor(path); handler == nil {
 			return nil
 		}
-		handler = r.otherwise
 	} else {
 		ok := false
 		if handler, ok = matched.Node.GetHandler(method).(Middleware); !ok {
+			handler, ok = r.mounts[matched.Node]
+		}
+		if !ok {
 			// OPTIONS support
 			if method == http.MethodOptions {
 				ctx.SetHeader(HeaderAllow, matched.Node.GetAllow())
+				if r.preflight != nil {
+					if err := r.preflight(ctx); err != nil || ctx.Res.ended.isTrue() {
+						return err
+					}
+				}
 				return ctx.End(http.StatusNoContent)
 			}
 
-			if r.otherwise == nil {
+			if r.notAllowed != nil {
+				ctx.SetHeader(HeaderAllow, matched.Node.GetAllow())
+				if err := r.notAllowed(ctx); err != nil || ctx.Res.ended.isTrue() {
+					return err
+				}
+				return ErrMethodNotAllowed.WithMsgf(`"%s" is not allowed in "%s"`, method, ctx.Path)
+			}
+			if handler = r.otherwiseFor(path); handler == nil {
+				if r.fallthru {
+					return nil
+				}
 				// If no route handler is returned, it's a 405 error
 				ctx.SetHeader(HeaderAllow, matched.Node.GetAllow())
 				return ErrMethodNotAllowed.WithMsgf(`"%s" is not allowed in "%s"`, method, ctx.Path)
 			}
-			handler = r.otherwise
 		}
 	}
 
@@ -327,12 +1008,212 @@ func (r *Router) Serve(ctx *Context) error {
 
 	state.RouterPrefix = r.rt
 	state.RouterMatched = matched
+	state.routerPattern = pattern
+	state.routeMeta = r.meta[routeKey{matched.Node, method}]
+	if r.timeout != 0 {
+		ctx.SetTimeout(r.timeout)
+	}
+	if len(r.params) > 0 && len(matched.Params) > 0 {
+		handler = r.withParams(matched.Params, handler)
+	}
 	if len(r.mds) > 0 {
 		handler = Compose(r.middleware, handler)
 	}
 	return handler(ctx)
 }
 
+var paramConstraints = struct {
+	sync.RWMutex
+	m map[string]func(val string) bool
+}{m: map[string]func(val string) bool{
+	"int": func(val string) bool {
+		_, err := strconv.ParseInt(val, 10, 64)
+		return err == nil
+	},
+	"uint": func(val string) bool {
+		_, err := strconv.ParseUint(val, 10, 64)
+		return err == nil
+	},
+	"uuid": isUUID,
+	"date": func(val string) bool {
+		_, err := time.Parse("2006-01-02", val)
+		return err == nil
+	},
+}}
+
+// RegisterParamConstraint registers a typed constraint for the route parameters, such as ":code<country>".
+// The constraint follows the parameter name, before the regexp and suffix, such as ":id<uint>+.json".
+// The route parameter matches only if fn returns true with its value, otherwise the route is not matched,
+// and the request is handled by the Otherwise handler or the following middlewares. The constraints are
+// checked for the route of the request method after the path matched, so the routes of the same shape
+// (such as "GET /users/:id<int>" and "DELETE /users/:id") share the parameter name.
+// The constraints should be registered before defining the routes, the route with an unknown constraint
+// will panic. The builtin constraints "int", "uint", "uuid" and "date" can be replaced.
+//
+//	gear.RegisterParamConstraint("country", func(val string) bool {
+//		return len(val) == 2 && strings.ToUpper(val) == val
+//	})
+//	router.Get("/countries/:code<country>", GetCountry)
+func RegisterParamConstraint(name string, fn func(val string) bool) {
+	if name == "" || fn == nil {
+		panic(Err.WithMsg("invalid param constraint"))
+	}
+	paramConstraints.Lock()
+	defer paramConstraints.Unlock()
+	paramConstraints.m[name] = fn
+}
+
+func lookupParamConstraint(name string) func(val string) bool {
+	paramConstraints.RLock()
+	defer paramConstraints.RUnlock()
+	return paramConstraints.m[name]
+}
+
+// parseParamConstraints removes the typed constraints from the pattern for the trie, and returns them.
+// The constraint is the "<name>" right after the parameter name, the "<" in the regexp is not a constraint.
+func parseParamConstraints(pattern string) (string, []paramConstraint) {
+	var constraints []paramConstraint
+	segments := strings.Split(pattern, "/")
+	for i, seg := range segments {
+		if !strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "::") {
+			continue
+		}
+		start := 1
+		for start < len(seg) && isWordByte(seg[start]) {
+			start++
+		}
+		if start == len(seg) || seg[start] != '<' {
+			continue
+		}
+		end := strings.IndexByte(seg[start:], '>') + start
+		if end < start {
+			panic(Err.WithMsgf("invalid param constraint in pattern %q", pattern))
+		}
+		fn := lookupParamConstraint(seg[start+1 : end])
+		if fn == nil {
+			panic(Err.WithMsgf("unknown param constraint %q in pattern %q", seg[start+1:end], pattern))
+		}
+		constraints = append(constraints, paramConstraint{seg[1:start], fn})
+		segments[i] = seg[:start] + seg[end+1:]
+	}
+	return strings.Join(segments, "/"), constraints
+}
+
+// isWordByte reports whether c is a character of the parameter name, as the trie accepts.
+func isWordByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
+
+// handle defines the route on the top router, the typed constraints are removed from the pattern
+// for the trie, and kept by the node and method.
+func (r *Router) handle(method, pattern string, handler Middleware) *trie.Node {
+	path, constraints := parseParamConstraints(pattern)
+	node := r.trie.Define(path)
+	node.Handle(method, handler)
+	if len(constraints) > 0 {
+		if r.constraints == nil {
+			r.constraints = make(map[routeKey]*constrainedRoute)
+		}
+		r.constraints[routeKey{node, method}] = &constrainedRoute{pattern: pattern, constraints: constraints}
+	}
+	return node
+}
+
+// match matches the path with the routes, and checks the typed constraints of the route for the method,
+// returns the matched result and the pattern of the matched constrained route. The mismatched constraints
+// are the same as the path not matched.
+func (r *Router) match(path, method string) (*trie.Matched, string) {
+	matched := r.trie.Match(path)
+	if matched.Node == nil || len(r.constraints) == 0 {
+		return matched, ""
+	}
+	cr := r.constraints[routeKey{matched.Node, method}]
+	if cr == nil && matched.Node.GetHandler(method) == nil {
+		cr = r.constraints[routeKey{matched.Node, mountMethod}]
+	}
+	if cr == nil {
+		return matched, ""
+	}
+	if !cr.check(matched.Params) {
+		return &trie.Matched{}, ""
+	}
+	return matched, cr.pattern
+}
+
+// check checks the matched params by the typed constraints of the route.
+func (cr *constrainedRoute) check(params map[string]string) bool {
+	for _, c := range cr.constraints {
+		if !c.fn(params[c.param]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isUUID(val string) bool {
+	if len(val) != 36 {
+		return false
+	}
+	for i := 0; i < 36; i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if val[i] != '-' {
+				return false
+			}
+		default:
+			c := val[i]
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// overrideMethod returns the overridden method of the POST request by the X-HTTP-Method-Override header
+// or the "_method" form field, and returns the method.
+func (r *Router) overrideMethod(ctx *Context) string {
+	method := ctx.GetHeader(HeaderXHTTPMethodOverride)
+	if method == "" {
+		method = formMethod(ctx)
+	}
+	if method = strings.ToUpper(method); r.overrides[method] {
+		return method
+	}
+	return ""
+}
+
+// maxOverrideFormBytes is the max size of the form body to read the "_method" field.
+const maxOverrideFormBytes = 64 << 10
+
+// formMethod returns the "_method" field of the form body, the body is restored for the handlers.
+func formMethod(ctx *Context) string {
+	if ctx.Req.Body == nil || ctx.Req.Body == http.NoBody || ctx.GetHeader(HeaderContentEncoding) != "" {
+		return ""
+	}
+	if mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader(HeaderContentType)); mediaType != MIMEApplicationForm {
+		return ""
+	}
+	if ctx.Req.ContentLength > maxOverrideFormBytes {
+		return ""
+	}
+	limit := int64(maxOverrideFormBytes + 1)
+	body := ctx.Req.Body
+	buf, err := io.ReadAll(io.LimitReader(body, limit))
+	ctx.Req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), body), body}
+	if err != nil || int64(len(buf)) >= limit {
+		return ""
+	}
+	values, err := url.ParseQuery(string(buf))
+	if err != nil {
+		return ""
+	}
+	return values.Get("_method")
+}
+
 // GetRouterNodeFromCtx returns matched Node from router
 //
 //	router.Get("/api/:type/:ID", func(ctx *Context) error {
@@ -354,7 +1235,52 @@ func GetRouterNodeFromCtx(ctx context.Context) *trie.Node {
 //	})
 func GetRouterPatternFromCtx(ctx context.Context) string {
 	if state := CtxValue[State](ctx); state != nil && state.RouterMatched != nil && state.RouterMatched.Node != nil {
+		if state.routerPattern != "" {
+			return state.RouterPrefix + state.routerPattern
+		}
 		return state.RouterPrefix + state.RouterMatched.Node.GetPattern()
 	}
 	return ""
 }
+
+// ParamsFromCtx returns the matched path parameters from router, or nil if no router matched.
+// The router sets them before running its own middlewares (router.Use), so the middlewares (such as auth or
+// metrics) can use them. The returned map is shared with the ctx and should not be modified.
+//
+//	router.Use(func(ctx *gear.Context) error {
+//		params := gear.ParamsFromCtx(ctx) // map[org:abc repo:xyz] for "/orgs/abc/repos/xyz"
+//		return checkPermission(ctx, params["org"], params["repo"])
+//	})
+//	router.Get("/orgs/:org/repos/:repo", GetRepo)
+func ParamsFromCtx(ctx context.Context) map[string]string {
+	if state := CtxValue[State](ctx); state != nil && state.RouterMatched != nil {
+		return state.RouterMatched.Params
+	}
+	return nil
+}
+
+// RouteMetaFromCtx returns the metadata of the matched route, or nil if no router matched or no metadata attached.
+// The returned map is shared with the router and should not be modified. See router.Meta.
+func RouteMetaFromCtx(ctx context.Context) map[string]any {
+	if state := CtxValue[State](ctx); state != nil {
+		return state.routeMeta
+	}
+	return nil
+}
+
+// isToken reports whether s is a valid HTTP token, https://www.rfc-editor.org/rfc/rfc9110#name-tokens
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
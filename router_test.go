@@ -1,11 +1,18 @@
 package gear
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/teambition/trie-mux"
 )
 
 func TestGearRouter(t *testing.T) {
@@ -165,6 +172,54 @@ func TestGearRouter(t *testing.T) {
 		res.Body.Close()
 	})
 
+	t.Run("router with custom methods", func(t *testing.T) {
+		assert := assert.New(t)
+
+		middleware := func(ctx *Context) error {
+			return ctx.HTML(200, ctx.Method)
+		}
+		r := NewRouter()
+		r.Handle(MethodPropfind, "/dav/:file*", middleware)
+		r.Handle(MethodReport, "/dav/:file*", middleware)
+		r.Handle("purge", "/cache/:key", middleware)
+		r.Handle("X-CUSTOM", "/cache/:key", middleware)
+
+		for _, method := range []string{" GET", "GET\n", "(GET)", "GÉT"} {
+			assert.Panics(func() {
+				r.Handle(method, "/", middleware)
+			}, method)
+		}
+
+		srv := newApp(r)
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		for method, url := range map[string]string{
+			MethodPropfind: "/dav/a/b.txt",
+			MethodReport:   "/dav/a",
+			MethodPurge:    "/cache/abc",
+			"X-CUSTOM":     "/cache/abc",
+		} {
+			res, err := RequestBy(method, host+url)
+			assert.Nil(err)
+			assert.Equal(200, res.StatusCode)
+			assert.Equal(method, PickRes(res.Text()).(string))
+			res.Body.Close()
+		}
+
+		res, err := RequestBy(MethodMkcol, host+"/dav/a")
+		assert.Nil(err)
+		assert.Equal(405, res.StatusCode)
+		assert.Equal("PROPFIND, REPORT", res.Header.Get(HeaderAllow))
+		res.Body.Close()
+
+		res, err = RequestBy("OPTIONS", host+"/cache/abc")
+		assert.Nil(err)
+		assert.Equal(204, res.StatusCode)
+		assert.Equal("PURGE, X-CUSTOM", res.Header.Get(HeaderAllow))
+		res.Body.Close()
+	})
+
 	t.Run("automatic handle `OPTIONS` method", func(t *testing.T) {
 		assert := assert.New(t)
 
@@ -188,6 +243,106 @@ func TestGearRouter(t *testing.T) {
 		res.Body.Close()
 	})
 
+	t.Run("automatic handle `OPTIONS` method with Preflight", func(t *testing.T) {
+		assert := assert.New(t)
+
+		called := 0
+		r := NewRouter(RouterOptions{
+			Root: "/api",
+			Preflight: func(ctx *Context) error {
+				called++
+				if ctx.GetHeader(HeaderOrigin) == "" {
+					return nil
+				}
+				ctx.SetHeader(HeaderAccessControlAllowMethods, ctx.Res.Get(HeaderAllow))
+				ctx.SetHeader(HeaderAccessControlMaxAge, "600")
+				return ctx.End(200)
+			},
+		})
+		r.Use(func(ctx *Context) error {
+			ctx.SetHeader("X-Router", "true")
+			return nil
+		})
+		r.Get("/user", func(ctx *Context) error {
+			return ctx.HTML(200, ctx.Method)
+		})
+		r.Options("/options", func(ctx *Context) error {
+			return ctx.HTML(200, ctx.Method)
+		})
+
+		srv := newApp(r)
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("OPTIONS", host+"/api/user")
+		assert.Nil(err)
+		assert.Equal(204, res.StatusCode)
+		assert.Equal("GET", res.Header.Get(HeaderAllow))
+		assert.Equal("", res.Header.Get(HeaderAccessControlMaxAge))
+		res.Body.Close()
+
+		req, _ := NewRequst("OPTIONS", host+"/api/user")
+		req.Header.Set(HeaderOrigin, "example.com")
+		res, err = DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("GET", res.Header.Get(HeaderAccessControlAllowMethods))
+		assert.Equal("600", res.Header.Get(HeaderAccessControlMaxAge))
+		assert.Equal("", res.Header.Get("X-Router"), "should not run router middlewares")
+		res.Body.Close()
+		assert.Equal(2, called)
+
+		res, err = RequestBy("OPTIONS", host+"/api/options")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("true", res.Header.Get("X-Router"))
+		assert.Equal("OPTIONS", PickRes(res.Text()).(string))
+		assert.Equal(2, called, "should not call Preflight for OPTIONS route")
+	})
+
+	t.Run("fall through with Fallthrough option", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		r1 := NewRouter(RouterOptions{Root: "/api", Fallthrough: true})
+		r1.Get("/user", func(ctx *Context) error {
+			return ctx.HTML(200, "r1 "+ctx.Method)
+		})
+		r2 := NewRouter(RouterOptions{Root: "/api"})
+		r2.Post("/user", func(ctx *Context) error {
+			return ctx.HTML(200, "r2 "+ctx.Method)
+		})
+		app.UseHandler(r1)
+		app.UseHandler(r2)
+		app.Use(func(ctx *Context) error {
+			return ctx.HTML(200, "next "+ctx.Method)
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("GET", host+"/api/user")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("r1 GET", PickRes(res.Text()).(string))
+
+		res, err = RequestBy("POST", host+"/api/user")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("r2 POST", PickRes(res.Text()).(string))
+
+		res, err = RequestBy("PUT", host+"/api/user")
+		assert.Nil(err)
+		assert.Equal(405, res.StatusCode, "should be claimed by r2")
+		assert.Equal("POST", res.Header.Get(HeaderAllow))
+		res.Body.Close()
+
+		res, err = RequestBy("GET", host+"/api/none")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("next GET", PickRes(res.Text()).(string))
+	})
+
 	t.Run("router.Get with one more middleware", func(t *testing.T) {
 		assert := assert.New(t)
 
@@ -272,6 +427,7 @@ func TestGearRouter(t *testing.T) {
 		count := 0
 		r := NewRouter()
 		r.Use(func(ctx *Context) error {
+			assert.Equal(map[string]string{"type": "user", "ID": "123"}, ParamsFromCtx(ctx))
 			count++
 			return nil
 		})
@@ -291,6 +447,7 @@ func TestGearRouter(t *testing.T) {
 		assert.Equal(200, res.StatusCode)
 		assert.Equal("user123", PickRes(res.Text()).(string))
 		res.Body.Close()
+		assert.Nil(ParamsFromCtx(CtxTest(New(), "GET", "http://example.com/api/user/123", nil)))
 	})
 
 	t.Run("router with double colon pattern", func(t *testing.T) {
@@ -977,3 +1134,781 @@ func TestGearRouter(t *testing.T) {
 		res.Body.Close()
 	})
 }
+
+func TestGearRouterURL(t *testing.T) {
+	t.Run("should reverse named routes", func(t *testing.T) {
+		assert := assert.New(t)
+
+		router := NewRouter(RouterOptions{Root: "/api"})
+		router.Get("/users/:id", noOp).Name("user.show", "/users/:id")
+		router.Name("file", "/files/:dir(^[a-z]+$)/:filepath*").
+			Name("undelete", "/files/:id+:undelete").
+			Name("literal", "/::name/:id").
+			Name("index", "/")
+
+		assert.Panics(func() {
+			router.Name("", "/")
+		})
+		assert.Panics(func() {
+			router.Name("index", "/index")
+		})
+
+		url, err := router.URL("user.show", 123)
+		assert.Nil(err)
+		assert.Equal("/api/users/123", url)
+
+		url, err = router.URL("user.show", "a b/c")
+		assert.Nil(err)
+		assert.Equal("/api/users/a%20b%2Fc", url)
+
+		url, err = router.URL("file", "docs", "a/b.md")
+		assert.Nil(err)
+		assert.Equal("/api/files/docs/a/b.md", url)
+
+		url, err = router.URL("undelete", "123")
+		assert.Nil(err)
+		assert.Equal("/api/files/123:undelete", url)
+
+		url, err = router.URL("literal", "123")
+		assert.Nil(err)
+		assert.Equal("/api/:name/123", url)
+
+		url, err = router.URL("index")
+		assert.Nil(err)
+		assert.Equal("/api/", url)
+	})
+
+	t.Run("should return error", func(t *testing.T) {
+		assert := assert.New(t)
+
+		router := NewRouter()
+		_, err := router.URL("user.show", 123)
+		assert.Equal(`Error: route "user.show" not found`, err.Error())
+
+		router.Name("user.show", "/users/:id")
+		_, err = router.URL("user.show")
+		assert.NotNil(err)
+		_, err = router.URL("user.show", 1, 2)
+		assert.NotNil(err)
+	})
+
+	t.Run("should name the last route", func(t *testing.T) {
+		assert := assert.New(t)
+
+		router := NewRouter(RouterOptions{Root: "/api"})
+		assert.Panics(func() {
+			router.Name("none")
+		})
+		router.Get("/users/:id", noOp).Name("user.show")
+		router.Group("/v1").Get("/users/:id/posts", noOp).Name("user.posts")
+
+		url, err := router.URL("user.show", 123)
+		assert.Nil(err)
+		assert.Equal("/api/users/123", url)
+
+		url, err = router.URL("user.posts", 123)
+		assert.Nil(err)
+		assert.Equal("/api/v1/users/123/posts", url)
+	})
+
+	t.Run("should reverse named routes with params by name", func(t *testing.T) {
+		assert := assert.New(t)
+
+		router := NewRouter(RouterOptions{Root: "/api"})
+		router.Get("/users/:id", noOp).Name("user.show")
+		router.Name("file", "/files/:dir(^[a-z]+$)/:filepath*").
+			Name("undelete", "/files/:id+:undelete").
+			Name("index", "/")
+
+		url, err := router.URLWith("user.show", map[string]string{"id": "a b/c"}, nil)
+		assert.Nil(err)
+		assert.Equal("/api/users/a%20b%2Fc", url)
+
+		url, err = router.URLWith("file", map[string]string{"dir": "docs", "filepath": "a/b.md"},
+			map[string][]string{"v": {"1"}, "q": {"x y"}})
+		assert.Nil(err)
+		assert.Equal("/api/files/docs/a/b.md?q=x+y&v=1", url)
+
+		url, err = router.URLWith("undelete", map[string]string{"id": "123"}, nil)
+		assert.Nil(err)
+		assert.Equal("/api/files/123:undelete", url)
+
+		url, err = router.URLWith("index", nil, nil)
+		assert.Nil(err)
+		assert.Equal("/api/", url)
+
+		_, err = router.URLWith("none", nil, nil)
+		assert.Equal(`Error: route "none" not found`, err.Error())
+		_, err = router.URLWith("user.show", nil, nil)
+		assert.Equal(`Error: route "user.show": missing param "id"`, err.Error())
+		_, err = router.URLWith("user.show", map[string]string{"id": "1", "name": "x"}, nil)
+		assert.NotNil(err)
+	})
+}
+
+func TestGearRouterGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	trace := func(name string) Middleware {
+		return func(ctx *Context) error {
+			ctx.Res.Header().Add("X-Trace", name)
+			return nil
+		}
+	}
+	handler := func(ctx *Context) error {
+		return ctx.HTML(200, GetRouterPatternFromCtx(ctx))
+	}
+
+	router := NewRouter(RouterOptions{Root: "/api", IgnoreCase: true})
+	router.Use(trace("root"))
+	router.Get("/health", handler)
+
+	v1 := router.Group("/v1/", trace("v1"))
+	v1.Get("/users", handler).Name("users", "/users")
+	admin := v1.Group("/admin", trace("admin"))
+	admin.Delete("/users/:id", handler).Name("admin.user", "/users/:id")
+	admin.Use(trace("admin2")) // after routes registered
+	admin.Get("/deny", func(ctx *Context) error {
+		return ErrForbidden.WithMsg("deny")
+	})
+	admin.Group("/stop", func(ctx *Context) error {
+		return ctx.End(204)
+	}).Get("/x", handler)
+
+	assert.Panics(func() { router.Group("v2") })
+	v1.Otherwise(func(ctx *Context) error {
+		return ctx.HTML(404, "v1 otherwise")
+	})
+	admin.Otherwise(func(ctx *Context) error {
+		return ctx.HTML(404, "admin otherwise")
+	})
+	router.Otherwise(func(ctx *Context) error {
+		return ctx.HTML(404, "otherwise")
+	})
+
+	u, err := admin.URL("admin.user", 123)
+	assert.Nil(err)
+	assert.Equal("/api/v1/admin/users/123", u)
+	u, err = router.URL("users")
+	assert.Nil(err)
+	assert.Equal("/api/v1/users", u)
+
+	app := New()
+	app.UseHandler(router)
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+		trace              []string
+	}{
+		{"GET", "/api/health", "/api/health", 200, []string{"root"}},
+		{"GET", "/api/v1/users", "/api/v1/users", 200, []string{"root", "v1"}},
+		{"DELETE", "/api/v1/admin/users/123", "/api/v1/admin/users/:id", 200, []string{"root", "v1", "admin", "admin2"}},
+		{"GET", "/api/v1/admin/deny", `{"error":"Forbidden","message":"deny"}`, 403, []string{"root", "v1", "admin", "admin2"}},
+		{"GET", "/api/v1/admin/stop/x", "", 204, []string{"root", "v1", "admin", "admin2"}},
+		{"GET", "/api/v1/none", "v1 otherwise", 404, []string{"root", "v1"}},
+		{"GET", "/api/V1", "v1 otherwise", 404, []string{"root", "v1"}},
+		{"POST", "/api/v1/users", "v1 otherwise", 404, []string{"root", "v1"}},
+		{"GET", "/api/v1/admin/none", "admin otherwise", 404, []string{"root", "v1", "admin", "admin2"}},
+		{"GET", "/api/v1admin", "otherwise", 404, []string{"root"}},
+		{"GET", "/api/none", "otherwise", 404, []string{"root"}},
+	} {
+		res, err := RequestBy(c.method, host+c.path)
+		assert.Nil(err)
+		assert.Equal(c.status, res.StatusCode, c.path)
+		assert.Equal(c.trace, res.Header.Values("X-Trace"), c.path)
+		assert.Equal(c.body, PickRes(res.Text()).(string), c.path)
+	}
+}
+
+func TestGearRouterParam(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewRouter()
+	assert.Panics(func() { r.Param("", func(ctx *Context, val string) error { return nil }) })
+	assert.Panics(func() { r.Param("id", nil) })
+
+	var calls []string
+	r.Use(func(ctx *Context) error {
+		calls = append(calls, "md")
+		return nil
+	})
+	r.Param("id", func(ctx *Context, val string) error {
+		calls = append(calls, "id:"+val)
+		if val == "0" {
+			return ErrNotFound.WithMsg("user not found")
+		}
+		CtxValue[State](ctx).KV["user"] = "user" + val
+		return nil
+	})
+	r.Param("pid", func(ctx *Context, val string) error {
+		calls = append(calls, "pid:"+val)
+		if val == "end" {
+			return ctx.End(204)
+		}
+		return nil
+	})
+	assert.Panics(func() { r.Param("id", func(ctx *Context, val string) error { return nil }) })
+
+	handler := func(ctx *Context) error {
+		calls = append(calls, "handler")
+		user, _ := CtxValue[State](ctx).KV["user"].(string)
+		return ctx.HTML(200, user)
+	}
+	r.Get("/users", handler)
+	r.Get("/users/:id", handler)
+	r.Get("/users/:id/posts/:pid", handler)
+	v1 := r.Group("/v1", func(ctx *Context) error {
+		calls = append(calls, "group")
+		return nil
+	})
+	v1.Get("/users/:id", handler)
+	v1.Get("/users/:id/posts/:pid", handler)
+	v1.Param("pid", func(ctx *Context, val string) error {
+		calls = append(calls, "v1 pid:"+val)
+		return nil
+	})
+	assert.Panics(func() { v1.Param("pid", func(ctx *Context, val string) error { return nil }) })
+
+	app := New()
+	app.UseHandler(r)
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	for _, c := range []struct {
+		path   string
+		status int
+		body   string
+		calls  []string
+	}{
+		{"/users", 200, "", []string{"md", "handler"}},
+		{"/users/1", 200, "user1", []string{"md", "id:1", "handler"}},
+		{"/users/0", 404, `{"error":"NotFound","message":"user not found"}`, []string{"md", "id:0"}},
+		{"/users/2/posts/3", 200, "user2", []string{"md", "id:2", "pid:3", "handler"}},
+		{"/users/2/posts/end", 204, "", []string{"md", "id:2", "pid:end"}},
+		{"/v1/users/4", 200, "user4", []string{"md", "id:4", "group", "handler"}},
+		{"/v1/users/4/posts/5", 200, "user4", []string{"md", "id:4", "pid:5", "group", "v1 pid:5", "handler"}},
+	} {
+		calls = nil
+		res, err := RequestBy("GET", host+c.path)
+		assert.Nil(err)
+		assert.Equal(c.status, res.StatusCode, c.path)
+		assert.Equal(c.body, PickRes(res.Text()).(string), c.path)
+		assert.Equal(c.calls, calls, c.path)
+	}
+}
+
+func TestGearRouterInclude(t *testing.T) {
+	assert := assert.New(t)
+
+	var calls []string
+	md := func(name string) Middleware {
+		return func(ctx *Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	handler := func(ctx *Context) error {
+		calls = append(calls, "handler")
+		return ctx.HTML(200, ctx.Method+" "+GetRouterPatternFromCtx(ctx)+" "+ctx.Param("id"))
+	}
+
+	newUsers := func(name string) *Router {
+		users := NewRouter(RouterOptions{Root: "/users"})
+		users.Use(md("users"))
+		users.Param("id", func(ctx *Context, val string) error {
+			calls = append(calls, "id:"+val)
+			return nil
+		})
+		users.Get("/", handler)
+		users.Get("/:id", handler).Put("/:id", handler).Name(name)
+		users.Group("/:id/posts", md("posts")).Get("", handler)
+		return users
+	}
+	users := newUsers("user.show")
+
+	r := NewRouter(RouterOptions{Root: "/api"})
+	assert.Panics(func() { r.Include(nil) })
+	assert.Panics(func() { r.Include(r) })
+	assert.Panics(func() { r.Include(r.Group("/v1")) })
+
+	r.Use(md("api"))
+	r.Get("/health", handler)
+	r.Include(users)
+	r.Group("/v1", md("v1")).Include(newUsers("v1.user.show"))
+	assert.Panics(func() { r.Include(users) })
+	assert.Panics(func() { r.Include(NewRouter().Get("/health", handler)) })
+
+	url, err := r.URL("user.show", 123)
+	assert.Nil(err)
+	assert.Equal("/api/users/123", url)
+	url, err = r.URL("v1.user.show", 123)
+	assert.Nil(err)
+	assert.Equal("/api/v1/users/123", url)
+
+	app := New()
+	app.UseHandler(r)
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	for _, c := range []struct {
+		method string
+		path   string
+		status int
+		body   string
+		calls  []string
+	}{
+		{"GET", "/api/health", 200, "GET /api/health ", []string{"api", "handler"}},
+		{"GET", "/api/users/", 200, "GET /api/users/ ", []string{"api", "users", "handler"}},
+		{"GET", "/api/users/1", 200, "GET /api/users/:id 1", []string{"api", "users", "id:1", "handler"}},
+		{"PUT", "/api/users/1", 200, "PUT /api/users/:id 1", []string{"api", "users", "id:1", "handler"}},
+		{"GET", "/api/users/2/posts", 200, "GET /api/users/:id/posts 2", []string{"api", "users", "id:2", "posts", "handler"}},
+		{"GET", "/api/v1/users/3", 200, "GET /api/v1/users/:id 3", []string{"api", "v1", "users", "id:3", "handler"}},
+	} {
+		calls = nil
+		req, _ := NewRequst(c.method, host+c.path)
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(c.status, res.StatusCode, c.path)
+		assert.Equal(c.body, PickRes(res.Text()).(string), c.path)
+		assert.Equal(c.calls, calls, c.path)
+	}
+
+	res, err := RequestBy("OPTIONS", host+"/api/users/1")
+	assert.Nil(err)
+	assert.Equal(204, res.StatusCode)
+	assert.Equal("GET, PUT", res.Header.Get(HeaderAllow))
+}
+
+func TestGearRouterMount(t *testing.T) {
+	assert := assert.New(t)
+
+	users := NewRouter(RouterOptions{Root: "/users"})
+	users.Get("/:id", func(ctx *Context) error {
+		return ctx.HTML(200, GetRouterPatternFromCtx(ctx)+" "+ctx.Param("tenant")+" "+ctx.Param("id"))
+	})
+	mux := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		params := ParamsFromCtx(req.Context())
+		w.Write([]byte(req.Method + " " + req.URL.Path + " " + params["tenant"] + " " + strconv.Itoa(len(params))))
+	})
+
+	files := NewRouter(RouterOptions{Root: "/files"})
+	files.Mount("/dav", mux)
+
+	var matched []*trie.Matched
+	router := NewRouter(RouterOptions{Root: "/api"})
+	router.Use(func(ctx *Context) error {
+		ctx.SetHeader("X-Router", "api")
+		matched = append(matched, CtxValue[State](ctx).RouterMatched)
+		return nil
+	})
+	router.Mount("/tenants/:tenant", users)
+	router.Mount("/ops/", NewHealth())
+	router.Mount("/gw/:tenant", mux)
+	router.Mount("/", files)
+	router.Get("/gw/:tenant/static", func(ctx *Context) error {
+		return ctx.HTML(200, "static")
+	})
+	router.Post("/gw/:tenant", func(ctx *Context) error {
+		return ctx.HTML(200, "route")
+	})
+	assert.Panics(func() { router.Mount("ops", mux) })
+	assert.Panics(func() { router.Mount("/x", "handler") })
+	assert.Panics(func() { router.Mount("/gw/:tenant", mux) })
+
+	app := New()
+	app.UseHandler(router)
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{"GET", "/api/tenants/t1/users/123", "/api/tenants/:tenant/users/:id t1 123", 200},
+		{"GET", "/api/ops/healthz", `{"status":"ok","checks":{}}`, 200},
+		{"GET", "/api/gw/t2", "GET / t2 1", 200},
+		{"DELETE", "/api/gw/t2/v1/a%2Fb/c", "DELETE /v1/a/b/c t2 1", 200},
+		{"PROPFIND", "/api/gw/t2/v1", "PROPFIND /v1 t2 1", 200},
+		{"PURGE", "/api/gw/t2", "PURGE / t2 1", 200},
+		{"POST", "/api/gw/t2", "route", 200},
+		{"GET", "/api/gw/t2/static", "static", 200},
+		{"MKCOL", "/api/files/dav/a", "MKCOL /a  0", 200},
+	} {
+		res, err := RequestBy(c.method, host+c.path)
+		assert.Nil(err)
+		assert.Equal(c.status, res.StatusCode, c.path)
+		assert.Equal("api", res.Header.Get("X-Router"), c.path)
+		assert.Equal(c.body, PickRes(res.Text()).(string), c.path)
+	}
+
+	kept := 0
+	for _, m := range matched {
+		if m.Params[mountParam] != "" {
+			kept++ // the matched params are copied by the mount, not mutated
+		}
+	}
+	assert.Equal(4, kept)
+
+	var buf bytes.Buffer
+	app.PrintRoutes(&buf)
+	assert.Regexp(`\n\*\s+/api/gw/:tenant/\*\s+http.HandlerFunc\s+1\n`, buf.String())
+}
+
+func TestGearRouterTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	app := New()
+	app.Set(SetTimeout, 50*time.Millisecond)
+	router := NewRouter(RouterOptions{Root: "/api", Timeout: 20 * time.Millisecond})
+	sleep := func(ctx *Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+			return ctx.HTML(200, "OK")
+		}
+	}
+	router.Get("/fast", sleep)
+	router.Post("/upload", Timeout(time.Second), sleep)
+	router.Group("/events", Timeout(-1)).Get("", sleep)
+	app.UseHandler(router)
+	app.Use(sleep)
+
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	res, err := RequestBy("GET", host+"/api/fast")
+	assert.Nil(err)
+	assert.Equal(504, res.StatusCode)
+	res.Body.Close()
+
+	res, err = RequestBy("POST", host+"/api/upload")
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	res.Body.Close()
+
+	res, err = RequestBy("GET", host+"/api/events")
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	res.Body.Close()
+
+	res, err = RequestBy("GET", host+"/other")
+	assert.Nil(err)
+	assert.Equal(504, res.StatusCode, "should use the app's timeout for unmatched requests")
+	res.Body.Close()
+}
+
+func TestGearRouterParamConstraints(t *testing.T) {
+	t.Run("should match by the typed constraints", func(t *testing.T) {
+		assert := assert.New(t)
+
+		RegisterParamConstraint("country", func(val string) bool {
+			return len(val) == 2 && strings.ToUpper(val) == val
+		})
+		assert.Panics(func() {
+			RegisterParamConstraint("", nil)
+		})
+
+		handler := func(ctx *Context) error {
+			return ctx.HTML(200, ctx.Method+" "+GetRouterPatternFromCtx(ctx)+" "+ctx.Param("id")+ctx.Param("code")+ctx.Param("slug"))
+		}
+		router := NewRouter(RouterOptions{Root: "/api"})
+		router.Get("/users/:id<int>", handler).Name("user")
+		router.Delete("/users/:id", handler)
+		router.Get("/posts/:id<uuid>/:date<date>", handler)
+		router.Get("/items/:id<uint>+.json", handler)
+		router.Group("/countries").Get("/:code<country>", handler)
+		router.Get("/tags/:id(^[a-z]+$)", handler)
+		router.Get("/codes/:id(^(?P<n>\\d+)$)", handler)
+		router.Get("/orders/:id<int>", handler)
+		router.Put("/orders/:id<uuid>", handler)
+
+		other := NewRouter(RouterOptions{Root: "/files"})
+		other.Get("/:id<int>", handler)
+		router.Include(other)
+
+		assert.Panics(func() {
+			router.Get("/unknown/:id<none>", handler)
+		})
+		assert.Panics(func() {
+			router.Get("/invalid/:id>int<", handler)
+		})
+		assert.Panics(func() {
+			router.Get("/invalid/:id<int", handler)
+		})
+		assert.Panics(func() {
+			router.Get("/orders/:slug", handler) // the same shape with another param name
+		})
+
+		url, err := router.URL("user", 123)
+		assert.Nil(err)
+		assert.Equal("/api/users/123", url)
+
+		app := New()
+		app.UseHandler(router)
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		for path, body := range map[string]string{
+			"/api/users/-123": "GET /api/users/:id<int> -123",
+			"/api/posts/0b9a9f0e-9d3e-4b7e-8c6a-1f2e3d4c5b6a/2024-01-31": "GET /api/posts/:id<uuid>/:date<date> 0b9a9f0e-9d3e-4b7e-8c6a-1f2e3d4c5b6a",
+			"/api/items/123.json": "GET /api/items/:id<uint>+.json 123",
+			"/api/countries/CN":   "GET /api/countries/:code<country> CN",
+			"/api/tags/abc":       "GET /api/tags/:id(^[a-z]+$) abc",
+			"/api/files/1":        "GET /api/files/:id<int> 1",
+			"/api/orders/1":       "GET /api/orders/:id<int> 1",
+			"/api/codes/42":       "GET /api/codes/:id(^(?P<n>\\d+)$) 42",
+		} {
+			res, err := RequestBy("GET", host+path)
+			assert.Nil(err)
+			assert.Equal(200, res.StatusCode, path)
+			assert.Equal(body, PickRes(res.Text()).(string))
+		}
+
+		for _, path := range []string{
+			"/api/posts/0b9a9f0e-9d3e-4b7e-8c6a-1f2e3d4c5b6/2024-01-31",
+			"/api/posts/0b9a9f0e-9d3e-4b7e-8c6a-1f2e3d4c5b6a/2024-13-01",
+			"/api/items/-1.json",
+			"/api/countries/cn",
+			"/api/tags/123",
+			"/api/files/x",
+			"/api/orders/abc",
+			"/api/codes/x42",
+			"/api/users/abc", // the GET route is not matched, though the DELETE route of the same shape is
+			"/api/users/1.5",
+		} {
+			res, err := RequestBy("GET", host+path)
+			assert.Nil(err)
+			assert.Equal(421, res.StatusCode, path) // no handler
+
+		}
+
+		res, err := RequestBy("OPTIONS", host+"/api/users/abc")
+		assert.Nil(err)
+		assert.Equal(204, res.StatusCode)
+		assert.Equal("GET, DELETE", res.Header.Get(HeaderAllow))
+
+		res, err = RequestBy("DELETE", host+"/api/users/abc")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("DELETE /api/users/:id abc", PickRes(res.Text()).(string))
+
+		res, err = RequestBy("PUT", host+"/api/orders/0b9a9f0e-9d3e-4b7e-8c6a-1f2e3d4c5b6a")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal("PUT /api/orders/:id<uuid> 0b9a9f0e-9d3e-4b7e-8c6a-1f2e3d4c5b6a", PickRes(res.Text()).(string))
+
+		res, err = RequestBy("PUT", host+"/api/orders/1")
+		assert.Nil(err)
+		assert.Equal(421, res.StatusCode)
+
+		res, err = RequestBy("POST", host+"/api/orders/1")
+		assert.Nil(err)
+		assert.Equal(405, res.StatusCode)
+		assert.Equal("GET, PUT", res.Header.Get(HeaderAllow))
+	})
+
+	t.Run("should run Otherwise handler when mismatched", func(t *testing.T) {
+		assert := assert.New(t)
+
+		router := NewRouter()
+		router.Get("/users/:id<int>", func(ctx *Context) error {
+			return ctx.HTML(200, ctx.Param("id"))
+		})
+		router.Otherwise(func(ctx *Context) error {
+			return ctx.HTML(404, "otherwise")
+		})
+		app := New()
+		app.UseHandler(router)
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		res, err := RequestBy("GET", host+"/users/abc")
+		assert.Nil(err)
+		assert.Equal(404, res.StatusCode)
+		assert.Equal("otherwise", PickRes(res.Text()).(string))
+
+		res, err = RequestBy("GET", host+"/users/123")
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+	})
+}
+
+func TestGearRouterMethodOverride(t *testing.T) {
+	assert := assert.New(t)
+
+	router := NewRouter(RouterOptions{Root: "/api", MethodOverride: []string{"put", "DELETE"}})
+	router.Post("/posts/:id", func(ctx *Context) error {
+		return ctx.HTML(200, "POST "+ctx.Req.Method)
+	})
+	router.Delete("/posts/:id", func(ctx *Context) error {
+		return ctx.HTML(200, "DELETE "+ctx.Req.Method)
+	})
+	router.Put("/posts/:id", func(ctx *Context) error {
+		buf, err := io.ReadAll(ctx.Req.Body)
+		if err != nil {
+			return err
+		}
+		return ctx.HTML(200, "PUT "+string(buf))
+	})
+	router.Post("/drafts", func(ctx *Context) error {
+		buf, err := io.ReadAll(ctx.Req.Body)
+		if err != nil {
+			return err
+		}
+		return ctx.HTML(200, ctx.Method+" "+ctx.Req.Method+" "+strconv.Itoa(len(buf)))
+	})
+	app := New()
+	app.UseHandler(router)
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	post := func(header, contentType, body string) string {
+		req, _ := http.NewRequest("POST", host+"/api/posts/1", strings.NewReader(body))
+		if header != "" {
+			req.Header.Set(HeaderXHTTPMethodOverride, header)
+		}
+		if contentType != "" {
+			req.Header.Set(HeaderContentType, contentType)
+		}
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		return PickRes(res.Text()).(string)
+	}
+
+	assert.Equal("POST POST", post("", "", ""))
+	assert.Equal("DELETE DELETE", post("delete", "", ""))
+	assert.Equal("POST POST", post("PATCH", "", ""), "should not override to the method not allowed")
+	assert.Equal("DELETE DELETE", post("", MIMEApplicationForm, "_method=DELETE"))
+	assert.Equal("PUT _method=put&title=gear", post("", MIMEApplicationForm, "_method=put&title=gear"))
+	assert.Equal("POST POST", post("", MIMEApplicationJSON, `{"_method":"DELETE"}`))
+	big := "_method=DELETE&text=" + strings.Repeat("x", maxOverrideFormBytes)
+	assert.Equal("POST POST", post("", MIMEApplicationForm, big), "should not read the big form body")
+
+	req, _ := http.NewRequest("POST", host+"/api/drafts", strings.NewReader("_method=DELETE"))
+	req.Header.Set(HeaderContentType, MIMEApplicationForm)
+	res, err := DefaultClientDo(req)
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal("POST POST 14", PickRes(res.Text()).(string), "should override only if the route matches")
+
+	res, err = RequestBy("DELETE", host+"/api/posts/1")
+	assert.Nil(err)
+	assert.Equal("DELETE DELETE", PickRes(res.Text()).(string))
+
+	req, _ = http.NewRequest("GET", host+"/api/posts/1", nil)
+	req.Header.Set(HeaderXHTTPMethodOverride, "DELETE")
+	res, err = DefaultClientDo(req)
+	assert.Nil(err)
+	assert.Equal(405, res.StatusCode, "should override POST requests only")
+}
+
+func TestGearRouterMethodNotAllowed(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := func(ctx *Context) error { return ctx.HTML(200, "OK") }
+	router := NewRouter(RouterOptions{
+		Root: "/api",
+		MethodNotAllowed: func(ctx *Context) error {
+			if ctx.Method == http.MethodDelete {
+				return nil
+			}
+			return ctx.HTML(405, ctx.Method+" not in "+ctx.Res.Header().Get(HeaderAllow))
+		},
+	})
+	router.Get("/users", handler)
+	router.Post("/users", handler)
+	router.Otherwise(func(ctx *Context) error {
+		return ctx.HTML(404, "otherwise")
+	})
+
+	app := New()
+	app.UseHandler(router)
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{"GET", "/api/users", "OK", 200},
+		{"PUT", "/api/users", "PUT not in GET, POST", 405},
+		{"DELETE", "/api/users", `{"error":"MethodNotAllowed","message":"\"DELETE\" is not allowed in \"/api/users\""}`, 405},
+		{"OPTIONS", "/api/users", "", 204},
+		{"GET", "/api/none", "otherwise", 404},
+	} {
+		res, err := RequestBy(c.method, host+c.path)
+		assert.Nil(err)
+		assert.Equal(c.status, res.StatusCode, c.method)
+		assert.Equal(c.body, PickRes(res.Text()).(string), c.method)
+	}
+}
+
+func TestGearRouterMeta(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Panics(func() { NewRouter().Meta("auth", "admin") })
+
+	handler := func(ctx *Context) error {
+		meta := RouteMetaFromCtx(ctx)
+		return ctx.HTML(200, fmt.Sprintf("%v %v", ctx.RouteMeta("auth"), len(meta)))
+	}
+	users := NewRouter(RouterOptions{Root: "/users"})
+	users.Delete("/:id<int>", handler).Meta("auth", "owner").Meta("doc", "delete a user")
+
+	router := NewRouter(RouterOptions{Root: "/api"})
+	router.Use(func(ctx *Context) error {
+		if ctx.RouteMeta("auth") == "admin" && ctx.GetHeader("X-Role") != "admin" {
+			return ErrForbidden
+		}
+		return nil
+	})
+	router.Get("/posts", handler).Meta("auth", "admin")
+	router.Post("/posts", handler)
+	router.Group("/v1").Get("/posts/:id", handler).Meta("auth", "reader")
+	router.Include(users)
+	router.Otherwise(handler)
+
+	app := New()
+	app.UseHandler(router)
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	cases := []struct {
+		method, path, role string
+		status             int
+		body               string
+	}{
+		{"GET", "/api/posts", "", 403, ""},
+		{"GET", "/api/posts", "admin", 200, "admin 1"},
+		{"POST", "/api/posts", "", 200, "<nil> 0"},
+		{"GET", "/api/v1/posts/1", "", 200, "reader 1"},
+		{"DELETE", "/api/users/1", "", 200, "owner 2"},
+		{"GET", "/api/none", "", 200, "<nil> 0"},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, host+c.path, nil)
+		req.Header.Set("X-Role", c.role)
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(c.status, res.StatusCode, c.path)
+		if c.body != "" {
+			assert.Equal(c.body, PickRes(res.Text()).(string), c.path)
+		}
+		res.Body.Close()
+	}
+}
@@ -0,0 +1,62 @@
+package gear
+
+import "reflect"
+
+// SettingKey is a typed key for the user-defined settings, so the value type is checked when setting,
+// and the handlers get the typed value without type assertions.
+//
+//	var SetMaxUploadSize = gear.NewSettingKey[int64]("MaxUploadSize")
+//
+//	SetMaxUploadSize.Set(app, 10<<20) // or app.Set(SetMaxUploadSize, int64(10<<20))
+//	app.Set(SetMaxUploadSize, "10MB") // panic: MaxUploadSize setting must be `int64`
+//
+//	app.Use(func(ctx *gear.Context) error {
+//		size, _ := SetMaxUploadSize.Get(ctx)
+//		// or size, ok := gear.Setting[int64](ctx, SetMaxUploadSize)
+//		// ...
+//	})
+type SettingKey[T any] struct {
+	name string
+}
+
+// NewSettingKey returns a new typed setting key with the name. The key is compared by pointer,
+// so the keys with the same name are different settings.
+func NewSettingKey[T any](name string) *SettingKey[T] {
+	return &SettingKey[T]{name: name}
+}
+
+// String returns the name of the key.
+func (k *SettingKey[T]) String() string {
+	return k.name
+}
+
+// Set sets the typed value to app, it is the same as app.Set(k, val).
+func (k *SettingKey[T]) Set(app *App, val T) *App {
+	return app.Set(k, val)
+}
+
+// Get returns the typed value from the app of ctx, and whether the value is set.
+func (k *SettingKey[T]) Get(ctx *Context) (T, bool) {
+	return Setting[T](ctx, k)
+}
+
+func (k *SettingKey[T]) check(val any) {
+	if _, ok := val.(T); !ok {
+		panic(Err.WithMsgf("%s setting must be `%v`", k.name, reflect.TypeOf((*T)(nil)).Elem()))
+	}
+}
+
+// typedSetting is implemented by SettingKey to check the value type in app.Set.
+type typedSetting interface {
+	check(val any)
+}
+
+// Setting returns the app's setting of ctx by key as type T, and false if the setting is not set
+// or not the type T. It works with the builtin settings and SettingKey:
+//
+//	env, _ := gear.Setting[string](ctx, gear.SetEnv)
+//	size, ok := gear.Setting[int64](ctx, SetMaxUploadSize)
+func Setting[T any](ctx *Context, key any) (T, bool) {
+	val, ok := ctx.app.settings[key].(T)
+	return val, ok
+}
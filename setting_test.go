@@ -0,0 +1,78 @@
+package gear
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearSettingKey(t *testing.T) {
+	t.Run("should check the value type", func(t *testing.T) {
+		assert := assert.New(t)
+
+		key := NewSettingKey[int64]("MaxUploadSize")
+		assert.Equal("MaxUploadSize", key.String())
+
+		app := New()
+		key.Set(app, 10<<20)
+		app.Set(key, int64(1<<20))
+		assert.Panics(func() {
+			app.Set(key, 10)
+		})
+		func() {
+			defer func() {
+				assert.Equal("Error: MaxUploadSize setting must be `int64`", recover().(error).Error())
+			}()
+			app.Set(key, "10MB")
+		}()
+
+		iface := NewSettingKey[Sender]("Sender")
+		iface.Set(app, DefaultSender{})
+		func() {
+			defer func() {
+				assert.Equal("Error: Sender setting must be `gear.Sender`", recover().(error).Error())
+			}()
+			app.Set(iface, struct{}{})
+		}()
+	})
+
+	t.Run("should get the typed value", func(t *testing.T) {
+		assert := assert.New(t)
+
+		key := NewSettingKey[int64]("MaxUploadSize")
+		other := NewSettingKey[int64]("MaxUploadSize")
+		app := New()
+		app.Set(SetTimeout, time.Second)
+		app.Set("name", "gear")
+		key.Set(app, 10<<20)
+
+		ctx := NewContext(app, httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		size, ok := key.Get(ctx)
+		assert.True(ok)
+		assert.Equal(int64(10<<20), size)
+		size, ok = Setting[int64](ctx, key)
+		assert.True(ok)
+		assert.Equal(int64(10<<20), size)
+
+		size, ok = other.Get(ctx)
+		assert.False(ok, "should be different settings")
+		assert.Equal(int64(0), size)
+
+		env, ok := Setting[string](ctx, SetEnv)
+		assert.True(ok)
+		assert.Equal("development", env)
+		timeout, ok := Setting[time.Duration](ctx, SetTimeout)
+		assert.True(ok)
+		assert.Equal(time.Second, timeout)
+		name, ok := Setting[string](ctx, "name")
+		assert.True(ok)
+		assert.Equal("gear", name)
+
+		_, ok = Setting[int](ctx, "name")
+		assert.False(ok)
+		_, ok = Setting[string](ctx, "none")
+		assert.False(ok)
+	})
+}
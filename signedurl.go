@@ -0,0 +1,74 @@
+package gear
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SignURL returns a time-limited URL signed by HMAC-SHA256 with the key. The "expires" (unix seconds) and
+// "signature" query parameters will be added to the URL, and the signature covers the path, the query and the expiry.
+// The signed URL can be verified by VerifyURL or the VerifySignedURL middleware.
+//
+//	signed, err := gear.SignURL("https://example.com/files/report.pdf?download=1", 10*time.Minute, key)
+//	// https://example.com/files/report.pdf?download=1&expires=1700000000&signature=...
+func SignURL(rawURL string, expiry time.Duration, key []byte) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Del("signature")
+	query.Set("expires", strconv.FormatInt(time.Now().Add(expiry).Unix(), 10))
+	query.Set("signature", signURL(u.EscapedPath(), query, key))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// VerifyURL verifies the URL signed by SignURL with the key. It returns ErrForbidden if the signature
+// is missing, invalid or expired.
+func VerifyURL(rawURL string, key []byte) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ErrForbidden.WithMsg("invalid signed URL")
+	}
+	return verifyURL(u, key)
+}
+
+// VerifySignedURL creates a middleware to verify the request URL signed by SignURL with the key.
+// It responds 403 if the signature is missing, invalid or expired.
+//
+//	router.Get("/files/:name", gear.VerifySignedURL(key), downloadFile)
+func VerifySignedURL(key []byte) Middleware {
+	return func(ctx *Context) error {
+		return verifyURL(ctx.Req.URL, key)
+	}
+}
+
+func verifyURL(u *url.URL, key []byte) error {
+	query := u.Query()
+	signature := query.Get("signature")
+	if signature == "" {
+		return ErrForbidden.WithMsg("URL signature required")
+	}
+	query.Del("signature")
+	if !hmac.Equal([]byte(signature), []byte(signURL(u.EscapedPath(), query, key))) {
+		return ErrForbidden.WithMsg("invalid URL signature")
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return ErrForbidden.WithMsg("URL signature expired")
+	}
+	return nil
+}
+
+func signURL(path string, query url.Values, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path))
+	mac.Write([]byte{'?'})
+	mac.Write([]byte(query.Encode())) // sorted by key
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,75 @@
+package gear
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearSignURL(t *testing.T) {
+	key := []byte("secret key")
+
+	t.Run("should sign and verify URL", func(t *testing.T) {
+		assert := assert.New(t)
+
+		signed, err := SignURL("https://example.com/files/report%20v1.pdf?download=1&a=b", time.Minute, key)
+		assert.Nil(err)
+		assert.True(strings.HasPrefix(signed, "https://example.com/files/report%20v1.pdf?a=b&download=1&expires="))
+		assert.Nil(VerifyURL(signed, key))
+
+		u, _ := url.Parse(signed)
+		assert.Nil(VerifyURL(u.RequestURI(), key), "should verify path and query only")
+
+		resigned, err := SignURL(signed, time.Minute, key)
+		assert.Nil(err)
+		assert.Equal(1, strings.Count(resigned, "signature="))
+		assert.Nil(VerifyURL(resigned, key))
+	})
+
+	t.Run("should reject invalid URL", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := SignURL("http://[::1", time.Minute, key)
+		assert.NotNil(err)
+
+		signed, _ := SignURL("/files/report.pdf?download=1", time.Minute, key)
+		assert.Equal("Forbidden: invalid URL signature", VerifyURL(signed, []byte("other key")).Error())
+		assert.Equal("Forbidden: invalid URL signature", VerifyURL(strings.Replace(signed, "download=1", "download=2", 1), key).Error())
+		assert.Equal("Forbidden: invalid URL signature", VerifyURL(strings.Replace(signed, "report", "other", 1), key).Error())
+		assert.Equal("Forbidden: URL signature required", VerifyURL("/files/report.pdf", key).Error())
+		assert.Equal("Forbidden: invalid signed URL", VerifyURL("http://[::1", key).Error())
+
+		expired, _ := SignURL("/files/report.pdf", -time.Minute, key)
+		assert.Equal("Forbidden: URL signature expired", VerifyURL(expired, key).Error())
+	})
+}
+
+func TestGearVerifySignedURL(t *testing.T) {
+	assert := assert.New(t)
+
+	key := []byte("secret key")
+	app := New()
+	router := NewRouter()
+	router.Get("/files/:name", VerifySignedURL(key), func(ctx *Context) error {
+		return ctx.HTML(http.StatusOK, ctx.Param("name"))
+	})
+	app.UseHandler(router)
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	signed, _ := SignURL(host+"/files/report.pdf", time.Minute, key)
+	res, err := RequestBy("GET", signed)
+	assert.Nil(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal("report.pdf", PickRes(res.Text()).(string))
+
+	res, err = RequestBy("GET", host+"/files/report.pdf")
+	assert.Nil(err)
+	assert.Equal(403, res.StatusCode)
+	res.Body.Close()
+}
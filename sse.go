@@ -0,0 +1,152 @@
+package gear
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSEOptions is options for ctx.SSEStream.
+type SSEOptions struct {
+	// Heartbeat is the interval to send a comment line (": ping") to keep the connection alive
+	// through proxies and to detect client disconnection.
+	// Optional. Default to 15 seconds, negative to disable.
+	Heartbeat time.Duration
+	// Retry tells the client the reconnection time with "retry" field.
+	// Optional. Default to 0, not send.
+	Retry time.Duration
+	// OnShutdown is called with the send function when the app starts closing (see ctx.OnShutdown),
+	// to send a final event to the client, such as a reconnection hint. Then the stream ends:
+	// the ctx is canceled, so the fn of ctx.SSEStream should return on ctx.Done(), and send returns error.
+	// Optional. Default to nil, the stream ends without a final event.
+	OnShutdown func(send func(event, data string) error)
+}
+
+// SSEStream sends a Server-Sent Events (https://html.spec.whatwg.org/multipage/server-sent-events.html) response.
+// It sets the SSE headers, disables the response compression, and sends heartbeats in the background.
+// The send function writes an event and flushes it to the client. It blocks until the event is written,
+// so slow clients apply backpressure to the producer, and it returns an error if the client disconnected.
+// The event name is optional, the multi-line data will be sent as multiple "data" fields.
+// The stream ends when the app starts closing, so the graceful shutdown will not wait for it, see SSEOptions.OnShutdown.
+// It will end the ctx. The middlewares after current middleware will not run.
+// "after hooks" and "end hooks" will run normally.
+//
+//	router.Get("/events", func(ctx *gear.Context) error {
+//		return ctx.SSEStream(func(send func(event, data string) error) error {
+//			for {
+//				select {
+//				case <-ctx.Done():
+//					return nil
+//				case msg := <-messages:
+//					if err := send("message", msg); err != nil {
+//						return err
+//					}
+//				}
+//			}
+//		})
+//	})
+func (ctx *Context) SSEStream(fn func(send func(event, data string) error) error, options ...SSEOptions) (err error) {
+	opts := SSEOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.Heartbeat == 0 {
+		opts.Heartbeat = 15 * time.Second
+	}
+	if _, ok := ctx.Res.w.(http.Flusher); !ok {
+		return ErrInternalServerError.WithMsg("streaming unsupported by ctx.SSEStream")
+	}
+	if !ctx.Res.ended.swapTrue() {
+		return ErrInternalServerError.WithMsg("request ended before ctx.SSEStream")
+	}
+
+	// events should be flushed immediately, so don't compress them.
+	if cw, ok := ctx.Res.rw.(*compressWriter); ok {
+		ctx.Res.rw = cw.rw
+	}
+	ctx.Type(MIMETextEventStream)
+	ctx.SetHeader(HeaderCacheControl, "no-cache")
+	ctx.SetHeader(HeaderXAccelBuffering, "no") // disable Nginx buffering
+	ctx.Res.Del(HeaderContentLength)
+
+	var mu sync.Mutex
+	closed := false
+	write := func(s string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return ErrInternalServerError.WithMsg("ctx.SSEStream closed")
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := ctx.Res.Write([]byte(s)); err != nil {
+			return err
+		}
+		ctx.Res.Flush()
+		return nil
+	}
+
+	stop := make(chan struct{})
+	defer func() {
+		mu.Lock()
+		closed = true // no more writing after ctx.SSEStream returned
+		mu.Unlock()
+		close(stop)
+		// the client disconnected, it is not an error.
+		if e := ctx.Err(); e != nil && errors.Is(err, e) {
+			err = nil
+		}
+	}()
+
+	head := ": ok\n\n"
+	if opts.Retry > 0 {
+		head = "retry: " + strconv.FormatInt(opts.Retry.Milliseconds(), 10) + "\n\n"
+	}
+	if err = write(head); err != nil {
+		return
+	}
+
+	if opts.Heartbeat > 0 {
+		go func() {
+			ticker := time.NewTicker(opts.Heartbeat)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					if write(": ping\n\n") != nil {
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	send := func(event, data string) error {
+		var b strings.Builder
+		if event != "" {
+			b.WriteString("event: ")
+			b.WriteString(strings.NewReplacer("\r", "", "\n", "").Replace(event))
+			b.WriteByte('\n')
+		}
+		for _, line := range strings.Split(data, "\n") {
+			b.WriteString("data: ")
+			b.WriteString(strings.TrimSuffix(line, "\r"))
+			b.WriteByte('\n')
+		}
+		b.WriteByte('\n')
+		return write(b.String())
+	}
+	ctx.OnShutdown(func() {
+		if opts.OnShutdown != nil {
+			opts.OnShutdown(send)
+		}
+		ctx.cancelCtx()
+	})
+	return fn(send)
+}
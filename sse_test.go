@@ -0,0 +1,161 @@
+package gear
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearContextSSEStream(t *testing.T) {
+	t.Run("should send events", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Set(SetCompress, &DefaultCompress{})
+		app.Use(func(ctx *Context) error {
+			return ctx.SSEStream(func(send func(event, data string) error) error {
+				if err := send("", "hello"); err != nil {
+					return err
+				}
+				time.Sleep(30 * time.Millisecond)
+				return send("update", "line1\nline2")
+			}, SSEOptions{Heartbeat: 10 * time.Millisecond, Retry: 3 * time.Second})
+		})
+		srv := app.Start()
+		defer srv.Close()
+
+		req, _ := NewRequst("GET", "http://"+srv.Addr().String())
+		req.Header.Set(HeaderAcceptEncoding, "gzip")
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(200, res.StatusCode)
+		assert.Equal(MIMETextEventStream, res.Header.Get(HeaderContentType))
+		assert.Equal("no-cache", res.Header.Get(HeaderCacheControl))
+		assert.Equal("no", res.Header.Get(HeaderXAccelBuffering))
+		assert.Equal("", res.Header.Get(HeaderContentEncoding))
+
+		body := PickRes(res.Text()).(string)
+		assert.True(strings.HasPrefix(body, "retry: 3000\n\ndata: hello\n\n"))
+		assert.True(strings.HasSuffix(body, "event: update\ndata: line1\ndata: line2\n\n"))
+		assert.True(strings.Contains(body, ": ping\n\n"))
+	})
+
+	t.Run("should stop when client disconnected", func(t *testing.T) {
+		assert := assert.New(t)
+
+		errCh := make(chan error, 1)
+		app := New()
+		app.Use(func(ctx *Context) error {
+			err := ctx.SSEStream(func(send func(event, data string) error) error {
+				for {
+					if err := send("tick", "ok"); err != nil {
+						return err
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+			})
+			errCh <- err
+			return err
+		})
+		srv := app.Start()
+		defer srv.Close()
+
+		res, err := http.Get("http://" + srv.Addr().String())
+		assert.Nil(err)
+		r := bufio.NewReader(res.Body)
+		line, err := r.ReadString('\n')
+		assert.Nil(err)
+		assert.Equal(": ok\n", line)
+		res.Body.Close()
+
+		select {
+		case err := <-errCh:
+			assert.Nil(err)
+		case <-time.After(time.Second):
+			t.Error("SSEStream should return when client disconnected")
+		}
+	})
+
+	t.Run("should not stream after ended", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var sseErr error
+		app := New()
+		app.Use(func(ctx *Context) error {
+			ctx.End(204)
+			sseErr = ctx.SSEStream(func(send func(event, data string) error) error {
+				return nil
+			})
+			return sseErr
+		})
+		app.Set(SetLogger, log.New(io.Discard, "", 0))
+		srv := app.Start()
+		defer srv.Close()
+
+		res, err := RequestBy("GET", "http://"+srv.Addr().String())
+		assert.Nil(err)
+		assert.Equal(204, res.StatusCode)
+		res.Body.Close()
+		assert.Equal("InternalServerError: request ended before ctx.SSEStream", sseErr.Error())
+	})
+}
+
+func TestGearContextSSEStreamShutdown(t *testing.T) {
+	assert := assert.New(t)
+
+	errCh := make(chan error, 1)
+	app := New()
+	app.Use(func(ctx *Context) error {
+		err := ctx.SSEStream(func(send func(event, data string) error) error {
+			if err := send("", "hello"); err != nil {
+				return err
+			}
+			<-ctx.Done()
+			return nil
+		}, SSEOptions{Heartbeat: -1, OnShutdown: func(send func(event, data string) error) {
+			send("shutdown", "bye")
+		}})
+		errCh <- err
+		return err
+	})
+	srv := app.Start()
+	defer srv.Close()
+
+	res, err := http.Get("http://" + srv.Addr().String())
+	assert.Nil(err)
+	defer res.Body.Close()
+	r := bufio.NewReader(res.Body)
+	for _, expected := range []string{": ok\n", "\n", "data: hello\n", "\n"} {
+		line, err := r.ReadString('\n')
+		assert.Nil(err)
+		assert.Equal(expected, line)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	start := time.Now()
+	assert.Nil(app.Close(ctx))
+	assert.True(time.Since(start) < time.Second)
+	assert.Nil(<-errCh)
+
+	rest, err := io.ReadAll(r)
+	assert.Nil(err)
+	assert.Equal("event: shutdown\ndata: bye\n\n", string(rest))
+
+	// the requests started after closing are notified immediately
+	called := make(chan struct{})
+	ctx2 := CtxTest(app, "GET", "http://example.com", nil)
+	ctx2.OnShutdown(func() { close(called) })
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		assert.Fail("OnShutdown not called")
+	}
+}
@@ -0,0 +1,433 @@
+package gear
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError is a field error of the "validate" struct tag, see ValidateStruct.
+type FieldError struct {
+	Field   string `json:"field"` // the dot-separated path of the field, such as "items[0].name"
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// FieldErrors is the field errors of the "validate" struct tags, it is the error returned by ValidateStruct,
+// and the Data of the 400 error returned by ctx.ParseBody, ctx.ParseURL and ctx.ParseMultipart
+// with SetValidateTags setting.
+type FieldErrors []FieldError
+
+// Error implemented error interface.
+func (fe FieldErrors) Error() string {
+	msgs := make([]string, 0, len(fe))
+	for _, e := range fe {
+		msgs = append(msgs, e.Field+" "+e.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// validateRule checks the field value with the rule param, it returns the error message if invalid.
+type validateRule func(val reflect.Value, param string) (msg string)
+
+var validateRules = struct {
+	sync.RWMutex
+	m map[string]validateRule
+}{m: map[string]validateRule{
+	"min":   ruleBound("at least", func(n, p float64) bool { return n >= p }),
+	"max":   ruleBound("at most", func(n, p float64) bool { return n <= p }),
+	"len":   ruleBound("exactly", func(n, p float64) bool { return n == p }),
+	"oneof": ruleOneOf,
+	"email": ruleString("must be a valid email", func(s string) bool {
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	}),
+	"url": ruleString("must be a valid URL", func(s string) bool {
+		u, err := url.ParseRequestURI(s)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	}),
+	"uuid": ruleString("must be a valid UUID", isUUID),
+}}
+
+// RegisterValidateRule registers a rule for the "validate" struct tags, such as `validate:"phone=CN"`.
+// The fn checks the field value with the rule param ("CN"), the pointer value is dereferenced, and the nil pointer
+// is not checked. It returns false if invalid, the message will be "must be a valid {name}".
+// The rules should be registered before validating, the tag with an unknown rule is invalid.
+// The builtin rules "min", "max", "len", "oneof", "email", "url" and "uuid" can be replaced.
+//
+//	gear.RegisterValidateRule("phone", func(val reflect.Value, param string) bool {
+//		return val.Kind() == reflect.String && phones.Valid(val.String(), param)
+//	})
+func RegisterValidateRule(name string, fn func(val reflect.Value, param string) bool) {
+	if name == "" || fn == nil || name == "required" || name == "omitempty" {
+		panic(Err.WithMsgf("invalid validate rule %q", name))
+	}
+	validateRules.Lock()
+	defer validateRules.Unlock()
+	validateRules.m[name] = func(val reflect.Value, param string) string {
+		if fn(val, param) {
+			return ""
+		}
+		return "must be a valid " + name
+	}
+}
+
+// ValidateStruct validates the struct fields (including the nested structs and the slices of structs)
+// by the "validate" struct tags, returns FieldErrors with all invalid fields, or nil.
+// It returns an *Error (not FieldErrors) if the tags are invalid, such as an unknown rule, see CheckValidateTags.
+// The rules are separated by commas, and applied in order:
+//
+//   - required: the field should not be zero value.
+//   - omitempty: the other rules are skipped if the field is zero value.
+//   - min=N, max=N, len=N: the length of string (in runes), slice or map, or the value of number.
+//   - oneof=a b c: the string or number should be one of the space-separated values.
+//   - email, url, uuid: the string should be a valid email address, absolute URL or UUID, the empty string is valid.
+//   - the rules registered by gear.RegisterValidateRule.
+//
+// The field name in errors is the name of the json, form, query or param tag if present.
+// With SetValidateTags setting, ctx.ParseBody, ctx.ParseURL, ctx.ParseMultipart and ctx.ParseMergePatch validate
+// the body by the tags before calling body.Validate(), so the hand-written Validate() can only check
+// the cross-field rules:
+//
+//	type createUserBody struct {
+//		Name  string   `json:"name" validate:"required,min=3,max=32"`
+//		Email string   `json:"email" validate:"required,email"`
+//		Role  string   `json:"role" validate:"omitempty,oneof=admin member"`
+//		Tags  []string `json:"tags" validate:"max=10"`
+//	}
+//
+//	app.Set(gear.SetValidateTags, true)
+//	app.AddValidator(gear.CheckValidateTags(createUserBody{}))
+//
+//	// ErrBadRequest with Data: [{"field":"name","rule":"min","param":"3","message":"must have at least 3 characters"}]
+//	err := ctx.ParseBody(&body)
+func ValidateStruct(v any) error {
+	var errs FieldErrors
+	if bad := validateValue(reflect.ValueOf(v), &errs); bad != nil {
+		return bad.err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// CheckValidateTags is a validator for app.AddValidator, it checks the "validate" struct tags of the body types
+// (the values or pointers), so the invalid tags, such as the rules of other validators ("gte", "dive") or
+// the unregistered rules, fail the app when it starts serving.
+//
+//	app.AddValidator(gear.CheckValidateTags(createUserBody{}, updateUserBody{}))
+func CheckValidateTags(bodies ...any) func(app *App) error {
+	return func(app *App) error {
+		var errs []error
+		for _, body := range bodies {
+			rt := reflect.TypeOf(body)
+			for rt != nil && rt.Kind() == reflect.Ptr {
+				rt = rt.Elem()
+			}
+			if rt == nil || rt.Kind() != reflect.Struct {
+				continue
+			}
+			if bad := checkStructRules(rt, make(map[reflect.Type]bool)); bad != nil {
+				errs = append(errs, bad.err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// validateBody validates the body by the "validate" struct tags with SetValidateTags setting,
+// and then body.Validate(), the error is based on e, with the field errors in Data.
+// The invalid tags are logged once for the type and skipped, the request is not failed by them.
+func (ctx *Context) validateBody(body BodyTemplate, e *Error) error {
+	if ctx.app.validateTag {
+		var errs FieldErrors
+		if bad := validateValue(reflect.ValueOf(body), &errs); bad != nil {
+			bad.report.Do(func() { ctx.app.Error(bad.err) })
+		} else if len(errs) > 0 {
+			res := e.From(errs)
+			res.Data = errs
+			return res
+		}
+	}
+	if err := body.Validate(); err != nil {
+		return e.From(err)
+	}
+	return nil
+}
+
+type fieldRules struct {
+	index  int
+	name   string
+	rules  []parsedRule
+	nested bool // struct, pointer to struct, or slice/array of them
+	embed  bool // the embedded struct without json tag, its fields are validated like the parent's
+}
+
+type parsedRule struct {
+	name  string
+	param string
+	fn    validateRule
+}
+
+// structRules is the parsed "validate" struct tags of a struct type.
+type structRules struct {
+	fields []fieldRules
+	err    *Error    // the invalid tag, the struct is not validated
+	report sync.Once // the invalid tag is logged once by ctx.validateBody
+}
+
+var structRulesCache sync.Map // map[reflect.Type]*structRules
+
+// validateValue validates the struct value (or pointer) by the tags, returns the struct rules if its tags are invalid.
+func validateValue(rv reflect.Value, errs *FieldErrors) *structRules {
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	return validateStruct(rv, "", errs)
+}
+
+func validateStruct(rv reflect.Value, prefix string, errs *FieldErrors) *structRules {
+	sr := parseStructRules(rv.Type())
+	if sr.err != nil {
+		return sr
+	}
+	for _, f := range sr.fields {
+		fv := rv.Field(f.index)
+		path := prefix + f.name
+		if len(f.rules) > 0 && !validateField(fv, path, f.rules, errs) {
+			continue
+		}
+		var bad *structRules
+		if f.embed {
+			bad = validateNested(fv, strings.TrimSuffix(prefix, "."), errs)
+		} else if f.nested {
+			bad = validateNested(fv, path, errs)
+		}
+		if bad != nil {
+			return bad
+		}
+	}
+	return nil
+}
+
+func validateNested(fv reflect.Value, path string, errs *FieldErrors) *structRules {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	switch fv.Kind() {
+	case reflect.Struct:
+		if path != "" {
+			path += "."
+		}
+		return validateStruct(fv, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			if bad := validateNested(fv.Index(i), path+"["+strconv.Itoa(i)+"]", errs); bad != nil {
+				return bad
+			}
+		}
+	}
+	return nil
+}
+
+// checkStructRules parses the tags of the struct type and the nested struct types without values,
+// returns the first struct rules with invalid tags.
+func checkStructRules(rt reflect.Type, seen map[reflect.Type]bool) *structRules {
+	if seen[rt] {
+		return nil
+	}
+	seen[rt] = true
+	sr := parseStructRules(rt)
+	if sr.err != nil {
+		return sr
+	}
+	for _, f := range sr.fields {
+		if !f.nested {
+			continue
+		}
+		ft := rt.Field(f.index).Type
+		for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array {
+			ft = ft.Elem()
+		}
+		if bad := checkStructRules(ft, seen); bad != nil {
+			return bad
+		}
+	}
+	return nil
+}
+
+// validateField checks the field by the rules, returns false if invalid.
+func validateField(fv reflect.Value, path string, rules []parsedRule, errs *FieldErrors) bool {
+	for _, r := range rules {
+		switch r.name {
+		case "required":
+			if fv.IsZero() {
+				*errs = append(*errs, FieldError{Field: path, Rule: r.name, Message: "is required"})
+				return false
+			}
+			continue
+		case "omitempty":
+			if fv.IsZero() {
+				return true
+			}
+			continue
+		}
+
+		val := fv
+		for val.Kind() == reflect.Ptr && !val.IsNil() {
+			val = val.Elem()
+		}
+		if val.Kind() == reflect.Ptr {
+			continue // nil pointer is checked by required only
+		}
+		if msg := r.fn(val, r.param); msg != "" {
+			*errs = append(*errs, FieldError{Field: path, Rule: r.name, Param: r.param, Message: msg})
+			return false
+		}
+	}
+	return true
+}
+
+// parseStructRules parses the tags of the struct type once, the result is cached.
+func parseStructRules(rt reflect.Type) *structRules {
+	if v, ok := structRulesCache.Load(rt); ok {
+		return v.(*structRules)
+	}
+	sr := &structRules{}
+	sr.fields, sr.err = parseFieldRules(rt)
+	v, _ := structRulesCache.LoadOrStore(rt, sr)
+	return v.(*structRules)
+}
+
+func parseFieldRules(rt reflect.Type) ([]fieldRules, *Error) {
+	res := make([]fieldRules, 0)
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() && !(sf.Anonymous && isNestedType(sf.Type)) {
+			continue // the exported fields of the embedded struct are validated
+		}
+		f := fieldRules{index: i, name: fieldName(sf), nested: isNestedType(sf.Type)}
+		f.embed = f.nested && sf.Anonymous && sf.Tag.Get("json") == ""
+		tag := sf.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		if tag != "" {
+			for _, item := range strings.Split(tag, ",") {
+				name, param, _ := strings.Cut(strings.TrimSpace(item), "=")
+				r := parsedRule{name: name, param: param}
+				switch name {
+				case "required", "omitempty":
+				case "min", "max", "len":
+					if _, err := strconv.ParseFloat(param, 64); err != nil {
+						return nil, Err.WithMsgf("invalid validate rule %q of %s.%s", item, rt.Name(), sf.Name)
+					}
+					fallthrough
+				default:
+					validateRules.RLock()
+					r.fn = validateRules.m[name]
+					validateRules.RUnlock()
+					if r.fn == nil {
+						return nil, Err.WithMsgf("unknown validate rule %q of %s.%s", name, rt.Name(), sf.Name)
+					}
+				}
+				f.rules = append(f.rules, r)
+			}
+		}
+		if len(f.rules) > 0 || f.nested {
+			res = append(res, f)
+		}
+	}
+	return res, nil
+}
+
+func fieldName(sf reflect.StructField) string {
+	for _, key := range []string{"json", "form", "query", "param"} {
+		if name, _, _ := strings.Cut(sf.Tag.Get(key), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return sf.Name
+}
+
+func isNestedType(rt reflect.Type) bool {
+	for rt.Kind() == reflect.Ptr || rt.Kind() == reflect.Slice || rt.Kind() == reflect.Array {
+		rt = rt.Elem()
+	}
+	return rt.Kind() == reflect.Struct && rt.PkgPath() != "time"
+}
+
+// ruleBound checks the length of string, slice or map, or the value of number, with the param.
+func ruleBound(word string, ok func(n, p float64) bool) validateRule {
+	return func(val reflect.Value, param string) string {
+		p, _ := strconv.ParseFloat(param, 64) // checked when parsing the tag
+		var n float64
+		unit := ""
+		switch val.Kind() {
+		case reflect.String:
+			n, unit = float64(utf8.RuneCountInString(val.String())), " characters"
+		case reflect.Slice, reflect.Array, reflect.Map:
+			n, unit = float64(val.Len()), " items"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = float64(val.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			n = float64(val.Uint())
+		case reflect.Float32, reflect.Float64:
+			n = val.Float()
+		default:
+			return ""
+		}
+		if ok(n, p) {
+			return ""
+		}
+		if unit != "" {
+			return fmt.Sprintf("must have %s %s%s", word, param, unit)
+		}
+		return fmt.Sprintf("must be %s %s", word, param)
+	}
+}
+
+func ruleOneOf(val reflect.Value, param string) string {
+	var s string
+	switch val.Kind() {
+	case reflect.String:
+		s = val.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(val.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s = strconv.FormatUint(val.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		s = strconv.FormatFloat(val.Float(), 'f', -1, 64)
+	default:
+		return ""
+	}
+	for _, opt := range strings.Fields(param) {
+		if s == opt {
+			return ""
+		}
+	}
+	return "must be one of " + strings.Join(strings.Fields(param), ", ")
+}
+
+func ruleString(msg string, ok func(s string) bool) validateRule {
+	return func(val reflect.Value, param string) string {
+		if val.Kind() != reflect.String || val.Len() == 0 || ok(val.String()) {
+			return ""
+		}
+		return msg
+	}
+}
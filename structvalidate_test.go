@@ -0,0 +1,207 @@
+package gear
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type validateAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type validateBase struct {
+	ID string `json:"id" validate:"omitempty,uuid"`
+}
+
+type validateUserBody struct {
+	validateBase
+	Name    string             `json:"name" validate:"required,min=3,max=8"`
+	Email   string             `json:"email" validate:"required,email"`
+	Site    string             `json:"site" validate:"url"`
+	Role    string             `json:"role" validate:"omitempty,oneof=admin member"`
+	Age     *int               `json:"age" validate:"min=18"`
+	Level   int                `json:"level" validate:"oneof=1 2 3"`
+	Tags    []string           `json:"tags" validate:"max=2"`
+	Phone   string             `json:"phone" validate:"omitempty,phone=CN"`
+	Home    *validateAddress   `json:"home"`
+	Offices []validateAddress  `json:"offices"`
+	Labels  map[string]string  `validate:"len=1"`
+	Skip    string             `json:"skip" validate:"-"`
+	private string             // ignored
+	Extra   map[string]*string `json:"extra"`
+}
+
+type validateUnknownBody struct {
+	Name string `json:"name"`
+	Age  int    `json:"age" validate:"gte=18"` // the rule of other validators
+}
+
+func (b *validateUnknownBody) Validate() error {
+	if b.Name == "" {
+		return ErrBadRequest.WithMsg("name is required")
+	}
+	return nil
+}
+
+func (b *validateUserBody) Validate() error {
+	if b.Role == "admin" && b.Level != 3 {
+		return ErrBadRequest.WithMsg("admin should be level 3")
+	}
+	return nil
+}
+
+func TestGearValidateStruct(t *testing.T) {
+	RegisterValidateRule("phone", func(val reflect.Value, param string) bool {
+		return param == "CN" && strings.HasPrefix(val.String(), "+86")
+	})
+	assert.Panics(t, func() { RegisterValidateRule("required", func(reflect.Value, string) bool { return true }) })
+
+	t.Run("should validate fields by tags", func(t *testing.T) {
+		assert := assert.New(t)
+
+		age := 16
+		body := &validateUserBody{
+			validateBase: validateBase{ID: "123"},
+			Name:         "ab",
+			Site:         "/path",
+			Role:         "owner",
+			Age:          &age,
+			Level:        4,
+			Tags:         []string{"a", "b", "c"},
+			Phone:        "123",
+			Home:         &validateAddress{},
+			Offices:      []validateAddress{{City: "Beijing"}, {}},
+		}
+		err := ValidateStruct(body)
+		errs, ok := err.(FieldErrors)
+		assert.True(ok)
+		assert.Equal(FieldErrors{
+			{Field: "id", Rule: "uuid", Message: "must be a valid UUID"},
+			{Field: "name", Rule: "min", Param: "3", Message: "must have at least 3 characters"},
+			{Field: "email", Rule: "required", Message: "is required"},
+			{Field: "site", Rule: "url", Message: "must be a valid URL"},
+			{Field: "role", Rule: "oneof", Param: "admin member", Message: "must be one of admin, member"},
+			{Field: "age", Rule: "min", Param: "18", Message: "must be at least 18"},
+			{Field: "level", Rule: "oneof", Param: "1 2 3", Message: "must be one of 1, 2, 3"},
+			{Field: "tags", Rule: "max", Param: "2", Message: "must have at most 2 items"},
+			{Field: "phone", Rule: "phone", Param: "CN", Message: "must be a valid phone"},
+			{Field: "home.city", Rule: "required", Message: "is required"},
+			{Field: "offices[1].city", Rule: "required", Message: "is required"},
+			{Field: "Labels", Rule: "len", Param: "1", Message: "must have exactly 1 items"},
+		}, errs)
+		assert.True(strings.HasPrefix(err.Error(), "id must be a valid UUID; name must have at least 3 characters; "))
+
+		age = 20
+		body = &validateUserBody{
+			Name:   "张三丰",
+			Email:  "a@example.com",
+			Site:   "https://example.com",
+			Age:    &age,
+			Level:  1,
+			Phone:  "+86123",
+			Labels: map[string]string{"a": "b"},
+		}
+		assert.Nil(ValidateStruct(body))
+		body.Age = nil
+		assert.Nil(ValidateStruct(body))
+		body.Name = "张三丰张三丰张三丰"
+		assert.NotNil(ValidateStruct(body))
+
+		assert.Nil(ValidateStruct("not struct"))
+		err = ValidateStruct(&validateUnknownBody{})
+		assert.Equal("Error: unknown validate rule \"gte\" of validateUnknownBody.Age", err.Error())
+		err = ValidateStruct(&struct {
+			Name string `validate:"min=a"`
+		}{})
+		assert.Equal("Error: invalid validate rule \"min=a\" of .Name", err.Error())
+	})
+
+	t.Run("should check the tags by CheckValidateTags validator", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.AddValidator(CheckValidateTags(validateUserBody{}, &validateUserBody{}, "not struct"))
+		assert.Nil(app.Validate())
+
+		app.AddValidator(CheckValidateTags(&struct {
+			Items []*validateUnknownBody
+		}{}))
+		errs := app.Validate()
+		assert.Equal(1, len(errs))
+		assert.Equal("Error: unknown validate rule \"gte\" of validateUnknownBody.Age", errs[0].Error())
+	})
+
+	t.Run("should validate body by tags before Validate()", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		assert.Panics(func() { app.Set(SetValidateTags, "true") })
+		app.Set(SetValidateTags, true)
+		app.Use(func(ctx *Context) error {
+			body := validateUserBody{}
+			if err := ctx.ParseBody(&body); err != nil {
+				return err
+			}
+			return ctx.HTML(200, body.Name)
+		})
+		srv := app.Start()
+		defer srv.Close()
+		host := "http://" + srv.Addr().String()
+
+		post := func(body string) (int, string) {
+			req, _ := http.NewRequest("POST", host, strings.NewReader(body))
+			req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+			res, err := DefaultClientDo(req)
+			assert.Nil(err)
+			return res.StatusCode, PickRes(res.Text()).(string)
+		}
+
+		code, text := post(`{"name":"ab","level":1,"labels":{}}`)
+		assert.Equal(400, code)
+		assert.Equal(`{"error":"BadRequest","message":"name must have at least 3 characters; email is required; `+
+			`Labels must have exactly 1 items","data":[{"field":"name","rule":"min","param":"3","message":"must have `+
+			`at least 3 characters"},{"field":"email","rule":"required","message":"is required"},{"field":"Labels",`+
+			`"rule":"len","param":"1","message":"must have exactly 1 items"}]}`, text)
+
+		code, text = post(`{"name":"abc","email":"a@b.c","level":1,"role":"admin","Labels":{"a":"b"}}`)
+		assert.Equal(400, code)
+		assert.Equal(`{"error":"BadRequest","message":"admin should be level 3"}`, text)
+
+		code, text = post(`{"name":"abc","email":"a@b.c","level":3,"role":"admin","Labels":{"a":"b"}}`)
+		assert.Equal(200, code)
+		assert.Equal("abc", text)
+	})
+
+	t.Run("should not validate body by tags without SetValidateTags", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		ctx := CtxTest(app, "POST", "http://example.com/", strings.NewReader(`{"name":"ab"}`))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+		assert.Nil(ctx.ParseBody(&validateUserBody{}))
+	})
+
+	t.Run("should log the invalid tags once and skip them", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf bytes.Buffer
+		app := New()
+		app.Set(SetLogger, log.New(&buf, "", 0))
+		app.Set(SetValidateTags, true)
+		for i := 0; i < 2; i++ {
+			ctx := CtxTest(app, "POST", "http://example.com/", strings.NewReader(`{"name":"gear","age":1}`))
+			ctx.Req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+			assert.Nil(ctx.ParseBody(&validateUnknownBody{}))
+		}
+		ctx := CtxTest(app, "POST", "http://example.com/", strings.NewReader(`{"age":1}`))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+		assert.Equal("name is required", ctx.ParseBody(&validateUnknownBody{}).(*Error).Msg)
+		assert.Equal(1, strings.Count(buf.String(), `unknown validate rule \"gte\" of validateUnknownBody.Age`))
+	})
+}
@@ -0,0 +1,409 @@
+package gear
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BalanceStrategy is the strategy to pick an upstream target for a request.
+type BalanceStrategy string
+
+// Balance strategies for UpstreamOptions.Balance.
+const (
+	// BalanceRoundRobin picks the healthy targets in turn.
+	BalanceRoundRobin BalanceStrategy = "round-robin"
+	// BalanceLeastConn picks the healthy target with the least active requests.
+	BalanceLeastConn BalanceStrategy = "least-conn"
+	// BalanceWeighted picks the healthy targets in turn by their weights (smooth weighted round-robin).
+	BalanceWeighted BalanceStrategy = "weighted"
+)
+
+// the max request body size buffered for retrying.
+const upstreamMaxRetryBody = 1 << 20
+
+var errUpstreamStatus = errors.New("upstream responded with unavailable status")
+
+// UpstreamTarget is a target of Upstream.
+type UpstreamTarget struct {
+	// URL is the target URL, such as "http://user-service-1:8080".
+	URL string `json:"url" yaml:"url"`
+	// Weight is used by BalanceWeighted.
+	// Optional. Default to 1.
+	Weight int `json:"weight,omitempty" yaml:"weight,omitempty"`
+}
+
+// UpstreamOptions is options for NewUpstream.
+type UpstreamOptions struct {
+	// Targets is the upstream targets to proxy to.
+	Targets []UpstreamTarget
+	// Balance is the strategy to pick a target for a request.
+	// Optional. Default to BalanceRoundRobin.
+	Balance BalanceStrategy
+	// MaxFails is the number of consecutive failures (connection errors and 502, 503, 504 responses)
+	// that marks a target as unhealthy for FailTimeout (passive health check).
+	// Optional. Default to 3.
+	MaxFails int
+	// FailTimeout is the duration that a target is unhealthy after MaxFails failures.
+	// Optional. Default to 10 seconds.
+	FailTimeout time.Duration
+	// HealthPath enables the active health check, the path is requested on every target by GET in
+	// HealthInterval, a target is unhealthy if it does not respond 2xx or 3xx in HealthTimeout.
+	// Optional. Default to "", no active health check.
+	HealthPath string
+	// HealthInterval is the interval of the active health check.
+	// Optional. Default to 10 seconds.
+	HealthInterval time.Duration
+	// HealthTimeout is the timeout of the active health check request.
+	// Optional. Default to 2 seconds.
+	HealthTimeout time.Duration
+	// Retries is the max number of retries on the next target for the idempotent requests (GET, HEAD, OPTIONS,
+	// TRACE, PUT and DELETE) that failed with connection errors or 502, 503, 504 responses. The requests with
+	// body larger than 1MB or unknown length are not retried.
+	// Optional. Default to 0, no retry.
+	Retries int
+	// Transport is used to proxy requests and check health.
+	// Optional. Default to http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// UpstreamState is the state of an upstream target, see Upstream.States.
+type UpstreamState struct {
+	URL       string    `json:"url"`
+	Weight    int       `json:"weight"`
+	Healthy   bool      `json:"healthy"`
+	Active    int64     `json:"active"`    // the number of the active requests
+	Requests  int64     `json:"requests"`  // the number of the proxied requests
+	Failures  int64     `json:"failures"`  // the number of the failed requests
+	Fails     int64     `json:"fails"`     // the number of the consecutive failures
+	DownUntil time.Time `json:"downUntil"` // the target is unhealthy until the time by passive health check
+	CheckErr  string    `json:"checkErr"`  // the error of the last active health check
+}
+
+// Upstream is a load-balanced reverse proxy to multiple targets with health checking, see NewUpstream.
+type Upstream struct {
+	opts    UpstreamOptions
+	targets []*upstreamTarget
+	next    atomic.Uint64
+	mu      sync.Mutex // for BalanceWeighted
+	done    chan struct{}
+	once    sync.Once
+}
+
+type upstreamTarget struct {
+	url       *url.URL
+	weight    int
+	current   int // for BalanceWeighted
+	proxy     *httputil.ReverseProxy
+	active    atomic.Int64
+	requests  atomic.Int64
+	failures  atomic.Int64
+	fails     atomic.Int64
+	downUntil atomic.Int64 // unix nano
+	checkErr  atomic.Pointer[string]
+}
+
+type upstreamAttemptKey struct{}
+
+type upstreamAttempt struct {
+	last bool // the response with unavailable status is not retried on the last attempt
+	err  error
+}
+
+// NewUpstream returns a load-balanced reverse proxy handler to the targets with passive and active health checks,
+// and retry on the next target for the idempotent requests. Call Close to stop the active health check.
+//
+//	up, err := gear.NewUpstream(gear.UpstreamOptions{
+//		Targets: []gear.UpstreamTarget{
+//			{URL: "http://user-service-1:8080", Weight: 2},
+//			{URL: "http://user-service-2:8080"},
+//		},
+//		Balance:    gear.BalanceWeighted,
+//		HealthPath: "/healthz",
+//		Retries:    1,
+//	})
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer up.Close()
+//
+//	router := gear.NewRouter()
+//	router.Get("/_upstreams", up.ServeState) // should be protected for operations
+//	router.Otherwise(up.Serve)
+func NewUpstream(opts UpstreamOptions) (*Upstream, error) {
+	if len(opts.Targets) == 0 {
+		return nil, Err.WithMsg("upstream targets are required")
+	}
+	switch opts.Balance {
+	case "":
+		opts.Balance = BalanceRoundRobin
+	case BalanceRoundRobin, BalanceLeastConn, BalanceWeighted:
+	default:
+		return nil, Err.WithMsgf("invalid upstream balance strategy %q", opts.Balance)
+	}
+	if opts.MaxFails <= 0 {
+		opts.MaxFails = 3
+	}
+	if opts.FailTimeout <= 0 {
+		opts.FailTimeout = 10 * time.Second
+	}
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = 10 * time.Second
+	}
+	if opts.HealthTimeout <= 0 {
+		opts.HealthTimeout = 2 * time.Second
+	}
+	if opts.Retries < 0 {
+		opts.Retries = 0
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+
+	u := &Upstream{opts: opts, done: make(chan struct{})}
+	for _, target := range opts.Targets {
+		tu, err := url.Parse(target.URL)
+		if err != nil || tu.Scheme == "" || tu.Host == "" {
+			return nil, Err.WithMsgf("invalid upstream target %q", target.URL)
+		}
+		t := &upstreamTarget{url: tu, weight: target.Weight}
+		if t.weight <= 0 {
+			t.weight = 1
+		}
+		t.proxy = httputil.NewSingleHostReverseProxy(tu)
+		t.proxy.Transport = opts.Transport
+		t.proxy.ModifyResponse = func(res *http.Response) error {
+			switch res.StatusCode {
+			case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				if a, _ := res.Request.Context().Value(upstreamAttemptKey{}).(*upstreamAttempt); a != nil {
+					a.err = errUpstreamStatus
+					if !a.last {
+						return errUpstreamStatus // the response is discarded and retried on the next target
+					}
+				}
+			}
+			return nil
+		}
+		t.proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+			// nothing is written, the error is handled by Upstream.Serve
+			if a, _ := req.Context().Value(upstreamAttemptKey{}).(*upstreamAttempt); a != nil {
+				a.err = err
+			}
+		}
+		u.targets = append(u.targets, t)
+	}
+
+	if opts.HealthPath != "" {
+		go u.checkHealth()
+	}
+	return u, nil
+}
+
+// Serve implemented gear.Handler interface, it proxies the request to a healthy target.
+// It responds 503 if no healthy target, or 502 (504 for timeout) if the proxying failed.
+func (u *Upstream) Serve(ctx *Context) error {
+	retries := 0
+	var body []byte
+	switch ctx.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		retries = u.opts.Retries
+		if retries > 0 && ctx.Req.Body != nil && ctx.Req.Body != http.NoBody {
+			if ctx.Req.ContentLength < 0 || ctx.Req.ContentLength > upstreamMaxRetryBody {
+				retries = 0
+			} else {
+				var err error
+				if body, err = io.ReadAll(ctx.Req.Body); err != nil {
+					return ErrBadRequest.From(err)
+				}
+			}
+		}
+	}
+
+	var err error
+	tried := make(map[*upstreamTarget]bool, retries+1)
+	for i := 0; i <= retries; i++ {
+		t := u.pick(tried)
+		if t == nil {
+			break
+		}
+		tried[t] = true
+
+		a := &upstreamAttempt{last: i == retries || len(tried) == len(u.targets)}
+		req := ctx.Req.WithContext(context.WithValue(ctx.Req.Context(), upstreamAttemptKey{}, a))
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		t.active.Add(1)
+		t.requests.Add(1)
+		t.proxy.ServeHTTP(ctx.Res, req)
+		t.active.Add(-1)
+
+		if a.err == nil {
+			t.fails.Store(0)
+			return nil
+		}
+		if e := ctx.Err(); e != nil {
+			return e // canceled by client or timeout
+		}
+		t.failures.Add(1)
+		if t.fails.Add(1) >= int64(u.opts.MaxFails) {
+			t.downUntil.Store(time.Now().Add(u.opts.FailTimeout).UnixNano())
+		}
+		if a.err == errUpstreamStatus && a.last {
+			return nil // the unavailable response has been written
+		}
+		err = a.err
+	}
+
+	if err == nil {
+		return ErrServiceUnavailable.WithMsg("no healthy upstream")
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrGatewayTimeout.WithMsg(err.Error())
+	}
+	return ErrBadGateway.WithMsg(err.Error())
+}
+
+// ServeState responds the states of the targets as JSON, it is a introspection handler for operations.
+func (u *Upstream) ServeState(ctx *Context) error {
+	return ctx.JSON(http.StatusOK, u.States())
+}
+
+// States returns the states of the targets.
+func (u *Upstream) States() []UpstreamState {
+	now := time.Now().UnixNano()
+	states := make([]UpstreamState, 0, len(u.targets))
+	for _, t := range u.targets {
+		s := UpstreamState{
+			URL:      t.url.String(),
+			Weight:   t.weight,
+			Healthy:  t.healthy(now),
+			Active:   t.active.Load(),
+			Requests: t.requests.Load(),
+			Failures: t.failures.Load(),
+			Fails:    t.fails.Load(),
+		}
+		if until := t.downUntil.Load(); until > now {
+			s.DownUntil = time.Unix(0, until)
+		}
+		if e := t.checkErr.Load(); e != nil {
+			s.CheckErr = *e
+		}
+		states = append(states, s)
+	}
+	return states
+}
+
+// Close stops the active health check.
+func (u *Upstream) Close() {
+	u.once.Do(func() { close(u.done) })
+}
+
+func (t *upstreamTarget) healthy(now int64) bool {
+	if e := t.checkErr.Load(); e != nil && *e != "" {
+		return false
+	}
+	return t.downUntil.Load() <= now
+}
+
+// pick returns a healthy target that not tried, or nil if no one.
+func (u *Upstream) pick(tried map[*upstreamTarget]bool) *upstreamTarget {
+	now := time.Now().UnixNano()
+	n := len(u.targets)
+	switch u.opts.Balance {
+	case BalanceWeighted:
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		var best *upstreamTarget
+		total := 0
+		for _, t := range u.targets {
+			if tried[t] || !t.healthy(now) {
+				continue
+			}
+			t.current += t.weight
+			total += t.weight
+			if best == nil || t.current > best.current {
+				best = t
+			}
+		}
+		if best != nil {
+			best.current -= total
+		}
+		return best
+
+	case BalanceLeastConn:
+		var best *upstreamTarget
+		start := int(u.next.Add(1) % uint64(n)) // break ties in turn
+		for i := 0; i < n; i++ {
+			t := u.targets[(start+i)%n]
+			if tried[t] || !t.healthy(now) {
+				continue
+			}
+			if best == nil || t.active.Load() < best.active.Load() {
+				best = t
+			}
+		}
+		return best
+
+	default:
+		start := int(u.next.Add(1) % uint64(n))
+		for i := 0; i < n; i++ {
+			if t := u.targets[(start+i)%n]; !tried[t] && t.healthy(now) {
+				return t
+			}
+		}
+		return nil
+	}
+}
+
+func (u *Upstream) checkHealth() {
+	client := &http.Client{
+		Transport: u.opts.Transport,
+		Timeout:   u.opts.HealthTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	ticker := time.NewTicker(u.opts.HealthInterval)
+	defer ticker.Stop()
+	for {
+		var wg sync.WaitGroup
+		for _, t := range u.targets {
+			wg.Add(1)
+			go func(t *upstreamTarget) {
+				defer wg.Done()
+				msg := ""
+				res, err := client.Get(t.url.JoinPath(u.opts.HealthPath).String())
+				if err != nil {
+					msg = err.Error()
+				} else {
+					res.Body.Close()
+					if res.StatusCode >= 400 {
+						msg = "health check responded " + res.Status
+					}
+				}
+				if prev := t.checkErr.Load(); msg == "" && prev != nil && *prev != "" {
+					t.fails.Store(0) // recovered
+					t.downUntil.Store(0)
+				}
+				t.checkErr.Store(&msg)
+			}(t)
+		}
+		wg.Wait()
+
+		select {
+		case <-u.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package gear
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newUpstreamTarget(name string, status ...int) (string, func()) {
+	app := New()
+	app.Use(func(ctx *Context) error {
+		code := http.StatusOK
+		if len(status) > 0 {
+			code = status[0]
+		}
+		if ctx.Path == "/healthz" {
+			return ctx.End(code)
+		}
+		body, _ := io.ReadAll(ctx.Req.Body)
+		return ctx.HTML(code, name+" "+ctx.Method+" "+ctx.Path+string(body))
+	})
+	srv := app.Start()
+	return "http://" + srv.Addr().String(), func() { srv.Close() }
+}
+
+// deadTarget returns a target that closes connections without response.
+func deadTarget(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return "http://" + l.Addr().String()
+}
+
+func serveUpstream(up *Upstream) (string, func()) {
+	app := New()
+	router := NewRouter()
+	router.Get("/_upstreams", up.ServeState)
+	router.Otherwise(up.Serve)
+	app.UseHandler(router)
+	srv := app.Start()
+	return "http://" + srv.Addr().String(), func() { srv.Close() }
+}
+
+func upstreamBodies(t *testing.T, method, url string, n int) []string {
+	bodies := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		req, _ := http.NewRequest(method, url, nil)
+		res, err := DefaultClientDo(req)
+		assert.Nil(t, err)
+		bodies = append(bodies, PickRes(res.Text()).(string))
+	}
+	return bodies
+}
+
+func TestGearUpstream(t *testing.T) {
+	a, closeA := newUpstreamTarget("a")
+	defer closeA()
+	b, closeB := newUpstreamTarget("b")
+	defer closeB()
+
+	t.Run("should return error with invalid options", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := NewUpstream(UpstreamOptions{})
+		assert.Equal("Error: upstream targets are required", err.Error())
+		_, err = NewUpstream(UpstreamOptions{Targets: []UpstreamTarget{{URL: "localhost"}}})
+		assert.Equal(`Error: invalid upstream target "localhost"`, err.Error())
+		_, err = NewUpstream(UpstreamOptions{Targets: []UpstreamTarget{{URL: a}}, Balance: "random"})
+		assert.Equal(`Error: invalid upstream balance strategy "random"`, err.Error())
+	})
+
+	t.Run("should balance by round-robin", func(t *testing.T) {
+		assert := assert.New(t)
+
+		up, err := NewUpstream(UpstreamOptions{Targets: []UpstreamTarget{{URL: a}, {URL: b}}})
+		assert.Nil(err)
+		host, stop := serveUpstream(up)
+		defer stop()
+
+		bodies := upstreamBodies(t, "GET", host+"/users", 4)
+		assert.NotEqual(bodies[0], bodies[1])
+		assert.Equal(bodies[0], bodies[2])
+		assert.Equal(bodies[1], bodies[3])
+		assert.True(strings.HasSuffix(bodies[0], " GET /users"))
+
+		res, err := RequestBy("GET", host+"/_upstreams")
+		assert.Nil(err)
+		states := []UpstreamState{}
+		assert.Nil(json.NewDecoder(res.Body).Decode(&states))
+		res.Body.Close()
+		assert.Equal(2, len(states))
+		assert.Equal(a, states[0].URL)
+		assert.True(states[0].Healthy)
+		assert.Equal(int64(2), states[0].Requests)
+		assert.Equal(int64(2), states[1].Requests)
+	})
+
+	t.Run("should balance by weights", func(t *testing.T) {
+		assert := assert.New(t)
+
+		up, err := NewUpstream(UpstreamOptions{
+			Targets: []UpstreamTarget{{URL: a, Weight: 2}, {URL: b}},
+			Balance: BalanceWeighted,
+		})
+		assert.Nil(err)
+		host, stop := serveUpstream(up)
+		defer stop()
+
+		assert.Equal([]string{"a GET /", "b GET /", "a GET /", "a GET /", "b GET /", "a GET /"},
+			upstreamBodies(t, "GET", host, 6))
+	})
+
+	t.Run("should balance by least connections", func(t *testing.T) {
+		assert := assert.New(t)
+
+		release := make(chan struct{})
+		slow := New()
+		slow.Use(func(ctx *Context) error {
+			<-release
+			return ctx.HTML(200, "slow")
+		})
+		srv := slow.Start()
+		defer srv.Close()
+
+		up, err := NewUpstream(UpstreamOptions{
+			Targets: []UpstreamTarget{{URL: "http://" + srv.Addr().String()}, {URL: b}},
+			Balance: BalanceLeastConn,
+		})
+		assert.Nil(err)
+		host, stop := serveUpstream(up)
+		defer stop()
+
+		done := make(chan string)
+		go func() {
+			for {
+				res, err := RequestBy("GET", host)
+				assert.Nil(err)
+				if body := PickRes(res.Text()).(string); body == "slow" {
+					done <- body
+					return
+				}
+			}
+		}()
+		for up.States()[0].Active == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		assert.Equal([]string{"b GET /", "b GET /", "b GET /"}, upstreamBodies(t, "GET", host, 3))
+		close(release)
+		assert.Equal("slow", <-done)
+	})
+
+	t.Run("should retry on next upstream for idempotent methods", func(t *testing.T) {
+		assert := assert.New(t)
+
+		dead := deadTarget(t)
+		up, err := NewUpstream(UpstreamOptions{
+			Targets:  []UpstreamTarget{{URL: dead}, {URL: a}},
+			MaxFails: 2,
+			Retries:  1,
+		})
+		assert.Nil(err)
+		host, stop := serveUpstream(up)
+		defer stop()
+
+		assert.Equal([]string{"a GET /", "a GET /", "a GET /"}, upstreamBodies(t, "GET", host, 3))
+		state := up.States()[0]
+		assert.Equal(dead, state.URL)
+		assert.False(state.Healthy)
+		assert.Equal(int64(2), state.Failures)
+		assert.True(state.DownUntil.After(time.Now()))
+
+		req, _ := http.NewRequest("PUT", host+"/users", strings.NewReader(`{"name":"gear"}`))
+		res, err := DefaultClientDo(req)
+		assert.Nil(err)
+		assert.Equal(`a PUT /users{"name":"gear"}`, PickRes(res.Text()).(string))
+
+		up, err = NewUpstream(UpstreamOptions{Targets: []UpstreamTarget{{URL: dead}, {URL: a}}, Retries: 1})
+		assert.Nil(err)
+		host2, stop2 := serveUpstream(up)
+		defer stop2()
+		statuses := []int{}
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest("POST", host2, nil)
+			res, err := DefaultClientDo(req)
+			assert.Nil(err)
+			res.Body.Close()
+			statuses = append(statuses, res.StatusCode)
+		}
+		assert.Contains(statuses, http.StatusBadGateway)
+		assert.Contains(statuses, http.StatusOK)
+	})
+
+	t.Run("should retry on unavailable responses", func(t *testing.T) {
+		assert := assert.New(t)
+
+		c, closeC := newUpstreamTarget("c", http.StatusServiceUnavailable)
+		defer closeC()
+		up, err := NewUpstream(UpstreamOptions{Targets: []UpstreamTarget{{URL: c}, {URL: a}}, Retries: 1})
+		assert.Nil(err)
+		host, stop := serveUpstream(up)
+		defer stop()
+		assert.Equal([]string{"a GET /", "a GET /"}, upstreamBodies(t, "GET", host, 2))
+
+		up, err = NewUpstream(UpstreamOptions{Targets: []UpstreamTarget{{URL: c}}, Retries: 1})
+		assert.Nil(err)
+		host, stop = serveUpstream(up)
+		defer stop()
+		res, err := RequestBy("GET", host)
+		assert.Nil(err)
+		assert.Equal(http.StatusServiceUnavailable, res.StatusCode)
+		assert.Equal("c GET /", PickRes(res.Text()).(string))
+	})
+
+	t.Run("should check health actively", func(t *testing.T) {
+		assert := assert.New(t)
+
+		c, closeC := newUpstreamTarget("c", http.StatusInternalServerError)
+		defer closeC()
+		up, err := NewUpstream(UpstreamOptions{
+			Targets:        []UpstreamTarget{{URL: c}, {URL: a}},
+			HealthPath:     "/healthz",
+			HealthInterval: 10 * time.Millisecond,
+		})
+		assert.Nil(err)
+		defer up.Close()
+		host, stop := serveUpstream(up)
+		defer stop()
+
+		for up.States()[0].CheckErr == "" {
+			time.Sleep(time.Millisecond)
+		}
+		assert.Equal("health check responded 500 Internal Server Error", up.States()[0].CheckErr)
+		assert.False(up.States()[0].Healthy)
+		assert.True(up.States()[1].Healthy)
+		assert.Equal([]string{"a GET /", "a GET /"}, upstreamBodies(t, "GET", host, 2))
+
+		up.Close()
+		up.Close()
+	})
+
+	t.Run("should respond 503 if no healthy upstream", func(t *testing.T) {
+		assert := assert.New(t)
+
+		up, err := NewUpstream(UpstreamOptions{Targets: []UpstreamTarget{{URL: deadTarget(t)}}, MaxFails: 1})
+		assert.Nil(err)
+		host, stop := serveUpstream(up)
+		defer stop()
+
+		res, err := RequestBy("GET", host)
+		assert.Nil(err)
+		assert.Equal(http.StatusBadGateway, res.StatusCode)
+		res.Body.Close()
+
+		res, err = RequestBy("GET", host)
+		assert.Nil(err)
+		assert.Equal(http.StatusServiceUnavailable, res.StatusCode)
+		assert.Equal(`{"error":"ServiceUnavailable","message":"no healthy upstream"}`, PickRes(res.Text()).(string))
+	})
+}
@@ -18,9 +18,12 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
+	"unicode"
 	"unicode/utf8"
+	"unsafe"
 )
 
 type middlewares []Middleware
@@ -48,6 +51,29 @@ func Compose(mds ...Middleware) Middleware {
 
 var noOp Middleware = func(ctx *Context) error { return nil }
 
+// OnlyEnv returns a middleware that runs md only if the app env (see SetEnv) is env, otherwise it does nothing.
+// It reduces accidental exposure of the development tools in production:
+//
+//	app.Use(gear.OnlyEnv("development", debugDumper))
+//	app.Use(gear.OnlyEnv("production", secure.StrictTransportSecurity(opts)))
+//
+// The returned middleware has the same name as md for app.Middlewares.
+//
+// The built-in development tools are gated by their Options.Envs already: inspector (default to "development"),
+// schema (default to "development") and contract (default to "test") middlewares. The other built-in middlewares,
+// such as cors and secure, run in all envs, wrap them by OnlyEnv if needed.
+func OnlyEnv(env string, md Middleware) Middleware {
+	if md == nil {
+		panic(Err.WithMsg("invalid middleware"))
+	}
+	return nameMiddleware(MiddlewareName(md), func(ctx *Context) error {
+		if ctx.app.Env() != env {
+			return nil
+		}
+		return md(ctx)
+	})
+}
+
 // WrapHandler wrap a http.Handler to Gear Middleware
 func WrapHandler(handler http.Handler) Middleware {
 	return func(ctx *Context) error {
@@ -81,11 +107,12 @@ func IsNil(val any) bool {
 
 // Error represents a numeric error with optional meta. It can be used in middleware as a return result.
 type Error struct {
-	Code  int    `json:"-"`
-	Err   string `json:"error"`
-	Msg   string `json:"message"`
-	Data  any    `json:"data,omitempty"`
-	Stack string `json:"-"`
+	Code   int    `json:"-"`
+	Err    string `json:"error"`
+	Msg    string `json:"message"`
+	Reason string `json:"reason,omitempty"` // a stable machine-readable error code, such as "user_not_found"
+	Data   any    `json:"data,omitempty"`
+	Stack  string `json:"-"`
 }
 
 // ErrorResponse represents error response like JSON-RPC2 or Google cloud API.
@@ -94,6 +121,7 @@ type ErrorResponse struct {
 		Code    int    `json:"code"`
 		Status  string `json:"status"`
 		Message string `json:"message"`
+		Reason  string `json:"reason,omitempty"`
 		Data    any    `json:"data,omitempty"`
 	} `json:"error"`
 }
@@ -105,17 +133,19 @@ func ToErrorResponse(e error) ErrorResponse {
 	res.Error.Code = err.Code
 	res.Error.Status = err.Err
 	res.Error.Message = err.Msg
+	res.Error.Reason = err.Reason
 	res.Error.Data = err.Data
 	return res
 }
 
 // errorForLog use to marshal for logging.
 type errorForLog struct {
-	Code  int    `json:"code"`
-	Err   string `json:"error"`
-	Msg   string `json:"message"`
-	Data  any    `json:"data,omitempty"`
-	Stack string `json:"stack"`
+	Code   int    `json:"code"`
+	Err    string `json:"error"`
+	Msg    string `json:"message"`
+	Reason string `json:"reason,omitempty"`
+	Data   any    `json:"data,omitempty"`
+	Stack  string `json:"stack"`
 }
 
 // Status implemented HTTPError interface.
@@ -189,6 +219,17 @@ func (err Error) WithCode(code int) *Error {
 	return &err
 }
 
+// WithReason returns a copy of err with given machine-readable error code, it is rendered as the "reason" field
+// (as in ErrorResponse and the error logs), so the clients don't need to parse the message.
+//
+//	ErrUserNotFound := gear.ErrNotFound.WithReason("user_not_found")
+//	// {"error":"NotFound","message":"user 123 not found","reason":"user_not_found"}
+//	return ErrUserNotFound.WithMsgf("user %s not found", id)
+func (err Error) WithReason(code string) *Error {
+	err.Reason = code
+	return &err
+}
+
 // WithStack returns a copy of err with error stack.
 //
 //	err := gear.Err.WithMsg("some error").WithStack()
@@ -278,6 +319,8 @@ func ErrorWithStack(val any, skip ...int) *Error {
 }
 
 // ValuesToStruct converts url.Values into struct object. It supports specific types that implementing encoding.TextUnmarshaler interface.
+// The fields of embedded structs are filled as the fields of the struct, and the fields of nested struct
+// (or pointer to struct) are filled by the dot-separated keys, such as "user.name" for `form:"user"`.
 //
 //	type jsonQueryTemplate struct {
 //		ID   string `json:"id" form:"id"`
@@ -337,15 +380,51 @@ func valuesToStruct(values map[string][]string, rv reflect.Value, tag string) (e
 			} else if len(vals) > 0 && vals[0] != "" {
 				err = setRefField(value, vals[0])
 			}
-			if err != nil {
-				return
+		} else if isNestedStruct(value.Type()) {
+			if sub := subValues(values, fk+"."); len(sub) > 0 {
+				if value.Kind() == reflect.Ptr {
+					if value.IsNil() {
+						value.Set(reflect.New(value.Type().Elem()))
+					}
+					err = valuesToStruct(sub, value, tag)
+				} else {
+					err = valuesToStruct(sub, value.Addr(), tag)
+				}
 			}
 		}
+		if err != nil {
+			return
+		}
 	}
 
 	return
 }
 
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isNestedStruct reports whether the type is a struct or pointer to struct that
+// should be filled field by field, not by encoding.TextUnmarshaler.
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// subValues returns the values with the prefix, and the prefix is trimmed from the keys.
+func subValues(values map[string][]string, prefix string) map[string][]string {
+	var sub map[string][]string
+	for k, v := range values {
+		if len(k) > len(prefix) && strings.HasPrefix(k, prefix) {
+			if sub == nil {
+				sub = make(map[string][]string)
+			}
+			sub[k[len(prefix):]] = v
+		}
+	}
+	return sub
+}
+
 func shouldDeref(k reflect.Kind) bool {
 	switch k {
 	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
@@ -644,8 +723,65 @@ func (s *LoggerFilterWriter) Write(p []byte) (n int, err error) {
 	return s.out.Write(p)
 }
 
+// stringBytes returns the bytes of s without copying, the bytes must not be modified.
+// It is used by ctx.HTMLString for the constant responses, such as ping and health endpoints.
+func stringBytes(s string) []byte {
+	if s == "" {
+		return []byte{}
+	}
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
+
+// Decompressor creates a reader decompressing r, see RegisterDecompressor.
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+var decompressors = struct {
+	sync.RWMutex
+	m map[string]Decompressor
+}{m: make(map[string]Decompressor)}
+
+// RegisterDecompressor registers a Decompressor for the Content-Encoding of request bodies, it is used by
+// gear.Decompress and ctx.ParseBody. It can add modern codecs such as br and zstd without adding dependencies
+// to gear, or replace the builtin gzip, zlib and deflate decompressors:
+//
+//	import (
+//		"github.com/andybalholm/brotli"
+//		"github.com/klauspost/compress/zstd"
+//	)
+//
+//	gear.RegisterDecompressor("br", func(r io.Reader) (io.ReadCloser, error) {
+//		return io.NopCloser(brotli.NewReader(r)), nil
+//	})
+//	gear.RegisterDecompressor("zstd", func(r io.Reader) (io.ReadCloser, error) {
+//		d, err := zstd.NewReader(r)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return d.IOReadCloser(), nil
+//	})
+//
+// The request bodies with unsupported Content-Encoding are rejected with 415 Unsupported Media Type.
+func RegisterDecompressor(encoding string, fn Decompressor) {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	if encoding == "" || fn == nil {
+		panic(Err.WithMsg("invalid decompressor"))
+	}
+	decompressors.Lock()
+	decompressors.m[encoding] = fn
+	decompressors.Unlock()
+}
+
 // Decompress wrap the reader for decompressing, It support gzip and zlib, and compatible for deflate.
+// More encodings can be supported by RegisterDecompressor.
 func Decompress(encoding string, r io.Reader) (io.ReadCloser, error) {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	decompressors.RLock()
+	fn := decompressors.m[encoding]
+	decompressors.RUnlock()
+	if fn != nil {
+		return fn(r)
+	}
+
 	switch encoding {
 	case "gzip":
 		return gzip.NewReader(r)
@@ -688,7 +824,7 @@ func isLikeMediaType(s, t string) bool {
 //	 }
 func ContextWithSignal(ctx context.Context) context.Context {
 	newCtx, cancel := context.WithCancel(ctx)
-	signals := make(chan os.Signal)
+	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-signals
@@ -713,6 +849,71 @@ func RenderErrorResponse(err HTTPError) (int, string, []byte) {
 	return err.Status(), MIMEApplicationJSONCharsetUTF8, body
 }
 
+// RenderErrorOptions is options for NewRenderError.
+type RenderErrorOptions struct {
+	// Status adds the numeric HTTP status as the "status" field, such as `"status":404`.
+	Status bool
+	// DefaultReason derives the "reason" field from the error name (such as "not_found" for "NotFound")
+	// for the errors without Reason, so every error response has a machine-readable code.
+	DefaultReason bool
+}
+
+// NewRenderError returns a SetRenderError function that renders the error as JSON like the default one,
+// with the machine-readable "reason" field (see Error.WithReason) and the numeric "status" field by options:
+//
+//	app.Set(gear.SetRenderError, gear.NewRenderError(gear.RenderErrorOptions{Status: true, DefaultReason: true}))
+//	// {"error":"NotFound","message":"user 123 not found","reason":"user_not_found","status":404}
+//	// {"error":"Unauthorized","message":"invalid token","reason":"unauthorized","status":401}
+func NewRenderError(opts RenderErrorOptions) func(HTTPError) (int, string, []byte) {
+	type errorBody struct {
+		*Error
+		Status int `json:"status,omitempty"`
+	}
+
+	return func(err HTTPError) (int, string, []byte) {
+		e, ok := err.(*Error)
+		if !ok {
+			e = &Error{Code: err.Status(), Err: http.StatusText(err.Status()), Msg: err.Error()}
+		}
+		if opts.DefaultReason && e.Reason == "" {
+			e = e.WithReason(snakeCase(e.Err))
+		}
+		res := errorBody{Error: e}
+		if opts.Status {
+			res.Status = err.Status()
+		}
+		body, er := json.Marshal(res)
+		if er != nil {
+			body, _ = json.Marshal(map[string]string{"error": err.Error()})
+		}
+		return err.Status(), MIMEApplicationJSONCharsetUTF8, body
+	}
+}
+
+// snakeCase converts the error name to snake case, such as "RequestURITooLong" and "Request URI Too Long"
+// to "request_uri_too_long".
+func snakeCase(name string) string {
+	rs := []rune(name)
+	b := strings.Builder{}
+	for i, r := range rs {
+		switch {
+		case r == ' ' || r == '-' || r == '_':
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+				b.WriteByte('_')
+			}
+			continue
+		case unicode.IsUpper(r) && i > 0 && !strings.HasSuffix(b.String(), "_"):
+			prev := rs[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && i+1 < len(rs) && unicode.IsLower(rs[i+1])) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
 func defaultRenderError(err HTTPError) (int, string, []byte) {
 	// default to render error as json
 	body, e := json.Marshal(err)
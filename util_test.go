@@ -6,6 +6,7 @@ import (
 	"compress/zlib"
 	"crypto/tls"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"io"
 	"log"
@@ -19,6 +20,7 @@ import (
 	"strings"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/http2"
@@ -891,6 +893,48 @@ func TestGearValuesToStruct(t *testing.T) {
 		assert.Equal(myDuration{time.Millisecond * 300}, *s.PDu2)
 		assert.Equal(ObjectIdHex("a0a0a0a0a0a0a0a0a0a0a0a0"), *s.PObjectID)
 	})
+
+	t.Run("Should support nested structs", func(t *testing.T) {
+		assert := assert.New(t)
+
+		type address struct {
+			City string `form:"city"`
+			Zip  int    `form:"zip"`
+		}
+		type profile struct {
+			Address address `form:"address"`
+		}
+		type user struct {
+			profile
+			Name    string    `form:"name"`
+			Tags    []string  `form:"tags"`
+			Home    *address  `form:"home"`
+			Office  *address  `form:"office"`
+			Born    time.Time `form:"born"`
+			Profile profile   `form:"profile"`
+		}
+
+		s := user{}
+		assert.Nil(ValuesToStruct(url.Values{
+			"name":                 {"gear"},
+			"tags":                 {"a", "b"},
+			"address.city":         {"Beijing"},
+			"home.city":            {"Shanghai"},
+			"home.zip":             {"200000"},
+			"born":                 {"2016-01-01T00:00:00Z"},
+			"born.x":               {"ignored"},
+			"profile.address.city": {"Hangzhou"},
+		}, &s, "form"))
+		assert.Equal("gear", s.Name)
+		assert.Equal([]string{"a", "b"}, s.Tags)
+		assert.Equal("Beijing", s.Address.City)
+		assert.Equal(&address{"Shanghai", 200000}, s.Home)
+		assert.Nil(s.Office)
+		assert.Equal(2016, s.Born.Year())
+		assert.Equal("Hangzhou", s.Profile.Address.City)
+
+		assert.NotNil(ValuesToStruct(url.Values{"home.zip": {"abc"}}, &s, "form"))
+	})
 }
 
 func TestLoggerFilterWriter(t *testing.T) {
@@ -991,6 +1035,64 @@ func TestDecompress(t *testing.T) {
 		assert.Nil(reader)
 		assert.Equal(415, err.(*Error).Status())
 	})
+
+	t.Run("should support registered decompressor", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.Panics(func() { RegisterDecompressor(" ", func(r io.Reader) (io.ReadCloser, error) { return nil, nil }) })
+		assert.Panics(func() { RegisterDecompressor("x-hex", nil) })
+
+		body := `{"id":"admin","pass":"password"}`
+		app := New()
+		ctx := CtxTest(app, "POST", "http://example.com/foo", strings.NewReader(hex.EncodeToString([]byte(body))))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+		ctx.Req.Header.Set(HeaderContentEncoding, "X-Hex")
+		err := ctx.ParseBody(new(jsonBodyTemplate))
+		assert.Equal(415, err.(*Error).Status())
+
+		RegisterDecompressor(" X-Hex ", func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(hex.NewDecoder(r)), nil
+		})
+		defer func() {
+			decompressors.Lock()
+			delete(decompressors.m, "x-hex")
+			decompressors.Unlock()
+		}()
+
+		reader, err := Decompress("x-hex", strings.NewReader(hex.EncodeToString([]byte(body))))
+		assert.Nil(err)
+		data, err := io.ReadAll(reader)
+		assert.Nil(err)
+		assert.Equal(body, string(data))
+
+		ctx = CtxTest(app, "POST", "http://example.com/foo", strings.NewReader(hex.EncodeToString([]byte(body))))
+		ctx.Req.Header.Set(HeaderContentType, MIMEApplicationJSON)
+		ctx.Req.Header.Set(HeaderContentEncoding, "X-Hex")
+		template := new(jsonBodyTemplate)
+		assert.Nil(ctx.ParseBody(template))
+		assert.Equal("admin", template.ID)
+		assert.Equal("password", template.Pass)
+	})
+}
+
+func TestStringBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]byte{}, stringBytes(""))
+	str := "Hello, Gear"
+	buf := stringBytes(str)
+	assert.Equal([]byte(str), buf)
+	assert.True(unsafe.StringData(str) == &buf[0])
+
+	ctx := CtxTest(New(), "GET", "http://example.com/ping", nil)
+	assert.Nil(ctx.HTMLString(200, str))
+	assert.Equal(MIMETextHTMLCharsetUTF8, ctx.Res.Get(HeaderContentType))
+	assert.True(unsafe.StringData(str) == &ctx.Res.Body()[0])
+
+	ctx = CtxTest(New(), "GET", "http://example.com/ping", nil)
+	assert.Nil(ctx.HTML(200, str))
+	assert.Equal([]byte(str), ctx.Res.Body())
+	assert.False(unsafe.StringData(str) == &ctx.Res.Body()[0], "should copy by default")
 }
 
 func TestIsLikeMediaType(t *testing.T) {
@@ -1020,3 +1122,103 @@ func TestToErrorResponse(t *testing.T) {
 		assert.Equal("test123", e2.Error.Message)
 	})
 }
+
+func TestNewRenderError(t *testing.T) {
+	t.Run("should render the reason code", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ErrUserNotFound := ErrNotFound.WithReason("user_not_found")
+		assert.Equal("", ErrNotFound.Reason, "should not change the original error")
+		err := ErrUserNotFound.WithMsg("user 123 not found")
+		assert.Equal("user_not_found", err.Reason)
+		assert.Equal("user_not_found", ToErrorResponse(err).Error.Reason)
+
+		body, _ := json.Marshal(err)
+		assert.Equal(`{"error":"NotFound","message":"user 123 not found","reason":"user_not_found"}`, string(body))
+
+		code, contentType, body := NewRenderError(RenderErrorOptions{})(err)
+		assert.Equal(404, code)
+		assert.Equal(MIMEApplicationJSONCharsetUTF8, contentType)
+		assert.Equal(`{"error":"NotFound","message":"user 123 not found","reason":"user_not_found"}`, string(body))
+
+		_, _, body = NewRenderError(RenderErrorOptions{})(ErrBadRequest.WithMsg("x"))
+		assert.Equal(`{"error":"BadRequest","message":"x"}`, string(body))
+	})
+
+	t.Run("should render with options", func(t *testing.T) {
+		assert := assert.New(t)
+
+		render := NewRenderError(RenderErrorOptions{Status: true, DefaultReason: true})
+		_, _, body := render(ErrNotFound.WithReason("user_not_found").WithMsg("x"))
+		assert.Equal(`{"error":"NotFound","message":"x","reason":"user_not_found","status":404}`, string(body))
+
+		code, _, body := render(ErrRequestURITooLong.WithMsg("x"))
+		assert.Equal(414, code)
+		assert.Equal(`{"error":"RequestURITooLong","message":"x","reason":"request_uri_too_long","status":414}`, string(body))
+
+		err := ErrorWithStack(errors.New("some error"))
+		_, _, body = render(err)
+		assert.Equal(`{"error":"InternalServerError","message":"some error","reason":"internal_server_error","status":500}`, string(body))
+		assert.Equal("", err.Reason, "should not change the rendered error")
+
+		code, _, body = render(&textError{code: 502, msg: "bad gateway"})
+		assert.Equal(502, code)
+		assert.Equal(`{"error":"Bad Gateway","message":"bad gateway","reason":"bad_gateway","status":502}`, string(body))
+	})
+
+	t.Run("snakeCase", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.Equal("not_found", snakeCase("NotFound"))
+		assert.Equal("request_uri_too_long", snakeCase("RequestURITooLong"))
+		assert.Equal("request_uri_too_long", snakeCase("Request URI Too Long"))
+		assert.Equal("http_version_not_supported", snakeCase("HTTPVersionNotSupported"))
+		assert.Equal("error", snakeCase("Error"))
+		assert.Equal("", snakeCase(""))
+	})
+}
+
+type textError struct {
+	code int
+	msg  string
+}
+
+func (e *textError) Error() string { return e.msg }
+func (e *textError) Status() int   { return e.code }
+
+func TestGearOnlyEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Panics(func() {
+		OnlyEnv("development", nil)
+	})
+
+	app := New()
+	app.Use(OnlyEnv("development", Named("dumper", func(ctx *Context) error {
+		return ctx.HTML(200, "development")
+	})))
+	app.Use(OnlyEnv("production", func(ctx *Context) error {
+		ctx.SetHeader("X-Env", "production")
+		return nil
+	}))
+	app.Use(func(ctx *Context) error {
+		return ctx.HTML(200, "OK")
+	})
+	assert.Equal("dumper", app.Middlewares()[0])
+	assert.Equal("github.com/teambition/gear.TestGearOnlyEnv.func3", app.Middlewares()[1])
+
+	srv := app.Start()
+	defer srv.Close()
+	host := "http://" + srv.Addr().String()
+
+	res, err := RequestBy("GET", host)
+	assert.Nil(err)
+	assert.Equal("development", PickRes(res.Text()).(string))
+	assert.Equal("", res.Header.Get("X-Env"))
+
+	app.Set(SetEnv, "production")
+	res, err = RequestBy("GET", host)
+	assert.Nil(err)
+	assert.Equal("OK", PickRes(res.Text()).(string))
+	assert.Equal("production", res.Header.Get("X-Env"))
+}
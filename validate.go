@@ -0,0 +1,109 @@
+package gear
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Validate checks the app for the common misconfigurations, and returns the errors with clear messages.
+// It runs once automatically when the app starts serving (Listen, ListenTLS, ListenWithContext, etc.),
+// the app fails fast with the joined errors instead of serving with a broken configuration.
+// Start closes the listener and returns the errors by ServerListener.Wait. It checks:
+//
+//   - the SetTimeout setting and RouterOptions.Timeout should be less than app.Server.WriteTimeout,
+//     otherwise the timed out responses can't be written.
+//   - the ThresholdCompress of the SetCompress setting should be positive, otherwise the tiny responses are compressed.
+//   - the SetKeys setting should not have empty keys.
+//   - the routes should not be defined by multiple routers, and the routers should not be shadowed by
+//     the Otherwise handler of the previous router, they are unreachable.
+//   - the validators added by app.AddValidator, such as gear.RequireKeys.
+//
+// The usage of the signed cookies can't be detected before serving, so the SetKeys setting is not
+// required by default, add gear.RequireKeys validator to require it.
+func (app *App) Validate() []error {
+	var errs []error
+	if wt := app.Server.WriteTimeout; wt > 0 {
+		if app.timeout >= wt {
+			errs = append(errs, Err.WithMsgf("SetTimeout setting (%v) should be less than Server.WriteTimeout (%v)", app.timeout, wt))
+		}
+		for _, r := range app.routers {
+			if r.timeout >= wt {
+				errs = append(errs, Err.WithMsgf("Timeout of router %q (%v) should be less than Server.WriteTimeout (%v)", r.root, r.timeout, wt))
+			}
+		}
+	}
+	if tc, ok := app.compress.(ThresholdCompress); ok && tc <= 0 {
+		errs = append(errs, Err.WithMsgf("SetCompress setting ThresholdCompress(%d) should be positive, such as ThresholdCompress(1024)", tc))
+	}
+	for i, key := range app.keys {
+		if key == "" {
+			errs = append(errs, Err.WithMsgf("SetKeys setting has an empty key at index %d", i))
+		}
+	}
+	errs = append(errs, app.validateRouters()...)
+	for _, fn := range app.validators {
+		if err := fn(app); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (app *App) validateRouters() []error {
+	var errs []error
+	defined := make(map[string]string) // "METHOD /path" -> router root
+	for i, r := range app.routers {
+		for _, prev := range app.routers[:i] {
+			if prev.otherwise != nil && strings.HasPrefix(r.root, prev.root) {
+				errs = append(errs, Err.WithMsgf("router %q is unreachable, the router %q before it has an Otherwise handler", r.root, prev.root))
+				break
+			}
+		}
+		for _, route := range r.routes {
+			key := route.method + " " + r.rt + route.pattern
+			if root, ok := defined[key]; ok && root != r.root {
+				errs = append(errs, Err.WithMsgf("route %s is defined by router %q and router %q, the latter is unreachable", key, root, r.root))
+				continue
+			}
+			defined[key] = r.root
+		}
+	}
+	return errs
+}
+
+// AddValidator adds a validator that checks the app configuration, it runs by app.Validate.
+// The middlewares and handlers can use it to declare their requirements to the app:
+//
+//	app.AddValidator(gear.RequireKeys) // signed cookies are used
+//	app.AddValidator(func(app *gear.App) error {
+//		if app.Env() == "production" && os.Getenv("DATABASE_URL") == "" {
+//			return gear.Err.WithMsg("DATABASE_URL is required")
+//		}
+//		return nil
+//	})
+func (app *App) AddValidator(fn func(app *App) error) *App {
+	app.validators = append(app.validators, fn)
+	return app
+}
+
+// RequireKeys is a validator for app.AddValidator, it requires the SetKeys setting for the signed cookies,
+// ctx.Cookies panics when setting or getting the signed cookies without keys. It should be added
+// explicitly by the app or the middlewares that use the signed cookies.
+func RequireKeys(app *App) error {
+	if len(app.keys) == 0 {
+		return Err.WithMsg("SetKeys setting is required by the signed cookies")
+	}
+	return nil
+}
+
+// prepare validates the app and sets the handler of app.Server, it is called once by every entry point
+// that starts serving, so the app fails fast with the joined errors of app.Validate.
+func (app *App) prepare(handler http.Handler) error {
+	if err := errors.Join(app.Validate()...); err != nil {
+		return err
+	}
+	app.Server.ErrorLog = app.logger
+	app.Server.Handler = handler
+	return nil
+}
@@ -0,0 +1,96 @@
+package gear
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGearAppValidate(t *testing.T) {
+	t.Run("should pass by default", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		router := NewRouter()
+		router.Get("/", func(ctx *Context) error { return nil })
+		app.UseHandler(router)
+		assert.Nil(app.Validate())
+		assert.Nil(app.prepare(app))
+	})
+
+	t.Run("should check the settings", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.Set(SetTimeout, 3*time.Minute)
+		app.Set(SetCompress, ThresholdCompress(0))
+		app.Set(SetKeys, []string{"key", ""})
+		app.UseHandler(NewRouter(RouterOptions{Root: "/upload", Timeout: 5 * time.Minute}))
+
+		errs := app.Validate()
+		assert.Equal(4, len(errs))
+		assert.Equal("Error: SetTimeout setting (3m0s) should be less than Server.WriteTimeout (2m0s)", errs[0].Error())
+		assert.Equal(`Error: Timeout of router "/upload/" (5m0s) should be less than Server.WriteTimeout (2m0s)`, errs[1].Error())
+		assert.Equal("Error: SetCompress setting ThresholdCompress(0) should be positive, such as ThresholdCompress(1024)", errs[2].Error())
+		assert.Equal("Error: SetKeys setting has an empty key at index 1", errs[3].Error())
+
+		app.Server.WriteTimeout = 0
+		assert.Equal(2, len(app.Validate()))
+	})
+
+	t.Run("should check the routers", func(t *testing.T) {
+		assert := assert.New(t)
+
+		handler := func(ctx *Context) error { return nil }
+		app := New()
+		r1 := NewRouter()
+		r1.Get("/api/users", handler)
+		r2 := NewRouter(RouterOptions{Root: "/api"})
+		r2.Get("/users", handler)
+		r2.Post("/users", handler)
+		r2.Otherwise(handler)
+		r3 := NewRouter(RouterOptions{Root: "/api/v2"})
+		r3.Get("/users", handler)
+		app.UseHandler(r1)
+		app.UseHandler(r2)
+		app.UseHandler(r3)
+
+		errs := app.Validate()
+		assert.Equal(2, len(errs))
+		assert.Equal(`Error: route GET /api/users is defined by router "/" and router "/api/", the latter is unreachable`, errs[0].Error())
+		assert.Equal(`Error: router "/api/v2/" is unreachable, the router "/api/" before it has an Otherwise handler`, errs[1].Error())
+	})
+
+	t.Run("should run the validators", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.AddValidator(RequireKeys)
+		errs := app.Validate()
+		assert.Equal(1, len(errs))
+		assert.Equal("Error: SetKeys setting is required by the signed cookies", errs[0].Error())
+
+		app.Set(SetKeys, []string{"some key"})
+		assert.Nil(app.Validate())
+	})
+
+	t.Run("should fail fast when listening", func(t *testing.T) {
+		assert := assert.New(t)
+
+		app := New()
+		app.AddValidator(RequireKeys)
+		app.Set(SetCompress, ThresholdCompress(-1))
+
+		err := app.Listen("127.0.0.1:0")
+		assert.Equal("Error: SetCompress setting ThresholdCompress(-1) should be positive, such as ThresholdCompress(1024)\nError: SetKeys setting is required by the signed cookies", err.Error())
+		assert.NotNil(app.ListenTLS("127.0.0.1:0", "", ""))
+		assert.NotNil(app.ListenWithContext(context.Background(), "127.0.0.1:0"))
+		assert.NotNil(app.ServeWithContext(context.Background(), nil))
+		assert.NotNil(NewGroup().Add(app, "127.0.0.1:0").Run(context.Background()))
+		srv := app.Start()
+		assert.Equal(err, srv.Wait())
+		assert.NotNil(srv.Addr())
+	})
+}